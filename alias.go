@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// aliases são os comandos definidos pelo usuário com "alias.<nome> = comando; comando" na configuração.
+// O corpo segue a sintaxe dos scripts; $1 a $9 são trocados pelos argumentos do alias e $@ por todos eles.
+// Sem nenhum desses marcadores, os argumentos são acrescentados ao fim do último comando.
+var aliases = map[string]string{}
+
+// runningAliases guarda os aliases em execução, para impedir que um alias chame a si mesmo.
+var runningAliases = map[string]bool{}
+
+// loadAliases carrega os aliases da configuração. Um alias não pode ter o nome de um comando.
+func loadAliases() error {
+	config, err := loadConfig(configPath())
+	if err != nil {
+		return err
+	}
+	for name := range config.Aliases {
+		if name == "-c" || findCommand(name) != nil {
+			return classErrorf(ErrUsage, "erro: o alias '%s' tem o nome de um comando", name)
+		}
+	}
+	aliases = config.Aliases
+	return nil
+}
+
+// aliasCommand retorna a função que executa o alias name, ou nil se ele não existir.
+func aliasCommand(name string) func(fs *FURGFileSystem, args []string) error {
+	if _, ok := aliases[name]; !ok {
+		return nil
+	}
+	return func(fs *FURGFileSystem, args []string) error {
+		return fs.runAlias(name, args)
+	}
+}
+
+// runAlias executa os comandos do alias name com os argumentos args. A política de confirmação atual
+// (--force, --override-protection ou as perguntas do menu) vale para todos os comandos do alias.
+func (fs *FURGFileSystem) runAlias(name string, args []string) error {
+	if runningAliases[name] {
+		return classErrorf(ErrUsage, "erro: o alias '%s' chama a si mesmo", name)
+	}
+	runningAliases[name] = true
+	defer delete(runningAliases, name)
+
+	logger.Debug("alias", "name", name, "args", args)
+	return fs.runScript(expandAlias(aliases[name], args), fs.Policy)
+}
+
+// expandAlias troca os marcadores $1 a $9 e $@ do corpo pelos argumentos, protegidos por aspas quando necessário.
+func expandAlias(body string, args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteScriptArg(arg)
+	}
+
+	var b strings.Builder
+	used := false
+	for i := 0; i < len(body); i++ {
+		if body[i] != '$' || i+1 == len(body) {
+			b.WriteByte(body[i])
+			continue
+		}
+		switch next := body[i+1]; {
+		case next == '@':
+			b.WriteString(strings.Join(quoted, " "))
+		case next >= '1' && next <= '9':
+			if n, _ := strconv.Atoi(string(next)); n <= len(quoted) {
+				b.WriteString(quoted[n-1])
+			}
+		default:
+			b.WriteByte(body[i])
+			continue
+		}
+		used = true
+		i++
+	}
+	if !used && len(quoted) > 0 {
+		b.WriteString(" " + strings.Join(quoted, " "))
+	}
+	return b.String()
+}
+
+// quoteScriptArg envolve arg em aspas quando ele contém caracteres que parseScript interpretaria.
+func quoteScriptArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\r\n;|#'\"") {
+		return arg
+	}
+	if strings.Contains(arg, "'") {
+		return `"` + arg + `"`
+	}
+	return "'" + arg + "'"
+}
+
+// cliAlias implementa "alias [nome]": lista os aliases da configuração ou mostra o corpo de um deles.
+func cliAlias(fs *FURGFileSystem, args []string) error {
+	if len(args) > 1 {
+		return classErrorf(ErrUsage, "uso: furgfs alias [nome]")
+	}
+	if len(args) == 1 {
+		body, ok := aliases[args[0]]
+		if !ok {
+			return classErrorf(ErrNotFound, "erro: alias '%s' não definido", args[0])
+		}
+		fmt.Println(body)
+		return nil
+	}
+
+	if len(aliases) == 0 {
+		fmt.Println(tr("Nenhum alias definido; use \"alias.<nome> = comando; comando\" no arquivo de configuração."))
+		return nil
+	}
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s = %s\n", name, aliases[name])
+	}
+	return nil
+}
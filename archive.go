@@ -0,0 +1,269 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ImportTar lê um arquivo tar (opcionalmente compactado com gzip) de r e recria sua estrutura de diretórios
+// e arquivos dentro de destPath no FURGfs2. Entradas que não são arquivos regulares nem diretórios
+// (links, dispositivos etc.) são ignoradas.
+func (fs *FURGFileSystem) ImportTar(r io.Reader, destPath string) error {
+	destPath = fs.resolvePath(destPath)
+	if fs.CheckDirectoryExists(destPath) == -1 {
+		return classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", destPath)
+	}
+
+	br := bufio.NewReader(r)
+	var source io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("erro ao descompactar o arquivo gzip: %v", err)
+		}
+		defer gz.Close()
+		source = gz
+	}
+
+	tr := tar.NewReader(source)
+	files, directories, skipped := 0, 0, 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("erro ao ler o arquivo tar: %v", err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = fs.importArchiveEntry(destPath, header.Name, true, nil)
+			directories++
+		case tar.TypeReg:
+			err = fs.importArchiveEntry(destPath, header.Name, false, tr)
+			files++
+		default:
+			fmt.Printf("Ignorando '%s': tipo de entrada não suportado.\n", header.Name)
+			skipped++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("erro ao importar '%s': %w", header.Name, err)
+		}
+	}
+
+	fmt.Printf("Importação concluída: %d arquivo(s), %d diretório(s), %d entrada(s) ignorada(s).\n", files, directories, skipped)
+	return nil
+}
+
+// ImportZip lê um arquivo zip acessível por ra (de tamanho size) e recria seus diretórios e arquivos dentro de destPath.
+// ra pode ser um arquivo do sistema real ou um arquivo já armazenado no FURGfs2 (ver newFileReaderAt).
+func (fs *FURGFileSystem) ImportZip(ra io.ReaderAt, size int64, destPath string) error {
+	destPath = fs.resolvePath(destPath)
+	if fs.CheckDirectoryExists(destPath) == -1 {
+		return classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", destPath)
+	}
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return fmt.Errorf("erro ao ler o arquivo zip: %v", err)
+	}
+
+	files, directories := 0, 0
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			err = fs.importArchiveEntry(destPath, zf.Name, true, nil)
+			directories++
+		} else {
+			var rc io.ReadCloser
+			rc, err = zf.Open()
+			if err == nil {
+				err = fs.importArchiveEntry(destPath, zf.Name, false, rc)
+				rc.Close()
+			}
+			files++
+		}
+		if err != nil {
+			return fmt.Errorf("erro ao importar '%s': %w", zf.Name, err)
+		}
+	}
+
+	fmt.Printf("Importação concluída: %d arquivo(s), %d diretório(s).\n", files, directories)
+	return nil
+}
+
+// importExternalZip expande em destPath um arquivo zip do sistema real.
+func (fs *FURGFileSystem) importExternalZip(zipPath, destPath string) error {
+	f, err := os.Open(zipPath)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("erro ao obter informações do arquivo: %w", err)
+	}
+	return fs.ImportZip(f, info.Size(), destPath)
+}
+
+// importInternalZip expande em destPath um arquivo zip já armazenado no FURGfs2, lendo-o diretamente dos blocos.
+func (fs *FURGFileSystem) importInternalZip(zipPath, destPath string) error {
+	parent, name := splitPath(fs.resolvePath(zipPath))
+	rootDirIndex := fs.lookupEntry(name, parent)
+	if rootDirIndex == -1 || fs.RootDir[rootDirIndex].IsDirectory {
+		return classErrorf(ErrNotFound, "erro: O arquivo '%s' não foi encontrado no sistema de arquivos", zipPath)
+	}
+
+	// A entrada é copiada porque a importação pode reorganizar o diretório
+	entry := fs.RootDir[rootDirIndex]
+	ra, err := fs.newFileReaderAt(&entry)
+	if err != nil {
+		return err
+	}
+	return fs.ImportZip(ra, int64(entry.Size), destPath)
+}
+
+// importArchiveEntry cria dentro de destPath o diretório ou arquivo de nome relativo name vindo de um tar ou zip,
+// criando os diretórios intermediários que faltarem. Nomes com ".." não conseguem sair de destPath.
+func (fs *FURGFileSystem) importArchiveEntry(destPath, name string, isDir bool, r io.Reader) error {
+	destPath = fs.resolvePath(destPath)
+	relative := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if relative == "" {
+		return nil
+	}
+	parent, base := splitPath(joinPath(destPath, relative))
+
+	if isDir {
+		return fs.CreateDirectory(base, parent, true)
+	}
+	err := fs.createParentDirectories(parent)
+	if err != nil {
+		return err
+	}
+	_, err = fs.createFile(parent, base, r, false)
+	return err
+}
+
+// Formatos aceitos por ExportArchive.
+const (
+	archiveTar   = "tar"
+	archiveTarGz = "tar.gz"
+	archiveZip   = "zip"
+)
+
+// archiveFormatFromName deduz o formato do arquivo compactado pela extensão do nome, usando tar como padrão.
+func archiveFormatFromName(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return archiveZip
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return archiveTarGz
+	default:
+		return archiveTar
+	}
+}
+
+// ExportArchive escreve em w o diretório dirPath do FURGfs2, com todo o seu conteúdo, no formato informado
+// (tar, tar.gz ou zip). Os nomes dentro do arquivo começam pelo nome do próprio diretório; para a raiz,
+// as entradas ficam no topo do arquivo. O diretório de sistema só é exportado quando pedido explicitamente,
+// para que o arquivo possa ser importado em outro volume, que já tem seus próprios metadados.
+func (fs *FURGFileSystem) ExportArchive(dirPath string, w io.Writer, format string) error {
+	dirPath = fs.resolvePath(dirPath)
+	if fs.CheckDirectoryExists(dirPath) == -1 {
+		return classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", dirPath)
+	}
+
+	parent, base := splitPath(dirPath)
+	var indices []int
+	if dirPath != "/" {
+		indices = append(indices, fs.lookupEntry(base, parent))
+	}
+	for _, i := range fs.entriesInDirectory(dirPath, true) {
+		if path := fs.RootDir[i].FullPath(); dirPath == "/" && (path == systemDir || strings.HasPrefix(path, systemDir+"/")) {
+			continue
+		}
+		indices = append(indices, i)
+	}
+	sort.Slice(indices, func(a, b int) bool {
+		return fs.RootDir[indices[a]].FullPath() < fs.RootDir[indices[b]].FullPath()
+	})
+
+	// archiveName converte o caminho interno no nome relativo usado dentro do arquivo
+	archiveName := func(entry *FileEntry) string {
+		return strings.TrimPrefix(strings.TrimPrefix(entry.FullPath(), parent), "/")
+	}
+	if dirPath == "/" {
+		archiveName = func(entry *FileEntry) string {
+			return strings.TrimPrefix(entry.FullPath(), "/")
+		}
+	}
+
+	switch format {
+	case archiveTar, archiveTarGz:
+		if format == archiveTarGz {
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			w = gz
+		}
+		tw := tar.NewWriter(w)
+		for _, i := range indices {
+			entry := &fs.RootDir[i]
+			header := &tar.Header{Name: archiveName(entry), Mode: 0644, Size: int64(entry.Size), Typeflag: tar.TypeReg}
+			if entry.IsDirectory {
+				header = &tar.Header{Name: archiveName(entry) + "/", Mode: 0755, Typeflag: tar.TypeDir}
+			} else if entry.Protected {
+				header.Mode = 0444
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return fmt.Errorf("erro ao escrever o arquivo tar: %v", err)
+			}
+			if !entry.IsDirectory {
+				if err := fs.exportFile(entry, tw); err != nil {
+					return err
+				}
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return fmt.Errorf("erro ao finalizar o arquivo tar: %v", err)
+		}
+	case archiveZip:
+		zw := zip.NewWriter(w)
+		for _, i := range indices {
+			entry := &fs.RootDir[i]
+			if entry.IsDirectory {
+				if _, err := zw.Create(archiveName(entry) + "/"); err != nil {
+					return fmt.Errorf("erro ao escrever o arquivo zip: %v", err)
+				}
+				continue
+			}
+			header := &zip.FileHeader{Name: archiveName(entry), Method: zip.Deflate}
+			header.SetMode(0644)
+			if entry.Protected {
+				header.SetMode(0444)
+			}
+			fw, err := zw.CreateHeader(header)
+			if err != nil {
+				return fmt.Errorf("erro ao escrever o arquivo zip: %v", err)
+			}
+			if err := fs.exportFile(entry, fw); err != nil {
+				return err
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("erro ao finalizar o arquivo zip: %v", err)
+		}
+	default:
+		return fmt.Errorf("erro: formato '%s' desconhecido (use tar, tar.gz ou zip)", format)
+	}
+	return nil
+}
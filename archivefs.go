@@ -0,0 +1,331 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	iofs "io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Com a opção --archives de serve e mount, os arquivos zip e tar (com ou sem gzip) armazenados aparecem
+// como diretórios somente leitura com o seu conteúdo: /arquivos/dados.zip/docs/a.txt. O conteúdo é lido
+// direto dos blocos da imagem, sem extrair o arquivo: os membros de um tar sem compressão são lidos em
+// qualquer posição, e os de um zip ou tar.gz são descompactados do início, o que favorece a leitura
+// sequencial. O índice de cada arquivo é montado no primeiro acesso e refeito se ele for substituído.
+
+// isArchiveName informa se name tem a extensão de um formato que pode ser navegado.
+func isArchiveName(name string) bool {
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveMember é um arquivo ou diretório dentro de um arquivo navegável.
+type archiveMember struct {
+	dir     bool
+	size    int64
+	modTime time.Time
+	zip     *zip.File // membro de um zip
+	offset  int64     // início do conteúdo em um tar sem compressão; -1 nos outros formatos
+	ordinal int       // posição do membro em um tar.gz, para encontrá-lo de novo
+}
+
+// archiveIndex é o conteúdo de um arquivo navegável, pelo caminho dentro dele ("/" é a raiz).
+type archiveIndex struct {
+	first, size uint32 // entrada de origem, para saber se o arquivo foi substituído
+	reader      *fileReaderAt
+	gzip        bool
+	members     map[string]*archiveMember
+	children    map[string][]string
+
+	// cursor é a leitura em andamento de um membro compactado; a próxima leitura a partir de pos continua
+	// dela em vez de descompactar o membro de novo desde o início
+	cursor struct {
+		member *archiveMember
+		r      io.Reader
+		close  func() error
+		pos    int64
+	}
+}
+
+// archiveOf informa se p é um arquivo navegável ou está dentro de um, retornando o caminho do arquivo e o
+// caminho dentro dele ("" para o próprio arquivo).
+func (v *sharedVolume) archiveOf(p string) (archive, inner string, ok bool) {
+	if !v.archives || v.hidden(p) {
+		return "", "", false
+	}
+	for i := 1; i <= len(p); i++ {
+		if i < len(p) && p[i] != '/' {
+			continue
+		}
+		dir, name := splitPath(p[:i])
+		index := v.fs.lookupEntry(name, dir)
+		if index == -1 {
+			return "", "", false
+		}
+		if v.fs.RootDir[index].IsDirectory {
+			continue
+		}
+		if !isArchiveName(name) {
+			return "", "", false
+		}
+		return p[:i], p[i:], true
+	}
+	return "", "", false
+}
+
+// checkWritable recusa alterações nos arquivos navegáveis e no seu conteúdo, que são somente leitura.
+func (v *sharedVolume) checkWritable(paths ...string) error {
+	for _, p := range paths {
+		if _, _, ok := v.archiveOf(p); ok {
+			return iofs.ErrPermission
+		}
+	}
+	return nil
+}
+
+// archiveIndex retorna o índice do arquivo navegável archive, montando-o se preciso.
+func (v *sharedVolume) archiveIndex(archive string) (*archiveIndex, error) {
+	dir, name := splitPath(archive)
+	entry := &v.fs.RootDir[v.fs.lookupEntry(name, dir)]
+	if index := v.archiveCache[archive]; index != nil && index.first == entry.FirstBlockID && index.size == entry.Size {
+		return index, nil
+	}
+	reader, err := v.fs.newFileReaderAt(entry)
+	if err != nil {
+		return nil, err
+	}
+	index := &archiveIndex{first: entry.FirstBlockID, size: entry.Size, reader: reader,
+		members: map[string]*archiveMember{"/": {dir: true}}, children: make(map[string][]string)}
+	magic := make([]byte, 2)
+	if n, _ := reader.ReadAt(magic, 0); n == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		index.gzip = true
+	}
+	if strings.HasSuffix(strings.ToLower(name), ".zip") {
+		err = index.readZip()
+	} else {
+		err = index.readTar()
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, names := range index.children {
+		sort.Strings(names)
+	}
+	if v.archiveCache == nil {
+		v.archiveCache = make(map[string]*archiveIndex)
+	}
+	v.archiveCache[archive] = index
+	return index, nil
+}
+
+// add acrescenta o membro de nome name, com os diretórios intermediários que faltarem. Nomes que não
+// ficam dentro do arquivo são ignorados.
+func (a *archiveIndex) add(name string, member *archiveMember) {
+	p := path.Clean("/" + name)
+	if p == "/" || strings.Contains(name, "..") {
+		return
+	}
+	if old, ok := a.members[p]; ok {
+		if old.dir && member.dir {
+			return
+		}
+	} else {
+		parent := path.Dir(p)
+		if _, ok := a.members[parent]; !ok {
+			a.add(parent, &archiveMember{dir: true, modTime: member.modTime, offset: -1})
+		}
+		a.children[parent] = append(a.children[parent], path.Base(p))
+	}
+	a.members[p] = member
+}
+
+func (a *archiveIndex) readZip() error {
+	r, err := zip.NewReader(a.reader, int64(a.size))
+	if err != nil {
+		return classErrorf(ErrCorrupted, "erro ao abrir o arquivo zip: %v", err)
+	}
+	for _, f := range r.File {
+		dir := strings.HasSuffix(f.Name, "/")
+		a.add(f.Name, &archiveMember{dir: dir, size: int64(f.UncompressedSize64), modTime: f.Modified, zip: f, offset: -1})
+	}
+	return nil
+}
+
+// countingReader conta os bytes lidos de um tar sem compressão, para saber onde começa cada membro.
+// Seek permite que tar.Reader pule o conteúdo sem lê-lo.
+type countingReader struct {
+	r   *io.SectionReader
+	pos int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.pos += int64(n)
+	return n, err
+}
+
+func (c *countingReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := c.r.Seek(offset, whence)
+	c.pos = pos
+	return pos, err
+}
+
+func (a *archiveIndex) readTar() error {
+	source := io.NewSectionReader(a.reader, 0, int64(a.size))
+	counter := &countingReader{r: source}
+	var r io.Reader = counter
+	if a.gzip {
+		gz, err := gzip.NewReader(source)
+		if err != nil {
+			return classErrorf(ErrCorrupted, "erro ao descompactar o arquivo gzip: %v", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for ordinal := 0; ; ordinal++ {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return classErrorf(ErrCorrupted, "erro ao ler o arquivo tar: %v", err)
+		}
+		member := &archiveMember{size: header.Size, modTime: header.ModTime, offset: -1, ordinal: ordinal}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			member.dir, member.size = true, 0
+		case tar.TypeReg:
+			if !a.gzip {
+				member.offset = counter.pos
+			}
+		default:
+			continue
+		}
+		a.add(header.Name, member)
+	}
+}
+
+// info descreve o membro p, de nome name.
+func (m *archiveMember) info(name string) entryInfo {
+	return entryInfo{name: name, size: m.size, dir: m.dir, protected: true, modTime: m.modTime}
+}
+
+// archiveStat descreve o membro inner do arquivo archive ("" é a raiz).
+func (v *sharedVolume) archiveStat(archive, inner string) (entryInfo, error) {
+	index, err := v.archiveIndex(archive)
+	if err != nil {
+		return entryInfo{}, err
+	}
+	if inner == "" {
+		inner = "/"
+	}
+	member, ok := index.members[inner]
+	if !ok {
+		return entryInfo{}, iofs.ErrNotExist
+	}
+	return member.info(path.Base(inner)), nil
+}
+
+// archiveReadDir lista o diretório inner do arquivo archive ("" é a raiz).
+func (v *sharedVolume) archiveReadDir(archive, inner string) ([]entryInfo, error) {
+	index, err := v.archiveIndex(archive)
+	if err != nil {
+		return nil, err
+	}
+	if inner == "" {
+		inner = "/"
+	}
+	member, ok := index.members[inner]
+	if !ok {
+		return nil, iofs.ErrNotExist
+	}
+	if !member.dir {
+		return nil, errNotDir
+	}
+	var infos []entryInfo
+	for _, name := range index.children[inner] {
+		infos = append(infos, index.members[path.Join(inner, name)].info(name))
+	}
+	return infos, nil
+}
+
+// archiveReadAt lê o membro inner do arquivo archive a partir de off, como io.ReaderAt.
+func (v *sharedVolume) archiveReadAt(archive, inner string, buf []byte, off int64) (int, error) {
+	index, err := v.archiveIndex(archive)
+	if err != nil {
+		return 0, err
+	}
+	member, ok := index.members[inner]
+	switch {
+	case inner == "" || ok && member.dir:
+		return 0, errIsDirectory
+	case !ok:
+		return 0, iofs.ErrNotExist
+	case off >= member.size:
+		return 0, io.EOF
+	case member.offset >= 0:
+		return io.NewSectionReader(index.reader, member.offset, member.size).ReadAt(buf, off)
+	}
+
+	cursor := &index.cursor
+	if cursor.member != member || cursor.pos > off {
+		if err := index.open(member); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := io.CopyN(io.Discard, cursor.r, off-cursor.pos); err != nil {
+		index.closeCursor()
+		return 0, err
+	}
+	cursor.pos = off
+	n, err := io.ReadFull(cursor.r, buf[:min(int64(len(buf)), member.size-off)])
+	cursor.pos += int64(n)
+	if err == nil && n < len(buf) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// open posiciona o cursor no início do membro compactado member.
+func (a *archiveIndex) open(member *archiveMember) error {
+	a.closeCursor()
+	if member.zip != nil {
+		rc, err := member.zip.Open()
+		if err != nil {
+			return err
+		}
+		a.cursor.r, a.cursor.close = rc, rc.Close
+	} else {
+		gz, err := gzip.NewReader(io.NewSectionReader(a.reader, 0, int64(a.size)))
+		if err != nil {
+			return err
+		}
+		tr := tar.NewReader(gz)
+		for range member.ordinal + 1 {
+			if _, err := tr.Next(); err != nil {
+				gz.Close()
+				return err
+			}
+		}
+		a.cursor.r, a.cursor.close = tr, gz.Close
+	}
+	a.cursor.member, a.cursor.pos = member, 0
+	return nil
+}
+
+func (a *archiveIndex) closeCursor() {
+	if a.cursor.close != nil {
+		a.cursor.close()
+	}
+	a.cursor.member, a.cursor.r, a.cursor.close = nil, nil, nil
+}
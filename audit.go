@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A auditoria registra as operações que alteram o volume: quem fez, o quê, em qual caminho, quando e com
+// qual resultado. Os registros são linhas JSON acrescentadas a segmentos de até auditSegmentSize bytes no
+// diretório de sistema (/.furgfs/audit-000001.jsonl, ...), que nunca são reescritos depois de fechados, ou
+// a um arquivo externo. A configuração fica em /.furgfs/audit.json; sem ela, ou com disabled, nada é
+// registrado.
+const (
+	auditInfoName    = "audit.json"
+	auditPrefix      = "audit-"
+	auditSuffix      = ".jsonl"
+	auditSegmentSize = 64 << 10
+)
+
+// auditConfig é o conteúdo de /.furgfs/audit.json.
+type auditConfig struct {
+	File     string `json:"file,omitempty"` // arquivo externo; vazio grava os registros na própria imagem
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// auditState é a configuração lida da imagem; enabled é falso sem /.furgfs/audit.json ou com Disabled.
+type auditState struct {
+	enabled bool
+	auditConfig
+}
+
+// writeAuditConfig grava a configuração da auditoria e passa a usá-la.
+func (fs *FURGFileSystem) writeAuditConfig(config auditConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fs.writeSystemFile(auditInfoName, data); err != nil {
+		return err
+	}
+	if err := fs.saveFileSystemState(); err != nil {
+		return err
+	}
+	fs.audit = &auditState{enabled: !config.Disabled, auditConfig: config}
+	return nil
+}
+
+// auditRecord é um registro da auditoria. Os comandos da linha de comando registram seus argumentos; os
+// servidores, o caminho da operação.
+type auditRecord struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user,omitempty"`
+	Source string    `json:"source"` // cli, tui, menu, mount ou o protocolo de "serve"
+	Op     string    `json:"op"`
+	Path   string    `json:"path,omitempty"`
+	Target string    `json:"target,omitempty"` // destino de uma cópia ou de uma renomeação
+	Args   []string  `json:"args,omitempty"`
+	OK     bool      `json:"ok"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// auditSkipped são os comandos que nunca são registrados: as consultas e os que só executam outros
+// comandos ou servem o volume, cujas operações são registradas uma a uma.
+var auditSkipped = map[string]bool{
+	"ls": true, "tree": true, "stat": true, "df": true, "imagediff": true, "stats": true, "catalog": true, "cat": true,
+	"grep": true, "find": true, "get": true, "export-archive": true, "export-cloud": true, "verify-image": true, "selftest": true,
+	"layout": true, "cd": true, "pwd": true, "images": true, "open": true, "use": true, "close": true,
+	"help": true, "script": true, "shell": true, "tui": true, "serve": true, "mount": true,
+}
+
+// localUser é o usuário do sistema operacional que executa o FURGfs2, o autor das operações locais.
+var localUser = sync.OnceValue(func() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return strconv.Itoa(os.Getuid())
+})
+
+// auditSettings lê a configuração da auditoria uma única vez.
+func (fs *FURGFileSystem) auditSettings() (*auditState, error) {
+	if fs.audit != nil {
+		return fs.audit, nil
+	}
+	state := &auditState{}
+	data, err := fs.readSystemFile(auditInfoName)
+	if err == nil {
+		if err = json.Unmarshal(data, &state.auditConfig); err != nil {
+			return nil, classErrorf(ErrCorrupted, "erro ao ler %s: %v", joinPath(systemDir, auditInfoName), err)
+		}
+		state.enabled = !state.Disabled
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	fs.audit = state
+	return state, nil
+}
+
+// recordAudit acrescenta rec, com o resultado err, à auditoria, se ela estiver ativa. Uma falha ao registrar
+// é apenas avisada no log: a operação já foi feita.
+func (fs *FURGFileSystem) recordAudit(rec auditRecord, err error) {
+	state, serr := fs.auditSettings()
+	if serr != nil || !state.enabled {
+		if serr != nil {
+			logger.Warn("configuração de auditoria inválida", fs.imageAttr(), "error", serr)
+		}
+		return
+	}
+	rec.Time, rec.OK = time.Now(), err == nil
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	line, serr := json.Marshal(rec)
+	if serr == nil {
+		line = append(line, '\n')
+		if state.File != "" {
+			serr = appendAuditFile(state.File, line)
+		} else {
+			serr = fs.appendAuditSegment(line)
+		}
+	}
+	if serr != nil {
+		logger.Warn("erro ao registrar a auditoria", fs.imageAttr(), "op", rec.Op, "error", serr)
+		return
+	}
+	fs.indexAudit(rec)
+}
+
+// auditCommand registra o comando name da linha de comando, executado com args e terminado com err, se ele
+// alterou a imagem (saves é o contador de gravações antes do comando) ou falhou.
+func (fs *FURGFileSystem) auditCommand(name string, args []string, saves uint64, err error) {
+	if auditSkipped[name] || findCommand(name) == nil || err == nil && fs.saves == saves {
+		// os comandos de um alias ou de -c são registrados um a um
+		return
+	}
+	fs.recordAudit(auditRecord{User: localUser(), Source: "cli", Op: name, Args: redactSecrets(args)}, err)
+}
+
+// auditSecretFlags são as opções cujo valor não vai para a auditoria.
+var auditSecretFlags = []string{"-secret", "--secret"}
+
+// redactSecrets retorna uma cópia de args com os valores de auditSecretFlags trocados por "***".
+func redactSecrets(args []string) []string {
+	redacted := slices.Clone(args)
+	for i, arg := range redacted {
+		name, _, inline := strings.Cut(arg, "=")
+		if !slices.Contains(auditSecretFlags, name) {
+			continue
+		}
+		if inline {
+			redacted[i] = name + "=***"
+		} else if i+1 < len(redacted) {
+			redacted[i+1] = "***"
+		}
+	}
+	return redacted
+}
+
+func appendAuditFile(name string, line []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// auditSegments retorna os nomes dos segmentos da auditoria na imagem, do mais antigo ao mais novo.
+func (fs *FURGFileSystem) auditSegments() []string {
+	var names []string
+	for _, i := range fs.entriesInDirectory(systemDir, false) {
+		name := fs.RootDir[i].NameString()
+		if strings.HasPrefix(name, auditPrefix) && strings.HasSuffix(name, auditSuffix) && !fs.RootDir[i].IsDirectory {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// appendAuditSegment acrescenta line ao segmento atual, ou a um novo, se o atual já estiver cheio.
+func (fs *FURGFileSystem) appendAuditSegment(line []byte) error {
+	segments := fs.auditSegments()
+	name := fmt.Sprintf("%s%06d%s", auditPrefix, 1, auditSuffix)
+	var data []byte
+	if len(segments) > 0 {
+		name = segments[len(segments)-1]
+		content, err := fs.readSystemFile(name)
+		if err != nil {
+			return err
+		}
+		if len(content)+len(line) <= auditSegmentSize {
+			data = content
+		} else {
+			number, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, auditPrefix), auditSuffix))
+			name = fmt.Sprintf("%s%06d%s", auditPrefix, number+1, auditSuffix)
+		}
+	}
+	if err := fs.writeSystemFile(name, append(data, line...)); err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
+
+// AuditRecords lê os registros da auditoria, do mais antigo ao mais novo, do arquivo externo configurado
+// ou dos segmentos da imagem.
+func (fs *FURGFileSystem) AuditRecords() ([]auditRecord, error) {
+	state, err := fs.auditSettings()
+	if err != nil {
+		return nil, err
+	}
+	var records []auditRecord
+	parse := func(source string, data []byte) error {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, auditSegmentSize)
+		for line := 1; scanner.Scan(); line++ {
+			var rec auditRecord
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				return classErrorf(ErrCorrupted, "erro: registro inválido em %s, linha %d: %v", source, line, err)
+			}
+			records = append(records, rec)
+		}
+		return scanner.Err()
+	}
+	if state.File != "" {
+		data, err := os.ReadFile(state.File)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		err = parse(state.File, data)
+		return records, err
+	}
+	for _, name := range fs.auditSegments() {
+		data, err := fs.readSystemFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := parse(joinPath(systemDir, name), data); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// parseSince interpreta o início de "audit list --since": uma duração até agora (24h, 90m) ou uma data
+// (2006-01-02, 2006-01-02T15:04:05 ou RFC 3339), no fuso local.
+func parseSince(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, classErrorf(ErrUsage, "erro: data '%s' inválida (use 24h, 2006-01-02 ou 2006-01-02T15:04:05)", value)
+}
+
+// matchesPath informa se rec envolve p ou algo abaixo dele.
+func (rec auditRecord) matchesPath(p string) bool {
+	under := func(s string) bool {
+		return s == p || strings.HasPrefix(s, strings.TrimSuffix(p, "/")+"/")
+	}
+	if under(rec.Path) || under(rec.Target) {
+		return true
+	}
+	for _, arg := range rec.Args {
+		if under(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// cliAudit implementa "audit enable [--file <arquivo>]", "audit disable" e "audit list".
+func cliAudit(fs *FURGFileSystem, args []string) error {
+	usage := classErrorf(ErrUsage, "uso: furgfs audit enable [--file <arquivo>] | audit disable | audit list [--since <data>] [--user <nome>] [--path <caminho>] [--failed] [--json]")
+	if len(args) == 0 {
+		return usage
+	}
+	state, err := fs.auditSettings()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("audit "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "enable":
+		file := flags.String("file", "", "grava os registros neste arquivo, fora da imagem")
+		if err := flags.Parse(args[1:]); err != nil {
+			return classErrorf(ErrUsage, "%w", err)
+		}
+		if flags.NArg() != 0 {
+			return usage
+		}
+		config := auditConfig{File: *file}
+		if config.File != "" {
+			if config.File, err = filepath.Abs(config.File); err != nil {
+				return err
+			}
+		}
+		if err := fs.writeAuditConfig(config); err != nil {
+			return err
+		}
+		if config.File != "" {
+			fmt.Printf(tr("Auditoria ativa; os registros vão para '%s'.\n"), config.File)
+		} else {
+			fmt.Printf(tr("Auditoria ativa; os registros ficam em %s.\n"), joinPath(systemDir, auditPrefix+"*"+auditSuffix))
+		}
+		return nil
+	case "disable":
+		if len(args) != 1 {
+			return usage
+		}
+		if !state.enabled {
+			fmt.Println(tr("A auditoria já está desativada."))
+			return nil
+		}
+		// O último registro é o próprio desligamento; o arquivo externo continua configurado para "audit list"
+		fs.recordAudit(auditRecord{User: localUser(), Source: "cli", Op: "audit", Args: args}, nil)
+		config := state.auditConfig
+		config.Disabled = true
+		if err := fs.writeAuditConfig(config); err != nil {
+			return err
+		}
+		fmt.Println(tr("Auditoria desativada; os registros anteriores foram mantidos."))
+		return nil
+	case "list":
+		since := flags.String("since", "", "só os registros a partir desta data ou duração (ex.: 24h)")
+		userName := flags.String("user", "", "só as operações deste usuário")
+		path := flags.String("path", "", "só as operações neste caminho ou abaixo dele")
+		failed := flags.Bool("failed", false, "só as operações que falharam")
+		asJSON := flags.Bool("json", false, "saída em JSON")
+		if err := flags.Parse(args[1:]); err != nil {
+			return classErrorf(ErrUsage, "%w", err)
+		}
+		if flags.NArg() != 0 {
+			return usage
+		}
+		var start time.Time
+		if *since != "" {
+			if start, err = parseSince(*since); err != nil {
+				return err
+			}
+		}
+		var records []auditRecord
+		indexed := false
+		if *path != "" {
+			// Com o índice de metadados, só os registros do caminho são lidos
+			records, indexed = fs.indexedAuditRecords(normalizePath(*path))
+		}
+		if !indexed {
+			if records, err = fs.AuditRecords(); err != nil {
+				return err
+			}
+		}
+		selected := make([]auditRecord, 0, len(records))
+		for _, rec := range records {
+			if rec.Time.Before(start) || *userName != "" && rec.User != *userName ||
+				*path != "" && !rec.matchesPath(normalizePath(*path)) || *failed && rec.OK {
+				continue
+			}
+			selected = append(selected, rec)
+		}
+		if *asJSON {
+			return printJSON(struct {
+				Schema  int           `json:"schema"`
+				Enabled bool          `json:"enabled"`
+				Records []auditRecord `json:"records"`
+			}{jsonSchemaVersion, state.enabled, selected})
+		}
+		if len(selected) == 0 {
+			fmt.Println(tr("Nenhum registro de auditoria."))
+			return nil
+		}
+		fmt.Printf("%-19s %-12s %-6s %-10s %s\n", tr("Data"), tr("Usuário"), tr("Origem"), tr("Operação"), tr("Alvo e resultado"))
+		for _, rec := range selected {
+			what := strings.Join(rec.Args, " ")
+			if rec.Path != "" {
+				what = rec.Path
+				if rec.Target != "" {
+					what += " -> " + rec.Target
+				}
+			}
+			result := "ok"
+			if !rec.OK {
+				result = rec.Error
+			}
+			if what != "" {
+				result = what + ": " + result
+			}
+			user := rec.User
+			if user == "" {
+				user = "-"
+			}
+			fmt.Printf("%-19s %-12s %-6s %-10s %s\n", rec.Time.Local().Format("2006-01-02 15:04:05"), user, rec.Source, rec.Op, result)
+		}
+		return nil
+	default:
+		return usage
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Um backup é um arquivo tar compactado com gzip, no sistema real. O primeiro membro, backup.json, descreve todas
+// as entradas do volume no momento do backup; os demais guardam o conteúdo dos arquivos em data/<caminho>.
+// Um backup completo guarda todos os arquivos; um incremental, apenas os que mudaram desde um snapshot. Como os
+// blocos de dados não são reescritos no lugar, um arquivo mudou quando sua cadeia de blocos é outra.
+const (
+	backupManifestName = "backup.json"
+	backupDataPrefix   = "data"
+)
+
+// snapshotRef identifica um snapshot pelo nome e pela data de criação, para que um snapshot recriado com o
+// mesmo nome não seja confundido com o original.
+type snapshotRef struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+// backupManifest é o conteúdo de backup.json.
+type backupManifest struct {
+	Schema  int       `json:"schema"`
+	Volume  string    `json:"volume"` // UUID do volume, vazio em imagens sem metadados
+	Created time.Time `json:"created"`
+	// Since é o snapshot de referência de um backup incremental; nil em um backup completo
+	Since *snapshotRef `json:"since,omitempty"`
+	// Snapshot é o snapshot criado junto com o backup, base do próximo incremental
+	Snapshot *snapshotRef    `json:"snapshot,omitempty"`
+	Entries  []snapshotEntry `json:"entries"`
+	Files    int             `json:"files"` // arquivos cujo conteúdo está no backup
+}
+
+// Backup grava em w um backup do volume: completo, se since for vazio, ou apenas com os arquivos que mudaram
+// desde o snapshot since. Se snapshotName não for vazio, o snapshot com esse nome é criado com o mesmo estado
+// do backup, para servir de base ao próximo incremental; ele é apagado se o backup falhar.
+func (fs *FURGFileSystem) Backup(w io.Writer, since, snapshotName string) (manifest backupManifest, err error) {
+	manifest.Schema = jsonSchemaVersion
+	base := map[string]snapshotEntry{}
+	if since != "" {
+		snapshot, err := fs.readSnapshot(since)
+		if err != nil {
+			return manifest, err
+		}
+		manifest.Since = &snapshotRef{snapshot.Name, snapshot.Created}
+		for _, e := range snapshot.Entries {
+			base[e.Path] = e
+		}
+	}
+	info, err := fs.VolumeInfo()
+	if err != nil {
+		return manifest, err
+	}
+	manifest.Volume = info.UUID
+
+	if snapshotName != "" {
+		if err := fs.CreateSnapshot(snapshotName); err != nil {
+			return manifest, err
+		}
+		snapshot, err := fs.readSnapshot(snapshotName)
+		if err != nil {
+			return manifest, err
+		}
+		manifest.Snapshot = &snapshotRef{snapshot.Name, snapshot.Created}
+		defer func() {
+			if err != nil {
+				fs.dropSnapshot(snapshot)
+			}
+		}()
+	}
+
+	entries, err := fs.volumeEntries()
+	if err != nil {
+		return manifest, err
+	}
+	var changed []snapshotEntry
+	for i, e := range entries {
+		if !e.Directory && !sameBlocks(e, base[e.Path]) {
+			changed = append(changed, e)
+		}
+		entries[i].Blocks = nil
+	}
+	manifest.Created = time.Now().UTC().Truncate(time.Second)
+	manifest.Entries = entries
+	manifest.Files = len(changed)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	header := &tar.Header{Name: backupManifestName, Mode: 0644, Size: int64(len(data)), ModTime: manifest.Created}
+	if err := tw.WriteHeader(header); err != nil {
+		return manifest, fmt.Errorf("erro ao escrever o backup: %v", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return manifest, fmt.Errorf("erro ao escrever o backup: %v", err)
+	}
+	for _, e := range changed {
+		dir, name := splitPath(e.Path)
+		header := &tar.Header{Name: backupDataPrefix + e.Path, Mode: 0644, Size: int64(e.Size), ModTime: manifest.Created}
+		if err := tw.WriteHeader(header); err != nil {
+			return manifest, fmt.Errorf("erro ao escrever o backup: %v", err)
+		}
+		if err := fs.exportFile(&fs.RootDir[fs.lookupEntry(name, dir)], tw); err != nil {
+			return manifest, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return manifest, fmt.Errorf("erro ao finalizar o backup: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return manifest, fmt.Errorf("erro ao finalizar o backup: %v", err)
+	}
+
+	logger.Info("backup gravado", fs.imageAttr(), "entries", len(entries), "files", len(changed), "since", since)
+	return manifest, nil
+}
+
+// sameBlocks informa se o arquivo e tem a mesma cadeia de blocos que base.
+func sameBlocks(e, base snapshotEntry) bool {
+	if base.Path == "" || base.Directory || e.Size != base.Size || len(e.Blocks) != len(base.Blocks) {
+		return false
+	}
+	for k := range e.Blocks {
+		if e.Blocks[k] != base.Blocks[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// backupReader lê um arquivo de backup: o manifesto e, em seguida, os membros com o conteúdo dos arquivos.
+type backupReader struct {
+	name     string
+	file     *os.File
+	gz       *gzip.Reader
+	tar      *tar.Reader
+	manifest backupManifest
+}
+
+// openBackup abre o backup name e lê seu manifesto.
+func openBackup(name string) (*backupReader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "erro: não foi possível abrir o backup '%s': %v", name, err)
+	}
+	b := &backupReader{name: name, file: f}
+	b.gz, err = gzip.NewReader(f)
+	if err == nil {
+		b.tar = tar.NewReader(b.gz)
+		var header *tar.Header
+		header, err = b.tar.Next()
+		if err == nil && header.Name != backupManifestName {
+			err = errors.New("o primeiro membro não é o manifesto")
+		}
+		if err == nil {
+			err = json.NewDecoder(b.tar).Decode(&b.manifest)
+		}
+	}
+	if err != nil {
+		b.Close()
+		return nil, classErrorf(ErrCorrupted, "erro: '%s' não é um backup válido: %v", name, err)
+	}
+	return b, nil
+}
+
+func (b *backupReader) Close() {
+	if b.gz != nil {
+		b.gz.Close()
+	}
+	b.file.Close()
+}
+
+// openBackupChain abre os backups de names e verifica que formam uma cadeia: o primeiro é completo e cada
+// incremental parte do snapshot criado pelo backup anterior, no mesmo volume.
+func openBackupChain(names []string) ([]*backupReader, error) {
+	var chain []*backupReader
+	fail := func(err error) ([]*backupReader, error) {
+		for _, b := range chain {
+			b.Close()
+		}
+		return nil, err
+	}
+	for k, name := range names {
+		b, err := openBackup(name)
+		if err != nil {
+			return fail(err)
+		}
+		chain = append(chain, b)
+		if k == 0 {
+			if b.manifest.Since != nil {
+				return fail(classErrorf(ErrUsage, "erro: '%s' é incremental (desde o snapshot '%s'); a cadeia deve começar por um backup completo", name, b.manifest.Since.Name))
+			}
+			continue
+		}
+		previous := chain[k-1].manifest
+		switch {
+		case b.manifest.Since == nil:
+			return fail(classErrorf(ErrUsage, "erro: '%s' é um backup completo; apenas o primeiro da cadeia pode ser completo", name))
+		case previous.Snapshot == nil || *previous.Snapshot != *b.manifest.Since:
+			return fail(classErrorf(ErrUsage, "erro: '%s' parte do snapshot '%s', que não foi criado pelo backup '%s'", name, b.manifest.Since.Name, names[k-1]))
+		case previous.Volume != b.manifest.Volume:
+			return fail(classErrorf(ErrUsage, "erro: '%s' é de outro volume que '%s'", name, names[k-1]))
+		}
+	}
+	return chain, nil
+}
+
+// RestoreBackups substitui o conteúdo do volume, exceto o diretório de sistema, pelo estado registrado no último
+// backup da cadeia names, aplicando o backup completo e, em ordem, os incrementais.
+func (fs *FURGFileSystem) RestoreBackups(names []string) error {
+	chain, err := openBackupChain(names)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, b := range chain {
+			b.Close()
+		}
+	}()
+
+	last := chain[len(chain)-1].manifest
+	current, err := fs.confirmReplaceVolume("O conteúdo atual do volume será substituído pelo do backup de %s.", last.Created.Local().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return err
+	}
+	fs.discardEntries(current)
+
+	for _, b := range chain {
+		if err := fs.applyBackup(b); err != nil {
+			return err
+		}
+	}
+	if fs.CheckDirectoryExists(fs.WorkingDir) == -1 {
+		fs.WorkingDir = "/"
+	}
+
+	files := 0
+	for _, e := range last.Entries {
+		if !e.Directory {
+			files++
+		}
+	}
+	logger.Info("backup restaurado", fs.imageAttr(), "backups", len(chain), "entries", len(last.Entries))
+	fmt.Printf(tr("Volume restaurado de %d backup(s): %d entrada(s), %d arquivo(s).\n"), len(chain), len(last.Entries), files)
+	return nil
+}
+
+// applyBackup leva o volume ao estado do backup b: remove as entradas que ele não lista, cria os diretórios
+// que faltam, grava os arquivos que ele contém e confere que os demais vieram dos backups anteriores.
+func (fs *FURGFileSystem) applyBackup(b *backupReader) error {
+	wanted := make(map[string]snapshotEntry, len(b.manifest.Entries))
+	for _, e := range b.manifest.Entries {
+		wanted[e.Path] = e
+	}
+
+	var removed []int
+	for _, i := range fs.volumeContent() {
+		entry := &fs.RootDir[i]
+		if e, ok := wanted[entry.FullPath()]; !ok || e.Directory != entry.IsDirectory {
+			removed = append(removed, i)
+		}
+	}
+	fs.discardEntries(removed)
+
+	for _, e := range b.manifest.Entries {
+		if e.Directory && fs.CheckDirectoryExists(e.Path) == -1 {
+			dir, name := splitPath(e.Path)
+			if err := fs.CreateDirectory(name, dir, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		header, err := b.tar.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return classErrorf(ErrCorrupted, "erro ao ler o backup '%s': %v", b.name, err)
+		}
+		path := strings.TrimPrefix(header.Name, backupDataPrefix)
+		if e, ok := wanted[path]; !ok || e.Directory {
+			return classErrorf(ErrCorrupted, "erro: o backup '%s' contém '%s', que não está no manifesto", b.name, header.Name)
+		}
+		dir, name := splitPath(path)
+		if old := fs.lookupEntry(name, dir); old != -1 {
+			fs.discardEntries([]int{old})
+		}
+		if _, err := fs.createFile(dir, name, b.tar, false); err != nil {
+			return fmt.Errorf("erro ao restaurar '%s': %w", path, err)
+		}
+	}
+
+	for _, e := range b.manifest.Entries {
+		dir, name := splitPath(e.Path)
+		index := fs.lookupEntry(name, dir)
+		if index == -1 || (!e.Directory && fs.RootDir[index].Size != e.Size) {
+			return classErrorf(ErrCorrupted, "erro: o conteúdo de '%s' não está em '%s' nem nos backups anteriores", e.Path, b.name)
+		}
+		fs.setProtected(index, e.Protected)
+	}
+	return nil
+}
+
+// cliBackup implementa "backup [--since <snapshot>] [--snapshot <nome>] <arquivo>".
+func cliBackup(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	since := flags.String("since", "", "grava apenas os arquivos que mudaram desde o snapshot")
+	snapshotName := flags.String("snapshot", "", "cria um snapshot com o estado do backup, base do próximo incremental")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 1 {
+		return classErrorf(ErrUsage, "uso: furgfs backup [--since <snapshot>] [--snapshot <nome>] <arquivo>")
+	}
+
+	destFile, err := os.Create(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("erro ao criar o arquivo no sistema real: %v", err)
+	}
+	manifest, err := fs.Backup(destFile, *since, *snapshotName)
+	if closeErr := destFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(flags.Arg(0))
+		return err
+	}
+
+	if manifest.Since != nil {
+		fmt.Printf(tr("Backup incremental gravado em '%s': %d arquivo(s) alterado(s) desde o snapshot '%s', %d entrada(s) no volume.\n"),
+			flags.Arg(0), manifest.Files, manifest.Since.Name, len(manifest.Entries))
+	} else {
+		fmt.Printf(tr("Backup completo gravado em '%s': %d arquivo(s), %d entrada(s) no volume.\n"), flags.Arg(0), manifest.Files, len(manifest.Entries))
+	}
+	if manifest.Snapshot != nil {
+		return fs.saveFileSystemState()
+	}
+	return nil
+}
+
+// cliRestore implementa "restore <backup> [incremental...]".
+func cliRestore(fs *FURGFileSystem, args []string) error {
+	if len(args) == 0 {
+		return classErrorf(ErrUsage, "uso: furgfs restore <backup> [incremental...]")
+	}
+	if err := fs.RestoreBackups(args); err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// O comando bench mede as operações cujo desempenho depende do alocador, do cache de escritas e da gravação
+// dos metadados: importar arquivos (Import), encontrar uma entrada pelo caminho (Lookup) e salvar o estado
+// depois de uma alteração pequena (Save). Cada um roda sobre uma imagem temporária com uma carga sintética
+// gerada de forma determinística a partir de benchWorkload, de modo que duas execuções com as mesmas opções
+// medem o mesmo trabalho. Os resultados podem ser guardados em JSON (--save) e comparados com uma execução
+// anterior (--baseline), o que transforma o comando em um teste de regressão de desempenho.
+//
+// Os mesmos benchmarks rodam com go test -bench (veja bench_test.go): as duas formas chamam as funções de
+// benchmarks com um benchTimer, que é o *testing.B no go test e um benchRunner no comando, para que o
+// binário não dependa do pacote testing.
+
+// benchWorkload descreve a carga sintética: files arquivos espalhados por dirs diretórios, com tamanhos
+// entre 0 e maxSize bytes. A maioria dos arquivos cabe em um bloco, como num volume real, e o resto tem
+// tamanhos uniformes até maxSize.
+type benchWorkload struct {
+	Files     int    `json:"files"`
+	Dirs      int    `json:"dirs"`
+	MaxSize   int    `json:"max_size"`
+	BlockSize uint32 `json:"block_size"`
+	Seed      int64  `json:"seed"`
+}
+
+// defaultBenchWorkload é a carga padrão do comando bench e a dos benchmarks do go test.
+var defaultBenchWorkload = benchWorkload{Files: 1000, Dirs: 10, MaxSize: 256 << 10, BlockSize: 4096, Seed: 1}
+
+// benchFile é um arquivo da carga sintética.
+type benchFile struct {
+	dir, name string
+	content   []byte
+}
+
+func (f benchFile) path() string { return f.dir + "/" + f.name }
+
+// benchEnv é o estado compartilhado pelos benchmarks: a carga gerada e o diretório das imagens.
+type benchEnv struct {
+	workload benchWorkload
+	files    []benchFile
+	total    int64 // bytes de conteúdo da carga
+	dir      string
+}
+
+// newBenchEnv gera a carga de w, com os arquivos na ordem em que são importados.
+func newBenchEnv(w benchWorkload, dir string) *benchEnv {
+	random := rand.New(rand.NewSource(w.Seed))
+	e := &benchEnv{workload: w, dir: dir}
+	for i := range w.Files {
+		size := random.Intn(int(w.BlockSize) + 1)
+		if random.Intn(4) == 0 {
+			size = random.Intn(w.MaxSize + 1)
+		}
+		content := make([]byte, size)
+		random.Read(content)
+		e.files = append(e.files, benchFile{fmt.Sprintf("/d%d", i%w.Dirs), fmt.Sprintf("f%05d.bin", i), content})
+		e.total += int64(size)
+	}
+	return e
+}
+
+// newImage cria a imagem name no diretório do benchmark, com espaço e entradas para a carga duas vezes.
+// Cada benchmark é chamado algumas vezes, com n crescente, e a imagem da chamada anterior é substituída.
+func (e *benchEnv) newImage(name string) (*FURGFileSystem, error) {
+	blocks := int64(0)
+	for _, f := range e.files {
+		blocks += int64(len(f.content))/int64(e.workload.BlockSize) + 1
+	}
+	size := 2*blocks*int64(e.workload.BlockSize) + 1<<20
+	if size > 1<<32-1 {
+		return nil, classErrorf(ErrUsage, "erro: a carga não cabe em uma imagem; use menos arquivos ou um --max-size menor")
+	}
+	entries := 2*(e.workload.Files+e.workload.Dirs) + 64
+	image := filepath.Join(e.dir, name)
+	os.Remove(image)
+	fs, err := createFileSystemImage(image, e.workload.BlockSize, uint32(size), uint32(entries))
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.initVolume("bench"); err != nil {
+		fs.FilePointer.Close()
+		return nil, err
+	}
+	return fs, fs.saveFileSystemState()
+}
+
+// populate importa a carga em fs, criando os diretórios.
+func (e *benchEnv) populate(fs *FURGFileSystem) error {
+	for d := range e.workload.Dirs {
+		if err := fs.CreateDirectory(fmt.Sprintf("d%d", d), "/", false); err != nil {
+			return err
+		}
+	}
+	for _, f := range e.files {
+		if _, err := fs.createFile(f.dir, f.name, bytes.NewReader(f.content), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchTimer é o cronômetro de um benchmark; *testing.B e *benchRunner o implementam.
+type benchTimer interface {
+	StartTimer()
+	StopTimer()
+	ResetTimer()
+	SetBytes(n int64)
+	ReportAllocs()
+}
+
+// benchImport mede n importações da carga inteira, incluindo a criação dos diretórios e o salvamento do
+// estado ao final. Entre as repetições, a carga é apagada fora da medição.
+func (e *benchEnv) benchImport(t benchTimer, n int) error {
+	fs, err := e.newImage("import.fs2")
+	if err != nil {
+		return err
+	}
+	defer fs.FilePointer.Close()
+	t.SetBytes(e.total)
+	t.ReportAllocs()
+	t.ResetTimer()
+	for range n {
+		if err := e.populate(fs); err != nil {
+			return err
+		}
+		if err := fs.saveFileSystemState(); err != nil {
+			return err
+		}
+		t.StopTimer()
+		for d := range e.workload.Dirs {
+			if err := fs.removeInternalTree("/", fmt.Sprintf("d%d", d)); err != nil {
+				return err
+			}
+		}
+		if err := fs.saveFileSystemState(); err != nil {
+			return err
+		}
+		t.StartTimer()
+	}
+	return nil
+}
+
+// benchLookup mede n buscas de arquivos da carga pelo caminho, em ordem aleatória.
+func (e *benchEnv) benchLookup(t benchTimer, n int) error {
+	fs, err := e.newImage("lookup.fs2")
+	if err != nil {
+		return err
+	}
+	defer fs.FilePointer.Close()
+	if err := e.populate(fs); err != nil {
+		return err
+	}
+	order := rand.New(rand.NewSource(e.workload.Seed)).Perm(len(e.files))
+	t.ReportAllocs()
+	t.ResetTimer()
+	for i := range n {
+		if _, err := fs.internalFile(e.files[order[i%len(order)]].path()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchSave mede n salvamentos do estado, cada um depois de alterar a proteção de um arquivo da carga, o
+// caso comum de uma operação que muda poucas páginas dos metadados.
+func (e *benchEnv) benchSave(t benchTimer, n int) error {
+	fs, err := e.newImage("save.fs2")
+	if err != nil {
+		return err
+	}
+	defer fs.FilePointer.Close()
+	if err := e.populate(fs); err != nil {
+		return err
+	}
+	if err := fs.saveFileSystemState(); err != nil {
+		return err
+	}
+	t.ReportAllocs()
+	t.ResetTimer()
+	for i := range n {
+		f := e.files[i%len(e.files)]
+		if err := fs.ChangePermission(f.name, f.dir, (i/len(e.files))%2 == 0, false); err != nil {
+			return err
+		}
+		if err := fs.saveFileSystemState(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchmarks são os benchmarks do comando bench, na ordem em que rodam.
+var benchmarks = []struct {
+	name string
+	run  func(e *benchEnv, t benchTimer, n int) error
+}{
+	{"Import", (*benchEnv).benchImport},
+	{"Lookup", (*benchEnv).benchLookup},
+	{"Save", (*benchEnv).benchSave},
+}
+
+// benchRunner é o cronômetro do comando bench. Como testing.Benchmark, roda o benchmark com n crescente
+// até que a medição dure benchtime, ou exatamente count vezes, e conta as alocações do período medido.
+type benchRunner struct {
+	benchtime time.Duration
+	count     int // repetições fixas ("100x"); 0 usa benchtime
+
+	running     bool
+	start       time.Time
+	elapsed     time.Duration
+	startAllocs uint64
+	allocs      uint64
+	bytes       int64
+}
+
+// parseBenchtime lê a duração de --benchtime, como "1s", ou um número de repetições, como "100x".
+func parseBenchtime(s string) (*benchRunner, error) {
+	if count, ok := strings.CutSuffix(s, "x"); ok {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return nil, classErrorf(ErrUsage, "erro: duração inválida '%s'", s)
+		}
+		return &benchRunner{count: n}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return nil, classErrorf(ErrUsage, "erro: duração inválida '%s'", s)
+	}
+	return &benchRunner{benchtime: d}, nil
+}
+
+func (r *benchRunner) StartTimer() {
+	if !r.running {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		r.startAllocs = stats.Mallocs
+		r.start = time.Now()
+		r.running = true
+	}
+}
+
+func (r *benchRunner) StopTimer() {
+	if r.running {
+		r.elapsed += time.Since(r.start)
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		r.allocs += stats.Mallocs - r.startAllocs
+		r.running = false
+	}
+}
+
+func (r *benchRunner) ResetTimer() {
+	if r.running {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		r.startAllocs = stats.Mallocs
+		r.start = time.Now()
+	}
+	r.elapsed, r.allocs = 0, 0
+}
+
+func (r *benchRunner) SetBytes(n int64) { r.bytes = n }
+
+// ReportAllocs não faz nada: o benchRunner sempre conta as alocações.
+func (r *benchRunner) ReportAllocs() {}
+
+// runOnce roda o benchmark n vezes com o cronômetro zerado.
+func (r *benchRunner) runOnce(e *benchEnv, run func(*benchEnv, benchTimer, int) error, n int) error {
+	runtime.GC()
+	r.elapsed, r.allocs, r.bytes, r.running = 0, 0, 0, false
+	r.StartTimer()
+	err := run(e, r, n)
+	r.StopTimer()
+	return err
+}
+
+// run mede o benchmark e retorna o resultado com o nome name. Como em testing.Benchmark, cada nova rodada
+// estima, pela anterior, quantas repetições levam benchtime, com folga de 20% e no máximo 100 vezes mais.
+func (r *benchRunner) run(name string, e *benchEnv, bench func(*benchEnv, benchTimer, int) error) (benchResult, error) {
+	n := 1
+	if r.count > 0 {
+		n = r.count
+	}
+	if err := r.runOnce(e, bench, n); err != nil {
+		return benchResult{}, err
+	}
+	for r.count == 0 && r.elapsed < r.benchtime && n < 1e9 {
+		last := n
+		n = int(min(int64(r.benchtime)*int64(last)/max(int64(r.elapsed), 1), 1e9))
+		n = max(min(n+n/5, 100*last), last+1)
+		if err := r.runOnce(e, bench, n); err != nil {
+			return benchResult{}, err
+		}
+	}
+
+	result := benchResult{Name: name, N: n, NsPerOp: r.elapsed.Nanoseconds() / int64(n), AllocsPerOp: int64(r.allocs) / int64(n)}
+	if r.bytes > 0 && r.elapsed > 0 {
+		result.MBPerSec = float64(r.bytes) * float64(n) / 1e6 / r.elapsed.Seconds()
+	}
+	return result, nil
+}
+
+// benchResult é o resultado de um benchmark, como guardado por --save.
+type benchResult struct {
+	Name        string  `json:"name"`
+	N           int     `json:"n"`
+	NsPerOp     int64   `json:"ns_per_op"`
+	MBPerSec    float64 `json:"mb_per_sec,omitempty"`
+	AllocsPerOp int64   `json:"allocs_per_op"`
+}
+
+// benchReport é o conteúdo do arquivo de --save e --baseline.
+type benchReport struct {
+	Workload benchWorkload `json:"workload"`
+	Results  []benchResult `json:"results"`
+}
+
+// cliBench implementa "bench [opções] [benchmark...]": roda os benchmarks sobre a carga sintética e
+// mostra os resultados; com --baseline, falha se algum ficou mais de --tolerance por cento mais lento.
+func cliBench(imageName string, args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	w := defaultBenchWorkload
+	flags.IntVar(&w.Files, "files", w.Files, "número de arquivos da carga")
+	flags.IntVar(&w.Dirs, "dirs", w.Dirs, "número de diretórios da carga")
+	maxSize := flags.String("max-size", strconv.Itoa(w.MaxSize>>10)+"K", "tamanho máximo de um arquivo da carga")
+	blockSize := flags.Uint("block-size", uint(w.BlockSize), "tamanho do bloco das imagens")
+	flags.Int64Var(&w.Seed, "seed", w.Seed, "semente da carga sintética")
+	benchtime := flags.String("benchtime", "1s", "duração de cada benchmark, ou o número de repetições (100x)")
+	save := flags.String("save", "", "grava os resultados em JSON no arquivo")
+	baseline := flags.String("baseline", "", "compara os resultados com os de um arquivo gravado por --save")
+	tolerance := flags.Float64("tolerance", 10, "piora aceita em relação a --baseline, em porcento")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	size, err := parseSize(*maxSize)
+	if err != nil {
+		return classErrorf(ErrUsage, "erro: tamanho inválido '%s'", *maxSize)
+	}
+	w.MaxSize, w.BlockSize = int(size), uint32(*blockSize)
+	if w.Files < 1 || w.Dirs < 1 || w.BlockSize == 0 {
+		return classErrorf(ErrUsage, "uso: furgfs bench [--files <n>] [--dirs <n>] [--max-size <tamanho>] [--block-size <bytes>] [--seed <n>] [--benchtime <duração>] [--save <arquivo>] [--baseline <arquivo> [--tolerance <porcento>]] [benchmark...]")
+	}
+	selected := make(map[string]bool)
+	for _, name := range flags.Args() {
+		known := false
+		for _, bench := range benchmarks {
+			known = known || bench.name == name
+		}
+		if !known {
+			return classErrorf(ErrUsage, "erro: benchmark '%s' desconhecido; use Import, Lookup ou Save", name)
+		}
+		selected[name] = true
+	}
+	var reference *benchReport
+	if *baseline != "" {
+		if reference, err = readBenchReport(*baseline); err != nil {
+			return err
+		}
+		if reference.Workload != w {
+			fmt.Fprintln(os.Stderr, tr("aviso: a carga da referência difere da atual; a comparação pode não fazer sentido"))
+		}
+	}
+
+	runner, err := parseBenchtime(*benchtime)
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "furgfs-bench-")
+	if err != nil {
+		return fmt.Errorf("erro ao criar o diretório temporário: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	e := newBenchEnv(w, dir)
+	fmt.Printf(tr("Carga: %d arquivos em %d diretórios, %d bytes no total, blocos de %d bytes.\n"), w.Files, w.Dirs, e.total, w.BlockSize)
+
+	report := benchReport{Workload: w}
+	regressions := 0
+	for _, bench := range benchmarks {
+		if len(selected) > 0 && !selected[bench.name] {
+			continue
+		}
+		var result benchResult
+		// As mensagens das operações são descartadas, como no selftest
+		_, err := redirectStdio(nil, false, true, func() error {
+			var err error
+			result, err = runner.run(bench.name, e, bench.run)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf(tr("erro no benchmark %s: %w"), bench.name, err)
+		}
+		report.Results = append(report.Results, result)
+		line := fmt.Sprintf("Benchmark%-8s %8d %14d ns/op", result.Name, result.N, result.NsPerOp)
+		if result.MBPerSec > 0 {
+			line += fmt.Sprintf(" %10.2f MB/s", result.MBPerSec)
+		}
+		line += fmt.Sprintf(" %10d allocs/op", result.AllocsPerOp)
+		if old, ok := reference.result(bench.name); ok && old.NsPerOp > 0 {
+			change := 100 * (float64(result.NsPerOp) - float64(old.NsPerOp)) / float64(old.NsPerOp)
+			line += fmt.Sprintf("  %+6.1f%%", change)
+			if change > *tolerance {
+				line += "  " + stdoutColors.failure(tr("REGRESSÃO"))
+				regressions++
+			}
+		}
+		fmt.Println(line)
+	}
+
+	if *save != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*save, append(data, '\n'), 0o644); err != nil {
+			return fmt.Errorf("erro ao gravar '%s': %v", *save, err)
+		}
+	}
+	if regressions > 0 {
+		return fmt.Errorf(tr("erro: %d benchmark(s) mais de %s%% mais lento(s) que a referência"), regressions, strconv.FormatFloat(*tolerance, 'f', -1, 64))
+	}
+	return nil
+}
+
+// readBenchReport lê os resultados gravados por --save.
+func readBenchReport(name string) (*benchReport, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, classErrorf(ErrNotFound, "erro: arquivo de referência '%s' não encontrado", name)
+		}
+		return nil, err
+	}
+	var report benchReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, classErrorf(ErrCorrupted, "erro: arquivo de referência '%s' inválido: %v", name, err)
+	}
+	return &report, nil
+}
+
+// result retorna o resultado do benchmark name no relatório, que pode ser nil.
+func (r *benchReport) result(name string) (benchResult, bool) {
+	if r == nil {
+		return benchResult{}, false
+	}
+	for _, res := range r.Results {
+		if res.Name == name {
+			return res, true
+		}
+	}
+	return benchResult{}, false
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// Os benchmarks do comando bench, para go test -bench, sobre a carga padrão (veja bench.go).
+
+func BenchmarkImport(b *testing.B) { runBenchmark(b, (*benchEnv).benchImport) }
+
+func BenchmarkLookup(b *testing.B) { runBenchmark(b, (*benchEnv).benchLookup) }
+
+func BenchmarkSave(b *testing.B) { runBenchmark(b, (*benchEnv).benchSave) }
+
+// runBenchmark roda bench com b.N repetições sobre a carga padrão, em imagens no diretório temporário do
+// benchmark. As mensagens das operações são descartadas, como no comando bench.
+func runBenchmark(b *testing.B, bench func(e *benchEnv, t benchTimer, n int) error) {
+	e := newBenchEnv(defaultBenchWorkload, b.TempDir())
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	os.Stdout = devNull
+	defer func() {
+		os.Stdout = stdout
+		devNull.Close()
+	}()
+	if err := bench(e, b, b.N); err != nil {
+		b.Fatal(err)
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// fileBlocks retorna a sequência de blocos com o conteúdo da entrada, seguindo a cadeia da FAT a partir de
+// FirstBlockID. O fim da cadeia é indicado por NextBlockID igual a 0; arquivos vazios não ocupam blocos. Em um
+// arquivo guardado por conteúdo, os blocos vêm do manifesto (veja cas.go).
+func (fs *FURGFileSystem) fileBlocks(entry *FileEntry) ([]uint32, error) {
+	if entry.IsDirectory || entry.Size == 0 {
+		return nil, nil
+	}
+	if fs.isCASFile(entry) {
+		refs, err := fs.casManifest(entry)
+		if err != nil {
+			return nil, err
+		}
+		blocks := make([]uint32, len(refs))
+		for i, ref := range refs {
+			blocks[i] = ref.Block
+		}
+		return blocks, nil
+	}
+	return fs.chainBlocks(entry, entry.Size)
+}
+
+// chainBlocks segue a cadeia da FAT de entry, que deve guardar size bytes.
+func (fs *FURGFileSystem) chainBlocks(entry *FileEntry, size uint32) ([]uint32, error) {
+	expected := (size + fs.Header.BlockSize - 1) / fs.Header.BlockSize
+	blocks := make([]uint32, 0, expected)
+	currentBlockID := entry.FirstBlockID
+	for {
+		if int(currentBlockID) >= len(fs.FAT) {
+			return nil, classErrorf(ErrCorrupted, "erro: bloco %d fora da FAT na cadeia de '%s'", currentBlockID, entry.FullPath())
+		}
+		if uint32(len(blocks)) >= expected {
+			return nil, classErrorf(ErrCorrupted, "erro: cadeia de '%s' maior que o tamanho do arquivo", entry.FullPath())
+		}
+		blocks = append(blocks, currentBlockID)
+
+		currentBlockID = fs.fatEntry(currentBlockID).NextBlockID
+		if currentBlockID == 0 {
+			break
+		}
+	}
+	return blocks, nil
+}
+
+// blockOffset retorna a posição do bloco blockID na imagem. Todo acesso à imagem é posicional (ReadAt e
+// WriteAt), sem depender de uma posição compartilhada, para que leituras e escritas simultâneas não
+// interfiram umas nas outras.
+func (fs *FURGFileSystem) blockOffset(blockID uint32) int64 {
+	return int64(fs.Header.DataStart) + int64(blockID)*int64(fs.Header.BlockSize)
+}
+
+// readBlock lê o conteúdo do bloco blockID da região de dados para buf.
+func (fs *FURGFileSystem) readBlock(blockID uint32, buf []byte) (n int, err error) {
+	end := fs.startSpan("furgfs.block.read", attribute.Int("furgfs.block", int(blockID)))
+	defer func() { end(err) }()
+	cached := fs.wcache.overlay(blockID, buf, 0)
+	if cached == len(buf) {
+		return cached, nil
+	}
+	n, err = fs.FilePointer.ReadAt(buf, fs.blockOffset(blockID))
+	if err != nil && n == 0 && cached == 0 {
+		return 0, fmt.Errorf("erro ao ler bloco %d: %v", blockID, err)
+	}
+	// O início pendente no cache de escritas vale mais que o conteúdo da imagem
+	fs.wcache.overlay(blockID, buf, 0)
+	return max(n, cached), nil
+}
+
+// readFileContent lê todo o conteúdo de um arquivo armazenado, respeitando o tamanho registrado na entrada.
+func (fs *FURGFileSystem) readFileContent(entry *FileEntry) (data []byte, err error) {
+	end := fs.startSpan("furgfs.read", attribute.String("furgfs.path", entry.FullPath()))
+	defer func() { end(err) }()
+	r, err := fs.newFileReader(entry)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// fileReader lê sequencialmente o conteúdo de um arquivo armazenado, um trecho contíguo da cadeia por vez
+// (veja fileExtents). Os trechos seguintes são lidos da imagem em segundo plano (veja readAhead), enquanto
+// quem lê consome o trecho atual.
+type fileReader struct {
+	fs        *FURGFileSystem
+	cipher    *xtsCipher // nil se o conteúdo não é cifrado
+	extents   []blockRun
+	remaining uint32
+	buf       []byte
+	pending   []byte
+	ahead     []<-chan aheadBlock // leituras em andamento dos primeiros trechos de extents
+	spare     [][]byte
+}
+
+// newFileReader cria um leitor para o conteúdo da entrada, seguindo sua cadeia de blocos na FAT.
+func (fs *FURGFileSystem) newFileReader(entry *FileEntry) (*fileReader, error) {
+	blocks, err := fs.fileBlocks(entry)
+	if err != nil {
+		return nil, err
+	}
+	c, err := fs.dataCipher(entry)
+	if err != nil {
+		return nil, err
+	}
+	return &fileReader{
+		fs:        fs,
+		cipher:    c,
+		extents:   fs.fileExtents(blocks),
+		remaining: entry.Size,
+	}, nil
+}
+
+func (r *fileReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		if len(r.extents) == 0 || r.remaining == 0 {
+			r.release()
+			return 0, io.EOF
+		}
+		if err := r.nextExtent(); err != nil {
+			return 0, err
+		}
+		n := min(len(r.buf), int(r.remaining))
+		r.pending = r.buf[:n]
+		r.remaining -= uint32(n)
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// fileReaderAt permite leituras em posições arbitrárias de um arquivo armazenado,
+// convertendo cada deslocamento no bloco correspondente da cadeia. Em um volume cifrado, cada bloco é lido e
+// decifrado por inteiro, e o último bloco lido é mantido em cache; sem cifra, uma leitura que atravessa
+// blocos contíguos na imagem é feita com um só ReadAt.
+type fileReaderAt struct {
+	fs     *FURGFileSystem
+	blocks []uint32
+	size   int64
+
+	cipher *xtsCipher
+	cached int64 // índice na cadeia do bloco decifrado em buf, ou -1
+	buf    []byte
+}
+
+// newFileReaderAt cria um io.ReaderAt sobre o conteúdo da entrada, usado por exemplo para abrir arquivos zip armazenados.
+func (fs *FURGFileSystem) newFileReaderAt(entry *FileEntry) (*fileReaderAt, error) {
+	blocks, err := fs.fileBlocks(entry)
+	if err != nil {
+		return nil, err
+	}
+	c, err := fs.dataCipher(entry)
+	if err != nil {
+		return nil, err
+	}
+	r := &fileReaderAt{fs: fs, blocks: blocks, size: int64(entry.Size), cipher: c, cached: -1}
+	if c != nil {
+		r.buf = getBuffer(int(fs.Header.BlockSize))
+	}
+	return r, nil
+}
+
+// release devolve a bufferPools o buffer de r, que não pode mais ser usado.
+func (r *fileReaderAt) release() {
+	if r.buf != nil {
+		putBuffer(r.buf)
+		r.buf = nil
+	}
+}
+
+func (r *fileReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	blockSize := int64(r.fs.Header.BlockSize)
+	n := 0
+	for n < len(p) && off < r.size {
+		blockID := r.blocks[off/blockSize]
+		inner := off % blockSize
+		chunk := min(int64(len(p)-n), blockSize-inner, r.size-off)
+
+		if r.cipher != nil {
+			if r.cached != off/blockSize {
+				read, err := r.fs.readBlock(blockID, r.buf)
+				if err != nil {
+					return n, err
+				}
+				clear(r.buf[read:])
+				r.cipher.decryptBlock(r.buf, blockID)
+				r.cached = off / blockSize
+			}
+			n += copy(p[n:n+int(chunk)], r.buf[inner:])
+			off += chunk
+			continue
+		}
+
+		if cached := r.fs.wcache.overlay(blockID, p[n:n+int(chunk)], inner); cached > 0 {
+			n += cached
+			off += int64(cached)
+			continue
+		}
+		// Os blocos seguintes da cadeia contíguos na imagem, sem nada pendente no cache, vêm na mesma leitura
+		for next := off/blockSize + 1; chunk < int64(len(p)-n) && off+chunk < r.size &&
+			r.blocks[next] == r.blocks[next-1]+1 && !r.fs.wcache.holds(r.blocks[next:next+1]); next++ {
+			chunk = min(chunk+blockSize, int64(len(p)-n), r.size-off)
+		}
+		position := r.fs.blockOffset(blockID) + inner
+		read, err := r.fs.FilePointer.ReadAt(p[n:n+int(chunk)], position)
+		n += read
+		off += int64(read)
+		if err != nil {
+			return n, fmt.Errorf("erro ao ler bloco %d: %v", blockID, err)
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// storeFile grava o conteúdo lido de r em blocos livres, encadeando-os na FAT, e adiciona entry ao diretório.
+// Size e FirstBlockID de entry são preenchidos a partir do que foi gravado. Em caso de erro, os blocos já alocados
+// são liberados e a entrada não é criada. Retorna o índice da nova entrada no diretório.
+func (fs *FURGFileSystem) storeFile(entry FileEntry, r io.Reader) (index int, err error) {
+	end := fs.startSpan("furgfs.store", attribute.String("furgfs.path", entry.FullPath()))
+	defer func() { end(err) }()
+	rootDirIndex := fs.freeEntryIndex()
+	if rootDirIndex == -1 {
+		return -1, classErrorf(ErrNoSpace, "erro: Não foi possível adicionar a entrada de arquivo ao sistema de arquivos")
+	}
+
+	c, err := fs.dataCipher(&entry)
+	if err != nil {
+		return -1, err
+	}
+	if fs.isCASFile(&entry) {
+		return fs.storeCASFile(rootDirIndex, entry, r, c)
+	}
+
+	buf := getBuffer(int(fs.Header.BlockSize))
+	defer putBuffer(buf)
+	var blocks []uint32
+	var size uint64
+	var run blockRun
+	expected, known := readerSize(r)
+	if known && expected <= math.MaxUint32 {
+		count := uint32((expected + int64(fs.Header.BlockSize) - 1) / int64(fs.Header.BlockSize))
+		if found, ok := fs.findFreeRun(count, true); ok {
+			run = found
+			logger.Debug("sequência contígua escolhida", fs.imageAttr(), "path", entry.FullPath(), "first", run.Start, "blocks", count)
+		}
+	}
+	// Conteúdos grandes ou de tamanho desconhecido passam pelo pipeline (veja pipeline.go); um conteúdo já
+	// em memória não precisa ser lido adiantado
+	var writer *blockWriter
+	if !known || expected >= pipelineMinBlocks*int64(fs.Header.BlockSize) {
+		if _, inMemory := r.(interface{ Len() int }); !inMemory {
+			prefetch := newPrefetchReader(r, len(buf))
+			defer prefetch.Close()
+			r = prefetch
+		}
+		writer = fs.newBlockWriter(c)
+	}
+	fail := func(err error) (int, error) {
+		if writer != nil {
+			writer.wait()
+		}
+		fs.freeBlocks(blocks)
+		return -1, err
+	}
+	// place reserva um bloco para data, cuja capacidade é a de um bloco inteiro, encadeia-o e o grava
+	place := func(data []byte) error {
+		blockID, err := fs.allocateFromRun(&run, len(blocks) == 0)
+		if err != nil {
+			return err
+		}
+		if len(blocks) > 0 {
+			fs.modifyFAT(blocks[len(blocks)-1]).NextBlockID = blockID
+		}
+		blocks = append(blocks, blockID)
+		if writer != nil {
+			return writer.write(blockID, data)
+		}
+		if c != nil {
+			// Blocos cifrados são sempre gravados por inteiro
+			full := data[:cap(data)]
+			clear(full[len(data):])
+			c.encryptBlock(full, blockID)
+			data = full
+		}
+		return fs.writeBlock(blockID, data)
+	}
+	// Com o cache de escritas ligado, um conteúdo de tamanho desconhecido tem a alocação adiada (veja
+	// prealloc.go): os blocos lidos esperam em pending até somarem o limite do cache, ou até o fim, e só
+	// então são reservados, juntos
+	var pending [][]byte
+	cache := fs.writeCache()
+	delayed := !known && cache != nil
+	placePending := func() error {
+		if found, ok := fs.findFreeRun(uint32(len(pending)), len(blocks) == 0); ok {
+			run = found
+			logger.Debug("sequência contígua escolhida", fs.imageAttr(), "path", entry.FullPath(), "first", run.Start, "blocks", len(pending))
+		}
+		for _, data := range pending {
+			if err := place(data); err != nil {
+				return err
+			}
+			putBuffer(data)
+		}
+		pending = pending[:0]
+		return nil
+	}
+	for {
+		bytesRead, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fail(fmt.Errorf("erro ao ler o arquivo: %v", err))
+		}
+		if bytesRead == 0 {
+			break
+		}
+
+		size += uint64(bytesRead)
+		if size > math.MaxUint32 {
+			return fail(fmt.Errorf("erro: o arquivo excede o tamanho máximo de 4 GB"))
+		}
+
+		var placeErr error
+		if delayed {
+			pending = append(pending, append(getBuffer(len(buf))[:0], buf[:bytesRead]...))
+			if len(pending)*len(buf) >= cache.limit {
+				placeErr = placePending()
+			}
+		} else {
+			placeErr = place(buf[:bytesRead])
+		}
+		if placeErr != nil {
+			return fail(placeErr)
+		}
+		if bytesRead < len(buf) {
+			break
+		}
+	}
+	if len(pending) > 0 {
+		if err := placePending(); err != nil {
+			return fail(err)
+		}
+	}
+	if writer != nil {
+		if err := writer.wait(); err != nil {
+			fs.freeBlocks(blocks)
+			return -1, err
+		}
+	}
+
+	entry.Size = uint32(size)
+	entry.FirstBlockID = 0
+	if len(blocks) > 0 {
+		entry.FirstBlockID = blocks[0]
+	}
+	fs.setEntry(rootDirIndex, &entry)
+	logger.Info("arquivo gravado", fs.imageAttr(), "path", entry.FullPath(), "size", size, "blocks", len(blocks))
+	logger.Debug("cadeia do arquivo", fs.imageAttr(), "path", entry.FullPath(), "runs", fmt.Sprint(blockRuns(blocks)))
+	return rootDirIndex, nil
+}
+
+// allocateBlock reserva um bloco livre e desconta seu tamanho do espaço livre. A busca começa logo após o
+// último bloco reservado (next-fit) e dá a volta na FAT só quando chega ao fim: reservas seguidas custam
+// O(1) amortizado em vez de varrer a FAT desde o início, e os blocos de um arquivo grande ficam contíguos.
+// Como 0 em NextBlockID encerra uma cadeia, o bloco 0 só pode ser o primeiro de um arquivo; por isso ele
+// só é entregue na primeira reserva da sessão, que sempre abre uma cadeia.
+func (fs *FURGFileSystem) allocateBlock() (blockID uint32, err error) {
+	end := fs.startSpan("furgfs.allocate")
+	defer func() { end(err) }()
+	if fs.fatErr != nil {
+		return 0, fs.fatErr
+	}
+	n := uint32(len(fs.FAT))
+	start := fs.nextFree
+	if start >= n {
+		start = 1
+	}
+	for k := range n {
+		i := (start + k) % n
+		if fs.fatEntry(i).Used || i == 0 && fs.nextFree != 0 {
+			continue
+		}
+		*fs.modifyFAT(i) = FATEntry{
+			BlockID:     i,
+			NextBlockID: 0,
+			Used:        true,
+		}
+		fs.Header.FreeSpace -= fs.Header.BlockSize
+		fs.nextFree = i + 1
+		return i, nil
+	}
+	logger.Warn("sem blocos livres", fs.imageAttr(), "blocks", len(fs.FAT))
+	return 0, classErrorf(ErrNoSpace, "erro: espaço insuficiente na FAT.")
+}
+
+// freeBlocks libera os blocos informados na FAT e devolve seu tamanho ao espaço livre.
+// Apenas o campo Used é apagado: o encadeamento é mantido para que ScanDeletedFiles consiga recuperar o arquivo
+// enquanto os blocos não forem reaproveitados. Os blocos retidos por um snapshot continuam em uso.
+func (fs *FURGFileSystem) freeBlocks(blocks []uint32) {
+	pinned := fs.snapshotBlocks()
+	for _, blockID := range blocks {
+		if fs.fatEntry(blockID).Used && !pinned[blockID] {
+			fs.modifyFAT(blockID).Used = false
+			fs.Header.FreeSpace += fs.Header.BlockSize
+		}
+	}
+	logger.Debug("blocos liberados", fs.imageAttr(), "blocks", len(blocks))
+}
+
+// writeBlock grava data no início do bloco blockID da região de dados, passando pelo cache de escritas
+// (veja writecache.go) quando ele está ligado.
+func (fs *FURGFileSystem) writeBlock(blockID uint32, data []byte) (err error) {
+	end := fs.startSpan("furgfs.block.write", attribute.Int("furgfs.block", int(blockID)))
+	defer func() { end(err) }()
+	if c := fs.writeCache(); c != nil {
+		return fs.cacheBlock(c, blockID, data)
+	}
+	_, err = fs.FilePointer.WriteAt(data, fs.blockOffset(blockID))
+	if err != nil {
+		return fmt.Errorf("Erro ao escrever dados no arquivo: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// Os buffers de blocos e de trechos das cópias para dentro e para fora do volume (storeFile, blockWriter,
+// fileReader, fileReaderAt, copyBlocksOut e a leitura adiantada da origem) vêm de bufferPools e voltam a
+// ele quando quem os usa termina, em vez de um make a cada bloco: numa transferência longa, ou num servidor
+// que atende muitas leituras, o coletor de lixo quase não tem o que recolher. Há um sync.Pool por
+// capacidade; os trechos têm a capacidade arredondada para uma potência de dois de blocos (veja
+// extentBuffer), para que os tamanhos em uso num volume fiquem em poucas classes. Um buffer devolvido não
+// pode mais ser usado por quem o devolveu.
+var bufferPools sync.Map // capacidade → *sync.Pool de *[]byte
+
+// Os pools guardam ponteiros, e não os slices, porque pôr um slice em uma interface aloca a cada Put. Os
+// ponteiros esvaziados por getBuffer esperam em bufferHolders para serem reaproveitados por putBuffer, e
+// assim nem o ponteiro é alocado de novo.
+var bufferHolders = sync.Pool{New: func() any { return new([]byte) }}
+
+// getBuffer retorna um buffer de size bytes, com capacidade size e conteúdo qualquer.
+func getBuffer(size int) []byte {
+	if pool, ok := bufferPools.Load(size); ok {
+		if holder, ok := pool.(*sync.Pool).Get().(*[]byte); ok {
+			buf := *holder
+			*holder = nil
+			bufferHolders.Put(holder)
+			return buf[:size]
+		}
+	}
+	return make([]byte, size)
+}
+
+// putBuffer devolve buf ao pool de sua capacidade.
+func putBuffer(buf []byte) {
+	pool, ok := bufferPools.Load(cap(buf))
+	if !ok {
+		pool, _ = bufferPools.LoadOrStore(cap(buf), new(sync.Pool))
+	}
+	holder := bufferHolders.Get().(*[]byte)
+	*holder = buf[:0]
+	pool.(*sync.Pool).Put(holder)
+}
+
+// extentBuffer retorna um buffer do tamanho do trecho run, com a capacidade de uma potência de dois de blocos.
+func (fs *FURGFileSystem) extentBuffer(run blockRun) []byte {
+	class := blockRun{Length: 1 << bits.Len32(run.Length-1)}
+	return getBuffer(fs.extentBytes(class))[:fs.extentBytes(run)]
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+)
+
+// No armazenamento por conteúdo, escolhido com "mkfs --cas", cada bloco de dados é identificado pelo SHA-256
+// do seu conteúdo e guardado uma vez só: arquivos e trechos repetidos usam os mesmos blocos. A cadeia de um
+// arquivo na FAT deixa de ser o conteúdo e passa a ser o seu manifesto, a lista dos hashes e dos blocos de
+// cada trecho, e os blocos de dados ficam fora de qualquer cadeia, com NextBlockID 0. Um bloco de dados é
+// liberado quando o último manifesto que o usa é apagado; os hashes permitem conferir o conteúdo (veja
+// verify-image). Os arquivos do diretório de sistema continuam gravados como cadeias comuns, pois guardam,
+// entre outros, o próprio modo de armazenamento.
+const (
+	storageCAS = "cas"
+	casRefSize = sha256.Size + 4 // hash e número do bloco de cada trecho do manifesto
+)
+
+// casRef é um trecho do arquivo no manifesto: o hash do conteúdo e o bloco que o guarda.
+type casRef struct {
+	Hash  [sha256.Size]byte
+	Block uint32
+}
+
+// casState é o modo de armazenamento do volume e, no armazenamento por conteúdo, o índice dos blocos.
+type casState struct {
+	enabled bool
+	index   *casIndex // nil até ser montado (veja casBlockIndex)
+}
+
+// casIndex localiza os blocos de dados pelo hash e conta os manifestos que usam cada um.
+type casIndex struct {
+	blocks map[[sha256.Size]byte]uint32
+	refs   map[uint32]int
+}
+
+// casSettings lê, na primeira chamada, o modo de armazenamento do volume.
+func (fs *FURGFileSystem) casSettings() (*casState, error) {
+	if fs.cas != nil {
+		return fs.cas, nil
+	}
+	info, err := fs.VolumeInfo()
+	if err != nil {
+		return nil, err
+	}
+	fs.cas = &casState{enabled: info.Storage == storageCAS}
+	return fs.cas, nil
+}
+
+// casVolume informa se o volume guarda os arquivos por conteúdo. Um volume.json ilegível é tratado como
+// armazenamento comum, e o erro aparece nos comandos que o leem.
+func (fs *FURGFileSystem) casVolume() bool {
+	state, err := fs.casSettings()
+	return err == nil && state.enabled
+}
+
+// isCASFile informa se o conteúdo de entry é um manifesto: em um volume com armazenamento por conteúdo,
+// os arquivos fora do diretório de sistema.
+func (fs *FURGFileSystem) isCASFile(entry *FileEntry) bool {
+	return !entry.IsDirectory && entry.PathString() != systemDir && fs.casVolume()
+}
+
+// initCAS passa um volume recém-criado, ainda vazio, para o armazenamento por conteúdo.
+func (fs *FURGFileSystem) initCAS() error {
+	info, err := fs.VolumeInfo()
+	if err != nil {
+		return err
+	}
+	info.Storage = storageCAS
+	if err := fs.SetVolumeInfo(info); err != nil {
+		return err
+	}
+	fs.cas = &casState{enabled: true}
+	return nil
+}
+
+// checkNotCAS recusa as operações que dependem de cada bloco pertencer à cadeia de um único arquivo.
+func (fs *FURGFileSystem) checkNotCAS(operation string) error {
+	if fs.casVolume() {
+		return classErrorf(ErrUsage, "erro: %s não é suportado em volumes com armazenamento por conteúdo", operation)
+	}
+	return nil
+}
+
+// manifestSize é o tamanho do manifesto de um arquivo de size bytes.
+func (fs *FURGFileSystem) manifestSize(size uint32) uint32 {
+	return (size + fs.Header.BlockSize - 1) / fs.Header.BlockSize * casRefSize
+}
+
+// casManifest lê o manifesto de entry, um arquivo guardado por conteúdo.
+func (fs *FURGFileSystem) casManifest(entry *FileEntry) ([]casRef, error) {
+	size := fs.manifestSize(entry.Size)
+	chain, err := fs.chainBlocks(entry, size)
+	if err != nil {
+		return nil, err
+	}
+	c, err := fs.dataCipher(entry)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, len(chain)*int(fs.Header.BlockSize))
+	buf := make([]byte, fs.Header.BlockSize)
+	for _, blockID := range chain {
+		n, err := fs.readBlock(blockID, buf)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			clear(buf[n:])
+			c.decryptBlock(buf, blockID)
+			n = len(buf)
+		}
+		data = append(data, buf[:n]...)
+	}
+	if uint32(len(data)) < size {
+		return nil, classErrorf(ErrCorrupted, "erro: o manifesto de '%s' está incompleto", entry.FullPath())
+	}
+
+	refs := make([]casRef, size/casRefSize)
+	for i := range refs {
+		record := data[i*casRefSize:]
+		copy(refs[i].Hash[:], record)
+		refs[i].Block = binary.LittleEndian.Uint32(record[sha256.Size:])
+		if int(refs[i].Block) >= len(fs.FAT) {
+			return nil, classErrorf(ErrCorrupted, "erro: bloco %d fora da FAT no manifesto de '%s'", refs[i].Block, entry.FullPath())
+		}
+	}
+	return refs, nil
+}
+
+// writeManifest grava o manifesto refs em blocos livres encadeados, cifrados com c, e retorna a cadeia.
+func (fs *FURGFileSystem) writeManifest(refs []casRef, c *xtsCipher) ([]uint32, error) {
+	data := make([]byte, 0, len(refs)*casRefSize)
+	for _, ref := range refs {
+		data = append(data, ref.Hash[:]...)
+		data = binary.LittleEndian.AppendUint32(data, ref.Block)
+	}
+	var chain []uint32
+	buf := make([]byte, fs.Header.BlockSize)
+	for len(data) > 0 {
+		blockID, err := fs.allocateBlock()
+		if err != nil {
+			fs.freeBlocks(chain)
+			return nil, err
+		}
+		if len(chain) > 0 {
+			fs.modifyFAT(chain[len(chain)-1]).NextBlockID = blockID
+		}
+		chain = append(chain, blockID)
+
+		n := copy(buf, data)
+		data = data[n:]
+		block := buf[:n]
+		if c != nil {
+			clear(buf[n:])
+			c.encryptBlock(buf, blockID)
+			block = buf
+		}
+		if err := fs.writeBlock(blockID, block); err != nil {
+			fs.freeBlocks(chain)
+			return nil, err
+		}
+	}
+	return chain, nil
+}
+
+// casBlockIndex retorna o índice dos blocos de dados, montado na primeira chamada a partir dos manifestos
+// de todos os arquivos e dos hashes guardados nos snapshots. storeFile e releaseBlocks o mantêm atualizado;
+// as operações que refazem o diretório de outra forma o descartam com fs.cas.index = nil.
+func (fs *FURGFileSystem) casBlockIndex() (*casIndex, error) {
+	state, err := fs.casSettings()
+	if err != nil {
+		return nil, err
+	}
+	if state.index != nil {
+		return state.index, nil
+	}
+	index := &casIndex{blocks: make(map[[sha256.Size]byte]uint32), refs: make(map[uint32]int)}
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 || entry.Size == 0 || !fs.isCASFile(entry) {
+			continue
+		}
+		refs, err := fs.casManifest(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%w (execute o fsck antes)", err)
+		}
+		for _, ref := range refs {
+			index.add(ref)
+		}
+	}
+	snapshots, err := fs.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		for _, e := range snapshot.Entries {
+			for k, hash := range e.Hashes {
+				var ref casRef
+				if _, err := hex.Decode(ref.Hash[:], []byte(hash)); err != nil || k >= len(e.Blocks) {
+					continue
+				}
+				if _, ok := index.blocks[ref.Hash]; !ok && int(e.Blocks[k]) < len(fs.FAT) {
+					index.blocks[ref.Hash] = e.Blocks[k]
+				}
+			}
+		}
+	}
+	state.index = index
+	return index, nil
+}
+
+// add conta mais um uso do bloco de ref.
+func (index *casIndex) add(ref casRef) {
+	if _, ok := index.blocks[ref.Hash]; !ok {
+		index.blocks[ref.Hash] = ref.Block
+	}
+	index.refs[ref.Block]++
+}
+
+// release desconta os usos dos blocos de refs e retorna os que não são mais usados por nenhum manifesto.
+func (index *casIndex) release(refs []casRef) []uint32 {
+	var unused []uint32
+	for _, ref := range refs {
+		index.refs[ref.Block]--
+		if index.refs[ref.Block] > 0 {
+			continue
+		}
+		delete(index.refs, ref.Block)
+		if index.blocks[ref.Hash] == ref.Block {
+			delete(index.blocks, ref.Hash)
+		}
+		unused = append(unused, ref.Block)
+	}
+	return unused
+}
+
+// storeCASFile é storeFile no armazenamento por conteúdo: cada trecho de r cujo hash já está no índice usa o
+// bloco existente, depois de conferido byte a byte; os demais são gravados em blocos novos. O manifesto é
+// gravado por último, e a entrada é criada na posição rootDirIndex.
+func (fs *FURGFileSystem) storeCASFile(rootDirIndex int, entry FileEntry, r io.Reader, c *xtsCipher) (int, error) {
+	index, err := fs.casBlockIndex()
+	if err != nil {
+		return -1, err
+	}
+	var refs []casRef
+	fail := func(err error) (int, error) {
+		fs.freeBlocks(index.release(refs))
+		return -1, err
+	}
+
+	buf := make([]byte, fs.Header.BlockSize)
+	stored := make([]byte, fs.Header.BlockSize)
+	var size uint64
+	reused := 0
+	for {
+		bytesRead, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fail(fmt.Errorf("erro ao ler o arquivo: %v", err))
+		}
+		if bytesRead == 0 {
+			break
+		}
+		size += uint64(bytesRead)
+		if size > math.MaxUint32 {
+			return fail(fmt.Errorf("erro: o arquivo excede o tamanho máximo de 4 GB"))
+		}
+
+		ref := casRef{Hash: sha256.Sum256(buf[:bytesRead])}
+		blockID, found := index.blocks[ref.Hash]
+		if found {
+			found, err = fs.sameBlock(blockID, buf[:bytesRead], stored, c)
+			if err != nil {
+				return fail(err)
+			}
+		}
+		if found {
+			reused++
+		} else {
+			if blockID, err = fs.allocateBlock(); err != nil {
+				return fail(err)
+			}
+			data := buf[:bytesRead]
+			if c != nil {
+				clear(buf[bytesRead:])
+				c.encryptBlock(buf, blockID)
+				data = buf
+			}
+			if err := fs.writeBlock(blockID, data); err != nil {
+				fs.freeBlocks([]uint32{blockID})
+				return fail(err)
+			}
+		}
+		ref.Block = blockID
+		index.add(ref)
+		refs = append(refs, ref)
+		if bytesRead < len(buf) {
+			break
+		}
+	}
+
+	chain, err := fs.writeManifest(refs, c)
+	if err != nil {
+		return fail(err)
+	}
+	entry.Size = uint32(size)
+	entry.FirstBlockID = 0
+	if len(chain) > 0 {
+		entry.FirstBlockID = chain[0]
+	}
+	fs.setEntry(rootDirIndex, &entry)
+	logger.Info("arquivo gravado", fs.imageAttr(), "path", entry.FullPath(), "size", size, "blocks", len(refs), "reused", reused)
+	return rootDirIndex, nil
+}
+
+// sameBlock informa se o bloco blockID, em uso, guarda exatamente content; buf recebe a leitura.
+func (fs *FURGFileSystem) sameBlock(blockID uint32, content, buf []byte, c *xtsCipher) (bool, error) {
+	if !fs.fatEntry(blockID).Used {
+		return false, nil
+	}
+	n, err := fs.readBlock(blockID, buf)
+	if err != nil {
+		return false, err
+	}
+	if c != nil {
+		clear(buf[n:])
+		c.decryptBlock(buf, blockID)
+		n = len(buf)
+	}
+	return n >= len(content) && bytes.Equal(buf[:len(content)], content), nil
+}
+
+// entryBlocks retorna todos os blocos usados por entry: a cadeia e, em um arquivo guardado por conteúdo,
+// também os blocos de dados do manifesto, que podem ser compartilhados com outros arquivos.
+func (fs *FURGFileSystem) entryBlocks(entry *FileEntry) ([]uint32, error) {
+	if entry.IsDirectory || entry.Size == 0 || !fs.isCASFile(entry) {
+		return fs.fileBlocks(entry)
+	}
+	chain, err := fs.chainBlocks(entry, fs.manifestSize(entry.Size))
+	if err != nil {
+		return nil, err
+	}
+	data, err := fs.fileBlocks(entry)
+	if err != nil {
+		return nil, err
+	}
+	return append(chain, data...), nil
+}
+
+// releaseBlocks libera os blocos de entry, que vai ser apagada, e retorna quantos foram liberados. Em um
+// arquivo guardado por conteúdo, os blocos de dados ainda usados por outros arquivos são mantidos.
+func (fs *FURGFileSystem) releaseBlocks(entry *FileEntry) (int, error) {
+	if entry.IsDirectory || entry.Size == 0 || !fs.isCASFile(entry) {
+		blocks, err := fs.fileBlocks(entry)
+		if err != nil {
+			return 0, err
+		}
+		fs.freeBlocks(blocks)
+		return len(blocks), nil
+	}
+	index, err := fs.casBlockIndex()
+	if err != nil {
+		return 0, err
+	}
+	chain, err := fs.chainBlocks(entry, fs.manifestSize(entry.Size))
+	if err != nil {
+		return 0, err
+	}
+	refs, err := fs.casManifest(entry)
+	if err != nil {
+		return 0, err
+	}
+	blocks := append(chain, index.release(refs)...)
+	fs.freeBlocks(blocks)
+	return len(blocks), nil
+}
+
+// casFileHashes retorna os hashes do manifesto de entry, em hexadecimal, para os snapshots.
+func (fs *FURGFileSystem) casFileHashes(entry *FileEntry) ([]string, error) {
+	refs, err := fs.casManifest(entry)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(refs))
+	for i, ref := range refs {
+		hashes[i] = hex.EncodeToString(ref.Hash[:])
+	}
+	return hashes, nil
+}
+
+// restoreCASFile grava um manifesto novo para os blocos e hashes de e, de um snapshot, e retorna o primeiro
+// bloco dele. Os blocos de dados foram retidos pelo snapshot e voltam a ficar fora de qualquer cadeia.
+func (fs *FURGFileSystem) restoreCASFile(e snapshotEntry, entry *FileEntry) (uint32, error) {
+	if len(e.Hashes) != len(e.Blocks) {
+		return 0, classErrorf(ErrCorrupted, "erro: o snapshot não guarda os hashes de '%s'", e.Path)
+	}
+	refs := make([]casRef, len(e.Blocks))
+	for k, blockID := range e.Blocks {
+		if _, err := hex.Decode(refs[k].Hash[:], []byte(e.Hashes[k])); err != nil {
+			return 0, classErrorf(ErrCorrupted, "erro: o snapshot não guarda os hashes de '%s'", e.Path)
+		}
+		refs[k].Block = blockID
+		if !fs.fatEntry(blockID).Used {
+			fs.Header.FreeSpace -= fs.Header.BlockSize
+		}
+		*fs.modifyFAT(blockID) = FATEntry{BlockID: blockID, Used: true}
+	}
+	c, err := fs.dataCipher(entry)
+	if err != nil {
+		return 0, err
+	}
+	chain, err := fs.writeManifest(refs, c)
+	if err != nil || len(chain) == 0 {
+		return 0, err
+	}
+	return chain[0], nil
+}
+
+// verifyCASFile confere o conteúdo de cada bloco de entry com o hash do manifesto e retorna os problemas
+// encontrados.
+func (fs *FURGFileSystem) verifyCASFile(entry *FileEntry) ([]string, error) {
+	refs, err := fs.casManifest(entry)
+	if err != nil {
+		return nil, err
+	}
+	c, err := fs.dataCipher(entry)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	buf := make([]byte, fs.Header.BlockSize)
+	remaining := entry.Size
+	for k, ref := range refs {
+		n, err := fs.readBlock(ref.Block, buf)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			clear(buf[n:])
+			c.decryptBlock(buf, ref.Block)
+			n = len(buf)
+		}
+		n = min(n, int(remaining))
+		remaining -= uint32(n)
+		if sha256.Sum256(buf[:n]) != ref.Hash {
+			problems = append(problems, fmt.Sprintf(tr("'%s': o bloco %d (trecho %d) não confere com o hash do manifesto"), entry.FullPath(), ref.Block, k))
+		}
+	}
+	return problems, nil
+}
+
+// chainCapacity é o maior tamanho de arquivo que uma cadeia de n blocos comporta: o conteúdo ou, se cas, o
+// manifesto.
+func (fs *FURGFileSystem) chainCapacity(n int, cas bool) uint32 {
+	capacity := uint32(n) * fs.Header.BlockSize
+	if cas {
+		capacity = capacity / casRefSize * fs.Header.BlockSize
+	}
+	return capacity
+}
+
+// truncateCASFile troca o manifesto da entrada de índice i por refs, o início do atual, ajustando o tamanho,
+// e retorna a cadeia do manifesto novo. É o reparo do fsck para um arquivo que perdeu blocos de dados.
+func (fs *FURGFileSystem) truncateCASFile(i int, refs []casRef) []uint32 {
+	entry := &fs.RootDir[i]
+	fs.markEntryDirty(i)
+	if old, err := fs.chainBlocks(entry, fs.manifestSize(entry.Size)); err == nil {
+		fs.freeBlocks(old)
+	}
+	entry.Size = min(entry.Size, uint32(len(refs))*fs.Header.BlockSize)
+	entry.FirstBlockID = 0
+	c, err := fs.dataCipher(entry)
+	var chain []uint32
+	if err == nil {
+		chain, err = fs.writeManifest(refs, c)
+	}
+	if err != nil {
+		fmt.Println(tr("  Erro ao gravar o manifesto:"), err)
+		entry.Size = 0
+		return nil
+	}
+	if len(chain) > 0 {
+		entry.FirstBlockID = chain[0]
+	}
+	return chain
+}
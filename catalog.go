@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// "catalog" exporta a tabela do diretório inteira, uma linha por entrada e na ordem da tabela, em CSV ou
+// JSON, para planilhas e análises externas. O formato da imagem não guarda datas: created e modified vêm
+// do diário de alterações (veja journal), quando ativo, e ficam vazias nas entradas sem registros. O
+// SHA-256 do conteúdo só é calculado com --checksum, lendo os arquivos ou o índice de metadados.
+const (
+	catalogCSV  = "csv"
+	catalogJSON = "json"
+)
+
+// catalogEntry é uma linha do catálogo.
+type catalogEntry struct {
+	Index      int       `json:"index"` // posição na tabela do diretório
+	Path       string    `json:"path"`
+	Name       string    `json:"name"`
+	Parent     string    `json:"parent"`
+	Type       string    `json:"type"` // "file" ou "directory"
+	Size       uint32    `json:"size"`
+	Blocks     int       `json:"blocks"`
+	FirstBlock *uint32   `json:"first_block"` // null para diretórios e arquivos vazios
+	Fragments  int       `json:"fragments"`
+	Protected  bool      `json:"protected"`
+	System     bool      `json:"system"` // dentro do diretório de sistema /.furgfs
+	Created    time.Time `json:"created,omitzero"`
+	Modified   time.Time `json:"modified,omitzero"`
+	SHA256     string    `json:"sha256,omitempty"`
+}
+
+// catalogColumns são as colunas do CSV, na ordem dos campos de catalogEntry.
+var catalogColumns = []string{"index", "path", "name", "parent", "type", "size", "blocks", "first_block",
+	"fragments", "protected", "system", "created", "modified", "sha256"}
+
+// journalTimes retorna, pelo caminho, quando cada entrada foi criada e alterada pela última vez segundo os
+// segmentos disponíveis do diário; sem diário, o mapa fica vazio.
+func (fs *FURGFileSystem) journalTimes() (map[string][2]time.Time, error) {
+	times := make(map[string][2]time.Time)
+	if fs.journal == nil {
+		return times, nil
+	}
+	for _, segment := range fs.journalSegments() {
+		records, err := fs.readJournalSegment(segment)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			switch rec.Op {
+			case "create":
+				times[rec.Path] = [2]time.Time{rec.Time, rec.Time}
+			case "modify":
+				t := times[rec.Path]
+				t[1] = rec.Time
+				times[rec.Path] = t
+			case "delete":
+				delete(times, rec.Path)
+			}
+		}
+	}
+	return times, nil
+}
+
+// Catalog descreve todas as entradas da tabela do diretório; com checksum, inclui o SHA-256 dos arquivos.
+func (fs *FURGFileSystem) Catalog(checksum bool) ([]catalogEntry, error) {
+	times, err := fs.journalTimes()
+	if err != nil {
+		return nil, err
+	}
+	entries := []catalogEntry{}
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 {
+			continue
+		}
+		p := entry.FullPath()
+		c := catalogEntry{
+			Index:     i,
+			Path:      p,
+			Name:      entry.NameString(),
+			Parent:    entry.PathString(),
+			Type:      "file",
+			Size:      entry.Size,
+			Protected: entry.Protected,
+			System:    p == systemDir || strings.HasPrefix(p, systemDir+"/"),
+			Created:   times[p][0],
+			Modified:  times[p][1],
+		}
+		if entry.IsDirectory {
+			c.Type = "directory"
+		} else {
+			blocks, err := fs.fileBlocks(entry)
+			if err != nil {
+				return nil, fmt.Errorf(tr("erro ao ler '%s': %w"), p, err)
+			}
+			if len(blocks) > 0 {
+				c.Blocks, c.FirstBlock, c.Fragments = len(blocks), &blocks[0], len(blockRuns(blocks))
+			}
+			if checksum {
+				if c.SHA256, err = fs.imageFileHash(p); err != nil {
+					return nil, fmt.Errorf(tr("erro ao ler '%s': %w"), p, err)
+				}
+			}
+		}
+		entries = append(entries, c)
+	}
+	return entries, nil
+}
+
+// writeCatalog escreve entries em w no formato format.
+func writeCatalog(w io.Writer, entries []catalogEntry, format string) error {
+	if format == catalogJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			Schema  int            `json:"schema"`
+			Entries []catalogEntry `json:"entries"`
+		}{jsonSchemaVersion, entries})
+	}
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Local().Format(time.RFC3339)
+	}
+	out := csv.NewWriter(w)
+	out.Write(catalogColumns)
+	for _, c := range entries {
+		first := ""
+		if c.FirstBlock != nil {
+			first = strconv.FormatUint(uint64(*c.FirstBlock), 10)
+		}
+		out.Write([]string{strconv.Itoa(c.Index), c.Path, c.Name, c.Parent, c.Type,
+			strconv.FormatUint(uint64(c.Size), 10), strconv.Itoa(c.Blocks), first, strconv.Itoa(c.Fragments),
+			strconv.FormatBool(c.Protected), strconv.FormatBool(c.System), formatTime(c.Created),
+			formatTime(c.Modified), c.SHA256})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// cliCatalog implementa "catalog [--format csv|json] [--checksum] [arquivo|-]". Sem --format, o formato
+// vem da extensão do arquivo, e a saída padrão recebe CSV.
+func cliCatalog(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("catalog", flag.ContinueOnError)
+	format := flags.String("format", "", "formato do catálogo: csv ou json")
+	checksum := flags.Bool("checksum", false, "inclui o SHA-256 do conteúdo dos arquivos")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() > 1 {
+		return classErrorf(ErrUsage, "uso: furgfs catalog [--format csv|json] [--checksum] [arquivo|-]")
+	}
+	dest := flags.Arg(0)
+	if *format == "" {
+		*format = catalogCSV
+		if strings.EqualFold(filepath.Ext(dest), ".json") {
+			*format = catalogJSON
+		}
+	}
+	if *format != catalogCSV && *format != catalogJSON {
+		return classErrorf(ErrUsage, "erro: formato '%s' desconhecido (use csv ou json)", *format)
+	}
+
+	entries, err := fs.Catalog(*checksum)
+	if err != nil {
+		return err
+	}
+	if dest == "" || dest == "-" {
+		return writeCatalog(os.Stdout, entries, *format)
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("erro ao criar o arquivo no sistema real: %v", err)
+	}
+	defer f.Close()
+	if err := writeCatalog(f, entries, *format); err != nil {
+		return err
+	}
+	fmt.Printf(tr("Catálogo de %d entrada(s) exportado para '%s'.\n"), len(entries), dest)
+	return f.Close()
+}
@@ -0,0 +1,857 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// runCLI executa um único comando sobre o sistema de arquivos imageName, sem o menu interativo,
+// e retorna o código de saída do processo. Mensagens de erro vão para a saída de erro padrão
+// para que a saída padrão possa ser usada em pipelines (ex.: furgfs get /a.txt | less).
+func runCLI(imageName string, args []string) int {
+	if args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		// help não precisa de uma imagem
+		err := printHelp(args[1:])
+		if err != nil {
+			printError(err)
+		}
+		return exitCode(err)
+	}
+	if command := findCommand(args[0]); command != nil && command.Standalone != nil {
+		// mkfs cria a imagem e superblock deve funcionar mesmo com um cabeçalho que impede o carregamento
+		err := command.Standalone(imageName, args[1:])
+		if err != nil {
+			printError(err)
+		}
+		return exitCode(err)
+	}
+	run := cliCommand(args[0])
+	if run == nil {
+		printError(classErrorf(ErrUsage, "erro: comando desconhecido '%s'", args[0]))
+		fmt.Fprintf(os.Stderr, "\n%s\n", cliUsage())
+		return exitUsage
+	}
+
+	// Com um furgfsd em execução, o comando é executado por ele
+	if code, ok := forwardToDaemon(imageName, args); ok {
+		return code
+	}
+
+	endSpan := startCommandSpan(args[0])
+	fs, err := loadFileSystem(imageName)
+	if err != nil {
+		endSpan(err)
+		printError(fmt.Errorf("%s %w", tr("Erro ao carregar o sistema de arquivos:"), err))
+		return exitCode(err)
+	}
+	openImages.add("", fs)
+	// Os comandos que alteram uma imagem a salvam; as demais imagens abertas são apenas fechadas
+	defer openImages.closeAll(false)
+	err = runCommand(fs, args)
+	endSpan(err)
+	return exitCode(err)
+}
+
+// runCommand executa o comando args sobre fs, já registrada na sessão, e exibe o erro, se houver.
+func runCommand(fs *FURGFileSystem, args []string) error {
+	rest, policy := extractPolicyFlags(args[1:])
+	fs.Policy = policy
+	logger.Debug("comando", "name", args[0], "args", rest)
+	saves := fs.saves
+	err := cliCommand(args[0])(fs, rest)
+	fs.auditCommand(args[0], rest, saves, err)
+	if syncErr := fs.syncChanged(); err == nil {
+		err = syncErr
+	}
+	if err != nil {
+		printError(err)
+		logger.Info("comando falhou", "name", args[0], "exit_code", exitCode(err), "error", err.Error())
+	}
+	return err
+}
+
+// cliCommand retorna a função que implementa o comando name, ou nil se o comando não existir ou só puder
+// ser executado antes de carregar a imagem.
+func cliCommand(name string) func(fs *FURGFileSystem, args []string) error {
+	if name == "-c" {
+		return cliInlineScript
+	}
+	if command := findCommand(name); command != nil {
+		return command.Run
+	}
+	return aliasCommand(name)
+}
+
+// cliMkfs implementa "mkfs [--size 10M] [--block-size 4096] [--entries 100] [--label rótulo] [--cas]
+// [--prealloc|--sparse] [imagem]": cria uma imagem vazia com a geometria indicada. Sem imagem, usa imageName.
+func cliMkfs(imageName string, args []string) error {
+	args, policy := extractPolicyFlags(args)
+	flags := flag.NewFlagSet("mkfs", flag.ContinueOnError)
+	sizeStr := flags.String("size", "10M", "tamanho total da imagem (ex.: 10M, 2G)")
+	blockSizeStr := flags.String("block-size", "4096", "tamanho de cada bloco de dados")
+	entries := flags.Uint("entries", 100, "número de entradas da tabela do diretório")
+	label := flags.String("label", "", "rótulo do volume")
+	cas := flags.Bool("cas", false, "guarda os arquivos por conteúdo, com deduplicação dos blocos")
+	prealloc := flags.Bool("prealloc", false, "reserva no disco todo o espaço da imagem na criação")
+	sparse := flags.Bool("sparse", false, "cria a imagem esparsa, ocupando o disco conforme é usada (padrão)")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() > 1 || *prealloc && *sparse {
+		return classErrorf(ErrUsage, "uso: furgfs mkfs [--size 10M] [--block-size 4096] [--entries 100] [--label rótulo] [--cas] [--prealloc|--sparse] [imagem]")
+	}
+	if flags.NArg() == 1 {
+		imageName = flags.Arg(0)
+	}
+
+	size, err := parseSize(*sizeStr)
+	if err != nil {
+		return err
+	}
+	blockSize, err := parseSize(*blockSizeStr)
+	if err != nil {
+		return err
+	}
+	if *entries > math.MaxUint32 {
+		return fmt.Errorf("erro: número de entradas '%d' inválido", *entries)
+	}
+	if err := defaultValidationRules.ValidateName(*label); *label != "" && err != nil {
+		return fmt.Errorf("erro: rótulo '%s' inválido: %v", *label, errors.Unwrap(err))
+	}
+
+	if _, err := os.Stat(imageName); err == nil {
+		err = policy.Confirm(fmt.Sprintf(tr("A imagem '%s' já existe e será substituída."), imageName))
+		if err != nil {
+			return err
+		}
+		err = os.Remove(imageName)
+		if err != nil {
+			return fmt.Errorf("erro ao remover a imagem existente: %v", err)
+		}
+	}
+
+	fs, err := createFileSystemImage(imageName, blockSize, size, uint32(*entries))
+	if err != nil {
+		return err
+	}
+	defer fs.closeImage()
+
+	if *prealloc {
+		err = fs.preallocate()
+	}
+	if err == nil {
+		err = fs.initVolume(*label)
+	}
+	if err == nil && *cas {
+		err = fs.initCAS()
+	}
+	if err == nil {
+		err = fs.saveFileSystemState()
+	}
+	if err != nil {
+		fs.FilePointer.Close()
+		os.Remove(imageName)
+		return err
+	}
+
+	info, _ := fs.VolumeInfo()
+	fmt.Printf("Imagem '%s' criada: %d blocos de %d bytes, %d entradas no diretório.\n",
+		imageName, len(fs.FAT), fs.Header.BlockSize, len(fs.RootDir))
+	fmt.Printf("Rótulo: '%s', UUID: %s\n", info.Label, info.UUID)
+	return nil
+}
+
+// cliLs implementa "ls [-l] [-R] [diretório]".
+func cliLs(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("ls", flag.ContinueOnError)
+	long := flags.Bool("l", false, "mostra tipo, proteção e tamanho")
+	recursive := flags.Bool("R", false, "lista também os subdiretórios")
+	asJSON := flags.Bool("json", false, "saída em JSON")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() > 1 {
+		return classErrorf(ErrUsage, "uso: furgfs ls [-l] [-R] [--json] [diretório]")
+	}
+
+	dir := fs.resolvePath(flags.Arg(0))
+	if fs.CheckDirectoryExists(dir) == -1 {
+		return classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", dir)
+	}
+
+	if *asJSON {
+		listing := jsonListing{Schema: jsonSchemaVersion, Directory: dir, Entries: []jsonEntry{}}
+		indices := fs.sortedEntries(dir)
+		if *recursive {
+			indices = fs.entriesInDirectory(dir, true)
+			sort.Slice(indices, func(a, b int) bool {
+				return fs.RootDir[indices[a]].FullPath() < fs.RootDir[indices[b]].FullPath()
+			})
+		}
+		for _, i := range indices {
+			listing.Entries = append(listing.Entries, newJSONEntry(&fs.RootDir[i]))
+		}
+		return printJSON(listing)
+	}
+
+	dirs := []string{dir}
+	for k := 0; k < len(dirs); k++ {
+		if *recursive {
+			if k > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s:\n", dirs[k])
+		}
+		for _, i := range fs.sortedEntries(dirs[k]) {
+			entry := &fs.RootDir[i]
+			name := entry.NameString()
+			if entry.IsDirectory {
+				name += "/"
+				if *recursive {
+					dirs = append(dirs, entry.FullPath())
+				}
+			}
+			name = stdoutColors.entryName(entry, name)
+			if *long {
+				fmt.Printf("%s %10d %s\n", entryMode(entry), entry.Size, name)
+			} else {
+				fmt.Println(name)
+			}
+		}
+	}
+	return nil
+}
+
+// sortedEntries retorna os índices das entradas do diretório dir, ordenadas por nome.
+func (fs *FURGFileSystem) sortedEntries(dir string) []int {
+	indices := fs.entriesInDirectory(dir, false)
+	sort.Slice(indices, func(a, b int) bool {
+		return fs.RootDir[indices[a]].NameString() < fs.RootDir[indices[b]].NameString()
+	})
+	return indices
+}
+
+// entryMode resume o tipo e a proteção da entrada, por exemplo "d-" para diretório e "-p" para arquivo protegido.
+func entryMode(entry *FileEntry) string {
+	mode := []byte("--")
+	if entry.IsDirectory {
+		mode[0] = 'd'
+	}
+	if entry.Protected {
+		mode[1] = 'p'
+	}
+	return string(mode)
+}
+
+// cliTree implementa "tree [--json] [diretório]".
+func cliTree(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("tree", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "saída em JSON")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() > 1 {
+		return classErrorf(ErrUsage, "uso: furgfs tree [--json] [diretório]")
+	}
+	dir := fs.resolvePath(flags.Arg(0))
+	if fs.CheckDirectoryExists(dir) == -1 {
+		return classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", dir)
+	}
+
+	if *asJSON {
+		root := jsonEntry{Path: dir, Type: "directory", Children: fs.jsonTreeOf(dir)}
+		if dir != "/" {
+			path, name := splitPath(dir)
+			root = newJSONEntry(&fs.RootDir[fs.lookupEntry(name, path)])
+			root.Children = fs.jsonTreeOf(dir)
+		}
+		return printJSON(jsonTree{Schema: jsonSchemaVersion, Root: root})
+	}
+
+	fmt.Println(dir)
+	dirs, files := fs.printTree(dir, "")
+	fmt.Printf("\n%d diretório(s), %d arquivo(s)\n", dirs, files)
+	return nil
+}
+
+// printTree exibe as entradas de dir com linhas de árvore e retorna quantos diretórios e arquivos foram exibidos.
+func (fs *FURGFileSystem) printTree(dir, prefix string) (int, int) {
+	dirs, files := 0, 0
+	indices := fs.sortedEntries(dir)
+	for k, i := range indices {
+		entry := &fs.RootDir[i]
+		branch, next := "├── ", "│   "
+		if k == len(indices)-1 {
+			branch, next = "└── ", "    "
+		}
+		if entry.IsDirectory {
+			fmt.Printf("%s%s%s\n", prefix, branch, stdoutColors.entryName(entry, entry.NameString()+"/"))
+			d, f := fs.printTree(entry.FullPath(), prefix+next)
+			dirs, files = dirs+d+1, files+f
+		} else {
+			fmt.Printf("%s%s%s (%d bytes)\n", prefix, branch, stdoutColors.entryName(entry, entry.NameString()), entry.Size)
+			files++
+		}
+	}
+	return dirs, files
+}
+
+// cliStat implementa "stat [--json] <caminho-interno>".
+func cliStat(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("stat", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "saída em JSON")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 1 {
+		return classErrorf(ErrUsage, "uso: furgfs stat [--json] <caminho-interno>")
+	}
+	full := fs.resolvePath(flags.Arg(0))
+	if *asJSON {
+		return fs.statJSON(full)
+	}
+	if full == "/" {
+		info, err := fs.VolumeInfo()
+		if err != nil {
+			return err
+		}
+		used := fs.Header.TotalSize - fs.Header.DataStart - fs.Header.FreeSpace
+		fmt.Printf("Caminho:    /\nTipo:       diretório raiz\nEntradas:   %d\nEm uso:     %d bytes\n",
+			len(fs.entriesInDirectory("/", true)), used)
+		if info.UUID != "" {
+			fmt.Printf("Rótulo:     %s\nUUID:       %s\n", info.Label, info.UUID)
+		}
+		if !info.Created.IsZero() {
+			fmt.Printf("Criado em:  %s\n", info.Created.Local().Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	}
+
+	path, name := splitPath(full)
+	index := fs.lookupEntry(name, path)
+	if index == -1 {
+		return classErrorf(ErrNotFound, "erro: '%s' não foi encontrado no sistema de arquivos", full)
+	}
+	entry := &fs.RootDir[index]
+
+	fmt.Printf("Caminho:    %s\n", entry.FullPath())
+	if entry.IsDirectory {
+		fmt.Printf("Tipo:       diretório\nEntradas:   %d\n", len(fs.entriesInDirectory(full, false)))
+	} else {
+		blocks, err := fs.fileBlocks(entry)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Tipo:       arquivo\nTamanho:    %d bytes\nBlocos:     %d de %d bytes\n", entry.Size, len(blocks), fs.Header.BlockSize)
+		if len(blocks) > 0 {
+			runs := blockRuns(blocks)
+			fmt.Printf("Fragmentos: %d\nPrimeiro:   %d\n", len(runs), entry.FirstBlockID)
+		}
+	}
+	fmt.Printf("Protegido:  %t\nÍndice:     %d\n", entry.Protected, index)
+	return nil
+}
+
+// statJSON escreve os atributos da entrada full no formato jsonStat. A raiz tem índice -1.
+func (fs *FURGFileSystem) statJSON(full string) error {
+	stat := jsonStat{
+		Schema:    jsonSchemaVersion,
+		jsonEntry: jsonEntry{Path: "/", Type: "directory"},
+		Index:     -1,
+		Blocks:    []uint32{},
+	}
+	if full == "/" {
+		info, err := fs.VolumeInfo()
+		if err != nil {
+			return err
+		}
+		stat.Volume = &info
+	} else {
+		path, name := splitPath(full)
+		index := fs.lookupEntry(name, path)
+		if index == -1 {
+			return classErrorf(ErrNotFound, "erro: '%s' não foi encontrado no sistema de arquivos", full)
+		}
+		entry := &fs.RootDir[index]
+		stat.jsonEntry = newJSONEntry(entry)
+		stat.Index = index
+
+		if !entry.IsDirectory {
+			blocks, err := fs.fileBlocks(entry)
+			if err != nil {
+				return err
+			}
+			if len(blocks) > 0 {
+				stat.Blocks = blocks
+				stat.Fragments = len(blockRuns(blocks))
+				stat.FirstBlock = &blocks[0]
+			}
+		}
+	}
+	return printJSON(stat)
+}
+
+// cliDf implementa "df [--json]": espaço livre e ocupado da área de dados e uso da tabela do diretório.
+func cliDf(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("df", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "saída em JSON")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 0 {
+		return classErrorf(ErrUsage, "uso: furgfs df [--json]")
+	}
+
+	info := fs.freeSpaceInfo()
+	if *asJSON {
+		return printJSON(info)
+	}
+	percent := 0.0
+	if info.TotalBytes > 0 {
+		percent = float64(info.UsedBytes) / float64(info.TotalBytes) * 100
+	}
+	fmt.Printf("Espaço total:   %d bytes (%d blocos de %d bytes)\n", info.TotalBytes, info.TotalBlocks, info.BlockSize)
+	fmt.Printf("Espaço livre:   %d bytes (%d blocos)\n", info.FreeBytes, info.FreeBlocks)
+	fmt.Printf("Espaço ocupado: %d bytes (%.2f%%)\n", info.UsedBytes, percent)
+	fmt.Printf("Entradas:       %d de %d em uso\n", info.UsedEntries, info.TotalEntries)
+	return nil
+}
+
+// cliMkdir implementa "mkdir [-p] <diretório>".
+func cliMkdir(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("mkdir", flag.ContinueOnError)
+	parents := flags.Bool("p", false, "cria também os diretórios pais")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 1 {
+		return classErrorf(ErrUsage, "uso: furgfs mkdir [-p] <diretório>")
+	}
+
+	path, name := splitPath(fs.resolvePath(flags.Arg(0)))
+	err := fs.CreateDirectory(name, path, *parents)
+	if err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
+
+// cliRm implementa "rm [-r] <caminho-interno>...". Em um pipeline e sem caminhos, lê um caminho por linha da entrada.
+func cliRm(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("rm", flag.ContinueOnError)
+	recursive := flags.Bool("r", false, "remove diretórios com todo o conteúdo")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	paths := flags.Args()
+	if len(paths) == 0 && pipedInput {
+		// Em um pipeline ("find *.log | rm"), os caminhos vêm do estágio anterior
+		var err error
+		if paths, err = readPipedLines(); err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			return nil
+		}
+	}
+	if len(paths) == 0 {
+		return classErrorf(ErrUsage, "uso: furgfs rm [-r] <caminho-interno>...")
+	}
+
+	for _, arg := range paths {
+		if err := fs.removeEntry(arg, *recursive); err != nil {
+			fs.saveFileSystemState()
+			return err
+		}
+	}
+	return fs.saveFileSystemState()
+}
+
+// removeEntry remove o arquivo ou diretório arg; diretórios com conteúdo exigem recursive.
+func (fs *FURGFileSystem) removeEntry(arg string, recursive bool) error {
+	path, name := splitPath(fs.resolvePath(arg))
+	index := fs.lookupEntry(name, path)
+	if index == -1 {
+		return classErrorf(ErrNotFound, "erro: '%s' não foi encontrado no sistema de arquivos", joinPath(path, name))
+	}
+
+	switch {
+	case !fs.RootDir[index].IsDirectory:
+		return fs.RemoveFileFromFileSystem(name, path)
+	case recursive:
+		return fs.removeInternalTree(path, name)
+	default:
+		return fs.DeleteDirectory(name, path)
+	}
+}
+
+// cliMv implementa "mv <origem> <destino>".
+// Com mais de uma imagem aberta, origem e destino podem ser "imagem:/caminho".
+func cliMv(fs *FURGFileSystem, args []string) error {
+	if len(args) != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs mv <origem> <destino>")
+	}
+	return saveImages(fs.transferEntry(args[0], args[1], true))
+}
+
+// cliCp implementa "cp <origem> <destino>". Diretórios são copiados com todo o conteúdo e, com mais de
+// uma imagem aberta, origem e destino podem ser "imagem:/caminho".
+func cliCp(fs *FURGFileSystem, args []string) error {
+	if len(args) != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs cp <origem> <destino>")
+	}
+	return saveImages(fs.transferEntry(args[0], args[1], false))
+}
+
+// cliProtect implementa "protect [-r] [--off] <caminho-interno|padrão>".
+func cliProtect(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("protect", flag.ContinueOnError)
+	recursive := flags.Bool("r", false, "inclui os subdiretórios")
+	off := flags.Bool("off", false, "retira a proteção")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 1 {
+		return classErrorf(ErrUsage, "uso: furgfs protect [-r] [--off] <caminho-interno|padrão>")
+	}
+
+	path, name := splitPath(fs.resolvePath(flags.Arg(0)))
+	if name == "" {
+		// A raiz não tem entrada própria: vale para os arquivos dentro dela, como os outros diretórios
+		name = "*"
+	}
+	if err := fs.ChangePermission(name, path, !*off, *recursive); err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
+
+// saveImages salva as imagens alteradas por uma operação, mesmo que ela tenha falhado no meio.
+func saveImages(images []*FURGFileSystem, err error) error {
+	for _, image := range images {
+		if saveErr := image.saveFileSystemState(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	return err
+}
+
+// cliPut implementa "put [arquivo-local|-] <caminho-interno>". Sem arquivo local, ou com "-", lê da entrada padrão.
+// Se o caminho interno for um diretório existente, o arquivo é gravado nele com o nome do arquivo local.
+// Com -r, copia um diretório local com todo o conteúdo, selecionado pelas opções de filtro.
+func cliPut(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("put", flag.ContinueOnError)
+	recursive := flags.Bool("r", false, "copia um diretório com todo o conteúdo")
+	filter := &transferFilter{}
+	minSize, maxSize := filter.addFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if err := filter.setSizes(*minSize, *maxSize); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if *recursive {
+		if len(args) != 2 {
+			return classErrorf(ErrUsage, "uso: furgfs put -r [filtros] <diretório-local> <caminho-interno>")
+		}
+		dir, name := splitPath(fs.resolvePath(args[1]))
+		if fs.CheckDirectoryExists(joinPath(dir, name)) != -1 {
+			dir, name = joinPath(dir, name), filepath.Base(args[0])
+		}
+		if err := fs.importTree(args[0], dir, name, filter); err != nil {
+			return err
+		}
+		fmt.Printf(tr("%d arquivo(s) copiado(s), %d entrada(s) ignorada(s) pelos filtros.\n"), filter.copied, filter.skipped)
+		return fs.saveFileSystemState()
+	}
+	if filter.active() {
+		return classErrorf(ErrUsage, "erro: --include, --exclude, --min-size e --max-size exigem -r")
+	}
+
+	var source io.Reader = os.Stdin
+	var localName, internalPath string
+	switch len(args) {
+	case 1:
+		internalPath = args[0]
+	case 2:
+		internalPath = args[1]
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("erro ao abrir o arquivo: %w", err)
+			}
+			defer f.Close()
+			source = f
+			localName = filepath.Base(args[0])
+		}
+	default:
+		return classErrorf(ErrUsage, "uso: furgfs put [arquivo-local|-] <caminho-interno>")
+	}
+
+	internalPath = fs.resolvePath(internalPath)
+	if fs.CheckDirectoryExists(internalPath) != -1 {
+		if localName == "" {
+			return fmt.Errorf("erro: '%s' é um diretório, informe o nome do arquivo", internalPath)
+		}
+		internalPath = joinPath(internalPath, localName)
+	}
+
+	path, name := splitPath(fs.resolvePath(internalPath))
+	_, err := fs.createFile(path, name, source, false)
+	if err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
+
+// cliGet implementa "get <caminho-interno> [arquivo-local|-]". Sem arquivo local, ou com "-", escreve na saída padrão.
+// Com -r, copia um diretório interno com todo o conteúdo, selecionado pelas opções de filtro; se o destino
+// local for um diretório existente, a cópia fica dentro dele.
+func cliGet(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	recursive := flags.Bool("r", false, "copia um diretório com todo o conteúdo")
+	filter := &transferFilter{}
+	minSize, maxSize := filter.addFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if err := filter.setSizes(*minSize, *maxSize); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if *recursive {
+		if len(args) != 2 {
+			return classErrorf(ErrUsage, "uso: furgfs get -r [filtros] <caminho-interno> <diretório-local>")
+		}
+		dir, name := splitPath(fs.resolvePath(args[0]))
+		hostPath := args[1]
+		if info, err := os.Stat(hostPath); err == nil && info.IsDir() {
+			hostPath = filepath.Join(hostPath, name)
+		}
+		if err := fs.exportTree(dir, name, hostPath, filter); err != nil {
+			return err
+		}
+		fmt.Printf(tr("%d arquivo(s) copiado(s), %d entrada(s) ignorada(s) pelos filtros.\n"), filter.copied, filter.skipped)
+		return nil
+	}
+	if filter.active() {
+		return classErrorf(ErrUsage, "erro: --include, --exclude, --min-size e --max-size exigem -r")
+	}
+
+	if len(args) != 1 && len(args) != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs get <caminho-interno> [arquivo-local|-]")
+	}
+
+	path, name := splitPath(fs.resolvePath(args[0]))
+	rootDirIndex := fs.lookupEntry(name, path)
+	if rootDirIndex == -1 || fs.RootDir[rootDirIndex].IsDirectory {
+		return classErrorf(ErrNotFound, "erro: O arquivo '%s' não foi encontrado no sistema de arquivos", args[0])
+	}
+
+	if len(args) == 1 || args[1] == "-" {
+		return fs.exportFile(&fs.RootDir[rootDirIndex], os.Stdout)
+	}
+
+	destFile, err := os.Create(args[1])
+	if err != nil {
+		return fmt.Errorf("erro ao criar o arquivo no sistema real: %v", err)
+	}
+	defer destFile.Close()
+
+	return fs.exportFile(&fs.RootDir[rootDirIndex], destFile)
+}
+
+// cliImportTar implementa "import-tar [arquivo.tar[.gz]|-] [destino]". O destino padrão é a raiz.
+func cliImportTar(fs *FURGFileSystem, args []string) error {
+	if len(args) > 2 {
+		return classErrorf(ErrUsage, "uso: furgfs import-tar [arquivo.tar[.gz]|-] [destino]")
+	}
+
+	var source io.Reader = os.Stdin
+	if len(args) >= 1 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("erro ao abrir o arquivo: %w", err)
+		}
+		defer f.Close()
+		source = f
+	}
+
+	destPath := "/"
+	if len(args) == 2 {
+		destPath = args[1]
+	}
+
+	err := fs.ImportTar(source, destPath)
+	if err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
+
+// cliExportArchive implementa "export-archive [--format tar|tar.gz|zip] <diretório> [arquivo|-]".
+// Sem --format, o formato é deduzido pela extensão do arquivo de destino (tar para a saída padrão).
+func cliExportArchive(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("export-archive", flag.ContinueOnError)
+	format := flags.String("format", "", "formato do arquivo: tar, tar.gz ou zip")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	args = flags.Args()
+	if len(args) != 1 && len(args) != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs export-archive [--format tar|tar.gz|zip] <diretório> [arquivo|-]")
+	}
+
+	if len(args) == 1 || args[1] == "-" {
+		if *format == "" {
+			*format = archiveTar
+		}
+		return fs.ExportArchive(args[0], os.Stdout, *format)
+	}
+
+	if *format == "" {
+		*format = archiveFormatFromName(args[1])
+	}
+	destFile, err := os.Create(args[1])
+	if err != nil {
+		return fmt.Errorf("erro ao criar o arquivo no sistema real: %v", err)
+	}
+	defer destFile.Close()
+
+	return fs.ExportArchive(args[0], destFile, *format)
+}
+
+// cliImportZip implementa "import-zip [--internal] <arquivo.zip> [destino]".
+func cliImportZip(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("import-zip", flag.ContinueOnError)
+	internal := flags.Bool("internal", false, "o arquivo zip está armazenado no FURGfs2")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	args = flags.Args()
+	if len(args) != 1 && len(args) != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs import-zip [--internal] <arquivo.zip> [destino]")
+	}
+
+	destPath := "/"
+	if len(args) == 2 {
+		destPath = args[1]
+	}
+
+	var err error
+	if *internal {
+		err = fs.importInternalZip(args[0], destPath)
+	} else {
+		err = fs.importExternalZip(args[0], destPath)
+	}
+	if err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
+
+// cliClone implementa "clone [--size N] [--block-size N] [--entries N] <nova-imagem>".
+// Os valores não informados são herdados da imagem atual.
+func cliClone(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("clone", flag.ContinueOnError)
+	sizeStr := flags.String("size", "", "tamanho total da nova imagem (ex.: 100M, 1G)")
+	blockSize := flags.Uint("block-size", uint(fs.Header.BlockSize), "tamanho do bloco em bytes")
+	entries := flags.Uint("entries", uint(len(fs.RootDir)), "número de entradas do diretório")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 1 {
+		return classErrorf(ErrUsage, "uso: furgfs clone [--size 100M] [--block-size 4096] [--entries 100] <nova-imagem>")
+	}
+
+	totalSize := fs.Header.TotalSize
+	if *sizeStr != "" {
+		var err error
+		totalSize, err = parseSize(*sizeStr)
+		if err != nil {
+			return err
+		}
+	}
+	return fs.CloneTo(flags.Arg(0), totalSize, uint32(*blockSize), uint32(*entries))
+}
+
+// cliFsck implementa "fsck [-y|-n]". Sem opções, pergunta antes de cada reparo.
+// Retorna erro se restarem problemas não reparados.
+func cliFsck(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	yes := flags.Bool("y", false, "repara todos os problemas sem perguntar")
+	no := flags.Bool("n", false, "apenas verifica, sem reparar")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+
+	mode := fsckInteractive
+	if *yes {
+		mode = fsckAutomatic
+	} else if *no {
+		mode = fsckCheckOnly
+	}
+
+	found, repaired := fs.CheckFileSystem(mode)
+	if repaired > 0 {
+		if err := fs.saveFileSystemState(); err != nil {
+			return err
+		}
+	}
+	if found > repaired {
+		return classErrorf(ErrCorrupted, "erro: %d problema(s) não reparado(s)", found-repaired)
+	}
+	return nil
+}
+
+// cliGC implementa "gc [-n]".
+func cliGC(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := flags.Bool("n", false, "apenas informa os blocos órfãos, sem liberá-los")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+
+	collected, err := fs.CollectOrphanBlocks(*dryRun)
+	if err != nil {
+		return err
+	}
+	if collected > 0 && !*dryRun {
+		return fs.saveFileSystemState()
+	}
+	return nil
+}
+
+// cliUndelete implementa "undelete [<número> <caminho-interno>]". Sem argumentos, apenas lista os candidatos.
+func cliUndelete(fs *FURGFileSystem, args []string) error {
+	candidates, err := fs.ScanDeletedFiles()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		fs.ShowDeletedFiles(candidates)
+		return nil
+	}
+	if len(args) != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs undelete [<número> <caminho-interno>]")
+	}
+
+	choice, err := strconv.Atoi(args[0])
+	if err != nil || choice < 1 || choice > len(candidates) {
+		return fmt.Errorf("erro: número '%s' inválido, existem %d candidato(s)", args[0], len(candidates))
+	}
+	path, name := splitPath(fs.resolvePath(args[1]))
+	err = fs.UndeleteFile(candidates[choice-1], name, path)
+	if err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/aes"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CloneTo cria a imagem fileName com a nova geometria (tamanho total, tamanho do bloco e número de entradas)
+// e copia para ela todos os diretórios e arquivos, com seus atributos. A imagem atual não é alterada e,
+// se a cópia falhar, a nova imagem incompleta é apagada. Os snapshots não são copiados, pois referenciam os
+// blocos da imagem atual. Em um volume cifrado, a nova imagem usa a mesma chave e a mesma senha.
+func (fs *FURGFileSystem) CloneTo(fileName string, totalSize, blockSize, entries uint32) (err error) {
+	var usedEntries, requiredBlocks uint64
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 || isSnapshotFile(entry) {
+			continue
+		}
+		usedEntries++
+		requiredBlocks += (uint64(entry.Size) + uint64(blockSize) - 1) / uint64(blockSize)
+	}
+	if usedEntries > uint64(entries) {
+		return classErrorf(ErrNoSpace, "erro: a nova imagem precisa de pelo menos %d entradas no diretório", usedEntries)
+	}
+
+	state, err := fs.cryptState()
+	if err != nil {
+		return err
+	}
+	if state.params != nil && blockSize%aes.BlockSize != 0 {
+		return classErrorf(ErrUsage, "erro: a cifra exige blocos com tamanho múltiplo de %d bytes", aes.BlockSize)
+	}
+
+	clone, err := createFileSystemImage(fileName, blockSize, totalSize, entries)
+	if err != nil {
+		return err
+	}
+	// O crypt.json e o volume.json copiados só seriam lidos depois dos primeiros arquivos
+	clone.crypt = state
+	clone.cas = &casState{enabled: fs.casVolume()}
+	defer func() {
+		clone.closeImage()
+		if err != nil {
+			os.Remove(fileName)
+		}
+	}()
+
+	// No armazenamento por conteúdo, os blocos repetidos são gravados uma vez só; a falta de espaço
+	// aparece ao gravar os arquivos
+	if !clone.cas.enabled && requiredBlocks > uint64(len(clone.FAT)) {
+		return classErrorf(ErrNoSpace, "erro: a nova imagem tem %d blocos, mas os arquivos precisam de %d", len(clone.FAT), requiredBlocks)
+	}
+
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 || isSnapshotFile(entry) {
+			continue
+		}
+		if entry.IsDirectory {
+			err = clone.AddFileEntry(entry)
+		} else {
+			var r *fileReader
+			r, err = fs.newFileReader(entry)
+			if err == nil {
+				_, err = clone.storeFile(*entry, r)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("erro ao copiar '%s': %w", entry.FullPath(), err)
+		}
+	}
+
+	err = clone.saveFileSystemState()
+	if err != nil {
+		return err
+	}
+
+	if clone.cas.enabled {
+		space := clone.freeSpaceInfo()
+		requiredBlocks = uint64(space.TotalBlocks - space.FreeBlocks)
+	}
+	fmt.Printf("Imagem clonada para '%s': %d entradas copiadas, %d de %d blocos em uso.\n",
+		fileName, usedEntries, requiredBlocks, len(clone.FAT))
+	return nil
+}
+
+// parseSize converte tamanhos como "4096", "10M", "512K" ou "2G" (potências de 1024) em bytes.
+func parseSize(s string) (uint32, error) {
+	multiplier := uint64(1)
+	number := strings.ToUpper(strings.TrimSpace(s))
+	number = strings.TrimSuffix(number, "B")
+	switch {
+	case strings.HasSuffix(number, "K"):
+		multiplier = 1024
+	case strings.HasSuffix(number, "M"):
+		multiplier = 1024 * 1024
+	case strings.HasSuffix(number, "G"):
+		multiplier = 1024 * 1024 * 1024
+	}
+	if multiplier != 1 {
+		number = number[:len(number)-1]
+	}
+
+	value, err := strconv.ParseUint(number, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("erro: tamanho '%s' inválido", s)
+	}
+	if value*multiplier > math.MaxUint32 {
+		return 0, fmt.Errorf("erro: tamanho '%s' excede o máximo de 4 GB", s)
+	}
+	return uint32(value * multiplier), nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// "export-cloud" envia arquivos e árvores de diretórios do volume direto a um armazenamento de objetos, S3
+// ou Google Cloud Storage, sem passar pelo sistema real: o conteúdo é lido bloco a bloco enquanto é enviado.
+// Vários arquivos são enviados ao mesmo tempo; as leituras da imagem continuam uma de cada vez, e um envio
+// que falha por erro de rede ou resposta 5xx é repetido, com espera crescente, desde o primeiro bloco.
+const (
+	cloudExportJobs    = 4
+	cloudExportRetries = 3
+)
+
+// cloudUploader envia um objeto ao serviço e informa se vale tentar de novo após uma falha.
+type cloudUploader interface {
+	put(key string, body io.Reader, size int64, contentType string) (retry bool, err error)
+}
+
+// cloudTarget interpreta o destino s3://bucket/prefixo ou gs://bucket/prefixo.
+func cloudTarget(dest string) (cloudUploader, string, error) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Host == "" || u.Scheme != "s3" && u.Scheme != "gs" {
+		return nil, "", classErrorf(ErrUsage, "erro: destino '%s' inválido, use s3://bucket/prefixo ou gs://bucket/prefixo", dest)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if u.Scheme == "gs" {
+		up, err := newGCSUploader(u.Host)
+		return up, prefix, err
+	}
+	// newS3Backend exige uma chave; o objeto é trocado a cada envio
+	b, err := newS3Backend(&url.URL{Scheme: "s3", Host: u.Host, Path: "/-"})
+	if err != nil {
+		return nil, "", err
+	}
+	return &s3Uploader{backend: *b, bucket: strings.TrimSuffix(b.object, "/-")}, prefix, nil
+}
+
+// s3Uploader envia objetos com as credenciais e o endereço de s3Backend.
+type s3Uploader struct {
+	backend s3Backend
+	bucket  string // URL do bucket, sem a barra final
+}
+
+func (s *s3Uploader) put(key string, body io.Reader, size int64, contentType string) (bool, error) {
+	b := s.backend
+	b.object = s.bucket + "/" + s3Escape(key, true)
+	req, err := b.request(http.MethodPut, body)
+	if err != nil {
+		return false, err
+	}
+	return sendObject(req, size, contentType)
+}
+
+// gcsUploader envia objetos pela API XML do Cloud Storage, com o token OAuth de GOOGLE_OAUTH_ACCESS_TOKEN
+// (por exemplo, o de "gcloud auth print-access-token") e o endereço de FURGFS_GCS_ENDPOINT, para emuladores.
+type gcsUploader struct {
+	bucket string // URL do bucket, sem a barra final
+	token  string
+}
+
+func newGCSUploader(bucket string) (*gcsUploader, error) {
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if token == "" {
+		return nil, classErrorf(ErrUsage, "erro: defina GOOGLE_OAUTH_ACCESS_TOKEN para enviar ao Cloud Storage")
+	}
+	endpoint := strings.TrimSuffix(os.Getenv("FURGFS_GCS_ENDPOINT"), "/")
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+	return &gcsUploader{bucket: endpoint + "/" + s3Escape(bucket, false), token: token}, nil
+}
+
+func (g *gcsUploader) put(key string, body io.Reader, size int64, contentType string) (bool, error) {
+	req, err := http.NewRequest(http.MethodPut, g.bucket+"/"+s3Escape(key, true), body)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	return sendObject(req, size, contentType)
+}
+
+// sendObject envia o pedido de gravação req, cujo corpo tem size bytes.
+func sendObject(req *http.Request, size int64, contentType string) (bool, error) {
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		// S3 e a API XML do Cloud Storage descrevem o erro no mesmo formato
+		var e struct{ Code, Message string }
+		if xml.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&e) == nil && e.Code != "" {
+			return retry, fmt.Errorf("resposta %s: %s (%s)", resp.Status, e.Message, e.Code)
+		}
+		return retry, fmt.Errorf("resposta %s", resp.Status)
+	}
+	return false, nil
+}
+
+// cloudObject é um arquivo a enviar e a chave do objeto que o recebe.
+type cloudObject struct {
+	entry *FileEntry
+	key   string
+}
+
+// lockedReader serializa as leituras da imagem feitas pelos envios simultâneos.
+type lockedReader struct {
+	mu *sync.Mutex
+	r  io.Reader
+}
+
+func (l lockedReader) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Read(p)
+}
+
+// cloudObjects lista os arquivos de source, um arquivo ou diretório, com as chaves sob prefix: o nome de
+// source e, abaixo dele, o caminho relativo de cada arquivo.
+func (fs *FURGFileSystem) cloudObjects(source, prefix string, filter *transferFilter) ([]cloudObject, error) {
+	dir, name := splitPath(source)
+	index := fs.lookupEntry(name, dir)
+	if index == -1 {
+		return nil, classErrorf(ErrNotFound, "erro: '%s' não foi encontrado no sistema de arquivos", source)
+	}
+	base := path.Join(prefix, name)
+	if !fs.RootDir[index].IsDirectory {
+		if !filter.allows(name, false, int64(fs.RootDir[index].Size)) {
+			return nil, nil
+		}
+		return []cloudObject{{&fs.RootDir[index], base}}, nil
+	}
+	var objects []cloudObject
+	var walk func(dir, rel string)
+	walk = func(dir, rel string) {
+		for _, i := range fs.entriesInDirectory(dir, false) {
+			entry := &fs.RootDir[i]
+			child := path.Join(rel, entry.NameString())
+			if !filter.allows(child, entry.IsDirectory, int64(entry.Size)) {
+				continue
+			}
+			if entry.IsDirectory {
+				walk(entry.FullPath(), child)
+			} else {
+				objects = append(objects, cloudObject{entry, path.Join(base, child)})
+			}
+		}
+	}
+	walk(source, "")
+	return objects, nil
+}
+
+// ExportCloud envia objects por up, com jobs envios ao mesmo tempo e até retries novas tentativas cada um.
+// report é chamada, um arquivo por vez, com o resultado de cada envio. Retorna o total de bytes enviados.
+func (fs *FURGFileSystem) ExportCloud(up cloudUploader, objects []cloudObject, jobs, retries int, report func(o cloudObject, err error)) int64 {
+	var readMu, reportMu sync.Mutex
+	var sent int64
+	queue := make(chan cloudObject)
+	var wg sync.WaitGroup
+	for range min(jobs, max(len(objects), 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for o := range queue {
+				err := fs.uploadObject(up, o, retries, &readMu)
+				reportMu.Lock()
+				if err == nil {
+					sent += int64(o.entry.Size)
+				}
+				report(o, err)
+				reportMu.Unlock()
+			}
+		}()
+	}
+	for _, o := range objects {
+		queue <- o
+	}
+	close(queue)
+	wg.Wait()
+	logger.Info("arquivos enviados ao armazenamento de objetos", fs.imageAttr(), "files", len(objects), "bytes", sent)
+	return sent
+}
+
+// uploadObject envia o arquivo de o, lendo-o de novo da imagem a cada tentativa.
+func (fs *FURGFileSystem) uploadObject(up cloudUploader, o cloudObject, retries int, readMu *sync.Mutex) error {
+	contentType := mime.TypeByExtension(path.Ext(o.key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	delay := time.Second
+	for attempt := 0; ; attempt++ {
+		readMu.Lock()
+		r, err := fs.newFileReader(o.entry)
+		readMu.Unlock()
+		if err != nil {
+			return err
+		}
+		retry, err := up.put(o.key, lockedReader{readMu, r}, int64(o.entry.Size), contentType)
+		if err == nil || !retry || attempt == retries {
+			return err
+		}
+		logger.Warn("envio falhou; tentando de novo", "key", o.key, "attempt", attempt+1, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// cliExportCloud implementa "export-cloud [-j N] [--retries N] [filtros] <caminho-interno>... <destino>".
+func cliExportCloud(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("export-cloud", flag.ContinueOnError)
+	jobs := flags.Int("j", cloudExportJobs, "número de envios simultâneos")
+	retries := flags.Int("retries", cloudExportRetries, "novas tentativas de cada envio após uma falha")
+	filter := &transferFilter{}
+	minSize, maxSize := filter.addFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if err := filter.setSizes(*minSize, *maxSize); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		return classErrorf(ErrUsage, "uso: furgfs export-cloud [-j N] [--retries N] [filtros] <caminho-interno>... <s3://bucket/prefixo|gs://bucket/prefixo>")
+	}
+	if *jobs < 1 || *retries < 0 {
+		return classErrorf(ErrUsage, "erro: -j deve ser pelo menos 1 e --retries não pode ser negativo")
+	}
+	dest := flags.Arg(flags.NArg() - 1)
+	up, prefix, err := cloudTarget(dest)
+	if err != nil {
+		return err
+	}
+	var objects []cloudObject
+	for _, source := range flags.Args()[:flags.NArg()-1] {
+		found, err := fs.cloudObjects(fs.resolvePath(source), prefix, filter)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, found...)
+	}
+
+	failed := 0
+	sent := fs.ExportCloud(up, objects, *jobs, *retries, func(o cloudObject, err error) {
+		if err != nil {
+			failed++
+			printError(fmt.Errorf(tr("erro ao enviar '%s': %v"), o.entry.FullPath(), err))
+		}
+	})
+	fmt.Printf(tr("%d arquivo(s) enviado(s) para '%s' (%d bytes), %d entrada(s) ignorada(s) pelos filtros.\n"),
+		len(objects)-failed, dest, sent, filter.skipped)
+	if failed > 0 {
+		return fmt.Errorf(tr("erro: %d arquivo(s) não foram enviados"), failed)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Sequências ANSI usadas na saída colorida.
+const (
+	ansiReset     = "\x1b[0m"
+	ansiDirectory = "\x1b[1;34m" // azul em negrito
+	ansiProtected = "\x1b[33m"   // amarelo
+	ansiError     = "\x1b[31m"   // vermelho
+)
+
+// palette aplica as cores de uma saída; com enabled falso o texto é devolvido sem alterações.
+type palette struct {
+	enabled bool
+}
+
+// Cores da saída padrão e da saída de erro, definidas por setupColor. Cada saída é detectada
+// separadamente, de modo que "furgfs ls > lista.txt" grava texto puro mesmo em um terminal.
+var (
+	stdoutColors palette
+	stderrColors palette
+)
+
+// setupColor define quando colorir a saída: "always", "never" ou "auto" (padrão), que colore apenas
+// quando a saída é um terminal, NO_COLOR não está definida e TERM não é "dumb".
+func setupColor(mode string) error {
+	switch strings.ToLower(mode) {
+	case "", "auto":
+		stdoutColors = palette{enabled: colorTerminal(os.Stdout)}
+		stderrColors = palette{enabled: colorTerminal(os.Stderr)}
+	case "always":
+		stdoutColors, stderrColors = palette{enabled: true}, palette{enabled: true}
+	case "never":
+		stdoutColors, stderrColors = palette{}, palette{}
+	default:
+		return classErrorf(ErrUsage, "erro: modo de cor '%s' inválido (use auto, always ou never)", mode)
+	}
+	return nil
+}
+
+// colorTerminal informa se f é um terminal que aceita cores.
+func colorTerminal(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	_, _, err := terminalSize(int(f.Fd()))
+	return err == nil
+}
+
+func (p palette) paint(code, text string) string {
+	if !p.enabled || text == "" {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+// entryName colore o nome de uma entrada: diretórios em azul, arquivos protegidos em amarelo.
+func (p palette) entryName(entry *FileEntry, name string) string {
+	switch {
+	case entry.IsDirectory:
+		return p.paint(ansiDirectory, name)
+	case entry.Protected:
+		return p.paint(ansiProtected, name)
+	}
+	return name
+}
+
+// failure colore uma mensagem de erro.
+func (p palette) failure(text string) string {
+	return p.paint(ansiError, text)
+}
+
+// printError exibe err na saída de erro padrão, em vermelho quando ela é um terminal.
+func printError(err error) {
+	fmt.Fprintln(os.Stderr, stderrColors.failure(err.Error()))
+}
+
+// printMenuError exibe err na saída padrão, usada pelo menu interativo para todas as mensagens.
+func printMenuError(err error) {
+	fmt.Println(stdoutColors.failure(err.Error()))
+}
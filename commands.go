@@ -0,0 +1,752 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// commandInfo descreve um comando da linha de comando: como usá-lo, o que faz e exemplos, para o help.
+type commandInfo struct {
+	Name     string
+	Usage    string // argumentos após o nome; formas alternativas separadas por '\n'
+	Summary  string // descrição curta exibida na lista de comandos
+	Details  string // explicação exibida apenas em "help <comando>"
+	Examples []string
+	// Run executa o comando sobre a imagem atual. Comandos que operam antes de a imagem ser carregada
+	// (mkfs e superblock) usam Standalone e não ficam disponíveis em scripts.
+	Run        func(fs *FURGFileSystem, args []string) error
+	Standalone func(imageName string, args []string) error
+}
+
+// Convenções de caminhos, referenciadas pelo help dos comandos que recebem caminhos internos.
+const pathConventions = `Caminhos internos começam com '/' (absolutos) ou são relativos ao diretório atual (veja cd);
+'.' e '..' são aceitos. O caminho de uma entrada é o do diretório pai mais o nome, por exemplo
+/docs/relatorio.pdf. Nomes têm até 32 bytes, caminhos até 128, e não podem conter '/', '*', '?' ou '['.
+Com mais de uma imagem aberta (veja open), cp e mv aceitam "imagem:/caminho".
+Arquivos locais (do sistema real) seguem as regras do sistema operacional; '-' indica a entrada ou
+a saída padrão.`
+
+// Opções de filtro das cópias recursivas de put e get.
+const transferFilters = `Filtros das cópias com -r: --include <padrão> copia apenas os arquivos que casam com o padrão,
+--exclude <padrão> ignora arquivos e diretórios (os dois podem ser repetidos), --min-size e --max-size
+limitam o tamanho dos arquivos (aceitam K, M e G). Padrões com '/' valem para o caminho relativo à
+raiz da cópia; os demais, para o nome.`
+
+// helpTopics são assuntos gerais que podem ser consultados com "help <assunto>".
+var helpTopics = map[string]string{
+	"caminhos": pathConventions,
+	"codigos": `Códigos de saída: 0 sucesso, 1 erro geral, 2 uso incorreto, 3 arquivo, diretório ou imagem não encontrado,
+4 espaço ou entradas insuficientes, 5 arquivo protegido, 6 imagem corrompida (execute o fsck),
+7 ação destrutiva não confirmada (falta --force). Em scripts, vale o código da primeira falha.`,
+}
+
+// commands é o registro dos comandos, na ordem em que aparecem no help. É preenchido em init porque
+// script e help consultam o próprio registro.
+var commands []commandInfo
+
+func init() {
+	commands = []commandInfo{
+		{
+			Name:       "mkfs",
+			Usage:      "[--size 10M] [--block-size 4096] [--entries 100] [--label rótulo] [--cas] [--prealloc|--sparse] [imagem]",
+			Summary:    "cria uma imagem vazia (--force substitui uma imagem existente);\nduas entradas guardam os metadados do volume em /.furgfs",
+			Details:    "Os tamanhos aceitam os sufixos K, M e G. Sem a imagem, usa a imagem selecionada por --image.\n\nCom --cas, os arquivos são guardados por conteúdo: cada bloco é identificado pelo SHA-256 dos seus\ndados e guardado uma vez só, mesmo que se repita em vários arquivos, e cada arquivo passa a ser a lista\ndos hashes dos seus blocos. Cópias e snapshots não duplicam os dados, e verify-image confere o conteúdo\nde cada bloco com o hash registrado. O modo só é escolhido na criação da imagem; compact e undelete não\nsão suportados nele.\n\nA imagem é criada com o tamanho total, mas esparsa (--sparse, o padrão): ocupa no disco só o que já\nfoi escrito e cresce conforme os blocos são usados. Com --prealloc, todo o espaço é reservado na criação\n(fallocate, no Linux), para um desempenho previsível e sem o risco de o disco encher durante o uso.",
+			Examples:   []string{"furgfs mkfs --size 50M --label backup novo.fs2", "furgfs mkfs --size 1G --cas dedup.fs2", "furgfs mkfs --size 2G --prealloc dados.fs2"},
+			Standalone: cliMkfs,
+		},
+		{
+			Name:     "ls",
+			Usage:    "[-l] [-R] [--json] [diretório]",
+			Summary:  "lista o conteúdo de um diretório; -l detalha, -R inclui subdiretórios",
+			Details:  pathConventions,
+			Examples: []string{"furgfs ls -l /docs", "furgfs ls -R --json"},
+			Run:      cliLs,
+		},
+		{
+			Name:     "tree",
+			Usage:    "[--json] [diretório]",
+			Summary:  "mostra a árvore de diretórios e arquivos",
+			Examples: []string{"furgfs tree /docs"},
+			Run:      cliTree,
+		},
+		{
+			Name:     "stat",
+			Usage:    "[--json] <caminho-interno>",
+			Summary:  "mostra os atributos e os blocos de um arquivo ou diretório",
+			Details:  pathConventions,
+			Examples: []string{"furgfs stat /docs/relatorio.pdf"},
+			Run:      cliStat,
+		},
+		{
+			Name:     "df",
+			Usage:    "[--json]",
+			Summary:  "mostra o espaço livre e ocupado",
+			Examples: []string{"furgfs df --json"},
+			Run:      cliDf,
+		},
+		{
+			Name:     "imagediff",
+			Usage:    "[--json] [imagem] <outra>",
+			Summary:  "compara logicamente duas imagens (arquivos e metadados)",
+			Details:  "As imagens podem ser caminhos, volumes da configuração ou nomes de imagens abertas com open.\nSem a primeira imagem, compara a imagem atual com a outra.",
+			Examples: []string{"furgfs -i furg.fs2 imagediff backup.fs2", "furgfs imagediff antes.fs2 depois.fs2"},
+			Run:      cliImageDiff,
+		},
+		{
+			Name:     "stats",
+			Usage:    "[--json] [--top N]",
+			Summary:  "estatísticas de uso: contagens, maiores arquivos e histograma",
+			Examples: []string{"furgfs stats --top 5"},
+			Run:      cliStats,
+		},
+		{
+			Name:     "catalog",
+			Usage:    "[--format csv|json] [--checksum] [arquivo|-]",
+			Summary:  "exporta a tabela do diretório inteira em CSV ou JSON, para planilhas e análises externas",
+			Details:  "Cada entrada traz índice, caminho, nome, diretório pai, tipo, tamanho, número de blocos, primeiro\nbloco, fragmentos e as marcas protected e system (dentro de /.furgfs). Sem --format, o formato vem da\nextensão do arquivo; na saída padrão, o padrão é CSV.\n\nA imagem não guarda datas: created e modified vêm do diário de alterações (veja journal), quando\nativo, e ficam vazias nas entradas anteriores aos registros disponíveis. --checksum acrescenta o\nSHA-256 do conteúdo dos arquivos, lido do índice de metadados (veja index) quando houver.",
+			Examples: []string{"furgfs catalog entradas.csv", "furgfs catalog --format json --checksum - | jq '.entries[] | select(.size > 1000000)'"},
+			Run:      cliCatalog,
+		},
+		{
+			Name:     "tune",
+			Usage:    "[--label <rótulo>] [--uuid regenerate|<uuid>]",
+			Summary:  "altera o rótulo e o UUID do volume",
+			Details:  "--uuid regenerate gera um UUID aleatório; trocar um UUID existente exige --force, pois os próximos\nbackups incrementais não poderão continuar as cadeias gravadas com o UUID anterior. Imagens antigas,\nsem metadados, passam a tê-los. O rótulo e o UUID aparecem em stat / e em stats.",
+			Examples: []string{"furgfs tune --label dados", "furgfs tune --uuid regenerate --force"},
+			Run:      cliTune,
+		},
+		{
+			Name:     "user",
+			Usage:    "add [--read-only] [--smb] [--key <arquivo.pub>] <nome>\npasswd [--smb] <nome>\ns3key [--remove] <nome>\nremove <nome>\nlist [--json]",
+			Summary:  "cadastra um usuário dos servidores de rede, com senha ou chaves públicas SSH\ntroca a senha de um usuário\ncria uma nova chave de acesso S3 para um usuário, ou a remove\nremove um usuário\nlista os usuários cadastrados",
+			Details:  "Os usuários são usados por \"serve sftp\", \"serve api\", \"serve grpc\" e \"serve smb\" e ficam em\n/.furgfs/users.json, com a senha guardada como hash PBKDF2-SHA256. Sem --key, a senha é lida de\nFURGFS_NEW_PASSWORD ou pedida no terminal; com --key, o usuário entra com as chaves do arquivo, no formato\nde authorized_keys. Um servidor em execução só vê as alterações ao ser reiniciado.\n\nO SMB autentica por NTLMv2, que precisa do hash NT da senha. Ele só é guardado com --smb e, como não\ntem sal, vale tanto quanto a própria senha para quem ler a imagem; \"user passwd\" sem --smb o remove.\n\nO \"serve s3\" usa o nome do usuário como chave de acesso e uma chave secreta aleatória, mostrada por\n\"user s3key\". A AWS Signature Version 4 exige a chave em texto, por isso ela é guardada como está;\ncada \"user s3key\" troca a chave anterior, e --remove a apaga.",
+			Examples: []string{"furgfs user add ana", "furgfs user add --read-only --key ~/.ssh/id_ed25519.pub leitor", "furgfs user add --smb ana", "furgfs user s3key ana"},
+			Run:      cliUser,
+		},
+		{
+			Name:     "quota",
+			Usage:    "set [--files N|none] <diretório> <tamanho|none>\nset [--files N|none] --user <nome> <tamanho|none>\nshow [--json]\nreport [--json] [--top N]",
+			Summary:  "define ou remove os limites de bytes e de arquivos de um diretório\ndefine ou remove os limites do que um usuário grava pelos servidores de rede\nmostra as cotas com o uso atual de cada diretório e de cada usuário\nlista os diretórios que mais ocupam espaço, com suas cotas",
+			Details:  "A cota de um diretório conta o diretório e todos os seus subdiretórios e é verificada ao gravar e ao\nmover arquivos. As cotas dos diretórios ficam em /.furgfs/quota.json e acompanham o diretório quando ele\né movido.\n\nA cota de um usuário vale para o que ele grava por \"serve\" e conta os arquivos de que ele é dono: os que\ngravou pelos servidores de rede e que ainda existem, onde quer que estejam. O dono de cada arquivo fica\nem /.furgfs/owners.json; os arquivos gravados pela linha de comando não têm dono. As cotas dos usuários\nficam em /.furgfs/user_quota.json.",
+			Examples: []string{"furgfs quota set --files 500 /docs 10M", "furgfs quota set --user ana 1G", "furgfs quota report --top 5"},
+			Run:      cliQuota,
+		},
+		{
+			Name:     "mkdir",
+			Usage:    "[-p] <diretório>",
+			Summary:  "cria um diretório; -p cria também os diretórios pais",
+			Details:  pathConventions,
+			Examples: []string{"furgfs mkdir -p /docs/2024/notas"},
+			Run:      cliMkdir,
+		},
+		{
+			Name:     "rm",
+			Usage:    "[-r] <caminho-interno>...",
+			Summary:  "remove um arquivo ou diretório vazio; -r remove com todo o conteúdo",
+			Details:  "Exige --force; arquivos protegidos exigem também --override-protection. Em um pipeline e sem\ncaminhos, remove os caminhos recebidos do comando anterior, um por linha.\n\n" + pathConventions,
+			Examples: []string{"furgfs rm --force /docs/velho.txt", "furgfs rm -r --force /tmp", "furgfs -c 'find *.log | rm --force'"},
+			Run:      cliRm,
+		},
+		{
+			Name:     "mv",
+			Usage:    "<origem> <destino>",
+			Summary:  "move ou renomeia um arquivo ou diretório",
+			Details:  "Se o destino for um diretório existente, a entrada é movida para dentro dele.\n\n" + pathConventions,
+			Examples: []string{"furgfs mv /a.txt /docs/b.txt", "furgfs -c 'open outra.fs2 bkp; mv /docs bkp:/ --force'"},
+			Run:      cliMv,
+		},
+		{
+			Name:     "cp",
+			Usage:    "<origem> <destino>",
+			Summary:  "copia um arquivo ou diretório; entre imagens abertas, use imagem:/caminho",
+			Details:  "Se o destino for um diretório existente, a entrada é copiada para dentro dele.\n\n" + pathConventions,
+			Examples: []string{"furgfs cp /docs /docs-copia", "furgfs -c 'open outra.fs2 bkp; cp /img.jpeg bkp:/'"},
+			Run:      cliCp,
+		},
+		{
+			Name:     "protect",
+			Usage:    "[-r] [--off] <caminho-interno|padrão>",
+			Summary:  "protege arquivos contra escrita e remoção; --off retira a proteção",
+			Details:  "O caminho pode ser um arquivo, um diretório (afeta os arquivos dentro dele) ou um padrão glob no\núltimo elemento, como /docs/*.txt; -r inclui os subdiretórios.\n\n" + pathConventions,
+			Examples: []string{"furgfs protect /docs/contrato.pdf", "furgfs protect -r '/docs/*.txt'", "furgfs protect --off /docs"},
+			Run:      cliProtect,
+		},
+		{
+			Name:     "split",
+			Usage:    "<caminho-interno> <tamanho>",
+			Summary:  "divide um arquivo em partes numeradas <nome>.000, <nome>.001...",
+			Details:  "As partes ficam no mesmo diretório do arquivo, que é mantido. O tamanho aceita os sufixos K, M e G.",
+			Examples: []string{"furgfs split /videos/aula.mp4 100M"},
+			Run:      cliSplit,
+		},
+		{
+			Name:     "join",
+			Usage:    "<padrão> <destino>",
+			Summary:  "junta em um novo arquivo os arquivos que casam com o padrão",
+			Details:  "As partes são juntadas em ordem alfabética. O padrão é um glob no último elemento do caminho, como /videos/aula.mp4.*. Use aspas na linha de\ncomando para que o shell do sistema não o expanda.",
+			Examples: []string{"furgfs join '/videos/aula.mp4.*' /videos/aula.mp4"},
+			Run:      cliJoin,
+		},
+		{
+			Name:     "put",
+			Usage:    "[arquivo-local|-] <caminho-interno>\n-r [filtros] <diretório-local> <caminho-interno>",
+			Summary:  "copia um arquivo (ou a entrada padrão) para o FURGfs2\ncopia um diretório local com todo o conteúdo",
+			Details:  "Se o caminho interno for um diretório, o arquivo mantém o nome local.\n\n" + transferFilters + "\n\n" + pathConventions,
+			Examples: []string{"furgfs put foto.jpg /fotos", "echo oi | furgfs put - /oi.txt", "furgfs put -r --include '*.pdf' --exclude rascunhos docs /"},
+			Run:      cliPut,
+		},
+		{
+			Name:     "get",
+			Usage:    "<caminho-interno> [arquivo-local|-]\n-r [filtros] <caminho-interno> <diretório-local>",
+			Summary:  "copia um arquivo do FURGfs2 para o sistema real (ou a saída padrão)\ncopia um diretório interno com todo o conteúdo",
+			Details:  transferFilters + "\n\n" + pathConventions,
+			Examples: []string{"furgfs get /docs/relatorio.pdf relatorio.pdf", "furgfs get /a.txt | less", "furgfs get -r --max-size 10M /fotos ."},
+			Run:      cliGet,
+		},
+		{
+			Name:     "sync",
+			Usage:    "[--to-host] [--delete] [--checksum] [-n] [filtros] <diretório-local> <caminho-interno>",
+			Summary:  "sincroniza um diretório local com um diretório interno, copiando só o que mudou",
+			Details:  "Copia do diretório local para o interno ou, com --to-host, do interno para o local, apenas os arquivos\nnovos ou alterados; --delete apaga no destino o que não existe mais na origem, e -n só mostra o que\nseria feito. Cada alteração é listada com + (criado), ~ (atualizado), - (apagado) ou ! (protegido).\n\nO FURGfs2 não guarda datas, por isso o tamanho, a data do arquivo local e o SHA-256 de cada arquivo\nna última sincronização ficam em /.furgfs/sync.json. Arquivos com o mesmo tamanho são comparados pelo\nconteúdo, a menos que o arquivo local não tenha mudado desde então; --checksum compara sempre.\n\nAceita os filtros --include, --exclude, --min-size e --max-size de put -r; as entradas ignoradas\nnão são copiadas nem apagadas.",
+			Examples: []string{"furgfs sync --delete fotos /fotos", "furgfs sync --to-host -n ~/docs /docs"},
+			Run:      cliSync,
+		},
+		{
+			Name:       "sync-watch",
+			Usage:      "[--delay <duração>] <diretório-local> <caminho-interno> [imagem]",
+			Summary:    "mantém um diretório local e um diretório interno sincronizados nos dois sentidos",
+			Details:    "Fica em execução até ser interrompido com Ctrl+C, observando o diretório local e o arquivo da imagem:\ndepois de --delay sem novas alterações, o que mudou de um lado, inclusive arquivos apagados, é levado para\no outro. A imagem é lida de novo a cada vez, então as alterações de um \"serve\" em execução também são\nsincronizadas.\n\nCada lado é comparado com a última sincronização, guardada em /.furgfs/sync.json como em sync. Um arquivo\nalterado dos dois lados de formas diferentes é um conflito: ele é indicado com ! e fica como está até que\num dos lados seja acertado. Alterar um arquivo vence apagá-lo do outro lado.",
+			Examples:   []string{"furgfs sync-watch ~/docs /docs", "furgfs sync-watch --delay 2s fotos /fotos backup.fs2"},
+			Standalone: cliSyncWatch,
+		},
+		{
+			Name:     "cat",
+			Usage:    "[arquivo...]",
+			Summary:  "escreve o conteúdo de arquivos internos na saída padrão",
+			Details:  "Sem arquivos, copia a entrada padrão, por exemplo no meio de um pipeline.\n\n" + pathConventions,
+			Examples: []string{"furgfs cat /notas.txt", "furgfs -c 'cat /notas.txt | grep TODO'"},
+			Run:      cliCat,
+		},
+		{
+			Name:     "grep",
+			Usage:    "[-i] [-v] [-n] [-c] <padrão> [arquivo...]",
+			Summary:  "exibe as linhas de arquivos internos (ou da entrada) que casam com uma expressão regular",
+			Details:  "-i ignora maiúsculas e minúsculas, -v inverte a busca, -n numera as linhas e -c apenas as conta.",
+			Examples: []string{"furgfs grep -n TODO /notas.txt", "furgfs -c 'ls -R / | grep pdf'"},
+			Run:      cliGrep,
+		},
+		{
+			Name:     "find",
+			Usage:    "[-type f|d] [--hash <sha256>] [padrão] [diretório]",
+			Summary:  "lista os caminhos das entradas cujo nome casa com um padrão glob",
+			Details:  "A busca inclui os subdiretórios do diretório (o atual, por padrão). Use aspas no padrão na linha de\ncomando para que o shell do sistema não o expanda.\n\n--hash lista só os arquivos com o conteúdo de SHA-256 indicado, como as cópias de um arquivo. Sem o\níndice de metadados (veja index), todos os arquivos são lidos.",
+			Examples: []string{"furgfs find '*.pdf' /docs", "furgfs find -type d", "furgfs find --hash $(sha256sum foto.jpg | cut -c1-64)"},
+			Run:      cliFind,
+		},
+		{
+			Name:     "diff",
+			Usage:    "<arquivo1> <arquivo2>",
+			Summary:  "compara dois arquivos armazenados, bloco a bloco e, se forem texto, linha a linha",
+			Details:  "A comparação para no primeiro bloco diferente, que é mostrado. Arquivos de texto de até 64 KB também são\ncomparados linha a linha, com um diff unificado.",
+			Examples: []string{"furgfs diff /docs/v1.txt /docs/v2.txt"},
+			Run:      cliDiff,
+		},
+		{
+			Name:     "import-tar",
+			Usage:    "[arquivo.tar[.gz]|-] [destino]",
+			Summary:  "expande um arquivo tar (ou a entrada padrão) dentro do FURGfs2",
+			Examples: []string{"furgfs import-tar fontes.tar.gz /fontes", "tar c docs | furgfs import-tar - /"},
+			Run:      cliImportTar,
+		},
+		{
+			Name:     "import-zip",
+			Usage:    "[--internal] <arquivo.zip> [destino]",
+			Summary:  "expande um arquivo zip do sistema real (ou do FURGfs2, com --internal)",
+			Examples: []string{"furgfs import-zip fotos.zip /fotos", "furgfs import-zip --internal /pacote.zip /"},
+			Run:      cliImportZip,
+		},
+		{
+			Name:     "import-fat",
+			Usage:    "<imagem-fat> [destino]",
+			Summary:  "copia os diretórios e arquivos de uma imagem FAT12/16/32 (disquete, cartão SD)",
+			Details:  "A imagem deve conter o volume FAT diretamente, sem tabela de partições. Nomes longos são\npreservados e arquivos somente leitura ficam protegidos; nomes que o FURGfs2 não aceita são ignorados.",
+			Examples: []string{"furgfs import-fat disquete.img /disquete"},
+			Run:      cliImportFAT,
+		},
+		{
+			Name:     "export-archive",
+			Usage:    "[--format tar|tar.gz|zip] <diretório> [arquivo|-]",
+			Summary:  "exporta um diretório como tar/zip (ou para a saída padrão)",
+			Examples: []string{"furgfs export-archive --format zip /docs docs.zip"},
+			Run:      cliExportArchive,
+		},
+		{
+			Name:     "export-cloud",
+			Usage:    "[-j N] [--retries N] [filtros] <caminho-interno>... <s3://bucket/prefixo|gs://bucket/prefixo>",
+			Summary:  "envia arquivos e diretórios direto a um bucket S3 ou Google Cloud Storage",
+			Details:  "Cada arquivo vira o objeto <prefixo>/<nome>, e um diretório leva junto o caminho relativo de cada\narquivo abaixo dele; os filtros são os de get -r. O conteúdo é lido da imagem enquanto é enviado, com\n-j envios ao mesmo tempo (padrão 4), e um envio que falha por erro de rede ou resposta 5xx é repetido\naté --retries vezes (padrão 3).\n\nO S3 usa as mesmas variáveis das imagens s3:// (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION\ne FURGFS_S3_ENDPOINT); o Cloud Storage usa o token OAuth de GOOGLE_OAUTH_ACCESS_TOKEN, por exemplo o de\n\"gcloud auth print-access-token\", e FURGFS_GCS_ENDPOINT para emuladores.",
+			Examples: []string{"furgfs export-cloud /www s3://site/publicado", "furgfs export-cloud -j 8 --include '*.jpg' /fotos gs://acervo/2024"},
+			Run:      cliExportCloud,
+		},
+		{
+			Name:     "clone",
+			Usage:    "[--size 100M] [--block-size 4096] [--entries 100] <nova-imagem>",
+			Summary:  "copia todo o conteúdo para uma nova imagem com outra geometria",
+			Examples: []string{"furgfs clone --size 100M --entries 500 maior.fs2"},
+			Run:      cliClone,
+		},
+		{
+			Name:       "convert",
+			Usage:      "<imagem> <arquivo.tar|.tar.gz|.zip|->\n[--size 10M] [--block-size 4096] [--entries 100] [--label rótulo] <arquivo> <nova-imagem>",
+			Summary:    "exporta todo o conteúdo de uma imagem para um tar ou zip\ncria uma nova imagem com o conteúdo de um tar ou zip",
+			Details:    "O sentido é deduzido da origem: uma imagem FURGfs2 é exportada, qualquer outro arquivo é importado.\nSem --size e --entries, a nova imagem é dimensionada pelo conteúdo, com folga de 25%. O diretório de\nsistema /.furgfs não é exportado; a nova imagem recebe metadados de volume novos.",
+			Examples:   []string{"furgfs convert furg.fs2 furg.tar.gz", "furgfs convert --label fotos fotos.zip fotos.fs2"},
+			Standalone: cliConvert,
+		},
+		{
+			Name:       "apply",
+			Usage:      "[--prune] [-n] <manifesto>",
+			Summary:    "cria ou atualiza o volume para que ele corresponda a um manifesto YAML ou JSON",
+			Details:    "O manifesto descreve o volume (size, block-size, entries, label e cas, usados só ao criar a imagem),\nos diretórios, com quota e quota-files opcionais, e os arquivos, cada um com path, o conteúdo em source\n(um arquivo ou diretório do sistema real, relativo ao manifesto) ou em content, e protected. Um\ndiretório em source é sincronizado como em sync.\n\nSó o que difere é alterado, e aplicar o mesmo manifesto de novo não altera nada. Com --prune, as\nentradas que o manifesto não declara são apagadas (exige --force); -n só mostra o que seria feito.\nArquivos protegidos só são regravados com --override-protection.",
+			Examples:   []string{"furgfs -i site.fs2 apply site.yaml", "furgfs apply -n --prune --force volume.json"},
+			Standalone: cliApply,
+		},
+		{
+			Name:       "superblock",
+			Usage:      "[show] [--json]\nset <campo> <valor>",
+			Summary:    "mostra os campos do cabeçalho e verifica seu checksum\naltera um campo do cabeçalho e recalcula o checksum (exige --force)",
+			Examples:   []string{"furgfs superblock", "furgfs superblock set FreeSpace 1048576 --force"},
+			Standalone: cliSuperblock,
+		},
+		{
+			Name:       "verify-image",
+			Usage:      "[--json] [imagem]",
+			Summary:    "relatório de integridade somente leitura: cabeçalho, cadeias da FAT, checksums e espaço livre",
+			Details:    "A imagem é aberta apenas para leitura; para reparar os problemas encontrados, use fsck.\nTermina com o código 6 quando a imagem tem problemas.",
+			Examples:   []string{"furgfs verify-image", "furgfs verify-image --json backup.fs2"},
+			Standalone: cliVerifyImage,
+		},
+		{
+			Name:       "selftest",
+			Usage:      "[--keep]",
+			Summary:    "cria uma imagem temporária e testa as operações básicas, informando o resultado de cada etapa",
+			Details:    "Útil após alterações no programa ou em uma plataforma nova. A imagem é removida ao final, a menos\nque --keep seja usado.",
+			Examples:   []string{"furgfs selftest", "furgfs selftest --keep"},
+			Standalone: cliSelftest,
+		},
+		{
+			Name:       "bench",
+			Usage:      "[--files <n>] [--dirs <n>] [--max-size <tamanho>] [--block-size <bytes>] [--seed <n>] [--benchtime <duração>]\n[--save <arquivo>] [--baseline <arquivo> [--tolerance <porcento>]] [Import|Lookup|Save...]",
+			Summary:    "mede a importação, a busca por caminho e o salvamento do estado sobre uma carga sintética",
+			Details:    "Os benchmarks Import, Lookup e Save rodam sobre imagens temporárias, com uma carga gerada a partir de\n--seed: a maioria dos arquivos cabe em um bloco e um quarto deles tem até --max-size bytes. A mesma carga\nmede o mesmo trabalho em outra versão do programa. Com a carga padrão, os mesmos benchmarks rodam com\ngo test -bench .\n\n--save grava os resultados em JSON; --baseline compara com um arquivo gravado antes e termina com erro se\nalgum benchmark ficou mais de --tolerance por cento (padrão 10) mais lento, o que permite medir uma\nalteração no alocador ou no cache antes de aceitá-la.",
+			Examples:   []string{"furgfs bench", "furgfs bench --files 5000 --save base.json", "furgfs bench --baseline base.json Import"},
+			Standalone: cliBench,
+		},
+		{
+			Name:       "mount",
+			Usage:      "[--allow-other] [--debug] [--archives] [--commit-delay <duração>] [imagem] <ponto-de-montagem>",
+			Summary:    "monta o volume com FUSE, para ser usado por qualquer programa, até ser desmontado",
+			Details:    "O comando fica em execução atendendo o sistema; para desmontar, use umount (ou fusermount -u) no\nponto de montagem ou pressione Ctrl+C. Como o FURGfs2 só grava arquivos inteiros, um arquivo aberto\npara escrita é mantido em memória e gravado por completo ao ser fechado. Retirar a permissão de\nescrita (chmod a-w) protege o arquivo. Disponível apenas no Linux e no macOS.\n\nCom --archives, os arquivos .zip, .tar, .tar.gz e .tgz aparecem como diretórios somente leitura com o\nseu conteúdo, lido direto da imagem sem extrair o arquivo. Nesse modo, o próprio arquivo também não pode\nser alterado, movido nem apagado pelo ponto de montagem.\n\nCom --commit-delay <duração>, as alterações são gravadas em lotes, como em serve; um fsync grava o\nlote em aberto na hora.",
+			Examples:   []string{"furgfs mount /mnt/furg", "furgfs mount --allow-other backup.fs2 /mnt/backup", "furgfs mount --archives /mnt/furg"},
+			Standalone: cliMount,
+		},
+		{
+			Name:       "docker-plugin",
+			Usage:      "[--socket <caminho>] [--root <diretório>]",
+			Summary:    "atende o Docker como plugin de volumes, guardando cada volume em uma imagem montada com FUSE",
+			Details:    "O comando fica em execução, em geral como root, até receber Ctrl+C ou SIGTERM, quando desmonta os\nvolumes em uso. O Docker encontra o plugin pelo socket /run/docker/plugins/furgfs.sock (veja --socket), e os\nvolumes são criados com \"docker volume create -d furgfs\". Cada volume é a imagem <nome>.fs2 no diretório\n/var/lib/furgfs/volumes (veja --root), montada em mounts/<nome> enquanto algum contêiner a usa.\n\nAs opções size (padrão 64M), block-size, entries (padrão 1000) e label de \"docker volume create -o\"\nvalem para a imagem criada, como em mkfs; -o image=<arquivo> usa uma imagem existente, que não é\napagada com o volume. Disponível apenas no Linux e no macOS.",
+			Examples:   []string{"furgfs docker-plugin", "docker volume create -d furgfs -o size=256M dados", "docker run -v dados:/dados alpine ls /dados"},
+			Standalone: cliDockerPlugin,
+		},
+		{
+			Name:       "serve",
+			Usage:      "webdav [--addr :8080] [imagem]\nsftp [--addr :2022] [--host-key <arquivo>] [imagem]\nhttp [--addr :8000] [imagem]\napi [--addr :8081] [imagem]\ngrpc [--addr :9090] [imagem]\nnfs [--addr :2049] [--read-only] [imagem]\nsmb [--addr :445] [--share <nome>] [imagem]\ns3 [--addr :9000] [imagem]\nnbd [--addr :10809] [--read-only] [imagem]",
+			Summary:    "compartilha o volume por WebDAV, para ser montado pelo Finder, Explorer ou gvfs sem instalar nada\ncompartilha o volume por SFTP, para os clientes sftp e scp, com os usuários cadastrados em user\nserve os arquivos por HTTP, somente para leitura, com páginas de índice dos diretórios\noferece uma API REST em JSON para listar, enviar, baixar, criar, apagar e renomear\noferece um serviço gRPC, com envio e download em fluxo e um cliente Go gerado\nexporta o volume por NFSv3, para ser montado pelo Linux e pelo macOS\ncompartilha o volume por SMB2, em caráter experimental, para ser mapeado como unidade de rede no Windows\noferece uma API compatível com o S3, para os SDKs da AWS e ferramentas como mc e rclone\nexporta a imagem inteira como um dispositivo de blocos por NBD, para ferramentas de outra máquina",
+			Details:    "O comando fica em execução até ser interrompido com Ctrl+C. Os clientes acessam o volume ao mesmo\ntempo, e cada alteração é gravada na imagem assim que termina; como o FURGfs2 só grava arquivos\ninteiros, um envio substitui o arquivo completo. O WebDAV não tem autenticação: use um endereço local ou uma\nrede confiável.\n\nCom --commit-delay <duração> (por exemplo, 200ms), as alterações feitas nesse intervalo são gravadas\njuntas: o diário e os metadados de uma rajada de operações vão para a imagem de uma vez ao fim do\nintervalo. Uma queda do processo perde, no máximo, as alterações do último intervalo; ao encerrar, o lote\nem aberto é gravado.\n\nO SFTP aceita os usuários cadastrados com \"furgfs user\", por senha ou chave pública; usuários\ncriados com --read-only só podem ler. A chave do servidor é gerada na primeira vez e guardada em\n/.furgfs/ssh_host_key; --host-key usa uma chave do OpenSSH em vez dela.\n\nO HTTP serve o index.html de um diretório ou, sem ele, a lista de arquivos. O FURGfs2 não guarda o\ntipo dos arquivos: o Content-Type vem da extensão do nome ou, sem ela, do início do conteúdo.\n\nA API REST fica em /api/v1 e é descrita pela especificação OpenAPI em /api/v1/openapi.json; ela\naceita, por HTTP Basic, os mesmos usuários do SFTP.\n\nO gRPC segue o serviço definido em furgfspb/furgfs.proto; o pacote FURGFS2/furgfspb traz também o\ncliente Go gerado. As chamadas levam os mesmos usuários no metadado authorization, em HTTP Basic (veja\nfurgfspb.BasicAuth).\n\nPara arquivos grandes, a API REST (POST /api/v1/uploads) e o gRPC (StartUpload, SendChunks e\nCommitUpload) aceitam envios retomáveis: os pedaços, cada um com a sua posição e, opcionalmente, o seu\nSHA-256, ficam em <imagem>.uploads até o arquivo estar completo, e um envio interrompido continua do\núltimo byte recebido. Os downloads continuam de um offset, e cada pedaço vem com o seu SHA-256.\n\nO NFS segue a versão 3 do protocolo, só por TCP e sem portmapper: o cliente informa a porta ao\nmontar, como em \"mount -t nfs -o vers=3,proto=tcp,port=2049,mountport=2049,nolock host:/ /mnt\" no\nLinux. Como no NFS tradicional, não há senha; use --read-only ou uma rede confiável. O conteúdo escrito\né gravado quando o cliente fecha o arquivo.\n\nO SMB é experimental e segue os dialetos 2.0.2 e 2.1, sem oplocks nem avisos de alteração. O volume\naparece como o compartilhamento furgfs (veja --share) e é mapeado no Windows com \"net use Z: \\\\host\\furgfs\";\no Windows só usa a porta 445, que no Linux exige privilégios. Entram, por NTLMv2, apenas os usuários\ncadastrados com \"furgfs user add --smb\", e as mensagens são assinadas quando o cliente pede.\n\nO S3 usa endereços por caminho (http://host:9000/balde/chave): cada diretório da raiz é um balde e\nas chaves são os caminhos dos arquivos dentro dele, criando os diretórios intermediários ao enviar. Os\npedidos são assinados com AWS Signature Version 4, em qualquer região, com as chaves criadas por \"furgfs\nuser s3key\". Há listagem, envio, download com Range, cópia e remoção de objetos e baldes; envios\nmultipart, versões e ACLs não são aceitos, e a ETag é sempre o MD5 do conteúdo.\n\nO NBD exporta o arquivo da imagem, e não os arquivos do volume, com o nome do arquivo como nome da\nexportação: em outra máquina, \"nbd-client host 10809 /dev/nbd0 -N n.fs2\" cria um dispositivo que pode ser\naberto com \"furgfs -i /dev/nbd0\" ou examinado por ferramentas de blocos. As escritas vão direto para a\nimagem, sem nenhuma verificação; use --read-only para só inspecioná-la e não use a mesma imagem por\noutro meio enquanto o dispositivo estiver em uso.\n\nCom --archives, os arquivos .zip, .tar, .tar.gz e .tgz aparecem como diretórios somente leitura com o\nseu conteúdo (/arquivos/dados.zip/docs/a.txt), lido direto da imagem sem extrair o arquivo; os membros\nde um tar sem compressão são lidos em qualquer posição, e os de um zip ou tar.gz, do início. Nesse modo,\no próprio arquivo também não pode ser alterado, movido nem apagado pelo servidor.\n\nEm todos os protocolos, exceto o NBD, o diretório de sistema /.furgfs fica escondido.",
+			Examples:   []string{"furgfs serve webdav", "furgfs serve webdav --addr 127.0.0.1:9000 backup.fs2", "furgfs serve sftp --addr :2222", "furgfs serve http --addr 127.0.0.1:8000 site.fs2", "furgfs serve api --addr 127.0.0.1:8081", "furgfs serve grpc --addr 127.0.0.1:9090", "furgfs serve nfs --read-only", "furgfs serve smb --share dados", "furgfs serve s3 --addr 127.0.0.1:9000", "furgfs serve nbd --read-only", "furgfs serve http --archives"},
+			Standalone: cliServe,
+		},
+		{
+			Name:       "daemon",
+			Usage:      "[--socket <caminho>] [imagem]",
+			Summary:    "mantém a imagem aberta e executa os comandos dos outros furgfs, um de cada vez",
+			Details:    "O daemon também é iniciado pelo mesmo executável com o nome furgfsd. Enquanto ele estiver em execução,\nos comandos do furgfs sobre a imagem são enviados a ele por um socket Unix, com o diretório atual, as\nvariáveis FURGFS_* e AWS_* e a entrada padrão, quando ela não é um terminal; assim vários processos usam\na imagem ao mesmo tempo sem que um desfaça as alterações do outro. A FAT e o diretório ficam na memória\nentre os comandos, e a senha de um volume cifrado só é pedida uma vez.\n\nA imagem fica travada: outros processos que a abrem diretamente, como serve, mount, shell e o menu\ninterativo, são recusados enquanto o daemon estiver em execução, e o daemon não inicia sobre uma imagem\nem uso. O socket fica em um diretório temporário do usuário, derivado do caminho da imagem; --socket e a\nvariável FURGFS_SOCKET, que os clientes também consultam, escolhem outro.",
+			Examples:   []string{"furgfs daemon dados.fs2", "furgfsd --socket /run/furgfs.sock"},
+			Standalone: cliDaemon,
+		},
+		{
+			Name:     "debugfs",
+			Usage:    "<header|fat|chain|owner|dump> ...",
+			Summary:  "inspeciona o cabeçalho, a FAT e os blocos sem alterar a imagem",
+			Details:  "Subcomandos: header; fat <início> [fim]; chain <bloco>; owner <bloco>; dump <bloco>.",
+			Examples: []string{"furgfs debugfs fat 0 20", "furgfs debugfs owner 98"},
+			Run:      cliDebugfs,
+		},
+		{
+			Name:    "layout",
+			Summary: "relatório de fragmentação e mapa de blocos",
+			Run: func(fs *FURGFileSystem, args []string) error {
+				return fs.FragmentationReport()
+			},
+		},
+		{
+			Name:     "fsck",
+			Usage:    "[-y|-n]",
+			Summary:  "verifica a consistência; -y repara sem perguntar, -n só verifica",
+			Examples: []string{"furgfs fsck -n", "furgfs fsck -y"},
+			Run:      cliFsck,
+		},
+		{
+			Name:     "gc",
+			Usage:    "[-n]",
+			Summary:  "libera blocos usados que nenhum arquivo referencia; -n só informa",
+			Examples: []string{"furgfs gc -n", "furgfs gc --force"},
+			Run:      cliGC,
+		},
+		{
+			Name:    "compact",
+			Summary: "move os blocos para o início e reduz a imagem ao tamanho mínimo",
+			Run: func(fs *FURGFileSystem, args []string) error {
+				return fs.Compact()
+			},
+		},
+		{
+			Name:    "repack",
+			Summary: "remove as lacunas da tabela do diretório",
+			Run: func(fs *FURGFileSystem, args []string) error {
+				if fs.RepackDirectory() == 0 {
+					return nil
+				}
+				return fs.saveFileSystemState()
+			},
+		},
+		{
+			Name:     "undelete",
+			Usage:    "[<número> <caminho-interno>]",
+			Summary:  "lista arquivos removidos recuperáveis ou recupera o de número indicado",
+			Examples: []string{"furgfs undelete", "furgfs undelete 1 /recuperado.txt"},
+			Run:      cliUndelete,
+		},
+		{
+			Name:     "snapshot",
+			Usage:    "create <nome>\nlist [--json]\nrestore <nome>\ndelete <nome>",
+			Summary:  "registra o estado atual do volume com um nome\nlista os snapshots com a data de criação\nsubstitui o conteúdo do volume pelo do snapshot (exige --force)\napaga o snapshot e libera os blocos que só ele usava (exige --force)",
+			Details:  "Os snapshots ficam em /.furgfs e retêm os blocos dos arquivos: remover ou alterar um arquivo não\nlibera o espaço que ele ocupava enquanto algum snapshot o usar. compact é recusado enquanto houver\nsnapshots, e clone não os copia.",
+			Examples: []string{"furgfs snapshot create antes-da-limpeza", "furgfs snapshot restore antes-da-limpeza --force"},
+			Run:      cliSnapshot,
+		},
+		{
+			Name:     "backup",
+			Usage:    "[--since <snapshot>] [--snapshot <nome>] <arquivo>",
+			Summary:  "grava um backup completo ou, com --since, só dos arquivos alterados desde um snapshot",
+			Details:  "O backup é um arquivo tar.gz no sistema real, com a lista das entradas do volume e o conteúdo dos\narquivos incluídos. --snapshot cria, com o mesmo estado, o snapshot que servirá de --since para o\npróximo backup incremental.",
+			Examples: []string{"furgfs backup --snapshot seg completo.tgz", "furgfs backup --since seg --snapshot ter ter.tgz"},
+			Run:      cliBackup,
+		},
+		{
+			Name:     "restore",
+			Usage:    "<backup> [incremental...]",
+			Summary:  "substitui o conteúdo do volume pelo de uma cadeia de backups (exige --force)",
+			Details:  "A cadeia começa por um backup completo, seguido dos incrementais na ordem em que foram gravados;\ncada incremental deve partir do snapshot criado pelo anterior. O diretório de sistema /.furgfs não\né alterado. Para reconstruir um volume perdido, crie uma imagem com mkfs e restaure nela.",
+			Examples: []string{"furgfs restore completo.tgz ter.tgz --force", "furgfs mkfs novo.fs2 && furgfs --image novo.fs2 restore completo.tgz --force"},
+			Run:      cliRestore,
+		},
+		{
+			Name:     "replica",
+			Usage:    "set <destino>\nsync\nstatus [--json]\nremove",
+			Summary:  "configura o espelho, uma cópia da imagem atualizada a cada alteração, e copia a imagem para ele\ncopia de novo a imagem inteira para o espelho, depois de uma falha\nmostra o espelho e se ele está em dia\ndeixa de replicar a imagem, sem apagar o espelho",
+			Details:  "Depois de \"replica set\", cada comando que altera a imagem envia ao espelho, em segundo plano, os trechos\nalterados, com os blocos de dados antes dos metadados; o comando só termina quando o envio acaba. Os\nservidores de \"serve\" replicam cada alteração assim que ela é salva. O destino fica em\n/.furgfs/replica.json e pode ser um arquivo local ou uma imagem s3:// (veja \"furgfs help\").\n\nSe um envio falhar, a replicação para até o fim do comando e o espelho fica desatualizado: \"replica\nstatus\" compara os metadados das duas imagens e \"replica sync\" volta a copiar a imagem inteira. O\nespelho é uma imagem comum e pode ser aberta com --image no lugar da original.",
+			Examples: []string{"furgfs replica set /mnt/backup/espelho.fs2", "furgfs replica set s3://copias/furg.fs2", "furgfs replica status"},
+			Run:      cliReplica,
+		},
+		{
+			Name:     "audit",
+			Usage:    "enable [--file <arquivo>]\ndisable\nlist [--since <data>] [--user <nome>] [--path <caminho>] [--failed] [--json]",
+			Summary:  "passa a registrar as operações que alteram o volume, na imagem ou em um arquivo externo\ndeixa de registrar as operações, mantendo os registros\nmostra os registros, filtrados por data, usuário, caminho ou falha",
+			Details:  "Cada registro traz a data, o usuário, a origem (cli, menu, tui, mount ou o protocolo de \"serve\"), a\noperação, o caminho ou os argumentos do comando e o resultado. Localmente, o usuário é o do sistema\noperacional; nos servidores, o usuário autenticado. As consultas não são registradas, apenas os\ncomandos que alteraram a imagem ou falharam.\n\nSem --file, os registros ficam em segmentos de 64 KiB em /.furgfs/audit-NNNNNN.jsonl, protegidos e\nnunca reescritos depois de cheios; com --file, são acrescentados ao arquivo indicado, uma linha JSON\npor operação. --since aceita uma duração (24h) ou uma data (2026-01-31 ou 2026-01-31T08:00:00).",
+			Examples: []string{"furgfs audit enable", "furgfs audit enable --file /var/log/furgfs-audit.jsonl", "furgfs audit list --user ana --since 24h"},
+			Run:      cliAudit,
+		},
+		{
+			Name:     "webhook",
+			Usage:    "add [--events create,modify,delete] [--secret <chave>] <url>\nlist [--json]\nremove <url>\ntest <url>",
+			Summary:  "cadastra uma URL avisada das alterações feitas pelos servidores e pelo ponto de montagem\nlista os webhooks e os eventos de cada um\nremove um webhook\nenvia um evento de teste e mostra se ele foi aceito",
+			Details:  "Enquanto \"serve\" ou \"mount\" estiver em execução, cada arquivo ou diretório criado, alterado ou\napagado gera um POST com um JSON como {\"event\": \"create\", \"path\": \"/entrada/a.txt\", \"type\":\n\"file\", \"size\": 120, \"user\": \"ana\", \"source\": \"sftp\", \"volume\": \"furg.fs2\", \"time\": ...}; o\ncabeçalho X-Furgfs-Event traz o evento. Uma renomeação gera delete do caminho antigo e create do novo.\nOs comandos da linha de comando não geram eventos.\n\nOs eventos são enviados em segundo plano, na ordem em que ocorreram; falhas de rede e respostas 5xx\nsão repetidas até 4 vezes. Com --secret, X-Furgfs-Signature traz \"sha256=\" e o HMAC-SHA256 do corpo\nem hexadecimal. Os webhooks ficam em /.furgfs/webhooks.json; cadastrar de novo uma URL troca seus\neventos e sua chave.",
+			Examples: []string{"furgfs webhook add https://ci.exemplo.com/furgfs", "furgfs webhook add --events create --secret s3gr3d0 http://localhost:9000/novos", "furgfs webhook test https://ci.exemplo.com/furgfs"},
+			Run:      cliWebhook,
+		},
+		{
+			Name:     "journal",
+			Usage:    "enable\ndisable\nstatus [--json]\nsince [--json] <sequência>",
+			Summary:  "passa a registrar as alterações das entradas, com números de sequência crescentes\napaga o diário (exige --force)\nmostra o identificador do diário e as sequências disponíveis\nlista as alterações com sequência maior que a indicada",
+			Details:  "A cada estado salvo, seja por um comando, pelo menu ou por um servidor, o diário registra as entradas\ncriadas, alteradas (conteúdo, tamanho ou proteção) e apagadas desde o estado anterior. Uma entrada\nmovida aparece como delete do caminho antigo e create do novo; as remoções vêm antes, dos filhos para\nos pais, e as criações depois, dos pais para os filhos.\n\nUma ferramenta de sincronização guarda o last_seq de \"journal since --json\" e o usa na consulta\nseguinte. Os registros ficam em /.furgfs/journal-*.jsonl e só os 16 segmentos mais recentes, de até\n64 KiB, são mantidos: uma sequência mais antiga que a primeira disponível termina com o código 3, e o\nidentificador muda quando o diário é recriado; nos dois casos, faça uma sincronização completa.",
+			Examples: []string{"furgfs journal enable", "furgfs journal since --json 0", "furgfs journal status"},
+			Run:      cliJournal,
+		},
+		{
+			Name:     "index",
+			Usage:    "enable\ndisable\nrebuild\nstatus [--json]",
+			Summary:  "cria o índice de metadados ao lado da imagem, com os caminhos e os hashes do conteúdo\napaga o índice\nrefaz o índice lendo todos os arquivos\nmostra o arquivo do índice e o que ele contém",
+			Details:  "O índice é um banco bbolt em <imagem>.idx, atualizado a cada estado salvo, com a posição de cada\nentrada, o primeiro bloco e o SHA-256 do conteúdo dos arquivos, além dos registros da auditoria pelo\ncaminho. Com ele, find --hash e sync --checksum não leem o conteúdo dos arquivos da imagem, e\naudit list --path lê só os registros do caminho.\n\nO índice é opcional e pode ser apagado a qualquer momento. Se ele não corresponder à imagem, porque\numa gravação foi interrompida ou a imagem foi alterada sem ele, é refeito ao abri-la. Imagens\nremotas não têm índice.",
+			Examples: []string{"furgfs index enable", "furgfs index status --json"},
+			Run:      cliIndex,
+		},
+		{
+			Name:     "crypt",
+			Usage:    "status\nenable\ndisable\nchange-password [--rekey]",
+			Summary:  "informa se o conteúdo do volume é cifrado\ncifra no lugar o conteúdo de todos os arquivos (exige --force)\ndecifra no lugar o conteúdo de todos os arquivos (exige --force)\ntroca a senha; com --rekey, recifra o conteúdo com uma chave nova (exige --force)",
+			Details:  "O conteúdo dos arquivos é cifrado com AES-256-XTS; nomes, tamanhos e o diretório de sistema /.furgfs\ncontinuam legíveis. A senha é lida de FURGFS_PASSWORD (e a nova, de FURGFS_NEW_PASSWORD) ou pedida\nno terminal ao abrir o primeiro arquivo. Trocar a senha sem --rekey regrava apenas a chave protegida.\n\nA cifra é aplicada bloco a bloco, com o andamento exibido na saída de erro; se a operação for\ninterrompida, o volume ficará ilegível. Os snapshots precisam ser apagados antes.",
+			Examples: []string{"furgfs crypt enable --force", "FURGFS_PASSWORD=segredo furgfs get /doc.txt doc.txt"},
+			Run:      cliCrypt,
+		},
+		{
+			Name:     "script",
+			Usage:    "<arquivo|->",
+			Summary:  "executa os comandos de um arquivo de script (um por linha ou separados por ;)",
+			Details:  "Linhas iniciadas por # são comentários e argumentos com espaços podem ficar entre aspas.\nComandos ligados por '|' formam um pipeline: a saída de cada um é a entrada do seguinte.\nA mesma sintaxe vale para -c e shell.",
+			Examples: []string{"furgfs script backup.txt", "furgfs -c 'mkdir /a; put x.txt /a/'"},
+			Run:      cliScript,
+		},
+		{
+			Name:     "shell",
+			Summary:  "abre um interpretador de comandos interativo, com pipelines entre os comandos",
+			Details:  "Cada linha segue a sintaxe dos scripts, inclusive ';' e '|'; Tab completa comandos e caminhos e\n\"exit\" encerra. As ações destrutivas pedem confirmação, a menos que o comando traga --force.",
+			Examples: []string{"furgfs shell", "find *.log | rm", "cat notes.txt | grep TODO"},
+			Run:      cliShell,
+		},
+		{
+			Name:     "alias",
+			Usage:    "[nome]",
+			Summary:  "lista os aliases da configuração ou mostra o corpo de um deles",
+			Details:  "Um alias é definido no arquivo de configuração com \"alias.<nome> = comando; comando\", na sintaxe\ndos scripts, e é chamado como um comando: furgfs <nome> [argumentos]. $1 a $9 são trocados pelos\nargumentos e $@ por todos eles; sem marcadores, os argumentos vão para o fim do último comando.\n--force e --override-protection passados ao alias valem para todos os seus comandos.",
+			Examples: []string{"furgfs alias", "furgfs backupdocs --force"},
+			Run:      cliAlias,
+		},
+		{
+			Name:     "open",
+			Usage:    "<imagem> [nome]",
+			Summary:  "abre outra imagem na mesma sessão (útil em scripts)",
+			Examples: []string{"furgfs -c 'open backup.fs2 bkp; images'"},
+			Run:      cliOpen,
+		},
+		{
+			Name:    "use",
+			Usage:   "<nome>",
+			Summary: "troca a imagem sobre a qual os comandos seguintes operam",
+			Run:     cliUse,
+		},
+		{
+			Name:    "close",
+			Usage:   "<nome>",
+			Summary: "salva e fecha uma imagem aberta com open",
+			Run:     cliClose,
+		},
+		{
+			Name:    "images",
+			Summary: "lista as imagens abertas; a atual é marcada com *",
+			Run:     cliImages,
+		},
+		{
+			Name:     "cd",
+			Usage:    "<diretório>",
+			Summary:  "muda o diretório base dos caminhos relativos (útil em scripts)",
+			Details:  pathConventions,
+			Examples: []string{"furgfs -c 'cd /docs; ls; get relatorio.pdf -'"},
+			Run: func(fs *FURGFileSystem, args []string) error {
+				if len(args) != 1 {
+					return classErrorf(ErrUsage, "uso: furgfs cd <diretório>")
+				}
+				return fs.ChangeDirectory(args[0])
+			},
+		},
+		{
+			Name:    "pwd",
+			Summary: "mostra o diretório atual",
+			Run: func(fs *FURGFileSystem, args []string) error {
+				fmt.Println(fs.WorkingDir)
+				return nil
+			},
+		},
+		{
+			Name:    "tui",
+			Summary: "abre o gerenciador de arquivos em tela cheia (FURGfs2 e sistema real)",
+			Run: func(fs *FURGFileSystem, args []string) error {
+				err := fs.FileManager()
+				if err != nil {
+					return err
+				}
+				return fs.saveFileSystemState()
+			},
+		},
+		{
+			Name:     "help",
+			Usage:    "[comando|assunto]",
+			Summary:  "mostra esta ajuda, a ajuda de um comando ou de um assunto (caminhos, codigos)",
+			Examples: []string{"furgfs help put", "furgfs help caminhos"},
+			Run: func(fs *FURGFileSystem, args []string) error {
+				return printHelp(args)
+			},
+		},
+	}
+}
+
+// menuCommands retorna os comandos do registro oferecidos pelo menu interativo (todos, exceto os que operam
+// antes de a imagem ser carregada), na ordem do help. Assim, um comando novo aparece no menu sem outras alterações.
+func menuCommands() []*commandInfo {
+	var available []*commandInfo
+	for i := range commands {
+		if commands[i].Run != nil {
+			available = append(available, &commands[i])
+		}
+	}
+	return available
+}
+
+// readMenuCommand lista os comandos do registro numerados e lê, com prompt, a linha de comando a executar: o
+// número de um comando, seguido da leitura dos seus argumentos, ou diretamente o comando (ou alias) com os
+// argumentos. Retorna false quando o usuário escolhe 0 ou a entrada termina.
+func readMenuCommand(prompt string) (string, bool) {
+	available := menuCommands()
+	for k, command := range available {
+		summary, _, _ := strings.Cut(tr(command.Summary), "\n")
+		fmt.Printf("%3d. %-16s %s\n", k+1, command.Name, summary)
+	}
+	fmt.Println("  " + tr("0. Sair"))
+
+	var choice string
+	if err := console.Prompt(prompt, &choice); err == io.EOF {
+		return "", false // fim da entrada: salva e encerra
+	}
+	n, err := strconv.Atoi(choice)
+	if err != nil {
+		return choice, true
+	}
+	if n == 0 {
+		return "", false
+	}
+	if n < 1 || n > len(available) {
+		fmt.Println(tr("Opção inválida. Tente novamente."))
+		return "", true
+	}
+
+	command := available[n-1]
+	var args string
+	if command.Usage != "" {
+		usage := strings.ReplaceAll(trUsage(command.Usage), "\n", " | ")
+		console.Prompt(fmt.Sprintf("Argumentos (%s %s): ", command.Name, usage), &args)
+	}
+	return strings.TrimSpace(command.Name + " " + args), true
+}
+
+// findCommand retorna o comando name do registro, ou nil se não existir.
+func findCommand(name string) *commandInfo {
+	for i := range commands {
+		if commands[i].Name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// Coluna em que começam as descrições na lista de comandos.
+const usageColumn = 44
+
+// cliUsage monta o texto de uso da linha de comando a partir do registro de comandos.
+func cliUsage() string {
+	var b strings.Builder
+	b.WriteString(tr("uso: furgfs [--image <imagem>] [--lang pt|en] <comando> [argumentos]") + "\n")
+	b.WriteString(tr("       furgfs [--image <imagem>] -c \"comando; comando; ...\"") + "\n\n")
+	b.WriteString(tr("Comandos:") + "\n")
+	for _, command := range commands {
+		writeCommandSummary(&b, command)
+	}
+	for _, paragraph := range []string{
+		"Ações destrutivas (remoção, gc, compactação) exigem --force; alterar arquivos protegidos exige\ntambém --override-protection.",
+		"A imagem é escolhida por --image (ou -i), pela variável FURGFS_IMAGE ou pela chave \"image\" do arquivo\nde configuração (FURGFS_CONFIG ou ~/.config/furgfs/config), nessa ordem; o padrão é furg.fs2.\nLinhas \"volume.<nome> = <caminho>\" na configuração permitem usar <nome> no lugar do caminho.",
+		"A imagem também pode ser remota: http:// e https:// leem, somente para leitura, uma imagem publicada em\num servidor com suporte a Range, e s3://bucket/chave usa um objeto S3, com as credenciais de\nAWS_ACCESS_KEY_ID e AWS_SECRET_ACCESS_KEY, a região de AWS_REGION e, para serviços compatíveis, o\nendereço de FURGFS_S3_ENDPOINT. As páginas lidas ficam em um cache local (FURGFS_CACHE_DIR) e as\nescritas só são enviadas, com a imagem inteira, ao fim do comando.",
+		"--sync always|onclose|never, ou FURGFS_SYNC, escolhe quando a imagem é levada ao disco com fsync:\nalways a cada alteração, antes e depois dos metadados; onclose ao fim de cada comando que alterou a\nimagem e ao fechá-la; never (padrão) deixa a gravação com o sistema operacional. O fsync de um ponto\nde montagem é sempre atendido.",
+		"Com --mmap, ou FURGFS_MMAP=1, as imagens locais são mapeadas na memória: a FAT, o diretório e os blocos\nsão lidos e escritos como memória, e o sistema operacional cuida do cache (somente Linux e macOS).",
+		"As escritas de blocos de dados ficam em um cache em memória de até 8 MB e vão para a imagem em lotes\nsequenciais, sempre antes dos metadados; FURGFS_WRITE_CACHE muda o tamanho (por exemplo, 32M), e 0\ndesliga o cache.",
+		"Em mount e serve, FURGFS_COMMIT_DELAY (por exemplo, 200ms) tem o efeito de --commit-delay: as\nalterações feitas no intervalo são gravadas juntas, com uma só gravação dos metadados.",
+		"O log de eventos internos vai para a saída de erro padrão com o nível de --log-level quiet|normal|info|debug\n(-q e -v são atalhos para quiet e debug) ou, com --log-file <arquivo>, para o arquivo em JSON;\nas variáveis FURGFS_LOG_LEVEL e FURGFS_LOG_FILE têm o mesmo efeito. O padrão é normal (erros e avisos).",
+		"As operações (comando, carga e gravação do estado, alocação, leitura e escrita de blocos e busca no\ndiretório) geram spans do OpenTelemetry: OTEL_TRACES_EXPORTER=otlp, ou apenas OTEL_EXPORTER_OTLP_ENDPOINT,\nos envia por OTLP/HTTP, e console os escreve em JSON na saída de erro.",
+		"Com --color auto (padrão), diretórios, arquivos protegidos e erros são coloridos apenas quando a saída é\num terminal e NO_COLOR não está definida; --color always e --color never forçam ou desligam as cores.",
+		"O idioma é escolhido por --lang, pela variável FURGFS_LANG, pela chave \"lang\" da configuração ou\npor LC_ALL/LC_MESSAGES/LANG; o padrão é o português.",
+		helpTopics["codigos"],
+		"Use \"furgfs help <comando>\" para detalhes e exemplos, e \"furgfs help caminhos\" para as convenções de caminhos.\nSem argumentos, o programa abre o menu interativo.",
+	} {
+		b.WriteString("\n" + tr(paragraph) + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeCommandSummary escreve as formas de uso de command, com a descrição alinhada em usageColumn. A
+// forma i recebe a linha i da descrição, na mesma linha ou, quando a forma não cabe antes da coluna, na
+// linha seguinte; as linhas da descrição que sobram vão depois da última forma.
+func writeCommandSummary(b *strings.Builder, command commandInfo) {
+	summary := strings.Split(tr(command.Summary), "\n")
+	forms := strings.Split(trUsage(command.Usage), "\n")
+	for i, form := range forms {
+		line := "  " + strings.TrimSpace(command.Name+" "+form)
+		switch {
+		case i >= len(summary):
+			b.WriteString(line + "\n")
+		case len([]rune(line)) < usageColumn:
+			fmt.Fprintf(b, "%-*s%s\n", usageColumn, line, summary[i])
+		default:
+			fmt.Fprintf(b, "%s\n%*s%s\n", line, usageColumn, "", summary[i])
+		}
+	}
+	for _, text := range summary[min(len(forms), len(summary)):] {
+		fmt.Fprintf(b, "%*s%s\n", usageColumn, "", text)
+	}
+}
+
+// printHelp implementa "help [comando|assunto]".
+func printHelp(args []string) error {
+	if len(args) == 0 {
+		fmt.Println(cliUsage())
+		return nil
+	}
+	if len(args) != 1 {
+		return classErrorf(ErrUsage, "uso: furgfs help [comando|assunto]")
+	}
+
+	if text, ok := helpTopics[args[0]]; ok {
+		fmt.Println(tr(text))
+		return nil
+	}
+	command := findCommand(args[0])
+	if command == nil {
+		var topics []string
+		for topic := range helpTopics {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+		return classErrorf(ErrNotFound, "erro: comando ou assunto '%s' desconhecido; assuntos: %s", args[0], strings.Join(topics, ", "))
+	}
+
+	for _, form := range strings.Split(command.Usage, "\n") {
+		fmt.Println(tr("uso: furgfs " + strings.TrimSpace(command.Name+" "+form)))
+	}
+	fmt.Printf("\n%s\n", tr(command.Summary))
+	if command.Details != "" {
+		// Os parágrafos são traduzidos separadamente porque vários comandos repetem as convenções de caminhos
+		for _, paragraph := range strings.Split(command.Details, "\n\n") {
+			fmt.Printf("\n%s\n", tr(paragraph))
+		}
+	}
+	if len(command.Examples) > 0 {
+		fmt.Println("\n" + tr("Exemplos:"))
+		for _, example := range command.Examples {
+			fmt.Printf("  %s\n", example)
+		}
+	}
+	if command.Standalone != nil {
+		fmt.Println("\n" + tr("Este comando opera diretamente sobre o arquivo da imagem e não pode ser usado em scripts."))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCommandSummaryPairsForms confere, no texto do help, que cada forma de uso fica ao lado da sua linha
+// da descrição, ou logo acima dela quando a forma passa da coluna das descrições.
+func TestCommandSummaryPairsForms(t *testing.T) {
+	lines := strings.Split(cliUsage(), "\n")
+	find := func(text string) int {
+		for i, line := range lines {
+			if line == text || strings.HasPrefix(line, text+" ") {
+				return i
+			}
+		}
+		return -1
+	}
+	indent := strings.Repeat(" ", usageColumn)
+
+	for _, command := range commands {
+		summary := strings.Split(command.Summary, "\n")
+		for i, form := range strings.Split(command.Usage, "\n") {
+			if i >= len(summary) {
+				break
+			}
+			text := "  " + strings.TrimSpace(command.Name+" "+form)
+			k := find(text)
+			if k == -1 {
+				t.Errorf("%s: a forma %q não aparece no help", command.Name, text)
+				continue
+			}
+			sameLine := strings.TrimSpace(strings.TrimPrefix(lines[k], text)) == summary[i]
+			nextLine := k+1 < len(lines) && lines[k+1] == indent+summary[i]
+			if !sameLine && !nextLine {
+				t.Errorf("%s: a forma %q não está ao lado da descrição %q", command.Name, text, summary[i])
+			}
+		}
+	}
+}
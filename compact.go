@@ -0,0 +1,150 @@
+package main
+
+import "fmt"
+
+// Compact move todos os blocos em uso para o início da região de dados, na ordem das cadeias de cada arquivo,
+// reduz o arquivo da imagem ao tamanho mínimo necessário e reescreve o cabeçalho. O volume resultante não tem
+// blocos livres; para voltar a ter espaço, use clone com um tamanho maior. Como os snapshots registram os números
+// dos blocos, a compactação é recusada enquanto houver algum, assim como no armazenamento por conteúdo, em que
+// os manifestos registram os blocos de dados.
+func (fs *FURGFileSystem) Compact() error {
+	if err := fs.checkNotCAS("compact"); err != nil {
+		return err
+	}
+	if len(fs.snapshotBlocks()) > 0 {
+		return classErrorf(ErrUsage, "erro: a imagem tem snapshots; apague-os antes de compactar (veja snapshot list)")
+	}
+	orphans, err := fs.orphanBlocks()
+	if err != nil {
+		return err
+	}
+	if len(orphans) > 0 {
+		return classErrorf(ErrCorrupted, "erro: existem %d bloco(s) órfão(s), execute o fsck ou o gc antes de compactar", len(orphans))
+	}
+	err = fs.confirm("A imagem será compactada e os arquivos removidos não poderão mais ser recuperados.")
+	if err != nil {
+		return err
+	}
+
+	// order[i] é o bloco atual cujo conteúdo deve terminar na posição i
+	var order []uint32
+	var files []int
+	var chains [][]uint32
+	// Em um volume cifrado, o conteúdo depende da posição do bloco: os blocos em sealed são decifrados
+	// na posição de origem e cifrados de novo na de destino
+	sealed := make(map[uint32]bool)
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 || entry.IsDirectory {
+			continue
+		}
+		blocks, err := fs.fileBlocks(entry)
+		if err != nil {
+			return err
+		}
+		c, err := fs.dataCipher(entry)
+		if err != nil {
+			return err
+		}
+		for _, blockID := range blocks {
+			sealed[blockID] = c != nil
+		}
+		files = append(files, i)
+		chains = append(chains, blocks)
+		order = append(order, blocks...)
+	}
+	c, err := fs.volumeCipher()
+	if err != nil {
+		return err
+	}
+	relocate := func(buf []byte, original, from, to uint32) {
+		if sealed[original] {
+			c.decryptBlock(buf, from)
+			c.encryptBlock(buf, to)
+		}
+	}
+
+	// where[b] é a posição atual do conteúdo que estava originalmente no bloco b, e at[p] o bloco original guardado em p
+	where := make(map[uint32]uint32, len(order))
+	at := make(map[uint32]uint32, len(order))
+	for _, blockID := range order {
+		where[blockID] = blockID
+		at[blockID] = blockID
+	}
+
+	bufA := make([]byte, fs.Header.BlockSize)
+	bufB := make([]byte, fs.Header.BlockSize)
+	moved := 0
+	for target, original := range order {
+		position := uint32(target)
+		source := where[original]
+		if source == position {
+			continue
+		}
+
+		_, err := fs.readBlock(source, bufA)
+		if err != nil {
+			return err
+		}
+		if occupant, ok := at[position]; ok {
+			// A posição de destino ainda guarda outro bloco: os dois trocam de lugar
+			_, err = fs.readBlock(position, bufB)
+			if err != nil {
+				return err
+			}
+			relocate(bufB, occupant, position, source)
+			err = fs.writeBlock(source, bufB)
+			if err != nil {
+				return err
+			}
+			where[occupant] = source
+			at[source] = occupant
+		} else {
+			delete(at, source)
+		}
+		relocate(bufA, original, source, position)
+		err = fs.writeBlock(position, bufA)
+		if err != nil {
+			return err
+		}
+		where[original] = position
+		at[position] = original
+		moved++
+	}
+
+	// Reconstrói a FAT com as cadeias contíguas a partir do bloco 0
+	usedBlocks := uint32(len(order))
+	fat := make([]FATEntry, usedBlocks)
+	next := uint32(0)
+	for k, blocks := range chains {
+		fs.RootDir[files[k]].FirstBlockID = next
+		for j := range blocks {
+			fat[next] = FATEntry{BlockID: next, Used: true}
+			if j < len(blocks)-1 {
+				fat[next].NextBlockID = next + 1
+			}
+			next++
+		}
+	}
+
+	oldSize := fs.Header.TotalSize
+	fs.FAT = fat
+	fs.fatLoaded, fs.rawMeta = nil, nil
+	fs.markAllMetadata()
+	fs.Header.TotalSize = fs.Header.DataStart + usedBlocks*fs.Header.BlockSize
+	fs.Header.FreeSpace = 0
+	// Os arquivos mudaram de bloco, mas não de conteúdo
+	fs.rebaseJournal()
+
+	err = fs.saveFileSystemState()
+	if err != nil {
+		return err
+	}
+	err = fs.FilePointer.Truncate(int64(fs.Header.TotalSize))
+	if err != nil {
+		return fmt.Errorf("erro ao reduzir o arquivo da imagem: %v", err)
+	}
+
+	fmt.Printf("Compactação concluída: %d bloco(s) movidos, imagem reduzida de %d para %d bytes.\n", moved, oldSize, fs.Header.TotalSize)
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// completePath completa o caminho interno em line usando a tabela do diretório. A última parte do texto
+// (após a última '/') é o prefixo procurado no diretório indicado pelo restante, absoluto ou relativo ao
+// diretório atual. Retorna as linhas completas candidatas; diretórios terminam com '/'.
+func (fs *FURGFileSystem) completePath(line string) []string {
+	dirPart, prefix := "", line
+	if i := strings.LastIndex(line, "/"); i >= 0 {
+		dirPart, prefix = line[:i+1], line[i+1:]
+	}
+
+	dir := fs.resolvePath(dirPart)
+	if dir != "/" && fs.CheckDirectoryExists(dir) == -1 {
+		return nil
+	}
+
+	var candidates []string
+	for _, i := range fs.entriesInDirectory(dir, false) {
+		entry := &fs.RootDir[i]
+		name := entry.NameString()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		candidate := dirPart + name
+		if entry.IsDirectory {
+			candidate += "/"
+		}
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// commonPrefix retorna o maior prefixo comum a todas as strings.
+func commonPrefix(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
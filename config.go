@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultImageName é a imagem usada quando nenhuma outra é configurada.
+const defaultImageName = "furg.fs2"
+
+// Config é o conteúdo do arquivo de configuração, no formato "chave = valor", uma por linha, com
+// comentários iniciados por '#':
+//
+//	image = /home/ana/furg.fs2
+//	lang = en
+//	volume.docs = /home/ana/volumes/docs.fs2
+//	alias.backupdocs = export-archive --format zip /docs docs.zip; stats
+//
+// "image" é a imagem padrão, "lang" o idioma das mensagens e cada "volume.<nome>" dá um nome curto a uma
+// imagem, que pode ser usado no lugar do caminho em --image e em FURGFS_IMAGE. Cada "alias.<nome>" define
+// um comando formado por uma sequência de comandos (veja alias.go).
+type Config struct {
+	Image   string
+	Lang    string
+	Volumes map[string]string
+	Aliases map[string]string
+}
+
+// configPath retorna o caminho do arquivo de configuração: FURGFS_CONFIG, se definida, ou
+// <diretório de configuração do usuário>/furgfs/config.
+func configPath() string {
+	if path := os.Getenv("FURGFS_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "furgfs", "config")
+}
+
+// loadConfig lê o arquivo de configuração. Um arquivo inexistente resulta em uma configuração vazia.
+func loadConfig(path string) (Config, error) {
+	config := Config{Volumes: make(map[string]string), Aliases: make(map[string]string)}
+	if path == "" {
+		return config, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("erro ao abrir a configuração: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case !ok || value == "":
+			return config, fmt.Errorf("erro em %s:%d: esperado 'chave = valor'", path, lineNumber)
+		case key == "image":
+			config.Image = value
+		case key == "lang":
+			config.Lang = value
+		case strings.HasPrefix(key, "volume.") && len(key) > len("volume."):
+			config.Volumes[strings.TrimPrefix(key, "volume.")] = value
+		case strings.HasPrefix(key, "alias.") && len(key) > len("alias."):
+			config.Aliases[strings.TrimPrefix(key, "alias.")] = value
+		default:
+			return config, fmt.Errorf("erro em %s:%d: chave '%s' desconhecida", path, lineNumber, key)
+		}
+	}
+	return config, scanner.Err()
+}
+
+// resolveImage escolhe a imagem a usar, nesta ordem: a opção --image, a variável FURGFS_IMAGE, a chave
+// "image" da configuração e, por fim, furg.fs2 no diretório atual. Nomes de volume da configuração
+// são trocados pelo caminho correspondente.
+func resolveImage(flagValue string) (string, error) {
+	config, err := loadConfig(configPath())
+	if err != nil {
+		return "", err
+	}
+
+	image := defaultImageName
+	for _, candidate := range []string{flagValue, os.Getenv("FURGFS_IMAGE"), config.Image} {
+		if candidate != "" {
+			image = candidate
+			break
+		}
+	}
+	if path, ok := config.Volumes[image]; ok {
+		image = path
+	}
+	return image, nil
+}
+
+// globalOptions são as opções que valem para qualquer comando e devem vir antes dele.
+type globalOptions struct {
+	Image    string // --image <caminho>, --image=<caminho> ou -i <caminho>
+	Lang     string // --lang <idioma> ou --lang=<idioma>
+	LogLevel string // --log-level <nível>; -v equivale a debug e -q a quiet
+	LogFile  string // --log-file <arquivo>
+	Color    string // --color auto|always|never
+	Mmap     bool   // --mmap
+	Sync     string // --sync always|onclose|never
+}
+
+// extractGlobalFlags remove de args as opções globais, que devem vir antes do comando, e retorna seus valores.
+func extractGlobalFlags(args []string) (globalOptions, []string, error) {
+	var options globalOptions
+	for len(args) > 0 {
+		name, value, hasValue := strings.Cut(args[0], "=")
+		var target *string
+		switch name {
+		case "--image", "-i":
+			target = &options.Image
+		case "--lang":
+			target = &options.Lang
+		case "--log-level":
+			target = &options.LogLevel
+		case "--log-file":
+			target = &options.LogFile
+		case "--color":
+			target = &options.Color
+		case "--sync":
+			target = &options.Sync
+		case "-v":
+			options.LogLevel, args = "debug", args[1:]
+			continue
+		case "-q":
+			options.LogLevel, args = "quiet", args[1:]
+			continue
+		case "--mmap":
+			options.Mmap, args = true, args[1:]
+			continue
+		default:
+			return options, args, nil
+		}
+		switch {
+		case hasValue:
+			*target, args = value, args[1:]
+		case len(args) < 2:
+			return options, nil, classErrorf(ErrUsage, "erro: %s exige um valor", args[0])
+		default:
+			*target, args = args[1], args[2:]
+		}
+	}
+	return options, args, nil
+}
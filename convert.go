@@ -0,0 +1,231 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"unsafe"
+)
+
+// isImageFile informa se name é uma imagem FURGfs2, isto é, se começa com um cabeçalho válido.
+func isImageFile(name string) bool {
+	f, err := os.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	h, _, err := readHeader(f)
+	return err == nil && len(headerProblems(h)) == 0
+}
+
+// archiveContents percorre o arquivo tar (opcionalmente com gzip) ou zip name e retorna o número de entradas
+// e o número de blocos de blockSize bytes que seus arquivos ocupariam.
+func archiveContents(name string, blockSize uint32) (entries, blocks uint64, err error) {
+	count := func(size int64, isDir bool) {
+		entries++
+		if !isDir {
+			blocks += (uint64(size) + uint64(blockSize) - 1) / uint64(blockSize)
+		}
+	}
+
+	if archiveFormatFromName(name) == archiveZip {
+		zr, err := zip.OpenReader(name)
+		if err != nil {
+			return 0, 0, fmt.Errorf("erro ao abrir o arquivo zip: %v", err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			count(int64(f.UncompressedSize64), f.FileInfo().IsDir())
+		}
+		return entries, blocks, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, 0, fmt.Errorf("erro ao abrir o arquivo: %w", err)
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	var source io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return 0, 0, fmt.Errorf("erro ao descompactar o arquivo gzip: %v", err)
+		}
+		defer gz.Close()
+		source = gz
+	}
+	tr := tar.NewReader(source)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return entries, blocks, nil
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("erro ao ler o arquivo tar: %v", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			count(0, true)
+		case tar.TypeReg:
+			count(header.Size, false)
+		}
+	}
+}
+
+// imageSizeFor calcula o menor tamanho total de uma imagem com a geometria informada que tenha pelo menos
+// blocks blocos de dados, seguindo as contas de createFileSystemImage.
+func imageSizeFor(blockSize, entries uint32, blocks uint64) (uint32, error) {
+	fixed := uint64(calculateHeaderSize()) + uint64(calculateRootDirSize(entries))
+	fatEntrySize := uint64(unsafe.Sizeof(FATEntry{}))
+	total := fixed + blocks*(uint64(blockSize)+fatEntrySize)
+	for {
+		fatSize := (total - fixed) / uint64(blockSize) * fatEntrySize
+		if (total-fixed-fatSize)/uint64(blockSize) >= blocks {
+			break
+		}
+		total += uint64(blockSize)
+	}
+	if total > math.MaxUint32 {
+		return 0, classErrorf(ErrNoSpace, "erro: o conteúdo precisa de %d bytes, mais que o máximo de uma imagem FURGfs2", total)
+	}
+	return uint32(total), nil
+}
+
+// ConvertArchiveToImage cria a imagem imageName com os diretórios e arquivos do tar ou zip archiveName.
+// Com totalSize ou entries iguais a zero, a geometria é calculada pelo conteúdo, com folga de 25%.
+func ConvertArchiveToImage(archiveName, imageName string, totalSize, blockSize, entries uint32, label string) (err error) {
+	needEntries, needBlocks, err := archiveContents(archiveName, blockSize)
+	if err != nil {
+		return err
+	}
+	needEntries += 2 // o diretório de sistema e os metadados do volume
+	if entries == 0 {
+		entries = uint32(max(100, needEntries+needEntries/4))
+	}
+	if totalSize == 0 {
+		// Um bloco a mais para os metadados do volume
+		totalSize, err = imageSizeFor(blockSize, entries, needBlocks+(needBlocks+3)/4+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	fs, err := createFileSystemImage(imageName, blockSize, totalSize, entries)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		fs.FilePointer.Close()
+		if err != nil {
+			os.Remove(imageName)
+		}
+	}()
+	if err = fs.initVolume(label); err != nil {
+		return err
+	}
+
+	if archiveFormatFromName(archiveName) == archiveZip {
+		err = fs.importExternalZip(archiveName, "/")
+	} else {
+		var f *os.File
+		f, err = os.Open(archiveName)
+		if err != nil {
+			return fmt.Errorf("erro ao abrir o arquivo: %w", err)
+		}
+		defer f.Close()
+		err = fs.ImportTar(f, "/")
+	}
+	if err != nil {
+		return err
+	}
+	if err = fs.saveFileSystemState(); err != nil {
+		return err
+	}
+	fmt.Printf(tr("Imagem '%s' criada: %d de %d blocos em uso, %d entradas no diretório.\n"),
+		imageName, len(fs.FAT)-int(fs.Header.FreeSpace/fs.Header.BlockSize), len(fs.FAT), len(fs.RootDir))
+	return nil
+}
+
+// cliConvert implementa "convert <imagem> <arquivo.tar|.tar.gz|.zip|->" e
+// "convert [--size 10M] [--block-size 4096] [--entries 100] [--label rótulo] <arquivo> <nova-imagem>".
+// O sentido da conversão é deduzido da origem: se ela for uma imagem FURGfs2, seu conteúdo é exportado.
+func cliConvert(imageName string, args []string) error {
+	args, policy := extractPolicyFlags(args)
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	sizeStr := flags.String("size", "", "tamanho total da nova imagem (padrão: calculado pelo conteúdo)")
+	blockSizeStr := flags.String("block-size", "4096", "tamanho de cada bloco de dados da nova imagem")
+	entries := flags.Uint("entries", 0, "número de entradas do diretório da nova imagem (padrão: calculado)")
+	label := flags.String("label", "", "rótulo da nova imagem")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs convert <imagem> <arquivo.tar|.tar.gz|.zip|-> | convert [opções] <arquivo> <nova-imagem>")
+	}
+	source, dest := flags.Arg(0), flags.Arg(1)
+
+	if isImageFile(source) {
+		fs, err := loadFileSystem(source)
+		if err != nil {
+			return err
+		}
+		defer fs.FilePointer.Close()
+		if dest == "-" {
+			return fs.ExportArchive("/", os.Stdout, archiveTar)
+		}
+		destFile, err := os.Create(dest)
+		if err != nil {
+			return fmt.Errorf("erro ao criar o arquivo no sistema real: %v", err)
+		}
+		if err = fs.ExportArchive("/", destFile, archiveFormatFromName(dest)); err == nil {
+			err = destFile.Close()
+		} else {
+			destFile.Close()
+		}
+		if err != nil {
+			os.Remove(dest)
+			return err
+		}
+		fmt.Printf(tr("Conteúdo de '%s' exportado para '%s'.\n"), source, dest)
+		return nil
+	}
+
+	if _, err := os.Stat(source); err != nil {
+		return classErrorf(ErrNotFound, "erro: o arquivo '%s' não existe", source)
+	}
+	var totalSize uint32
+	if *sizeStr != "" {
+		size, err := parseSize(*sizeStr)
+		if err != nil {
+			return err
+		}
+		totalSize = size
+	}
+	blockSize, err := parseSize(*blockSizeStr)
+	if err != nil {
+		return err
+	}
+	if *entries > math.MaxUint32 {
+		return fmt.Errorf("erro: número de entradas '%d' inválido", *entries)
+	}
+	if err := defaultValidationRules.ValidateName(*label); *label != "" && err != nil {
+		return fmt.Errorf("erro: rótulo '%s' inválido: %v", *label, errors.Unwrap(err))
+	}
+	if _, err := os.Stat(dest); err == nil {
+		if err := policy.Confirm(fmt.Sprintf(tr("A imagem '%s' já existe e será substituída."), dest)); err != nil {
+			return err
+		}
+		if err := os.Remove(dest); err != nil {
+			return fmt.Errorf("erro ao remover a imagem existente: %v", err)
+		}
+	}
+	return ConvertArchiveToImage(source, dest, totalSize, blockSize, uint32(*entries), *label)
+}
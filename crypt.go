@@ -0,0 +1,588 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Em um volume cifrado, o conteúdo dos arquivos é gravado com AES-256 no modo XTS, usando o número do bloco
+// como tweak, de modo que cada bloco pode ser lido e gravado de forma independente. Os nomes, tamanhos e a
+// FAT continuam legíveis, assim como os arquivos do diretório de sistema, onde /.furgfs/crypt.json guarda a
+// chave de dados cifrada com AES-GCM por uma chave derivada da senha (PBKDF2-SHA256). Trocar a senha regrava
+// apenas esse arquivo.
+const (
+	cryptInfoName    = "crypt.json"
+	cryptCipherName  = "aes-256-xts"
+	cryptKDFName     = "pbkdf2-sha256"
+	cryptIterations  = 600000
+	cryptDataKeySize = 64 // duas chaves AES-256: uma para os dados e outra para o tweak
+)
+
+// cryptParams é o conteúdo de /.furgfs/crypt.json.
+type cryptParams struct {
+	Cipher     string `json:"cipher"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Key        []byte `json:"key"` // chave de dados cifrada pela chave derivada da senha
+}
+
+// volumeCrypt guarda o estado da cifra do volume aberto.
+type volumeCrypt struct {
+	params *cryptParams // nil se o volume não é cifrado
+	key    []byte       // chave de dados; nil até a senha ser informada
+	cipher *xtsCipher
+}
+
+// xtsCipher cifra blocos de dados com AES-XTS (IEEE 1619), usando o número do bloco como tweak. O tamanho dos
+// blocos deve ser múltiplo de 16 bytes.
+type xtsCipher struct {
+	data, tweak cipher.Block
+}
+
+func newXTSCipher(key []byte) (*xtsCipher, error) {
+	data, err := aes.NewCipher(key[:len(key)/2])
+	if err != nil {
+		return nil, err
+	}
+	tweak, err := aes.NewCipher(key[len(key)/2:])
+	if err != nil {
+		return nil, err
+	}
+	return &xtsCipher{data: data, tweak: tweak}, nil
+}
+
+// crypt cifra (ou, com decrypt, decifra) src em dst, que podem ser o mesmo slice.
+func (c *xtsCipher) crypt(dst, src []byte, blockID uint32, decrypt bool) {
+	var tweak, buf [aes.BlockSize]byte
+	binary.LittleEndian.PutUint64(tweak[:8], uint64(blockID))
+	c.tweak.Encrypt(tweak[:], tweak[:])
+
+	for i := 0; i+aes.BlockSize <= len(src); i += aes.BlockSize {
+		for j := range buf {
+			buf[j] = src[i+j] ^ tweak[j]
+		}
+		if decrypt {
+			c.data.Decrypt(buf[:], buf[:])
+		} else {
+			c.data.Encrypt(buf[:], buf[:])
+		}
+		for j := range buf {
+			dst[i+j] = buf[j] ^ tweak[j]
+		}
+
+		// Multiplica o tweak por x em GF(2^128)
+		carry := tweak[15] >> 7
+		for j := 15; j > 0; j-- {
+			tweak[j] = tweak[j]<<1 | tweak[j-1]>>7
+		}
+		tweak[0] <<= 1
+		if carry != 0 {
+			tweak[0] ^= 0x87
+		}
+	}
+}
+
+func (c *xtsCipher) encryptBlock(buf []byte, blockID uint32) { c.crypt(buf, buf, blockID, false) }
+func (c *xtsCipher) decryptBlock(buf []byte, blockID uint32) { c.crypt(buf, buf, blockID, true) }
+
+// pbkdf2SHA256 deriva uma chave de keyLen bytes da senha, conforme o PBKDF2 (RFC 8018) com HMAC-SHA256.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// keyWrapper retorna o AES-GCM com a chave derivada de password e dos parâmetros de p.
+func (p *cryptParams) keyWrapper(password string) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2SHA256([]byte(password), p.Salt, p.Iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealKey cria os parâmetros que protegem dataKey com password, com sal e nonce novos.
+func sealKey(dataKey []byte, password string) (*cryptParams, error) {
+	p := &cryptParams{Cipher: cryptCipherName, KDF: cryptKDFName, Iterations: cryptIterations,
+		Salt: make([]byte, 16), Nonce: make([]byte, 12)}
+	if _, err := rand.Read(p.Salt); err != nil {
+		return nil, fmt.Errorf("erro ao gerar o sal: %v", err)
+	}
+	if _, err := rand.Read(p.Nonce); err != nil {
+		return nil, fmt.Errorf("erro ao gerar o nonce: %v", err)
+	}
+	aead, err := p.keyWrapper(password)
+	if err != nil {
+		return nil, err
+	}
+	p.Key = aead.Seal(nil, p.Nonce, dataKey, []byte(p.Cipher))
+	return p, nil
+}
+
+// openKey recupera a chave de dados com password.
+func (p *cryptParams) openKey(password string) ([]byte, error) {
+	if p.Cipher != cryptCipherName || p.KDF != cryptKDFName {
+		return nil, classErrorf(ErrCorrupted, "erro: cifra '%s' com derivação '%s' não suportada", p.Cipher, p.KDF)
+	}
+	aead, err := p.keyWrapper(password)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Nonce) != aead.NonceSize() {
+		return nil, classErrorf(ErrCorrupted, "erro: parâmetros de cifra inválidos em %s", joinPath(systemDir, cryptInfoName))
+	}
+	key, err := aead.Open(nil, p.Nonce, p.Key, []byte(p.Cipher))
+	if err != nil || len(key) != cryptDataKeySize {
+		return nil, classErrorf(ErrUsage, "erro: senha incorreta")
+	}
+	return key, nil
+}
+
+// cryptState lê, na primeira chamada, os parâmetros de cifra do volume.
+func (fs *FURGFileSystem) cryptState() (*volumeCrypt, error) {
+	if fs.crypt != nil {
+		return fs.crypt, nil
+	}
+	state := &volumeCrypt{}
+	data, err := fs.readSystemFile(cryptInfoName)
+	if err == nil {
+		state.params = &cryptParams{}
+		if err = json.Unmarshal(data, state.params); err != nil {
+			return nil, classErrorf(ErrCorrupted, "erro ao ler os parâmetros de cifra: %v", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	fs.crypt = state
+	return state, nil
+}
+
+// encrypted informa se o conteúdo dos arquivos do volume é cifrado.
+func (fs *FURGFileSystem) encrypted() (bool, error) {
+	state, err := fs.cryptState()
+	if err != nil {
+		return false, err
+	}
+	return state.params != nil, nil
+}
+
+// volumeCipher retorna a cifra do volume, pedindo a senha (ou lendo FURGFS_PASSWORD) no primeiro uso.
+// Retorna nil se o volume não é cifrado.
+func (fs *FURGFileSystem) volumeCipher() (*xtsCipher, error) {
+	state, err := fs.cryptState()
+	if err != nil {
+		return nil, err
+	}
+	if state.params == nil || state.cipher != nil {
+		return state.cipher, nil
+	}
+	password, err := readPassword("FURGFS_PASSWORD", "Senha do volume: ")
+	if err != nil {
+		return nil, err
+	}
+	key, err := state.params.openKey(password)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newXTSCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	state.key, state.cipher = key, c
+	return c, nil
+}
+
+// dataCipher retorna a cifra a usar no conteúdo de entry: nil para arquivos do diretório de sistema e em
+// volumes não cifrados.
+func (fs *FURGFileSystem) dataCipher(entry *FileEntry) (*xtsCipher, error) {
+	if entry.PathString() == systemDir {
+		return nil, nil
+	}
+	return fs.volumeCipher()
+}
+
+// readPassword lê uma senha da variável de ambiente envName ou, se ela não estiver definida, da entrada
+// padrão, sem eco quando ela é um terminal.
+func readPassword(envName, prompt string) (string, error) {
+	if password := os.Getenv(envName); password != "" {
+		return password, nil
+	}
+	fmt.Fprint(os.Stderr, tr(prompt))
+	defer fmt.Fprintln(os.Stderr)
+
+	restore, err := enableRawMode(console.fd)
+	if err != nil {
+		line, err := console.in.ReadString('\n')
+		if err != nil && line == "" {
+			return "", classErrorf(ErrUsage, "erro: senha não informada")
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	defer restore()
+	var password []rune
+	for {
+		r, _, err := console.in.ReadRune()
+		if err != nil {
+			return "", classErrorf(ErrUsage, "erro: senha não informada")
+		}
+		switch r {
+		case '\r', '\n':
+			return string(password), nil
+		case 0x7f, 0x08:
+			if len(password) > 0 {
+				password = password[:len(password)-1]
+			}
+		default:
+			password = append(password, r)
+		}
+	}
+}
+
+// newPassword lê uma nova senha de FURGFS_NEW_PASSWORD ou, pedindo duas vezes, da entrada padrão.
+func newPassword() (string, error) {
+	password, err := readPassword("FURGFS_NEW_PASSWORD", "Nova senha: ")
+	if err != nil || os.Getenv("FURGFS_NEW_PASSWORD") != "" {
+		return password, err
+	}
+	if password == "" {
+		return "", classErrorf(ErrUsage, "erro: a senha não pode ser vazia")
+	}
+	again, err := readPassword("FURGFS_NEW_PASSWORD", "Confirme a nova senha: ")
+	if err != nil {
+		return "", err
+	}
+	if again != password {
+		return "", classErrorf(ErrUsage, "erro: as senhas não conferem")
+	}
+	return password, nil
+}
+
+// progress exibe na saída de erro padrão, quando ela é um terminal, o andamento de uma operação longa.
+type progress struct {
+	label       string
+	total, done int
+	shown       int // último percentual exibido
+	tty         bool
+}
+
+func newProgress(label string, total int) *progress {
+	_, _, err := terminalSize(int(os.Stderr.Fd()))
+	return &progress{label: tr(label), total: total, shown: -1, tty: err == nil}
+}
+
+// step registra mais uma unidade concluída.
+func (p *progress) step() {
+	p.done++
+	percent := 100
+	if p.total > 0 {
+		percent = p.done * 100 / p.total
+	}
+	if p.tty && percent != p.shown {
+		fmt.Fprintf(os.Stderr, "\r%s: %d/%d (%d%%)", p.label, p.done, p.total, percent)
+		p.shown = percent
+	}
+}
+
+// finish encerra a linha de andamento.
+func (p *progress) finish() {
+	if p.tty && p.shown != -1 {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// contentBlocks retorna os blocos de todos os arquivos fora do diretório de sistema, isto é, os blocos cujo
+// conteúdo é cifrado em um volume cifrado, e o número desses arquivos. Os blocos compartilhados do
+// armazenamento por conteúdo aparecem uma vez só.
+func (fs *FURGFileSystem) contentBlocks() ([]uint32, int, error) {
+	var blocks []uint32
+	seen := make(map[uint32]bool)
+	files := 0
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 || entry.IsDirectory || entry.PathString() == systemDir {
+			continue
+		}
+		chain, err := fs.entryBlocks(entry)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, blockID := range chain {
+			if !seen[blockID] {
+				seen[blockID] = true
+				blocks = append(blocks, blockID)
+			}
+		}
+		files++
+	}
+	return blocks, files, nil
+}
+
+// recrypt regrava no lugar os blocos informados, decifrando com from e cifrando com to (qualquer um pode ser nil).
+func (fs *FURGFileSystem) recrypt(blocks []uint32, from, to *xtsCipher, label string) error {
+	buf := make([]byte, fs.Header.BlockSize)
+	bar := newProgress(label, len(blocks))
+	defer bar.finish()
+	for _, blockID := range blocks {
+		n, err := fs.readBlock(blockID, buf)
+		if err != nil {
+			return err
+		}
+		clear(buf[n:])
+		if from != nil {
+			from.decryptBlock(buf, blockID)
+		}
+		if to != nil {
+			to.encryptBlock(buf, blockID)
+		}
+		if err = fs.writeBlock(blockID, buf); err != nil {
+			return err
+		}
+		bar.step()
+	}
+	return nil
+}
+
+// checkRecrypt verifica se o conteúdo do volume pode ser regravado no lugar.
+func (fs *FURGFileSystem) checkRecrypt() error {
+	if fs.Header.BlockSize%aes.BlockSize != 0 {
+		return classErrorf(ErrUsage, "erro: a cifra exige blocos com tamanho múltiplo de %d bytes", aes.BlockSize)
+	}
+	if len(fs.snapshotBlocks()) > 0 {
+		return classErrorf(ErrUsage, "erro: a imagem tem snapshots; apague-os antes de alterar a cifra (veja snapshot list)")
+	}
+	return nil
+}
+
+// writeCryptParams grava os parâmetros de cifra em /.furgfs/crypt.json.
+func (fs *FURGFileSystem) writeCryptParams(params *cryptParams) error {
+	data, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fs.writeSystemFile(cryptInfoName, data)
+}
+
+// EnableEncryption cifra no lugar o conteúdo de todos os arquivos com uma chave nova, protegida pela senha
+// lida por newPassword.
+func (fs *FURGFileSystem) EnableEncryption() error {
+	if on, err := fs.encrypted(); err != nil || on {
+		if err == nil {
+			err = classErrorf(ErrUsage, "erro: o volume já é cifrado")
+		}
+		return err
+	}
+	if err := fs.checkRecrypt(); err != nil {
+		return err
+	}
+	blocks, files, err := fs.contentBlocks()
+	if err != nil {
+		return err
+	}
+	err = fs.confirm("O conteúdo de %d arquivo(s) será cifrado no lugar; se a operação for interrompida, o volume ficará ilegível.", files)
+	if err != nil {
+		return err
+	}
+	password, err := newPassword()
+	if err != nil {
+		return err
+	}
+
+	key := make([]byte, cryptDataKeySize)
+	if _, err = rand.Read(key); err != nil {
+		return fmt.Errorf("erro ao gerar a chave: %v", err)
+	}
+	params, err := sealKey(key, password)
+	if err != nil {
+		return err
+	}
+	c, err := newXTSCipher(key)
+	if err != nil {
+		return err
+	}
+	if err = fs.recrypt(blocks, nil, c, "Cifrando"); err != nil {
+		return err
+	}
+	if err = fs.writeCryptParams(params); err != nil {
+		return err
+	}
+	fs.crypt = &volumeCrypt{params: params, key: key, cipher: c}
+	fmt.Printf(tr("Volume cifrado: %d bloco(s) de %d arquivo(s).\n"), len(blocks), files)
+	return nil
+}
+
+// DisableEncryption decifra no lugar o conteúdo de todos os arquivos e apaga os parâmetros de cifra.
+func (fs *FURGFileSystem) DisableEncryption() error {
+	if on, err := fs.encrypted(); err != nil || !on {
+		if err == nil {
+			err = classErrorf(ErrUsage, "erro: o volume não é cifrado")
+		}
+		return err
+	}
+	if err := fs.checkRecrypt(); err != nil {
+		return err
+	}
+	c, err := fs.volumeCipher()
+	if err != nil {
+		return err
+	}
+	blocks, files, err := fs.contentBlocks()
+	if err != nil {
+		return err
+	}
+	err = fs.confirm("O conteúdo de %d arquivo(s) será gravado sem cifra; se a operação for interrompida, o volume ficará ilegível.", files)
+	if err != nil {
+		return err
+	}
+	if err = fs.recrypt(blocks, c, nil, "Decifrando"); err != nil {
+		return err
+	}
+	fs.discardEntries([]int{fs.lookupEntry(cryptInfoName, systemDir)})
+	fs.crypt = &volumeCrypt{}
+	fmt.Printf(tr("Cifra removida: %d bloco(s) de %d arquivo(s).\n"), len(blocks), files)
+	return nil
+}
+
+// ChangePassword troca a senha do volume. Com rekey, o conteúdo também é recifrado no lugar com uma chave nova,
+// o que invalida cópias antigas de crypt.json.
+func (fs *FURGFileSystem) ChangePassword(rekey bool) error {
+	if on, err := fs.encrypted(); err != nil || !on {
+		if err == nil {
+			err = classErrorf(ErrUsage, "erro: o volume não é cifrado")
+		}
+		return err
+	}
+	old, err := fs.volumeCipher()
+	if err != nil {
+		return err
+	}
+	var blocks []uint32
+	if rekey {
+		if err = fs.checkRecrypt(); err != nil {
+			return err
+		}
+		var files int
+		blocks, files, err = fs.contentBlocks()
+		if err != nil {
+			return err
+		}
+		err = fs.confirm("O conteúdo de %d arquivo(s) será recifrado no lugar; se a operação for interrompida, o volume ficará ilegível.", files)
+		if err != nil {
+			return err
+		}
+	}
+	password, err := newPassword()
+	if err != nil {
+		return err
+	}
+
+	state := fs.crypt
+	key, c := state.key, old
+	if rekey {
+		key = make([]byte, cryptDataKeySize)
+		if _, err = rand.Read(key); err != nil {
+			return fmt.Errorf("erro ao gerar a chave: %v", err)
+		}
+		if c, err = newXTSCipher(key); err != nil {
+			return err
+		}
+	}
+	params, err := sealKey(key, password)
+	if err != nil {
+		return err
+	}
+	if rekey {
+		if err = fs.recrypt(blocks, old, c, "Recifrando"); err != nil {
+			return err
+		}
+	}
+	if err = fs.writeCryptParams(params); err != nil {
+		return err
+	}
+	fs.crypt = &volumeCrypt{params: params, key: key, cipher: c}
+	if rekey {
+		fmt.Printf(tr("Senha trocada e conteúdo recifrado: %d bloco(s).\n"), len(blocks))
+	} else {
+		fmt.Println(tr("Senha trocada."))
+	}
+	return nil
+}
+
+// cliCrypt implementa "crypt status|enable|disable" e "crypt change-password [--rekey]".
+func cliCrypt(fs *FURGFileSystem, args []string) error {
+	usage := classErrorf(ErrUsage, "uso: furgfs crypt status|enable|disable | crypt change-password [--rekey]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	var err error
+	switch args[0] {
+	case "status":
+		if len(args) != 1 {
+			return usage
+		}
+		state, err := fs.cryptState()
+		if err != nil {
+			return err
+		}
+		if state.params == nil {
+			fmt.Println(tr("Volume não cifrado."))
+			return nil
+		}
+		fmt.Printf(tr("Volume cifrado com %s; chave protegida por %s com %d iterações.\n"),
+			state.params.Cipher, state.params.KDF, state.params.Iterations)
+		return nil
+	case "enable", "disable":
+		if len(args) != 1 {
+			return usage
+		}
+		if args[0] == "enable" {
+			err = fs.EnableEncryption()
+		} else {
+			err = fs.DisableEncryption()
+		}
+	case "change-password":
+		flags := flag.NewFlagSet("crypt change-password", flag.ContinueOnError)
+		rekey := flags.Bool("rekey", false, "recifra o conteúdo com uma chave nova")
+		if err := flags.Parse(args[1:]); err != nil {
+			return classErrorf(ErrUsage, "%w", err)
+		}
+		if flags.NArg() != 0 {
+			return usage
+		}
+		err = fs.ChangePassword(*rekey)
+	default:
+		return usage
+	}
+	if err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
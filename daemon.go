@@ -0,0 +1,436 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// O furgfsd ("furgfs daemon", ou o mesmo executável com o nome furgfsd) mantém a imagem aberta e recebe
+// os comandos por um socket Unix. Quando ele está em execução, o furgfs apenas envia o comando e repassa a
+// saída, de modo que vários processos usam a mesma imagem sem que um sobrescreva o estado salvo pelo
+// outro. Os comandos são executados um de cada vez, com a FAT e o diretório já carregados.
+
+// daemonLock é a trava exclusiva da imagem mantida pelo daemon deste processo; nil fora do daemon.
+var daemonLock *os.File
+
+// imageLocks são as travas compartilhadas das imagens abertas por este processo, mantidas até o fim dele
+// para que um daemon não seja iniciado sobre uma imagem em uso.
+var imageLocks = make(map[string]*os.File)
+
+// lockImage trava a imagem local name para este processo, falhando se um daemon a tiver aberto.
+func lockImage(name string) error {
+	if daemonLock != nil || isRemoteImage(name) {
+		return nil
+	}
+	path, err := filepath.Abs(name)
+	if err != nil || imageLocks[path] != nil {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		// O erro aparece ao abrir a imagem
+		return nil
+	}
+	if lockFile(f, false) != nil {
+		f.Close()
+		return classErrorf(ErrUsage, "erro: a imagem '%s' está aberta pelo furgfsd; use os comandos do furgfs ou encerre-o", name)
+	}
+	imageLocks[path] = f
+	return nil
+}
+
+// unlockImage solta a trava de name obtida por lockImage, para um processo que não vai mais usar a imagem.
+func unlockImage(name string) {
+	path, err := filepath.Abs(name)
+	if f := imageLocks[path]; err == nil && f != nil {
+		f.Close()
+		delete(imageLocks, path)
+	}
+}
+
+// daemonSocket retorna o socket do daemon da imagem name: FURGFS_SOCKET ou um nome derivado do caminho
+// da imagem em um diretório temporário acessível só pelo usuário.
+func daemonSocket(name string) string {
+	if path := os.Getenv("FURGFS_SOCKET"); path != "" {
+		return path
+	}
+	if !isRemoteImage(name) {
+		if abs, err := filepath.Abs(name); err == nil {
+			name = abs
+		}
+	}
+	sum := sha256.Sum256([]byte(name))
+	return filepath.Join(os.TempDir(), fmt.Sprintf("furgfs-%d", os.Getuid()), hex.EncodeToString(sum[:8])+".sock")
+}
+
+// daemonRequest é o pedido do cliente: o comando, o diretório e as variáveis FURGFS_* e AWS_* dele, e o
+// idioma e as cores da saída.
+type daemonRequest struct {
+	Args        []string          `json:"args"`
+	Dir         string            `json:"dir"`
+	Env         map[string]string `json:"env,omitempty"`
+	Lang        string            `json:"lang"`
+	StdoutColor bool              `json:"stdout_color,omitempty"`
+	StderrColor bool              `json:"stderr_color,omitempty"`
+}
+
+// daemonFrame é uma mensagem trocada durante o comando: a entrada padrão, do cliente para o daemon, e
+// as saídas e o código de saída, do daemon para o cliente.
+type daemonFrame struct {
+	Stdin  []byte `json:"stdin,omitempty"`
+	EOF    bool   `json:"eof,omitempty"` // fim da entrada padrão
+	Stdout []byte `json:"stdout,omitempty"`
+	Stderr []byte `json:"stderr,omitempty"`
+	Exit   *int   `json:"exit,omitempty"`
+}
+
+// daemonEnv informa se a variável name é repassada ao daemon.
+func daemonEnv(name string) bool {
+	return strings.HasPrefix(name, "FURGFS_") || strings.HasPrefix(name, "AWS_")
+}
+
+// forwardToDaemon envia args ao daemon da imagem, se houver um, e repassa a entrada e as saídas. Retorna
+// falso se nenhum daemon atender no socket.
+func forwardToDaemon(imageName string, args []string) (int, bool) {
+	conn, err := net.Dial("unix", daemonSocket(imageName))
+	if err != nil {
+		return 0, false
+	}
+	defer conn.Close()
+	if args[0] == "shell" || args[0] == "tui" {
+		printError(classErrorf(ErrUsage, "erro: %s usa o terminal e não pode ser executado pelo furgfsd", args[0]))
+		return exitUsage, true
+	}
+
+	req := daemonRequest{Args: args, Env: make(map[string]string), Lang: currentLang,
+		StdoutColor: stdoutColors.enabled, StderrColor: stderrColors.enabled}
+	req.Dir, _ = os.Getwd()
+	for _, variable := range os.Environ() {
+		if name, value, ok := strings.Cut(variable, "="); ok && daemonEnv(name) {
+			req.Env[name] = value
+		}
+	}
+	enc := json.NewEncoder(conn)
+	if err := enc.Encode(req); err != nil {
+		printError(fmt.Errorf(tr("erro ao enviar o comando ao furgfsd: %v"), err))
+		return exitFailure, true
+	}
+	go func() {
+		// A entrada de um terminal não é repassada: o daemon não tem como ler senhas ou confirmações
+		if _, _, err := terminalSize(int(os.Stdin.Fd())); err != nil {
+			buf := make([]byte, 32<<10)
+			for {
+				n, err := os.Stdin.Read(buf)
+				if n > 0 && enc.Encode(daemonFrame{Stdin: buf[:n]}) != nil {
+					return
+				}
+				if err != nil {
+					break
+				}
+			}
+		}
+		enc.Encode(daemonFrame{EOF: true})
+	}()
+
+	dec := json.NewDecoder(conn)
+	for {
+		var frame daemonFrame
+		if err := dec.Decode(&frame); err != nil {
+			printError(fmt.Errorf(tr("erro: a conexão com o furgfsd foi interrompida: %v"), err))
+			return exitFailure, true
+		}
+		os.Stdout.Write(frame.Stdout)
+		os.Stderr.Write(frame.Stderr)
+		if frame.Exit != nil {
+			return *frame.Exit, true
+		}
+	}
+}
+
+// furgfsd é o daemon de uma imagem.
+type furgfsd struct {
+	image    string
+	mu       sync.Mutex // um comando de cada vez
+	fs       *FURGFileSystem
+	listener net.Listener
+}
+
+// cliDaemon implementa "daemon [--socket <caminho>] [imagem]": abre a imagem e atende os comandos pelo
+// socket até ser interrompido com Ctrl+C.
+func cliDaemon(imageName string, args []string) error {
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	socket := flags.String("socket", "", "socket Unix em que os comandos são recebidos; o padrão deriva do caminho da imagem")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() > 1 {
+		return classErrorf(ErrUsage, "uso: furgfs daemon [--socket <caminho>] [imagem]")
+	}
+	if flags.NArg() == 1 {
+		var err error
+		if imageName, err = resolveImage(flags.Arg(0)); err != nil {
+			return err
+		}
+	}
+	if *socket == "" {
+		*socket = daemonSocket(imageName)
+	}
+
+	if !isRemoteImage(imageName) {
+		f, err := os.Open(imageName)
+		if err != nil {
+			return classErrorf(ErrNotFound, "erro ao abrir o arquivo: %w", err)
+		}
+		defer f.Close()
+		if lockFile(f, true) != nil {
+			return classErrorf(ErrUsage, "erro: a imagem '%s' está em uso por outro processo", imageName)
+		}
+		daemonLock = f
+	}
+	d := &furgfsd{image: imageName}
+	var err error
+	if d.fs, err = loadFileSystem(imageName); err != nil {
+		return err
+	}
+	defer d.close()
+
+	if err := os.MkdirAll(filepath.Dir(*socket), 0700); err != nil {
+		return fmt.Errorf(tr("erro ao criar o diretório do socket: %v"), err)
+	}
+	if conn, err := net.Dial("unix", *socket); err == nil {
+		conn.Close()
+		return classErrorf(ErrUsage, "erro: já há um furgfsd atendendo em '%s'", *socket)
+	}
+	os.Remove(*socket)
+	d.listener, err = net.Listen("unix", *socket)
+	if err != nil {
+		return fmt.Errorf(tr("erro ao escutar em '%s': %v"), *socket, err)
+	}
+	defer os.Remove(*socket)
+	os.Chmod(*socket, 0600)
+	fmt.Printf(tr("furgfsd: imagem '%s' aberta, comandos em %s; Ctrl+C para encerrar.\n"), imageName, *socket)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		d.listener.Close()
+	}()
+	for {
+		conn, err := d.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		go d.handle(conn)
+	}
+	d.close()
+	fmt.Println(tr("furgfsd encerrado."))
+	return nil
+}
+
+// close espera o comando em andamento terminar e fecha a imagem.
+func (d *furgfsd) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.fs != nil {
+		d.fs.closeImage()
+		d.fs = nil
+	}
+}
+
+// handle atende um cliente: lê o pedido, executa o comando e envia o código de saída.
+func (d *furgfsd) handle(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	var req daemonRequest
+	if err := dec.Decode(&req); err != nil || len(req.Args) == 0 {
+		// Um cliente que desiste antes de enviar o pedido, como shell e tui, não é um erro
+		if !errors.Is(err, io.EOF) {
+			logger.Warn("furgfsd: pedido inválido", "error", err)
+		}
+		return
+	}
+	var encMu sync.Mutex
+	enc := json.NewEncoder(conn)
+	send := func(frame daemonFrame) {
+		encMu.Lock()
+		defer encMu.Unlock()
+		enc.Encode(frame)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.fs == nil {
+		return
+	}
+	logger.Debug("furgfsd: comando", "args", req.Args)
+	code := d.run(req, dec, send)
+	send(daemonFrame{Exit: &code})
+}
+
+// run executa o pedido com o diretório, as variáveis, o idioma, as cores e a entrada e as saídas padrão do
+// cliente, restaurando os do daemon no fim. Um comando que falhou pode ter deixado alterações não salvas
+// na memória, por isso a imagem é recarregada.
+func (d *furgfsd) run(req daemonRequest, dec *json.Decoder, send func(daemonFrame)) int {
+	dir, _ := os.Getwd()
+	defer os.Chdir(dir)
+	saved := make(map[string]*string)
+	for _, variable := range os.Environ() {
+		if name, value, ok := strings.Cut(variable, "="); ok && daemonEnv(name) {
+			saved[name] = &value
+			os.Unsetenv(name)
+		}
+	}
+	for name, value := range req.Env {
+		if _, ok := saved[name]; !ok {
+			saved[name] = nil
+		}
+		os.Setenv(name, value)
+	}
+	defer func() {
+		for name, value := range saved {
+			if value == nil {
+				os.Unsetenv(name)
+			} else {
+				os.Setenv(name, *value)
+			}
+		}
+	}()
+	lang, outColors, errColors := currentLang, stdoutColors, stderrColors
+	defer func() { currentLang, stdoutColors, stderrColors = lang, outColors, errColors }()
+	stdoutColors, stderrColors = palette{enabled: req.StdoutColor}, palette{enabled: req.StderrColor}
+
+	// Entrada e saídas padrão por pipes ligados ao cliente
+	stdin, stdout, stderr, savedConsole := os.Stdin, os.Stdout, os.Stderr, console
+	inR, inW, _ := os.Pipe()
+	outR, outW, _ := os.Pipe()
+	errR, errW, _ := os.Pipe()
+	os.Stdin, os.Stdout, os.Stderr = inR, outW, errW
+	console = newLineEditor(inR, outW)
+	go func() {
+		defer inW.Close()
+		for {
+			var frame daemonFrame
+			if dec.Decode(&frame) != nil || frame.EOF {
+				return
+			}
+			if _, err := inW.Write(frame.Stdin); err != nil {
+				return
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	forward := func(r *os.File, stderr bool) {
+		defer wg.Done()
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				data := append([]byte(nil), buf[:n]...)
+				if stderr {
+					send(daemonFrame{Stderr: data})
+				} else {
+					send(daemonFrame{Stdout: data})
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	wg.Add(2)
+	go forward(outR, false)
+	go forward(errR, true)
+
+	code := exitOK
+	if err := setLanguage(req.Lang); err != nil {
+		printError(err)
+		code = exitCode(err)
+	} else if err := os.Chdir(req.Dir); err != nil {
+		printError(fmt.Errorf(tr("erro: diretório do cliente inacessível: %v"), err))
+		code = exitFailure
+	} else {
+		code = d.command(req.Args)
+	}
+
+	os.Stdin, os.Stdout, os.Stderr, console = stdin, stdout, stderr, savedConsole
+	outW.Close()
+	errW.Close()
+	wg.Wait()
+	inR.Close()
+	outR.Close()
+	errR.Close()
+
+	if code != exitOK {
+		d.reload()
+	}
+	return code
+}
+
+// command executa args como runCLI, sobre a imagem já carregada. As imagens abertas pelo comando são
+// fechadas no fim e a do daemon continua aberta.
+func (d *furgfsd) command(args []string) int {
+	if args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		err := printHelp(args[1:])
+		if err != nil {
+			printError(err)
+		}
+		return exitCode(err)
+	}
+	if command := findCommand(args[0]); command != nil && command.Standalone != nil {
+		err := classErrorf(ErrUsage, "erro: %s abre a imagem por conta própria e não pode ser executado pelo furgfsd", args[0])
+		printError(err)
+		return exitCode(err)
+	}
+	if cliCommand(args[0]) == nil {
+		err := classErrorf(ErrUsage, "erro: comando desconhecido '%s'", args[0])
+		printError(err)
+		return exitCode(err)
+	}
+
+	d.fs.WorkingDir = "/"
+	openImages.add("", d.fs)
+	defer func() {
+		for _, name := range openImages.names() {
+			if image := openImages.images[name]; image != d.fs {
+				image.closeImage()
+			}
+			delete(openImages.images, name)
+		}
+		openImages.current = ""
+	}()
+	endSpan := startCommandSpan(args[0])
+	err := runCommand(d.fs, args)
+	endSpan(err)
+	return exitCode(err)
+}
+
+// reload descarta o estado em memória e lê a imagem de novo. Se a imagem não puder ser lida, o daemon
+// deixa de atender.
+func (d *furgfsd) reload() {
+	d.fs.closeImage()
+	fs, err := loadFileSystem(d.image)
+	if err != nil {
+		logger.Error("furgfsd: erro ao recarregar a imagem; encerrando", "error", err)
+		d.fs = nil
+		d.listener.Close()
+		return
+	}
+	d.fs = fs
+}
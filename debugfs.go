@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Limite de blocos percorridos por "debugfs chain", para que uma cadeia em laço não trave o inspetor.
+const maxDebugChainLength = 1 << 24
+
+// cliDebugfs implementa o inspetor de baixo nível "debugfs <header|fat|chain|owner|dump> ...".
+// Nenhum subcomando altera a imagem.
+func cliDebugfs(fs *FURGFileSystem, args []string) error {
+	usage := fmt.Errorf(`uso: furgfs debugfs <subcomando>
+  header                   mostra os campos do cabeçalho e a posição de cada região
+  fat <início> [fim]       mostra as entradas da FAT no intervalo (inclusive)
+  chain <bloco>            segue a cadeia da FAT a partir de um bloco
+  owner <bloco>            mostra qual entrada do diretório usa o bloco
+  dump <bloco>             mostra o conteúdo do bloco em hexadecimal`)
+	if len(args) == 0 {
+		return usage
+	}
+
+	blockArg := func(s string) (uint32, error) {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil || n >= uint64(len(fs.FAT)) {
+			return 0, fmt.Errorf("erro: bloco '%s' inválido, a FAT tem %d blocos (0 a %d)", s, len(fs.FAT), len(fs.FAT)-1)
+		}
+		return uint32(n), nil
+	}
+
+	switch {
+	case args[0] == "header" && len(args) == 1:
+		fs.debugHeader()
+	case args[0] == "fat" && (len(args) == 2 || len(args) == 3):
+		start, err := blockArg(args[1])
+		if err != nil {
+			return err
+		}
+		end := start
+		if len(args) == 3 {
+			end, err = blockArg(args[2])
+			if err != nil {
+				return err
+			}
+		}
+		fs.debugFAT(start, end)
+	case args[0] == "chain" && len(args) == 2:
+		block, err := blockArg(args[1])
+		if err != nil {
+			return err
+		}
+		fs.debugChain(block)
+	case args[0] == "owner" && len(args) == 2:
+		block, err := blockArg(args[1])
+		if err != nil {
+			return err
+		}
+		fs.debugOwner(block)
+	case args[0] == "dump" && len(args) == 2:
+		block, err := blockArg(args[1])
+		if err != nil {
+			return err
+		}
+		return fs.debugDump(block)
+	default:
+		return usage
+	}
+	return nil
+}
+
+// debugHeader mostra o cabeçalho e as regiões calculadas a partir dele.
+func (fs *FURGFileSystem) debugHeader() {
+	h := fs.Header
+	fmt.Printf("TotalSize:            %d\n", h.TotalSize)
+	fmt.Printf("BlockSize:            %d\n", h.BlockSize)
+	fmt.Printf("FreeSpace:            %d\n", h.FreeSpace)
+	fmt.Printf("FATEntrypointAddress: %d\n", h.FATEntrypointAddress)
+	fmt.Printf("RootDirStart:         %d\n", h.RootDirStart)
+	fmt.Printf("DataStart:            %d\n", h.DataStart)
+	fmt.Println()
+	fmt.Printf("Blocos na FAT:        %d\n", len(fs.FAT))
+	fmt.Printf("Entradas no diretório: %d\n", len(fs.RootDir))
+	fmt.Printf("Região de dados:      %d a %d\n", h.DataStart, uint64(h.DataStart)+uint64(len(fs.FAT))*uint64(h.BlockSize)-1)
+}
+
+// debugFAT mostra as entradas da FAT de start a end.
+func (fs *FURGFileSystem) debugFAT(start, end uint32) {
+	fmt.Printf("%-10s %-10s %-10s %s\n", "Índice", "BlockID", "Próximo", "Usado")
+	for i := start; i <= end; i++ {
+		entry := *fs.fatEntry(i)
+		fmt.Printf("%-10d %-10d %-10d %t\n", i, entry.BlockID, entry.NextBlockID, entry.Used)
+	}
+}
+
+// debugChain segue a cadeia a partir de block até NextBlockID 0, indicando blocos livres, fora da FAT e laços.
+func (fs *FURGFileSystem) debugChain(block uint32) {
+	visited := make(map[uint32]bool)
+	var chain []uint32
+	problem := ""
+	for current := block; ; {
+		if int(current) >= len(fs.FAT) {
+			problem = fmt.Sprintf("o bloco %d está fora da FAT", current)
+			break
+		}
+		if visited[current] {
+			problem = fmt.Sprintf("laço: o bloco %d já foi visitado", current)
+			break
+		}
+		if len(chain) >= maxDebugChainLength {
+			problem = "cadeia longa demais"
+			break
+		}
+		visited[current] = true
+		chain = append(chain, current)
+		if !fs.fatEntry(current).Used {
+			problem = fmt.Sprintf("o bloco %d está marcado como livre", current)
+		}
+		current = fs.fatEntry(current).NextBlockID
+		if current == 0 {
+			break
+		}
+	}
+
+	runs := blockRuns(chain)
+	parts := make([]string, len(runs))
+	for i, run := range runs {
+		if run.Length == 1 {
+			parts[i] = fmt.Sprintf("%d", run.Start)
+		} else {
+			parts[i] = fmt.Sprintf("%d-%d", run.Start, run.Start+run.Length-1)
+		}
+	}
+	fmt.Printf("Cadeia a partir do bloco %d: %d bloco(s) em %d fragmento(s)\n", block, len(chain), len(runs))
+	fmt.Println(strings.Join(parts, " -> "))
+	if problem != "" {
+		fmt.Printf("Problema: %s\n", problem)
+	}
+}
+
+// debugOwner procura a entrada do diretório cuja cadeia contém block.
+func (fs *FURGFileSystem) debugOwner(block uint32) {
+	status := "livre"
+	if fs.fatEntry(block).Used {
+		status = "usado"
+	}
+	fmt.Printf("Bloco %d (%s, próximo %d)\n", block, status, fs.fatEntry(block).NextBlockID)
+
+	found := false
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 {
+			continue
+		}
+		blocks, err := fs.fileBlocks(entry)
+		if err != nil {
+			continue
+		}
+		for position, id := range blocks {
+			if id == block {
+				fmt.Printf("Pertence a '%s' (entrada %d), bloco lógico %d de %d\n", entry.FullPath(), i, position, len(blocks))
+				found = true
+			}
+		}
+	}
+	if !found {
+		if fs.fatEntry(block).Used {
+			fmt.Println("Nenhuma entrada usa este bloco (órfão).")
+		} else {
+			fmt.Println("Nenhuma entrada usa este bloco.")
+		}
+	}
+}
+
+// debugDump mostra o conteúdo do bloco no formato de hexdump, omitindo linhas repetidas.
+func (fs *FURGFileSystem) debugDump(block uint32) error {
+	buf := make([]byte, fs.Header.BlockSize)
+	n, err := fs.readBlock(block, buf)
+	if err != nil {
+		return err
+	}
+	buf = buf[:n]
+
+	offset := uint64(fs.Header.DataStart) + uint64(block)*uint64(fs.Header.BlockSize)
+	fmt.Printf("Bloco %d, deslocamento %d na imagem, %d bytes\n", block, offset, n)
+	previous := ""
+	repeated := false
+	for i := 0; i < len(buf); i += 16 {
+		line := buf[i:min(i+16, len(buf))]
+		hex := fmt.Sprintf("% x", line)
+		if hex == previous && i+16 < len(buf) {
+			if !repeated {
+				fmt.Println("*")
+				repeated = true
+			}
+			continue
+		}
+		previous, repeated = hex, false
+
+		text := []byte(string(line))
+		for k, c := range text {
+			if c < 32 || c > 126 {
+				text[k] = '.'
+			}
+		}
+		fmt.Printf("%08x  %-47s  |%s|\n", i, hex, text)
+	}
+	return nil
+}
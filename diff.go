@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limites para o diff textual: arquivos maiores são comparados apenas bloco a bloco.
+const (
+	maxTextDiffSize  = 64 * 1024
+	maxTextDiffLines = 2000
+	diffContextLines = 3
+)
+
+// cliDiff implementa "diff <arquivo1> <arquivo2>".
+func cliDiff(fs *FURGFileSystem, args []string) error {
+	if len(args) != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs diff <arquivo1> <arquivo2>")
+	}
+	path1, name1 := splitPath(fs.resolvePath(args[0]))
+	path2, name2 := splitPath(fs.resolvePath(args[1]))
+	return fs.DiffFiles(name1, path1, name2, path2)
+}
+
+// DiffFiles compara dois arquivos armazenados no FURGfs2 bloco a bloco, parando no primeiro bloco que
+// difere, que é exibido. Se os dois arquivos forem texto e pequenos, também exibe um diff unificado linha a linha.
+func (fs *FURGFileSystem) DiffFiles(fileName1, path1, fileName2, path2 string) error {
+	path1 = fs.resolvePath(path1)
+	path2 = fs.resolvePath(path2)
+	var entries [2]*FileEntry
+	for i, target := range [2][2]string{{fileName1, path1}, {fileName2, path2}} {
+		var nameArray [32]byte
+		copy(nameArray[:], target[0])
+
+		var pathArray [128]byte
+		copy(pathArray[:], target[1])
+
+		rootDirIndex := fs.CheckFileEntryAlreadyExists(nameArray, pathArray)
+		if rootDirIndex == -1 || fs.RootDir[rootDirIndex].IsDirectory {
+			return classErrorf(ErrNotFound, "erro: O arquivo '%s' em '%s' não foi encontrado no sistema de arquivos", target[0], target[1])
+		}
+		entries[i] = &fs.RootDir[rootDirIndex]
+	}
+
+	nameA, nameB := entries[0].FullPath(), entries[1].FullPath()
+	first, err := fs.firstDifferentBlock(entries[0], entries[1])
+	if err != nil {
+		return err
+	}
+	if first == -1 {
+		fmt.Printf("Os arquivos '%s' e '%s' são idênticos (%d bytes).\n", nameA, nameB, entries[0].Size)
+		return nil
+	}
+
+	fmt.Printf("Os arquivos '%s' (%d bytes) e '%s' (%d bytes) diferem.\n", nameA, entries[0].Size, nameB, entries[1].Size)
+	blockSize := int64(fs.Header.BlockSize)
+	totalBlocks := (int64(max(entries[0].Size, entries[1].Size)) + blockSize - 1) / blockSize
+	fmt.Printf("Primeiro bloco diferente: %d de %d (byte %d)\n", first, totalBlocks, int64(first)*blockSize)
+
+	// Só o diff textual precisa dos conteúdos inteiros, e só arquivos pequenos chegam a ele
+	if entries[0].Size > maxTextDiffSize || entries[1].Size > maxTextDiffSize {
+		return nil
+	}
+	contentA, err := fs.readFileContent(entries[0])
+	if err != nil {
+		return err
+	}
+	contentB, err := fs.readFileContent(entries[1])
+	if err != nil {
+		return err
+	}
+	if isSmallText(contentA) && isSmallText(contentB) {
+		linesA, linesB := splitLines(contentA), splitLines(contentB)
+		if len(linesA) <= maxTextDiffLines && len(linesB) <= maxTextDiffLines {
+			fmt.Print(unifiedDiff(nameA, nameB, linesA, linesB))
+		}
+	}
+	return nil
+}
+
+// firstDifferentBlock lê os dois arquivos juntos, um bloco lógico de cada vez, e retorna o índice do primeiro
+// bloco em que diferem, ou -1 se forem idênticos. A leitura para na primeira diferença.
+func (fs *FURGFileSystem) firstDifferentBlock(a, b *FileEntry) (int, error) {
+	readerA, err := fs.newFileReader(a)
+	if err != nil {
+		return 0, err
+	}
+	defer readerA.release()
+	readerB, err := fs.newFileReader(b)
+	if err != nil {
+		return 0, err
+	}
+	defer readerB.release()
+
+	blockA, blockB := getBuffer(int(fs.Header.BlockSize)), getBuffer(int(fs.Header.BlockSize))
+	defer putBuffer(blockA)
+	defer putBuffer(blockB)
+	for block := 0; ; block++ {
+		nA, err := io.ReadFull(readerA, blockA)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		nB, err := io.ReadFull(readerB, blockB)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		if !bytes.Equal(blockA[:nA], blockB[:nB]) {
+			return block, nil
+		}
+		if nA < len(blockA) {
+			return -1, nil
+		}
+	}
+}
+
+func isSmallText(content []byte) bool {
+	return len(content) <= maxTextDiffSize && utf8.Valid(content) && bytes.IndexByte(content, 0) == -1
+}
+
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+type diffOp struct {
+	kind byte // ' ' para linha comum, '-' para removida, '+' para adicionada
+	line string
+}
+
+// diffLines calcula a sequência de operações que transforma a em b usando a maior subsequência comum (LCS).
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// unifiedDiff formata as diferenças entre a e b no formato de diff unificado, com diffContextLines linhas de contexto.
+func unifiedDiff(nameA, nameB string, a, b []string) string {
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+
+	for start := 0; start < len(ops); {
+		// Procura a próxima alteração
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Estende o trecho enquanto as alterações estiverem próximas
+		hunkStart := max(0, start-diffContextLines)
+		end := start
+		for end < len(ops) {
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContextLines {
+				break
+			}
+			for next < len(ops) && ops[next].kind != ' ' {
+				next++
+			}
+			end = next
+		}
+		hunkEnd := min(len(ops), end+diffContextLines)
+
+		// Calcula as linhas iniciais de cada lado
+		lineA, lineB := 1, 1
+		for _, op := range ops[:hunkStart] {
+			if op.kind != '+' {
+				lineA++
+			}
+			if op.kind != '-' {
+				lineB++
+			}
+		}
+		countA, countB := 0, 0
+		for _, op := range ops[hunkStart:hunkEnd] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+		for _, op := range ops[hunkStart:hunkEnd] {
+			fmt.Fprintf(&out, "%c%s\n", op.kind, op.line)
+		}
+		start = hunkEnd
+	}
+	return out.String()
+}
@@ -0,0 +1,96 @@
+package main
+
+// As buscas no diretório usam um índice em memória, do nome e do caminho do diretório pai para a posição na
+// tabela, montado na primeira busca com uma única varredura. Todas as alterações de nome, caminho ou de
+// entradas inteiras passam por setEntry, clearEntry ou renameEntry, que mantêm o índice em dia; uma posição
+// que não confere mais com a entrada, sinal de uma alteração feita por fora, faz o índice ser montado de
+// novo. Uma tabela com entradas repetidas, só possível numa imagem corrompida, é indexada pela primeira
+// delas, como na varredura.
+//
+// Quem consulta o diretório trabalha com as posições na tabela, que as buscas retornam, e com ponteiros
+// para as entradas nela (&fs.RootDir[i]), e não com cópias: cada FileEntry tem mais de 160 bytes, e as
+// varreduras da tabela inteira copiariam todos eles a cada passo. Uma cópia só é feita quando a entrada
+// precisa sobreviver a uma alteração da tabela.
+
+// dirKey identifica uma entrada pelo nome e pelo caminho do diretório pai, como gravados na tabela.
+type dirKey struct {
+	name [32]byte
+	path [128]byte
+}
+
+// directoryIndex retorna o índice do diretório, montando-o se preciso.
+func (fs *FURGFileSystem) directoryIndex() map[dirKey]int {
+	if fs.dirIndex != nil {
+		return fs.dirIndex
+	}
+	fs.dirIndex = make(map[dirKey]int, len(fs.RootDir))
+	fs.dirDuplicates = false
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 {
+			continue
+		}
+		key := dirKey{entry.Name, entry.Path}
+		if _, found := fs.dirIndex[key]; found {
+			fs.dirDuplicates = true
+			continue
+		}
+		fs.dirIndex[key] = i
+	}
+	return fs.dirIndex
+}
+
+// setEntry grava uma cópia de *entry na posição i da tabela do diretório e atualiza o índice.
+func (fs *FURGFileSystem) setEntry(i int, entry *FileEntry) {
+	old := dirKey{fs.RootDir[i].Name, fs.RootDir[i].Path}
+	fs.RootDir[i] = *entry
+	fs.markEntryDirty(i)
+	fs.reindexEntry(i, old)
+}
+
+// clearEntry apaga a entrada da posição i da tabela do diretório.
+func (fs *FURGFileSystem) clearEntry(i int) {
+	fs.setEntry(i, &FileEntry{})
+}
+
+// setProtected troca a proteção da entrada i da tabela do diretório.
+func (fs *FURGFileSystem) setProtected(i int, protected bool) {
+	fs.RootDir[i].Protected = protected
+	fs.markEntryDirty(i)
+}
+
+// renameEntry troca, na própria tabela, o nome e o caminho do diretório pai da entrada i.
+func (fs *FURGFileSystem) renameEntry(i int, name [32]byte, path [128]byte) {
+	entry := &fs.RootDir[i]
+	old := dirKey{entry.Name, entry.Path}
+	entry.Name, entry.Path = name, path
+	fs.markEntryDirty(i)
+	fs.reindexEntry(i, old)
+}
+
+// reindexEntry atualiza o índice depois que a entrada i, antes indexada por old, foi alterada.
+func (fs *FURGFileSystem) reindexEntry(i int, old dirKey) {
+	if fs.dirIndex == nil {
+		return
+	}
+	entry := &fs.RootDir[i]
+	key := dirKey{entry.Name, entry.Path}
+	if key == old {
+		return
+	}
+	if fs.dirDuplicates {
+		// A entrada removida pode ter uma repetida que passa a valer; o índice é montado de novo
+		fs.dirIndex = nil
+		return
+	}
+	if j, found := fs.dirIndex[old]; found && j == i {
+		delete(fs.dirIndex, old)
+	}
+	if entry.Name[0] != 0 {
+		if _, found := fs.dirIndex[key]; found {
+			fs.dirIndex = nil
+			return
+		}
+		fs.dirIndex[key] = i
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+// cliDockerPlugin não é suportado fora do Linux e do macOS, que têm FUSE.
+func cliDockerPlugin(imageName string, args []string) error {
+	return classErrorf(ErrUsage, "erro: docker-plugin exige FUSE, disponível apenas no Linux e no macOS")
+}
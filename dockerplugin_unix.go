@@ -0,0 +1,302 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// O plugin de volumes do Docker ("furgfs docker-plugin") guarda cada volume em uma imagem <nome>.fs2 no
+// diretório de volumes e a monta com FUSE quando um contêiner a usa, em <diretório>/mounts/<nome>. O Docker
+// conversa com o plugin por HTTP no socket de /run/docker/plugins, segundo a API VolumeDriver; uma
+// imagem é montada uma vez só, não importa quantos contêineres a usem, e desmontada quando o último sai.
+const (
+	dockerPluginSocket  = "/run/docker/plugins/furgfs.sock"
+	dockerPluginRoot    = "/var/lib/furgfs/volumes"
+	dockerPluginContent = "application/vnd.docker.plugins.v1.2+json"
+)
+
+// dockerVolumeName restringe os nomes dos volumes aos que servem de nome de arquivo.
+var dockerVolumeName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// dockerPlugin é o estado do plugin: as imagens montadas e os contêineres que usam cada uma.
+type dockerPlugin struct {
+	root string
+
+	mu     sync.Mutex
+	mounts map[string]*dockerMount
+}
+
+type dockerMount struct {
+	*fuseMount
+	users map[string]bool // IDs dos pedidos de montagem ainda não desmontados
+}
+
+// dockerRequest é o corpo dos pedidos do Docker; cada chamada usa só parte dos campos.
+type dockerRequest struct {
+	Name string
+	ID   string
+	Opts map[string]string
+}
+
+type dockerVolume struct {
+	Name       string
+	Mountpoint string            `json:",omitempty"`
+	Status     map[string]string `json:",omitempty"`
+}
+
+func (p *dockerPlugin) image(name string) string      { return filepath.Join(p.root, name+".fs2") }
+func (p *dockerPlugin) mountpoint(name string) string { return filepath.Join(p.root, "mounts", name) }
+
+// volume descreve o volume name, que precisa existir.
+func (p *dockerPlugin) volume(name string) (dockerVolume, error) {
+	image := p.image(name)
+	if _, err := os.Stat(image); err != nil {
+		return dockerVolume{}, classErrorf(ErrNotFound, "erro: volume '%s' não encontrado", name)
+	}
+	v := dockerVolume{Name: name, Status: map[string]string{"image": image}}
+	if target, err := os.Readlink(image); err == nil {
+		v.Status["image"] = target
+	}
+	if p.mounts[name] != nil {
+		v.Mountpoint = p.mountpoint(name)
+	}
+	return v, nil
+}
+
+// create cria o volume name. Com a opção image, o volume usa uma imagem já existente, que não é apagada
+// com ele; senão, uma imagem vazia é criada com as opções size, block-size, entries e
+// label de mkfs.
+func (p *dockerPlugin) create(name string, opts map[string]string) error {
+	if !dockerVolumeName.MatchString(name) {
+		return classErrorf(ErrUsage, "erro: nome de volume '%s' inválido", name)
+	}
+	image := p.image(name)
+	if _, err := os.Lstat(image); err == nil {
+		return nil
+	}
+	if source, ok := opts["image"]; ok {
+		source, err := filepath.Abs(source)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(source); err != nil {
+			return classErrorf(ErrNotFound, "erro: imagem '%s' não encontrada", source)
+		}
+		return os.Symlink(source, image)
+	}
+
+	args := []string{"--size", "64M", "--entries", "1000"}
+	for key, value := range opts {
+		switch key {
+		case "size", "block-size", "entries", "label":
+			args = append(args, "--"+key, value)
+		default:
+			return classErrorf(ErrUsage, "erro: opção '%s' desconhecida; use size, block-size, entries, label ou image", key)
+		}
+	}
+	// cliMkfs escreve o resumo da imagem criada, que vai para o log do plugin
+	return cliMkfs(image, append(args, image))
+}
+
+// remove apaga o volume name, que não pode estar montado. Uma imagem importada com image é mantida.
+func (p *dockerPlugin) remove(name string) error {
+	if _, err := p.volume(name); err != nil {
+		return err
+	}
+	if p.mounts[name] != nil {
+		return classErrorf(ErrUsage, "erro: o volume '%s' está em uso", name)
+	}
+	os.Remove(p.mountpoint(name))
+	os.Remove(indexPath(p.image(name)))
+	os.RemoveAll(p.image(name) + uploadsSuffix)
+	unlockImage(p.image(name))
+	return os.Remove(p.image(name))
+}
+
+// mount monta o volume name para o pedido id, se ainda não estiver montado.
+func (p *dockerPlugin) mount(name, id string) (string, error) {
+	if _, err := p.volume(name); err != nil {
+		return "", err
+	}
+	mountpoint := p.mountpoint(name)
+	m := p.mounts[name]
+	if m == nil {
+		if err := os.MkdirAll(mountpoint, 0o755); err != nil {
+			return "", err
+		}
+		// Os processos dos contêineres podem usar qualquer usuário
+		fm, err := mountVolume(p.image(name), mountpoint, false, fuse.MountOptions{AllowOther: true})
+		if err != nil {
+			return "", err
+		}
+		m = &dockerMount{fuseMount: fm, users: make(map[string]bool)}
+		p.mounts[name] = m
+	}
+	m.users[id] = true
+	return mountpoint, nil
+}
+
+// unmount libera o volume name do pedido id e o desmonta quando ninguém mais o usa.
+func (p *dockerPlugin) unmount(name, id string) error {
+	m := p.mounts[name]
+	if m == nil {
+		return nil
+	}
+	delete(m.users, id)
+	if len(m.users) > 0 {
+		return nil
+	}
+	if err := m.server.Unmount(); err != nil {
+		return fmt.Errorf(tr("erro ao desmontar '%s': %v"), p.mountpoint(name), err)
+	}
+	m.wait()
+	delete(p.mounts, name)
+	return nil
+}
+
+// unmountAll desmonta todos os volumes, no encerramento do plugin.
+func (p *dockerPlugin) unmountAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name, m := range p.mounts {
+		m.users = nil
+		if err := p.unmount(name, ""); err != nil {
+			printError(err)
+		}
+	}
+}
+
+// handler atende a API de plugins do Docker. Os erros vão no campo Err de uma resposta 200, como o Docker
+// espera.
+func (p *dockerPlugin) handler() http.Handler {
+	mux := http.NewServeMux()
+	handle := func(method string, fn func(req dockerRequest) (map[string]any, error)) {
+		mux.HandleFunc("POST /"+method, func(w http.ResponseWriter, r *http.Request) {
+			var req dockerRequest
+			// Alguns pedidos, como Plugin.Activate, vêm sem corpo
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			p.mu.Lock()
+			resp, err := fn(req)
+			p.mu.Unlock()
+			if resp == nil {
+				resp = map[string]any{}
+			}
+			if err != nil {
+				logger.Warn("docker-plugin: pedido falhou", "method", method, "volume", req.Name, "error", err)
+				resp["Err"] = err.Error()
+			}
+			w.Header().Set("Content-Type", dockerPluginContent)
+			json.NewEncoder(w).Encode(resp)
+		})
+	}
+
+	handle("Plugin.Activate", func(dockerRequest) (map[string]any, error) {
+		return map[string]any{"Implements": []string{"VolumeDriver"}}, nil
+	})
+	handle("VolumeDriver.Capabilities", func(dockerRequest) (map[string]any, error) {
+		return map[string]any{"Capabilities": map[string]string{"Scope": "local"}}, nil
+	})
+	handle("VolumeDriver.Create", func(req dockerRequest) (map[string]any, error) {
+		return nil, p.create(req.Name, req.Opts)
+	})
+	handle("VolumeDriver.Remove", func(req dockerRequest) (map[string]any, error) {
+		return nil, p.remove(req.Name)
+	})
+	handle("VolumeDriver.Mount", func(req dockerRequest) (map[string]any, error) {
+		mountpoint, err := p.mount(req.Name, req.ID)
+		return map[string]any{"Mountpoint": mountpoint}, err
+	})
+	handle("VolumeDriver.Unmount", func(req dockerRequest) (map[string]any, error) {
+		return nil, p.unmount(req.Name, req.ID)
+	})
+	handle("VolumeDriver.Path", func(req dockerRequest) (map[string]any, error) {
+		v, err := p.volume(req.Name)
+		return map[string]any{"Mountpoint": v.Mountpoint}, err
+	})
+	handle("VolumeDriver.Get", func(req dockerRequest) (map[string]any, error) {
+		v, err := p.volume(req.Name)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"Volume": v}, nil
+	})
+	handle("VolumeDriver.List", func(dockerRequest) (map[string]any, error) {
+		images, err := filepath.Glob(filepath.Join(p.root, "*.fs2"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(images)
+		volumes := []dockerVolume{}
+		for _, image := range images {
+			if v, err := p.volume(strings.TrimSuffix(filepath.Base(image), ".fs2")); err == nil {
+				volumes = append(volumes, v)
+			}
+		}
+		return map[string]any{"Volumes": volumes}, nil
+	})
+	return mux
+}
+
+// cliDockerPlugin implementa "docker-plugin [--socket <caminho>] [--root <diretório>]": atende o Docker
+// até receber Ctrl+C ou SIGTERM, quando desmonta os volumes em uso.
+func cliDockerPlugin(imageName string, args []string) error {
+	flags := flag.NewFlagSet("docker-plugin", flag.ContinueOnError)
+	socket := flags.String("socket", dockerPluginSocket, "socket em que o Docker procura o plugin")
+	root := flags.String("root", dockerPluginRoot, "diretório das imagens dos volumes")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 0 {
+		return classErrorf(ErrUsage, "uso: furgfs docker-plugin [--socket <caminho>] [--root <diretório>]")
+	}
+	plugin := &dockerPlugin{root: *root, mounts: make(map[string]*dockerMount)}
+	if err := os.MkdirAll(filepath.Join(plugin.root, "mounts"), 0o700); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*socket), 0o755); err != nil {
+		return err
+	}
+	if err := os.Remove(*socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	listener, err := net.Listen("unix", *socket)
+	if err != nil {
+		return fmt.Errorf(tr("erro ao escutar em '%s': %v"), *socket, err)
+	}
+	defer os.Remove(*socket)
+	fmt.Printf(tr("Plugin de volumes do Docker em '%s', com as imagens em '%s'; Ctrl+C para encerrar.\n"), *socket, plugin.root)
+
+	server := &http.Server{Handler: plugin.handler()}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		server.Close()
+	}()
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	signal.Stop(signals)
+	plugin.unmountAll()
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A política de durabilidade diz quando o programa pede ao sistema operacional, com fsync, que leve a
+// imagem ao disco. Sem isso, uma alteração já gravada pode continuar só no cache do sistema e se perder
+// numa queda de energia; com ele, cada pedido espera o disco. A política vem de --sync ou de FURGFS_SYNC:
+//
+//   - always: a cada salvamento do estado, duas vezes: antes dos metadados, para que os blocos de dados
+//     a que eles apontam já estejam no disco, e depois deles. Uma queda perde no máximo a operação em
+//     andamento, e a FAT gravada nunca aponta para blocos que não chegaram ao disco.
+//   - onclose: ao fim de cada comando que alterou a imagem e ao fechá-la (close, serve, mount e o daemon
+//     ao encerrar). Um comando concluído está no disco; uma queda no meio dele pode perder o que ele fez.
+//   - never (padrão): nunca; o sistema operacional grava quando quiser.
+//
+// Os pedidos explícitos dos clientes, como o fsync num ponto de montagem e o FLUSH do NBD, são atendidos
+// em qualquer política. As imagens remotas são enviadas ao serem fechadas, também em qualquer política.
+type syncPolicy int
+
+const (
+	syncNever syncPolicy = iota
+	syncOnClose
+	syncAlways
+)
+
+// imageSync é a política de durabilidade em uso.
+var imageSync syncPolicy
+
+// setupSync escolhe a política de durabilidade pelo valor de --sync ou, sem ele, de FURGFS_SYNC.
+func setupSync(mode string) error {
+	if mode == "" {
+		mode = os.Getenv("FURGFS_SYNC")
+	}
+	switch strings.ToLower(mode) {
+	case "", "never":
+		imageSync = syncNever
+	case "onclose":
+		imageSync = syncOnClose
+	case "always":
+		imageSync = syncAlways
+	default:
+		return classErrorf(ErrUsage, "erro: política de sincronização '%s' inválida (use always, onclose ou never)", mode)
+	}
+	return nil
+}
+
+// syncImage pede que a imagem seja levada ao disco, exceto numa imagem remota, que é enviada ao ser fechada.
+func (fs *FURGFileSystem) syncImage() error {
+	if _, remote := baseStore(fs.FilePointer).(*remoteStore); !remote {
+		if err := fs.FilePointer.Sync(); err != nil {
+			return fmt.Errorf("erro ao levar a imagem ao disco: %v", err)
+		}
+		logger.Debug("imagem levada ao disco", fs.imageAttr())
+	}
+	fs.synced = fs.saves
+	return nil
+}
+
+// syncChanged leva a imagem ao disco se ela foi salva desde a última vez e a política não é never. É
+// chamada ao fim de cada comando e ao fechar a imagem.
+func (fs *FURGFileSystem) syncChanged() error {
+	if imageSync == syncNever || fs.synced == fs.saves {
+		return nil
+	}
+	return fs.syncImage()
+}
+
+// closeImage leva ao disco, conforme a política, as alterações ainda não sincronizadas e fecha a imagem.
+func (fs *FURGFileSystem) closeImage() error {
+	err := fs.syncChanged()
+	if closeErr := fs.FilePointer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Categorias de erro. Os erros de cada categoria mantêm sua mensagem original e podem ser identificados
+// com errors.Is; na linha de comando, cada categoria tem um código de saída próprio (veja exitCode).
+var (
+	ErrUsage     = errors.New("uso incorreto")
+	ErrNotFound  = errors.New("não encontrado")
+	ErrNoSpace   = errors.New("espaço insuficiente")
+	ErrProtected = errors.New("arquivo protegido")
+	ErrCorrupted = errors.New("imagem corrompida")
+)
+
+// classError associa uma categoria a um erro sem alterar sua mensagem.
+type classError struct {
+	err   error
+	class error
+}
+
+func (e *classError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classError) Unwrap() []error {
+	return []error{e.err, e.class}
+}
+
+// classErrorf formata um erro como fmt.Errorf, com o formato traduzido por tr, e o associa à categoria class.
+func classErrorf(class error, format string, args ...any) error {
+	return &classError{err: fmt.Errorf(tr(format), args...), class: class}
+}
+
+// Códigos de saída da linha de comando.
+const (
+	exitOK           = 0
+	exitFailure      = 1 // erro sem categoria
+	exitUsage        = 2 // comando ou argumentos inválidos
+	exitNotFound     = 3 // arquivo, diretório ou imagem inexistente
+	exitNoSpace      = 4 // sem blocos ou entradas livres
+	exitProtected    = 5 // arquivo protegido
+	exitCorrupted    = 6 // imagem inconsistente (execute o fsck)
+	exitNotConfirmed = 7 // ação destrutiva sem --force
+)
+
+// exitCode retorna o código de saída correspondente à categoria de err.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, ErrUsage):
+		return exitUsage
+	case errors.Is(err, ErrNotFound), errors.Is(err, os.ErrNotExist):
+		return exitNotFound
+	case errors.Is(err, ErrNoSpace):
+		return exitNoSpace
+	case errors.Is(err, ErrProtected):
+		return exitProtected
+	case errors.Is(err, ErrCorrupted), errors.Is(err, io.ErrUnexpectedEOF):
+		return exitCorrupted
+	case errors.Is(err, ErrNotConfirmed):
+		return exitNotConfirmed
+	}
+	return exitFailure
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// O conteúdo de um arquivo é lido e gravado por trechos contíguos da cadeia (extents), e não bloco a bloco:
+// a cadeia é percorrida na FAT, os blocos consecutivos são juntados (veja blockRuns) em trechos de até
+// extentMaxBytes, e cada trecho é uma só chamada de ReadAt ou WriteAt na imagem. Um arquivo de milhares
+// de blocos alocado numa sequência contígua (veja prealloc.go) é lido com poucas chamadas, e um arquivo
+// fragmentado cai naturalmente em trechos de um bloco. A cifra e o cache de escritas continuam trabalhando
+// por bloco, sobre o trecho já na memória.
+const extentMaxBytes = 1 << 20
+
+// fileExtents divide a cadeia de blocos em trechos contíguos de no máximo extentMaxBytes.
+func (fs *FURGFileSystem) fileExtents(blocks []uint32) []blockRun {
+	limit := max(extentMaxBytes/fs.Header.BlockSize, 1)
+	var extents []blockRun
+	for _, run := range blockRuns(blocks) {
+		for run.Length > 0 {
+			n := min(run.Length, limit)
+			extents = append(extents, blockRun{Start: run.Start, Length: n})
+			run.Start += n
+			run.Length -= n
+		}
+	}
+	return extents
+}
+
+// extentBytes retorna o tamanho em bytes do trecho run.
+func (fs *FURGFileSystem) extentBytes(run blockRun) int {
+	return int(run.Length) * int(fs.Header.BlockSize)
+}
+
+// readExtent lê o trecho run para buf com uma só chamada de ReadAt e o completa como finishExtent.
+func (fs *FURGFileSystem) readExtent(run blockRun, buf []byte, c *xtsCipher) (err error) {
+	end := fs.startSpan("furgfs.extent.read", attribute.Int("furgfs.block", int(run.Start)), attribute.Int("furgfs.blocks", int(run.Length)))
+	defer func() { end(err) }()
+	n, err := fs.FilePointer.ReadAt(buf, fs.blockOffset(run.Start))
+	return fs.finishExtent(run, buf, n, err, c)
+}
+
+// finishExtent completa o trecho run, do qual n bytes foram lidos da imagem para buf: zera o que faltou,
+// aplica por cima o início pendente de cada bloco no cache de escritas e decifra os blocos com c, se não
+// for nil. Pode ser chamada por várias goroutines ao mesmo tempo, desde que ninguém grave no volume.
+func (fs *FURGFileSystem) finishExtent(run blockRun, buf []byte, n int, readErr error, c *xtsCipher) error {
+	if readErr != nil && readErr != io.EOF {
+		return fmt.Errorf("erro ao ler os blocos %d a %d: %v", run.Start, run.Start+run.Length-1, readErr)
+	}
+	clear(buf[n:])
+	blockSize := int(fs.Header.BlockSize)
+	for i := range int(run.Length) {
+		blockID := run.Start + uint32(i)
+		block := buf[i*blockSize : (i+1)*blockSize]
+		// O início pendente no cache de escritas vale mais que o conteúdo da imagem, como em readBlock
+		if cached := fs.wcache.overlay(blockID, block, 0); cached == 0 && i*blockSize >= n {
+			return fmt.Errorf("erro ao ler bloco %d: %v", blockID, io.EOF)
+		}
+		if c != nil {
+			c.decryptBlock(block, blockID)
+		}
+	}
+	return nil
+}
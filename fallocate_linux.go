@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// fallocate reserva no disco os primeiros size bytes de f, sem alterar o que já foi escrito.
+func fallocate(f *os.File, size int64) error {
+	return unix.Fallocate(int(f.Fd()), 0, 0, size)
+}
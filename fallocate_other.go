@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// fallocate não tem uma chamada portável fora do Linux; preallocate preenche a imagem com zeros.
+func fallocate(f *os.File, size int64) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// fatVolume lê uma imagem de disco FAT12, FAT16 ou FAT32 (sem tabela de partições), como as de disquetes e
+// cartões SD formatados diretamente. Apenas a primeira cópia da FAT é usada.
+type fatVolume struct {
+	r                 io.ReaderAt
+	bits              int // 12, 16 ou 32
+	clusterSize       uint32
+	rootDirOffset     int64  // FAT12 e FAT16: posição do diretório raiz de tamanho fixo
+	rootDirSize       uint32 // em bytes
+	rootCluster       uint32 // FAT32: primeiro cluster do diretório raiz
+	dataOffset        int64
+	clusterCount      uint32
+	table             []byte // a primeira FAT
+	bytesPerSector    uint32
+	sectorsPerCluster uint32
+}
+
+// fatDirEntry é uma entrada de diretório de um volume FAT, já com o nome longo, se houver.
+type fatDirEntry struct {
+	Name     string
+	Dir      bool
+	ReadOnly bool
+	Cluster  uint32
+	Size     uint32
+}
+
+// openFATVolume lê o setor de boot de r e prepara a leitura do volume.
+func openFATVolume(r io.ReaderAt) (*fatVolume, error) {
+	boot := make([]byte, 512)
+	if _, err := r.ReadAt(boot, 0); err != nil {
+		return nil, fmt.Errorf("erro ao ler o setor de boot: %v", err)
+	}
+	u16 := func(off int) uint32 { return uint32(binary.LittleEndian.Uint16(boot[off:])) }
+	u32 := func(off int) uint32 { return binary.LittleEndian.Uint32(boot[off:]) }
+
+	v := &fatVolume{r: r, bytesPerSector: u16(11), sectorsPerCluster: uint32(boot[13])}
+	reserved, fats, rootEntries := u16(14), uint32(boot[16]), u16(17)
+	totalSectors, fatSectors := u16(19), u16(22)
+	if totalSectors == 0 {
+		totalSectors = u32(32)
+	}
+	if fatSectors == 0 {
+		fatSectors = u32(36)
+	}
+
+	validSector := v.bytesPerSector == 512 || v.bytesPerSector == 1024 || v.bytesPerSector == 2048 || v.bytesPerSector == 4096
+	validCluster := v.sectorsPerCluster != 0 && v.sectorsPerCluster&(v.sectorsPerCluster-1) == 0
+	if !validSector || !validCluster || reserved == 0 || fats == 0 || fatSectors == 0 || totalSectors == 0 {
+		return nil, classErrorf(ErrCorrupted, "erro: a imagem não tem um setor de boot FAT válido")
+	}
+
+	v.clusterSize = v.bytesPerSector * v.sectorsPerCluster
+	rootDirSectors := (rootEntries*32 + v.bytesPerSector - 1) / v.bytesPerSector
+	firstData := reserved + fats*fatSectors + rootDirSectors
+	if firstData >= totalSectors {
+		return nil, classErrorf(ErrCorrupted, "erro: a imagem não tem um setor de boot FAT válido")
+	}
+	v.clusterCount = (totalSectors - firstData) / v.sectorsPerCluster
+
+	// A variante é definida pelo número de clusters, como na especificação da Microsoft
+	switch {
+	case v.clusterCount < 4085:
+		v.bits = 12
+	case v.clusterCount < 65525:
+		v.bits = 16
+	default:
+		v.bits = 32
+		v.rootCluster = u32(44)
+	}
+	v.rootDirOffset = int64(reserved+fats*fatSectors) * int64(v.bytesPerSector)
+	v.rootDirSize = rootEntries * 32
+	v.dataOffset = int64(firstData) * int64(v.bytesPerSector)
+
+	v.table = make([]byte, int64(fatSectors)*int64(v.bytesPerSector))
+	if _, err := r.ReadAt(v.table, int64(reserved)*int64(v.bytesPerSector)); err != nil {
+		return nil, fmt.Errorf("erro ao ler a FAT: %v", err)
+	}
+	return v, nil
+}
+
+// next retorna o cluster seguinte a cluster na FAT e se ele é o último da cadeia.
+func (v *fatVolume) next(cluster uint32) (uint32, bool, error) {
+	var value, end uint32
+	switch v.bits {
+	case 12:
+		off := cluster + cluster/2
+		if int(off)+2 > len(v.table) {
+			return 0, false, classErrorf(ErrCorrupted, "erro: cluster %d fora da FAT", cluster)
+		}
+		value = uint32(binary.LittleEndian.Uint16(v.table[off:]))
+		if cluster%2 == 1 {
+			value >>= 4
+		}
+		value, end = value&0xFFF, 0xFF8
+	case 16:
+		if int(cluster)*2+2 > len(v.table) {
+			return 0, false, classErrorf(ErrCorrupted, "erro: cluster %d fora da FAT", cluster)
+		}
+		value, end = uint32(binary.LittleEndian.Uint16(v.table[cluster*2:])), 0xFFF8
+	default:
+		if int(cluster)*4+4 > len(v.table) {
+			return 0, false, classErrorf(ErrCorrupted, "erro: cluster %d fora da FAT", cluster)
+		}
+		value, end = binary.LittleEndian.Uint32(v.table[cluster*4:])&0x0FFFFFFF, 0x0FFFFFF8
+	}
+	return value, value >= end, nil
+}
+
+// chain retorna a cadeia de clusters que começa em first.
+func (v *fatVolume) chain(first uint32) ([]uint32, error) {
+	var clusters []uint32
+	for cluster := first; ; {
+		if cluster < 2 || cluster >= v.clusterCount+2 || uint32(len(clusters)) > v.clusterCount {
+			return nil, classErrorf(ErrCorrupted, "erro: cadeia de clusters inválida a partir do cluster %d", first)
+		}
+		clusters = append(clusters, cluster)
+		next, last, err := v.next(cluster)
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			return clusters, nil
+		}
+		cluster = next
+	}
+}
+
+// open retorna um leitor para os size bytes guardados na cadeia que começa em first.
+func (v *fatVolume) open(first, size uint32) (io.Reader, error) {
+	if size == 0 {
+		return strings.NewReader(""), nil
+	}
+	clusters, err := v.chain(first)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(clusters))*uint64(v.clusterSize) < uint64(size) {
+		return nil, classErrorf(ErrCorrupted, "erro: a cadeia do cluster %d é menor que o tamanho do arquivo", first)
+	}
+	readers := make([]io.Reader, len(clusters))
+	for k, cluster := range clusters {
+		readers[k] = io.NewSectionReader(v.r, v.dataOffset+int64(cluster-2)*int64(v.clusterSize), int64(v.clusterSize))
+	}
+	return io.LimitReader(io.MultiReader(readers...), int64(size)), nil
+}
+
+// readDir lê as entradas do diretório que começa em cluster; 0 indica o diretório raiz.
+func (v *fatVolume) readDir(cluster uint32) ([]fatDirEntry, error) {
+	var data []byte
+	if cluster == 0 && v.bits != 32 {
+		data = make([]byte, v.rootDirSize)
+		if _, err := v.r.ReadAt(data, v.rootDirOffset); err != nil {
+			return nil, fmt.Errorf("erro ao ler o diretório raiz: %v", err)
+		}
+	} else {
+		if cluster == 0 {
+			cluster = v.rootCluster
+		}
+		clusters, err := v.chain(cluster)
+		if err != nil {
+			return nil, err
+		}
+		r, err := v.open(cluster, uint32(len(clusters))*v.clusterSize)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("erro ao ler o diretório: %v", err)
+		}
+	}
+
+	var entries []fatDirEntry
+	var longName []uint16
+	var longSum byte
+	for off := 0; off+32 <= len(data); off += 32 {
+		raw := data[off : off+32]
+		if raw[0] == 0 {
+			break
+		}
+		attr := raw[11]
+		if raw[0] == 0xE5 {
+			longName = nil
+			continue
+		}
+		if attr == 0x0F {
+			// Parte de um nome longo: as partes vêm da última para a primeira
+			order := int(raw[0] & 0x1F)
+			if raw[0]&0x40 != 0 {
+				longName, longSum = make([]uint16, 13*order), raw[13]
+			}
+			if order == 0 || longName == nil || 13*order > len(longName) || raw[13] != longSum {
+				longName = nil
+				continue
+			}
+			var part []uint16
+			for _, span := range [][2]int{{1, 11}, {14, 26}, {28, 32}} {
+				for i := span[0]; i < span[1]; i += 2 {
+					part = append(part, binary.LittleEndian.Uint16(raw[i:]))
+				}
+			}
+			copy(longName[13*(order-1):], part)
+			continue
+		}
+		if attr&0x08 != 0 {
+			longName = nil
+			continue
+		}
+
+		name := shortFATName(raw)
+		if longName != nil && fatNameChecksum(raw[:11]) == longSum {
+			end := len(longName)
+			for k, c := range longName {
+				if c == 0 {
+					end = k
+					break
+				}
+			}
+			name = string(utf16.Decode(longName[:end]))
+		}
+		longName = nil
+		if name == "." || name == ".." {
+			continue
+		}
+		entries = append(entries, fatDirEntry{
+			Name:     name,
+			Dir:      attr&0x10 != 0,
+			ReadOnly: attr&0x01 != 0,
+			Cluster:  uint32(binary.LittleEndian.Uint16(raw[20:]))<<16 | uint32(binary.LittleEndian.Uint16(raw[26:])),
+			Size:     binary.LittleEndian.Uint32(raw[28:]),
+		})
+	}
+	return entries, nil
+}
+
+// shortFATName monta o nome 8.3 da entrada, respeitando as marcas de minúsculas gravadas pelo Windows.
+func shortFATName(raw []byte) string {
+	decode := func(b []byte, lower bool) string {
+		var s strings.Builder
+		for _, c := range b {
+			if lower && c >= 'A' && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			s.WriteRune(rune(c))
+		}
+		return strings.TrimRight(s.String(), " ")
+	}
+	base := append([]byte(nil), raw[:8]...)
+	if base[0] == 0x05 {
+		base[0] = 0xE5
+	}
+	name := decode(base, raw[12]&0x08 != 0)
+	if ext := decode(raw[8:11], raw[12]&0x10 != 0); ext != "" {
+		name += "." + ext
+	}
+	return name
+}
+
+// fatNameChecksum calcula o checksum do nome 8.3 guardado nas partes do nome longo.
+func fatNameChecksum(name []byte) byte {
+	var sum byte
+	for _, c := range name {
+		sum = (sum>>1 | sum<<7) + c
+	}
+	return sum
+}
+
+// ImportFATImage copia para destPath os diretórios e arquivos do volume FAT lido de r. Arquivos somente
+// leitura ficam protegidos. Entradas com nomes que o FURGfs2 não aceita são ignoradas, com um aviso.
+func (fs *FURGFileSystem) ImportFATImage(r io.ReaderAt, destPath string) error {
+	destPath = fs.resolvePath(destPath)
+	if fs.CheckDirectoryExists(destPath) == -1 {
+		return classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", destPath)
+	}
+	volume, err := openFATVolume(r)
+	if err != nil {
+		return err
+	}
+
+	files, directories, skipped := 0, 0, 0
+	visited := map[uint32]bool{}
+	var walk func(cluster uint32, parent string) error
+	walk = func(cluster uint32, parent string) error {
+		if visited[cluster] {
+			return classErrorf(ErrCorrupted, "erro: ciclo na árvore de diretórios do volume FAT (cluster %d)", cluster)
+		}
+		visited[cluster] = true
+		entries, err := volume.readDir(cluster)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := fs.Rules.ValidateEntry(parent, e.Name); err != nil {
+				fmt.Printf(tr("Ignorando '%s': %v\n"), joinPath(parent, e.Name), err)
+				skipped++
+				continue
+			}
+			if e.Dir {
+				if err := fs.CreateDirectory(e.Name, parent, true); err != nil {
+					return err
+				}
+				directories++
+				if err := walk(e.Cluster, joinPath(parent, e.Name)); err != nil {
+					return err
+				}
+				continue
+			}
+			content, err := volume.open(e.Cluster, e.Size)
+			if err != nil {
+				return fmt.Errorf("erro ao ler '%s': %w", joinPath(parent, e.Name), err)
+			}
+			if _, err := fs.createFile(parent, e.Name, content, e.ReadOnly); err != nil {
+				return fmt.Errorf("erro ao importar '%s': %w", joinPath(parent, e.Name), err)
+			}
+			files++
+		}
+		return nil
+	}
+	if err := walk(0, destPath); err != nil {
+		return err
+	}
+
+	logger.Info("imagem FAT importada", fs.imageAttr(), "fat", volume.bits, "files", files, "directories", directories)
+	fmt.Printf(tr("Importação de FAT%d concluída: %d arquivo(s), %d diretório(s), %d entrada(s) ignorada(s).\n"),
+		volume.bits, files, directories, skipped)
+	return nil
+}
+
+// cliImportFAT implementa "import-fat <imagem-fat> [destino]".
+func cliImportFAT(fs *FURGFileSystem, args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs import-fat <imagem-fat> [destino]")
+	}
+	destPath := "/"
+	if len(args) == 2 {
+		destPath = args[1]
+	}
+
+	f, err := os.Open(args[0])
+	if errors.Is(err, os.ErrNotExist) {
+		return classErrorf(ErrNotFound, "erro: o arquivo '%s' não existe", args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo: %w", err)
+	}
+	defer f.Close()
+	if err := fs.ImportFATImage(f, destPath); err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
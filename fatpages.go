@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// A FAT de um volume grande (a partir de lazyFATBytes na imagem) não é lida inteira na carga: a imagem é
+// aberta só com o cabeçalho e o diretório, e a FAT é lida em trechos de fatChunk bytes na primeira vez que
+// uma entrada deles é usada (veja fatEntry). Quem percorre a FAT inteira, como o fsck, usa fatEntries, que
+// lê o que faltar de uma vez. Só uma entrada lida pode ser alterada, e as páginas dos trechos não lidos
+// nunca são gravadas; um volume pouco usado abre rápido e só ocupa memória com a parte da FAT usada.
+//
+// fatChunk é múltiplo de metadataPage e do tamanho de uma entrada, e os trechos são contados a partir do
+// início da imagem: cada página dos metadados pertence a um só trecho. Uma entrada que cruza a borda entre
+// dois trechos só é decodificada quando os dois foram lidos.
+const (
+	fatChunk     = 9 * metadataPage
+	lazyFATBytes = 1 << 20
+)
+
+// fatRegion retorna o início e o fim da FAT na imagem.
+func (fs *FURGFileSystem) fatRegion() (int, int) {
+	start := binary.Size(fs.Header)
+	return start, start + len(fs.FAT)*fatEntryBytes
+}
+
+// startLazyFAT lê o diretório da imagem e prepara a leitura da FAT por trechos. fs.rawMeta já tem o
+// tamanho dos metadados. Os trechos das bordas da FAT, que dividem páginas com o cabeçalho e o diretório,
+// são lidos logo.
+func (fs *FURGFileSystem) startLazyFAT() error {
+	start, end := fs.fatRegion()
+	if _, err := binary.Encode(fs.rawMeta[:start], binary.LittleEndian, fs.Header); err != nil {
+		return err
+	}
+	dir := fs.rawMeta[end:]
+	if n, err := fs.FilePointer.ReadAt(dir, int64(end)); n < len(dir) {
+		return classErrorf(ErrCorrupted, "erro ao ler o diretório raiz: %v", err)
+	}
+	if err := binary.Read(bytes.NewReader(dir), binary.LittleEndian, fs.RootDir); err != nil {
+		return classErrorf(ErrCorrupted, "erro ao ler o diretório raiz: %v", err)
+	}
+	fs.fatLoaded = make([]bool, (end+fatChunk-1)/fatChunk)
+	fs.fatUnloaded = len(fs.fatLoaded)
+	if err := fs.loadFATChunks(start/fatChunk, start/fatChunk); err != nil {
+		return err
+	}
+	if last := (end - 1) / fatChunk; fs.fatLoaded != nil && !fs.fatLoaded[last] {
+		return fs.loadFATChunks(last, last)
+	}
+	return nil
+}
+
+// loadFATChunks lê os trechos de first a last, ainda não lidos, e decodifica as entradas que ficaram
+// inteiras na memória.
+func (fs *FURGFileSystem) loadFATChunks(first, last int) error {
+	start, end := fs.fatRegion()
+	lo, hi := max(first*fatChunk, start), min((last+1)*fatChunk, end)
+	if n, err := fs.FilePointer.ReadAt(fs.rawMeta[lo:hi], int64(lo)); n < hi-lo {
+		return classErrorf(ErrCorrupted, "erro ao ler a FAT: %v", err)
+	}
+	for c := first; c <= last; c++ {
+		fs.fatLoaded[c] = true
+	}
+	fs.fatUnloaded -= last - first + 1
+	for j := (lo - start) / fatEntryBytes; j <= (hi-start-1)/fatEntryBytes; j++ {
+		off := start + j*fatEntryBytes
+		if fs.fatDecoded(off) {
+			decodeFAT(fs.FAT[j:j+1], fs.rawMeta[off:])
+		}
+	}
+	logger.Debug("trecho da FAT lido", fs.imageAttr(), "first", first, "last", last, "unloaded", fs.fatUnloaded)
+	if fs.fatUnloaded == 0 {
+		fs.fatLoaded, fs.rawMeta = nil, nil
+	}
+	return nil
+}
+
+// fatEntry retorna a entrada da FAT do bloco blockID, lendo seu trecho se preciso. Um erro de leitura fica
+// em fs.fatErr, que impede novas reservas e o salvamento.
+func (fs *FURGFileSystem) fatEntry(blockID uint32) *FATEntry {
+	if fs.fatLoaded != nil {
+		start, _ := fs.fatRegion()
+		off := start + int(blockID)*fatEntryBytes
+		for _, c := range [2]int{off / fatChunk, (off + fatEntryBytes - 1) / fatChunk} {
+			if fs.fatLoaded != nil && !fs.fatLoaded[c] && fs.fatErr == nil {
+				fs.failFAT(fs.loadFATChunks(c, c))
+			}
+		}
+	}
+	return &fs.FAT[blockID]
+}
+
+// fatEntries lê o que falta da FAT e a retorna inteira, para as varreduras.
+func (fs *FURGFileSystem) fatEntries() []FATEntry {
+	for c := 0; fs.fatLoaded != nil && c < len(fs.fatLoaded) && fs.fatErr == nil; {
+		if fs.fatLoaded[c] {
+			c++
+			continue
+		}
+		first := c
+		for c < len(fs.fatLoaded) && !fs.fatLoaded[c] {
+			c++
+		}
+		fs.failFAT(fs.loadFATChunks(first, c-1))
+	}
+	return fs.FAT
+}
+
+func (fs *FURGFileSystem) failFAT(err error) {
+	if err != nil {
+		logger.Error("falha ao ler a FAT", fs.imageAttr(), "error", err)
+		fs.fatErr = err
+	}
+}
+
+// fatDecoded informa se a entrada da FAT que começa no byte off da imagem está decodificada: se os trechos
+// em que ela fica foram lidos.
+func (fs *FURGFileSystem) fatDecoded(off int) bool {
+	return fs.fatLoaded == nil || fs.fatLoaded[off/fatChunk] && fs.fatLoaded[(off+fatEntryBytes-1)/fatChunk]
+}
+
+// imageHolds informa se a imagem tem pelo menos size bytes.
+func imageHolds(f imageStore, size int) bool {
+	info, err := f.Stat()
+	return err == nil && info.Size() >= int64(size)
+}
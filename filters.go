@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// internalFile localiza o arquivo (não diretório) arg, relativo ao diretório atual.
+func (fs *FURGFileSystem) internalFile(arg string) (*FileEntry, error) {
+	dir, name := splitPath(fs.resolvePath(arg))
+	index := fs.lookupEntry(name, dir)
+	if index == -1 || fs.RootDir[index].IsDirectory {
+		return nil, classErrorf(ErrNotFound, "erro: O arquivo '%s' não foi encontrado no sistema de arquivos", arg)
+	}
+	return &fs.RootDir[index], nil
+}
+
+// cliCat implementa "cat [arquivo...]": escreve o conteúdo dos arquivos internos na saída padrão, lendo
+// um bloco por vez. Sem arquivos, copia a entrada padrão, o que permite usá-lo no meio de um pipeline.
+func cliCat(fs *FURGFileSystem, args []string) error {
+	if len(args) == 0 {
+		_, err := io.Copy(os.Stdout, os.Stdin)
+		return err
+	}
+	for _, arg := range args {
+		entry, err := fs.internalFile(arg)
+		if err != nil {
+			return err
+		}
+		r, err := fs.newFileReader(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(os.Stdout, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cliGrep implementa "grep [-i] [-v] [-n] [-c] <padrão> [arquivo...]": exibe as linhas que casam com a
+// expressão regular, lidas dos arquivos internos ou, sem arquivos, da entrada padrão.
+func cliGrep(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("grep", flag.ContinueOnError)
+	ignoreCase := flags.Bool("i", false, "ignora maiúsculas e minúsculas")
+	invert := flags.Bool("v", false, "exibe as linhas que não casam")
+	lineNumbers := flags.Bool("n", false, "mostra o número de cada linha")
+	count := flags.Bool("c", false, "mostra apenas a quantidade de linhas")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() < 1 {
+		return classErrorf(ErrUsage, "uso: furgfs grep [-i] [-v] [-n] [-c] <padrão> [arquivo...]")
+	}
+
+	pattern := flags.Arg(0)
+	if *ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return classErrorf(ErrUsage, "erro: padrão inválido: %v", err)
+	}
+
+	match := func(r io.Reader, prefix string) error {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		matches := 0
+		for n := 1; scanner.Scan(); n++ {
+			if re.MatchString(scanner.Text()) == *invert {
+				continue
+			}
+			matches++
+			switch {
+			case *count:
+			case *lineNumbers:
+				fmt.Printf("%s%d:%s\n", prefix, n, scanner.Text())
+			default:
+				fmt.Printf("%s%s\n", prefix, scanner.Text())
+			}
+		}
+		if *count {
+			fmt.Printf("%s%d\n", prefix, matches)
+		}
+		return scanner.Err()
+	}
+
+	files := flags.Args()[1:]
+	if len(files) == 0 {
+		return match(os.Stdin, "")
+	}
+	for _, arg := range files {
+		entry, err := fs.internalFile(arg)
+		if err != nil {
+			return err
+		}
+		r, err := fs.newFileReader(entry)
+		if err != nil {
+			return err
+		}
+		prefix := ""
+		if len(files) > 1 {
+			prefix = entry.FullPath() + ":"
+		}
+		if err := match(r, prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cliFind implementa "find [-type f|d] [--hash <sha256>] [padrão] [diretório]": exibe, um por linha, os
+// caminhos das entradas abaixo do diretório (o atual, por padrão) cujo nome casa com o padrão glob e,
+// com --hash, cujo conteúdo tem o SHA-256 indicado.
+func cliFind(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("find", flag.ContinueOnError)
+	kind := flags.String("type", "", "f para arquivos, d para diretórios")
+	hash := flags.String("hash", "", "só os arquivos com este SHA-256")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() > 2 || (*kind != "" && *kind != "f" && *kind != "d") {
+		return classErrorf(ErrUsage, "uso: furgfs find [-type f|d] [--hash <sha256>] [padrão] [diretório]")
+	}
+	*hash = strings.ToLower(*hash)
+	if _, err := hex.DecodeString(*hash); err != nil || *hash != "" && len(*hash) != 2*sha256.Size {
+		return classErrorf(ErrUsage, "erro: hash '%s' inválido; use os 64 dígitos hexadecimais do SHA-256", *hash)
+	}
+	pattern := "*"
+	if flags.NArg() >= 1 {
+		pattern = flags.Arg(0)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return classErrorf(ErrUsage, "erro: padrão inválido: %v", err)
+	}
+	dir := fs.resolvePath(flags.Arg(1))
+	if fs.CheckDirectoryExists(dir) == -1 {
+		return classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", dir)
+	}
+
+	var found []string
+	if *hash != "" {
+		// Os arquivos com o hash vêm do índice de metadados, se houver, e só então são filtrados
+		paths, err := fs.FilesWithHash(*hash)
+		if err != nil {
+			return err
+		}
+		for _, p := range paths {
+			parent, name := splitPath(p)
+			matched, _ := path.Match(pattern, name)
+			if matched && *kind != "d" && (dir == "/" || parent == dir || strings.HasPrefix(parent, dir+"/")) {
+				found = append(found, p)
+			}
+		}
+	} else {
+		for _, i := range fs.entriesInDirectory(dir, true) {
+			entry := &fs.RootDir[i]
+			if (*kind == "f" && entry.IsDirectory) || (*kind == "d" && !entry.IsDirectory) {
+				continue
+			}
+			if matched, _ := path.Match(pattern, entry.NameString()); matched {
+				found = append(found, entry.FullPath())
+			}
+		}
+	}
+	sort.Strings(found)
+	for _, p := range found {
+		fmt.Println(p)
+	}
+	return nil
+}
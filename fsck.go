@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Modos de operação da verificação de consistência.
+const (
+	fsckCheckOnly   = iota // apenas relata os problemas
+	fsckInteractive        // pergunta antes de cada reparo
+	fsckAutomatic          // repara tudo sem perguntar
+)
+
+// CheckFileSystem verifica o checksum do cabeçalho e a consistência do sistema de arquivos (veja checkConsistency),
+// exibindo cada problema.
+// Conforme o modo, os problemas são apenas relatados, reparados após confirmação ou reparados automaticamente.
+// Retorna o número de problemas encontrados e o número de problemas reparados.
+func (fs *FURGFileSystem) CheckFileSystem(mode int) (int, int) {
+	found, repaired := 0, 0
+	report := func(problem string) bool {
+		found++
+		fmt.Println("Problema:", problem)
+		logger.Warn("fsck: problema encontrado", fs.imageAttr(), "problem", problem)
+		switch mode {
+		case fsckAutomatic:
+		case fsckInteractive:
+			answer, _ := console.ReadLine("  Reparar? (s/n): ")
+			if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "s") {
+				return false
+			}
+		default:
+			return false
+		}
+		repaired++
+		logger.Info("fsck: problema reparado", fs.imageAttr(), "problem", problem)
+		return true
+	}
+
+	// O checksum é recalculado sempre que o estado é salvo, então reparar significa apenas aceitar o cabeçalho atual
+	if _, stored, err := readHeader(fs.FilePointer); err == nil && stored != 0 && stored != headerChecksum(fs.Header) {
+		report(fmt.Sprintf("o checksum do cabeçalho (%08x) não confere com o calculado (%08x)", stored, headerChecksum(fs.Header)))
+	}
+	fs.checkConsistency(report)
+
+	if found == 0 {
+		fmt.Println("Nenhum problema encontrado.")
+	} else {
+		fmt.Printf("%d problema(s) encontrado(s), %d reparado(s).\n", found, repaired)
+	}
+	return found, repaired
+}
+
+// checkConsistency verifica a integridade das cadeias da FAT (sem ciclos, sem blocos compartilhados entre
+// arquivos, sem blocos livres ou inexistentes), o tamanho registrado no diretório contra o comprimento da
+// cadeia e o espaço livre do cabeçalho. No armazenamento por conteúdo, a cadeia é o manifesto, e os blocos de
+// dados que ele referencia, que podem ser compartilhados, também precisam estar em uso. Cada problema é passado
+// a report, e o reparo correspondente só é feito se report retornar verdadeiro; com report sempre falso, nada
+// é alterado.
+func (fs *FURGFileSystem) checkConsistency(report func(problem string) bool) {
+	blockSize := fs.Header.BlockSize
+	owner := make(map[uint32]int) // bloco -> índice da entrada que o referencia
+	var manifests []int           // entradas guardadas por conteúdo com a cadeia do manifesto íntegra
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 {
+			continue
+		}
+
+		parentPath := entry.PathString()
+		if fs.CheckDirectoryExists(parentPath) == -1 {
+			report(fmt.Sprintf("'%s' está em um diretório pai inexistente", entry.FullPath()))
+		}
+
+		if entry.IsDirectory || entry.Size == 0 {
+			continue
+		}
+
+		length := entry.Size
+		cas := fs.isCASFile(entry)
+		if cas {
+			length = fs.manifestSize(entry.Size)
+		}
+		expected := (length + blockSize - 1) / blockSize
+		var chain []uint32
+		var previous uint32
+		var problem string
+		currentBlockID := entry.FirstBlockID
+		for {
+			if int(currentBlockID) >= len(fs.FAT) {
+				problem = fmt.Sprintf("aponta para o bloco %d, fora da FAT", currentBlockID)
+			} else if !fs.fatEntry(currentBlockID).Used {
+				problem = fmt.Sprintf("usa o bloco %d, marcado como livre", currentBlockID)
+			} else if other, ok := owner[currentBlockID]; ok && other == i {
+				problem = fmt.Sprintf("tem um ciclo na cadeia no bloco %d", currentBlockID)
+			} else if ok {
+				problem = fmt.Sprintf("compartilha o bloco %d com '%s'", currentBlockID, fs.RootDir[other].FullPath())
+			} else if uint32(len(chain)) == expected {
+				problem = fmt.Sprintf("tem uma cadeia maior que os %d blocos do tamanho registrado", expected)
+			}
+			if problem != "" {
+				break
+			}
+
+			owner[currentBlockID] = i
+			chain = append(chain, currentBlockID)
+			previous = currentBlockID
+			currentBlockID = fs.fatEntry(currentBlockID).NextBlockID
+			if currentBlockID == 0 {
+				break
+			}
+		}
+
+		if problem != "" {
+			if report(fmt.Sprintf("'%s' %s", entry.FullPath(), problem)) {
+				// A cadeia é cortada no último bloco válido e o tamanho ajustado ao que restou
+				if len(chain) == 0 {
+					entry.FirstBlockID = 0
+					entry.Size = 0
+				} else {
+					fs.modifyFAT(previous).NextBlockID = 0
+					entry.Size = min(entry.Size, fs.chainCapacity(len(chain), cas))
+				}
+				fs.markEntryDirty(i)
+			}
+		} else if uint32(len(chain)) < expected {
+			if report(fmt.Sprintf("'%s' registra %d bytes, mas sua cadeia tem apenas %d bloco(s)", entry.FullPath(), entry.Size, len(chain))) {
+				entry.Size = fs.chainCapacity(len(chain), cas)
+				fs.markEntryDirty(i)
+			}
+		}
+		if cas && entry.Size > 0 {
+			if _, err := fs.chainBlocks(entry, fs.manifestSize(entry.Size)); err == nil {
+				manifests = append(manifests, i)
+			}
+		}
+	}
+
+	// Os blocos de dados são conferidos depois de conhecidas todas as cadeias, que eles não podem integrar
+	shared := make(map[uint32]bool)
+	for _, i := range manifests {
+		entry := &fs.RootDir[i]
+		refs, err := fs.casManifest(entry)
+		if err != nil {
+			report(fmt.Sprintf("'%s' tem um manifesto ilegível: %v", entry.FullPath(), err))
+			continue
+		}
+		for k, ref := range refs {
+			problem := ""
+			if other, ok := owner[ref.Block]; ok {
+				problem = fmt.Sprintf("usa o bloco de dados %d, que pertence à cadeia de '%s'", ref.Block, fs.RootDir[other].FullPath())
+			} else if !fs.fatEntry(ref.Block).Used {
+				problem = fmt.Sprintf("usa o bloco de dados %d, marcado como livre", ref.Block)
+			}
+			if problem == "" {
+				shared[ref.Block] = true
+				continue
+			}
+			if report(fmt.Sprintf("'%s' %s", entry.FullPath(), problem)) {
+				// O arquivo é cortado antes do trecho perdido, com um manifesto novo
+				for _, blockID := range fs.truncateCASFile(i, refs[:k]) {
+					owner[blockID] = i
+				}
+			}
+			break
+		}
+	}
+
+	freeBlocks := 0
+	var orphans []uint32
+	pinned := fs.snapshotBlocks()
+	for i := range fs.fatEntries() {
+		fatEntry := &fs.FAT[i]
+		if !fatEntry.Used {
+			freeBlocks++
+			continue
+		}
+		if fatEntry.BlockID != uint32(i) {
+			if report(fmt.Sprintf("a entrada %d da FAT registra o identificador %d", i, fatEntry.BlockID)) {
+				fs.modifyFAT(uint32(i)).BlockID = uint32(i)
+			}
+		}
+		if _, ok := owner[uint32(i)]; !ok && !pinned[uint32(i)] && !shared[uint32(i)] {
+			orphans = append(orphans, uint32(i))
+		}
+	}
+	if len(orphans) > 0 {
+		if report(fmt.Sprintf("%d bloco(s) marcados como usados não pertencem a nenhum arquivo", len(orphans))) {
+			// Cadeias completas viram arquivos em /lost+found; o restante é liberado
+			recovered, remaining, err := fs.recoverOrphanChains(orphans)
+			if err != nil {
+				fmt.Println("  Erro ao reconstruir cadeias órfãs:", err)
+			}
+			if recovered > 0 {
+				fmt.Printf("  %d cadeia(s) órfã(s) recuperada(s) em /%s.\n", recovered, lostAndFoundName)
+			}
+			fs.freeBlocks(remaining)
+			freeBlocks += len(remaining)
+		}
+	}
+
+	expectedFreeSpace := uint32(freeBlocks) * blockSize
+	if fs.Header.FreeSpace != expectedFreeSpace {
+		if report(fmt.Sprintf("o cabeçalho registra %d bytes livres, mas há %d blocos livres (%d bytes)", fs.Header.FreeSpace, freeBlocks, expectedFreeSpace)) {
+			fs.Header.FreeSpace = expectedFreeSpace
+		}
+	}
+	if fs.cas != nil {
+		fs.cas.index = nil
+	}
+}
@@ -0,0 +1,31 @@
+package furgfspb
+
+import (
+	"context"
+	"encoding/base64"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// BasicAuth autentica as chamadas com um usuário cadastrado em "furgfs user":
+//
+//	conn, err := grpc.NewClient("localhost:9090",
+//		grpc.WithTransportCredentials(insecure.NewCredentials()),
+//		grpc.WithPerRPCCredentials(furgfspb.BasicAuth("ana", "segredo")))
+//	client := furgfspb.NewFileSystemClient(conn)
+func BasicAuth(user, password string) credentials.PerRPCCredentials {
+	return basicAuth{"Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))}
+}
+
+type basicAuth struct {
+	header string
+}
+
+func (b basicAuth) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": b.header}, nil
+}
+
+// RequireTransportSecurity permite o uso sem TLS, como o servidor de "furgfs serve grpc".
+func (b basicAuth) RequireTransportSecurity() bool {
+	return false
+}
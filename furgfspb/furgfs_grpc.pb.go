@@ -0,0 +1,650 @@
+// Serviço gRPC do FURGfs2, servido por "furgfs serve grpc". Os caminhos são absolutos no volume; o
+// diretório de sistema /.furgfs não é acessível. Cada chamada é autenticada por HTTP Basic no metadado
+// "authorization", com os usuários cadastrados em "furgfs user" (veja BasicAuth); usuários somente
+// leitura só podem usar Stat, List, Download e FreeSpace.
+//
+// Para regenerar o código Go, veja generate.go.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: furgfspb/furgfs.proto
+
+package furgfspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	FileSystem_Stat_FullMethodName         = "/furgfs.v1.FileSystem/Stat"
+	FileSystem_List_FullMethodName         = "/furgfs.v1.FileSystem/List"
+	FileSystem_Download_FullMethodName     = "/furgfs.v1.FileSystem/Download"
+	FileSystem_Upload_FullMethodName       = "/furgfs.v1.FileSystem/Upload"
+	FileSystem_Mkdir_FullMethodName        = "/furgfs.v1.FileSystem/Mkdir"
+	FileSystem_Remove_FullMethodName       = "/furgfs.v1.FileSystem/Remove"
+	FileSystem_Rename_FullMethodName       = "/furgfs.v1.FileSystem/Rename"
+	FileSystem_SetProtected_FullMethodName = "/furgfs.v1.FileSystem/SetProtected"
+	FileSystem_FreeSpace_FullMethodName    = "/furgfs.v1.FileSystem/FreeSpace"
+	FileSystem_StartUpload_FullMethodName  = "/furgfs.v1.FileSystem/StartUpload"
+	FileSystem_GetUpload_FullMethodName    = "/furgfs.v1.FileSystem/GetUpload"
+	FileSystem_SendChunks_FullMethodName   = "/furgfs.v1.FileSystem/SendChunks"
+	FileSystem_CommitUpload_FullMethodName = "/furgfs.v1.FileSystem/CommitUpload"
+	FileSystem_AbortUpload_FullMethodName  = "/furgfs.v1.FileSystem/AbortUpload"
+)
+
+// FileSystemClient is the client API for FileSystem service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FileSystemClient interface {
+	// Stat descreve um arquivo ou diretório.
+	Stat(ctx context.Context, in *StatRequest, opts ...grpc.CallOption) (*Entry, error)
+	// List lista um diretório, em ordem de nome.
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// Download envia o conteúdo de um arquivo em pedaços de até 64 KiB, cada um com a sua posição e o seu
+	// SHA-256; uma transferência interrompida continua com um novo Download a partir do último offset.
+	Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Chunk], error)
+	// Upload cria um arquivo ou substitui todo o seu conteúdo. A primeira mensagem traz o caminho e as
+	// seguintes, o conteúdo; o arquivo é gravado quando o cliente encerra o envio.
+	Upload(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadRequest, Entry], error)
+	// Mkdir cria um diretório.
+	Mkdir(ctx context.Context, in *MkdirRequest, opts ...grpc.CallOption) (*Entry, error)
+	// Remove apaga um arquivo ou diretório vazio; com recursive, também o conteúdo do diretório.
+	Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error)
+	// Rename renomeia ou move uma entrada; o destino não pode existir.
+	Rename(ctx context.Context, in *RenameRequest, opts ...grpc.CallOption) (*Entry, error)
+	// SetProtected liga ou desliga a proteção de um arquivo.
+	SetProtected(ctx context.Context, in *SetProtectedRequest, opts ...grpc.CallOption) (*Entry, error)
+	// FreeSpace informa o espaço e as entradas livres do volume.
+	FreeSpace(ctx context.Context, in *FreeSpaceRequest, opts ...grpc.CallOption) (*FreeSpaceResponse, error)
+	// StartUpload abre um envio retomável: o conteúdo chega em pedaços por SendChunks, fica guardado no
+	// servidor e sobrevive a conexões interrompidas, e o arquivo só é gravado no volume por CommitUpload.
+	StartUpload(ctx context.Context, in *StartUploadRequest, opts ...grpc.CallOption) (*UploadStatus, error)
+	// GetUpload informa quanto de um envio já foi recebido, para continuar de onde ele parou.
+	GetUpload(ctx context.Context, in *UploadRef, opts ...grpc.CallOption) (*UploadStatus, error)
+	// SendChunks acrescenta pedaços a um envio. A primeira mensagem traz o id; cada pedaço traz a posição,
+	// que deve ser o offset atual do envio, e opcionalmente o SHA-256 dos dados, conferido antes de guardá-los.
+	SendChunks(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadChunk, UploadStatus], error)
+	// CommitUpload grava o arquivo, depois de recebidos todos os bytes e conferido o SHA-256 do arquivo
+	// inteiro, se informado em StartUpload.
+	CommitUpload(ctx context.Context, in *UploadRef, opts ...grpc.CallOption) (*Entry, error)
+	// AbortUpload descarta um envio.
+	AbortUpload(ctx context.Context, in *UploadRef, opts ...grpc.CallOption) (*AbortUploadResponse, error)
+}
+
+type fileSystemClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFileSystemClient(cc grpc.ClientConnInterface) FileSystemClient {
+	return &fileSystemClient{cc}
+}
+
+func (c *fileSystemClient) Stat(ctx context.Context, in *StatRequest, opts ...grpc.CallOption) (*Entry, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Entry)
+	err := c.cc.Invoke(ctx, FileSystem_Stat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileSystemClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, FileSystem_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileSystemClient) Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Chunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FileSystem_ServiceDesc.Streams[0], FileSystem_Download_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DownloadRequest, Chunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileSystem_DownloadClient = grpc.ServerStreamingClient[Chunk]
+
+func (c *fileSystemClient) Upload(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadRequest, Entry], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FileSystem_ServiceDesc.Streams[1], FileSystem_Upload_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UploadRequest, Entry]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileSystem_UploadClient = grpc.ClientStreamingClient[UploadRequest, Entry]
+
+func (c *fileSystemClient) Mkdir(ctx context.Context, in *MkdirRequest, opts ...grpc.CallOption) (*Entry, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Entry)
+	err := c.cc.Invoke(ctx, FileSystem_Mkdir_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileSystemClient) Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RemoveResponse)
+	err := c.cc.Invoke(ctx, FileSystem_Remove_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileSystemClient) Rename(ctx context.Context, in *RenameRequest, opts ...grpc.CallOption) (*Entry, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Entry)
+	err := c.cc.Invoke(ctx, FileSystem_Rename_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileSystemClient) SetProtected(ctx context.Context, in *SetProtectedRequest, opts ...grpc.CallOption) (*Entry, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Entry)
+	err := c.cc.Invoke(ctx, FileSystem_SetProtected_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileSystemClient) FreeSpace(ctx context.Context, in *FreeSpaceRequest, opts ...grpc.CallOption) (*FreeSpaceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FreeSpaceResponse)
+	err := c.cc.Invoke(ctx, FileSystem_FreeSpace_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileSystemClient) StartUpload(ctx context.Context, in *StartUploadRequest, opts ...grpc.CallOption) (*UploadStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UploadStatus)
+	err := c.cc.Invoke(ctx, FileSystem_StartUpload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileSystemClient) GetUpload(ctx context.Context, in *UploadRef, opts ...grpc.CallOption) (*UploadStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UploadStatus)
+	err := c.cc.Invoke(ctx, FileSystem_GetUpload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileSystemClient) SendChunks(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadChunk, UploadStatus], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FileSystem_ServiceDesc.Streams[2], FileSystem_SendChunks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UploadChunk, UploadStatus]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileSystem_SendChunksClient = grpc.ClientStreamingClient[UploadChunk, UploadStatus]
+
+func (c *fileSystemClient) CommitUpload(ctx context.Context, in *UploadRef, opts ...grpc.CallOption) (*Entry, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Entry)
+	err := c.cc.Invoke(ctx, FileSystem_CommitUpload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileSystemClient) AbortUpload(ctx context.Context, in *UploadRef, opts ...grpc.CallOption) (*AbortUploadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AbortUploadResponse)
+	err := c.cc.Invoke(ctx, FileSystem_AbortUpload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FileSystemServer is the server API for FileSystem service.
+// All implementations must embed UnimplementedFileSystemServer
+// for forward compatibility.
+type FileSystemServer interface {
+	// Stat descreve um arquivo ou diretório.
+	Stat(context.Context, *StatRequest) (*Entry, error)
+	// List lista um diretório, em ordem de nome.
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	// Download envia o conteúdo de um arquivo em pedaços de até 64 KiB, cada um com a sua posição e o seu
+	// SHA-256; uma transferência interrompida continua com um novo Download a partir do último offset.
+	Download(*DownloadRequest, grpc.ServerStreamingServer[Chunk]) error
+	// Upload cria um arquivo ou substitui todo o seu conteúdo. A primeira mensagem traz o caminho e as
+	// seguintes, o conteúdo; o arquivo é gravado quando o cliente encerra o envio.
+	Upload(grpc.ClientStreamingServer[UploadRequest, Entry]) error
+	// Mkdir cria um diretório.
+	Mkdir(context.Context, *MkdirRequest) (*Entry, error)
+	// Remove apaga um arquivo ou diretório vazio; com recursive, também o conteúdo do diretório.
+	Remove(context.Context, *RemoveRequest) (*RemoveResponse, error)
+	// Rename renomeia ou move uma entrada; o destino não pode existir.
+	Rename(context.Context, *RenameRequest) (*Entry, error)
+	// SetProtected liga ou desliga a proteção de um arquivo.
+	SetProtected(context.Context, *SetProtectedRequest) (*Entry, error)
+	// FreeSpace informa o espaço e as entradas livres do volume.
+	FreeSpace(context.Context, *FreeSpaceRequest) (*FreeSpaceResponse, error)
+	// StartUpload abre um envio retomável: o conteúdo chega em pedaços por SendChunks, fica guardado no
+	// servidor e sobrevive a conexões interrompidas, e o arquivo só é gravado no volume por CommitUpload.
+	StartUpload(context.Context, *StartUploadRequest) (*UploadStatus, error)
+	// GetUpload informa quanto de um envio já foi recebido, para continuar de onde ele parou.
+	GetUpload(context.Context, *UploadRef) (*UploadStatus, error)
+	// SendChunks acrescenta pedaços a um envio. A primeira mensagem traz o id; cada pedaço traz a posição,
+	// que deve ser o offset atual do envio, e opcionalmente o SHA-256 dos dados, conferido antes de guardá-los.
+	SendChunks(grpc.ClientStreamingServer[UploadChunk, UploadStatus]) error
+	// CommitUpload grava o arquivo, depois de recebidos todos os bytes e conferido o SHA-256 do arquivo
+	// inteiro, se informado em StartUpload.
+	CommitUpload(context.Context, *UploadRef) (*Entry, error)
+	// AbortUpload descarta um envio.
+	AbortUpload(context.Context, *UploadRef) (*AbortUploadResponse, error)
+	mustEmbedUnimplementedFileSystemServer()
+}
+
+// UnimplementedFileSystemServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFileSystemServer struct{}
+
+func (UnimplementedFileSystemServer) Stat(context.Context, *StatRequest) (*Entry, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stat not implemented")
+}
+func (UnimplementedFileSystemServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedFileSystemServer) Download(*DownloadRequest, grpc.ServerStreamingServer[Chunk]) error {
+	return status.Errorf(codes.Unimplemented, "method Download not implemented")
+}
+func (UnimplementedFileSystemServer) Upload(grpc.ClientStreamingServer[UploadRequest, Entry]) error {
+	return status.Errorf(codes.Unimplemented, "method Upload not implemented")
+}
+func (UnimplementedFileSystemServer) Mkdir(context.Context, *MkdirRequest) (*Entry, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Mkdir not implemented")
+}
+func (UnimplementedFileSystemServer) Remove(context.Context, *RemoveRequest) (*RemoveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
+}
+func (UnimplementedFileSystemServer) Rename(context.Context, *RenameRequest) (*Entry, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rename not implemented")
+}
+func (UnimplementedFileSystemServer) SetProtected(context.Context, *SetProtectedRequest) (*Entry, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetProtected not implemented")
+}
+func (UnimplementedFileSystemServer) FreeSpace(context.Context, *FreeSpaceRequest) (*FreeSpaceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FreeSpace not implemented")
+}
+func (UnimplementedFileSystemServer) StartUpload(context.Context, *StartUploadRequest) (*UploadStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartUpload not implemented")
+}
+func (UnimplementedFileSystemServer) GetUpload(context.Context, *UploadRef) (*UploadStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUpload not implemented")
+}
+func (UnimplementedFileSystemServer) SendChunks(grpc.ClientStreamingServer[UploadChunk, UploadStatus]) error {
+	return status.Errorf(codes.Unimplemented, "method SendChunks not implemented")
+}
+func (UnimplementedFileSystemServer) CommitUpload(context.Context, *UploadRef) (*Entry, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitUpload not implemented")
+}
+func (UnimplementedFileSystemServer) AbortUpload(context.Context, *UploadRef) (*AbortUploadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AbortUpload not implemented")
+}
+func (UnimplementedFileSystemServer) mustEmbedUnimplementedFileSystemServer() {}
+func (UnimplementedFileSystemServer) testEmbeddedByValue()                    {}
+
+// UnsafeFileSystemServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FileSystemServer will
+// result in compilation errors.
+type UnsafeFileSystemServer interface {
+	mustEmbedUnimplementedFileSystemServer()
+}
+
+func RegisterFileSystemServer(s grpc.ServiceRegistrar, srv FileSystemServer) {
+	// If the following call pancis, it indicates UnimplementedFileSystemServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&FileSystem_ServiceDesc, srv)
+}
+
+func _FileSystem_Stat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileSystemServer).Stat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileSystem_Stat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileSystemServer).Stat(ctx, req.(*StatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileSystem_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileSystemServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileSystem_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileSystemServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileSystem_Download_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FileSystemServer).Download(m, &grpc.GenericServerStream[DownloadRequest, Chunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileSystem_DownloadServer = grpc.ServerStreamingServer[Chunk]
+
+func _FileSystem_Upload_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FileSystemServer).Upload(&grpc.GenericServerStream[UploadRequest, Entry]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileSystem_UploadServer = grpc.ClientStreamingServer[UploadRequest, Entry]
+
+func _FileSystem_Mkdir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MkdirRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileSystemServer).Mkdir(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileSystem_Mkdir_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileSystemServer).Mkdir(ctx, req.(*MkdirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileSystem_Remove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileSystemServer).Remove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileSystem_Remove_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileSystemServer).Remove(ctx, req.(*RemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileSystem_Rename_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileSystemServer).Rename(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileSystem_Rename_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileSystemServer).Rename(ctx, req.(*RenameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileSystem_SetProtected_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetProtectedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileSystemServer).SetProtected(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileSystem_SetProtected_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileSystemServer).SetProtected(ctx, req.(*SetProtectedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileSystem_FreeSpace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FreeSpaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileSystemServer).FreeSpace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileSystem_FreeSpace_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileSystemServer).FreeSpace(ctx, req.(*FreeSpaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileSystem_StartUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileSystemServer).StartUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileSystem_StartUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileSystemServer).StartUpload(ctx, req.(*StartUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileSystem_GetUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadRef)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileSystemServer).GetUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileSystem_GetUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileSystemServer).GetUpload(ctx, req.(*UploadRef))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileSystem_SendChunks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FileSystemServer).SendChunks(&grpc.GenericServerStream[UploadChunk, UploadStatus]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileSystem_SendChunksServer = grpc.ClientStreamingServer[UploadChunk, UploadStatus]
+
+func _FileSystem_CommitUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadRef)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileSystemServer).CommitUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileSystem_CommitUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileSystemServer).CommitUpload(ctx, req.(*UploadRef))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileSystem_AbortUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadRef)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileSystemServer).AbortUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileSystem_AbortUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileSystemServer).AbortUpload(ctx, req.(*UploadRef))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// FileSystem_ServiceDesc is the grpc.ServiceDesc for FileSystem service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FileSystem_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "furgfs.v1.FileSystem",
+	HandlerType: (*FileSystemServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Stat",
+			Handler:    _FileSystem_Stat_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _FileSystem_List_Handler,
+		},
+		{
+			MethodName: "Mkdir",
+			Handler:    _FileSystem_Mkdir_Handler,
+		},
+		{
+			MethodName: "Remove",
+			Handler:    _FileSystem_Remove_Handler,
+		},
+		{
+			MethodName: "Rename",
+			Handler:    _FileSystem_Rename_Handler,
+		},
+		{
+			MethodName: "SetProtected",
+			Handler:    _FileSystem_SetProtected_Handler,
+		},
+		{
+			MethodName: "FreeSpace",
+			Handler:    _FileSystem_FreeSpace_Handler,
+		},
+		{
+			MethodName: "StartUpload",
+			Handler:    _FileSystem_StartUpload_Handler,
+		},
+		{
+			MethodName: "GetUpload",
+			Handler:    _FileSystem_GetUpload_Handler,
+		},
+		{
+			MethodName: "CommitUpload",
+			Handler:    _FileSystem_CommitUpload_Handler,
+		},
+		{
+			MethodName: "AbortUpload",
+			Handler:    _FileSystem_AbortUpload_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Download",
+			Handler:       _FileSystem_Download_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Upload",
+			Handler:       _FileSystem_Upload_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "SendChunks",
+			Handler:       _FileSystem_SendChunks_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "furgfspb/furgfs.proto",
+}
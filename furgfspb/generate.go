@@ -0,0 +1,6 @@
+// Package furgfspb contém o código gerado a partir de furgfs.proto: as mensagens e o cliente e o servidor
+// gRPC do FURGfs2. O código gerado não deve ser editado; depois de alterar furgfs.proto, rode go generate
+// com protoc, protoc-gen-go e protoc-gen-go-grpc instalados.
+package furgfspb
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative furgfspb/furgfs.proto
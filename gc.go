@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// referencedBlocks marca os blocos alcançados pelas cadeias de todos os arquivos do diretório e os retidos pelos snapshots.
+// Retorna erro se alguma cadeia estiver corrompida, caso em que o fsck deve ser executado antes.
+func (fs *FURGFileSystem) referencedBlocks() ([]bool, error) {
+	referenced := make([]bool, len(fs.FAT))
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 {
+			continue
+		}
+		blocks, err := fs.entryBlocks(entry)
+		if err != nil {
+			return nil, err
+		}
+		for _, blockID := range blocks {
+			referenced[blockID] = true
+		}
+	}
+	for blockID := range fs.snapshotBlocks() {
+		referenced[blockID] = true
+	}
+	return referenced, nil
+}
+
+// orphanBlocks retorna os blocos marcados como usados na FAT que nenhuma entrada do diretório referencia,
+// como os deixados por uma cópia interrompida no meio.
+func (fs *FURGFileSystem) orphanBlocks() ([]uint32, error) {
+	referenced, err := fs.referencedBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("%w (execute o fsck antes)", err)
+	}
+
+	var orphans []uint32
+	for i, fatEntry := range fs.fatEntries() {
+		if fatEntry.Used && !referenced[i] {
+			orphans = append(orphans, uint32(i))
+		}
+	}
+	return orphans, nil
+}
+
+// CollectOrphanBlocks libera os blocos órfãos e devolve seu espaço ao espaço livre do cabeçalho.
+// Com dryRun, apenas informa quantos blocos seriam recuperados. Retorna o número de blocos órfãos encontrados.
+func (fs *FURGFileSystem) CollectOrphanBlocks(dryRun bool) (int, error) {
+	orphans, err := fs.orphanBlocks()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(orphans) == 0 {
+		fmt.Println("Nenhum bloco órfão encontrado.")
+		return 0, nil
+	}
+
+	recovered := uint64(len(orphans)) * uint64(fs.Header.BlockSize)
+	if dryRun {
+		fmt.Printf("%d bloco(s) órfão(s) encontrados (%d bytes), nada foi alterado.\n", len(orphans), recovered)
+		return len(orphans), nil
+	}
+
+	err = fs.confirm("%d bloco(s) órfão(s) serão liberados e seu conteúdo não poderá ser recuperado.", len(orphans))
+	if err != nil {
+		return 0, err
+	}
+
+	fs.freeBlocks(orphans)
+	fmt.Printf("%d bloco(s) órfão(s) liberados (%d bytes recuperados).\n", len(orphans), recovered)
+	return len(orphans), nil
+}
+
+// Diretório onde o fsck reconstrói as cadeias órfãs recuperadas.
+const lostAndFoundName = "lost+found"
+
+// recoverOrphanChains procura, entre os blocos órfãos, cadeias completas (começam em um bloco que nenhum outro
+// órfão aponta e terminam com NextBlockID 0) e as reconstrói como arquivos "#<primeiro bloco>" em /lost+found.
+// Como a FAT não guarda o tamanho, ele é estimado pelo número de blocos, desconsiderando os bytes nulos
+// no fim do último bloco. Retorna o número de arquivos recuperados e os blocos que não puderam ser aproveitados.
+func (fs *FURGFileSystem) recoverOrphanChains(orphans []uint32) (int, []uint32, error) {
+	// No armazenamento por conteúdo, uma cadeia órfã seria um manifesto, e não o conteúdo de um arquivo
+	if fs.casVolume() {
+		return 0, orphans, nil
+	}
+	isOrphan := make(map[uint32]bool, len(orphans))
+	for _, blockID := range orphans {
+		isOrphan[blockID] = true
+	}
+	pointed := make(map[uint32]bool, len(orphans))
+	for _, blockID := range orphans {
+		if next := fs.fatEntry(blockID).NextBlockID; next != 0 {
+			pointed[next] = true
+		}
+	}
+
+	claimed := make(map[uint32]bool, len(orphans))
+	var chains [][]uint32
+	for _, head := range orphans {
+		if pointed[head] {
+			continue
+		}
+		chain := []uint32{head}
+		complete := true
+		for next := fs.fatEntry(head).NextBlockID; next != 0; next = fs.fatEntry(next).NextBlockID {
+			if int(next) >= len(fs.FAT) || !isOrphan[next] || claimed[next] || len(chain) > len(orphans) {
+				complete = false
+				break
+			}
+			chain = append(chain, next)
+			claimed[next] = true
+		}
+		claimed[head] = true
+		if complete {
+			chains = append(chains, chain)
+		}
+	}
+
+	recovered := 0
+	inChain := make(map[uint32]bool)
+	if len(chains) > 0 && fs.CheckDirectoryExists("/"+lostAndFoundName) == -1 {
+		err := fs.CreateDirectory(lostAndFoundName, "/", false)
+		if err != nil {
+			return 0, orphans, err
+		}
+	}
+	buf := make([]byte, fs.Header.BlockSize)
+	for _, chain := range chains {
+		last := chain[len(chain)-1]
+		n, err := fs.readBlock(last, buf)
+		if err != nil {
+			return recovered, nil, err
+		}
+		size := uint32(len(chain)-1)*fs.Header.BlockSize + uint32(len(bytes.TrimRight(buf[:n], "\x00")))
+
+		var nameArray [32]byte
+		copy(nameArray[:], fmt.Sprintf("#%d", chain[0]))
+
+		var pathArray [128]byte
+		copy(pathArray[:], "/"+lostAndFoundName)
+
+		err = fs.AddFileEntry(&FileEntry{Name: nameArray, Path: pathArray, Size: size, FirstBlockID: chain[0]})
+		if err != nil {
+			break
+		}
+		for _, blockID := range chain {
+			inChain[blockID] = true
+		}
+		recovered++
+	}
+
+	var remaining []uint32
+	for _, blockID := range orphans {
+		if !inChain[blockID] {
+			remaining = append(remaining, blockID)
+		}
+	}
+	return recovered, remaining, nil
+}
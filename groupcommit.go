@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// Por padrão, um volume compartilhado (mount e serve) grava o diário e os metadados ao fim de cada
+// operação. Com um intervalo em commitDelay, a primeira alteração abre um lote e as seguintes só o
+// aumentam: ao fim do intervalo, saveFileSystemState registra no diário as alterações do lote inteiro e
+// grava as páginas alteradas da FAT e do diretório de uma vez. Uma rajada de operações, como a cópia de
+// muitos arquivos pequenos, faz assim uma só gravação dos metadados. Os blocos de dados continuam sendo
+// gravados na hora; uma queda do processo perde, no máximo, as alterações do lote em aberto, e a imagem
+// continua consistente, já que os blocos não referenciados pelos metadados gravados ficam livres.
+var commitDelay time.Duration
+
+func init() {
+	// Sem --commit-delay, FURGFS_COMMIT_DELAY (como "200ms") liga os lotes
+	commitDelay, _ = time.ParseDuration(os.Getenv("FURGFS_COMMIT_DELAY"))
+}
+
+// commit grava o estado do volume depois de uma alteração, ou a junta ao lote em aberto. Deve ser chamada
+// com o volume bloqueado.
+func (v *volumeState) commit() error {
+	if commitDelay <= 0 {
+		return v.fs.saveFileSystemState()
+	}
+	v.batched++
+	if v.commitTimer == nil {
+		v.commitTimer = time.AfterFunc(commitDelay, func() {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+			// Um erro aqui não tem a quem ser devolvido; o próximo salvamento tenta gravar tudo de novo
+			if err := v.commitBatch(); err != nil {
+				logger.Error("falha ao gravar o lote de alterações", v.fs.imageAttr(), "error", err)
+			}
+		})
+	}
+	return nil
+}
+
+// commitBatch grava o lote em aberto, se houver. Deve ser chamada com o volume bloqueado.
+func (v *volumeState) commitBatch() error {
+	if v.commitTimer == nil {
+		return nil
+	}
+	v.commitTimer.Stop()
+	v.commitTimer = nil
+	if err := v.fs.saveFileSystemState(); err != nil {
+		return err
+	}
+	logger.Debug("lote de alterações gravado", v.fs.imageAttr(), "operations", v.batched)
+	v.batched = 0
+	return nil
+}
+
+// flushBatch grava o lote em aberto antes de o volume ser fechado.
+func (v *volumeState) flushBatch() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.commitBatch()
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"io"
+	iofs "io/fs"
+	"os"
+	"strings"
+
+	"FURGFS2/furgfspb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcChunkSize é o tamanho máximo de cada pedaço enviado por Download.
+const grpcChunkSize = 64 * 1024
+
+// grpcReadOnly são as chamadas permitidas aos usuários somente leitura.
+var grpcReadOnly = map[string]bool{
+	furgfspb.FileSystem_Stat_FullMethodName:      true,
+	furgfspb.FileSystem_List_FullMethodName:      true,
+	furgfspb.FileSystem_Download_FullMethodName:  true,
+	furgfspb.FileSystem_FreeSpace_FullMethodName: true,
+}
+
+// grpcServer implementa o serviço de furgfspb/furgfs.proto sobre um sharedVolume.
+type grpcServer struct {
+	furgfspb.UnimplementedFileSystemServer
+	vol   *sharedVolume
+	users map[string]VolumeUser
+}
+
+// grpcShare adapta *grpc.Server a shareServer.
+type grpcShare struct {
+	*grpc.Server
+}
+
+func (g grpcShare) Close() error {
+	g.Stop()
+	return nil
+}
+
+// setupGRPC prepara "serve grpc".
+func setupGRPC(flags *flag.FlagSet) func(vol *sharedVolume) (shareServer, error) {
+	return func(vol *sharedVolume) (shareServer, error) {
+		users, err := serveUsers(vol)
+		if err != nil {
+			return nil, err
+		}
+		s := &grpcServer{vol: vol, users: users}
+		server := grpc.NewServer(grpc.UnaryInterceptor(s.unaryAuth), grpc.StreamInterceptor(s.streamAuth))
+		furgfspb.RegisterFileSystemServer(server, s)
+		return grpcShare{server}, nil
+	}
+}
+
+// authorize confere o usuário do metadado "authorization" e se ele pode fazer a chamada method, e retorna
+// ctx com o usuário anotado (veja withVolumeUser).
+func (s *grpcServer) authorize(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var name, password string
+	ok := false
+	if values := md.Get("authorization"); len(values) > 0 {
+		if encoded, found := strings.CutPrefix(values[0], "Basic "); found {
+			if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+				name, password, ok = strings.Cut(string(decoded), ":")
+			}
+		}
+	}
+	user, known := s.users[name]
+	if !ok || !known || !user.CheckPassword(password) {
+		logger.Warn("grpc: autenticação recusada", "user", name, "method", method)
+		return nil, status.Error(codes.Unauthenticated, "autenticação necessária")
+	}
+	if user.ReadOnly && !grpcReadOnly[method] {
+		return nil, status.Error(codes.PermissionDenied, "usuário somente leitura")
+	}
+	logger.Info("grpc", "user", name, "method", method)
+	return withVolumeUser(ctx, name), nil
+}
+
+func (s *grpcServer) unaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *grpcServer) streamAuth(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authorize(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, authorizedStream{stream, ctx})
+}
+
+// authorizedStream troca o contexto de um stream pelo contexto com o usuário autenticado.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a authorizedStream) Context() context.Context { return a.ctx }
+
+// grpcError converte err no status gRPC correspondente.
+func grpcError(err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, iofs.ErrNotExist), errors.Is(err, ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, iofs.ErrPermission), errors.Is(err, ErrProtected):
+		code = codes.PermissionDenied
+	case errors.Is(err, errNotEmpty):
+		// ENOTEMPTY também corresponde a fs.ErrExist; por isso é testado antes
+		code = codes.FailedPrecondition
+	case errors.Is(err, iofs.ErrExist):
+		code = codes.AlreadyExists
+	case errors.Is(err, errIsDirectory), errors.Is(err, errNotDir), errors.Is(err, ErrUsage):
+		code = codes.InvalidArgument
+	case errors.Is(err, ErrNoSpace):
+		code = codes.ResourceExhausted
+	case errors.Is(err, errUploadOffset):
+		code = codes.Aborted
+	case errors.Is(err, errChunkChecksum), errors.Is(err, ErrCorrupted):
+		code = codes.DataLoss
+	}
+	return status.Error(code, err.Error())
+}
+
+func grpcEntry(p string, info entryInfo) *furgfspb.Entry {
+	return &furgfspb.Entry{Path: p, Name: info.name, Directory: info.dir, Size: uint64(info.size), Protected: info.protected}
+}
+
+// entry descreve p depois de uma alteração.
+func (s *grpcServer) entry(p string) (*furgfspb.Entry, error) {
+	info, err := s.vol.Stat(p)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return grpcEntry(p, info), nil
+}
+
+func (s *grpcServer) Stat(ctx context.Context, req *furgfspb.StatRequest) (*furgfspb.Entry, error) {
+	return s.entry(normalizePath(req.Path))
+}
+
+func (s *grpcServer) List(ctx context.Context, req *furgfspb.ListRequest) (*furgfspb.ListResponse, error) {
+	p := normalizePath(req.Path)
+	infos, err := s.vol.ReadDir(p)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &furgfspb.ListResponse{}
+	for _, info := range infos {
+		resp.Entries = append(resp.Entries, grpcEntry(joinPath(p, info.name), info))
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) Download(req *furgfspb.DownloadRequest, stream grpc.ServerStreamingServer[furgfspb.Chunk]) error {
+	if req.Offset < 0 || req.Length < 0 {
+		return status.Error(codes.InvalidArgument, "offset e length não podem ser negativos")
+	}
+	f, err := s.vol.OpenFile(normalizePath(req.Path), os.O_RDONLY)
+	if err != nil {
+		return grpcError(err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		if err == nil {
+			err = errIsDirectory
+		}
+		return grpcError(err)
+	}
+
+	offset, remaining := req.Offset, req.Length
+	buf := make([]byte, grpcChunkSize)
+	for req.Length == 0 || remaining > 0 {
+		chunk := buf
+		if req.Length > 0 {
+			chunk = buf[:min(remaining, grpcChunkSize)]
+		}
+		n, err := f.ReadAt(chunk, offset)
+		if n > 0 {
+			sum := sha256.Sum256(chunk[:n])
+			if err := stream.Send(&furgfspb.Chunk{Data: chunk[:n], Offset: offset, Sha256: hex.EncodeToString(sum[:])}); err != nil {
+				return err
+			}
+			offset += int64(n)
+			remaining -= int64(n)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return grpcError(err)
+		}
+	}
+	return nil
+}
+
+func (s *grpcServer) Upload(stream grpc.ClientStreamingServer[furgfspb.UploadRequest, furgfspb.Entry]) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if first.GetPath() == "" {
+		return status.Error(codes.InvalidArgument, "a primeira mensagem deve trazer o caminho")
+	}
+	p := normalizePath(first.GetPath())
+	f, err := s.vol.userVolume(stream.Context()).OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return grpcError(err)
+	}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// O envio foi interrompido; o conteúdo recebido é descartado
+			return err
+		}
+		if _, err := f.Write(req.GetData()); err != nil {
+			return grpcError(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return grpcError(err)
+	}
+	entry, err := s.entry(p)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(entry)
+}
+
+func grpcUploadStatus(session uploadSession) *furgfspb.UploadStatus {
+	return &furgfspb.UploadStatus{Id: session.ID, Path: session.Path, Size: session.Size, Offset: session.Offset, Sha256: session.SHA256}
+}
+
+func (s *grpcServer) StartUpload(ctx context.Context, req *furgfspb.StartUploadRequest) (*furgfspb.UploadStatus, error) {
+	session, err := s.vol.userVolume(ctx).StartUpload(req.Path, req.Size, req.Sha256)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return grpcUploadStatus(session), nil
+}
+
+func (s *grpcServer) GetUpload(ctx context.Context, req *furgfspb.UploadRef) (*furgfspb.UploadStatus, error) {
+	session, err := s.vol.userVolume(ctx).Upload(req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return grpcUploadStatus(session), nil
+}
+
+// SendChunks guarda cada pedaço assim que ele chega: se a conexão cair, os pedaços já recebidos ficam no
+// envio, e o cliente continua a partir do offset informado por GetUpload.
+func (s *grpcServer) SendChunks(stream grpc.ClientStreamingServer[furgfspb.UploadChunk, furgfspb.UploadStatus]) error {
+	vol := s.vol.userVolume(stream.Context())
+	var id string
+	var session uploadSession
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if id == "" {
+			if id = req.Id; id == "" {
+				return status.Error(codes.InvalidArgument, "a primeira mensagem deve trazer o id do envio")
+			}
+		}
+		if len(req.Data) == 0 {
+			session, err = vol.Upload(id)
+		} else {
+			session, err = vol.AppendUpload(id, req.Offset, req.Data, req.Sha256)
+		}
+		if err != nil {
+			return grpcError(err)
+		}
+	}
+	if id == "" {
+		return status.Error(codes.InvalidArgument, "a primeira mensagem deve trazer o id do envio")
+	}
+	return stream.SendAndClose(grpcUploadStatus(session))
+}
+
+func (s *grpcServer) CommitUpload(ctx context.Context, req *furgfspb.UploadRef) (*furgfspb.Entry, error) {
+	p, err := s.vol.userVolume(ctx).CommitUpload(req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return s.entry(p)
+}
+
+func (s *grpcServer) AbortUpload(ctx context.Context, req *furgfspb.UploadRef) (*furgfspb.AbortUploadResponse, error) {
+	if err := s.vol.userVolume(ctx).AbortUpload(req.Id); err != nil {
+		return nil, grpcError(err)
+	}
+	return &furgfspb.AbortUploadResponse{}, nil
+}
+
+func (s *grpcServer) Mkdir(ctx context.Context, req *furgfspb.MkdirRequest) (*furgfspb.Entry, error) {
+	p := normalizePath(req.Path)
+	if err := s.vol.userVolume(ctx).Mkdir(p); err != nil {
+		return nil, grpcError(err)
+	}
+	return s.entry(p)
+}
+
+func (s *grpcServer) Remove(ctx context.Context, req *furgfspb.RemoveRequest) (*furgfspb.RemoveResponse, error) {
+	p := normalizePath(req.Path)
+	var err error
+	if req.Recursive {
+		err = s.vol.userVolume(ctx).RemoveAll(p)
+	} else {
+		err = s.vol.userVolume(ctx).Remove(p)
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &furgfspb.RemoveResponse{}, nil
+}
+
+func (s *grpcServer) Rename(ctx context.Context, req *furgfspb.RenameRequest) (*furgfspb.Entry, error) {
+	from, to := normalizePath(req.From), normalizePath(req.To)
+	if _, err := s.vol.Stat(to); err == nil {
+		return nil, grpcError(iofs.ErrExist)
+	}
+	if err := s.vol.userVolume(ctx).Rename(from, to); err != nil {
+		return nil, grpcError(err)
+	}
+	return s.entry(to)
+}
+
+func (s *grpcServer) SetProtected(ctx context.Context, req *furgfspb.SetProtectedRequest) (*furgfspb.Entry, error) {
+	p := normalizePath(req.Path)
+	if err := s.vol.userVolume(ctx).SetProtected(p, req.Protected); err != nil {
+		return nil, grpcError(err)
+	}
+	return s.entry(p)
+}
+
+func (s *grpcServer) FreeSpace(ctx context.Context, req *furgfspb.FreeSpaceRequest) (*furgfspb.FreeSpaceResponse, error) {
+	var info jsonFreeSpace
+	s.vol.with(func(fs *FURGFileSystem) error {
+		info = fs.freeSpaceInfo()
+		return nil
+	})
+	return &furgfspb.FreeSpaceResponse{
+		BlockSize:    info.BlockSize,
+		TotalBytes:   info.TotalBytes,
+		FreeBytes:    info.FreeBytes,
+		TotalEntries: uint32(info.TotalEntries),
+		UsedEntries:  uint32(info.UsedEntries),
+	}, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+)
+
+// setupHTTP prepara "serve http": os arquivos do volume são servidos somente para leitura, com o
+// Content-Type deduzido da extensão ou, sem ela, do início do conteúdo. Um diretório com index.html é
+// servido por ele; os demais são listados.
+func setupHTTP(flags *flag.FlagSet) func(vol *sharedVolume) (shareServer, error) {
+	return func(vol *sharedVolume) (shareServer, error) {
+		files := http.FileServerFS(volumeFS{vol})
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger.Info("http", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				w.Header().Set("Allow", "GET, HEAD")
+				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				return
+			}
+			files.ServeHTTP(w, r)
+		})
+		return &http.Server{Handler: handler}, nil
+	}
+}
@@ -0,0 +1,676 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Idiomas suportados. As mensagens são escritas em português no código e traduzidas por tr, que consulta
+// o catálogo do idioma atual; mensagens ainda sem tradução continuam em português.
+const (
+	langPortuguese = "pt"
+	langEnglish    = "en"
+)
+
+// currentLang é o idioma das mensagens, definido uma vez no início por setLanguage.
+var currentLang = langPortuguese
+
+// setLanguage define o idioma das mensagens. Aceita também nomes de locale como "en_US.UTF-8".
+func setLanguage(lang string) error {
+	switch normalized := strings.ToLower(lang); {
+	case strings.HasPrefix(normalized, langPortuguese):
+		currentLang = langPortuguese
+	case strings.HasPrefix(normalized, langEnglish):
+		currentLang = langEnglish
+	default:
+		return classErrorf(ErrUsage, "erro: linguagem '%s' não suportada (use pt ou en)", lang)
+	}
+	return nil
+}
+
+// resolveLanguage escolhe o idioma, nesta ordem: a opção --lang, a variável FURGFS_LANG, a chave "lang" da
+// configuração e as variáveis de locale do sistema. Locales de outros idiomas resultam em português.
+// Erros no arquivo de configuração são ignorados aqui e informados ao escolher a imagem.
+func resolveLanguage(flagValue string) error {
+	config, _ := loadConfig(configPath())
+	for _, candidate := range []string{flagValue, os.Getenv("FURGFS_LANG"), config.Lang} {
+		if candidate != "" {
+			return setLanguage(candidate)
+		}
+	}
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			if setLanguage(value) != nil {
+				currentLang = langPortuguese
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// tr traduz a mensagem s para o idioma atual. Para os formatos de fmt, a chave é o próprio formato, antes
+// da substituição dos argumentos. Mensagens "uso: furgfs <comando> ..." têm os argumentos traduzidos por
+// trUsage, assim as formas de uso não precisam ser repetidas no catálogo.
+func tr(s string) string {
+	if currentLang == langPortuguese {
+		return s
+	}
+	if translated, ok := englishMessages[s]; ok {
+		return translated
+	}
+	if rest, ok := strings.CutPrefix(s, "uso: "); ok {
+		return "usage: " + trUsage(rest)
+	}
+	return s
+}
+
+// usagePlaceholder encontra as palavras dos argumentos nas formas de uso, como <caminho-interno>.
+var usagePlaceholder = regexp.MustCompile(`[\p{L}-]+`)
+
+// trUsage traduz os nomes dos argumentos de uma forma de uso. Opções e nomes de comandos não mudam.
+func trUsage(form string) string {
+	if currentLang == langPortuguese {
+		return form
+	}
+	return usagePlaceholder.ReplaceAllStringFunc(form, func(word string) string {
+		if translated, ok := englishPlaceholders[word]; ok {
+			return translated
+		}
+		return word
+	})
+}
+
+// Nomes dos argumentos das formas de uso em inglês.
+var englishPlaceholders = map[string]string{
+	"diretório":       "directory",
+	"caminho-interno": "internal-path",
+	"arquivo-local":   "local-file",
+	"arquivo":         "file",
+	"origem":          "source",
+	"destino":         "destination",
+	"imagem":          "image",
+	"nova-imagem":     "new-image",
+	"outra":           "other",
+	"nome":            "name",
+	"número":          "number",
+	"rótulo":          "label",
+	"campo":           "field",
+	"valor":           "value",
+	"comando":         "command",
+	"assunto":         "topic",
+	"comandos":        "commands",
+	"padrão":          "pattern",
+	"tamanho":         "size",
+	"duração":         "duration",
+	"porcento":        "percent",
+}
+
+// englishMessages é o catálogo de mensagens em inglês, indexado pela mensagem original em português.
+var englishMessages = map[string]string{
+	"erro: o alias '%s' tem o nome de um comando":                                                "error: alias '%s' has the name of a command",
+	"erro: o alias '%s' chama a si mesmo":                                                        "error: alias '%s' calls itself",
+	"erro: alias '%s' não definido":                                                              "error: alias '%s' is not defined",
+	"Nenhum alias definido; use \"alias.<nome> = comando; comando\" no arquivo de configuração.": "No aliases defined; use \"alias.<name> = command; command\" in the configuration file.",
+	"lista os aliases da configuração ou mostra o corpo de um deles":                             "lists the configured aliases or shows the body of one of them",
+	"Um alias é definido no arquivo de configuração com \"alias.<nome> = comando; comando\", na sintaxe\ndos scripts, e é chamado como um comando: furgfs <nome> [argumentos]. $1 a $9 são trocados pelos\nargumentos e $@ por todos eles; sem marcadores, os argumentos vão para o fim do último comando.\n--force e --override-protection passados ao alias valem para todos os seus comandos.": "An alias is defined in the configuration file with \"alias.<name> = command; command\", in the script\nsyntax, and is called like a command: furgfs <name> [arguments]. $1 to $9 are replaced by the\narguments and $@ by all of them; without placeholders, the arguments go to the end of the last command.\n--force and --override-protection given to the alias apply to all its commands.",
+	"Escolha sua opção:": "Choose an option:",
+	"4. Sair.":           "4. Exit.",
+	"5. Outro tamanho (para escolher também o bloco e as entradas, use furgfs mkfs)": "5. Other size (to also choose the block size and entries, use furgfs mkfs)",
+	"\n--- Menu do Sistema de Arquivos FURGfs2 ---":                                  "\n--- FURGfs2 File System Menu ---",
+	"0. Sair":                                                             "0. Exit",
+	"Escolha uma opção [%s:%s]: ":                                         "Choose an option [%s:%s]: ",
+	"Opção inválida. Tente novamente.":                                    "Invalid option. Try again.",
+	"Encerrando o sistema de arquivos...":                                 "Shutting down the file system...",
+	"Erro ao salvar o estado do sistema de arquivos:":                     "Error saving the file system state:",
+	"Estado do sistema de arquivos salvo com sucesso.":                    "File system state saved successfully.",
+	"Arquivo do sistema de arquivos encontrado. Carregando...":            "File system image found. Loading...",
+	"Erro ao carregar o sistema de arquivos:":                             "Error loading the file system:",
+	"Sistema de arquivos carregado com sucesso.":                          "File system loaded successfully.",
+	"Nenhum sistema de arquivos existente encontrado. Criando um novo...": "No existing file system found. Creating a new one...",
+	"Erro ao criar o sistema de arquivos:":                                "Error creating the file system:",
+	" Confirmar? (s/n): ":                                                 " Confirm? (y/n): ",
+	"O arquivo '%s' está protegido contra escrita/remoção.\n":             "The file '%s' is protected against writing/removal.\n",
+	"Para ignorar a proteção, digite o caminho completo do arquivo: ":     "To override the protection, type the file's full path: ",
+	"[%d] falhou: %s: %v\n":                                               "[%d] failed: %s: %v\n",
+	"[%d] ok: %s\n":                                                       "[%d] ok: %s\n",
+	"erro: %d de %d comando(s) falharam":                                  "error: %d of %d command(s) failed",
+	"erro: aspas não fechadas no script":                                  "error: unterminated quote in script",
+	"erro ao ler a FAT: %v":                                               "error reading the FAT: %v",
+	"erro ao ler o cabeçalho: %v":                                         "error reading the header: %v",
+	"erro ao ler o diretório raiz: %v":                                    "error reading the root directory: %v",
+	"erro: %d problema(s) não reparado(s)":                                "error: %d problem(s) not repaired",
+	"A imagem será compactada e os arquivos removidos não poderão mais ser recuperados.":             "The image will be compacted and removed files can no longer be recovered.",
+	"%d bloco(s) órfão(s) serão liberados e seu conteúdo não poderá ser recuperado.":                 "%d orphan block(s) will be freed and their contents cannot be recovered.",
+	"O diretório '%s' será removido.":                                                                "The directory '%s' will be removed.",
+	"O arquivo '%s' será removido.":                                                                  "The file '%s' will be removed.",
+	"A imagem '%s' já existe e será substituída.":                                                    "The image '%s' already exists and will be replaced.",
+	"O campo %s será alterado de %d para %d.":                                                        "The field %s will be changed from %d to %d.",
+	"erro: %s Use --force para confirmar":                                                            "error: %s Use --force to confirm",
+	"erro: '%s' não foi encontrado no sistema de arquivos":                                           "error: '%s' was not found in the file system",
+	"erro: Arquivo protegido, troque sua proteção para poder remover":                                "error: protected file, change its protection to remove it",
+	"erro: Nenhum arquivo em '%s' casa com o padrão '%s'":                                            "error: no file in '%s' matches the pattern '%s'",
+	"erro: Não foi possível adicionar a entrada de arquivo ao sistema de arquivos":                   "error: could not add the file entry to the file system",
+	"erro: O arquivo '%s' em '%s' não foi armazenado no sistema de arquivos":                         "error: the file '%s' in '%s' is not stored in the file system",
+	"erro: O arquivo '%s' em '%s' não foi encontrado no sistema de arquivos":                         "error: the file '%s' in '%s' was not found in the file system",
+	"erro: O arquivo '%s' não foi encontrado no sistema de arquivos":                                 "error: the file '%s' was not found in the file system",
+	"erro: O arquivo com nome '%s' não foi armazenado no sistema de arquivos":                        "error: no file named '%s' is stored in the file system",
+	"erro: O arquivo com nome '%s' não foi encontrado no sistema de arquivos":                        "error: no file named '%s' was found in the file system",
+	"erro: O caminho '%s' não existe":                                                                "error: the path '%s' does not exist",
+	"erro: O diretório '%s' não existe":                                                              "error: the directory '%s' does not exist",
+	"erro: São necessárias %d entradas livres no diretório, há apenas %d":                            "error: %d free directory entries are needed, only %d available",
+	"erro: a nova imagem precisa de pelo menos %d entradas no diretório":                             "error: the new image needs at least %d directory entries",
+	"erro: a nova imagem tem %d blocos, mas os arquivos precisam de %d":                              "error: the new image has %d blocks, but the files need %d",
+	"erro: bloco %d fora da FAT na cadeia de '%s'":                                                   "error: block %d outside the FAT in the chain of '%s'",
+	"erro: cadeia de '%s' maior que o tamanho do arquivo":                                            "error: chain of '%s' is longer than the file size",
+	"erro: comando desconhecido '%s'":                                                                "error: unknown command '%s'",
+	"erro: comando ou assunto '%s' desconhecido; assuntos: %s":                                       "error: unknown command or topic '%s'; topics: %s",
+	"erro: espaço insuficiente na FAT.":                                                              "error: not enough space in the FAT.",
+	"erro: existem %d bloco(s) órfão(s), execute o fsck ou o gc antes de compactar":                  "error: there are %d orphan block(s), run fsck or gc before compacting",
+	"erro: nenhuma imagem aberta com o nome '%s'":                                                    "error: no open image named '%s'",
+	"erro: o arquivo '%s' está protegido; use --force --override-protection para ignorar a proteção": "error: the file '%s' is protected; use --force --override-protection to override the protection",
+	"erro: o arquivo é muito grande para o espaço disponível":                                        "error: the file is too large for the available space",
+	"erro: linguagem '%s' não suportada (use pt ou en)":                                              "error: language '%s' is not supported (use pt or en)",
+	"erro: %s exige o caminho da imagem":                                                             "error: %s requires the image path",
+	"erro: %s exige um valor":                                                                        "error: %s requires a value",
+	"uso: furgfs [--image <imagem>] [--lang pt|en] <comando> [argumentos]":                           "usage: furgfs [--image <image>] [--lang pt|en] <command> [arguments]",
+	"       furgfs [--image <imagem>] -c \"comando; comando; ...\"":                                  "       furgfs [--image <image>] -c \"command; command; ...\"",
+	"O log de eventos internos vai para a saída de erro padrão com o nível de --log-level quiet|normal|info|debug\n(-q e -v são atalhos para quiet e debug) ou, com --log-file <arquivo>, para o arquivo em JSON;\nas variáveis FURGFS_LOG_LEVEL e FURGFS_LOG_FILE têm o mesmo efeito. O padrão é normal (erros e avisos).": "The internal event log goes to standard error with the --log-level quiet|normal|info|debug level\n(-q and -v are shortcuts for quiet and debug) or, with --log-file <file>, to the file as JSON;\nthe FURGFS_LOG_LEVEL and FURGFS_LOG_FILE variables have the same effect. The default is normal (errors and warnings).",
+	"erro: nível de log '%s' inválido (use quiet, normal, info ou debug)": "error: invalid log level '%s' (use quiet, normal, info or debug)",
+	"Com --color auto (padrão), diretórios, arquivos protegidos e erros são coloridos apenas quando a saída é\num terminal e NO_COLOR não está definida; --color always e --color never forçam ou desligam as cores.": "With --color auto (the default), directories, protected files and errors are colored only when the output is\na terminal and NO_COLOR is not set; --color always and --color never force or disable colors.",
+	"erro: modo de cor '%s' inválido (use auto, always ou never)":                                                                                                                                     "error: invalid color mode '%s' (use auto, always or never)",
+	"escreve o conteúdo de arquivos internos na saída padrão":                                                                                                                                         "writes the content of internal files to standard output",
+	"Sem arquivos, copia a entrada padrão, por exemplo no meio de um pipeline.":                                                                                                                       "Without files, copies standard input, for example in the middle of a pipeline.",
+	"exibe as linhas de arquivos internos (ou da entrada) que casam com uma expressão regular":                                                                                                        "shows the lines of internal files (or of the input) that match a regular expression",
+	"-i ignora maiúsculas e minúsculas, -v inverte a busca, -n numera as linhas e -c apenas as conta.":                                                                                                "-i ignores case, -v inverts the search, -n numbers the lines and -c only counts them.",
+	"lista os caminhos das entradas cujo nome casa com um padrão glob":                                                                                                                                "lists the paths of the entries whose name matches a glob pattern",
+	"A busca inclui os subdiretórios do diretório (o atual, por padrão). Use aspas no padrão na linha de\ncomando para que o shell do sistema não o expanda.":                                         "The search includes the subdirectories of the directory (the current one by default). Quote the pattern\non the command line so that the system shell does not expand it.",
+	"abre um interpretador de comandos interativo, com pipelines entre os comandos":                                                                                                                   "opens an interactive command interpreter, with pipelines between commands",
+	"Cada linha segue a sintaxe dos scripts, inclusive ';' e '|'; Tab completa comandos e caminhos e\n\"exit\" encerra. As ações destrutivas pedem confirmação, a menos que o comando traga --force.": "Each line follows the script syntax, including ';' and '|'; Tab completes commands and paths and\n\"exit\" quits. Destructive actions ask for confirmation unless the command has --force.",
+	"erro: comando vazio no pipeline": "error: empty command in pipeline",
+	"erro: padrão inválido: %v":       "error: invalid pattern: %v",
+	"cria uma imagem temporária e testa as operações básicas, informando o resultado de cada etapa":                             "creates a temporary image and tests the basic operations, reporting the result of each step",
+	"Útil após alterações no programa ou em uma plataforma nova. A imagem é removida ao final, a menos\nque --keep seja usado.": "Useful after changes to the program or on a new platform. The image is removed at the end unless\n--keep is used.",
+	"criar a imagem":                           "create the image",
+	"criar diretórios":                         "create directories",
+	"importar arquivos":                        "import files",
+	"exportar arquivos":                        "export files",
+	"renomear":                                 "rename",
+	"proteger e desproteger":                   "protect and unprotect",
+	"copiar diretório":                         "copy a directory",
+	"mover diretório":                          "move a directory",
+	"remover arquivos e diretórios":            "remove files and directories",
+	"gravar e recarregar a imagem":             "save and reload the image",
+	"verificar a consistência (fsck)":          "check consistency (fsck)",
+	"FALHOU":                                   "FAILED",
+	"erro: autoteste falhou na etapa %d de %d": "error: self-test failed at step %d of %d",
+	"Todas as %d etapas passaram.\n":           "All %d steps passed.\n",
+	"Imagem mantida em %s\n":                   "Image kept at %s\n",
+	"relatório de integridade somente leitura: cabeçalho, cadeias da FAT, checksums e espaço livre":                                                  "read-only integrity report: header, FAT chains, checksums and free space",
+	"A imagem é aberta apenas para leitura; para reparar os problemas encontrados, use fsck.\nTermina com o código 6 quando a imagem tem problemas.": "The image is opened read-only; to repair the problems found, use fsck.\nExits with code 6 when the image has problems.",
+	"erro: a imagem '%s' tem problemas de integridade (veja fsck)":                                                                                   "error: image '%s' has integrity problems (see fsck)",
+	"%d problema(s)": "%d problem(s)",
+	"Imagem: %s\n":   "Image: %s\n",
+	"Cabeçalho:":     "Header:",
+	"As demais verificações dependem de um cabeçalho válido (veja superblock).": "The other checks depend on a valid header (see superblock).",
+	"Cadeias da FAT:": "FAT chains:",
+	"  %d bloco(s), %d em uso, %d entrada(s) no diretório\n": "  %d block(s), %d in use, %d directory entry(ies)\n",
+	"cabeçalho válido":                    "header valid",
+	"cabeçalho sem checksum":              "header without checksum",
+	"cabeçalho inválido (execute o fsck)": "header invalid (run fsck)",
+	"Checksums:":                          "Checksums:",
+	"  conteúdo: %d de %d arquivo(s) cobertos (o formato não guarda checksums de dados)\n": "  content: %d of %d file(s) covered (the format does not store data checksums)\n",
+	"inconsistente": "inconsistent",
+	"Espaço livre:": "Free space:",
+	"  registrado: %d bytes, calculado pela FAT: %d bytes\n":                          "  recorded: %d bytes, computed from the FAT: %d bytes\n",
+	"erro: o nome do snapshot tem %d bytes, o máximo é %d":                            "error: the snapshot name has %d bytes, the maximum is %d",
+	"erro: nome de snapshot inválido: %w":                                             "error: invalid snapshot name: %w",
+	"erro: o snapshot '%s' não existe":                                                "error: snapshot '%s' does not exist",
+	"erro: o snapshot '%s' está corrompido: %v":                                       "error: snapshot '%s' is corrupted: %v",
+	"erro: o snapshot '%s' já existe":                                                 "error: snapshot '%s' already exists",
+	"Snapshot '%s' criado com %d entrada(s).\n":                                       "Snapshot '%s' created with %d entry(ies).\n",
+	"O snapshot '%s' será apagado e não poderá mais ser restaurado.":                  "Snapshot '%s' will be deleted and can no longer be restored.",
+	"Snapshot '%s' apagado, %d bloco(s) liberado(s).\n":                               "Snapshot '%s' deleted, %d block(s) released.\n",
+	"erro: o snapshot tem %d entradas, mas o diretório só comporta %d":                "error: the snapshot has %d entries, but the directory only holds %d",
+	"erro: o snapshot '%s' referencia o bloco %d, fora da FAT":                        "error: snapshot '%s' references block %d, outside the FAT",
+	"O conteúdo atual do volume será substituído pelo do snapshot '%s' (%s).":         "The current contents of the volume will be replaced by those of snapshot '%s' (%s).",
+	"Snapshot '%s' restaurado: %d entrada(s).\n":                                      "Snapshot '%s' restored: %d entry(ies).\n",
+	"Nenhum snapshot encontrado.":                                                     "No snapshots found.",
+	"Nome":                                                                            "Name",
+	"Criado em":                                                                       "Created",
+	"Entradas":                                                                        "Entries",
+	"Tamanho":                                                                         "Size",
+	"uso: furgfs snapshot create|restore|delete <nome> | snapshot list [--json]":      "usage: furgfs snapshot create|restore|delete <name> | snapshot list [--json]",
+	"erro: a imagem tem snapshots; apague-os antes de compactar (veja snapshot list)": "error: the image has snapshots; delete them before compacting (see snapshot list)",
+	"registra o estado atual do volume com um nome\nlista os snapshots com a data de criação\nsubstitui o conteúdo do volume pelo do snapshot (exige --force)\napaga o snapshot e libera os blocos que só ele usava (exige --force)":         "records the current state of the volume under a name\nlists the snapshots with their creation date\nreplaces the volume contents with those of the snapshot (requires --force)\ndeletes the snapshot and releases the blocks only it used (requires --force)",
+	"Os snapshots ficam em /.furgfs e retêm os blocos dos arquivos: remover ou alterar um arquivo não\nlibera o espaço que ele ocupava enquanto algum snapshot o usar. compact é recusado enquanto houver\nsnapshots, e clone não os copia.": "Snapshots are kept in /.furgfs and retain the files' blocks: removing or changing a file does not\nrelease the space it used while any snapshot uses it. compact is refused while there are\nsnapshots, and clone does not copy them.",
+	"erro: não foi possível abrir o backup '%s': %v":                                                                  "error: could not open backup '%s': %v",
+	"erro: '%s' não é um backup válido: %v":                                                                           "error: '%s' is not a valid backup: %v",
+	"erro: '%s' é incremental (desde o snapshot '%s'); a cadeia deve começar por um backup completo":                  "error: '%s' is incremental (since snapshot '%s'); the chain must start with a full backup",
+	"erro: '%s' é um backup completo; apenas o primeiro da cadeia pode ser completo":                                  "error: '%s' is a full backup; only the first in the chain may be full",
+	"erro: '%s' parte do snapshot '%s', que não foi criado pelo backup '%s'":                                          "error: '%s' starts from snapshot '%s', which was not created by backup '%s'",
+	"erro: '%s' é de outro volume que '%s'":                                                                           "error: '%s' belongs to a different volume than '%s'",
+	"O conteúdo atual do volume será substituído pelo do backup de %s.":                                               "The current contents of the volume will be replaced by those of the backup from %s.",
+	"Volume restaurado de %d backup(s): %d entrada(s), %d arquivo(s).\n":                                              "Volume restored from %d backup(s): %d entry(ies), %d file(s).\n",
+	"erro ao ler o backup '%s': %v":                                                                                   "error reading backup '%s': %v",
+	"erro: o backup '%s' contém '%s', que não está no manifesto":                                                      "error: backup '%s' contains '%s', which is not in the manifest",
+	"erro: o conteúdo de '%s' não está em '%s' nem nos backups anteriores":                                            "error: the contents of '%s' are not in '%s' nor in the previous backups",
+	"uso: furgfs backup [--since <snapshot>] [--snapshot <nome>] <arquivo>":                                           "usage: furgfs backup [--since <snapshot>] [--snapshot <name>] <file>",
+	"Backup incremental gravado em '%s': %d arquivo(s) alterado(s) desde o snapshot '%s', %d entrada(s) no volume.\n": "Incremental backup written to '%s': %d file(s) changed since snapshot '%s', %d entry(ies) in the volume.\n",
+	"Backup completo gravado em '%s': %d arquivo(s), %d entrada(s) no volume.\n":                                      "Full backup written to '%s': %d file(s), %d entry(ies) in the volume.\n",
+	"uso: furgfs restore <backup> [incremental...]":                                                                   "usage: furgfs restore <backup> [incremental...]",
+	"grava um backup completo ou, com --since, só dos arquivos alterados desde um snapshot":                           "writes a full backup or, with --since, one with only the files changed since a snapshot",
+	"O backup é um arquivo tar.gz no sistema real, com a lista das entradas do volume e o conteúdo dos\narquivos incluídos. --snapshot cria, com o mesmo estado, o snapshot que servirá de --since para o\npróximo backup incremental.": "The backup is a tar.gz file on the host, with the list of the volume entries and the contents of the\nincluded files. --snapshot creates, with the same state, the snapshot that will serve as --since for the\nnext incremental backup.",
+	"substitui o conteúdo do volume pelo de uma cadeia de backups (exige --force)": "replaces the volume contents with those of a backup chain (requires --force)",
+	"A cadeia começa por um backup completo, seguido dos incrementais na ordem em que foram gravados;\ncada incremental deve partir do snapshot criado pelo anterior. O diretório de sistema /.furgfs não\né alterado. Para reconstruir um volume perdido, crie uma imagem com mkfs e restaure nela.": "The chain starts with a full backup, followed by the incrementals in the order they were written;\neach incremental must start from the snapshot created by the previous one. The /.furgfs system\ndirectory is not changed. To rebuild a lost volume, create an image with mkfs and restore into it.",
+	"erro: o conteúdo precisa de %d bytes, mais que o máximo de uma imagem FURGfs2":                                  "error: the contents need %d bytes, more than the maximum of a FURGfs2 image",
+	"Imagem '%s' criada: %d de %d blocos em uso, %d entradas no diretório.\n":                                        "Image '%s' created: %d of %d blocks in use, %d directory entries.\n",
+	"uso: furgfs convert <imagem> <arquivo.tar|.tar.gz|.zip|-> | convert [opções] <arquivo> <nova-imagem>":           "usage: furgfs convert <image> <file.tar|.tar.gz|.zip|-> | convert [options] <file> <new-image>",
+	"Conteúdo de '%s' exportado para '%s'.\n":                                                                        "Contents of '%s' exported to '%s'.\n",
+	"erro: o arquivo '%s' não existe":                                                                                "error: file '%s' does not exist",
+	"exporta todo o conteúdo de uma imagem para um tar ou zip\ncria uma nova imagem com o conteúdo de um tar ou zip": "exports all the contents of an image to a tar or zip\ncreates a new image with the contents of a tar or zip",
+	"O sentido é deduzido da origem: uma imagem FURGfs2 é exportada, qualquer outro arquivo é importado.\nSem --size e --entries, a nova imagem é dimensionada pelo conteúdo, com folga de 25%. O diretório de\nsistema /.furgfs não é exportado; a nova imagem recebe metadados de volume novos.": "The direction is deduced from the source: a FURGfs2 image is exported, any other file is imported.\nWithout --size and --entries, the new image is sized from the contents, with 25% headroom. The\n/.furgfs system directory is not exported; the new image gets new volume metadata.",
+	"erro: a imagem não tem um setor de boot FAT válido":                                          "error: the image does not have a valid FAT boot sector",
+	"erro: cluster %d fora da FAT":                                                                "error: cluster %d outside the FAT",
+	"erro: cadeia de clusters inválida a partir do cluster %d":                                    "error: invalid cluster chain starting at cluster %d",
+	"erro: a cadeia do cluster %d é menor que o tamanho do arquivo":                               "error: the chain of cluster %d is shorter than the file size",
+	"erro: ciclo na árvore de diretórios do volume FAT (cluster %d)":                              "error: cycle in the directory tree of the FAT volume (cluster %d)",
+	"Ignorando '%s': %v\n":                                                                        "Skipping '%s': %v\n",
+	"Importação de FAT%d concluída: %d arquivo(s), %d diretório(s), %d entrada(s) ignorada(s).\n": "FAT%d import finished: %d file(s), %d directory(ies), %d entry(ies) skipped.\n",
+	"uso: furgfs import-fat <imagem-fat> [destino]":                                               "usage: furgfs import-fat <fat-image> [destination]",
+	"copia os diretórios e arquivos de uma imagem FAT12/16/32 (disquete, cartão SD)":              "copies the directories and files of a FAT12/16/32 image (floppy, SD card)",
+	"A imagem deve conter o volume FAT diretamente, sem tabela de partições. Nomes longos são\npreservados e arquivos somente leitura ficam protegidos; nomes que o FURGfs2 não aceita são ignorados.": "The image must contain the FAT volume directly, without a partition table. Long names are\npreserved and read-only files become protected; names FURGfs2 does not accept are skipped.",
+	"%d arquivo(s) copiado(s), %d entrada(s) ignorada(s) pelos filtros.\n":                                                "%d file(s) copied, %d entry(ies) skipped by the filters.\n",
+	"erro: --include, --exclude, --min-size e --max-size exigem -r":                                                       "error: --include, --exclude, --min-size and --max-size require -r",
+	"uso: furgfs put -r [filtros] <diretório-local> <caminho-interno>":                                                    "usage: furgfs put -r [filters] <local-directory> <internal-path>",
+	"uso: furgfs get -r [filtros] <caminho-interno> <diretório-local>":                                                    "usage: furgfs get -r [filters] <internal-path> <local-directory>",
+	"copia um arquivo (ou a entrada padrão) para o FURGfs2\ncopia um diretório local com todo o conteúdo":                 "copies a file (or standard input) into FURGfs2\ncopies a local directory with all its contents",
+	"copia um arquivo do FURGfs2 para o sistema real (ou a saída padrão)\ncopia um diretório interno com todo o conteúdo": "copies a file from FURGfs2 to the host (or standard output)\ncopies an internal directory with all its contents",
+	"Filtros das cópias com -r: --include <padrão> copia apenas os arquivos que casam com o padrão,\n--exclude <padrão> ignora arquivos e diretórios (os dois podem ser repetidos), --min-size e --max-size\nlimitam o tamanho dos arquivos (aceitam K, M e G). Padrões com '/' valem para o caminho relativo à\nraiz da cópia; os demais, para o nome.": "Filters for copies with -r: --include <pattern> copies only the files matching the pattern,\n--exclude <pattern> skips files and directories (both may be repeated), --min-size and --max-size\nlimit the file size (K, M and G are accepted). Patterns with '/' apply to the path relative to the\ncopy root; the others, to the name.",
+	"informa se o conteúdo do volume é cifrado\ncifra no lugar o conteúdo de todos os arquivos (exige --force)\ndecifra no lugar o conteúdo de todos os arquivos (exige --force)\ntroca a senha; com --rekey, recifra o conteúdo com uma chave nova (exige --force)":                                                                                     "tells whether the volume contents are encrypted\nencrypts the contents of all files in place (requires --force)\ndecrypts the contents of all files in place (requires --force)\nchanges the password; with --rekey, re-encrypts the contents with a new key (requires --force)",
+	"O conteúdo dos arquivos é cifrado com AES-256-XTS; nomes, tamanhos e o diretório de sistema /.furgfs\ncontinuam legíveis. A senha é lida de FURGFS_PASSWORD (e a nova, de FURGFS_NEW_PASSWORD) ou pedida\nno terminal ao abrir o primeiro arquivo. Trocar a senha sem --rekey regrava apenas a chave protegida.":                                    "File contents are encrypted with AES-256-XTS; names, sizes and the /.furgfs system directory\nremain readable. The password is read from FURGFS_PASSWORD (and the new one from FURGFS_NEW_PASSWORD) or asked\nfor on the terminal when the first file is opened. Changing the password without --rekey rewrites only the protected key.",
+	"A cifra é aplicada bloco a bloco, com o andamento exibido na saída de erro; se a operação for\ninterrompida, o volume ficará ilegível. Os snapshots precisam ser apagados antes.":                                                                                                                                                                   "Encryption is applied block by block, with progress shown on standard error; if the operation is\ninterrupted, the volume becomes unreadable. Snapshots must be deleted first.",
+	"erro: cifra '%s' com derivação '%s' não suportada":           "error: cipher '%s' with key derivation '%s' is not supported",
+	"erro: parâmetros de cifra inválidos em %s":                   "error: invalid encryption parameters in %s",
+	"erro: senha incorreta":                                       "error: wrong password",
+	"erro ao ler os parâmetros de cifra: %v":                      "error reading the encryption parameters: %v",
+	"Senha do volume: ":                                           "Volume password: ",
+	"erro: senha não informada":                                   "error: no password given",
+	"Nova senha: ":                                                "New password: ",
+	"erro: a senha não pode ser vazia":                            "error: the password cannot be empty",
+	"Confirme a nova senha: ":                                     "Confirm the new password: ",
+	"erro: as senhas não conferem":                                "error: the passwords do not match",
+	"erro: a cifra exige blocos com tamanho múltiplo de %d bytes": "error: encryption requires a block size that is a multiple of %d bytes",
+	"erro: a imagem tem snapshots; apague-os antes de alterar a cifra (veja snapshot list)": "error: the image has snapshots; delete them before changing the encryption (see snapshot list)",
+	"erro: o volume já é cifrado":  "error: the volume is already encrypted",
+	"erro: o volume não é cifrado": "error: the volume is not encrypted",
+	"O conteúdo de %d arquivo(s) será cifrado no lugar; se a operação for interrompida, o volume ficará ilegível.":   "The contents of %d file(s) will be encrypted in place; if the operation is interrupted, the volume will become unreadable.",
+	"O conteúdo de %d arquivo(s) será gravado sem cifra; se a operação for interrompida, o volume ficará ilegível.":  "The contents of %d file(s) will be written unencrypted; if the operation is interrupted, the volume will become unreadable.",
+	"O conteúdo de %d arquivo(s) será recifrado no lugar; se a operação for interrompida, o volume ficará ilegível.": "The contents of %d file(s) will be re-encrypted in place; if the operation is interrupted, the volume will become unreadable.",
+	"Cifrando":   "Encrypting",
+	"Decifrando": "Decrypting",
+	"Recifrando": "Re-encrypting",
+	"Volume cifrado: %d bloco(s) de %d arquivo(s).\n":    "Volume encrypted: %d block(s) in %d file(s).\n",
+	"Cifra removida: %d bloco(s) de %d arquivo(s).\n":    "Encryption removed: %d block(s) in %d file(s).\n",
+	"Senha trocada e conteúdo recifrado: %d bloco(s).\n": "Password changed and contents re-encrypted: %d block(s).\n",
+	"Senha trocada.":      "Password changed.",
+	"Volume não cifrado.": "Volume not encrypted.",
+	"Volume cifrado com %s; chave protegida por %s com %d iterações.\n":    "Volume encrypted with %s; key protected by %s with %d iterations.\n",
+	"erro: em um volume cifrado, arquivos não podem entrar nem sair de %s": "error: on an encrypted volume, files cannot be moved into or out of %s",
+	"define ou remove os limites de bytes e de arquivos de um diretório\ndefine ou remove os limites do que um usuário grava pelos servidores de rede\nmostra as cotas com o uso atual de cada diretório e de cada usuário\nlista os diretórios que mais ocupam espaço, com suas cotas":                                                                                "sets or removes the byte and file limits of a directory\nsets or removes the limits on what a user writes through the network servers\nshows the quotas with the current usage of each directory and each user\nlists the directories that use the most space, with their quotas",
+	"A cota de um diretório conta o diretório e todos os seus subdiretórios e é verificada ao gravar e ao\nmover arquivos. As cotas dos diretórios ficam em /.furgfs/quota.json e acompanham o diretório quando ele\né movido.":                                                                                                                                        "A directory quota counts the directory and all its subdirectories and is checked when writing and\nmoving files. Directory quotas are kept in /.furgfs/quota.json and follow the directory when it is\nmoved.",
+	"A cota de um usuário vale para o que ele grava por \"serve\" e conta os arquivos de que ele é dono: os que\ngravou pelos servidores de rede e que ainda existem, onde quer que estejam. O dono de cada arquivo fica\nem /.furgfs/owners.json; os arquivos gravados pela linha de comando não têm dono. As cotas dos usuários\nficam em /.furgfs/user_quota.json.": "A user quota applies to what the user writes through \"serve\" and counts the files the user owns: the\nones written through the network servers that still exist, wherever they are. The owner of each file is\nkept in /.furgfs/owners.json; files written from the command line have no owner. User quotas are kept\nin /.furgfs/user_quota.json.",
+	"uso: furgfs quota set [--files N|none] [--user <nome>] [<diretório>] <tamanho|none> | quota show [--json] | quota report [--json] [--top N]":                                                                                                                                                                                                                      "usage: furgfs quota set [--files N|none] [--user <name>] [<directory>] <size|none> | quota show [--json] | quota report [--json] [--top N]",
+	"erro ao ler as cotas dos usuários: %v":                        "error reading the user quotas: %v",
+	"erro ao ler os donos dos arquivos: %v":                        "error reading the file owners: %v",
+	"erro: a cota de %d arquivo(s) do usuário '%s' seria excedida": "error: the quota of %d file(s) of user '%s' would be exceeded",
+	"erro: a cota de %d bytes do usuário '%s' seria excedida":      "error: the quota of %d bytes of user '%s' would be exceeded",
+	"USUÁRIO":                          "USER",
+	"Cota do usuário '%s' removida.\n": "Quota of user '%s' removed.\n",
+	"Cota do usuário '%s' definida; ele já ocupa %d bytes em %d arquivo(s), acima do limite.\n": "Quota of user '%s' set; the user already owns %d bytes in %d file(s), above the limit.\n",
+	"Cota do usuário '%s' definida.\n":                     "Quota of user '%s' set.\n",
+	"erro ao ler as cotas: %v":                             "error reading the quotas: %v",
+	"erro: o diretório de sistema %s não tem cota":         "error: the system directory %s cannot have a quota",
+	"erro: a cota de %d arquivo(s) de '%s' seria excedida": "error: the quota of %d file(s) of '%s' would be exceeded",
+	"erro: a cota de %d bytes de '%s' seria excedida":      "error: the quota of %d bytes of '%s' would be exceeded",
+	"BYTES":          "BYTES",
+	"COTA":           "QUOTA",
+	"ARQS":           "FILES",
+	"COTA ARQS":      "FILE QUOTA",
+	"DIRETÓRIO":      "DIRECTORY",
+	" (inexistente)": " (missing)",
+	"erro: número de arquivos '%s' inválido": "error: invalid number of files '%s'",
+	"Cota de '%s' removida.\n":               "Quota of '%s' removed.\n",
+	"Cota de '%s' definida; o diretório já ocupa %d bytes em %d arquivo(s), acima do limite.\n": "Quota of '%s' set; the directory already uses %d bytes in %d file(s), above the limit.\n",
+	"Cota de '%s' definida.\n":           "Quota of '%s' set.\n",
+	"Nenhuma cota definida.":             "No quotas set.",
+	"altera o rótulo e o UUID do volume": "changes the volume label and UUID",
+	"--uuid regenerate gera um UUID aleatório; trocar um UUID existente exige --force, pois os próximos\nbackups incrementais não poderão continuar as cadeias gravadas com o UUID anterior. Imagens antigas,\nsem metadados, passam a tê-los. O rótulo e o UUID aparecem em stat / e em stats.": "--uuid regenerate generates a random UUID; replacing an existing UUID requires --force, since later\nincremental backups cannot continue the chains recorded with the previous UUID. Old images\nwithout metadata get them. The label and UUID are shown by stat / and stats.",
+	"erro: rótulo '%s' inválido: %v": "error: invalid label '%s': %v",
+	"erro: UUID '%s' inválido":       "error: invalid UUID '%s'",
+	"O UUID do volume será trocado de %s para %s; novos backups incrementais não poderão continuar as cadeias atuais.": "The volume UUID will change from %s to %s; new incremental backups will not be able to continue the current chains.",
+	"Rótulo: '%s', UUID: %s\n": "Label: '%s', UUID: %s\n",
+	"monta o volume com FUSE, para ser usado por qualquer programa, até ser desmontado": "mounts the volume with FUSE, for use by any program, until it is unmounted",
+	"O comando fica em execução atendendo o sistema; para desmontar, use umount (ou fusermount -u) no\nponto de montagem ou pressione Ctrl+C. Como o FURGfs2 só grava arquivos inteiros, um arquivo aberto\npara escrita é mantido em memória e gravado por completo ao ser fechado. Retirar a permissão de\nescrita (chmod a-w) protege o arquivo. Disponível apenas no Linux e no macOS.": "The command keeps running and serving the system; to unmount, use umount (or fusermount -u) on the\nmount point or press Ctrl+C. Since FURGfs2 only writes whole files, a file opened for writing is\nkept in memory and written in full when it is closed. Removing the write permission (chmod a-w)\nprotects the file. Available on Linux and macOS only.",
+	"erro: o ponto de montagem '%s' não é um diretório":             "error: mount point '%s' is not a directory",
+	"'%s' montada em '%s'; use umount ou Ctrl+C para desmontar.\n":  "'%s' mounted on '%s'; use umount or Ctrl+C to unmount.\n",
+	"erro ao desmontar '%s': %v":                                    "error unmounting '%s': %v",
+	"'%s' desmontada.\n":                                            "'%s' unmounted.\n",
+	"erro: mount exige FUSE, disponível apenas no Linux e no macOS": "error: mount requires FUSE, available on Linux and macOS only",
+	"uso: furgfs serve <%s> [--addr <endereço>] [opções] [imagem]":  "usage: furgfs serve <%s> [--addr <address>] [options] [image]",
+	"erro: protocolo '%s' desconhecido; use %s":                     "error: unknown protocol '%s'; use %s",
+	"erro ao escutar em '%s': %v":                                   "error listening on '%s': %v",
+	"Servindo '%s' por %s em %s; Ctrl+C para encerrar.\n":           "Serving '%s' over %s on %s; Ctrl+C to stop.\n",
+	"Servidor encerrado.":                                           "Server stopped.",
+	"O comando fica em execução até ser interrompido com Ctrl+C. Os clientes acessam o volume ao mesmo\ntempo, e cada alteração é gravada na imagem assim que termina; como o FURGfs2 só grava arquivos\ninteiros, um envio substitui o arquivo completo. O WebDAV não tem autenticação: use um endereço local ou uma\nrede confiável.":                         "The command keeps running until interrupted with Ctrl+C. Clients access the volume concurrently, and\neach change is written to the image as soon as it completes; since FURGfs2 only writes whole files,\nan upload replaces the entire file. WebDAV has no authentication: use a local address or a trusted\nnetwork.",
+	"O SFTP aceita os usuários cadastrados com \"furgfs user\", por senha ou chave pública; usuários\ncriados com --read-only só podem ler. A chave do servidor é gerada na primeira vez e guardada em\n/.furgfs/ssh_host_key; --host-key usa uma chave do OpenSSH em vez dela.":                                                                                "SFTP accepts the users registered with \"furgfs user\", by password or public key; users created with\n--read-only can only read. The server key is generated on first use and kept in\n/.furgfs/ssh_host_key; --host-key uses an OpenSSH key instead.",
+	"O HTTP serve o index.html de um diretório ou, sem ele, a lista de arquivos. O FURGfs2 não guarda o\ntipo dos arquivos: o Content-Type vem da extensão do nome ou, sem ela, do início do conteúdo.":                                                                                                                                                         "HTTP serves a directory's index.html or, without one, the file list. FURGfs2 does not store file\ntypes: the Content-Type comes from the name's extension or, without one, from the start of the\ncontent.",
+	"A API REST fica em /api/v1 e é descrita pela especificação OpenAPI em /api/v1/openapi.json; ela\naceita, por HTTP Basic, os mesmos usuários do SFTP.":                                                                                                                                                                                                      "The REST API lives under /api/v1 and is described by the OpenAPI specification at\n/api/v1/openapi.json; it accepts, over HTTP Basic, the same users as SFTP.",
+	"O gRPC segue o serviço definido em furgfspb/furgfs.proto; o pacote FURGFS2/furgfspb traz também o\ncliente Go gerado. As chamadas levam os mesmos usuários no metadado authorization, em HTTP Basic (veja\nfurgfspb.BasicAuth).":                                                                                                                           "gRPC follows the service defined in furgfspb/furgfs.proto; the FURGFS2/furgfspb package also\nprovides the generated Go client. Calls carry the same users in the authorization metadata, as HTTP\nBasic (see furgfspb.BasicAuth).",
+	"O NFS segue a versão 3 do protocolo, só por TCP e sem portmapper: o cliente informa a porta ao\nmontar, como em \"mount -t nfs -o vers=3,proto=tcp,port=2049,mountport=2049,nolock host:/ /mnt\" no\nLinux. Como no NFS tradicional, não há senha; use --read-only ou uma rede confiável. O conteúdo escrito\né gravado quando o cliente fecha o arquivo.": "NFS follows version 3 of the protocol, over TCP only and without a portmapper: the client gives the\nport when mounting, as in \"mount -t nfs -o vers=3,proto=tcp,port=2049,mountport=2049,nolock host:/ /mnt\"\non Linux. As in traditional NFS, there is no password; use --read-only or a trusted network. Written\ncontent is stored when the client closes the file.",
+	"Em todos os protocolos, exceto o NBD, o diretório de sistema /.furgfs fica escondido.": "In all protocols except NBD, the system directory /.furgfs is hidden.",
+	"erro: nome de usuário '%s' inválido":                                                   "error: invalid user name '%s'",
+	"erro: o usuário '%s' não existe":                                                       "error: user '%s' does not exist",
+	"erro: o usuário '%s' já existe":                                                        "error: user '%s' already exists",
+	"erro ao ler os usuários: %v":                                                           "error reading users: %v",
+	"erro: nenhuma chave encontrada em '%s'":                                                "error: no key found in '%s'",
+	"Usuário '%s' cadastrado.\n":                                                            "User '%s' registered.\n",
+	"Usuário '%s' removido.\n":                                                              "User '%s' removed.\n",
+	"Senha de '%s' alterada.\n":                                                             "Password of '%s' changed.\n",
+	"Nenhum usuário cadastrado.":                                                            "No users registered.",
+	"leitura e escrita":                                                                     "read and write",
+	"somente leitura":                                                                       "read-only",
+	"senha":                                                                                 "password",
+	"%d chave(s)":                                                                           "%d key(s)",
+	"erro: nenhum usuário cadastrado; use furgfs user add <nome>":                           "error: no users registered; use furgfs user add <name>",
+	"Chave do servidor: %s\n":                                                               "Server key: %s\n",
+	"erro ao ler a chave do servidor: %v":                                                   "error reading the server key: %v",
+	"O SMB é experimental e segue os dialetos 2.0.2 e 2.1, sem oplocks nem avisos de alteração. O volume\naparece como o compartilhamento furgfs (veja --share) e é mapeado no Windows com \"net use Z: \\\\host\\furgfs\";\no Windows só usa a porta 445, que no Linux exige privilégios. Entram, por NTLMv2, apenas os usuários\ncadastrados com \"furgfs user add --smb\", e as mensagens são assinadas quando o cliente pede.": "SMB is experimental and follows dialects 2.0.2 and 2.1, without oplocks or change notifications. The\nvolume appears as the furgfs share (see --share) and is mapped on Windows with \"net use Z: \\\\host\\furgfs\";\nWindows only uses port 445, which requires privileges on Linux. Only users registered with \"furgfs user\nadd --smb\" can log in, over NTLMv2, and messages are signed when the client asks.",
+	"Os usuários são usados por \"serve sftp\", \"serve api\", \"serve grpc\" e \"serve smb\" e ficam em\n/.furgfs/users.json, com a senha guardada como hash PBKDF2-SHA256. Sem --key, a senha é lida de\nFURGFS_NEW_PASSWORD ou pedida no terminal; com --key, o usuário entra com as chaves do arquivo, no formato\nde authorized_keys. Um servidor em execução só vê as alterações ao ser reiniciado.":                         "Users are used by \"serve sftp\", \"serve api\", \"serve grpc\" and \"serve smb\" and kept in\n/.furgfs/users.json, with the password stored as a PBKDF2-SHA256 hash. Without --key, the password is read\nfrom FURGFS_NEW_PASSWORD or asked on the terminal; with --key, the user logs in with the keys in the file,\nin authorized_keys format. A running server only sees the changes after a restart.",
+	"O SMB autentica por NTLMv2, que precisa do hash NT da senha. Ele só é guardado com --smb e, como não\ntem sal, vale tanto quanto a própria senha para quem ler a imagem; \"user passwd\" sem --smb o remove.":                                                                                                                                                                                                                 "SMB authenticates over NTLMv2, which needs the password's NT hash. It is only stored with --smb and,\nsince it is unsalted, it is as good as the password itself to anyone who reads the image; \"user passwd\"\nwithout --smb removes it.",
+	"erro: --smb exige uma senha e não pode ser usado com --key":                   "error: --smb needs a password and cannot be used with --key",
+	"erro: nome de compartilhamento '%s' inválido":                                 "error: invalid share name '%s'",
+	"erro: nenhum usuário com acesso por SMB; use furgfs user passwd --smb <nome>": "error: no users with SMB access; use furgfs user passwd --smb <name>",
+	"O S3 usa endereços por caminho (http://host:9000/balde/chave): cada diretório da raiz é um balde e\nas chaves são os caminhos dos arquivos dentro dele, criando os diretórios intermediários ao enviar. Os\npedidos são assinados com AWS Signature Version 4, em qualquer região, com as chaves criadas por \"furgfs\nuser s3key\". Há listagem, envio, download com Range, cópia e remoção de objetos e baldes; envios\nmultipart, versões e ACLs não são aceitos, e a ETag é sempre o MD5 do conteúdo.": "S3 uses path-style addresses (http://host:9000/bucket/key): each root directory is a bucket and the\nkeys are the paths of the files inside it, with intermediate directories created on upload. Requests\nare signed with AWS Signature Version 4, in any region, with the keys created by \"furgfs user s3key\".\nObjects and buckets can be listed, uploaded, downloaded with Range, copied and deleted; multipart\nuploads, versions and ACLs are not supported, and the ETag is always the MD5 of the content.",
+	"cadastra um usuário dos servidores de rede, com senha ou chaves públicas SSH\ntroca a senha de um usuário\ncria uma nova chave de acesso S3 para um usuário, ou a remove\nremove um usuário\nlista os usuários cadastrados":                                                                                                                                                                                                                                                                                "registers a network server user, with a password or SSH public keys\nchanges a user's password\ncreates a new S3 access key for a user, or removes it\nremoves a user\nlists the registered users",
+	"O \"serve s3\" usa o nome do usuário como chave de acesso e uma chave secreta aleatória, mostrada por\n\"user s3key\". A AWS Signature Version 4 exige a chave em texto, por isso ela é guardada como está;\ncada \"user s3key\" troca a chave anterior, e --remove a apaga.":                                                                                                                                                                                                                              "\"serve s3\" uses the user name as the access key and a random secret key, shown by \"user s3key\".\nAWS Signature Version 4 needs the key in clear text, so it is stored as is; each \"user s3key\"\nreplaces the previous key, and --remove deletes it.",
+	"uso: furgfs user add [--read-only] [--smb] [--key <arquivo.pub>] <nome> | user passwd [--smb] <nome> | user s3key [--remove] <nome> | user remove <nome> | user list [--json]":                                                                                                                                                                                                                                                                                                                             "usage: furgfs user add [--read-only] [--smb] [--key <file.pub>] <name> | user passwd [--smb] <name> | user s3key [--remove] <name> | user remove <name> | user list [--json]",
+	"Chave de acesso: %s\nChave secreta: %s\n":                                        "Access key: %s\nSecret key: %s\n",
+	"Chave S3 de '%s' removida.\n":                                                    "S3 key of '%s' removed.\n",
+	"erro: nenhum usuário com chave S3; use furgfs user s3key <nome>":                 "error: no users with an S3 key; use furgfs user s3key <name>",
+	"sincroniza um diretório local com um diretório interno, copiando só o que mudou": "synchronizes a local directory with an internal directory, copying only what changed",
+	"Copia do diretório local para o interno ou, com --to-host, do interno para o local, apenas os arquivos\nnovos ou alterados; --delete apaga no destino o que não existe mais na origem, e -n só mostra o que\nseria feito. Cada alteração é listada com + (criado), ~ (atualizado), - (apagado) ou ! (protegido).": "Copies from the local directory to the internal one or, with --to-host, from the internal one to the\nlocal one, only the new or changed files; --delete removes from the destination what no longer exists\nin the source, and -n only shows what would be done. Each change is listed with + (created),\n~ (updated), - (deleted) or ! (protected).",
+	"O FURGfs2 não guarda datas, por isso o tamanho, a data do arquivo local e o SHA-256 de cada arquivo\nna última sincronização ficam em /.furgfs/sync.json. Arquivos com o mesmo tamanho são comparados pelo\nconteúdo, a menos que o arquivo local não tenha mudado desde então; --checksum compara sempre.":       "FURGfs2 does not store dates, so the size, the local file's date and the SHA-256 of each file at the\nlast sync are kept in /.furgfs/sync.json. Files with the same size are compared by content, unless the\nlocal file has not changed since then; --checksum always compares.",
+	"Aceita os filtros --include, --exclude, --min-size e --max-size de put -r; as entradas ignoradas\nnão são copiadas nem apagadas.":                                                                                                                                                                                 "Accepts the --include, --exclude, --min-size and --max-size filters of put -r; ignored entries are\nneither copied nor deleted.",
+	"uso: furgfs sync [--to-host] [--delete] [--checksum] [-n] [filtros] <diretório-local> <caminho-interno>":                                                                                                                                                                                                          "usage: furgfs sync [--to-host] [--delete] [--checksum] [-n] [filters] <local-directory> <internal-path>",
+	"Simulação: %d arquivo(s) a copiar, %d entrada(s) a remover, %d arquivo(s) sem alteração.\n":                                                                                                                                                                                                                       "Dry run: %d file(s) to copy, %d entry(ies) to remove, %d file(s) unchanged.\n",
+	"%d arquivo(s) copiado(s), %d entrada(s) removida(s), %d arquivo(s) sem alteração.\n":                                                                                                                                                                                                                              "%d file(s) copied, %d entry(ies) removed, %d file(s) unchanged.\n",
+	"erro: %d entrada(s) protegida(s) não foram alteradas":                             "error: %d protected entry(ies) were not changed",
+	"erro ao ler o estado da sincronização: %v":                                        "error reading the sync state: %v",
+	"erro: o diretório de sistema %s não pode ser sincronizado":                        "error: the system directory %s cannot be synchronized",
+	"erro: '%s' não é um diretório":                                                    "error: '%s' is not a directory",
+	"erro: o diretório '%s' não existe no sistema real":                                "error: the directory '%s' does not exist on the host",
+	"erro: '%s' está protegido":                                                        "error: '%s' is protected",
+	"mantém um diretório local e um diretório interno sincronizados nos dois sentidos": "keeps a local directory and an internal directory synchronized in both directions",
+	"Fica em execução até ser interrompido com Ctrl+C, observando o diretório local e o arquivo da imagem:\ndepois de --delay sem novas alterações, o que mudou de um lado, inclusive arquivos apagados, é levado para\no outro. A imagem é lida de novo a cada vez, então as alterações de um \"serve\" em execução também são\nsincronizadas.": "Runs until interrupted with Ctrl+C, watching the local directory and the image file: after --delay\nwithout new changes, what changed on one side, including deleted files, is carried over to the other.\nThe image is read again each time, so the changes of a running \"serve\" are synchronized too.",
+	"Cada lado é comparado com a última sincronização, guardada em /.furgfs/sync.json como em sync. Um arquivo\nalterado dos dois lados de formas diferentes é um conflito: ele é indicado com ! e fica como está até que\num dos lados seja acertado. Alterar um arquivo vence apagá-lo do outro lado.":                                         "Each side is compared with the last sync, kept in /.furgfs/sync.json as in sync. A file changed on both\nsides in different ways is a conflict: it is shown with ! and left as it is until one of the sides is\nfixed. Changing a file wins over deleting it on the other side.",
+	"uso: furgfs sync-watch [--delay <duração>] <diretório-local> <caminho-interno> [imagem]": "usage: furgfs sync-watch [--delay <duration>] <local-directory> <internal-path> [image]",
+	"Sincronizando '%s' com '%s' em '%s'; Ctrl+C para encerrar.\n":                            "Synchronizing '%s' with '%s' in '%s'; Ctrl+C to stop.\n",
+	"Sincronização encerrada.":  "Synchronization stopped.",
+	"erro ao observar '%s': %v": "error watching '%s': %v",
+	"compartilha o volume por WebDAV, para ser montado pelo Finder, Explorer ou gvfs sem instalar nada\ncompartilha o volume por SFTP, para os clientes sftp e scp, com os usuários cadastrados em user\nserve os arquivos por HTTP, somente para leitura, com páginas de índice dos diretórios\noferece uma API REST em JSON para listar, enviar, baixar, criar, apagar e renomear\noferece um serviço gRPC, com envio e download em fluxo e um cliente Go gerado\nexporta o volume por NFSv3, para ser montado pelo Linux e pelo macOS\ncompartilha o volume por SMB2, em caráter experimental, para ser mapeado como unidade de rede no Windows\noferece uma API compatível com o S3, para os SDKs da AWS e ferramentas como mc e rclone\nexporta a imagem inteira como um dispositivo de blocos por NBD, para ferramentas de outra máquina": "shares the volume over WebDAV, to be mounted by Finder, Explorer or gvfs without installing anything\nshares the volume over SFTP, for the sftp and scp clients, with the users registered with user\nserves the files over HTTP, read-only, with directory index pages\noffers a JSON REST API to list, upload, download, create, delete and rename\noffers a gRPC service, with streaming upload and download and a generated Go client\nexports the volume over NFSv3, to be mounted by Linux and macOS\nshares the volume over SMB2, experimentally, to be mapped as a network drive on Windows\noffers an S3-compatible API, for the AWS SDKs and tools such as mc and rclone\nexports the whole image as a block device over NBD, for tools on another machine",
+	"O NBD exporta o arquivo da imagem, e não os arquivos do volume, com o nome do arquivo como nome da\nexportação: em outra máquina, \"nbd-client host 10809 /dev/nbd0 -N n.fs2\" cria um dispositivo que pode ser\naberto com \"furgfs -i /dev/nbd0\" ou examinado por ferramentas de blocos. As escritas vão direto para a\nimagem, sem nenhuma verificação; use --read-only para só inspecioná-la e não use a mesma imagem por\noutro meio enquanto o dispositivo estiver em uso.":                                                                                                                                                                                                                                                                                                                                                         "NBD exports the image file, not the files of the volume, with the file name as the export name: on\nanother machine, \"nbd-client host 10809 /dev/nbd0 -N n.fs2\" creates a device that can be opened with\n\"furgfs -i /dev/nbd0\" or examined by block tools. Writes go straight to the image, without any checks;\nuse --read-only to only inspect it and do not use the same image by other means while the device is in\nuse.",
+	"A imagem também pode ser remota: http:// e https:// leem, somente para leitura, uma imagem publicada em\num servidor com suporte a Range, e s3://bucket/chave usa um objeto S3, com as credenciais de\nAWS_ACCESS_KEY_ID e AWS_SECRET_ACCESS_KEY, a região de AWS_REGION e, para serviços compatíveis, o\nendereço de FURGFS_S3_ENDPOINT. As páginas lidas ficam em um cache local (FURGFS_CACHE_DIR) e as\nescritas só são enviadas, com a imagem inteira, ao fim do comando.":                                                                                                                                                                                                                                                                                                                                                            "The image can also be remote: http:// and https:// read, read-only, an image published on a server with\nRange support, and s3://bucket/key uses an S3 object, with the credentials from AWS_ACCESS_KEY_ID and\nAWS_SECRET_ACCESS_KEY, the region from AWS_REGION and, for compatible services, the address from\nFURGFS_S3_ENDPOINT. The pages read stay in a local cache (FURGFS_CACHE_DIR) and writes are only sent,\nwith the whole image, at the end of the command.",
+	"erro: imagem S3 inválida, use s3://bucket/chave":                                          "error: invalid S3 image, use s3://bucket/key",
+	"erro: defina AWS_ACCESS_KEY_ID e AWS_SECRET_ACCESS_KEY para usar imagens S3":              "error: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY to use S3 images",
+	"a imagem remota '%s' não existe":                                                          "the remote image '%s' does not exist",
+	"erro: a imagem remota foi alterada por outro cliente; as escritas ficaram no cache local": "error: the remote image was changed by another client; the writes were kept in the local cache",
+	"configura o espelho, uma cópia da imagem atualizada a cada alteração, e copia a imagem para ele\ncopia de novo a imagem inteira para o espelho, depois de uma falha\nmostra o espelho e se ele está em dia\ndeixa de replicar a imagem, sem apagar o espelho":                                                                                                                                              "configures the mirror, a copy of the image updated on every change, and copies the image to it\ncopies the whole image to the mirror again, after a failure\nshows the mirror and whether it is up to date\nstops replicating the image, without deleting the mirror",
+	"Depois de \"replica set\", cada comando que altera a imagem envia ao espelho, em segundo plano, os trechos\nalterados, com os blocos de dados antes dos metadados; o comando só termina quando o envio acaba. Os\nservidores de \"serve\" replicam cada alteração assim que ela é salva. O destino fica em\n/.furgfs/replica.json e pode ser um arquivo local ou uma imagem s3:// (veja \"furgfs help\").": "After \"replica set\", every command that changes the image sends the changed ranges to the mirror in the\nbackground, with the data blocks before the metadata; the command only finishes when the transfer ends.\nThe \"serve\" servers replicate each change as soon as it is saved. The target is kept in\n/.furgfs/replica.json and can be a local file or an s3:// image (see \"furgfs help\").",
+	"Se um envio falhar, a replicação para até o fim do comando e o espelho fica desatualizado: \"replica\nstatus\" compara os metadados das duas imagens e \"replica sync\" volta a copiar a imagem inteira. O\nespelho é uma imagem comum e pode ser aberta com --image no lugar da original.":                                                                                                                "If a transfer fails, replication stops until the end of the command and the mirror becomes outdated:\n\"replica status\" compares the metadata of both images and \"replica sync\" copies the whole image again.\nThe mirror is a regular image and can be opened with --image instead of the original.",
+	"uso: furgfs replica set <destino> | replica sync | replica status [--json] | replica remove": "usage: furgfs replica set <target> | replica sync | replica status [--json] | replica remove",
+	"erro ao ler %s: %v":                            "error reading %s: %v",
+	"erro: o espelho não pode ser a própria imagem": "error: the mirror cannot be the image itself",
+	"Espelho '%s' criado com %d bytes; as próximas alterações serão replicadas.\n":   "Mirror '%s' created with %d bytes; the next changes will be replicated.\n",
+	"erro: nenhum espelho configurado; use furgfs replica set <destino>":             "error: no mirror configured; use furgfs replica set <target>",
+	"Espelho '%s' atualizado com %d bytes.\n":                                        "Mirror '%s' updated with %d bytes.\n",
+	"Nenhum espelho configurado.":                                                    "No mirror configured.",
+	"Espelho: %s (inacessível: %s)\n":                                                "Mirror: %s (unreachable: %s)\n",
+	"Espelho: %s (em dia)\n":                                                         "Mirror: %s (up to date)\n",
+	"Espelho: %s (desatualizado; use furgfs replica sync)\n":                         "Mirror: %s (outdated; use furgfs replica sync)\n",
+	"erro: nenhum espelho configurado":                                               "error: no mirror configured",
+	"A imagem deixou de ser replicada para '%s'; o espelho não foi apagado.\n":       "The image is no longer replicated to '%s'; the mirror was not deleted.\n",
+	"mantém a imagem aberta e executa os comandos dos outros furgfs, um de cada vez": "keeps the image open and runs the commands of the other furgfs processes, one at a time",
+	"O daemon também é iniciado pelo mesmo executável com o nome furgfsd. Enquanto ele estiver em execução,\nos comandos do furgfs sobre a imagem são enviados a ele por um socket Unix, com o diretório atual, as\nvariáveis FURGFS_* e AWS_* e a entrada padrão, quando ela não é um terminal; assim vários processos usam\na imagem ao mesmo tempo sem que um desfaça as alterações do outro. A FAT e o diretório ficam na memória\nentre os comandos, e a senha de um volume cifrado só é pedida uma vez.": "The daemon is also started by the same executable under the name furgfsd. While it is running, furgfs\ncommands on the image are sent to it over a Unix socket, with the current directory, the FURGFS_* and\nAWS_* variables and the standard input, when it is not a terminal; this way several processes use the\nimage at the same time without one undoing the changes of the other. The FAT and the directory stay in\nmemory between commands, and the password of an encrypted volume is only asked once.",
+	"A imagem fica travada: outros processos que a abrem diretamente, como serve, mount, shell e o menu\ninterativo, são recusados enquanto o daemon estiver em execução, e o daemon não inicia sobre uma imagem\nem uso. O socket fica em um diretório temporário do usuário, derivado do caminho da imagem; --socket e a\nvariável FURGFS_SOCKET, que os clientes também consultam, escolhem outro.":                                                                                                         "The image is locked: other processes that open it directly, such as serve, mount, shell and the\ninteractive menu, are refused while the daemon is running, and the daemon does not start on an image in\nuse. The socket lives in a temporary directory of the user, derived from the image path; --socket and\nthe FURGFS_SOCKET variable, which clients also check, choose another one.",
+	"erro: a imagem '%s' está aberta pelo furgfsd; use os comandos do furgfs ou encerre-o": "error: the image '%s' is open by furgfsd; use the furgfs commands or stop it",
+	"erro: %s usa o terminal e não pode ser executado pelo furgfsd":                        "error: %s uses the terminal and cannot be run by furgfsd",
+	"erro ao enviar o comando ao furgfsd: %v":                                              "error sending the command to furgfsd: %v",
+	"erro: a conexão com o furgfsd foi interrompida: %v":                                   "error: the connection to furgfsd was interrupted: %v",
+	"uso: furgfs daemon [--socket <caminho>] [imagem]":                                     "usage: furgfs daemon [--socket <path>] [image]",
+	"erro ao abrir o arquivo: %w":                                                          "error opening the file: %w",
+	"erro: a imagem '%s' está em uso por outro processo":                                   "error: the image '%s' is in use by another process",
+	"erro ao criar o diretório do socket: %v":                                              "error creating the socket directory: %v",
+	"erro: já há um furgfsd atendendo em '%s'":                                             "error: there is already a furgfsd listening on '%s'",
+	"furgfsd: imagem '%s' aberta, comandos em %s; Ctrl+C para encerrar.\n":                 "furgfsd: image '%s' open, commands on %s; Ctrl+C to stop.\n",
+	"furgfsd encerrado.":                         "furgfsd stopped.",
+	"erro: diretório do cliente inacessível: %v": "error: client directory inaccessible: %v",
+	"erro: %s abre a imagem por conta própria e não pode ser executado pelo furgfsd": "error: %s opens the image on its own and cannot be run by furgfsd",
+	"As operações (comando, carga e gravação do estado, alocação, leitura e escrita de blocos e busca no\ndiretório) geram spans do OpenTelemetry: OTEL_TRACES_EXPORTER=otlp, ou apenas OTEL_EXPORTER_OTLP_ENDPOINT,\nos envia por OTLP/HTTP, e console os escreve em JSON na saída de erro.": "Operations (command, loading and saving the state, allocation, block reads and writes and directory\nlookups) produce OpenTelemetry spans: OTEL_TRACES_EXPORTER=otlp, or just OTEL_EXPORTER_OTLP_ENDPOINT,\nsends them over OTLP/HTTP, and console writes them as JSON to standard error.",
+	"erro: exportador de traces '%s' não suportado (use otlp, console ou none)": "error: trace exporter '%s' not supported (use otlp, console or none)",
+	"passa a registrar as operações que alteram o volume, na imagem ou em um arquivo externo\ndeixa de registrar as operações, mantendo os registros\nmostra os registros, filtrados por data, usuário, caminho ou falha":                                                                                                                                       "starts recording the operations that change the volume, in the image or in an external file\nstops recording operations, keeping the records\nshows the records, filtered by date, user, path or failure",
+	"Cada registro traz a data, o usuário, a origem (cli, menu, tui, mount ou o protocolo de \"serve\"), a\noperação, o caminho ou os argumentos do comando e o resultado. Localmente, o usuário é o do sistema\noperacional; nos servidores, o usuário autenticado. As consultas não são registradas, apenas os\ncomandos que alteraram a imagem ou falharam.": "Each record holds the date, the user, the source (cli, menu, tui, mount or the \"serve\" protocol), the\noperation, the path or the command arguments and the result. Locally, the user is the operating\nsystem user; on servers, the authenticated user. Queries are not recorded, only the commands that\nchanged the image or failed.",
+	"Sem --file, os registros ficam em segmentos de 64 KiB em /.furgfs/audit-NNNNNN.jsonl, protegidos e\nnunca reescritos depois de cheios; com --file, são acrescentados ao arquivo indicado, uma linha JSON\npor operação. --since aceita uma duração (24h) ou uma data (2026-01-31 ou 2026-01-31T08:00:00).":                                                 "Without --file, the records are kept in 64 KiB segments in /.furgfs/audit-NNNNNN.jsonl, protected and\nnever rewritten once full; with --file, they are appended to the given file, one JSON line per\noperation. --since accepts a duration (24h) or a date (2026-01-31 or 2026-01-31T08:00:00).",
+	"uso: furgfs audit enable [--file <arquivo>] | audit disable | audit list [--since <data>] [--user <nome>] [--path <caminho>] [--failed] [--json]":                                                                                                                                                                                                          "usage: furgfs audit enable [--file <file>] | audit disable | audit list [--since <date>] [--user <name>] [--path <path>] [--failed] [--json]",
+	"erro: registro inválido em %s, linha %d: %v":                           "error: invalid record in %s, line %d: %v",
+	"erro: data '%s' inválida (use 24h, 2006-01-02 ou 2006-01-02T15:04:05)": "error: invalid date '%s' (use 24h, 2006-01-02 or 2006-01-02T15:04:05)",
+	"Auditoria ativa; os registros vão para '%s'.\n":                        "Auditing enabled; records go to '%s'.\n",
+	"Auditoria ativa; os registros ficam em %s.\n":                          "Auditing enabled; records are kept in %s.\n",
+	"A auditoria já está desativada.":                                       "Auditing is already disabled.",
+	"Auditoria desativada; os registros anteriores foram mantidos.":         "Auditing disabled; earlier records were kept.",
+	"Nenhum registro de auditoria.":                                         "No audit records.",
+	"Data":                                                                  "Date",
+	"Usuário":                                                               "User",
+	"Origem":                                                                "Source",
+	"Operação":                                                              "Operation",
+	"Alvo e resultado":                                                      "Target and result",
+	"Enquanto \"serve\" ou \"mount\" estiver em execução, cada arquivo ou diretório criado, alterado ou\napagado gera um POST com um JSON como {\"event\": \"create\", \"path\": \"/entrada/a.txt\", \"type\":\n\"file\", \"size\": 120, \"user\": \"ana\", \"source\": \"sftp\", \"volume\": \"furg.fs2\", \"time\": ...}; o\ncabeçalho X-Furgfs-Event traz o evento. Uma renomeação gera delete do caminho antigo e create do novo.\nOs comandos da linha de comando não geram eventos.": "While \"serve\" or \"mount\" is running, each file or directory created, modified or deleted\ntriggers a POST with a JSON body such as {\"event\": \"create\", \"path\": \"/inbox/a.txt\", \"type\":\n\"file\", \"size\": 120, \"user\": \"ana\", \"source\": \"sftp\", \"volume\": \"furg.fs2\", \"time\": ...}; the\nX-Furgfs-Event header carries the event. A rename produces a delete of the old path and a create of\nthe new one. Command-line commands do not produce events.",
+	"Os eventos são enviados em segundo plano, na ordem em que ocorreram; falhas de rede e respostas 5xx\nsão repetidas até 4 vezes. Com --secret, X-Furgfs-Signature traz \"sha256=\" e o HMAC-SHA256 do corpo\nem hexadecimal. Os webhooks ficam em /.furgfs/webhooks.json; cadastrar de novo uma URL troca seus\neventos e sua chave.":                                                                                                                                                  "Events are sent in the background, in the order they happened; network failures and 5xx responses\nare retried up to 4 times. With --secret, X-Furgfs-Signature carries \"sha256=\" and the hex\nHMAC-SHA256 of the body. Webhooks are kept in /.furgfs/webhooks.json; adding a URL again replaces\nits events and key.",
+	"cadastra uma URL avisada das alterações feitas pelos servidores e pelo ponto de montagem\nlista os webhooks e os eventos de cada um\nremove um webhook\nenvia um evento de teste e mostra se ele foi aceito":                                                                                                                                                                                                                                                                          "registers a URL notified of the changes made by the servers and the mount point\nlists the webhooks and the events of each one\nremoves a webhook\nsends a test event and shows whether it was accepted",
+	"uso: furgfs webhook add [--events create,modify,delete] [--secret <chave>] <url> | webhook list [--json] | webhook remove <url> | webhook test <url>":                                                                                                                                                                                                                                                                                                                                 "usage: furgfs webhook add [--events create,modify,delete] [--secret <key>] <url> | webhook list [--json] | webhook remove <url> | webhook test <url>",
+	"erro: webhook '%s' não cadastrado":                "error: webhook '%s' is not registered",
+	"erro: URL '%s' inválida; use http:// ou https://": "error: invalid URL '%s'; use http:// or https://",
+	"erro: evento '%s' desconhecido; use %s":           "error: unknown event '%s'; use %s",
+	"Webhook '%s' cadastrado.\n":                       "Webhook '%s' registered.\n",
+	"Webhook '%s' removido.\n":                         "Webhook '%s' removed.\n",
+	"erro ao enviar para '%s': %v":                     "error sending to '%s': %v",
+	"Evento de teste entregue a '%s'.\n":               "Test event delivered to '%s'.\n",
+	"Nenhum webhook cadastrado.":                       "No webhooks registered.",
+	" (assinado)":                                      " (signed)",
+	"A cada estado salvo, seja por um comando, pelo menu ou por um servidor, o diário registra as entradas\ncriadas, alteradas (conteúdo, tamanho ou proteção) e apagadas desde o estado anterior. Uma entrada\nmovida aparece como delete do caminho antigo e create do novo; as remoções vêm antes, dos filhos para\nos pais, e as criações depois, dos pais para os filhos.":                                      "On each saved state, whether by a command, the menu or a server, the journal records the entries\ncreated, modified (content, size or protection) and deleted since the previous state. A moved entry\nshows up as a delete of the old path and a create of the new one; deletions come first, from children\nto parents, then creations, from parents to children.",
+	"Uma ferramenta de sincronização guarda o last_seq de \"journal since --json\" e o usa na consulta\nseguinte. Os registros ficam em /.furgfs/journal-*.jsonl e só os 16 segmentos mais recentes, de até\n64 KiB, são mantidos: uma sequência mais antiga que a primeira disponível termina com o código 3, e o\nidentificador muda quando o diário é recriado; nos dois casos, faça uma sincronização completa.": "A sync tool keeps the last_seq of \"journal since --json\" and uses it in the next query. Records are\nkept in /.furgfs/journal-*.jsonl and only the 16 most recent segments, of up to 64 KiB, are kept: a\nsequence older than the first available one ends with exit code 3, and the identifier changes when the\njournal is recreated; in both cases, do a full sync.",
+	"passa a registrar as alterações das entradas, com números de sequência crescentes\napaga o diário (exige --force)\nmostra o identificador do diário e as sequências disponíveis\nlista as alterações com sequência maior que a indicada":                                                                                                                                                                        "starts recording entry changes, with increasing sequence numbers\ndeletes the journal (requires --force)\nshows the journal identifier and the available sequences\nlists the changes with a sequence greater than the given one",
+	"uso: furgfs journal enable | journal disable | journal status [--json] | journal since [--json] <sequência>": "usage: furgfs journal enable | journal disable | journal status [--json] | journal since [--json] <sequence>",
+	"erro ao gravar o diário de alterações: %w":                                                                   "error writing the change journal: %w",
+	"erro: o diário não tem mais as alterações desde %d (a mais antiga é %d); faça uma sincronização completa":    "error: the journal no longer has the changes since %d (the oldest is %d); do a full sync",
+	"O diário '%s' já está ativo.\n":                                                                              "Journal '%s' is already active.\n",
+	"Diário de alterações '%s' ativo.\n":                                                                          "Change journal '%s' active.\n",
+	"O volume não tem diário de alterações.":                                                                      "The volume has no change journal.",
+	"O diário de alterações será apagado.":                                                                        "The change journal will be deleted.",
+	"Diário de alterações apagado.":                                                                               "Change journal deleted.",
+	"Diário:           %s (criado em %s)\n":                                                                       "Journal:          %s (created %s)\n",
+	"Sequências:       %d a %d\n":                                                                                 "Sequences:        %d to %d\n",
+	"Segmentos:        %d de até %d\n":                                                                            "Segments:         %d of up to %d\n",
+	"erro: sequência '%s' inválida":                                                                               "error: invalid sequence '%s'",
+	"erro: o volume não tem diário de alterações; use furgfs journal enable":                                      "error: the volume has no change journal; use furgfs journal enable",
+	"Com --archives, os arquivos .zip, .tar, .tar.gz e .tgz aparecem como diretórios somente leitura com o\nseu conteúdo, lido direto da imagem sem extrair o arquivo. Nesse modo, o próprio arquivo também não pode\nser alterado, movido nem apagado pelo ponto de montagem.":                                                                                                                                  "With --archives, .zip, .tar, .tar.gz and .tgz files appear as read-only directories with their\ncontents, read straight from the image without extracting the archive. In this mode the archive itself\ncannot be changed, moved or deleted through the mount point either.",
+	"Com --archives, os arquivos .zip, .tar, .tar.gz e .tgz aparecem como diretórios somente leitura com o\nseu conteúdo (/arquivos/dados.zip/docs/a.txt), lido direto da imagem sem extrair o arquivo; os membros\nde um tar sem compressão são lidos em qualquer posição, e os de um zip ou tar.gz, do início. Nesse modo,\no próprio arquivo também não pode ser alterado, movido nem apagado pelo servidor.": "With --archives, .zip, .tar, .tar.gz and .tgz files appear as read-only directories with their\ncontents (/archives/data.zip/docs/a.txt), read straight from the image without extracting the archive;\nmembers of an uncompressed tar are read at any offset, and those of a zip or tar.gz from the start. In\nthis mode the archive itself cannot be changed, moved or deleted through the server either.",
+	"erro ao abrir o arquivo zip: %v":         "error opening zip archive: %v",
+	"erro ao descompactar o arquivo gzip: %v": "error decompressing gzip file: %v",
+	"erro ao ler o arquivo tar: %v":           "error reading tar archive: %v",
+	"atende o Docker como plugin de volumes, guardando cada volume em uma imagem montada com FUSE": "serves Docker as a volume plugin, keeping each volume in an image mounted with FUSE",
+	"O comando fica em execução, em geral como root, até receber Ctrl+C ou SIGTERM, quando desmonta os\nvolumes em uso. O Docker encontra o plugin pelo socket /run/docker/plugins/furgfs.sock (veja --socket), e os\nvolumes são criados com \"docker volume create -d furgfs\". Cada volume é a imagem <nome>.fs2 no diretório\n/var/lib/furgfs/volumes (veja --root), montada em mounts/<nome> enquanto algum contêiner a usa.": "The command keeps running, usually as root, until it receives Ctrl+C or SIGTERM, when it unmounts the\nvolumes in use. Docker finds the plugin through the socket /run/docker/plugins/furgfs.sock (see --socket), and\nvolumes are created with \"docker volume create -d furgfs\". Each volume is the image <name>.fs2 in the\ndirectory /var/lib/furgfs/volumes (see --root), mounted at mounts/<name> while some container uses it.",
+	"As opções size (padrão 64M), block-size, entries (padrão 1000) e label de \"docker volume create -o\"\nvalem para a imagem criada, como em mkfs; -o image=<arquivo> usa uma imagem existente, que não é\napagada com o volume. Disponível apenas no Linux e no macOS.":                                                                                                                                                        "The size (default 64M), block-size, entries (default 1000) and label options of \"docker volume create -o\"\napply to the created image, as in mkfs; -o image=<file> uses an existing image, which is not deleted\nwith the volume. Available on Linux and macOS only.",
+	"erro: docker-plugin exige FUSE, disponível apenas no Linux e no macOS":                "error: docker-plugin requires FUSE, available on Linux and macOS only",
+	"uso: furgfs docker-plugin [--socket <caminho>] [--root <diretório>]":                  "usage: furgfs docker-plugin [--socket <path>] [--root <directory>]",
+	"erro: volume '%s' não encontrado":                                                     "error: volume '%s' not found",
+	"erro: nome de volume '%s' inválido":                                                   "error: invalid volume name '%s'",
+	"erro: imagem '%s' não encontrada":                                                     "error: image '%s' not found",
+	"erro: opção '%s' desconhecida; use size, block-size, entries, label ou image":         "error: unknown option '%s'; use size, block-size, entries, label or image",
+	"erro: o volume '%s' está em uso":                                                      "error: volume '%s' is in use",
+	"Plugin de volumes do Docker em '%s', com as imagens em '%s'; Ctrl+C para encerrar.\n": "Docker volume plugin at '%s', with images in '%s'; Ctrl+C to stop.\n",
+	"Com --cas, os arquivos são guardados por conteúdo: cada bloco é identificado pelo SHA-256 dos seus\ndados e guardado uma vez só, mesmo que se repita em vários arquivos, e cada arquivo passa a ser a lista\ndos hashes dos seus blocos. Cópias e snapshots não duplicam os dados, e verify-image confere o conteúdo\nde cada bloco com o hash registrado. O modo só é escolhido na criação da imagem; compact e undelete não\nsão suportados nele.": "With --cas, files are stored by content: each block is identified by the SHA-256 of its data and\nstored only once, even if it repeats across files, and each file becomes the list of the hashes of its\nblocks. Copies and snapshots do not duplicate data, and verify-image checks the content of each block\nagainst the recorded hash. The mode is chosen only when the image is created; compact and undelete are\nnot supported in it.",
+	"erro: %s não é suportado em volumes com armazenamento por conteúdo":    "error: %s is not supported on volumes with content-addressed storage",
+	"erro: o manifesto de '%s' está incompleto":                             "error: the manifest of '%s' is incomplete",
+	"erro: bloco %d fora da FAT no manifesto de '%s'":                       "error: block %d outside the FAT in the manifest of '%s'",
+	"erro: o snapshot não guarda os hashes de '%s'":                         "error: the snapshot does not hold the hashes of '%s'",
+	"'%s': o bloco %d (trecho %d) não confere com o hash do manifesto":      "'%s': block %d (chunk %d) does not match the manifest hash",
+	"  Erro ao gravar o manifesto:":                                         "  Error writing the manifest:",
+	"  conteúdo: %d de %d arquivo(s) conferidos com os hashes dos blocos\n": "  content: %d of %d file(s) checked against the block hashes\n",
+	"  conteúdo: %d arquivo(s) não conferidos (o volume é cifrado)\n":       "  content: %d file(s) not checked (the volume is encrypted)\n",
+	"--hash lista só os arquivos com o conteúdo de SHA-256 indicado, como as cópias de um arquivo. Sem o\níndice de metadados (veja index), todos os arquivos são lidos.":                                                                                                                                                                                     "--hash lists only the files whose content has the given SHA-256, such as the copies of a file. Without\nthe metadata index (see index), every file is read.",
+	"O índice é um banco bbolt em <imagem>.idx, atualizado a cada estado salvo, com a posição de cada\nentrada, o primeiro bloco e o SHA-256 do conteúdo dos arquivos, além dos registros da auditoria pelo\ncaminho. Com ele, find --hash e sync --checksum não leem o conteúdo dos arquivos da imagem, e\naudit list --path lê só os registros do caminho.": "The index is a bbolt database in <image>.idx, updated on every saved state, with the position of each\nentry, the first block and the SHA-256 of the file contents, plus the audit records by path. With it,\nfind --hash and sync --checksum do not read the contents of the image files, and audit list --path\nreads only the records of the path.",
+	"O índice é opcional e pode ser apagado a qualquer momento. Se ele não corresponder à imagem, porque\numa gravação foi interrompida ou a imagem foi alterada sem ele, é refeito ao abri-la. Imagens\nremotas não têm índice.":                                                                                                                             "The index is optional and can be deleted at any time. If it does not match the image, because a write\nwas interrupted or the image was changed without it, it is rebuilt when the image is opened. Remote\nimages have no index.",
+	"cria o índice de metadados ao lado da imagem, com os caminhos e os hashes do conteúdo\napaga o índice\nrefaz o índice lendo todos os arquivos\nmostra o arquivo do índice e o que ele contém":                                                                                                                                                            "creates the metadata index next to the image, with the paths and content hashes\ndeletes the index\nrebuilds the index by reading every file\nshows the index file and what it contains",
+	"erro ao abrir o índice '%s': %v":                                                          "error opening index '%s': %v",
+	"erro ao indexar '%s': %w":                                                                 "error indexing '%s': %w",
+	"erro ao ler '%s': %w":                                                                     "error reading '%s': %w",
+	"erro: o índice de metadados só é mantido para imagens locais":                             "error: the metadata index is only kept for local images",
+	"O índice '%s' já está ativo.\n":                                                           "Index '%s' is already active.\n",
+	"Índice de metadados '%s' criado com %d entrada(s).\n":                                     "Metadata index '%s' created with %d entry(ies).\n",
+	"O volume não tem índice de metadados.":                                                    "The volume has no metadata index.",
+	"Índice de metadados apagado.":                                                             "Metadata index deleted.",
+	"erro: o volume não tem índice de metadados; use furgfs index enable":                      "error: the volume has no metadata index; use furgfs index enable",
+	"Índice de metadados refeito com %d entrada(s).\n":                                         "Metadata index rebuilt with %d entry(ies).\n",
+	"Índice:           %s (%d bytes)\n":                                                        "Index:            %s (%d bytes)\n",
+	"Entradas:         %d (%d arquivo(s) com hash)\n":                                          "Entries:          %d (%d file(s) with a hash)\n",
+	"Auditoria:        %d registro(s)\n":                                                       "Audit:            %d record(s)\n",
+	"erro: hash '%s' inválido; use os 64 dígitos hexadecimais do SHA-256":                      "error: invalid hash '%s'; use the 64 hexadecimal digits of the SHA-256",
+	"exporta a tabela do diretório inteira em CSV ou JSON, para planilhas e análises externas": "exports the whole directory table as CSV or JSON, for spreadsheets and external analysis",
+	"Cada entrada traz índice, caminho, nome, diretório pai, tipo, tamanho, número de blocos, primeiro\nbloco, fragmentos e as marcas protected e system (dentro de /.furgfs). Sem --format, o formato vem da\nextensão do arquivo; na saída padrão, o padrão é CSV.":                               "Each entry has the index, path, name, parent directory, type, size, number of blocks, first block,\nfragments and the protected and system flags (inside /.furgfs). Without --format, the format comes from\nthe file extension; on standard output, the default is CSV.",
+	"A imagem não guarda datas: created e modified vêm do diário de alterações (veja journal), quando\nativo, e ficam vazias nas entradas anteriores aos registros disponíveis. --checksum acrescenta o\nSHA-256 do conteúdo dos arquivos, lido do índice de metadados (veja index) quando houver.": "The image stores no dates: created and modified come from the change journal (see journal), when\nactive, and are empty for entries older than the available records. --checksum adds the SHA-256 of\nthe file contents, read from the metadata index (see index) when there is one.",
+	"erro: formato '%s' desconhecido (use csv ou json)":                              "error: unknown format '%s' (use csv or json)",
+	"Catálogo de %d entrada(s) exportado para '%s'.\n":                               "Catalog of %d entry(ies) exported to '%s'.\n",
+	"cria ou atualiza o volume para que ele corresponda a um manifesto YAML ou JSON": "creates or updates the volume so that it matches a YAML or JSON manifest",
+	"O manifesto descreve o volume (size, block-size, entries, label e cas, usados só ao criar a imagem),\nos diretórios, com quota e quota-files opcionais, e os arquivos, cada um com path, o conteúdo em source\n(um arquivo ou diretório do sistema real, relativo ao manifesto) ou em content, e protected. Um\ndiretório em source é sincronizado como em sync.": "The manifest describes the volume (size, block-size, entries, label and cas, used only when creating\nthe image), the directories, with optional quota and quota-files, and the files, each with path, the\ncontent in source (a host file or directory, relative to the manifest) or in content, and protected. A\ndirectory in source is synchronized as in sync.",
+	"Só o que difere é alterado, e aplicar o mesmo manifesto de novo não altera nada. Com --prune, as\nentradas que o manifesto não declara são apagadas (exige --force); -n só mostra o que seria feito.\nArquivos protegidos só são regravados com --override-protection.":                                                                                           "Only what differs is changed, and applying the same manifest again changes nothing. With --prune,\nentries the manifest does not declare are deleted (requires --force); -n only shows what would be\ndone. Protected files are only rewritten with --override-protection.",
+	"erro ao ler o manifesto: %v":                                                                                 "error reading the manifest: %v",
+	"erro: manifesto '%s' inválido: %v":                                                                           "error: invalid manifest '%s': %v",
+	"erro: o caminho '%s' do manifesto não é absoluto":                                                            "error: manifest path '%s' is not absolute",
+	"erro: o diretório de sistema %s não pode ser provisionado":                                                   "error: the system directory %s cannot be provisioned",
+	"erro: o arquivo '%s' do manifesto precisa de source ou de content":                                           "error: manifest file '%s' needs source or content",
+	"erro: '%s' é um arquivo no volume e um diretório no manifesto":                                               "error: '%s' is a file in the volume and a directory in the manifest",
+	"erro: '%s' é um diretório no volume e um arquivo no manifesto":                                               "error: '%s' is a directory in the volume and a file in the manifest",
+	"erro: '%s' não existe no sistema real":                                                                       "error: '%s' does not exist on the host",
+	"rótulo '%s'":                                                                                                 "label '%s'",
+	"cota de '%s'":                                                                                                "quota of '%s'",
+	"A imagem '%s' seria criada com o conteúdo do manifesto.\n":                                                   "Image '%s' would be created with the manifest contents.\n",
+	"As entradas que o manifesto não declara serão apagadas.":                                                     "Entries the manifest does not declare will be deleted.",
+	"Simulação: %d entrada(s) a criar, %d a alterar, %d a remover, %d arquivo(s) sem alteração.\n":                "Dry run: %d entry(ies) to create, %d to change, %d to remove, %d file(s) unchanged.\n",
+	"Manifesto aplicado: %d entrada(s) criada(s), %d alterada(s), %d removida(s), %d arquivo(s) sem alteração.\n": "Manifest applied: %d entry(ies) created, %d changed, %d removed, %d file(s) unchanged.\n",
+	"envia arquivos e diretórios direto a um bucket S3 ou Google Cloud Storage":                                   "uploads files and directories straight to an S3 or Google Cloud Storage bucket",
+	"Cada arquivo vira o objeto <prefixo>/<nome>, e um diretório leva junto o caminho relativo de cada\narquivo abaixo dele; os filtros são os de get -r. O conteúdo é lido da imagem enquanto é enviado, com\n-j envios ao mesmo tempo (padrão 4), e um envio que falha por erro de rede ou resposta 5xx é repetido\naté --retries vezes (padrão 3).": "Each file becomes the object <prefix>/<name>, and a directory brings along the relative path of every\nfile below it; the filters are those of get -r. Content is read from the image while it is uploaded, with\n-j concurrent uploads (default 4), and an upload that fails with a network error or a 5xx response is\nretried up to --retries times (default 3).",
+	"O S3 usa as mesmas variáveis das imagens s3:// (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION\ne FURGFS_S3_ENDPOINT); o Cloud Storage usa o token OAuth de GOOGLE_OAUTH_ACCESS_TOKEN, por exemplo o de\n\"gcloud auth print-access-token\", e FURGFS_GCS_ENDPOINT para emuladores.":                                                        "S3 uses the same variables as s3:// images (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION\nand FURGFS_S3_ENDPOINT); Cloud Storage uses the OAuth token in GOOGLE_OAUTH_ACCESS_TOKEN, such as the one from\n\"gcloud auth print-access-token\", and FURGFS_GCS_ENDPOINT for emulators.",
+	"erro: destino '%s' inválido, use s3://bucket/prefixo ou gs://bucket/prefixo":                                            "error: invalid destination '%s', use s3://bucket/prefix or gs://bucket/prefix",
+	"erro: defina GOOGLE_OAUTH_ACCESS_TOKEN para enviar ao Cloud Storage":                                                    "error: set GOOGLE_OAUTH_ACCESS_TOKEN to upload to Cloud Storage",
+	"uso: furgfs export-cloud [-j N] [--retries N] [filtros] <caminho-interno>... <s3://bucket/prefixo|gs://bucket/prefixo>": "usage: furgfs export-cloud [-j N] [--retries N] [filters] <internal-path>... <s3://bucket/prefix|gs://bucket/prefix>",
+	"erro: -j deve ser pelo menos 1 e --retries não pode ser negativo":                                                       "error: -j must be at least 1 and --retries cannot be negative",
+	"erro ao enviar '%s': %v": "error uploading '%s': %v",
+	"%d arquivo(s) enviado(s) para '%s' (%d bytes), %d entrada(s) ignorada(s) pelos filtros.\n": "%d file(s) uploaded to '%s' (%d bytes), %d entry(ies) skipped by filters.\n",
+	"erro: %d arquivo(s) não foram enviados":                                                    "error: %d file(s) were not uploaded",
+	"Para arquivos grandes, a API REST (POST /api/v1/uploads) e o gRPC (StartUpload, SendChunks e\nCommitUpload) aceitam envios retomáveis: os pedaços, cada um com a sua posição e, opcionalmente, o seu\nSHA-256, ficam em <imagem>.uploads até o arquivo estar completo, e um envio interrompido continua do\núltimo byte recebido. Os downloads continuam de um offset, e cada pedaço vem com o seu SHA-256.": "For large files, the REST API (POST /api/v1/uploads) and gRPC (StartUpload, SendChunks and\nCommitUpload) accept resumable uploads: the chunks, each with its position and, optionally, its\nSHA-256, stay in <image>.uploads until the file is complete, and an interrupted upload resumes from\nthe last byte received. Downloads resume from an offset, and each chunk comes with its SHA-256.",
+	"erro: tamanho inválido; o FURGfs2 guarda arquivos de até 4 GB":           "error: invalid size; FURGfs2 stores files of up to 4 GB",
+	"erro: espaço insuficiente para '%s'":                                     "error: not enough space for '%s'",
+	"erro: o envio passaria do tamanho declarado (%d bytes)":                  "error: the upload would exceed its declared size (%d bytes)",
+	"erro: o SHA-256 do arquivo recebido não confere; o envio foi descartado": "error: the SHA-256 of the received file does not match; the upload was discarded",
+	"As escritas de blocos de dados ficam em um cache em memória de até 8 MB e vão para a imagem em lotes\nsequenciais, sempre antes dos metadados; FURGFS_WRITE_CACHE muda o tamanho (por exemplo, 32M), e 0\ndesliga o cache.":                                                                                                                "Data block writes are held in an in-memory cache of up to 8 MB and reach the image in sequential\nbatches, always before the metadata; FURGFS_WRITE_CACHE changes the size (for example, 32M), and 0\nturns the cache off.",
+	"Com --mmap, ou FURGFS_MMAP=1, as imagens locais são mapeadas na memória: a FAT, o diretório e os blocos\nsão lidos e escritos como memória, e o sistema operacional cuida do cache (somente Linux e macOS).":                                                                                                                               "With --mmap, or FURGFS_MMAP=1, local images are mapped into memory: the FAT, the directory and the blocks\nare read and written as memory, and the operating system handles caching (Linux and macOS only).",
+	"Com --commit-delay <duração>, as alterações são gravadas em lotes, como em serve; um fsync grava o\nlote em aberto na hora.":                                                                                                                                                                                                               "With --commit-delay <duration>, changes are saved in batches, as in serve; an fsync saves the\nopen batch immediately.",
+	"Com --commit-delay <duração> (por exemplo, 200ms), as alterações feitas nesse intervalo são gravadas\njuntas: o diário e os metadados de uma rajada de operações vão para a imagem de uma vez ao fim do\nintervalo. Uma queda do processo perde, no máximo, as alterações do último intervalo; ao encerrar, o lote\nem aberto é gravado.":  "With --commit-delay <duration> (for example, 200ms), changes made within that interval are saved\ntogether: the journal and the metadata of a burst of operations go to the image at once at the end of the\ninterval. A crash loses at most the changes of the last interval; on shutdown, the open batch\nis saved.",
+	"Em mount e serve, FURGFS_COMMIT_DELAY (por exemplo, 200ms) tem o efeito de --commit-delay: as\nalterações feitas no intervalo são gravadas juntas, com uma só gravação dos metadados.":                                                                                                                                                     "In mount and serve, FURGFS_COMMIT_DELAY (for example, 200ms) has the effect of --commit-delay:\nchanges made within the interval are saved together, with a single metadata write.",
+	"uso: furgfs mount [--allow-other] [--debug] [--archives] [--commit-delay <duração>] [imagem] <ponto-de-montagem>":                                                                                                                                                                                                                          "usage: furgfs mount [--allow-other] [--debug] [--archives] [--commit-delay <duration>] [image] <mount-point>",
+	"mede a importação, a busca por caminho e o salvamento do estado sobre uma carga sintética":                                                                                                                                                                                                                                                 "measures import, path lookup and state saving on a synthetic workload",
+	"Os benchmarks Import, Lookup e Save rodam sobre imagens temporárias, com uma carga gerada a partir de\n--seed: a maioria dos arquivos cabe em um bloco e um quarto deles tem até --max-size bytes. A mesma carga\nmede o mesmo trabalho em outra versão do programa. Com a carga padrão, os mesmos benchmarks rodam com\ngo test -bench .": "The Import, Lookup and Save benchmarks run on temporary images, with a workload generated from\n--seed: most files fit in one block and a quarter of them are up to --max-size bytes. The same workload\nmeasures the same work in another version of the program. With the default workload, the same\nbenchmarks run with go test -bench .",
+	"--save grava os resultados em JSON; --baseline compara com um arquivo gravado antes e termina com erro se\nalgum benchmark ficou mais de --tolerance por cento (padrão 10) mais lento, o que permite medir uma\nalteração no alocador ou no cache antes de aceitá-la.":                                                                     "--save writes the results as JSON; --baseline compares against a previously saved file and exits with an error if\nany benchmark got more than --tolerance percent (default 10) slower, so a change to the allocator\nor the cache can be measured before it is accepted.",
+	"erro: a carga não cabe em uma imagem; use menos arquivos ou um --max-size menor":                                                                                                                                                                                                                                                           "error: the workload does not fit in an image; use fewer files or a smaller --max-size",
+	"erro: tamanho inválido '%s'": "error: invalid size '%s'",
+	"uso: furgfs bench [--files <n>] [--dirs <n>] [--max-size <tamanho>] [--block-size <bytes>] [--seed <n>] [--benchtime <duração>] [--save <arquivo>] [--baseline <arquivo> [--tolerance <porcento>]] [benchmark...]": "usage: furgfs bench [--files <n>] [--dirs <n>] [--max-size <size>] [--block-size <bytes>] [--seed <n>] [--benchtime <duration>] [--save <file>] [--baseline <file> [--tolerance <percent>]] [benchmark...]",
+	"erro: benchmark '%s' desconhecido; use Import, Lookup ou Save":                     "error: unknown benchmark '%s'; use Import, Lookup or Save",
+	"aviso: a carga da referência difere da atual; a comparação pode não fazer sentido": "warning: the baseline workload differs from the current one; the comparison may not be meaningful",
+	"erro: duração inválida '%s'":                                                       "error: invalid duration '%s'",
+	"Carga: %d arquivos em %d diretórios, %d bytes no total, blocos de %d bytes.\n":     "Workload: %d files in %d directories, %d bytes in total, %d-byte blocks.\n",
+	"erro no benchmark %s: %w":                                                          "error in benchmark %s: %w",
+	"REGRESSÃO":                                                                         "REGRESSION",
+	"erro: %d benchmark(s) mais de %s%% mais lento(s) que a referência":                 "error: %d benchmark(s) more than %s%% slower than the baseline",
+	"erro: arquivo de referência '%s' não encontrado":                                   "error: baseline file '%s' not found",
+	"erro: arquivo de referência '%s' inválido: %v":                                     "error: invalid baseline file '%s': %v",
+	"erro ao gravar '%s': %v":                                                           "error writing '%s': %v",
+	"uso: furgfs mkfs [--size 10M] [--block-size 4096] [--entries 100] [--label rótulo] [--cas] [--prealloc|--sparse] [imagem]": "usage: furgfs mkfs [--size 10M] [--block-size 4096] [--entries 100] [--label label] [--cas] [--prealloc|--sparse] [image]",
+	"A imagem é criada com o tamanho total, mas esparsa (--sparse, o padrão): ocupa no disco só o que já\nfoi escrito e cresce conforme os blocos são usados. Com --prealloc, todo o espaço é reservado na criação\n(fallocate, no Linux), para um desempenho previsível e sem o risco de o disco encher durante o uso.": "The image is created at its full size, but sparse (--sparse, the default): it takes up on disk only what\nhas been written and grows as blocks are used. With --prealloc, all the space is reserved at creation\n(fallocate, on Linux), for predictable performance and no risk of the disk filling up during use.",
+	"erro: a pré-alocação só é possível em imagens locais": "error: preallocation is only possible for local images",
+	"erro ao reservar o espaço da imagem: %v":              "error reserving the image space: %v",
+	"erro ao definir o tamanho da imagem: %v":              "error setting the image size: %v",
+	"--sync always|onclose|never, ou FURGFS_SYNC, escolhe quando a imagem é levada ao disco com fsync:\nalways a cada alteração, antes e depois dos metadados; onclose ao fim de cada comando que alterou a\nimagem e ao fechá-la; never (padrão) deixa a gravação com o sistema operacional. O fsync de um ponto\nde montagem é sempre atendido.": "--sync always|onclose|never, or FURGFS_SYNC, chooses when the image is flushed to disk with fsync:\nalways on every change, before and after the metadata; onclose at the end of each command that changed\nthe image and when closing it; never (default) leaves writing to the operating system. An fsync on a\nmount point is always honored.",
+	"erro: política de sincronização '%s' inválida (use always, onclose ou never)": "error: invalid sync policy '%s' (use always, onclose or never)",
+	"erro ao levar a imagem ao disco: %v":                                          "error flushing the image to disk: %v",
+	"protege arquivos contra escrita e remoção; --off retira a proteção":           "protects files against writing and removal; --off removes the protection",
+	"O caminho pode ser um arquivo, um diretório (afeta os arquivos dentro dele) ou um padrão glob no\núltimo elemento, como /docs/*.txt; -r inclui os subdiretórios.": "The path can be a file, a directory (affects the files inside it) or a glob pattern in the\nlast element, such as /docs/*.txt; -r includes the subdirectories.",
+	"divide um arquivo em partes numeradas <nome>.000, <nome>.001...":                                     "splits a file into numbered parts <name>.000, <name>.001...",
+	"As partes ficam no mesmo diretório do arquivo, que é mantido. O tamanho aceita os sufixos K, M e G.": "The parts are placed in the file's directory, and the file is kept. The size accepts the K, M and G suffixes.",
+	"junta em um novo arquivo os arquivos que casam com o padrão":                                         "joins the files matching the pattern into a new file",
+	"As partes são juntadas em ordem alfabética. O padrão é um glob no último elemento do caminho, como /videos/aula.mp4.*. Use aspas na linha de\ncomando para que o shell do sistema não o expanda.": "The parts are joined in alphabetical order. The pattern is a glob in the last element of the path, such as /videos/aula.mp4.*. Quote it on the\ncommand line so the system shell does not expand it.",
+	"compara dois arquivos armazenados, bloco a bloco e, se forem texto, linha a linha":                                                                                                                "compares two stored files, block by block and, for text, line by line",
+	"A comparação para no primeiro bloco diferente, que é mostrado. Arquivos de texto de até 64 KB também são\ncomparados linha a linha, com um diff unificado.":                                       "The comparison stops at the first differing block, which is shown. Text files of up to 64 KB are also\ncompared line by line, with a unified diff.",
+	"Comandos:": "Commands:",
+	"Exemplos:": "Examples:",
+	"Este comando opera diretamente sobre o arquivo da imagem e não pode ser usado em scripts.":                                     "This command works directly on the image file and cannot be used in scripts.",
+	"Ações destrutivas (remoção, gc, compactação) exigem --force; alterar arquivos protegidos exige\ntambém --override-protection.": "Destructive actions (removal, gc, compaction) require --force; changing protected files also\nrequires --override-protection.",
+	"A imagem é escolhida por --image (ou -i), pela variável FURGFS_IMAGE ou pela chave \"image\" do arquivo\nde configuração (FURGFS_CONFIG ou ~/.config/furgfs/config), nessa ordem; o padrão é furg.fs2.\nLinhas \"volume.<nome> = <caminho>\" na configuração permitem usar <nome> no lugar do caminho.":                                                                                                                                                                                                      "The image is chosen by --image (or -i), by the FURGFS_IMAGE variable or by the \"image\" key of the\nconfiguration file (FURGFS_CONFIG or ~/.config/furgfs/config), in that order; the default is furg.fs2.\n\"volume.<name> = <path>\" lines in the configuration allow using <name> instead of the path.",
+	"O idioma é escolhido por --lang, pela variável FURGFS_LANG, pela chave \"lang\" da configuração ou\npor LC_ALL/LC_MESSAGES/LANG; o padrão é o português.":                                                                                                                                                                                                                                                                                                                                                    "The language is chosen by --lang, by the FURGFS_LANG variable, by the \"lang\" configuration key or\nby LC_ALL/LC_MESSAGES/LANG; the default is Portuguese.",
+	"Use \"furgfs help <comando>\" para detalhes e exemplos, e \"furgfs help caminhos\" para as convenções de caminhos.\nSem argumentos, o programa abre o menu interativo.":                                                                                                                                                                                                                                                                                                                                      "Use \"furgfs help <command>\" for details and examples, and \"furgfs help caminhos\" for the path conventions.\nWithout arguments, the program opens the interactive menu.",
+	"Códigos de saída: 0 sucesso, 1 erro geral, 2 uso incorreto, 3 arquivo, diretório ou imagem não encontrado,\n4 espaço ou entradas insuficientes, 5 arquivo protegido, 6 imagem corrompida (execute o fsck),\n7 ação destrutiva não confirmada (falta --force). Em scripts, vale o código da primeira falha.":                                                                                                                                                                                                  "Exit codes: 0 success, 1 general error, 2 incorrect usage, 3 file, directory or image not found,\n4 not enough space or entries, 5 protected file, 6 corrupted image (run fsck),\n7 destructive action not confirmed (missing --force). In scripts, the first failure's code is used.",
+	"Caminhos internos começam com '/' (absolutos) ou são relativos ao diretório atual (veja cd);\n'.' e '..' são aceitos. O caminho de uma entrada é o do diretório pai mais o nome, por exemplo\n/docs/relatorio.pdf. Nomes têm até 32 bytes, caminhos até 128, e não podem conter '/', '*', '?' ou '['.\nCom mais de uma imagem aberta (veja open), cp e mv aceitam \"imagem:/caminho\".\nArquivos locais (do sistema real) seguem as regras do sistema operacional; '-' indica a entrada ou\na saída padrão.": "Internal paths start with '/' (absolute) or are relative to the current directory (see cd);\n'.' and '..' are accepted. An entry's path is its parent directory's path plus its name, for example\n/docs/relatorio.pdf. Names are up to 32 bytes, paths up to 128, and cannot contain '/', '*', '?' or '['.\nWith more than one image open (see open), cp and mv accept \"image:/path\".\nLocal files (on the real file system) follow the operating system's rules; '-' means standard input\nor output.",
+	"cria uma imagem vazia (--force substitui uma imagem existente);\nduas entradas guardam os metadados do volume em /.furgfs":                                                                                                                                                                                                                                                                                                                                                                                   "creates an empty image (--force replaces an existing image);\ntwo entries hold the volume metadata in /.furgfs",
+	"Os tamanhos aceitam os sufixos K, M e G. Sem a imagem, usa a imagem selecionada por --image.":                                                                                                                                                                                                                                                                                                                                                                                                                "Sizes accept the K, M and G suffixes. Without the image, the image selected by --image is used.",
+	"lista o conteúdo de um diretório; -l detalha, -R inclui subdiretórios": "lists a directory; -l shows details, -R includes subdirectories",
+	"mostra a árvore de diretórios e arquivos":                              "shows the tree of directories and files",
+	"mostra os atributos e os blocos de um arquivo ou diretório":            "shows the attributes and blocks of a file or directory",
+	"mostra o espaço livre e ocupado":                                       "shows free and used space",
+	"compara logicamente duas imagens (arquivos e metadados)":               "compares two images logically (files and metadata)",
+	"As imagens podem ser caminhos, volumes da configuração ou nomes de imagens abertas com open.\nSem a primeira imagem, compara a imagem atual com a outra.":                "Images can be paths, configured volumes or names of images opened with open.\nWithout the first image, the current image is compared with the other one.",
+	"estatísticas de uso: contagens, maiores arquivos e histograma":                                                                                                           "usage statistics: counts, largest files and histogram",
+	"cria um diretório; -p cria também os diretórios pais":                                                                                                                    "creates a directory; -p also creates the parent directories",
+	"remove um arquivo ou diretório vazio; -r remove com todo o conteúdo":                                                                                                     "removes a file or empty directory; -r removes it with all its contents",
+	"Exige --force; arquivos protegidos exigem também --override-protection. Em um pipeline e sem\ncaminhos, remove os caminhos recebidos do comando anterior, um por linha.": "Requires --force; protected files also require --override-protection. In a pipeline and without\npaths, removes the paths received from the previous command, one per line.",
+	"move ou renomeia um arquivo ou diretório":                                                                                                                                "moves or renames a file or directory",
+	"Se o destino for um diretório existente, a entrada é movida para dentro dele.":                                                                                           "If the destination is an existing directory, the entry is moved into it.",
+	"copia um arquivo ou diretório; entre imagens abertas, use imagem:/caminho":                                                                                               "copies a file or directory; between open images, use image:/path",
+	"Se o destino for um diretório existente, a entrada é copiada para dentro dele.":                                                                                          "If the destination is an existing directory, the entry is copied into it.",
+	"copia um arquivo (ou a entrada padrão) para o FURGfs2":                                                                                                                   "copies a file (or standard input) into FURGfs2",
+	"Se o caminho interno for um diretório, o arquivo mantém o nome local.":                                                                                                   "If the internal path is a directory, the file keeps its local name.",
+	"copia um arquivo do FURGfs2 para o sistema real (ou a saída padrão)":                                                                                                     "copies a file from FURGfs2 to the real file system (or standard output)",
+	"expande um arquivo tar (ou a entrada padrão) dentro do FURGfs2":                                                                                                          "extracts a tar archive (or standard input) into FURGfs2",
+	"expande um arquivo zip do sistema real (ou do FURGfs2, com --internal)":                                                                                                  "extracts a zip archive from the real file system (or from FURGfs2, with --internal)",
+	"exporta um diretório como tar/zip (ou para a saída padrão)":                                                                                                              "exports a directory as tar/zip (or to standard output)",
+	"copia todo o conteúdo para uma nova imagem com outra geometria":                                                                                                          "copies all contents into a new image with another geometry",
+	"mostra os campos do cabeçalho e verifica seu checksum\naltera um campo do cabeçalho e recalcula o checksum (exige --force)":                                              "shows the header fields and checks its checksum\nchanges a header field and recomputes the checksum (requires --force)",
+	"inspeciona o cabeçalho, a FAT e os blocos sem alterar a imagem":                                                                                                          "inspects the header, the FAT and the blocks without changing the image",
+	"Subcomandos: header; fat <início> [fim]; chain <bloco>; owner <bloco>; dump <bloco>.":                                                                                    "Subcommands: header; fat <start> [end]; chain <block>; owner <block>; dump <block>.",
+	"relatório de fragmentação e mapa de blocos":                                                                                                                              "fragmentation report and block map",
+	"verifica a consistência; -y repara sem perguntar, -n só verifica":                                                                                                        "checks consistency; -y repairs without asking, -n only checks",
+	"libera blocos usados que nenhum arquivo referencia; -n só informa":                                                                                                       "frees used blocks no file references; -n only reports",
+	"move os blocos para o início e reduz a imagem ao tamanho mínimo":                                                                                                         "moves the blocks to the start and shrinks the image to its minimum size",
+	"remove as lacunas da tabela do diretório":                                                                                                                                "removes the gaps in the directory table",
+	"lista arquivos removidos recuperáveis ou recupera o de número indicado":                                                                                                  "lists recoverable removed files or recovers the given one",
+	"executa os comandos de um arquivo de script (um por linha ou separados por ;)":                                                                                           "runs the commands of a script file (one per line or separated by ;)",
+	"Linhas iniciadas por # são comentários e argumentos com espaços podem ficar entre aspas.\nComandos ligados por '|' formam um pipeline: a saída de cada um é a entrada do seguinte.\nA mesma sintaxe vale para -c e shell.": "Lines starting with # are comments and arguments with spaces can be quoted.\nCommands joined by '|' form a pipeline: the output of each one is the input of the next.\nThe same syntax applies to -c and shell.",
+	"abre outra imagem na mesma sessão (útil em scripts)":                           "opens another image in the same session (useful in scripts)",
+	"troca a imagem sobre a qual os comandos seguintes operam":                      "switches the image the following commands operate on",
+	"salva e fecha uma imagem aberta com open":                                      "saves and closes an image opened with open",
+	"lista as imagens abertas; a atual é marcada com *":                             "lists the open images; the current one is marked with *",
+	"muda o diretório base dos caminhos relativos (útil em scripts)":                "changes the base directory of relative paths (useful in scripts)",
+	"mostra o diretório atual":                                                      "shows the current directory",
+	"abre o gerenciador de arquivos em tela cheia (FURGfs2 e sistema real)":         "opens the full-screen file manager (FURGfs2 and the real file system)",
+	"mostra esta ajuda, a ajuda de um comando ou de um assunto (caminhos, codigos)": "shows this help, or the help of a command or topic (caminhos, codigos)",
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Uma imagem nova é criada com o tamanho total já definido, mas esparsa: o sistema de arquivos do
+// hospedeiro só ocupa espaço com as partes escritas, e a imagem cresce no disco conforme os blocos são
+// usados. Com "mkfs --prealloc", todo o espaço é reservado na criação (fallocate, no Linux), o que evita a
+// fragmentação do arquivo da imagem e garante que as escritas seguintes não falhem por falta de espaço no
+// disco hospedeiro.
+
+// preallocate reserva no disco todo o espaço da imagem recém-criada. Deve ser chamada antes de os
+// metadados serem gravados: sem fallocate, o espaço depois do cabeçalho é preenchido com zeros.
+func (fs *FURGFileSystem) preallocate() error {
+	f, ok := fs.FilePointer.(*os.File)
+	if !ok {
+		return fmt.Errorf("erro: a pré-alocação só é possível em imagens locais")
+	}
+	size := int64(fs.Header.TotalSize)
+	if err := fallocate(f, size); err == nil {
+		return nil
+	}
+	zeros := make([]byte, 1<<20)
+	for off := int64(fs.Header.FATEntrypointAddress); off < size; off += int64(len(zeros)) {
+		if _, err := f.WriteAt(zeros[:min(int64(len(zeros)), size-off)], off); err != nil {
+			return fmt.Errorf("erro ao reservar o espaço da imagem: %v", err)
+		}
+	}
+	return nil
+}
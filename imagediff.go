@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// imageChange descreve uma diferença entre duas imagens na saída de imagediff.
+type imageChange struct {
+	Kind   string `json:"kind"` // "added", "removed", "modified" ou "metadata"
+	Path   string `json:"path"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// jsonImageDiff é a saída de "imagediff --json".
+type jsonImageDiff struct {
+	Schema    int           `json:"schema"`
+	Identical bool          `json:"identical"`
+	Changes   []imageChange `json:"changes"`
+}
+
+// DiffImages compara logicamente a imagem fs com other: arquivos e diretórios adicionados, removidos ou
+// modificados (tipo, tamanho, conteúdo e proteção) e diferenças nos metadados do volume. A posição dos blocos
+// não é comparada, então uma imagem e seu clone, compactação ou cópia de segurança são consideradas iguais.
+// O diretório de sistema /.furgfs é comparado apenas pelo rótulo do volume.
+func (fs *FURGFileSystem) DiffImages(other *FURGFileSystem) ([]imageChange, error) {
+	var changes []imageChange
+
+	metadata := func(path, format string, a, b any) {
+		if a != b {
+			changes = append(changes, imageChange{Kind: "metadata", Path: path, Detail: fmt.Sprintf(format, a, b)})
+		}
+	}
+	metadata("block_size", "%v -> %v", fs.Header.BlockSize, other.Header.BlockSize)
+	metadata("image_size", "%v -> %v", fs.Header.TotalSize, other.Header.TotalSize)
+	metadata("entries", "%v -> %v", len(fs.RootDir), len(other.RootDir))
+	infoA, err := fs.VolumeInfo()
+	if err != nil {
+		return nil, err
+	}
+	infoB, err := other.VolumeInfo()
+	if err != nil {
+		return nil, err
+	}
+	metadata("label", "%q -> %q", infoA.Label, infoB.Label)
+
+	entriesA, entriesB := fs.entriesByPath(), other.entriesByPath()
+	var paths []string
+	for path := range entriesA {
+		paths = append(paths, path)
+	}
+	for path := range entriesB {
+		if _, ok := entriesA[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		a, inA := entriesA[path]
+		b, inB := entriesB[path]
+		switch {
+		case !inA:
+			changes = append(changes, imageChange{Kind: "added", Path: path, Detail: entryKind(b)})
+		case !inB:
+			changes = append(changes, imageChange{Kind: "removed", Path: path, Detail: entryKind(a)})
+		case a.IsDirectory != b.IsDirectory:
+			changes = append(changes, imageChange{Kind: "modified", Path: path, Detail: fmt.Sprintf("tipo %s -> %s", entryKind(a), entryKind(b))})
+		default:
+			var details []string
+			if a.Size != b.Size {
+				details = append(details, fmt.Sprintf("tamanho %d -> %d", a.Size, b.Size))
+			} else if !a.IsDirectory {
+				same, err := sameContent(fs, a, other, b)
+				if err != nil {
+					return nil, fmt.Errorf("erro ao comparar '%s': %w", path, err)
+				}
+				if !same {
+					details = append(details, "conteúdo")
+				}
+			}
+			if a.Protected != b.Protected {
+				details = append(details, fmt.Sprintf("proteção %v -> %v", a.Protected, b.Protected))
+			}
+			if len(details) > 0 {
+				changes = append(changes, imageChange{Kind: "modified", Path: path, Detail: strings.Join(details, ", ")})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// entriesByPath indexa as entradas em uso pelo caminho completo, sem o diretório de sistema.
+func (fs *FURGFileSystem) entriesByPath() map[string]*FileEntry {
+	entries := make(map[string]*FileEntry)
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 {
+			continue
+		}
+		path := entry.FullPath()
+		if path == systemDir || strings.HasPrefix(path, systemDir+"/") {
+			continue
+		}
+		entries[path] = entry
+	}
+	return entries
+}
+
+func entryKind(entry *FileEntry) string {
+	if entry.IsDirectory {
+		return "diretório"
+	}
+	return "arquivo"
+}
+
+// sameContent compara o conteúdo de dois arquivos, possivelmente em imagens diferentes, bloco a bloco.
+func sameContent(fsA *FURGFileSystem, a *FileEntry, fsB *FURGFileSystem, b *FileEntry) (bool, error) {
+	readerA, err := fsA.newFileReader(a)
+	if err != nil {
+		return false, err
+	}
+	readerB, err := fsB.newFileReader(b)
+	if err != nil {
+		return false, err
+	}
+	bufA := make([]byte, fsA.Header.BlockSize)
+	bufB := make([]byte, fsA.Header.BlockSize)
+	for {
+		n, errA := io.ReadFull(readerA, bufA)
+		m, errB := io.ReadFull(readerB, bufB)
+		if n != m || !bytes.Equal(bufA[:n], bufB[:m]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil && errB != io.EOF && errB != io.ErrUnexpectedEOF {
+			return false, errB
+		}
+	}
+}
+
+// imageArgument retorna a imagem aberta com o nome arg ou, se não houver, carrega a imagem do caminho arg
+// (ou do volume arg da configuração). O booleano indica se a imagem foi carregada e deve ser fechada.
+func imageArgument(arg string) (*FURGFileSystem, bool, error) {
+	if image, err := openImages.get(arg); err == nil {
+		return image, false, nil
+	}
+	path, err := resolveImage(arg)
+	if err != nil {
+		return nil, false, err
+	}
+	image, err := loadFileSystem(path)
+	if err != nil {
+		return nil, false, err
+	}
+	return image, true, nil
+}
+
+// cliImageDiff implementa "imagediff [--json] [imagem] <outra imagem>": sem a primeira imagem, compara a
+// imagem atual com a outra. As imagens podem ser nomes de imagens abertas, caminhos ou volumes da configuração.
+func cliImageDiff(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("imagediff", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "saída em JSON")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 1 && flags.NArg() != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs imagediff [--json] [imagem] <outra imagem>")
+	}
+
+	images := []*FURGFileSystem{fs}
+	if flags.NArg() == 2 {
+		images = nil
+	}
+	for _, arg := range flags.Args() {
+		image, loaded, err := imageArgument(arg)
+		if err != nil {
+			return err
+		}
+		if loaded {
+			defer image.FilePointer.Close()
+		}
+		images = append(images, image)
+	}
+
+	changes, err := images[0].DiffImages(images[1])
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if changes == nil {
+			changes = []imageChange{}
+		}
+		return printJSON(jsonImageDiff{Schema: jsonSchemaVersion, Identical: len(changes) == 0, Changes: changes})
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("As imagens são idênticas.")
+		return nil
+	}
+	symbols := map[string]string{"added": "+", "removed": "-", "modified": "M", "metadata": "*"}
+	for _, change := range changes {
+		if change.Detail != "" {
+			fmt.Printf("%s %s (%s)\n", symbols[change.Kind], change.Path, change.Detail)
+		} else {
+			fmt.Printf("%s %s\n", symbols[change.Kind], change.Path)
+		}
+	}
+	fmt.Printf("%d diferença(s).\n", len(changes))
+	return nil
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// O diário de alterações registra, a cada estado salvo, as entradas criadas, alteradas e apagadas desde o
+// estado anterior, cada uma com um número de sequência crescente. Ferramentas de sincronização e de backup
+// guardam o último número que viram e perguntam o que mudou desde ele, sem percorrer o volume. Os registros
+// ficam em segmentos de até journalSegmentSize bytes em /.furgfs/journal-<primeira sequência>.jsonl; os
+// mais antigos são apagados quando há mais de journalMaxSegments. /.furgfs/journal.json guarda o
+// identificador do diário, trocado sempre que ele é recriado.
+const (
+	journalInfoName    = "journal.json"
+	journalPrefix      = "journal-"
+	journalSuffix      = ".jsonl"
+	journalSegmentSize = 64 << 10
+	journalMaxSegments = 16
+)
+
+// journalConfig é o conteúdo de /.furgfs/journal.json.
+type journalConfig struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+}
+
+// journalRecord é uma alteração no diário. Um arquivo movido aparece como delete do caminho antigo e
+// create do novo.
+type journalRecord struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	Op   string    `json:"op"` // create, modify ou delete
+	Path string    `json:"path"`
+	Type string    `json:"type"` // "file" ou "directory"
+	Size uint32    `json:"size,omitempty"`
+}
+
+// journalEntry é o que o diário compara de uma entrada entre dois estados. Como os blocos de dados nunca
+// são reescritos no lugar, um conteúdo novo sempre começa em outro bloco.
+type journalEntry struct {
+	dir       bool
+	protected bool
+	size      uint32
+	first     uint32
+}
+
+// journalState é o diário de uma imagem aberta.
+type journalState struct {
+	journalConfig
+	base map[string]journalEntry // entradas no último estado registrado
+	next uint64                  // próximo número de sequência
+}
+
+// journalSnapshot retorna as entradas do volume, fora do diretório de sistema, pelo caminho.
+func (fs *FURGFileSystem) journalSnapshot() map[string]journalEntry {
+	entries := make(map[string]journalEntry)
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 {
+			continue
+		}
+		p := entry.FullPath()
+		if p == systemDir || strings.HasPrefix(p, systemDir+"/") {
+			continue
+		}
+		entries[p] = journalEntry{entry.IsDirectory, entry.Protected, entry.Size, entry.FirstBlockID}
+	}
+	return entries
+}
+
+// startJournal passa a registrar as alterações se a imagem tiver um diário.
+func (fs *FURGFileSystem) startJournal() error {
+	data, err := fs.readSystemFile(journalInfoName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	state := &journalState{base: fs.journalSnapshot(), next: 1}
+	if err := json.Unmarshal(data, &state.journalConfig); err != nil {
+		return classErrorf(ErrCorrupted, "erro ao ler %s: %v", joinPath(systemDir, journalInfoName), err)
+	}
+	if segments := fs.journalSegments(); len(segments) > 0 {
+		records, err := fs.readJournalSegment(segments[len(segments)-1])
+		if err != nil {
+			return err
+		}
+		state.next = segments[len(segments)-1].first
+		if len(records) > 0 {
+			state.next = records[len(records)-1].Seq + 1
+		}
+	}
+	fs.journal = state
+	return nil
+}
+
+// rebaseJournal aceita o estado atual das entradas sem registrar alterações; é usada por quem só muda os
+// blocos de lugar, como a compactação. O índice de metadados mantém os hashes do conteúdo.
+func (fs *FURGFileSystem) rebaseJournal() {
+	if fs.journal != nil {
+		fs.journal.base = fs.journalSnapshot()
+	}
+	if fs.index != nil {
+		fs.index.relocated = true
+	}
+}
+
+// journalChanges compara as entradas com o último estado registrado: primeiro as remoções, dos filhos para
+// os pais, depois as criações e alterações, dos pais para os filhos.
+func (fs *FURGFileSystem) journalChanges(current map[string]journalEntry) []journalRecord {
+	var deleted, changed []journalRecord
+	for p, old := range fs.journal.base {
+		if _, ok := current[p]; !ok {
+			deleted = append(deleted, journalRecord{Op: "delete", Path: p, Type: journalType(old)})
+		}
+	}
+	for p, entry := range current {
+		old, ok := fs.journal.base[p]
+		switch {
+		case !ok:
+			changed = append(changed, journalRecord{Op: "create", Path: p, Type: journalType(entry), Size: entry.size})
+		case old.dir != entry.dir:
+			// um arquivo trocado por um diretório de mesmo nome, ou o contrário
+			deleted = append(deleted, journalRecord{Op: "delete", Path: p, Type: journalType(old)})
+			changed = append(changed, journalRecord{Op: "create", Path: p, Type: journalType(entry), Size: entry.size})
+		case old != entry:
+			changed = append(changed, journalRecord{Op: "modify", Path: p, Type: journalType(entry), Size: entry.size})
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i].Path > deleted[j].Path })
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Path < changed[j].Path })
+	return append(deleted, changed...)
+}
+
+func journalType(entry journalEntry) string {
+	if entry.dir {
+		return "directory"
+	}
+	return "file"
+}
+
+// writeJournal registra as alterações desde o último estado registrado. É chamada por
+// saveFileSystemState antes de gravar os metadados, que passam a incluir os registros.
+func (fs *FURGFileSystem) writeJournal() error {
+	if fs.journal == nil {
+		return nil
+	}
+	current := fs.journalSnapshot()
+	records := fs.journalChanges(current)
+	if len(records) == 0 {
+		return nil
+	}
+	now := time.Now()
+	var lines []byte
+	next := fs.journal.next
+	for i := range records {
+		records[i].Seq, records[i].Time = next, now
+		next++
+		line, err := json.Marshal(records[i])
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+
+	segments := fs.journalSegments()
+	name := journalSegmentName(fs.journal.next)
+	var data []byte
+	if len(segments) > 0 {
+		last := segments[len(segments)-1]
+		content, err := fs.readSystemFile(last.name)
+		if err != nil {
+			return err
+		}
+		if len(content)+len(lines) <= journalSegmentSize {
+			name, data = last.name, content
+		} else {
+			segments = append(segments, journalSegment{name: name, first: fs.journal.next})
+		}
+	} else {
+		segments = append(segments, journalSegment{name: name, first: fs.journal.next})
+	}
+	if err := fs.writeSystemFile(name, append(data, lines...)); err != nil {
+		return fmt.Errorf("erro ao gravar o diário de alterações: %w", err)
+	}
+	for len(segments) > journalMaxSegments {
+		fs.discardEntries([]int{fs.lookupEntry(segments[0].name, systemDir)})
+		segments = segments[1:]
+	}
+	fs.journal.base, fs.journal.next = current, next
+	return nil
+}
+
+// journalSegment é um segmento do diário e o número de sequência do seu primeiro registro.
+type journalSegment struct {
+	name  string
+	first uint64
+}
+
+func journalSegmentName(first uint64) string {
+	return fmt.Sprintf("%s%012d%s", journalPrefix, first, journalSuffix)
+}
+
+// journalSegments retorna os segmentos do diário, do mais antigo ao mais novo.
+func (fs *FURGFileSystem) journalSegments() []journalSegment {
+	var segments []journalSegment
+	for _, i := range fs.entriesInDirectory(systemDir, false) {
+		name := fs.RootDir[i].NameString()
+		number, ok := strings.CutPrefix(name, journalPrefix)
+		number, found := strings.CutSuffix(number, journalSuffix)
+		first, err := strconv.ParseUint(number, 10, 64)
+		if ok && found && err == nil && !fs.RootDir[i].IsDirectory {
+			segments = append(segments, journalSegment{name, first})
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].first < segments[j].first })
+	return segments
+}
+
+func (fs *FURGFileSystem) readJournalSegment(segment journalSegment) ([]journalRecord, error) {
+	data, err := fs.readSystemFile(segment.name)
+	if err != nil {
+		return nil, err
+	}
+	var records []journalRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		var rec journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, classErrorf(ErrCorrupted, "erro: registro inválido em %s, linha %d: %v", joinPath(systemDir, segment.name), line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// JournalSince retorna as alterações com número de sequência maior que seq. Se o diário já não tiver
+// todas elas, porque os segmentos antigos foram apagados, o erro pede uma sincronização completa.
+func (fs *FURGFileSystem) JournalSince(seq uint64) ([]journalRecord, error) {
+	segments := fs.journalSegments()
+	if len(segments) > 0 && seq+1 < segments[0].first {
+		return nil, classErrorf(ErrNotFound, "erro: o diário não tem mais as alterações desde %d (a mais antiga é %d); faça uma sincronização completa", seq, segments[0].first)
+	}
+	var records []journalRecord
+	for i, segment := range segments {
+		// os segmentos que terminam antes de seq nem são lidos
+		if i+1 < len(segments) && segments[i+1].first <= seq+1 {
+			continue
+		}
+		part, err := fs.readJournalSegment(segment)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range part {
+			if rec.Seq > seq {
+				records = append(records, rec)
+			}
+		}
+	}
+	return records, nil
+}
+
+// removeJournal apaga o diário e todos os seus segmentos.
+func (fs *FURGFileSystem) removeJournal() {
+	indexes := []int{fs.lookupEntry(journalInfoName, systemDir)}
+	for _, segment := range fs.journalSegments() {
+		indexes = append(indexes, fs.lookupEntry(segment.name, systemDir))
+	}
+	fs.discardEntries(indexes)
+	fs.journal = nil
+}
+
+// cliJournal implementa "journal enable", "journal disable", "journal status" e "journal since".
+func cliJournal(fs *FURGFileSystem, args []string) error {
+	usage := classErrorf(ErrUsage, "uso: furgfs journal enable | journal disable | journal status [--json] | journal since [--json] <sequência>")
+	if len(args) == 0 {
+		return usage
+	}
+	flags := flag.NewFlagSet("journal "+args[0], flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "saída em JSON")
+	if err := flags.Parse(args[1:]); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+
+	switch args[0] {
+	case "enable":
+		if flags.NArg() != 0 || *asJSON {
+			return usage
+		}
+		if fs.journal != nil {
+			fmt.Printf(tr("O diário '%s' já está ativo.\n"), fs.journal.ID)
+			return nil
+		}
+		id, err := newUUID()
+		if err != nil {
+			return err
+		}
+		config := journalConfig{ID: id, Created: time.Now()}
+		data, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := fs.writeSystemFile(journalInfoName, data); err != nil {
+			return err
+		}
+		// O diário começa do estado atual: as entradas existentes não são registradas como criadas
+		fs.journal = &journalState{journalConfig: config, base: fs.journalSnapshot(), next: 1}
+		if err := fs.saveFileSystemState(); err != nil {
+			return err
+		}
+		fmt.Printf(tr("Diário de alterações '%s' ativo.\n"), id)
+		return nil
+	case "disable":
+		if flags.NArg() != 0 || *asJSON {
+			return usage
+		}
+		if fs.journal == nil {
+			fmt.Println(tr("O volume não tem diário de alterações."))
+			return nil
+		}
+		if err := fs.confirm("O diário de alterações será apagado."); err != nil {
+			return err
+		}
+		fs.removeJournal()
+		if err := fs.saveFileSystemState(); err != nil {
+			return err
+		}
+		fmt.Println(tr("Diário de alterações apagado."))
+		return nil
+	case "status":
+		if flags.NArg() != 0 {
+			return usage
+		}
+		status := struct {
+			Schema   int       `json:"schema"`
+			Enabled  bool      `json:"enabled"`
+			ID       string    `json:"id,omitempty"`
+			Created  time.Time `json:"created,omitzero"`
+			FirstSeq uint64    `json:"first_seq,omitempty"`
+			LastSeq  uint64    `json:"last_seq"`
+			Segments int       `json:"segments"`
+		}{Schema: jsonSchemaVersion, Enabled: fs.journal != nil}
+		if fs.journal != nil {
+			segments := fs.journalSegments()
+			status.ID, status.Created, status.LastSeq, status.Segments = fs.journal.ID, fs.journal.Created, fs.journal.next-1, len(segments)
+			if len(segments) > 0 {
+				status.FirstSeq = segments[0].first
+			}
+		}
+		if *asJSON {
+			return printJSON(status)
+		}
+		if !status.Enabled {
+			fmt.Println(tr("O volume não tem diário de alterações."))
+			return nil
+		}
+		fmt.Printf(tr("Diário:           %s (criado em %s)\n"), status.ID, status.Created.Local().Format("2006-01-02 15:04:05"))
+		fmt.Printf(tr("Sequências:       %d a %d\n"), status.FirstSeq, status.LastSeq)
+		fmt.Printf(tr("Segmentos:        %d de até %d\n"), status.Segments, journalMaxSegments)
+		return nil
+	case "since":
+		if flags.NArg() != 1 {
+			return usage
+		}
+		seq, err := strconv.ParseUint(flags.Arg(0), 10, 64)
+		if err != nil {
+			return classErrorf(ErrUsage, "erro: sequência '%s' inválida", flags.Arg(0))
+		}
+		if fs.journal == nil {
+			return classErrorf(ErrNotFound, "erro: o volume não tem diário de alterações; use furgfs journal enable")
+		}
+		records, err := fs.JournalSince(seq)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			// last_seq é o número a usar na próxima consulta, mesmo sem alterações
+			return printJSON(struct {
+				Schema  int             `json:"schema"`
+				ID      string          `json:"id"`
+				LastSeq uint64          `json:"last_seq"`
+				Records []journalRecord `json:"records"`
+			}{jsonSchemaVersion, fs.journal.ID, fs.journal.next - 1, append([]journalRecord{}, records...)})
+		}
+		for _, rec := range records {
+			fmt.Printf("%-8d %-6s %s\n", rec.Seq, rec.Op, rec.Path)
+		}
+		return nil
+	default:
+		return usage
+	}
+}
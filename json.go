@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// jsonSchemaVersion identifica o formato da saída --json. Campos novos podem ser acrescentados sem mudar a
+// versão; renomear ou remover campos exige incrementá-la.
+const jsonSchemaVersion = 1
+
+// jsonEntry descreve um arquivo ou diretório na saída --json de ls, tree e stat.
+type jsonEntry struct {
+	Path      string      `json:"path"`
+	Name      string      `json:"name"`
+	Type      string      `json:"type"` // "file" ou "directory"
+	Size      uint32      `json:"size"`
+	Protected bool        `json:"protected"`
+	Children  []jsonEntry `json:"children,omitempty"` // apenas em tree, para diretórios não vazios
+}
+
+// jsonListing é a saída de "ls --json".
+type jsonListing struct {
+	Schema    int         `json:"schema"`
+	Directory string      `json:"directory"`
+	Entries   []jsonEntry `json:"entries"`
+}
+
+// jsonTree é a saída de "tree --json".
+type jsonTree struct {
+	Schema int       `json:"schema"`
+	Root   jsonEntry `json:"root"`
+}
+
+// jsonStat é a saída de "stat --json".
+type jsonStat struct {
+	Schema int `json:"schema"`
+	jsonEntry
+	Index      int         `json:"index"`
+	Blocks     []uint32    `json:"blocks"`
+	Fragments  int         `json:"fragments"`
+	FirstBlock *uint32     `json:"first_block"`      // null para diretórios e arquivos vazios
+	Volume     *VolumeInfo `json:"volume,omitempty"` // apenas para a raiz
+}
+
+// jsonFreeSpace é a saída de "df --json". Os tamanhos são em bytes e se referem à área de dados.
+type jsonFreeSpace struct {
+	Schema       int    `json:"schema"`
+	ImageSize    uint32 `json:"image_size"`
+	BlockSize    uint32 `json:"block_size"`
+	TotalBlocks  int    `json:"total_blocks"`
+	FreeBlocks   int    `json:"free_blocks"`
+	TotalBytes   uint64 `json:"total_bytes"`
+	UsedBytes    uint64 `json:"used_bytes"`
+	FreeBytes    uint64 `json:"free_bytes"`
+	TotalEntries int    `json:"total_entries"`
+	UsedEntries  int    `json:"used_entries"`
+}
+
+func newJSONEntry(entry *FileEntry) jsonEntry {
+	kind := "file"
+	if entry.IsDirectory {
+		kind = "directory"
+	}
+	return jsonEntry{
+		Path:      entry.FullPath(),
+		Name:      entry.NameString(),
+		Type:      kind,
+		Size:      entry.Size,
+		Protected: entry.Protected,
+	}
+}
+
+// jsonTreeOf monta a árvore do diretório dir, com as entradas ordenadas por nome.
+func (fs *FURGFileSystem) jsonTreeOf(dir string) []jsonEntry {
+	children := []jsonEntry{}
+	for _, i := range fs.sortedEntries(dir) {
+		entry := newJSONEntry(&fs.RootDir[i])
+		if fs.RootDir[i].IsDirectory {
+			entry.Children = fs.jsonTreeOf(entry.Path)
+		}
+		children = append(children, entry)
+	}
+	return children
+}
+
+// freeSpaceInfo calcula o uso do espaço a partir da FAT e da tabela do diretório.
+func (fs *FURGFileSystem) freeSpaceInfo() jsonFreeSpace {
+	info := jsonFreeSpace{
+		Schema:       jsonSchemaVersion,
+		ImageSize:    fs.Header.TotalSize,
+		BlockSize:    fs.Header.BlockSize,
+		TotalBlocks:  len(fs.FAT),
+		TotalEntries: len(fs.RootDir),
+	}
+	for _, fat := range fs.fatEntries() {
+		if !fat.Used {
+			info.FreeBlocks++
+		}
+	}
+	for i := range fs.RootDir {
+		if fs.RootDir[i].Name[0] != 0 {
+			info.UsedEntries++
+		}
+	}
+	info.TotalBytes = uint64(info.TotalBlocks) * uint64(info.BlockSize)
+	info.FreeBytes = uint64(info.FreeBlocks) * uint64(info.BlockSize)
+	info.UsedBytes = info.TotalBytes - info.FreeBytes
+	return info
+}
+
+// printJSON escreve v na saída padrão como JSON indentado.
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// blockRun é uma sequência de blocos fisicamente contíguos dentro da cadeia de um arquivo.
+type blockRun struct {
+	Start  uint32
+	Length uint32
+}
+
+// blockRuns agrupa a cadeia de blocos de um arquivo em sequências contíguas.
+func blockRuns(blocks []uint32) []blockRun {
+	var runs []blockRun
+	for _, blockID := range blocks {
+		if n := len(runs); n > 0 && runs[n-1].Start+runs[n-1].Length == blockID {
+			runs[n-1].Length++
+			continue
+		}
+		runs = append(runs, blockRun{Start: blockID, Length: 1})
+	}
+	return runs
+}
+
+// Largura, em caracteres, de cada linha do mapa de blocos do relatório de fragmentação.
+const blockMapWidth = 64
+
+// FragmentationReport exibe, para cada arquivo, em quantos fragmentos (sequências contíguas) ele está dividido,
+// a contiguidade média das cadeias e um mapa da região de dados, para indicar quando desfragmentar vale a pena.
+func (fs *FURGFileSystem) FragmentationReport() error {
+	type fileLayout struct {
+		path   string
+		blocks int
+		runs   int
+	}
+
+	owners := make([]int, len(fs.FAT)) // 0 livre, 1 arquivo contíguo, 2 arquivo fragmentado
+	var layouts []fileLayout
+	var links, contiguousLinks int
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 || entry.IsDirectory {
+			continue
+		}
+		blocks, err := fs.fileBlocks(entry)
+		if err != nil {
+			return err
+		}
+		runs := blockRuns(blocks)
+		if len(blocks) > 1 {
+			links += len(blocks) - 1
+			contiguousLinks += len(blocks) - len(runs)
+		}
+		for _, blockID := range blocks {
+			owners[blockID] = 1
+			if len(runs) > 1 {
+				owners[blockID] = 2
+			}
+		}
+		layouts = append(layouts, fileLayout{path: entry.FullPath(), blocks: len(blocks), runs: len(runs)})
+	}
+
+	sort.Slice(layouts, func(a, b int) bool {
+		if layouts[a].runs != layouts[b].runs {
+			return layouts[a].runs > layouts[b].runs
+		}
+		return layouts[a].path < layouts[b].path
+	})
+
+	fragmented := 0
+	fmt.Printf("%-10s %-10s %s\n", "Blocos", "Fragmentos", "Arquivo")
+	for _, layout := range layouts {
+		if layout.runs > 1 {
+			fragmented++
+		}
+		fmt.Printf("%-10d %-10d %s\n", layout.blocks, layout.runs, layout.path)
+	}
+
+	contiguity := 1.0
+	if links > 0 {
+		contiguity = float64(contiguousLinks) / float64(links)
+	}
+	fmt.Printf("\nArquivos: %d, fragmentados: %d\n", len(layouts), fragmented)
+	fmt.Printf("Contiguidade média das cadeias: %.2f%%\n", contiguity*100)
+
+	// Cada caractere do mapa representa um grupo de blocos; o pior estado do grupo é exibido
+	perChar := max(1, (len(fs.FAT)+blockMapWidth*32-1)/(blockMapWidth*32))
+	symbols := []byte{'.', '#', 'X', '?'}
+	orphans := 0
+	fmt.Printf("\nMapa de blocos (cada caractere = %d bloco(s); '.' livre, '#' contíguo, 'X' fragmentado, '?' sem dono):\n", perChar)
+	var line strings.Builder
+	for start := 0; start < len(fs.FAT); start += perChar {
+		state := 0
+		for blockID := start; blockID < min(start+perChar, len(fs.FAT)); blockID++ {
+			if fs.fatEntry(uint32(blockID)).Used && owners[blockID] == 0 {
+				state = 3
+				orphans++
+			}
+			state = max(state, owners[blockID])
+		}
+		symbol := symbols[state]
+		line.WriteByte(symbol)
+		if line.Len() == blockMapWidth {
+			fmt.Println(line.String())
+			line.Reset()
+		}
+	}
+	if line.Len() > 0 {
+		fmt.Println(line.String())
+	}
+
+	if orphans > 0 {
+		fmt.Printf("\n%d bloco(s) marcados como usados não pertencem a nenhum arquivo.\n", orphans)
+	}
+	if fragmented > 0 && contiguity < 0.9 {
+		fmt.Println("\nA fragmentação está alta: copiar os arquivos para uma nova imagem (clone) reorganiza os blocos de forma contígua.")
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxHistoryLines limita quantas linhas o histórico do editor guarda.
+const maxHistoryLines = 500
+
+// lineEditor lê as respostas do menu interativo. Quando a entrada padrão é um terminal, oferece edição da
+// linha (setas, Home/End, Ctrl+A/E/K/U) e histórico navegável com as setas para cima e para baixo; caso
+// contrário (entrada redirecionada), lê linha a linha normalmente.
+type lineEditor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	fd      int
+	prompt  string // último prompt exibido, reescrito ao listar opções de completamento
+	history []string
+
+	// Complete, se definido, é chamado com o texto antes do cursor quando Tab é pressionado e retorna
+	// as linhas completas candidatas.
+	Complete func(line string) []string
+}
+
+// console é o editor de linha usado pelo menu interativo.
+var console = newLineEditor(os.Stdin, os.Stdout)
+
+func newLineEditor(in *os.File, out io.Writer) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(in), out: out, fd: int(in.Fd())}
+}
+
+// Prompt exibe prompt, lê uma linha e a armazena em v. Para strings, a linha inteira (sem espaços nas pontas) é usada,
+// o que permite nomes e caminhos com espaços; para os demais tipos, a linha é interpretada como em fmt.Sscan.
+func (le *lineEditor) Prompt(prompt string, v any) error {
+	line, err := le.ReadLine(prompt)
+	if err != nil {
+		return err
+	}
+	if s, ok := v.(*string); ok {
+		*s = line
+		return nil
+	}
+	if line == "" {
+		return fmt.Errorf("entrada vazia")
+	}
+	_, err = fmt.Sscan(line, v)
+	return err
+}
+
+// ReadLine exibe prompt e lê uma linha da entrada, sem a quebra de linha final e sem espaços nas pontas.
+// Linhas não vazias são adicionadas ao histórico.
+func (le *lineEditor) ReadLine(prompt string) (string, error) {
+	io.WriteString(le.out, prompt)
+	le.prompt = prompt
+
+	var line string
+	restore, err := enableRawMode(le.fd)
+	if err != nil {
+		line, err = le.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+	} else {
+		line, err = le.edit()
+		restore()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	line = strings.TrimSpace(line)
+	le.addHistory(line)
+	return line, nil
+}
+
+func (le *lineEditor) addHistory(line string) {
+	if line == "" || (len(le.history) > 0 && le.history[len(le.history)-1] == line) {
+		return
+	}
+	le.history = append(le.history, line)
+	if len(le.history) > maxHistoryLines {
+		le.history = le.history[len(le.history)-maxHistoryLines:]
+	}
+}
+
+// edit lê teclas em modo raw até Enter, mantendo o buffer e a posição do cursor e redesenhando a linha
+// a cada alteração. O redesenho é feito relativo à posição do cursor.
+func (le *lineEditor) edit() (string, error) {
+	var buf []rune
+	pos := 0
+	shown, shownPos := 0, 0 // tamanho da linha e posição do cursor exibidos no terminal
+	historyIndex := len(le.history)
+	var pending []rune // linha em edição, guardada ao navegar no histórico
+	lastWasTab := false
+
+	redraw := func() {
+		var b strings.Builder
+		if shownPos > 0 {
+			fmt.Fprintf(&b, "\x1b[%dD", shownPos)
+		}
+		b.WriteString(string(buf))
+		if shown > len(buf) {
+			b.WriteString("\x1b[K")
+		}
+		if back := len(buf) - pos; back > 0 {
+			fmt.Fprintf(&b, "\x1b[%dD", back)
+		}
+		io.WriteString(le.out, b.String())
+		shown, shownPos = len(buf), pos
+	}
+	setLine := func(line []rune) {
+		buf = append([]rune(nil), line...)
+		pos = len(buf)
+		redraw()
+	}
+
+	for {
+		r, _, err := le.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		repeatedTab := lastWasTab && r == '\t'
+		lastWasTab = r == '\t'
+
+		switch r {
+		case '\t':
+			if le.Complete == nil {
+				continue
+			}
+			candidates := le.Complete(string(buf[:pos]))
+			if len(candidates) == 0 {
+				continue
+			}
+			// Completa até o prefixo comum; um segundo Tab lista as opções quando há mais de uma
+			completed := []rune(commonPrefix(candidates))
+			if len(completed) > pos {
+				buf = append(completed, buf[pos:]...)
+				pos = len(completed)
+			} else if repeatedTab && len(candidates) > 1 {
+				io.WriteString(le.out, "\n"+strings.Join(candidateNames(candidates), "  ")+"\n"+le.prompt+string(buf))
+				shown, shownPos = len(buf), len(buf)
+				if back := len(buf) - pos; back > 0 {
+					fmt.Fprintf(le.out, "\x1b[%dD", back)
+					shownPos = pos
+				}
+				continue
+			} else {
+				continue
+			}
+		case '\r', '\n':
+			io.WriteString(le.out, "\n")
+			return string(buf), nil
+		case 4: // Ctrl+D
+			if len(buf) == 0 {
+				io.WriteString(le.out, "\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 1: // Ctrl+A
+			pos = 0
+		case 5: // Ctrl+E
+			pos = len(buf)
+		case 2: // Ctrl+B
+			pos = max(0, pos-1)
+		case 6: // Ctrl+F
+			pos = min(len(buf), pos+1)
+		case 11: // Ctrl+K
+			buf = buf[:pos]
+		case 21: // Ctrl+U
+			buf = append([]rune(nil), buf[pos:]...)
+			pos = 0
+		case 127, 8: // Backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 27: // sequências de escape (setas, Home, End, Delete)
+			key := le.readEscape()
+			switch key {
+			case "A": // seta para cima
+				if historyIndex > 0 {
+					if historyIndex == len(le.history) {
+						pending = append([]rune(nil), buf...)
+					}
+					historyIndex--
+					setLine([]rune(le.history[historyIndex]))
+				}
+				continue
+			case "B": // seta para baixo
+				if historyIndex < len(le.history) {
+					historyIndex++
+					if historyIndex == len(le.history) {
+						setLine(pending)
+					} else {
+						setLine([]rune(le.history[historyIndex]))
+					}
+				}
+				continue
+			case "C":
+				pos = min(len(buf), pos+1)
+			case "D":
+				pos = max(0, pos-1)
+			case "H", "1~", "7~":
+				pos = 0
+			case "F", "4~", "8~":
+				pos = len(buf)
+			case "3~":
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			}
+		default:
+			if r < 32 {
+				continue
+			}
+			buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+			pos++
+		}
+		redraw()
+	}
+}
+
+// readEscape lê o restante de uma sequência de escape CSI ou SS3 (ex.: "\x1b[A", "\x1b[3~", "\x1bOH")
+// e retorna a parte após o introdutor.
+func (le *lineEditor) readEscape() string {
+	introducer, _, err := le.in.ReadRune()
+	if err != nil || (introducer != '[' && introducer != 'O') {
+		return ""
+	}
+	var seq strings.Builder
+	for {
+		r, _, err := le.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq.WriteRune(r)
+		if (r >= 'A' && r <= 'Z') || r == '~' {
+			return seq.String()
+		}
+	}
+}
+
+// candidateNames reduz as linhas candidatas ao último componente de cada uma, para listá-las de forma compacta.
+func candidateNames(candidates []string) []string {
+	names := make([]string, len(candidates))
+	for i, c := range candidates {
+		trimmed := strings.TrimSuffix(c, "/")
+		names[i] = c[strings.LastIndex(trimmed, "/")+1:]
+	}
+	return names
+}
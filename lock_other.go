@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package main
+
+import "os"
+
+// lockFile não é suportado fora do Linux e do macOS; a imagem fica sem trava.
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile trava f sem esperar: exclusive para o daemon, que passa a ser o único a abrir a imagem, e
+// compartilhada para os demais processos, que podem usar a imagem juntos como antes.
+func lockFile(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	return syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+}
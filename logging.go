@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logger registra os eventos internos (carga e gravação de imagens, alocação de blocos, comandos executados,
+// reparos do fsck). As mensagens para o usuário continuam sendo impressas normalmente; o log é para
+// diagnóstico e para os modos sem terminal, como scripts.
+var logger = slog.New(discardHandler{})
+
+// Níveis de log aceitos por --log-level e FURGFS_LOG_LEVEL.
+var logLevels = map[string]slog.Level{
+	"quiet":  slog.LevelError, // apenas erros
+	"normal": slog.LevelWarn,  // erros e avisos, como inconsistências encontradas (padrão)
+	"info":   slog.LevelInfo,  // também as operações que alteram a imagem
+	"debug":  slog.LevelDebug, // também blocos alocados, gravações e comandos de scripts
+}
+
+// setupLogging configura o logger com o nível level (vazio para FURGFS_LOG_LEVEL ou "normal"). Sem arquivo,
+// o log vai para a saída de erro padrão em texto; com file (ou FURGFS_LOG_FILE), é acrescentado ao arquivo
+// em JSON, uma linha por evento. A função retornada fecha o arquivo de log.
+func setupLogging(level, file string) (func(), error) {
+	if level == "" {
+		level = os.Getenv("FURGFS_LOG_LEVEL")
+	}
+	if level == "" {
+		level = "normal"
+	}
+	slogLevel, ok := logLevels[strings.ToLower(level)]
+	if !ok {
+		return nil, classErrorf(ErrUsage, "erro: nível de log '%s' inválido (use quiet, normal, info ou debug)", level)
+	}
+	if file == "" {
+		file = os.Getenv("FURGFS_LOG_FILE")
+	}
+
+	options := &slog.HandlerOptions{Level: slogLevel}
+	if file == "" {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, options))
+		return func() {}, nil
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir o arquivo de log: %w", err)
+	}
+	logger = slog.New(slog.NewJSONHandler(f, options))
+	return func() { f.Close() }, nil
+}
+
+// discardHandler descarta todos os eventos; é o logger usado antes de setupLogging.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// imageAttr identifica a imagem nos eventos de log.
+func (fs *FURGFileSystem) imageAttr() slog.Attr {
+	if fs.FilePointer == nil {
+		return slog.String("image", "")
+	}
+	return slog.String("image", fs.FilePointer.Name())
+}
@@ -15,15 +15,21 @@ package main
 // A estrutura FURGFileSystem representa o estado do sistema de arquivos e fornece métodos para operá-lo.
 
 import (
-	"bufio"
 	"bytes"
+	"cmp"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
+	pathpkg "path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"unsafe"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // main é a função principal que inicia a aplicação do sistema de arquivos FURGfs2.
@@ -31,25 +37,69 @@ import (
 // Em seguida, ele inicia a operação do sistema de arquivos, permitindo que o usuário interaja com ele.
 func main() {
 	// fmt.Printf("O tamanho de FATEntry e %d bytes \n", unsafe.Sizeof(FATEntry{})) -> 12 bytes, compilador adiciona 3 bytes apos o campo USED para alinha ao tamanho com os outros campos -> Facilita a busca e acesso em memoria
-	fileName := "furg.fs2"
-	if _, err := os.Stat(fileName); err == nil {
-		fmt.Println("Arquivo do sistema de arquivos encontrado. Carregando...")
+	options, args, err := extractGlobalFlags(os.Args[1:])
+	if err == nil {
+		err = resolveLanguage(options.Lang)
+	}
+	if err == nil {
+		err = setupColor(options.Color)
+	}
+	if err == nil {
+		err = setupSync(options.Sync)
+	}
+	if err == nil {
+		err = loadAliases()
+	}
+	// Sem --mmap, FURGFS_MMAP=1 liga o mapeamento das imagens
+	mapImages, _ = strconv.ParseBool(os.Getenv("FURGFS_MMAP"))
+	mapImages = mapImages || options.Mmap
+	closeLog, closeTracing := func() {}, func() {}
+	if err == nil {
+		closeLog, err = setupLogging(options.LogLevel, options.LogFile)
+	}
+	if err == nil {
+		closeTracing, err = setupTracing()
+	}
+	if err != nil {
+		printError(err)
+		os.Exit(exitCode(err))
+	}
+	if strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe") == "furgfsd" {
+		// O mesmo executável, com o nome furgfsd, é o daemon
+		args = append([]string{"daemon"}, args...)
+	}
+	fileName, err := resolveImage(options.Image)
+	if err != nil {
+		printError(err)
+		os.Exit(exitCode(err))
+	}
+	if len(args) > 0 {
+		code := runCLI(fileName, args)
+		closeTracing()
+		closeLog()
+		os.Exit(code)
+	}
+	defer closeLog()
+	defer closeTracing()
+	if _, err := os.Stat(fileName); err == nil || isRemoteImage(fileName) {
+		fmt.Println(tr("Arquivo do sistema de arquivos encontrado. Carregando..."))
 		fs, err := loadFileSystem(fileName)
 		if err != nil {
-			fmt.Println("Erro ao carregar o sistema de arquivos:", err)
+			fmt.Println(tr("Erro ao carregar o sistema de arquivos:"), err)
 			return
 		}
+		fmt.Println(tr("Sistema de arquivos carregado com sucesso."))
 		fs.operateFileSystem()
 	} else {
-		fmt.Println("Nenhum sistema de arquivos existente encontrado. Criando um novo...")
+		fmt.Println(tr("Nenhum sistema de arquivos existente encontrado. Criando um novo..."))
 		fsSize := getFileSystemSize()
 		if fsSize == 0 {
 			return
 		}
 		var blockSize uint32 = 4096
-		fs, err := createFileSystem(blockSize, fsSize)
+		fs, err := createFileSystem(fileName, blockSize, fsSize)
 		if err != nil {
-			fmt.Println("Erro ao criar o sistema de arquivos:", err)
+			fmt.Println(tr("Erro ao criar o sistema de arquivos:"), err)
 			return
 		}
 		fs.operateFileSystem()
@@ -58,86 +108,148 @@ func main() {
 
 // loadFileSystem carrega um sistema de arquivos existente de um arquivo binário e retorna uma instância de FURGFileSystem.
 // Ele lê o cabeçalho, a FAT e o diretório raiz do arquivo e os armazena na estrutura FURGFileSystem que foram serializados.
-// Se ocorrer um erro ao abrir ou ler o arquivo, ele retorna um erro.
-func loadFileSystem(fileName string) (*FURGFileSystem, error) {
-	f, err := os.OpenFile(fileName, os.O_RDWR, 0666)
+// Se ocorrer um erro ao abrir ou ler o arquivo, ele retorna um erro. Nomes http://, https:// e s3://
+// indicam imagens remotas (veja openImageStore).
+func loadFileSystem(fileName string) (_ *FURGFileSystem, err error) {
+	_, span := tracer.Start(traceContext, "furgfs.load", trace.WithAttributes(attribute.String("furgfs.image", fileName)))
+	defer func() { endSpan(span, err) }()
+	if err := lockImage(fileName); err != nil {
+		return nil, err
+	}
+	f, err := openImageStore(fileName)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao abrir o arquivo: %v", err)
+		return nil, fmt.Errorf("erro ao abrir o arquivo: %w", err)
+	}
+	fs, err := readFileSystem(f)
+	if err != nil {
+		return nil, err
+	}
+	fs.startReplica(fileName)
+	if err := fs.startJournal(); err != nil {
+		logger.Warn("diário de alterações inválido", fs.imageAttr(), "error", err)
 	}
+	if err := fs.startIndex(fileName); err != nil {
+		logger.Warn("índice de metadados indisponível", fs.imageAttr(), "error", err)
+	}
+	return fs, nil
+}
 
+// readFileSystem lê o cabeçalho, a FAT e o diretório raiz do arquivo f, já aberto, como loadFileSystem.
+// Abrir o arquivo apenas para leitura garante que a imagem não será alterada.
+func readFileSystem(f imageStore) (*FURGFileSystem, error) {
 	// Ler o cabeçalho
 	var header Header
-	err = binary.Read(f, binary.LittleEndian, &header)
+	err := binary.Read(io.NewSectionReader(f, 0, int64(unsafe.Sizeof(header))), binary.LittleEndian, &header)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao ler o cabeçalho: %v", err)
+		return nil, classErrorf(ErrCorrupted, "erro ao ler o cabeçalho: %v", err)
 	}
 
 	// Calcular tamanhos
 	fatEntrySize := uint32(unsafe.Sizeof(FATEntry{}))
 	fatSize := calculateFATSize(header.TotalSize-header.DataStart, header.BlockSize, fatEntrySize)
 	entriesNumber := (header.DataStart - header.RootDirStart) / uint32(unsafe.Sizeof(FileEntry{}))
-
-	// Ler a FAT
 	fat := make([]FATEntry, fatSize/fatEntrySize)
-	for i := range fat {
-		err = binary.Read(f, binary.LittleEndian, &fat[i])
-		if err != nil {
-			return nil, fmt.Errorf("erro ao ler a FAT: %v", err)
-		}
-	}
-
-	// Ler o diretório raiz
 	rootDir := make([]FileEntry, entriesNumber)
-	for i := range rootDir {
-		err = binary.Read(f, binary.LittleEndian, &rootDir[i])
-		if err != nil && err != io.EOF {
-			return nil, fmt.Errorf("erro ao ler o diretório raiz: %v", err)
-		}
-	}
 
+	// A FAT e o diretório raiz são lidos de uma vez e decodificados da memória. Uma FAT grande é lida por
+	// trechos, quando usada (veja fatpages.go)
+	headerSize, fatBytes := binary.Size(header), len(fat)*fatEntryBytes
+	raw := make([]byte, headerSize+fatBytes+len(rootDir)*fileEntryBytes)
+	lazy := fatBytes >= lazyFATBytes && imageHolds(f, len(raw))
 	fs := FURGFileSystem{
 		Header:      header,
 		FAT:         fat,
 		RootDir:     rootDir,
 		FilePointer: f,
+		Rules:       defaultValidationRules,
+		WorkingDir:  "/",
+	}
+	// O primeiro salvamento grava só as páginas alteradas (veja writeMetadata), a não ser que o diretório
+	// esteja incompleto na imagem
+	if !lazy {
+		n, err := readMetadata(f, raw, fat, rootDir)
+		if err != nil {
+			return nil, err
+		}
+		if n == len(raw) {
+			fs.dirtyPages = make([]bool, (n+metadataPage-1)/metadataPage)
+		}
+	} else {
+		fs.rawMeta = raw
+		fs.dirtyPages = make([]bool, (len(raw)+metadataPage-1)/metadataPage)
+		if err := fs.startLazyFAT(); err != nil {
+			return nil, err
+		}
 	}
 
-	fmt.Println("Sistema de arquivos carregado com sucesso.")
+	logger.Debug("imagem carregada", fs.imageAttr(), "block_size", header.BlockSize, "blocks", len(fat), "entries", len(rootDir), "lazy_fat", lazy)
+	if _, stored, err := readHeader(f); err == nil && stored != 0 && stored != headerChecksum(header) {
+		logger.Warn("checksum do cabeçalho não confere, execute o fsck", fs.imageAttr())
+	}
 	return &fs, nil
 }
 
-// saveFileSystemState salva o estado atual do sistema de arquivos no arquivo binário.
-// Ele escreve o cabeçalho, a FAT e o diretório raiz no arquivo, serializando-os.
-// Se ocorrer um erro ao reposicionar o ponteiro do arquivo ou escrever os dados, ele retorna um erro.
-func (fs *FURGFileSystem) saveFileSystemState() error {
-	// Resetar o arquivo para escrever do início
-	_, err := fs.FilePointer.Seek(0, io.SeekStart)
-	if err != nil {
-		return fmt.Errorf("erro ao reposicionar ponteiro no arquivo: %v", err)
+// readMetadata lê para raw o cabeçalho, a FAT e o diretório raiz e os decodifica em fat e rootDir. Retorna
+// quantos bytes foram lidos.
+func readMetadata(f imageStore, raw []byte, fat []FATEntry, rootDir []FileEntry) (int, error) {
+	headerSize, fatBytes := binary.Size(Header{}), len(fat)*fatEntryBytes
+	n, err := f.ReadAt(raw, 0)
+	if n < headerSize+fatBytes {
+		return 0, classErrorf(ErrCorrupted, "erro ao ler a FAT: %v", cmp.Or(err, io.ErrUnexpectedEOF))
 	}
-
-	// Salvar o cabeçalho
-	err = binary.Write(fs.FilePointer, binary.LittleEndian, fs.Header)
-	if err != nil {
-		return fmt.Errorf("erro ao salvar cabeçalho: %v", err)
+	// Um diretório raiz incompleto no fim da imagem é lido até onde houver entradas inteiras
+	if err != nil && err != io.EOF {
+		return 0, classErrorf(ErrCorrupted, "erro ao ler o diretório raiz: %v", err)
 	}
-
-	// Salvar a FAT
-	for _, entry := range fs.FAT {
-		err = binary.Write(fs.FilePointer, binary.LittleEndian, entry)
-		if err != nil {
-			return fmt.Errorf("erro ao salvar FAT: %v", err)
-		}
+	raw = raw[:n]
+	decodeFAT(fat, raw[headerSize:])
+	entries := (n - headerSize - fatBytes) / binary.Size(FileEntry{})
+	if err := binary.Read(bytes.NewReader(raw[headerSize+fatBytes:]), binary.LittleEndian, rootDir[:entries]); err != nil {
+		return 0, classErrorf(ErrCorrupted, "erro ao ler o diretório raiz: %v", err)
 	}
+	return n, nil
+}
 
-	// Salvar o diretório raiz
-	for _, entry := range fs.RootDir {
-		err = binary.Write(fs.FilePointer, binary.LittleEndian, entry)
-		if err != nil {
-			return fmt.Errorf("erro ao salvar diretório raiz: %v", err)
+// saveFileSystemState salva o estado atual do sistema de arquivos no arquivo binário.
+// Ele serializa o cabeçalho, a FAT e o diretório raiz e escreve no arquivo as partes alteradas.
+// Se ocorrer um erro ao escrever os dados, ele retorna um erro.
+func (fs *FURGFileSystem) saveFileSystemState() (err error) {
+	end := fs.startSpan("furgfs.save")
+	defer func() { end(err) }()
+	// As alterações desde o último estado vão para o diário antes, para que os metadados gravados o incluam
+	if err = fs.writeJournal(); err != nil {
+		return err
+	}
+	// Os blocos pendentes vão para a imagem antes dos metadados que apontam para eles e, com --sync=always,
+	// para o disco (veja durability.go)
+	if err = fs.flushBlocks(); err != nil {
+		return err
+	}
+	if imageSync == syncAlways {
+		if err = fs.syncImage(); err != nil {
+			return err
 		}
 	}
+	if fs.fatErr != nil {
+		return fs.fatErr
+	}
+	// Só as páginas alteradas dos metadados são gravadas (veja writeMetadata)
+	if err = fs.writeMetadata(); err != nil {
+		return err
+	}
 
+	logger.Debug("estado salvo", fs.imageAttr(), "free_space", fs.Header.FreeSpace)
+	if err := writeHeaderChecksum(fs.FilePointer, fs.Header); err != nil {
+		return err
+	}
+	fs.updateIndex()
+	if fs.replica != nil {
+		fs.replica.commit()
+	}
+	fs.saves++
+	if imageSync == syncAlways {
+		return fs.syncImage()
+	}
 	return nil
 }
 
@@ -146,18 +258,20 @@ func getFileSystemSize() uint32 {
 	var size uint32
 	running := true
 	for running {
-		fmt.Println("Escolha sua opção:")
+		fmt.Println(tr("Escolha sua opção:"))
 		fmt.Println("1. 10MB")
 		fmt.Println("2. 100MB")
 		fmt.Println("3. 800MB")
-		fmt.Println("4. Sair.")
-		consoleScanner := bufio.NewScanner(os.Stdin)
-		fmt.Printf("Resposta: ")
-		consoleScanner.Scan()
-		inputStr := consoleScanner.Text()
+		fmt.Println(tr("4. Sair."))
+		fmt.Println(tr("5. Outro tamanho (para escolher também o bloco e as entradas, use furgfs mkfs)"))
+		inputStr, err := console.ReadLine("Resposta: ")
+		if err != nil {
+			running = false
+			continue
+		}
 		option, e := strconv.Atoi(inputStr)
 		if e != nil {
-			fmt.Printf("Entrada inválida: '%s'. Por favor, insira um número entre 1 e 4.\n", inputStr)
+			fmt.Printf("Entrada inválida: '%s'. Por favor, insira um número entre 1 e 5.\n", inputStr)
 			continue
 		}
 		switch option {
@@ -170,8 +284,20 @@ func getFileSystemSize() uint32 {
 		case 4:
 			running = false
 			continue
+		case 5:
+			sizeStr, err := console.ReadLine("Tamanho total (ex.: 50M, 2G): ")
+			if err != nil {
+				running = false
+				continue
+			}
+			size, err = parseSize(sizeStr)
+			if err != nil {
+				printMenuError(err)
+				continue
+			}
 		default:
-			fmt.Println("Opção inválida. Escolha um número entre 1 e 4.")
+			fmt.Println("Opção inválida. Escolha um número entre 1 e 5.")
+			continue
 		}
 		return size
 	}
@@ -201,11 +327,52 @@ type FileEntry struct {
 	Protected    bool
 	IsDirectory  bool
 }
+
+// NameString retorna o nome da entrada sem os bytes nulos de preenchimento.
+func (e *FileEntry) NameString() string {
+	return string(bytes.Trim(e.Name[:], "\x00"))
+}
+
+// PathString retorna o caminho do diretório pai da entrada sem os bytes nulos de preenchimento.
+func (e *FileEntry) PathString() string {
+	return string(bytes.Trim(e.Path[:], "\x00"))
+}
+
+// FullPath retorna o caminho completo da entrada (diretório pai + nome).
+func (e *FileEntry) FullPath() string {
+	return joinPath(e.PathString(), e.NameString())
+}
+
 type FURGFileSystem struct {
 	Header      Header
 	FAT         []FATEntry
 	RootDir     []FileEntry
-	FilePointer *os.File
+	FilePointer imageStore
+	Rules       ValidationRules // regras para nomes e caminhos de novas entradas
+	WorkingDir  string          // diretório atual do menu interativo, base dos caminhos relativos
+	Policy      ConfirmPolicy   // confirmação de ações destrutivas; nil permite tudo, exceto alterar arquivos protegidos
+
+	pinnedBlocks map[uint32]bool // blocos retidos pelos snapshots (veja snapshotBlocks); nil até ser calculado
+	crypt        *volumeCrypt    // estado da cifra do conteúdo (veja cryptState); nil até ser lido
+	replica      *replicaStore   // envio das alterações ao espelho (veja startReplica); nil sem espelho
+	spanContext  context.Context // span atual das operações (veja startSpan); nil fora de um span
+	audit        *auditState     // configuração da auditoria (veja auditSettings); nil até ser lida
+	saves        uint64          // número de vezes que o estado foi salvo, para saber se um comando alterou a imagem
+	synced       uint64          // valor de saves no último fsync (veja syncPolicy)
+	journal      *journalState   // diário de alterações (veja startJournal); nil sem diário
+	cas          *casState       // modo de armazenamento (veja casSettings); nil até ser lido
+	index        *metaIndex      // índice de metadados ao lado da imagem (veja startIndex); nil sem índice
+	wcache       *writeCache     // blocos escritos ainda não gravados (veja writeCache); nil até a primeira escrita
+	dirtyPages   []bool          // páginas dos metadados a gravar no próximo salvamento (veja writeMetadata); nil grava tudo
+	rawMeta      []byte          // metadados lidos da imagem por trechos (veja fatpages.go); nil com a FAT lida inteira
+	nextFree     uint32          // onde allocateBlock começa a próxima busca; 0 antes da primeira reserva
+
+	dirIndex      map[dirKey]int // posição de cada entrada no diretório (veja directoryIndex); nil até a primeira busca
+	dirDuplicates bool           // a tabela tem entradas repetidas, e o índice guarda só a primeira
+
+	fatLoaded   []bool // trechos da FAT já lidos (veja fatEntry); nil com a FAT inteira na memória
+	fatUnloaded int    // trechos ainda não lidos
+	fatErr      error  // falha ao ler um trecho da FAT; impede reservas e o salvamento
 }
 
 func calculateFATSize(FileSystemSize uint32, BlockSize uint32, FATEntrySize uint32) uint32 {
@@ -224,249 +391,151 @@ func calculateHeaderSize() uint32 {
 	return HeaderSize
 }
 
-// createFileSystem cria um novo sistema de arquivos com o tamanho total especificado e o tamanho do bloco.
+// createFileSystem cria um novo sistema de arquivos em fileName com o tamanho total especificado e o tamanho do bloco.
 // Ele cria um arquivo binário para armazenar o sistema de arquivos e escreve o cabeçalho inicial no arquivo.
 // Em seguida, ele calcula o tamanho da FAT e do diretório raiz com base no tamanho total e no número de entradas.
-func createFileSystem(BlockSize uint32, TotalSize uint32) (*FURGFileSystem, error) {
-	f, err := os.OpenFile("furg.fs2", os.O_RDWR|os.O_CREATE, 0666)
+func createFileSystem(fileName string, BlockSize uint32, TotalSize uint32) (*FURGFileSystem, error) {
+	fs, err := createFileSystemImage(fileName, BlockSize, TotalSize, 100)
 	if err != nil {
-		fmt.Println("Erro ao abrir/criar o arquivo", err)
-		return nil, nil
-	} else {
-		fmt.Println("Arquivo do FileSystem criado com sucesso com permissao de escrita e leitura.")
+		return nil, err
 	}
+	err = fs.initVolume("")
+	if err != nil {
+		fs.FilePointer.Close()
+		os.Remove(fileName)
+		return nil, err
+	}
+	fmt.Println("Arquivo do FileSystem criado com sucesso com permissao de escrita e leitura.")
+	return fs, nil
+}
 
-	var entriesNumber uint32 = 100
+// createFileSystemImage cria o arquivo fileName com um sistema de arquivos vazio de geometria arbitrária:
+// tamanho total, tamanho do bloco e número de entradas do diretório. O arquivo não pode existir previamente.
+func createFileSystemImage(fileName string, BlockSize uint32, TotalSize uint32, entriesNumber uint32) (*FURGFileSystem, error) {
+	if BlockSize < 512 {
+		return nil, fmt.Errorf("erro: o tamanho do bloco deve ser de pelo menos 512 bytes")
+	}
+	if entriesNumber == 0 {
+		return nil, fmt.Errorf("erro: o diretório precisa de pelo menos uma entrada")
+	}
 
 	rootDirSize := calculateRootDirSize(entriesNumber)
 	headerSize := calculateHeaderSize()
 	fatEntrySize := uint32(unsafe.Sizeof(FATEntry{}))
+	if uint64(headerSize)+uint64(rootDirSize)+uint64(BlockSize) > uint64(TotalSize) {
+		return nil, fmt.Errorf("erro: o tamanho total é pequeno demais para a geometria escolhida")
+	}
 	FATSize := calculateFATSize(TotalSize-headerSize-rootDirSize, BlockSize, fatEntrySize)
+	dataStart := headerSize + FATSize + rootDirSize
+
+	// O número de blocos de dados é calculado da mesma forma que em loadFileSystem,
+	// para que a FAT gravada tenha o mesmo tamanho da FAT lida ao carregar o sistema.
+	blocksNumber := (TotalSize - dataStart) / BlockSize
+	if blocksNumber == 0 {
+		return nil, fmt.Errorf("erro: o tamanho total é pequeno demais para a geometria escolhida")
+	}
 
 	header := Header{
 		TotalSize:            TotalSize,
 		BlockSize:            BlockSize,
-		FreeSpace:            TotalSize - headerSize - FATSize - rootDirSize,
+		FreeSpace:            blocksNumber * BlockSize,
 		FATEntrypointAddress: headerSize,
 		RootDirStart:         headerSize + FATSize,
-		DataStart:            headerSize + FATSize + rootDirSize,
+		DataStart:            dataStart,
 	}
 
-	err = binary.Write(f, binary.LittleEndian, header)
+	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
 	if err != nil {
-		fmt.Println("Escrita do arquivo em binario falhou.", err)
+		return nil, fmt.Errorf("erro ao criar o arquivo: %v", err)
+	}
+
+	err = binary.Write(io.NewOffsetWriter(f, 0), binary.LittleEndian, header)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("escrita do arquivo em binario falhou: %v", err)
+	}
+	// O arquivo já tem o tamanho total, esparso até os blocos serem escritos (veja imagealloc.go)
+	if err := f.Truncate(int64(TotalSize)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("erro ao definir o tamanho da imagem: %v", err)
 	}
 	fileSystem := FURGFileSystem{
 		Header:      header,
-		FAT:         make([]FATEntry, FATSize/fatEntrySize),
+		FAT:         make([]FATEntry, blocksNumber),
 		RootDir:     make([]FileEntry, entriesNumber),
 		FilePointer: f,
+		Rules:       defaultValidationRules,
+		WorkingDir:  "/",
 	}
 
 	return &fileSystem, nil // Retornar pontiero pois ao inves de duplicar a memoria, apenas retorna o ponteiro de referencia a ele.
 }
 
 // operateFileSystem exibe um menu para o usuário escolher uma opção de operação do sistema de arquivos.
-// Através desse menu todas as funções do sistema de arquivos são acessadas.
+// O menu é montado a partir do registro de comandos (veja readMenuCommand), e cada escolha é executada
+// como uma linha de script, com as mesmas opções e mensagens da linha de comando.
 func (fs *FURGFileSystem) operateFileSystem() {
-	var option int
-	for {
-		fmt.Println("\n--- Menu do Sistema de Arquivos FURGfs2 ---")
-		fmt.Println("1. Copiar arquivo para o sistema de arquivos")
-		fmt.Println("2. Remover arquivo do sistema de arquivos")
-		fmt.Println("3. Renomear arquivo armazenado no FURGfs2")
-		fmt.Println("4. Listar todos os arquivos armazenados no FURGfs2")
-		fmt.Println("5. Listar o espaço livre em relação ao total do FURGfs2")
-		fmt.Println("6. Proteger/desproteger arquivo contra escrita/remoção")
-		fmt.Println("7. Copiar um arquivo do sistema ficticio para o real")
-		fmt.Println("8. Criar diretório")
-		fmt.Println("9. Listar diretórios")
-		fmt.Println("10. Remover diretório")
-		fmt.Println("0. Sair")
-		fmt.Print("Escolha uma opção: ")
-		fmt.Scanln(&option)
-
-		switch option {
-		case 1:
-			var externalPath string
-			var internalPath string
-			var protectionBit int
-
-			fmt.Println("Opção 1: Copiar arquivo para o sistema de arquivos.")
-
-			fmt.Print("Digite o caminho completo do arquivo para copiar: ")
-			fmt.Scanln(&externalPath)
-
-			fmt.Print("Digite o caminho completo no FurgFS2 onde o arquivo vai ficar: (digite / para raiz) ")
-			fmt.Scanln(&internalPath)
-
-			fmt.Print("Digite o bit de proteção (1 para protegido, 0 para não protegido): ")
-			fmt.Scanln(&protectionBit)
-
-			if protectionBit != 0 && protectionBit != 1 {
-				fmt.Println("Bit de proteção inválido! Deve ser 1 ou 0.")
-				continue
-			}
-			isProtected := protectionBit == 1
-
-			fs.CopyFileToFileSystem(externalPath, internalPath, isProtected)
-		case 2:
-			var fileName string
-			var path string
-
-			fmt.Println("Opção 2: Remover arquivo do sistema de arquivos.")
-
-			fmt.Print("Digite o nome completo do arquivo(com extensão) para remover: ")
-			fmt.Scanln(&fileName)
-
-			fmt.Print("Digite o caminho do arquivo: ")
-			fmt.Scanln(&path)
-
-			fmt.Printf("Arquivo '%s' será removido.\n", fileName)
-			err := fs.RemoveFileFromFileSystem(fileName, path)
-			if err != nil {
-				fmt.Println(err)
-			}
-		case 3:
-			var oldName string
-			var path string
-			var newName string
-
-			fmt.Println("Opção 3: Renomear arquivo armazenado no FURGfs2.")
-
-			fmt.Print("Digite o o nome completo do arquivo(com extensão) a ser renomeado: ")
-			fmt.Scanln(&oldName)
-
-			fmt.Print("Digite o caminho do arquivo: ")
-			fmt.Scanln(&path)
-
-			fmt.Print("Digite o novo nome do arquivo: ")
-			fmt.Scanln(&newName)
-
-			fmt.Printf("Arquivo '%s' será renomeado para '%s'.\n", oldName, newName)
-			err := fs.RenameFileFromFileSystem(oldName, path, newName)
-			if err != nil {
-				fmt.Println(err)
-			}
-		case 4:
-			fmt.Println("Opção 4: Listar todos os arquivos armazenados no FURGfs2.")
-			fmt.Println("Listagem de arquivos:")
-			fs.ShowAllFilesFromFileSystem()
-		case 5:
-			fmt.Println("Opção 5: Listar o espaço livre em relação ao total do FURGfs2.")
-			fmt.Println("Espaço livre e total:")
-			fs.ShowFreeSpaceFromFileSystem()
-		case 6:
-			var fileName string
-			var path string
-
-			fmt.Println("Opção 6: Proteger/desproteger arquivo contra escrita/remoção.")
-
-			fmt.Print("Digite o nome do arquivo a ser protegido/desprotegido: ")
-			fmt.Scanln(&fileName)
-
-			fmt.Print("Digite o caminho do arquivo: ")
-			fmt.Scanln(&path)
-
-			err := fs.ChangePermission(fileName, path)
-			if err != nil {
-				fmt.Println(err)
-			}
-		case 7:
-			var fileName string
-			var internalPath string
-			var externalPath string
-
-			fmt.Print("Digite o nome do arquivo que deseja copiar para o sistema real: ")
-			fmt.Scanln(&fileName)
-
-			if fileName == "" {
-				fmt.Println("Erro: Nome do arquivo não pode estar vazio.")
-				break
-			}
-
-			fmt.Print("Digite o caminho do arquivo no FURGfs2: ")
-			fmt.Scanln(&internalPath)
-			if internalPath == "" {
-				fmt.Println("Erro: Caminho do arquivo não pode estar vazio.")
-				break
-			}
-
-			fmt.Print("Digite o caminho completo onde deseja salvar o arquivo(lembrar de colocar a extensao caso queira abrir o arquivo): ")
-			fmt.Scanln(&externalPath)
-			if externalPath == "" {
-				fmt.Println("Erro: Caminho de destino não pode estar vazio.")
-				break
-			}
-
-			err := fs.CopyFileFromFileSystem(fileName, internalPath, externalPath)
-			if err != nil {
-				fmt.Printf("Erro ao copiar o arquivo: %v\n", err)
-			} else {
-				fmt.Printf("Arquivo '%s' copiado com sucesso para '%s'.\n", fileName, externalPath)
-			}
-		case 8:
-			fmt.Println("Opção 8: Criar diretório.")
-			fmt.Print("Digite o nome do diretório a ser criado(Não pode conter /): ")
-			var name string
-			fmt.Scanln(&name)
-			var path string
-			fmt.Print("Digite o caminho do diretório pai(Exemplo: /, ou /teste):")
-			fmt.Scanln(&path)
-			err := fs.CreateDirectory(name, path)
-
-			if err != nil {
-				fmt.Println(err)
-			} else {
-				fmt.Printf("Diretório '%s' criado com sucesso no caminho '%s'.\n", name, path)
-			}
-		case 9:
-			fmt.Println("Opção 9: Listar diretórios.")
-			fs.Tree()
-
-		case 10:
-			var name string
-			var path string
-
-			fmt.Println("Opção 10: Remover diretório.")
-
-			fmt.Print("Digite o nome do diretório a ser removido: ")
-			fmt.Scanln(&name)
-
-			fmt.Print("Digite o caminho do diretório pai: ")
-			fmt.Scanln(&path)
+	if openImages.currentImage() == nil {
+		openImages.add("", fs)
+	}
+	console.Complete = fs.completePath
+	fs.Policy = promptPolicy{}
 
-			err := fs.DeleteDirectory(name, path)
-			if err != nil {
-				fmt.Println(err)
-			} else {
-				fmt.Printf("Diretório '%s' removido com sucesso no caminho '%s'.\n", name, path)
-			}
-		case 0:
-			fmt.Println("Encerrando o sistema de arquivos...")
-			err := fs.saveFileSystemState()
-			if err != nil {
-				fmt.Println("Erro ao salvar o estado do sistema de arquivos:", err)
-			} else {
-				fmt.Println("Estado do sistema de arquivos salvo com sucesso.")
-			}
-			return
+	for {
+		fmt.Println(tr("\n--- Menu do Sistema de Arquivos FURGfs2 ---"))
+		line, ok := readMenuCommand(fmt.Sprintf(tr("Escolha uma opção [%s:%s]: "), openImages.current, fs.WorkingDir))
+		if !ok {
+			break
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
 
-		default:
-			fmt.Println("Opção inválida. Tente novamente.")
+		// As confirmações continuam sendo perguntadas, a menos que o comando traga --force
+		err := fs.runScript(line, promptPolicy{})
+		if err != nil {
+			printMenuError(err)
 		}
+		// "use" pode ter trocado a imagem atual
+		if current := openImages.currentImage(); current != nil {
+			fs = current
+		}
+		fs.Policy = promptPolicy{}
+		console.Complete = fs.completePath
+	}
+
+	fmt.Println(tr("Encerrando o sistema de arquivos..."))
+	err := openImages.closeAll(true)
+	if err != nil {
+		fmt.Println(tr("Erro ao salvar o estado do sistema de arquivos:"), err)
+	} else {
+		fmt.Println(tr("Estado do sistema de arquivos salvo com sucesso."))
 	}
 }
 
+// CheckFileEntryAlreadyExists retorna o índice da entrada com o nome e o caminho informados, ou -1. A busca
+// usa o índice do diretório (veja dirindex.go); só um nome vazio, que casa com as posições livres, varre a
+// tabela.
 func (fs *FURGFileSystem) CheckFileEntryAlreadyExists(name [32]byte, path [128]byte) int {
-	fileNameStr := string(name[:])
-	pathStr := string(path[:])
-
-	for i, v := range fs.RootDir {
-		existingFileName := string(v.Name[:])
-		existingPath := string(v.Path[:])
+	if name[0] != 0 {
+		i, found := fs.directoryIndex()[dirKey{name, path}]
+		if !found {
+			return -1
+		}
+		if fs.RootDir[i].Name == name && fs.RootDir[i].Path == path {
+			return i
+		}
+		logger.Debug("índice do diretório desatualizado; montando de novo", fs.imageAttr())
+		fs.dirIndex = nil
+		i, found = fs.directoryIndex()[dirKey{name, path}]
+		if !found {
+			return -1
+		}
+		return i
+	}
 
-		if existingFileName == fileNameStr && existingPath == pathStr {
+	for i := range fs.RootDir {
+		if entry := &fs.RootDir[i]; entry.Name == name && entry.Path == path {
 			return i
 		}
 	}
@@ -475,6 +544,19 @@ func (fs *FURGFileSystem) CheckFileEntryAlreadyExists(name [32]byte, path [128]b
 	return -1
 }
 
+// lookupEntry procura a entrada com o nome e o caminho do diretório pai informados e retorna seu índice, ou -1.
+func (fs *FURGFileSystem) lookupEntry(name, path string) int {
+	defer fs.startSpan("furgfs.lookup", attribute.String("furgfs.name", name), attribute.String("furgfs.dir", path))(nil)
+	path = fs.resolvePath(path)
+	var nameArray [32]byte
+	copy(nameArray[:], name)
+
+	var pathArray [128]byte
+	copy(pathArray[:], path)
+
+	return fs.CheckFileEntryAlreadyExists(nameArray, pathArray)
+}
+
 func (fs *FURGFileSystem) ProcessFileForFileSystem(path string) (*os.File, [32]byte, string, uint32, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -490,16 +572,16 @@ func (fs *FURGFileSystem) ProcessFileForFileSystem(path string) (*os.File, [32]b
 	fileSize := fileInfo.Size()
 	if fileSize > int64(fs.Header.FreeSpace) {
 		f.Close()
-		return nil, [32]byte{}, "", 0, fmt.Errorf("erro: o arquivo é muito grande para o espaço disponível")
+		return nil, [32]byte{}, "", 0, classErrorf(ErrNoSpace, "erro: o arquivo é muito grande para o espaço disponível")
 	}
 
 	var fileSizeUint32 uint32 = uint32(fileSize)
 
 	fileName := filepath.Base(path)
 
-	if len(fileName) > 32 {
+	if err := fs.Rules.ValidateName(fileName); err != nil {
 		f.Close()
-		return nil, [32]byte{}, "", 0, fmt.Errorf("erro: o nome do arquivo excede o limite de 32 bytes")
+		return nil, [32]byte{}, "", 0, err
 	}
 
 	var fileNameArray [32]byte
@@ -509,10 +591,21 @@ func (fs *FURGFileSystem) ProcessFileForFileSystem(path string) (*os.File, [32]b
 }
 
 func (fs *FURGFileSystem) CopyFileToFileSystem(externalPath string, internalPath string, protected bool) bool {
-	f, fileNameArray, fileName, fileSizeUint32, err := fs.ProcessFileForFileSystem(externalPath)
+	internalPath = fs.resolvePath(internalPath)
+	f, fileNameArray, fileName, _, err := fs.ProcessFileForFileSystem(externalPath)
 
 	if err != nil {
-		fmt.Println(err)
+		printMenuError(err)
+		return false
+	}
+	defer f.Close()
+
+	if err := fs.Rules.ValidatePath(internalPath); err != nil {
+		printMenuError(err)
+		return false
+	}
+	if fs.CheckDirectoryExists(internalPath) == -1 {
+		fmt.Printf("erro: O caminho '%s' não existe\n", internalPath)
 		return false
 	}
 
@@ -524,93 +617,68 @@ func (fs *FURGFileSystem) CopyFileToFileSystem(externalPath string, internalPath
 		return false
 	}
 
-	buf := make([]byte, fs.Header.BlockSize)
+	_, err = fs.storeQuotaFile(FileEntry{Name: fileNameArray, Path: pathArray, Protected: protected}, f)
+	if err != nil {
+		printMenuError(err)
+		return false
+	}
 
-	var firstBlock, previousBlock uint32
-	firstBlockSet := false
-	for {
-		bytesRead, err := f.Read(buf)
-		if err != nil && err != io.EOF {
-			fmt.Println("Erro ao ler o arquivo:", err)
-			return false
-		}
-		if bytesRead == 0 {
-			break
-		}
+	fmt.Printf("Arquivo '%s' copiado com sucesso para o sistema de arquivos.\n", fileName)
+	return true
+}
 
-		var currentBlockID uint32
-		found := false
-		for i, v := range fs.FAT {
-			if !v.Used {
-				currentBlockID = uint32(i)
-				tmp := FATEntry{
-					BlockID:     currentBlockID,
-					NextBlockID: 0,
-					Used:        true,
-				}
-				found = true
-				fs.FAT[i] = tmp
-				fs.Header.FreeSpace -= fs.Header.BlockSize
-				break
-			}
-		}
-		if !found {
-			fmt.Println("erro: espaço insuficiente na FAT.")
-			return false
-		}
+// createFile valida o nome e o diretório pai e grava o conteúdo de r como um novo arquivo name dentro de path.
+// Retorna o índice da nova entrada no diretório.
+func (fs *FURGFileSystem) createFile(path, name string, r io.Reader, protected bool) (int, error) {
+	path = fs.resolvePath(path)
+	if err := fs.Rules.ValidateEntry(path, name); err != nil {
+		return -1, err
+	}
+	if fs.CheckDirectoryExists(path) == -1 {
+		return -1, classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", path)
+	}
+	if fs.lookupEntry(name, path) != -1 {
+		return -1, fmt.Errorf("erro: arquivo com o mesmo nome já existe no diretório pai.")
+	}
 
-		if !firstBlockSet {
-			firstBlock = currentBlockID
-			firstBlockSet = true
-		} else {
-			fs.FAT[previousBlock].NextBlockID = currentBlockID
-		}
-		previousBlock = currentBlockID
+	var nameArray [32]byte
+	copy(nameArray[:], name)
 
-		_, err = fs.FilePointer.Seek(int64(fs.Header.DataStart+(currentBlockID*fs.Header.BlockSize)), 0)
-		if err != nil {
-			fmt.Println("Erro ao mover ponteiro do arquivo:", err)
-			return false
-		}
-		_, err = fs.FilePointer.Write(buf[:bytesRead])
+	var pathArray [128]byte
+	copy(pathArray[:], path)
+
+	return fs.storeQuotaFile(FileEntry{Name: nameArray, Path: pathArray, Protected: protected}, r)
+}
+
+// CreateDirectory cria o diretório name dentro do diretório pai path.
+// Se parents for verdadeiro, os componentes de path que ainda não existem são criados antes (como em "mkdir -p")
+// e um diretório já existente com o mesmo nome não é considerado erro.
+func (fs *FURGFileSystem) CreateDirectory(name string, path string, parents bool) error {
+	path = fs.resolvePath(path)
+	if parents {
+		err := fs.createParentDirectories(path)
 		if err != nil {
-			fmt.Println("Erro ao escrever dados no arquivo:", err)
-			return false
+			return err
 		}
-	}
-
-	for i, entry := range fs.RootDir {
-		if entry.Name[0] == 0 {
-			fs.RootDir[i] = FileEntry{
-				Name:         fileNameArray,
-				Path:         pathArray,
-				Size:         fileSizeUint32,
-				FirstBlockID: firstBlock,
-				Protected:    protected,
-			}
-			break
+		if fs.CheckDirectoryExists(joinPath(path, name)) != -1 {
+			return nil
 		}
 	}
-	fmt.Printf("Arquivo '%s' copiado com sucesso para o sistema de arquivos.\n", fileName)
-	defer f.Close()
-	return true
-}
 
-func (fs *FURGFileSystem) CreateDirectory(name string, path string) error {
 	var nameArray [32]byte
 	copy(nameArray[:], name)
 
-	if bytes.Contains(nameArray[:], []byte("/")) {
-		return fmt.Errorf("erro: O nome do diretório não pode conter '/'")
+	// O caminho completo do novo diretório será o Path de suas entradas, então também precisa ser válido
+	if err := fs.Rules.ValidateEntry(path, name); err != nil {
+		return err
 	}
-
-	if isAllNullBytes(name) {
-		return fmt.Errorf("erro: Não existem diretórios com nome vazio")
+	if err := fs.Rules.ValidatePath(joinPath(path, name)); err != nil {
+		return err
 	}
 
 	// verificar se o path existe
 	if i := fs.CheckDirectoryExists(path); i == -1 {
-		return fmt.Errorf("erro: O caminho '%s' não existe", path)
+		return classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", path)
 	}
 
 	// cria entry file
@@ -628,72 +696,96 @@ func (fs *FURGFileSystem) CreateDirectory(name string, path string) error {
 		IsDirectory: true,
 	}
 
-	err := fs.AddFileEntry(fileEntry)
+	err := fs.AddFileEntry(&fileEntry)
 	if err != nil {
 		return err
 	}
 
+	logger.Info("diretório criado", fs.imageAttr(), "path", joinPath(path, name))
+	return nil
+}
+
+// createParentDirectories percorre os componentes de path a partir da raiz e cria os diretórios que faltam.
+func (fs *FURGFileSystem) createParentDirectories(path string) error {
+	path = fs.resolvePath(path)
+	current := "/"
+	for _, component := range strings.Split(path, "/") {
+		if component == "" {
+			continue
+		}
+		next := joinPath(current, component)
+		if fs.CheckDirectoryExists(next) == -1 {
+			err := fs.CreateDirectory(component, current, false)
+			if err != nil {
+				return err
+			}
+		}
+		current = next
+	}
 	return nil
 }
 
 func (fs *FURGFileSystem) DeleteDirectory(name, path string) error {
+	path = fs.resolvePath(path)
 	var nameArray [32]byte
 	copy(nameArray[:], name)
 
 	var pathArray [128]byte
 	copy(pathArray[:], path)
 
-	rootDirIndex := fs.CheckDirectoryExists(path)
-	if rootDirIndex == -1 {
-		return fmt.Errorf("erro: O caminho '%s' não existe", path)
+	if fs.CheckDirectoryExists(path) == -1 {
+		return classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", path)
 	}
 
-	var completePath string
-	if path == "/" {
-		completePath = "/" + name
-	} else {
-		completePath = path + "/" + name
+	completePath := joinPath(path, name)
+	rootDirIndex := fs.CheckDirectoryExists(completePath)
+	if name == "" || rootDirIndex == -1 {
+		return classErrorf(ErrNotFound, "erro: O diretório '%s' não existe", completePath)
 	}
 
-	for _, v := range fs.RootDir {
-		trimmedExistingPath := string(bytes.Trim(v.Path[:], "\x00"))
-
-		if trimmedExistingPath == completePath {
+	for i := range fs.RootDir {
+		if fs.RootDir[i].PathString() == completePath {
 			return fmt.Errorf("erro: O diretório '%s' não está vazio", completePath)
 		}
 	}
 
-	fs.RootDir[rootDirIndex] = FileEntry{}
+	err := fs.confirm("O diretório '%s' será removido.", completePath)
+	if err != nil {
+		return err
+	}
+
+	fs.clearEntry(rootDirIndex)
+	logger.Info("diretório removido", fs.imageAttr(), "path", completePath)
 	return nil
 }
 
-func (fs *FURGFileSystem) AddFileEntry(fileEntry FileEntry) error {
-	for i, entry := range fs.RootDir {
-		if entry.Name[0] == 0 {
-			fs.RootDir[i] = fileEntry
-			return nil
+func (fs *FURGFileSystem) AddFileEntry(fileEntry *FileEntry) error {
+	i := fs.freeEntryIndex()
+	if i == -1 {
+		return classErrorf(ErrNoSpace, "erro: Não foi possível adicionar a entrada de arquivo ao sistema de arquivos")
+	}
+	fs.setEntry(i, fileEntry)
+	return nil
+}
+
+// freeEntryIndex retorna a posição da primeira entrada livre do diretório, ou -1.
+func (fs *FURGFileSystem) freeEntryIndex() int {
+	for i := range fs.RootDir {
+		if fs.RootDir[i].Name[0] == 0 {
+			return i
 		}
 	}
-	return fmt.Errorf("erro: Não foi possível adicionar a entrada de arquivo ao sistema de arquivos")
+	return -1
 }
 
 func (fs *FURGFileSystem) CheckDirectoryExists(path string) int {
+	path = fs.resolvePath(path)
 	if path == "/" {
 		return 0
 	}
 
-	var completePath string
-	for i, v := range fs.RootDir {
-		trimmedExistingName := string(bytes.Trim(v.Name[:], "\x00"))
-		trimmedExistingPath := string(bytes.Trim(v.Path[:], "\x00"))
-
-		if trimmedExistingPath == "/" {
-			completePath = "/" + trimmedExistingName
-		} else {
-			completePath = trimmedExistingPath + "/" + trimmedExistingName
-		}
-
-		if completePath == path && fs.RootDir[i].IsDirectory {
+	for i := range fs.RootDir {
+		if entry := &fs.RootDir[i]; entry.IsDirectory && entry.FullPath() == path {
 			return i
 		}
 	}
@@ -709,6 +801,7 @@ func (fs *FURGFileSystem) Tree() {
 		name := string(bytes.Trim(entry.Name[:], "\x00")) // Remove bytes nulos do nome
 		path := string(bytes.Trim(entry.Path[:], "\x00")) // Remove bytes nulos do path
 		if name != "" {
+			name = stdoutColors.entryName(entry, name)
 			if path == "/" {
 				fmt.Printf("/%s (Size: %d bytes)\n", name, entry.Size)
 			} else {
@@ -720,6 +813,7 @@ func (fs *FURGFileSystem) Tree() {
 }
 
 func (fs *FURGFileSystem) RemoveFileFromFileSystem(fileName, path string) error {
+	path = fs.resolvePath(path)
 	var fileNameArray [32]byte
 	copy(fileNameArray[:], fileName)
 
@@ -732,30 +826,38 @@ func (fs *FURGFileSystem) RemoveFileFromFileSystem(fileName, path string) error
 
 	rootDirIndex := fs.CheckFileEntryAlreadyExists(fileNameArray, pathArray)
 	if rootDirIndex == -1 {
-		return fmt.Errorf("erro: O arquivo '%s' em '%s' não foi armazenado no sistema de arquivos", path, fileName)
+		return classErrorf(ErrNotFound, "erro: O arquivo '%s' em '%s' não foi armazenado no sistema de arquivos", path, fileName)
 	}
 
-	f := fs.RootDir[rootDirIndex]
+	f := &fs.RootDir[rootDirIndex]
+	fullPath := f.FullPath()
 
 	if f.Protected {
-		return fmt.Errorf("erro: Arquivo protegido, troque sua proteção para poder remover")
+		err := fs.confirmProtected(fullPath)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := fs.confirm("O arquivo '%s' será removido.", fullPath)
+		if err != nil {
+			return err
+		}
 	}
 
-	nextBlockId := f.FirstBlockID
-	for nextBlockId != 0 {
-		currentFileEntry := fs.FAT[nextBlockId]
-		nextBlockId = currentFileEntry.NextBlockID
-		fs.FAT[nextBlockId] = FATEntry{}
-		fs.Header.FreeSpace += fs.Header.BlockSize
+	released, err := fs.releaseBlocks(f)
+	if err != nil {
+		return err
 	}
 
-	fs.RootDir[rootDirIndex] = FileEntry{}
+	fs.clearEntry(rootDirIndex)
+	logger.Info("arquivo removido", fs.imageAttr(), "path", fullPath, "blocks", released)
 
 	fmt.Printf("O arquivo com nome '%s' em '%s' foi removido no sistema de arquivos.\n", fileName, path)
 	return nil
 }
 
 func (fs *FURGFileSystem) RenameFileFromFileSystem(oldFileName, path, newFileName string) error {
+	path = fs.resolvePath(path)
 	var oldFileNameArray [32]byte
 	copy(oldFileNameArray[:], oldFileName)
 
@@ -764,20 +866,79 @@ func (fs *FURGFileSystem) RenameFileFromFileSystem(oldFileName, path, newFileNam
 
 	rootDirIndex := fs.CheckFileEntryAlreadyExists(oldFileNameArray, pathArray)
 	if rootDirIndex == -1 {
-		return fmt.Errorf("erro: O arquivo com nome '%s' não foi armazenado no sistema de arquivos", oldFileName)
+		return classErrorf(ErrNotFound, "erro: O arquivo com nome '%s' não foi armazenado no sistema de arquivos", oldFileName)
+	}
+
+	if err := fs.Rules.ValidateName(newFileName); err != nil {
+		return err
 	}
 
 	var newFileNameArray [32]byte
 	copy(newFileNameArray[:], newFileName)
+	if fs.CheckFileEntryAlreadyExists(newFileNameArray, pathArray) != -1 {
+		return fmt.Errorf("erro: Já existe uma entrada com o nome '%s' em '%s'", newFileName, path)
+	}
 	if fs.RootDir[rootDirIndex].Protected {
-		return fmt.Errorf("erro: Arquivo protegido, troque sua proteção para poder remover")
+		return classErrorf(ErrProtected, "erro: Arquivo protegido, troque sua proteção para poder remover")
+	}
+	if fs.RootDir[rootDirIndex].IsDirectory {
+		err := fs.renameSubtree(joinPath(path, oldFileName), joinPath(path, newFileName))
+		if err != nil {
+			return err
+		}
 	}
-	fs.RootDir[rootDirIndex].Name = newFileNameArray
+	fs.renameEntry(rootDirIndex, newFileNameArray, pathArray)
+	logger.Info("entrada renomeada", fs.imageAttr(), "path", joinPath(path, oldFileName), "new_name", newFileName)
 
 	fmt.Printf("arquivo '%s' renomeado, antes era '%s", newFileName, oldFileName)
 	return nil
 }
 
+// normalizePath converte um caminho interno para a forma canônica usada no diretório: absoluto, sem barras
+// duplicadas ou finais e com "." e ".." resolvidos (por exemplo, "/docs/../notas/" vira "/notas").
+// Caminhos relativos são resolvidos a partir da raiz e ".." na raiz permanece na raiz; para resolver
+// a partir do diretório atual, use resolvePath.
+func normalizePath(path string) string {
+	return pathpkg.Clean("/" + path)
+}
+
+// resolvePath normaliza um caminho interno informado pelo usuário. Caminhos que não começam com '/'
+// são relativos ao diretório atual (WorkingDir).
+func (fs *FURGFileSystem) resolvePath(path string) string {
+	if !strings.HasPrefix(path, "/") && fs.WorkingDir != "" {
+		path = fs.WorkingDir + "/" + path
+	}
+	return normalizePath(path)
+}
+
+// ChangeDirectory muda o diretório atual para path, que pode ser absoluto ou relativo ao diretório atual.
+func (fs *FURGFileSystem) ChangeDirectory(path string) error {
+	path = fs.resolvePath(path)
+	if fs.CheckDirectoryExists(path) == -1 {
+		return classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", path)
+	}
+	fs.WorkingDir = path
+	return nil
+}
+
+// joinPath monta o caminho completo de uma entrada a partir do caminho do diretório pai e do nome.
+func joinPath(path, name string) string {
+	if path == "/" {
+		return "/" + name
+	}
+	return path + "/" + name
+}
+
+// splitPath normaliza um caminho completo e o separa no caminho do diretório pai e no nome da entrada.
+func splitPath(fullPath string) (string, string) {
+	fullPath = normalizePath(fullPath)
+	i := strings.LastIndex(fullPath, "/")
+	if i <= 0 {
+		return "/", fullPath[i+1:]
+	}
+	return fullPath[:i], fullPath[i+1:]
+}
+
 func isAllNullBytes(s string) bool {
 	for i := 0; i < len(s); i++ {
 		if s[i] != 0 {
@@ -788,12 +949,13 @@ func isAllNullBytes(s string) bool {
 }
 
 func (fs *FURGFileSystem) ShowAllFilesFromFileSystem() {
-	for i, file := range fs.RootDir {
+	for i := range fs.RootDir {
+		file := &fs.RootDir[i]
 		fileName := string(file.Name[:])
 		path := string(file.Path[:])
 
 		if fileName != "" && !isAllNullBytes(fileName) && !file.IsDirectory {
-			fmt.Printf("%d. %s - path: %s", i, fileName, path)
+			fmt.Printf("%d. %s - path: %s", i, stdoutColors.entryName(file, fileName), path)
 			fmt.Printf("  -  %s\n", map[bool]string{true: "protegido", false: "desprotegido"}[file.Protected])
 		}
 	}
@@ -811,36 +973,86 @@ func (fs *FURGFileSystem) ShowFreeSpaceFromFileSystem() {
 	fmt.Printf("Espaço ocupado: %d MB (%.2f%%)\n", occupiedSpace, percentOccupied)
 }
 
-func (fs *FURGFileSystem) ChangePermission(fileName, path string) error {
-	var fileNameArray [32]byte
-	copy(fileNameArray[:], fileName)
-
-	var pathArray [128]byte
-	copy(pathArray[:], path)
-
+// ChangePermission define a proteção contra escrita/remoção de entradas armazenadas no FURGfs2.
+// fileName pode ser o nome de um arquivo, o nome de um diretório (afeta os arquivos dentro dele) ou um padrão glob
+// como "*.txt" (afeta os arquivos de path cujo nome casa com o padrão). Com recursive, os subdiretórios também são percorridos.
+// Ao final é exibido um resumo com as entradas que tiveram a proteção alterada.
+func (fs *FURGFileSystem) ChangePermission(fileName, path string, protected, recursive bool) error {
+	path = fs.resolvePath(path)
 	if isAllNullBytes(fileName) {
 		return fmt.Errorf("erro: Não existem arquivos com nome vazio")
 	}
 
-	rootDirIndex := fs.CheckFileEntryAlreadyExists(fileNameArray, pathArray)
-	if rootDirIndex == -1 {
-		return fmt.Errorf("erro: O arquivo com nome '%s' não foi armazenado no sistema de arquivos", fileName)
-	}
+	var targets []int
+	if strings.ContainsAny(fileName, "*?[") {
+		if _, err := filepath.Match(fileName, ""); err != nil {
+			return fmt.Errorf("erro: Padrão '%s' inválido: %v", fileName, err)
+		}
+		if fs.CheckDirectoryExists(path) == -1 {
+			return classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", path)
+		}
+		for _, i := range fs.entriesInDirectory(path, recursive) {
+			if matched, _ := filepath.Match(fileName, fs.RootDir[i].NameString()); matched && !fs.RootDir[i].IsDirectory {
+				targets = append(targets, i)
+			}
+		}
+	} else if dirPath := joinPath(path, fileName); fs.CheckDirectoryExists(dirPath) != -1 {
+		for _, i := range fs.entriesInDirectory(dirPath, recursive) {
+			if !fs.RootDir[i].IsDirectory {
+				targets = append(targets, i)
+			}
+		}
+	} else {
+		var fileNameArray [32]byte
+		copy(fileNameArray[:], fileName)
 
-	f := &fs.RootDir[rootDirIndex]
-	fmt.Printf("Mudando a proteção do arquivo, agora é: '%s'\n", map[bool]string{true: "protegido", false: "desprotegido"}[f.Protected])
-	f.Protected = !f.Protected
+		var pathArray [128]byte
+		copy(pathArray[:], path)
 
-	if f.Protected {
-		fmt.Printf("O arquivo '%s' agora está protegido.\n", fileName)
-	} else {
-		fmt.Printf("O arquivo '%s' agora está desprotegido.\n", fileName)
+		rootDirIndex := fs.CheckFileEntryAlreadyExists(fileNameArray, pathArray)
+		if rootDirIndex == -1 {
+			return classErrorf(ErrNotFound, "erro: O arquivo com nome '%s' não foi armazenado no sistema de arquivos", fileName)
+		}
+		targets = append(targets, rootDirIndex)
 	}
 
+	state := map[bool]string{true: "protegido", false: "desprotegido"}[protected]
+	changed := 0
+	for _, i := range targets {
+		f := &fs.RootDir[i]
+		if f.Protected == protected {
+			continue
+		}
+		fs.setProtected(i, protected)
+		changed++
+		fmt.Printf("  %s -> %s\n", f.FullPath(), state)
+	}
+	fmt.Printf("%d de %d arquivo(s) encontrados agora estão %ss.\n", changed, len(targets), state)
+
 	return nil
 }
 
+// entriesInDirectory retorna os índices das entradas cujo diretório pai é path.
+// Com recursive, inclui também as entradas de todos os subdiretórios de path.
+func (fs *FURGFileSystem) entriesInDirectory(path string, recursive bool) []int {
+	path = fs.resolvePath(path)
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	var indices []int
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 {
+			continue
+		}
+		entryPath := entry.PathString()
+		if entryPath == path || (recursive && strings.HasPrefix(entryPath, prefix)) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
 func (fs *FURGFileSystem) CopyFileFromFileSystem(fileName, internalPath, externalPath string) error {
+	internalPath = fs.resolvePath(internalPath)
 	var fileNameArray [32]byte
 	copy(fileNameArray[:], []byte(fileName))
 
@@ -855,39 +1067,50 @@ func (fs *FURGFileSystem) CopyFileFromFileSystem(fileName, internalPath, externa
 	// Localizar o arquivo no diretório raiz
 	rootDirIndex := fs.CheckFileEntryAlreadyExists(fileNameArray, internalPathArray)
 	if rootDirIndex == -1 {
-		return fmt.Errorf("erro: O arquivo com nome '%s' não foi encontrado no sistema de arquivos", fileName)
+		return classErrorf(ErrNotFound, "erro: O arquivo com nome '%s' não foi encontrado no sistema de arquivos", fileName)
 	}
 
-	fileEntry := fs.RootDir[rootDirIndex]
-
 	destFile, err := os.Create(externalPath)
 	if err != nil {
 		return fmt.Errorf("erro ao criar o arquivo no sistema real: %v", err)
 	}
 	defer destFile.Close()
 
-	currentBlockID := fileEntry.FirstBlockID
-	for currentBlockID != 0 {
-		offset := int64(fs.Header.DataStart + (currentBlockID * fs.Header.BlockSize))
-		_, err := fs.FilePointer.Seek(offset, 0)
-		if err != nil {
-			return fmt.Errorf("erro ao mover ponteiro para bloco %d: %v", currentBlockID, err)
-		}
-
-		buf := make([]byte, fs.Header.BlockSize)
-		bytesRead, err := fs.FilePointer.Read(buf)
-		if err != nil && err != io.EOF {
-			return fmt.Errorf("erro ao ler bloco %d: %v", currentBlockID, err)
-		}
-
-		_, err = destFile.Write(buf[:bytesRead])
-		if err != nil {
-			return fmt.Errorf("erro ao escrever dados no arquivo destino: %v", err)
-		}
-
-		currentBlockID = fs.FAT[currentBlockID].NextBlockID
+	err = fs.exportFile(&fs.RootDir[rootDirIndex], destFile)
+	if err != nil {
+		return err
 	}
 
 	fmt.Printf("Arquivo '%s' copiado com sucesso para o caminho '%s'.\n", fileName, externalPath)
 	return nil
 }
+
+// exportFile escreve em w o conteúdo do arquivo armazenado na entrada. Sem cifra, os blocos são copiados
+// direto da imagem, um trecho contíguo por vez (veja zerocopy.go); arquivos cifrados são decifrados bloco a
+// bloco, e os grandes são lidos em paralelo com a escrita (veja pipeline.go).
+func (fs *FURGFileSystem) exportFile(entry *FileEntry, w io.Writer) error {
+	blocks, err := fs.fileBlocks(entry)
+	if err != nil {
+		return err
+	}
+	c, err := fs.dataCipher(entry)
+	if err != nil {
+		return err
+	}
+	// Blocos ainda no cache de escritas não estão na imagem
+	if c == nil && !fs.wcache.holds(blocks) {
+		return fs.copySections(blocks, entry.Size, w)
+	}
+	if entry.Size >= pipelineMinBlocks*fs.Header.BlockSize {
+		return fs.copyBlocksOut(blocks, entry.Size, c, w)
+	}
+	r, err := fs.newFileReader(entry)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return fmt.Errorf("erro ao escrever dados no arquivo destino: %v", err)
+	}
+	return nil
+}
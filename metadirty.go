@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// saveFileSystemState não regrava mais o cabeçalho, a FAT e o diretório inteiros: quem altera uma entrada
+// da FAT (veja modifyFAT) ou do diretório (veja markEntryDirty) marca as páginas de metadataPage bytes em
+// que ela fica na imagem, e o salvamento serializa e grava só essas páginas, com as vizinhas juntadas em
+// uma escrita. Num volume grande, criar um arquivo grava poucas páginas da FAT, e não ela toda. O cabeçalho
+// é gravado sempre. Uma imagem nova, ou uma que teve a FAT refeita, como na compactação, é gravada inteira.
+const metadataPage = 4096
+
+// fatEntryBytes é o tamanho de uma entrada da FAT na imagem: BlockID, NextBlockID e Used, sem o
+// alinhamento da estrutura em memória.
+const fatEntryBytes = 9
+
+// fileEntryBytes é o tamanho de uma entrada do diretório na imagem.
+var fileEntryBytes = binary.Size(FileEntry{})
+
+// decodeFAT preenche fat a partir de src, na codificação de binary.Write. A FAT de um volume grande tem
+// milhões de entradas, e decodificá-las sem reflexão torna a carga muito mais rápida.
+func decodeFAT(fat []FATEntry, src []byte) {
+	for i := range fat {
+		b := src[i*fatEntryBytes : (i+1)*fatEntryBytes]
+		fat[i] = FATEntry{
+			BlockID:     binary.LittleEndian.Uint32(b),
+			NextBlockID: binary.LittleEndian.Uint32(b[4:]),
+			Used:        b[8] != 0,
+		}
+	}
+}
+
+// appendFAT acrescenta fat a dst, na codificação de binary.Write.
+func appendFAT(dst []byte, fat []FATEntry) []byte {
+	for _, entry := range fat {
+		dst = binary.LittleEndian.AppendUint32(dst, entry.BlockID)
+		dst = binary.LittleEndian.AppendUint32(dst, entry.NextBlockID)
+		used := byte(0)
+		if entry.Used {
+			used = 1
+		}
+		dst = append(dst, used)
+	}
+	return dst
+}
+
+// metadataSize retorna o tamanho do cabeçalho, da FAT e do diretório raiz na imagem.
+func (fs *FURGFileSystem) metadataSize() int {
+	_, end := fs.fatRegion()
+	return end + len(fs.RootDir)*fileEntryBytes
+}
+
+// markMetaDirty marca para o próximo salvamento as páginas dos metadados entre os bytes lo e hi.
+func (fs *FURGFileSystem) markMetaDirty(lo, hi int) {
+	if fs.dirtyPages == nil {
+		return // tudo será gravado
+	}
+	for page := lo / metadataPage; page <= (hi-1)/metadataPage; page++ {
+		fs.dirtyPages[page] = true
+	}
+}
+
+// modifyFAT retorna a entrada da FAT do bloco blockID, como fatEntry, para ser alterada: sua página é
+// marcada para o próximo salvamento.
+func (fs *FURGFileSystem) modifyFAT(blockID uint32) *FATEntry {
+	entry := fs.fatEntry(blockID)
+	start, _ := fs.fatRegion()
+	off := start + int(blockID)*fatEntryBytes
+	fs.markMetaDirty(off, off+fatEntryBytes)
+	return entry
+}
+
+// markEntryDirty marca para o próximo salvamento a página da entrada i do diretório, alterada na tabela.
+func (fs *FURGFileSystem) markEntryDirty(i int) {
+	_, end := fs.fatRegion()
+	off := end + i*fileEntryBytes
+	fs.markMetaDirty(off, off+fileEntryBytes)
+}
+
+// markAllMetadata faz o próximo salvamento gravar os metadados inteiros.
+func (fs *FURGFileSystem) markAllMetadata() {
+	fs.dirtyPages = nil
+}
+
+// encodeMetadata serializa os bytes de lo a hi dos metadados como ficam na imagem: o cabeçalho, a FAT e o
+// diretório raiz, só nas entradas que cruzam o intervalo.
+func (fs *FURGFileSystem) encodeMetadata(lo, hi int) ([]byte, error) {
+	start, end := fs.fatRegion()
+	meta := make([]byte, 0, hi-lo)
+	if lo < start {
+		header, err := binary.Append(nil, binary.LittleEndian, fs.Header)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao salvar cabeçalho: %v", err)
+		}
+		meta = append(meta, header[lo:min(hi, start)]...)
+	}
+	if lo < end && hi > start {
+		first, last := (max(lo, start)-start)/fatEntryBytes, (min(hi, end)-start-1)/fatEntryBytes
+		base := start + first*fatEntryBytes
+		fat := make([]byte, 0, (last-first+1)*fatEntryBytes)
+		for j := first; j <= last; j++ {
+			// Uma entrada que cruza um trecho não lido da FAT não foi decodificada nem alterada
+			if off := start + j*fatEntryBytes; fs.fatDecoded(off) {
+				fat = appendFAT(fat, fs.FAT[j:j+1])
+			} else {
+				fat = append(fat, fs.rawMeta[off:off+fatEntryBytes]...)
+			}
+		}
+		meta = append(meta, fat[max(lo, start)-base:min(hi, end)-base]...)
+	}
+	if hi > end {
+		first, last := (max(lo, end)-end)/fileEntryBytes, (hi-end-1)/fileEntryBytes
+		base := end + first*fileEntryBytes
+		dir, err := binary.Append(nil, binary.LittleEndian, fs.RootDir[first:last+1])
+		if err != nil {
+			return nil, fmt.Errorf("erro ao salvar diretório raiz: %v", err)
+		}
+		meta = append(meta, dir[max(lo, end)-base:hi-base]...)
+	}
+	return meta, nil
+}
+
+// writeMetadata grava no início da imagem as páginas dos metadados marcadas desde o último salvamento e a
+// do cabeçalho, ou tudo se não houver marcação.
+func (fs *FURGFileSystem) writeMetadata() error {
+	size := fs.metadataSize()
+	total := (size + metadataPage - 1) / metadataPage
+	if len(fs.dirtyPages) != total {
+		fs.dirtyPages = nil
+	}
+	fs.markMetaDirty(0, binary.Size(fs.Header))
+	dirty := func(page int) bool {
+		return fs.dirtyPages == nil || fs.dirtyPages[page]
+	}
+	pages, writes := 0, 0
+	for page := 0; page < total; {
+		if !dirty(page) {
+			page++
+			continue
+		}
+		first := page
+		for page < total && dirty(page) {
+			page++
+		}
+		start, end := first*metadataPage, min(page*metadataPage, size)
+		meta, err := fs.encodeMetadata(start, end)
+		if err != nil {
+			return err
+		}
+		if _, err := fs.FilePointer.WriteAt(meta, int64(start)); err != nil {
+			return fmt.Errorf("erro ao salvar os metadados: %v", err)
+		}
+		pages += page - first
+		writes++
+	}
+	if fs.dirtyPages == nil {
+		fs.dirtyPages = make([]bool, total)
+	} else {
+		clear(fs.dirtyPages)
+	}
+	logger.Debug("metadados gravados", fs.imageAttr(), "pages", pages, "total_pages", total, "writes", writes)
+	return nil
+}
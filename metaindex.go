@@ -0,0 +1,495 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// O índice de metadados é um banco bbolt opcional ao lado da imagem (<imagem>.idx) com o caminho de cada
+// entrada, a sua posição no diretório, o primeiro bloco e o SHA-256 do conteúdo dos arquivos, além dos
+// registros da auditoria por caminho. Com ele, find --hash, a comparação de conteúdo de sync e
+// audit list --path respondem sem ler os arquivos nem percorrer todos os registros. O índice é atualizado
+// a cada estado salvo e guarda uma impressão digital da tabela do diretório: se ela não confere na
+// abertura, porque uma gravação foi interrompida ou a imagem foi alterada sem o índice, ele é refeito.
+const indexSuffix = ".idx"
+
+var (
+	indexEntries     = []byte("entries") // caminho -> indexRecord
+	indexHashes      = []byte("hashes")  // SHA-256 + "\x00" + caminho -> vazio
+	indexAudit       = []byte("audit")   // caminho + "\x00" + sequência -> auditRecord
+	indexMeta        = []byte("meta")
+	indexFingerprint = []byte("fingerprint")
+)
+
+// indexRecord é uma entrada do volume no índice.
+type indexRecord struct {
+	Entry     int    `json:"entry"` // posição na tabela do diretório
+	Directory bool   `json:"directory,omitempty"`
+	Size      uint32 `json:"size,omitempty"`
+	Block     uint32 `json:"block,omitempty"`
+	Protected bool   `json:"protected,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+}
+
+// metaIndex é o índice de uma imagem aberta. Ele envolve o armazenamento da imagem para ser fechado com
+// ela.
+type metaIndex struct {
+	imageStore
+	db   *bolt.DB
+	base map[string]indexRecord // entradas no último estado indexado, sem os hashes
+
+	// relocated indica que os blocos mudaram de lugar sem mudar o conteúdo (veja rebaseJournal): os
+	// hashes dos arquivos com o mesmo caminho e o mesmo tamanho continuam valendo
+	relocated bool
+}
+
+func (ix *metaIndex) Close() error {
+	ix.db.Close()
+	return ix.imageStore.Close()
+}
+
+// indexPath retorna o caminho do índice da imagem imageName.
+func indexPath(imageName string) string {
+	return imageName + indexSuffix
+}
+
+// startIndex passa a manter o índice da imagem, se ele existir, refazendo-o se estiver desatualizado.
+func (fs *FURGFileSystem) startIndex(imageName string) error {
+	if isRemoteImage(imageName) {
+		return nil
+	}
+	if _, err := os.Stat(indexPath(imageName)); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err := fs.openIndex(imageName); err != nil {
+		return err
+	}
+	var fingerprint []byte
+	fs.index.db.View(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(indexMeta); meta != nil {
+			fingerprint = bytes.Clone(meta.Get(indexFingerprint))
+		}
+		return nil
+	})
+	if string(fingerprint) != fs.directoryFingerprint() {
+		logger.Info("índice de metadados desatualizado; refazendo", fs.imageAttr())
+		return fs.rebuildIndex()
+	}
+	fs.index.base = fs.indexSnapshot()
+	return nil
+}
+
+// openIndex abre ou cria o índice da imagem imageName.
+func (fs *FURGFileSystem) openIndex(imageName string) error {
+	db, err := bolt.Open(indexPath(imageName), 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf(tr("erro ao abrir o índice '%s': %v"), indexPath(imageName), err)
+	}
+	fs.index = &metaIndex{imageStore: fs.FilePointer, db: db}
+	fs.FilePointer = fs.index
+	return nil
+}
+
+// closeIndex fecha o índice, devolvendo o armazenamento que ele envolvia.
+func (fs *FURGFileSystem) closeIndex() {
+	fs.index.db.Close()
+	fs.FilePointer = fs.index.imageStore
+	fs.index = nil
+}
+
+// directoryFingerprint resume a tabela do diretório, que muda a cada entrada criada, alterada ou apagada.
+func (fs *FURGFileSystem) directoryFingerprint() string {
+	h := sha256.New()
+	binary.Write(h, binary.LittleEndian, fs.RootDir)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// indexSnapshot retorna as entradas do volume, fora do diretório de sistema, pelo caminho.
+func (fs *FURGFileSystem) indexSnapshot() map[string]indexRecord {
+	records := make(map[string]indexRecord)
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 {
+			continue
+		}
+		p := entry.FullPath()
+		if p == systemDir || strings.HasPrefix(p, systemDir+"/") {
+			continue
+		}
+		records[p] = indexRecord{Entry: i, Directory: entry.IsDirectory, Size: entry.Size, Block: entry.FirstBlockID, Protected: entry.Protected}
+	}
+	return records
+}
+
+func hashKey(hash, p string) []byte {
+	return []byte(hash + "\x00" + p)
+}
+
+// putIndexRecord grava rec como a entrada p, calculando o hash do conteúdo de um arquivo se ele não vier
+// em rec.
+func (fs *FURGFileSystem) putIndexRecord(tx *bolt.Tx, p string, rec indexRecord) error {
+	if !rec.Directory && rec.SHA256 == "" {
+		r, err := fs.newFileReader(&fs.RootDir[rec.Entry])
+		if err != nil {
+			return err
+		}
+		if rec.SHA256, err = hashReader(r); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(indexEntries).Put([]byte(p), data); err != nil {
+		return err
+	}
+	if rec.SHA256 != "" {
+		return tx.Bucket(indexHashes).Put(hashKey(rec.SHA256, p), nil)
+	}
+	return nil
+}
+
+// getIndexRecord lê a entrada p do índice.
+func getIndexRecord(tx *bolt.Tx, p string) (indexRecord, bool) {
+	var rec indexRecord
+	data := tx.Bucket(indexEntries).Get([]byte(p))
+	if data == nil || json.Unmarshal(data, &rec) != nil {
+		return rec, false
+	}
+	return rec, true
+}
+
+// deleteIndexRecord apaga a entrada p do índice.
+func deleteIndexRecord(tx *bolt.Tx, p string) error {
+	if rec, ok := getIndexRecord(tx, p); ok && rec.SHA256 != "" {
+		if err := tx.Bucket(indexHashes).Delete(hashKey(rec.SHA256, p)); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(indexEntries).Delete([]byte(p))
+}
+
+// rebuildIndex refaz o índice inteiro a partir da imagem, lendo o conteúdo de todos os arquivos.
+func (fs *FURGFileSystem) rebuildIndex() error {
+	current := fs.indexSnapshot()
+	err := fs.index.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{indexEntries, indexHashes, indexAudit, indexMeta} {
+			if tx.Bucket(name) != nil {
+				if err := tx.DeleteBucket(name); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		for p, rec := range current {
+			if err := fs.putIndexRecord(tx, p, rec); err != nil {
+				return fmt.Errorf(tr("erro ao indexar '%s': %w"), p, err)
+			}
+		}
+		records, err := fs.AuditRecords()
+		if err != nil {
+			logger.Warn("auditoria não indexada", fs.imageAttr(), "error", err)
+		}
+		for _, rec := range records {
+			if err := putAuditRecord(tx, rec); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(indexMeta).Put(indexFingerprint, []byte(fs.directoryFingerprint()))
+	})
+	if err != nil {
+		return err
+	}
+	fs.index.base, fs.index.relocated = current, false
+	return nil
+}
+
+// updateIndex leva ao índice as entradas criadas, alteradas e apagadas desde o último estado indexado. É
+// chamada por saveFileSystemState depois de gravar os metadados. Como os blocos de dados nunca são
+// reescritos no lugar, um arquivo com o mesmo primeiro bloco e o mesmo tamanho de antes, mesmo que
+// movido, mantém o hash; só o conteúdo novo é lido. Uma falha apenas desatualiza o índice, que é refeito
+// na próxima abertura.
+func (fs *FURGFileSystem) updateIndex() {
+	if fs.index == nil {
+		return
+	}
+	current := fs.indexSnapshot()
+	err := fs.index.db.Update(func(tx *bolt.Tx) error {
+		previous := make(map[[2]uint32]string) // hashes do conteúdo anterior, pelo primeiro bloco e tamanho
+		for p, old := range fs.index.base {
+			if rec, ok := current[p]; ok && rec == old {
+				continue
+			}
+			if rec, ok := getIndexRecord(tx, p); ok && rec.SHA256 != "" {
+				previous[[2]uint32{rec.Block, rec.Size}] = rec.SHA256
+				if fs.index.relocated && current[p].Size == rec.Size && !current[p].Directory {
+					previous[[2]uint32{current[p].Block, rec.Size}] = rec.SHA256
+				}
+			}
+			if err := deleteIndexRecord(tx, p); err != nil {
+				return err
+			}
+		}
+		for p, rec := range current {
+			if old, ok := fs.index.base[p]; ok && old == rec {
+				continue
+			}
+			if !rec.Directory {
+				rec.SHA256 = previous[[2]uint32{rec.Block, rec.Size}]
+			}
+			if err := fs.putIndexRecord(tx, p, rec); err != nil {
+				return fmt.Errorf(tr("erro ao indexar '%s': %w"), p, err)
+			}
+		}
+		return tx.Bucket(indexMeta).Put(indexFingerprint, []byte(fs.directoryFingerprint()))
+	})
+	if err != nil {
+		logger.Warn("erro ao atualizar o índice de metadados", fs.imageAttr(), "error", err)
+		fs.index.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(indexMeta).Delete(indexFingerprint)
+		})
+		return
+	}
+	fs.index.base, fs.index.relocated = current, false
+}
+
+// indexedHash retorna o SHA-256 do arquivo p guardado no índice, se o índice existir e a entrada não
+// tiver mudado desde a última atualização.
+func (fs *FURGFileSystem) indexedHash(p string) (string, bool) {
+	if fs.index == nil {
+		return "", false
+	}
+	dir, name := splitPath(p)
+	index := fs.lookupEntry(name, dir)
+	if index == -1 {
+		return "", false
+	}
+	var rec indexRecord
+	var ok bool
+	fs.index.db.View(func(tx *bolt.Tx) error {
+		rec, ok = getIndexRecord(tx, p)
+		return nil
+	})
+	entry := &fs.RootDir[index]
+	if !ok || rec.SHA256 == "" || rec.Block != entry.FirstBlockID || rec.Size != entry.Size {
+		return "", false
+	}
+	return rec.SHA256, true
+}
+
+// FilesWithHash retorna os caminhos dos arquivos cujo conteúdo tem o SHA-256 hash: pelo índice, se ele
+// existir, ou lendo todos os arquivos.
+func (fs *FURGFileSystem) FilesWithHash(hash string) ([]string, error) {
+	var paths []string
+	if fs.index != nil {
+		prefix := hashKey(hash, "")
+		fs.index.db.View(func(tx *bolt.Tx) error {
+			c := tx.Bucket(indexHashes).Cursor()
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+				paths = append(paths, string(k[len(prefix):]))
+			}
+			return nil
+		})
+		return paths, nil
+	}
+	for p, rec := range fs.indexSnapshot() {
+		if rec.Directory {
+			continue
+		}
+		r, err := fs.newFileReader(&fs.RootDir[rec.Entry])
+		if err != nil {
+			return nil, err
+		}
+		sum, err := hashReader(r)
+		if err != nil {
+			return nil, fmt.Errorf(tr("erro ao ler '%s': %w"), p, err)
+		}
+		if sum == hash {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// auditPaths retorna os caminhos envolvidos em rec, pelos quais ele é indexado: os mesmos que
+// auditRecord.matchesPath compara.
+func auditPaths(rec auditRecord) []string {
+	var paths []string
+	for _, p := range append([]string{rec.Path, rec.Target}, rec.Args...) {
+		if strings.HasPrefix(p, "/") && !slices.Contains(paths, p) {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// putAuditRecord acrescenta rec ao índice, uma vez para cada caminho envolvido.
+func putAuditRecord(tx *bolt.Tx, rec auditRecord) error {
+	bucket := tx.Bucket(indexAudit)
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	for _, p := range auditPaths(rec) {
+		key := binary.BigEndian.AppendUint64([]byte(p+"\x00"), seq)
+		if err := bucket.Put(key, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexAudit acrescenta rec, já registrado na auditoria, ao índice.
+func (fs *FURGFileSystem) indexAudit(rec auditRecord) {
+	if fs.index == nil {
+		return
+	}
+	if err := fs.index.db.Update(func(tx *bolt.Tx) error { return putAuditRecord(tx, rec) }); err != nil {
+		logger.Warn("erro ao indexar a auditoria", fs.imageAttr(), "error", err)
+	}
+}
+
+// indexedAuditRecords retorna, do mais antigo ao mais novo, os registros da auditoria que envolvem p ou algo
+// abaixo dele, se o índice existir.
+func (fs *FURGFileSystem) indexedAuditRecords(p string) ([]auditRecord, bool) {
+	if fs.index == nil {
+		return nil, false
+	}
+	found := make(map[uint64][]byte)
+	fs.index.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(indexAudit).Cursor()
+		for _, prefix := range []string{p + "\x00", strings.TrimSuffix(p, "/") + "/"} {
+			for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+				found[binary.BigEndian.Uint64(k[len(k)-8:])] = bytes.Clone(v)
+			}
+		}
+		return nil
+	})
+	seqs := make([]uint64, 0, len(found))
+	for seq := range found {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	records := make([]auditRecord, 0, len(seqs))
+	for _, seq := range seqs {
+		var rec auditRecord
+		if json.Unmarshal(found[seq], &rec) == nil {
+			records = append(records, rec)
+		}
+	}
+	return records, true
+}
+
+// cliIndex implementa "index enable", "index disable", "index rebuild" e "index status".
+func cliIndex(fs *FURGFileSystem, args []string) error {
+	usage := classErrorf(ErrUsage, "uso: furgfs index enable | index disable | index rebuild | index status [--json]")
+	if len(args) == 0 {
+		return usage
+	}
+	flags := flag.NewFlagSet("index "+args[0], flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "saída em JSON")
+	if err := flags.Parse(args[1:]); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 0 || *asJSON && args[0] != "status" {
+		return usage
+	}
+	imageName := fs.FilePointer.Name()
+
+	switch args[0] {
+	case "enable":
+		if fs.index != nil {
+			fmt.Printf(tr("O índice '%s' já está ativo.\n"), indexPath(imageName))
+			return nil
+		}
+		if isRemoteImage(imageName) {
+			return classErrorf(ErrUsage, "erro: o índice de metadados só é mantido para imagens locais")
+		}
+		if err := fs.openIndex(imageName); err != nil {
+			return err
+		}
+		if err := fs.rebuildIndex(); err != nil {
+			fs.closeIndex()
+			os.Remove(indexPath(imageName))
+			return err
+		}
+		fmt.Printf(tr("Índice de metadados '%s' criado com %d entrada(s).\n"), indexPath(imageName), len(fs.index.base))
+		return nil
+	case "disable":
+		if fs.index == nil {
+			fmt.Println(tr("O volume não tem índice de metadados."))
+			return nil
+		}
+		fs.closeIndex()
+		if err := os.Remove(indexPath(imageName)); err != nil {
+			return err
+		}
+		fmt.Println(tr("Índice de metadados apagado."))
+		return nil
+	case "rebuild":
+		if fs.index == nil {
+			return classErrorf(ErrNotFound, "erro: o volume não tem índice de metadados; use furgfs index enable")
+		}
+		if err := fs.rebuildIndex(); err != nil {
+			return err
+		}
+		fmt.Printf(tr("Índice de metadados refeito com %d entrada(s).\n"), len(fs.index.base))
+		return nil
+	case "status":
+		status := struct {
+			Schema       int    `json:"schema"`
+			Enabled      bool   `json:"enabled"`
+			File         string `json:"file,omitempty"`
+			Size         int64  `json:"size,omitempty"`
+			Entries      int    `json:"entries"`
+			Files        int    `json:"files"`
+			AuditRecords int    `json:"audit_records"`
+		}{Schema: jsonSchemaVersion, Enabled: fs.index != nil}
+		if fs.index != nil {
+			status.File = indexPath(imageName)
+			if info, err := os.Stat(status.File); err == nil {
+				status.Size = info.Size()
+			}
+			fs.index.db.View(func(tx *bolt.Tx) error {
+				status.Entries = tx.Bucket(indexEntries).Stats().KeyN
+				status.Files = tx.Bucket(indexHashes).Stats().KeyN
+				status.AuditRecords = int(tx.Bucket(indexAudit).Sequence())
+				return nil
+			})
+		}
+		if *asJSON {
+			return printJSON(status)
+		}
+		if !status.Enabled {
+			fmt.Println(tr("O volume não tem índice de metadados."))
+			return nil
+		}
+		fmt.Printf(tr("Índice:           %s (%d bytes)\n"), status.File, status.Size)
+		fmt.Printf(tr("Entradas:         %d (%d arquivo(s) com hash)\n"), status.Entries, status.Files)
+		fmt.Printf(tr("Auditoria:        %d registro(s)\n"), status.AuditRecords)
+		return nil
+	default:
+		return usage
+	}
+}
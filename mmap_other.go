@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package main
+
+import "os"
+
+// openMmapStore não é suportado fora do Linux e do macOS; a imagem é acessada por leituras e escritas.
+func openMmapStore(f *os.File) (imageStore, error) {
+	logger.Warn("--mmap não é suportado neste sistema; acessando a imagem sem mapeamento", "image", f.Name())
+	return f, nil
+}
@@ -0,0 +1,119 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmapStore é uma imagem local mapeada na memória (veja mapImages): leituras e escritas são cópias de
+// memória, sem chamadas ao sistema, e o sistema operacional cuida do cache e de levar as páginas ao
+// disco. Escrever além do fim ou truncar a imagem refaz o mapeamento com o novo tamanho.
+type mmapStore struct {
+	file *os.File
+
+	mu   sync.RWMutex // protege data das trocas de mapeamento
+	data []byte
+}
+
+// openMmapStore mapeia a imagem já aberta em f.
+func openMmapStore(f *os.File) (imageStore, error) {
+	m := &mmapStore{file: f}
+	if err := m.remap(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// remap mapeia o arquivo inteiro, no tamanho atual, no lugar do mapeamento anterior.
+func (m *mmapStore) remap() error {
+	if m.data != nil {
+		if err := unix.Munmap(m.data); err != nil {
+			return err
+		}
+		m.data = nil
+	}
+	info, err := m.file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	m.data, err = unix.Mmap(int(m.file.Fd()), 0, int(info.Size()), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	return err
+}
+
+func (m *mmapStore) ReadAt(p []byte, off int64) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *mmapStore) WriteAt(p []byte, off int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if end := off + int64(len(p)); end > int64(len(m.data)) {
+		if err := m.file.Truncate(end); err != nil {
+			return 0, err
+		}
+		if err := m.remap(); err != nil {
+			return 0, err
+		}
+	}
+	return copy(m.data[off:], p), nil
+}
+
+// writeSection escreve em w o trecho da imagem direto do mapeamento.
+func (m *mmapStore) writeSection(w io.Writer, off, n int64) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if off >= int64(len(m.data)) {
+		return 0, nil
+	}
+	written, err := w.Write(m.data[off:min(off+n, int64(len(m.data)))])
+	return int64(written), err
+}
+
+func (m *mmapStore) Truncate(size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.file.Truncate(size); err != nil {
+		return err
+	}
+	return m.remap()
+}
+
+// Sync leva ao disco as páginas alteradas do mapeamento e, depois, os metadados do arquivo.
+func (m *mmapStore) Sync() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.data != nil {
+		if err := unix.Msync(m.data, unix.MS_SYNC); err != nil {
+			return err
+		}
+	}
+	return m.file.Sync()
+}
+
+func (m *mmapStore) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data != nil {
+		unix.Munmap(m.data)
+		m.data = nil
+	}
+	return m.file.Close()
+}
+
+func (m *mmapStore) Name() string               { return m.file.Name() }
+func (m *mmapStore) Stat() (os.FileInfo, error) { return m.file.Stat() }
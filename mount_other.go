@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+// cliMount não é suportado fora do Linux e do macOS, que têm FUSE.
+func cliMount(imageName string, args []string) error {
+	return classErrorf(ErrUsage, "erro: mount exige FUSE, disponível apenas no Linux e no macOS")
+}
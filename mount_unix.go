@@ -0,0 +1,340 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"os/signal"
+	"syscall"
+
+	gofs "github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// fuseNode é um arquivo ou diretório do volume montado. O caminho de cada nó é obtido da árvore de inodes
+// mantida pelo go-fuse, que acompanha as renomeações.
+type fuseNode struct {
+	gofs.Inode
+	vol *sharedVolume
+}
+
+var (
+	_ gofs.NodeLookuper  = (*fuseNode)(nil)
+	_ gofs.NodeReaddirer = (*fuseNode)(nil)
+	_ gofs.NodeGetattrer = (*fuseNode)(nil)
+	_ gofs.NodeSetattrer = (*fuseNode)(nil)
+	_ gofs.NodeOpener    = (*fuseNode)(nil)
+	_ gofs.NodeCreater   = (*fuseNode)(nil)
+	_ gofs.NodeMkdirer   = (*fuseNode)(nil)
+	_ gofs.NodeUnlinker  = (*fuseNode)(nil)
+	_ gofs.NodeRmdirer   = (*fuseNode)(nil)
+	_ gofs.NodeRenamer   = (*fuseNode)(nil)
+)
+
+func (n *fuseNode) path() string {
+	return normalizePath(n.Path(nil))
+}
+
+func (n *fuseNode) child(name string) string {
+	return joinPath(n.path(), name)
+}
+
+// fuseErrno converte os erros do volume nos códigos esperados pelo kernel.
+func fuseErrno(err error) syscall.Errno {
+	var errno syscall.Errno
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &errno):
+		return errno
+	case errors.Is(err, iofs.ErrNotExist), errors.Is(err, ErrNotFound):
+		return syscall.ENOENT
+	case errors.Is(err, iofs.ErrExist):
+		return syscall.EEXIST
+	case errors.Is(err, iofs.ErrPermission), errors.Is(err, ErrProtected):
+		return syscall.EACCES
+	case errors.Is(err, ErrNoSpace):
+		return syscall.ENOSPC
+	case errors.Is(err, ErrUsage):
+		return syscall.EINVAL
+	}
+	logger.Warn("mount: erro de E/S", "error", err)
+	return syscall.EIO
+}
+
+// fillAttr preenche os atributos do kernel a partir de info.
+func fillAttr(info entryInfo, out *fuse.Attr) {
+	out.Mode = uint32(info.Mode().Perm())
+	if info.dir {
+		out.Mode |= syscall.S_IFDIR
+		out.Nlink = 2
+	} else {
+		out.Mode |= syscall.S_IFREG
+		out.Nlink = 1
+	}
+	out.Size = uint64(info.size)
+	out.Blocks = (out.Size + 511) / 512
+	out.SetTimes(&info.modTime, &info.modTime, &info.modTime)
+}
+
+// newChild cria o inode da entrada name, descrita por info.
+func (n *fuseNode) newChild(ctx context.Context, info entryInfo, out *fuse.EntryOut) *gofs.Inode {
+	fillAttr(info, &out.Attr)
+	mode := uint32(syscall.S_IFREG)
+	if info.dir {
+		mode = syscall.S_IFDIR
+	}
+	return n.NewInode(ctx, &fuseNode{vol: n.vol}, gofs.StableAttr{Mode: mode})
+}
+
+func (n *fuseNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*gofs.Inode, syscall.Errno) {
+	info, err := n.vol.Stat(n.child(name))
+	if err != nil {
+		return nil, fuseErrno(err)
+	}
+	return n.newChild(ctx, info, out), 0
+}
+
+func (n *fuseNode) Readdir(ctx context.Context) (gofs.DirStream, syscall.Errno) {
+	infos, err := n.vol.ReadDir(n.path())
+	if err != nil {
+		return nil, fuseErrno(err)
+	}
+	entries := make([]fuse.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fuse.DirEntry{Name: info.name, Mode: syscall.S_IFREG}
+		if info.dir {
+			entries[i].Mode = syscall.S_IFDIR
+		}
+	}
+	return gofs.NewListDirStream(entries), 0
+}
+
+func (n *fuseNode) Getattr(ctx context.Context, f gofs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	var info iofs.FileInfo
+	var err error
+	if h, ok := f.(*fuseHandle); ok {
+		// O conteúdo ainda não gravado no volume define o tamanho
+		info, err = h.file.Stat()
+	} else {
+		info, err = n.vol.Stat(n.path())
+	}
+	if err != nil {
+		return fuseErrno(err)
+	}
+	fillAttr(info.(entryInfo), &out.Attr)
+	return 0
+}
+
+// Setattr aceita mudanças de tamanho (truncate) e de permissão: retirar a escrita protege o arquivo.
+func (n *fuseNode) Setattr(ctx context.Context, f gofs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if size, ok := in.GetSize(); ok {
+		var err error
+		if h, ok := f.(*fuseHandle); ok && h.file.writable {
+			err = h.file.Truncate(int64(size))
+		} else {
+			err = n.vol.Truncate(n.path(), int64(size))
+		}
+		if err != nil {
+			return fuseErrno(err)
+		}
+	}
+	if mode, ok := in.GetMode(); ok && !n.IsDir() {
+		if err := n.vol.SetProtected(n.path(), mode&0200 == 0); err != nil {
+			return fuseErrno(err)
+		}
+	}
+	return n.Getattr(ctx, f, out)
+}
+
+func (n *fuseNode) Open(ctx context.Context, flags uint32) (gofs.FileHandle, uint32, syscall.Errno) {
+	file, err := n.vol.OpenFile(n.path(), int(flags)&(os.O_WRONLY|os.O_RDWR|os.O_TRUNC|os.O_APPEND))
+	if err != nil {
+		return nil, 0, fuseErrno(err)
+	}
+	return &fuseHandle{file}, 0, 0
+}
+
+func (n *fuseNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*gofs.Inode, gofs.FileHandle, uint32, syscall.Errno) {
+	p := n.child(name)
+	file, err := n.vol.OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return nil, nil, 0, fuseErrno(err)
+	}
+	info, err := n.vol.Stat(p)
+	if err != nil {
+		return nil, nil, 0, fuseErrno(err)
+	}
+	return n.newChild(ctx, info, out), &fuseHandle{file}, 0, 0
+}
+
+func (n *fuseNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*gofs.Inode, syscall.Errno) {
+	p := n.child(name)
+	if err := n.vol.Mkdir(p); err != nil {
+		return nil, fuseErrno(err)
+	}
+	info, err := n.vol.Stat(p)
+	if err != nil {
+		return nil, fuseErrno(err)
+	}
+	return n.newChild(ctx, info, out), 0
+}
+
+func (n *fuseNode) Unlink(ctx context.Context, name string) syscall.Errno {
+	return fuseErrno(n.vol.Remove(n.child(name)))
+}
+
+func (n *fuseNode) Rmdir(ctx context.Context, name string) syscall.Errno {
+	return fuseErrno(n.vol.Remove(n.child(name)))
+}
+
+func (n *fuseNode) Rename(ctx context.Context, name string, newParent gofs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	if flags != 0 {
+		// RENAME_EXCHANGE e RENAME_NOREPLACE não são suportados
+		return syscall.EINVAL
+	}
+	return fuseErrno(n.vol.Rename(n.child(name), newParent.EmbeddedInode().Operations().(*fuseNode).child(newName)))
+}
+
+// fuseHandle é um arquivo aberto pelo kernel. O conteúdo escrito é gravado no volume, por inteiro, quando o
+// arquivo é fechado ou sincronizado.
+type fuseHandle struct {
+	file *sharedFile
+}
+
+var (
+	_ gofs.FileReader   = (*fuseHandle)(nil)
+	_ gofs.FileWriter   = (*fuseHandle)(nil)
+	_ gofs.FileFlusher  = (*fuseHandle)(nil)
+	_ gofs.FileFsyncer  = (*fuseHandle)(nil)
+	_ gofs.FileReleaser = (*fuseHandle)(nil)
+)
+
+func (h *fuseHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	n, err := h.file.ReadAt(dest, off)
+	if err != nil && err != io.EOF {
+		return nil, fuseErrno(err)
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+func (h *fuseHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	n, err := h.file.WriteAt(data, off)
+	return uint32(n), fuseErrno(err)
+}
+
+func (h *fuseHandle) Flush(ctx context.Context) syscall.Errno { return fuseErrno(h.file.Sync()) }
+func (h *fuseHandle) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+	if err := h.file.Sync(); err != nil {
+		return fuseErrno(err)
+	}
+	// fsync também grava o lote de alterações em aberto e leva a imagem ao disco, com os blocos ainda no
+	// cache de escritas
+	vol := h.file.vol
+	return fuseErrno(vol.with(func(fs *FURGFileSystem) error {
+		if err := vol.commitBatch(); err != nil {
+			return err
+		}
+		return fs.Flush()
+	}))
+}
+func (h *fuseHandle) Release(ctx context.Context) syscall.Errno { return fuseErrno(h.file.Close()) }
+
+// fuseMount é um volume montado com FUSE, atendido em segundo plano pelo go-fuse.
+type fuseMount struct {
+	fs     *FURGFileSystem
+	vol    *sharedVolume
+	server *fuse.Server
+}
+
+// mountVolume monta a imagem imageName em mountpoint, com as opções de options; o nome e o dono do sistema
+// de arquivos são preenchidos aqui. As alterações são registradas na auditoria em nome do usuário local.
+func mountVolume(imageName, mountpoint string, archives bool, options fuse.MountOptions) (*fuseMount, error) {
+	fs, err := loadFileSystem(imageName)
+	if err != nil {
+		return nil, err
+	}
+	vol, err := newSharedVolume(fs)
+	if err != nil {
+		fs.FilePointer.Close()
+		return nil, err
+	}
+	vol.source, vol.archives = "mount", archives
+	vol = vol.as(localUser())
+
+	options.FsName, options.Name, options.DirectMount = imageName, "furgfs", true
+	server, err := gofs.Mount(mountpoint, &fuseNode{vol: vol}, &gofs.Options{
+		MountOptions: options,
+		UID:          uint32(os.Getuid()),
+		GID:          uint32(os.Getgid()),
+	})
+	if err != nil {
+		vol.hooks.Close()
+		fs.FilePointer.Close()
+		return nil, fmt.Errorf("erro ao montar '%s': %v", mountpoint, err)
+	}
+	return &fuseMount{fs: fs, vol: vol, server: server}, nil
+}
+
+// wait espera o ponto de montagem ser desmontado, grava o lote de alterações em aberto e fecha a imagem.
+func (m *fuseMount) wait() {
+	m.server.Wait()
+	if err := m.vol.flushBatch(); err != nil {
+		printError(err)
+	}
+	m.vol.hooks.Close()
+	if err := m.fs.closeImage(); err != nil {
+		printError(err)
+	}
+}
+
+// cliMount implementa "mount [--allow-other] [--debug] [--archives] [--commit-delay <duração>] [imagem] <ponto-de-montagem>": monta o volume com FUSE
+// e atende o kernel até o ponto de montagem ser desmontado (umount ou fusermount -u) ou até Ctrl+C.
+func cliMount(imageName string, args []string) error {
+	flags := flag.NewFlagSet("mount", flag.ContinueOnError)
+	allowOther := flags.Bool("allow-other", false, "permite o acesso de outros usuários (exige user_allow_other em /etc/fuse.conf)")
+	debug := flags.Bool("debug", false, "registra as mensagens trocadas com o kernel")
+	archives := flags.Bool("archives", false, "mostra os arquivos zip e tar como diretórios somente leitura")
+	flags.DurationVar(&commitDelay, "commit-delay", commitDelay, "junta as alterações feitas nesse intervalo em uma só gravação dos metadados")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return classErrorf(ErrUsage, "uso: furgfs mount [--allow-other] [--debug] [--archives] [--commit-delay <duração>] [imagem] <ponto-de-montagem>")
+	}
+	mountpoint := flags.Arg(flags.NArg() - 1)
+	if flags.NArg() == 2 {
+		var err error
+		if imageName, err = resolveImage(flags.Arg(0)); err != nil {
+			return err
+		}
+	}
+	if info, err := os.Stat(mountpoint); err != nil || !info.IsDir() {
+		return classErrorf(ErrNotFound, "erro: o ponto de montagem '%s' não é um diretório", mountpoint)
+	}
+
+	m, err := mountVolume(imageName, mountpoint, *archives, fuse.MountOptions{AllowOther: *allowOther, Debug: *debug})
+	if err != nil {
+		return err
+	}
+	fmt.Printf(tr("'%s' montada em '%s'; use umount ou Ctrl+C para desmontar.\n"), imageName, mountpoint)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		if err := m.server.Unmount(); err != nil {
+			printError(fmt.Errorf(tr("erro ao desmontar '%s': %v"), mountpoint, err))
+		}
+	}()
+	m.wait()
+	signal.Stop(signals)
+	fmt.Printf(tr("'%s' desmontada.\n"), mountpoint)
+	return nil
+}
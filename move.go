@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MoveEntry move ou renomeia o arquivo ou diretório source para destination. Se destination for um
+// diretório existente, a entrada é movida para dentro dele mantendo o nome. Ao mover um diretório,
+// o caminho de todas as entradas abaixo dele também é atualizado.
+func (fs *FURGFileSystem) MoveEntry(source, destination string) error {
+	source = fs.resolvePath(source)
+	destination = fs.resolvePath(destination)
+
+	srcDir, srcName := splitPath(source)
+	index := fs.lookupEntry(srcName, srcDir)
+	if source == "/" || index == -1 {
+		return classErrorf(ErrNotFound, "erro: '%s' não foi encontrado no sistema de arquivos", source)
+	}
+	if destination != source && fs.CheckDirectoryExists(destination) != -1 {
+		destination = joinPath(destination, srcName)
+	}
+	if destination == source {
+		return nil
+	}
+
+	dstDir, dstName := splitPath(destination)
+	if err := fs.Rules.ValidateEntry(dstDir, dstName); err != nil {
+		return err
+	}
+	if fs.CheckDirectoryExists(dstDir) == -1 {
+		return classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", dstDir)
+	}
+	if fs.lookupEntry(dstName, dstDir) != -1 {
+		return fmt.Errorf("erro: Já existe uma entrada com o nome '%s' em '%s'", dstName, dstDir)
+	}
+
+	if (srcDir == systemDir || source == systemDir) != (dstDir == systemDir) {
+		// O conteúdo dos arquivos de sistema não é cifrado
+		if on, err := fs.encrypted(); err != nil || on {
+			if err == nil {
+				err = classErrorf(ErrUsage, "erro: em um volume cifrado, arquivos não podem entrar nem sair de %s", systemDir)
+			}
+			return err
+		}
+	}
+
+	entry := &fs.RootDir[index]
+	if err := fs.checkMoveQuota(source, dstDir, entry); err != nil {
+		return err
+	}
+	if entry.Protected {
+		if err := fs.confirmProtected(source); err != nil {
+			return err
+		}
+	}
+	if entry.IsDirectory {
+		if strings.HasPrefix(destination, source+"/") {
+			return fmt.Errorf("erro: não é possível mover '%s' para dentro de si mesmo", source)
+		}
+		if err := fs.renameSubtree(source, destination); err != nil {
+			return err
+		}
+		if err := fs.moveQuotas(source, destination); err != nil {
+			return err
+		}
+	}
+	if err := fs.moveOwners(source, destination); err != nil {
+		return err
+	}
+
+	var nameArray [32]byte
+	copy(nameArray[:], dstName)
+	var pathArray [128]byte
+	copy(pathArray[:], dstDir)
+	fs.renameEntry(index, nameArray, pathArray)
+	logger.Info("entrada movida", fs.imageAttr(), "from", source, "to", destination)
+	return nil
+}
+
+// renameSubtree troca o prefixo oldDir por newDir no caminho de todas as entradas abaixo de oldDir.
+// Os novos caminhos são validados antes de qualquer alteração.
+func (fs *FURGFileSystem) renameSubtree(oldDir, newDir string) error {
+	indices := fs.entriesInDirectory(oldDir, true)
+	newPaths := make([]string, len(indices))
+	for k, i := range indices {
+		newPaths[k] = newDir + strings.TrimPrefix(fs.RootDir[i].PathString(), oldDir)
+		if err := fs.Rules.ValidatePath(joinPath(newPaths[k], fs.RootDir[i].NameString())); err != nil {
+			return err
+		}
+	}
+
+	for k, i := range indices {
+		var pathArray [128]byte
+		copy(pathArray[:], newPaths[k])
+		fs.renameEntry(i, fs.RootDir[i].Name, pathArray)
+	}
+	return nil
+}
+
+// removeInternalTree remove o arquivo ou diretório name, em dir, com todo o seu conteúdo.
+func (fs *FURGFileSystem) removeInternalTree(dir, name string) error {
+	index := fs.lookupEntry(name, dir)
+	if index == -1 {
+		return classErrorf(ErrNotFound, "erro: '%s' não foi encontrado no sistema de arquivos", joinPath(dir, name))
+	}
+	if !fs.RootDir[index].IsDirectory {
+		return fs.RemoveFileFromFileSystem(name, dir)
+	}
+
+	full := joinPath(dir, name)
+	for _, i := range fs.entriesInDirectory(full, false) {
+		err := fs.removeInternalTree(full, fs.RootDir[i].NameString())
+		if err != nil {
+			return err
+		}
+	}
+	return fs.DeleteDirectory(name, dir)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+)
+
+// "serve nbd" exporta a imagem inteira, byte a byte, pelo protocolo Network Block Device (handshake
+// "fixed newstyle", respostas simples). Ao contrário dos outros protocolos, o cliente vê o formato do
+// FURGfs2 e não os arquivos: o dispositivo pode ser aberto por "furgfs -i /dev/nbd0" em outra máquina ou
+// inspecionado com ferramentas de blocos. As escritas vão direto para o arquivo da imagem.
+const (
+	nbdMagic        = 0x4e42444d41474943 // "NBDMAGIC"
+	nbdOptionMagic  = 0x49484156454f5054 // "IHAVEOPT"
+	nbdReplyMagic   = 0x0003e889045565a9
+	nbdRequestMagic = 0x25609513
+	nbdSimpleMagic  = 0x67446698
+
+	nbdFlagFixedNewstyle = 1 << 0
+	nbdFlagNoZeroes      = 1 << 1
+
+	nbdOptExportName = 1
+	nbdOptAbort      = 2
+	nbdOptList       = 3
+	nbdOptInfo       = 6
+	nbdOptGo         = 7
+
+	nbdRepAck          = 1
+	nbdRepServer       = 2
+	nbdRepInfo         = 3
+	nbdRepErrUnsup     = 1<<31 | 1
+	nbdRepErrInvalid   = 1<<31 | 3
+	nbdRepErrUnknown   = 1<<31 | 6
+	nbdInfoExport      = 0
+	nbdInfoBlockSize   = 3
+	nbdMaxOptionLength = 4096
+
+	nbdFlagHasFlags        = 1 << 0
+	nbdFlagReadOnly        = 1 << 1
+	nbdFlagSendFlush       = 1 << 2
+	nbdFlagSendFUA         = 1 << 3
+	nbdFlagSendWriteZeroes = 1 << 6
+	nbdFlagCanMultiConn    = 1 << 8
+
+	nbdCmdRead        = 0
+	nbdCmdWrite       = 1
+	nbdCmdDisc        = 2
+	nbdCmdFlush       = 3
+	nbdCmdWriteZeroes = 6
+	nbdCmdFlagFUA     = 1 << 0
+	nbdMaxRequest     = 32 << 20
+
+	nbdEPERM  = 1
+	nbdEIO    = 5
+	nbdEINVAL = 22
+	nbdENOSPC = 28
+)
+
+// nbdServer atende o protocolo NBD sobre o arquivo da imagem.
+type nbdServer struct {
+	file      imageStore
+	name      string // nome da exportação, o nome do arquivo da imagem
+	size      uint64
+	blockSize uint32
+	readOnly  bool
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]bool
+}
+
+// setupNBD prepara "serve nbd".
+func setupNBD(flags *flag.FlagSet) func(vol *sharedVolume) (shareServer, error) {
+	readOnly := flags.Bool("read-only", false, "recusa as escritas dos clientes")
+	return func(vol *sharedVolume) (shareServer, error) {
+		s := &nbdServer{readOnly: *readOnly, conns: make(map[net.Conn]bool)}
+		err := vol.with(func(fs *FURGFileSystem) error {
+			info, err := fs.FilePointer.Stat()
+			if err != nil {
+				return err
+			}
+			s.file, s.name, s.size = fs.FilePointer, filepath.Base(fs.FilePointer.Name()), uint64(info.Size())
+			s.blockSize = fs.freeSpaceInfo().BlockSize
+			return nil
+		})
+		return s, err
+	}
+}
+
+// Serve aceita conexões até Close.
+func (s *nbdServer) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		go func() {
+			if err := s.handleConn(conn); err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				logger.Warn("nbd: conexão encerrada", "remote", conn.RemoteAddr().String(), "error", err)
+			}
+			conn.Close()
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close encerra o servidor e todas as conexões abertas.
+func (s *nbdServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// nbdConn é uma conexão, com a leitura e a escrita em buffer.
+type nbdConn struct {
+	r        *bufio.Reader
+	w        *bufio.Writer
+	noZeroes bool
+}
+
+func (c *nbdConn) read(v ...any) error {
+	for _, field := range v {
+		if err := binary.Read(c.r, binary.BigEndian, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *nbdConn) write(v ...any) {
+	for _, field := range v {
+		binary.Write(c.w, binary.BigEndian, field)
+	}
+}
+
+// reply envia uma resposta a uma opção da negociação.
+func (c *nbdConn) reply(option, kind uint32, data []byte) error {
+	c.write(uint64(nbdReplyMagic), option, kind, uint32(len(data)), data)
+	return c.w.Flush()
+}
+
+func (s *nbdServer) handleConn(conn net.Conn) error {
+	c := &nbdConn{r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	c.write(uint64(nbdMagic), uint64(nbdOptionMagic), uint16(nbdFlagFixedNewstyle|nbdFlagNoZeroes))
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	var clientFlags uint32
+	if err := c.read(&clientFlags); err != nil {
+		return err
+	}
+	if clientFlags&nbdFlagFixedNewstyle == 0 {
+		return errors.New("cliente sem suporte ao handshake fixed newstyle")
+	}
+	c.noZeroes = clientFlags&nbdFlagNoZeroes != 0
+	logger.Info("nbd: conexão", "remote", conn.RemoteAddr().String())
+
+	ready, err := s.negotiate(c)
+	if err != nil || !ready {
+		return err
+	}
+	return s.transmit(c)
+}
+
+// transmissionFlags são as opções da exportação anunciadas ao cliente.
+func (s *nbdServer) transmissionFlags() uint16 {
+	flags := uint16(nbdFlagHasFlags | nbdFlagSendFlush | nbdFlagSendFUA | nbdFlagCanMultiConn)
+	if s.readOnly {
+		flags |= nbdFlagReadOnly
+	} else {
+		flags |= nbdFlagSendWriteZeroes
+	}
+	return flags
+}
+
+// negotiate trata as opções do cliente até que ele escolha a exportação; retorna falso se ele desistir.
+func (s *nbdServer) negotiate(c *nbdConn) (bool, error) {
+	for {
+		var magic uint64
+		var option, length uint32
+		if err := c.read(&magic, &option, &length); err != nil {
+			return false, err
+		}
+		if magic != nbdOptionMagic || length > nbdMaxOptionLength {
+			return false, errors.New("opção NBD inválida")
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return false, err
+		}
+
+		switch option {
+		case nbdOptExportName:
+			// Sem como responder um erro: um nome desconhecido encerra a conexão
+			if name := string(data); name != "" && name != s.name {
+				return false, errors.New("exportação desconhecida: " + name)
+			}
+			c.write(s.size, s.transmissionFlags())
+			if !c.noZeroes {
+				c.write(make([]byte, 124))
+			}
+			return true, c.w.Flush()
+		case nbdOptAbort:
+			return false, c.reply(option, nbdRepAck, nil)
+		case nbdOptList:
+			export := append(binary.BigEndian.AppendUint32(nil, uint32(len(s.name))), s.name...)
+			if err := c.reply(option, nbdRepServer, export); err != nil {
+				return false, err
+			}
+			if err := c.reply(option, nbdRepAck, nil); err != nil {
+				return false, err
+			}
+		case nbdOptInfo, nbdOptGo:
+			if len(data) < 6 || int(binary.BigEndian.Uint32(data))+6 > len(data) {
+				if err := c.reply(option, nbdRepErrInvalid, nil); err != nil {
+					return false, err
+				}
+				continue
+			}
+			if name := string(data[4 : 4+binary.BigEndian.Uint32(data)]); name != "" && name != s.name {
+				if err := c.reply(option, nbdRepErrUnknown, nil); err != nil {
+					return false, err
+				}
+				continue
+			}
+			info := binary.BigEndian.AppendUint16(nil, nbdInfoExport)
+			info = binary.BigEndian.AppendUint64(info, s.size)
+			info = binary.BigEndian.AppendUint16(info, s.transmissionFlags())
+			if err := c.reply(option, nbdRepInfo, info); err != nil {
+				return false, err
+			}
+			sizes := binary.BigEndian.AppendUint16(nil, nbdInfoBlockSize)
+			sizes = binary.BigEndian.AppendUint32(sizes, 1)
+			sizes = binary.BigEndian.AppendUint32(sizes, s.blockSize)
+			sizes = binary.BigEndian.AppendUint32(sizes, nbdMaxRequest)
+			if err := c.reply(option, nbdRepInfo, sizes); err != nil {
+				return false, err
+			}
+			if err := c.reply(option, nbdRepAck, nil); err != nil {
+				return false, err
+			}
+			if option == nbdOptGo {
+				return true, nil
+			}
+		default:
+			if err := c.reply(option, nbdRepErrUnsup, nil); err != nil {
+				return false, err
+			}
+		}
+	}
+}
+
+// transmit atende os pedidos de leitura e escrita até o cliente desconectar.
+func (s *nbdServer) transmit(c *nbdConn) error {
+	for {
+		var magic uint32
+		var flags, command uint16
+		var handle, offset uint64
+		var length uint32
+		if err := c.read(&magic, &flags, &command, &handle, &offset, &length); err != nil {
+			return err
+		}
+		if magic != nbdRequestMagic {
+			return errors.New("pedido NBD inválido")
+		}
+		var data []byte
+		if command == nbdCmdWrite {
+			if length > nbdMaxRequest {
+				return errors.New("escrita NBD grande demais")
+			}
+			data = make([]byte, length)
+			if _, err := io.ReadFull(c.r, data); err != nil {
+				return err
+			}
+		}
+
+		var errno uint32
+		var payload []byte
+		outside := offset > s.size || uint64(length) > s.size-offset
+		switch {
+		case command == nbdCmdDisc:
+			return c.w.Flush()
+		case command == nbdCmdFlush:
+			if !s.readOnly && s.file.Sync() != nil {
+				errno = nbdEIO
+			}
+		case command != nbdCmdRead && command != nbdCmdWrite && command != nbdCmdWriteZeroes:
+			errno = nbdEINVAL
+		case length > nbdMaxRequest:
+			errno = nbdEINVAL
+		case outside && command == nbdCmdRead:
+			errno = nbdEINVAL
+		case outside:
+			errno = nbdENOSPC
+		case command == nbdCmdRead:
+			payload = make([]byte, length)
+			if _, err := s.file.ReadAt(payload, int64(offset)); err != nil {
+				errno, payload = nbdEIO, nil
+			}
+		case s.readOnly:
+			errno = nbdEPERM
+		default:
+			if command == nbdCmdWriteZeroes {
+				data = make([]byte, length)
+			}
+			if _, err := s.file.WriteAt(data, int64(offset)); err != nil {
+				errno = nbdEIO
+			} else if flags&nbdCmdFlagFUA != 0 && s.file.Sync() != nil {
+				errno = nbdEIO
+			}
+		}
+		c.write(uint32(nbdSimpleMagic), errno, handle)
+		if errno == 0 && payload != nil {
+			c.w.Write(payload)
+		}
+		if err := c.w.Flush(); err != nil {
+			return err
+		}
+	}
+}
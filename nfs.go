@@ -0,0 +1,985 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"io"
+	iofs "io/fs"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// O servidor NFS implementa a versão 3 do NFS e do MOUNT (RFC 1813) sobre ONC RPC (RFC 5531), apenas por TCP
+// e com os dois programas na mesma porta; não há portmapper, então o cliente deve informar a porta ao montar.
+// Como no NFS tradicional, não há senha: a credencial AUTH_UNIX só define o dono exibido dos arquivos.
+// Os identificadores de arquivo valem enquanto o servidor estiver em execução.
+const (
+	nfsProgram   = 100003
+	mountProgram = 100005
+	nfsVersion   = 3
+
+	nfsMaxRecord   = 1024 * 1024
+	nfsMaxTransfer = 128 * 1024
+	nfsHandleSize  = 16
+	nfsFSID        = 0x46555247 // "FURG"
+	nfsIdleFlush   = 5 * time.Second
+)
+
+// Estados de mensagens RPC.
+const (
+	rpcCall          = 0
+	rpcReply         = 1
+	rpcMsgAccepted   = 0
+	rpcMsgDenied     = 1
+	rpcSuccess       = 0
+	rpcProgUnavail   = 1
+	rpcProgMismatch  = 2
+	rpcProcUnavail   = 3
+	rpcGarbageArgs   = 4
+	rpcMismatch      = 0
+	rpcAuthNone      = 0
+	rpcAuthUnix      = 1
+	mountOK          = 0
+	mountErrNoEnt    = 2
+	mountErrNotDir   = 20
+	mountErrServFail = 10006
+)
+
+// Procedimentos do MOUNT v3.
+const (
+	mountProcNull    = 0
+	mountProcMnt     = 1
+	mountProcDump    = 2
+	mountProcUmnt    = 3
+	mountProcUmntAll = 4
+	mountProcExport  = 5
+)
+
+// Procedimentos do NFS v3.
+const (
+	nfsProcNull        = 0
+	nfsProcGetattr     = 1
+	nfsProcSetattr     = 2
+	nfsProcLookup      = 3
+	nfsProcAccess      = 4
+	nfsProcReadlink    = 5
+	nfsProcRead        = 6
+	nfsProcWrite       = 7
+	nfsProcCreate      = 8
+	nfsProcMkdir       = 9
+	nfsProcSymlink     = 10
+	nfsProcMknod       = 11
+	nfsProcRemove      = 12
+	nfsProcRmdir       = 13
+	nfsProcRename      = 14
+	nfsProcLink        = 15
+	nfsProcReaddir     = 16
+	nfsProcReaddirplus = 17
+	nfsProcFsstat      = 18
+	nfsProcFsinfo      = 19
+	nfsProcPathconf    = 20
+	nfsProcCommit      = 21
+)
+
+// Códigos nfsstat3.
+const (
+	nfsOK             = 0
+	nfsErrNoEnt       = 2
+	nfsErrIO          = 5
+	nfsErrAcces       = 13
+	nfsErrExist       = 17
+	nfsErrNotDir      = 20
+	nfsErrIsDir       = 21
+	nfsErrInval       = 22
+	nfsErrNoSpc       = 28
+	nfsErrROFS        = 30
+	nfsErrNameTooLong = 63
+	nfsErrNotEmpty    = 66
+	nfsErrStale       = 70
+	nfsErrBadHandle   = 10001
+	nfsErrNotSupp     = 10004
+	nfsErrTooSmall    = 10005
+)
+
+// Tipos de arquivo, modos de escrita e de criação e bits de ACCESS.
+const (
+	nfsTypeReg = 1
+	nfsTypeDir = 2
+
+	nfsUnstable = 0
+	nfsFileSync = 2
+
+	nfsCreateGuarded   = 1
+	nfsCreateExclusive = 2
+
+	nfsAccessRead    = 0x01
+	nfsAccessLookup  = 0x02
+	nfsAccessModify  = 0x04
+	nfsAccessExtend  = 0x08
+	nfsAccessDelete  = 0x10
+	nfsAccessExecute = 0x20
+)
+
+// errGarbageArgs indica argumentos RPC malformados.
+var errGarbageArgs = errors.New("argumentos RPC malformados")
+
+// xdrDecoder lê os campos XDR de uma mensagem recebida.
+type xdrDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *xdrDecoder) take(n int) []byte {
+	if d.err != nil || n < 0 || len(d.data) < n {
+		d.err = errGarbageArgs
+		return make([]byte, max(n, 0))
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *xdrDecoder) uint32() uint32 { return binary.BigEndian.Uint32(d.take(4)) }
+func (d *xdrDecoder) uint64() uint64 { return binary.BigEndian.Uint64(d.take(8)) }
+func (d *xdrDecoder) bool() bool     { return d.uint32() != 0 }
+
+// opaque lê um opaque<> de tamanho variável, cujo conteúdo é completado com zeros até múltiplo de 4.
+func (d *xdrDecoder) opaque() []byte {
+	n := d.uint32()
+	if n > uint32(len(d.data)) {
+		d.err = errGarbageArgs
+		return nil
+	}
+	b := d.take(int(n))
+	d.take(int(-n & 3))
+	return b
+}
+
+func (d *xdrDecoder) string() string { return string(d.opaque()) }
+
+// xdrEncoder monta uma mensagem XDR.
+type xdrEncoder []byte
+
+func (e xdrEncoder) uint32(v uint32) xdrEncoder { return binary.BigEndian.AppendUint32(e, v) }
+func (e xdrEncoder) uint64(v uint64) xdrEncoder { return binary.BigEndian.AppendUint64(e, v) }
+
+func (e xdrEncoder) bool(v bool) xdrEncoder {
+	if v {
+		return e.uint32(1)
+	}
+	return e.uint32(0)
+}
+
+func (e xdrEncoder) opaque(b []byte) xdrEncoder {
+	e = append(e.uint32(uint32(len(b))), b...)
+	return append(e, make([]byte, -len(b)&3)...)
+}
+
+func (e xdrEncoder) string(s string) xdrEncoder { return e.opaque([]byte(s)) }
+
+// nfsCall é uma chamada RPC recebida.
+type nfsCall struct {
+	xid       uint32
+	program   uint32
+	version   uint32
+	procedure uint32
+	uid, gid  uint32
+	args      *xdrDecoder
+}
+
+// nfsWriter guarda o conteúdo escrito em um arquivo até o COMMIT do cliente, já que o FURGfs2 só grava
+// arquivos inteiros.
+type nfsWriter struct {
+	file *sharedFile
+	last time.Time
+}
+
+// nfsServer atende NFS e MOUNT sobre um sharedVolume. Os pedidos são atendidos um de cada vez, mesmo vindos
+// de conexões diferentes.
+type nfsServer struct {
+	vol      *sharedVolume
+	readOnly bool
+	verifier [8]byte // muda a cada execução, para o cliente reenviar as escritas não confirmadas
+
+	mu         sync.Mutex
+	generation uint64
+	paths      map[uint64]string
+	ids        map[string]uint64
+	next       uint64
+	writers    map[uint64]*nfsWriter
+	exclusive  map[uint64][8]byte // verificadores de CREATE exclusivo
+
+	listener net.Listener
+	conns    map[net.Conn]bool
+	done     chan struct{}
+}
+
+// setupNFS prepara "serve nfs".
+func setupNFS(flags *flag.FlagSet) func(vol *sharedVolume) (shareServer, error) {
+	readOnly := flags.Bool("read-only", false, "recusa as alterações dos clientes")
+	return func(vol *sharedVolume) (shareServer, error) {
+		now := time.Now()
+		s := &nfsServer{
+			vol:        vol,
+			readOnly:   *readOnly,
+			generation: uint64(now.UnixNano()),
+			paths:      make(map[uint64]string),
+			ids:        make(map[string]uint64),
+			writers:    make(map[uint64]*nfsWriter),
+			exclusive:  make(map[uint64][8]byte),
+			conns:      make(map[net.Conn]bool),
+			done:       make(chan struct{}),
+		}
+		binary.BigEndian.PutUint64(s.verifier[:], s.generation)
+		s.id("/")
+		return s, nil
+	}
+}
+
+// Serve aceita conexões até Close. Em segundo plano, grava o conteúdo de arquivos que ficaram sem COMMIT.
+func (s *nfsServer) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+	go s.flushIdle()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		go func() {
+			s.handleConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close encerra o servidor e as conexões abertas e grava o conteúdo ainda não confirmado.
+func (s *nfsServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	for id := range s.writers {
+		s.commit(id)
+	}
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func (s *nfsServer) flushIdle() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for id, w := range s.writers {
+				if now.Sub(w.last) >= nfsIdleFlush {
+					s.commit(id)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// handleConn lê as chamadas de uma conexão, no formato de registros do RPC sobre TCP.
+func (s *nfsServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+	logger.Info("nfs: conexão", "remote", conn.RemoteAddr().String())
+	in := bufio.NewReader(conn)
+	out := bufio.NewWriter(conn)
+	for {
+		record, err := readRPCRecord(in)
+		if err != nil {
+			if err != io.EOF {
+				logger.Info("nfs: conexão encerrada", "remote", conn.RemoteAddr().String(), "error", err)
+			}
+			return
+		}
+		reply := s.handle(record)
+		if reply == nil {
+			continue
+		}
+		header := binary.BigEndian.AppendUint32(nil, uint32(len(reply))|0x80000000)
+		if _, err := out.Write(append(header, reply...)); err != nil {
+			return
+		}
+		if in.Buffered() == 0 {
+			if err := out.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readRPCRecord lê um registro, juntando seus fragmentos.
+func readRPCRecord(r io.Reader) ([]byte, error) {
+	var record []byte
+	for {
+		var header uint32
+		if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+			return nil, err
+		}
+		size := int(header & 0x7fffffff)
+		if len(record)+size > nfsMaxRecord {
+			return nil, errGarbageArgs
+		}
+		fragment := make([]byte, size)
+		if _, err := io.ReadFull(r, fragment); err != nil {
+			return nil, err
+		}
+		record = append(record, fragment...)
+		if header&0x80000000 != 0 {
+			return record, nil
+		}
+	}
+}
+
+// handle atende uma chamada e retorna a resposta, ou nil se a mensagem não for uma chamada.
+func (s *nfsServer) handle(record []byte) []byte {
+	d := &xdrDecoder{data: record}
+	call := nfsCall{xid: d.uint32()}
+	if d.uint32() != rpcCall || d.err != nil {
+		return nil
+	}
+	rpcVersion := d.uint32()
+	call.program, call.version, call.procedure = d.uint32(), d.uint32(), d.uint32()
+	flavor, credentials := d.uint32(), d.opaque()
+	d.uint32()
+	d.opaque()
+	if d.err != nil {
+		return nil
+	}
+	reply := xdrEncoder{}.uint32(call.xid).uint32(rpcReply)
+	if rpcVersion != 2 {
+		return reply.uint32(rpcMsgDenied).uint32(rpcMismatch).uint32(2).uint32(2)
+	}
+	if flavor == rpcAuthUnix {
+		c := &xdrDecoder{data: credentials}
+		c.uint32()
+		c.string()
+		call.uid, call.gid = c.uint32(), c.uint32()
+	}
+	call.args = d
+
+	reply = reply.uint32(rpcMsgAccepted).uint32(rpcAuthNone).uint32(0)
+	if call.program != nfsProgram && call.program != mountProgram {
+		return reply.uint32(rpcProgUnavail)
+	}
+	if call.version != nfsVersion {
+		return reply.uint32(rpcProgMismatch).uint32(nfsVersion).uint32(nfsVersion)
+	}
+
+	s.mu.Lock()
+	var result xdrEncoder
+	var ok bool
+	if call.program == mountProgram {
+		result, ok = s.mount(&call)
+	} else {
+		result, ok = s.nfs(&call)
+	}
+	s.mu.Unlock()
+	switch {
+	case !ok:
+		return reply.uint32(rpcProcUnavail)
+	case d.err != nil:
+		return reply.uint32(rpcGarbageArgs)
+	}
+	return append(reply.uint32(rpcSuccess), result...)
+}
+
+// mount atende o programa MOUNT: qualquer diretório do volume pode ser montado.
+func (s *nfsServer) mount(call *nfsCall) (xdrEncoder, bool) {
+	d := call.args
+	switch call.procedure {
+	case mountProcNull, mountProcUmnt, mountProcUmntAll:
+		if call.procedure == mountProcUmnt {
+			d.string()
+		}
+		return xdrEncoder{}, true
+	case mountProcMnt:
+		p := normalizePath(d.string())
+		info, err := s.vol.Stat(p)
+		switch {
+		case d.err != nil:
+			return xdrEncoder{}, true
+		case errors.Is(err, iofs.ErrNotExist):
+			return xdrEncoder{}.uint32(mountErrNoEnt), true
+		case err != nil:
+			return xdrEncoder{}.uint32(mountErrServFail), true
+		case !info.dir:
+			return xdrEncoder{}.uint32(mountErrNotDir), true
+		}
+		logger.Info("nfs: montagem", "path", p)
+		return xdrEncoder{}.uint32(mountOK).opaque(s.handleFor(p)).uint32(1).uint32(rpcAuthUnix), true
+	case mountProcDump:
+		return xdrEncoder{}.bool(false), true
+	case mountProcExport:
+		return xdrEncoder{}.bool(true).string("/").bool(false).bool(false), true
+	}
+	return nil, false
+}
+
+// id retorna o número que identifica o caminho p, criando um na primeira vez.
+func (s *nfsServer) id(p string) uint64 {
+	if id, ok := s.ids[p]; ok {
+		return id
+	}
+	s.next++
+	s.ids[p], s.paths[s.next] = s.next, p
+	return s.next
+}
+
+func (s *nfsServer) handleFor(p string) []byte {
+	fh := binary.BigEndian.AppendUint64(nil, s.generation)
+	return binary.BigEndian.AppendUint64(fh, s.id(p))
+}
+
+// resolve retorna o caminho e a descrição da entrada identificada por fh.
+func (s *nfsServer) resolve(fh []byte) (string, entryInfo, uint32) {
+	if len(fh) != nfsHandleSize {
+		return "", entryInfo{}, nfsErrBadHandle
+	}
+	p, ok := s.paths[binary.BigEndian.Uint64(fh[8:])]
+	if !ok || binary.BigEndian.Uint64(fh) != s.generation {
+		return "", entryInfo{}, nfsErrStale
+	}
+	info, err := s.stat(p)
+	if errors.Is(err, iofs.ErrNotExist) {
+		return "", entryInfo{}, nfsErrStale
+	}
+	if err != nil {
+		return "", entryInfo{}, nfsStatus(err)
+	}
+	return p, info, nfsOK
+}
+
+// stat descreve p, considerando o conteúdo escrito e ainda não gravado.
+func (s *nfsServer) stat(p string) (entryInfo, error) {
+	if w, ok := s.writers[s.ids[p]]; ok {
+		info, err := w.file.Stat()
+		if err != nil {
+			return entryInfo{}, err
+		}
+		return info.(entryInfo), nil
+	}
+	return s.vol.Stat(p)
+}
+
+// renamed atualiza os identificadores depois que oldPath foi movido para newPath, inclusive os do conteúdo
+// de um diretório. Os identificadores que existiam em newPath deixam de valer.
+func (s *nfsServer) renamed(oldPath, newPath string) {
+	s.forget(newPath)
+	for p, id := range s.ids {
+		if p == oldPath || strings.HasPrefix(p, oldPath+"/") {
+			moved := newPath + p[len(oldPath):]
+			delete(s.ids, p)
+			s.ids[moved], s.paths[id] = id, moved
+		}
+	}
+}
+
+// forget invalida os identificadores de p e de seu conteúdo.
+func (s *nfsServer) forget(p string) {
+	for q, id := range s.ids {
+		if q == p || strings.HasPrefix(q, p+"/") {
+			delete(s.ids, q)
+			delete(s.paths, id)
+			delete(s.exclusive, id)
+			if w, ok := s.writers[id]; ok {
+				w.file.dirty = false
+				delete(s.writers, id)
+			}
+		}
+	}
+}
+
+// writer retorna o arquivo aberto para escrita de p, abrindo-o na primeira escrita.
+func (s *nfsServer) writer(p string) (*nfsWriter, error) {
+	id := s.id(p)
+	if w, ok := s.writers[id]; ok {
+		w.last = time.Now()
+		return w, nil
+	}
+	file, err := s.vol.OpenFile(p, os.O_RDWR)
+	if err != nil {
+		return nil, err
+	}
+	w := &nfsWriter{file: file, last: time.Now()}
+	s.writers[id] = w
+	return w, nil
+}
+
+// commit grava o conteúdo escrito no arquivo id.
+func (s *nfsServer) commit(id uint64) error {
+	w, ok := s.writers[id]
+	if !ok {
+		return nil
+	}
+	delete(s.writers, id)
+	err := w.file.Close()
+	if err != nil {
+		logger.Warn("nfs: erro ao gravar", "path", w.file.path, "error", err)
+	}
+	return err
+}
+
+// nfsStatus converte err no nfsstat3 correspondente.
+func nfsStatus(err error) uint32 {
+	switch {
+	case err == nil:
+		return nfsOK
+	case errors.Is(err, iofs.ErrNotExist), errors.Is(err, ErrNotFound):
+		return nfsErrNoEnt
+	case errors.Is(err, errNotEmpty):
+		// ENOTEMPTY também corresponde a fs.ErrExist; por isso é testado antes
+		return nfsErrNotEmpty
+	case errors.Is(err, iofs.ErrExist):
+		return nfsErrExist
+	case errors.Is(err, errIsDirectory):
+		return nfsErrIsDir
+	case errors.Is(err, errNotDir):
+		return nfsErrNotDir
+	case errors.Is(err, iofs.ErrPermission), errors.Is(err, ErrProtected):
+		return nfsErrAcces
+	case errors.Is(err, ErrNoSpace):
+		return nfsErrNoSpc
+	case errors.Is(err, ErrNameTooLong), errors.Is(err, ErrPathTooLong):
+		return nfsErrNameTooLong
+	case errors.Is(err, ErrUsage):
+		return nfsErrInval
+	}
+	logger.Warn("nfs: erro de E/S", "error", err)
+	return nfsErrIO
+}
+
+// attr acrescenta o fattr3 de p.
+func (e xdrEncoder) attr(call *nfsCall, id uint64, info entryInfo) xdrEncoder {
+	kind, nlink := uint32(nfsTypeReg), uint32(1)
+	if info.dir {
+		kind, nlink = nfsTypeDir, 2
+	}
+	mtime := info.modTime
+	e = e.uint32(kind).uint32(uint32(info.Mode().Perm())).uint32(nlink).uint32(call.uid).uint32(call.gid)
+	e = e.uint64(uint64(info.size)).uint64(uint64(info.size)).uint32(0).uint32(0).uint64(nfsFSID).uint64(id)
+	for range 3 {
+		e = e.uint32(uint32(mtime.Unix())).uint32(uint32(mtime.Nanosecond()))
+	}
+	return e
+}
+
+// postOpAttr acrescenta o post_op_attr de p, vazio se p não puder ser descrito.
+func (s *nfsServer) postOpAttr(e xdrEncoder, call *nfsCall, p string) xdrEncoder {
+	info, err := s.stat(p)
+	if p == "" || err != nil {
+		return e.bool(false)
+	}
+	return e.bool(true).attr(call, s.id(p), info)
+}
+
+// wcc acrescenta o wcc_data de p; os atributos anteriores à operação não são informados.
+func (s *nfsServer) wcc(e xdrEncoder, call *nfsCall, p string) xdrEncoder {
+	return s.postOpAttr(e.bool(false), call, p)
+}
+
+// child valida o nome name, recebido do cliente, e retorna o caminho dele no diretório dir.
+func (s *nfsServer) child(dir, name string) (string, uint32) {
+	switch {
+	case name == "" || strings.Contains(name, "/"):
+		return "", nfsErrInval
+	case name == ".":
+		return dir, nfsOK
+	case name == "..":
+		parent, _ := splitPath(dir)
+		return parent, nfsOK
+	}
+	return joinPath(dir, name), nfsOK
+}
+
+// nfsAttrs são os atributos de SETATTR, CREATE e MKDIR; apenas o tamanho e o modo são usados.
+type nfsAttrs struct {
+	setMode bool
+	mode    uint32
+	setSize bool
+	size    uint64
+}
+
+func (d *xdrDecoder) sattr() nfsAttrs {
+	var a nfsAttrs
+	if a.setMode = d.bool(); a.setMode {
+		a.mode = d.uint32()
+	}
+	if d.bool() {
+		d.uint32()
+	}
+	if d.bool() {
+		d.uint32()
+	}
+	if a.setSize = d.bool(); a.setSize {
+		a.size = d.uint64()
+	}
+	for range 2 {
+		if d.uint32() == 2 {
+			d.uint64()
+		}
+	}
+	return a
+}
+
+// setattr aplica os atributos a p: o tamanho trunca o arquivo e retirar a escrita o protege.
+func (s *nfsServer) setattr(p string, info entryInfo, a nfsAttrs) error {
+	if a.setSize {
+		if info.dir {
+			return errIsDirectory
+		}
+		if w, ok := s.writers[s.id(p)]; ok {
+			if err := w.file.Truncate(int64(a.size)); err != nil {
+				return err
+			}
+		} else if err := s.vol.Truncate(p, int64(a.size)); err != nil {
+			return err
+		}
+	}
+	if a.setMode && !info.dir {
+		return s.vol.SetProtected(p, a.mode&0200 == 0)
+	}
+	return nil
+}
+
+// nfs atende o programa NFS.
+func (s *nfsServer) nfs(call *nfsCall) (xdrEncoder, bool) {
+	d := call.args
+	e := xdrEncoder{}
+	if call.procedure == nfsProcNull {
+		return e, true
+	}
+	if call.procedure > nfsProcCommit {
+		return nil, false
+	}
+	p, info, status := s.resolve(d.opaque())
+	if d.err != nil {
+		return e, true
+	}
+	if status != nfsOK {
+		return nfsFailure(e.uint32(status), call.procedure), true
+	}
+	id := s.id(p)
+
+	switch call.procedure {
+	case nfsProcGetattr:
+		return e.uint32(nfsOK).attr(call, id, info), true
+	case nfsProcLookup:
+		child, status := s.child(p, d.string())
+		if status == nfsOK && !info.dir {
+			status = nfsErrNotDir
+		}
+		var childInfo entryInfo
+		if status == nfsOK {
+			childInfo, status = s.statStatus(child)
+		}
+		if status != nfsOK {
+			return s.postOpAttr(e.uint32(status), call, p), true
+		}
+		e = e.uint32(nfsOK).opaque(s.handleFor(child)).bool(true).attr(call, s.id(child), childInfo)
+		return s.postOpAttr(e, call, p), true
+	case nfsProcAccess:
+		requested := d.uint32()
+		granted := uint32(nfsAccessRead | nfsAccessLookup | nfsAccessModify | nfsAccessExtend | nfsAccessDelete)
+		if info.dir {
+			granted |= nfsAccessExecute
+		}
+		if s.readOnly || info.protected {
+			granted &^= nfsAccessModify | nfsAccessExtend | nfsAccessDelete
+		}
+		return e.uint32(nfsOK).bool(true).attr(call, id, info).uint32(requested & granted), true
+	case nfsProcRead:
+		offset, count := d.uint64(), d.uint32()
+		if info.dir {
+			return s.postOpAttr(e.uint32(nfsErrIsDir), call, p), true
+		}
+		buf := make([]byte, min(count, nfsMaxTransfer))
+		var n int
+		var err error
+		if w, ok := s.writers[id]; ok {
+			n, err = w.file.ReadAt(buf, int64(offset))
+		} else {
+			n, err = s.vol.ReadAt(p, buf, int64(offset))
+		}
+		if err != nil && err != io.EOF {
+			return s.postOpAttr(e.uint32(nfsStatus(err)), call, p), true
+		}
+		eof := offset+uint64(n) >= uint64(info.size)
+		return e.uint32(nfsOK).bool(true).attr(call, id, info).uint32(uint32(n)).bool(eof).opaque(buf[:n]), true
+	case nfsProcReaddir, nfsProcReaddirplus:
+		return s.readdir(call, p, info), true
+	case nfsProcFsstat:
+		var free jsonFreeSpace
+		s.vol.with(func(fs *FURGFileSystem) error {
+			free = fs.freeSpaceInfo()
+			return nil
+		})
+		files := uint64(free.TotalEntries - free.UsedEntries)
+		e = e.uint32(nfsOK).bool(true).attr(call, id, info)
+		return e.uint64(free.TotalBytes).uint64(free.FreeBytes).uint64(free.FreeBytes).
+			uint64(uint64(free.TotalEntries)).uint64(files).uint64(files).uint32(0), true
+	case nfsProcFsinfo:
+		const properties = 0x0008 // FSF3_HOMOGENEOUS
+		e = e.uint32(nfsOK).bool(true).attr(call, id, info)
+		e = e.uint32(nfsMaxTransfer).uint32(nfsMaxTransfer).uint32(4096)
+		e = e.uint32(nfsMaxTransfer).uint32(nfsMaxTransfer).uint32(4096).uint32(nfsMaxTransfer)
+		return e.uint64(uint64(^uint32(0))).uint32(1).uint32(0).uint32(properties), true
+	case nfsProcPathconf:
+		var nameMax int
+		s.vol.with(func(fs *FURGFileSystem) error {
+			nameMax = min(fs.Rules.MaxNameLength, 32)
+			return nil
+		})
+		e = e.uint32(nfsOK).bool(true).attr(call, id, info)
+		return e.uint32(1).uint32(uint32(nameMax)).bool(true).bool(true).bool(false).bool(true), true
+	case nfsProcCommit:
+		err := s.commit(id)
+		e = s.wcc(e.uint32(nfsStatus(err)), call, p)
+		if err != nil {
+			return e, true
+		}
+		return append(e, s.verifier[:]...), true
+	case nfsProcReadlink, nfsProcSymlink, nfsProcMknod, nfsProcLink:
+		return nfsFailure(e.uint32(nfsErrNotSupp), call.procedure), true
+	}
+
+	// Os procedimentos seguintes alteram o volume
+	if s.readOnly {
+		return nfsFailure(e.uint32(nfsErrROFS), call.procedure), true
+	}
+	switch call.procedure {
+	case nfsProcSetattr:
+		attrs := d.sattr()
+		if d.bool() {
+			d.uint64()
+		}
+		return s.wcc(e.uint32(nfsStatus(s.setattr(p, info, attrs))), call, p), true
+	case nfsProcWrite:
+		offset, _, stable, data := d.uint64(), d.uint32(), d.uint32(), d.opaque()
+		if d.err != nil {
+			return e, true
+		}
+		if info.dir {
+			return s.wcc(e.uint32(nfsErrIsDir), call, p), true
+		}
+		w, err := s.writer(p)
+		if err == nil {
+			_, err = w.file.WriteAt(data, int64(offset))
+		}
+		committed := uint32(nfsUnstable)
+		if err == nil && stable != nfsUnstable {
+			err, committed = s.commit(id), nfsFileSync
+		}
+		if err != nil {
+			return s.wcc(e.uint32(nfsStatus(err)), call, p), true
+		}
+		e = s.wcc(e.uint32(nfsOK), call, p).uint32(uint32(len(data))).uint32(committed)
+		return append(e, s.verifier[:]...), true
+	case nfsProcCreate, nfsProcMkdir:
+		return s.create(call, p, info), true
+	case nfsProcRemove, nfsProcRmdir:
+		child, status := s.child(p, d.string())
+		var childInfo entryInfo
+		if status == nfsOK {
+			childInfo, status = s.statStatus(child)
+		}
+		switch {
+		case status != nfsOK:
+		case child == p || child == "/":
+			status = nfsErrInval
+		case call.procedure == nfsProcRemove && childInfo.dir:
+			status = nfsErrIsDir
+		case call.procedure == nfsProcRmdir && !childInfo.dir:
+			status = nfsErrNotDir
+		default:
+			if status = nfsStatus(s.vol.Remove(child)); status == nfsOK {
+				s.forget(child)
+			}
+		}
+		return s.wcc(e.uint32(status), call, p), true
+	case nfsProcRename:
+		name := d.string()
+		toDir, toInfo, status := s.resolve(d.opaque())
+		toName := d.string()
+		if d.err != nil {
+			return e, true
+		}
+		from, to := "", ""
+		if status == nfsOK && !toInfo.dir {
+			status = nfsErrNotDir
+		}
+		if status == nfsOK {
+			from, status = s.child(p, name)
+		}
+		if status == nfsOK {
+			to, status = s.child(toDir, toName)
+		}
+		if status == nfsOK {
+			// O conteúdo ainda não gravado vai junto com o arquivo
+			status = nfsStatus(s.commit(s.ids[from]))
+		}
+		if status == nfsOK {
+			if status = nfsStatus(s.vol.Rename(from, to)); status == nfsOK && from != to {
+				s.renamed(from, to)
+			}
+		}
+		return s.wcc(s.wcc(e.uint32(status), call, p), call, toDir), true
+	}
+	return nil, false
+}
+
+// nfsFailure completa a resposta de erro e, já com o código, com os atributos vazios que cada procedimento
+// inclui em caso de falha.
+func nfsFailure(e xdrEncoder, procedure uint32) xdrEncoder {
+	switch procedure {
+	case nfsProcGetattr:
+		return e
+	case nfsProcSetattr, nfsProcWrite, nfsProcCreate, nfsProcMkdir, nfsProcSymlink, nfsProcMknod,
+		nfsProcRemove, nfsProcRmdir, nfsProcCommit:
+		return e.bool(false).bool(false)
+	case nfsProcLink:
+		return e.bool(false).bool(false).bool(false)
+	case nfsProcRename:
+		return e.bool(false).bool(false).bool(false).bool(false)
+	}
+	return e.bool(false)
+}
+
+// statStatus descreve p, com o erro já convertido em nfsstat3.
+func (s *nfsServer) statStatus(p string) (entryInfo, uint32) {
+	info, err := s.stat(p)
+	return info, nfsStatus(err)
+}
+
+// create atende CREATE e MKDIR no diretório dir.
+func (s *nfsServer) create(call *nfsCall, dir string, info entryInfo) xdrEncoder {
+	d := call.args
+	name := d.string()
+	var attrs nfsAttrs
+	var mode uint32
+	var verifier [8]byte
+	if call.procedure == nfsProcCreate {
+		if mode = d.uint32(); mode == nfsCreateExclusive {
+			copy(verifier[:], d.take(8))
+		} else {
+			attrs = d.sattr()
+		}
+	} else {
+		attrs = d.sattr()
+	}
+	e := xdrEncoder{}
+	if d.err != nil {
+		return e
+	}
+	p, status := s.child(dir, name)
+	if status == nfsOK && !info.dir {
+		status = nfsErrNotDir
+	}
+	if status == nfsOK && (name == "." || name == "..") {
+		status = nfsErrExist
+	}
+	if status != nfsOK {
+		return s.wcc(e.uint32(status), call, dir)
+	}
+
+	existing, err := s.stat(p)
+	switch {
+	case call.procedure == nfsProcMkdir:
+		err = s.vol.Mkdir(p)
+	case err == nil && mode == nfsCreateExclusive:
+		// Uma repetição do mesmo CREATE exclusivo não é um erro
+		if existing.dir || s.exclusive[s.id(p)] != verifier {
+			err = iofs.ErrExist
+		}
+	case err == nil && mode == nfsCreateGuarded:
+		err = iofs.ErrExist
+	case err == nil:
+		err = s.setattr(p, existing, attrs)
+	case errors.Is(err, iofs.ErrNotExist):
+		if err = s.vol.WriteFile(p, strings.NewReader("")); err == nil && mode == nfsCreateExclusive {
+			s.exclusive[s.id(p)] = verifier
+		}
+	}
+	if err == nil && call.procedure == nfsProcCreate && mode != nfsCreateExclusive && attrs.setMode {
+		err = s.vol.SetProtected(p, attrs.mode&0200 == 0)
+	}
+	if err != nil {
+		return s.wcc(e.uint32(nfsStatus(err)), call, dir)
+	}
+	e = s.postOpAttr(e.uint32(nfsOK).bool(true).opaque(s.handleFor(p)), call, p)
+	return s.wcc(e, call, dir)
+}
+
+// readdir atende READDIR e READDIRPLUS. O cookie de cada entrada é a sua posição na listagem, em ordem de
+// nome, mais um.
+func (s *nfsServer) readdir(call *nfsCall, p string, info entryInfo) xdrEncoder {
+	d := call.args
+	plus := call.procedure == nfsProcReaddirplus
+	cookie := d.uint64()
+	d.take(8)
+	limit := d.uint32()
+	if plus {
+		limit = d.uint32()
+	}
+	e := xdrEncoder{}
+	if d.err != nil {
+		return e
+	}
+	if !info.dir {
+		return s.postOpAttr(e.uint32(nfsErrNotDir), call, p)
+	}
+	infos, err := s.vol.ReadDir(p)
+	if err != nil {
+		return s.postOpAttr(e.uint32(nfsStatus(err)), call, p)
+	}
+	if cookie > uint64(len(infos)) {
+		cookie = uint64(len(infos))
+	}
+
+	e = e.uint32(nfsOK).bool(true).attr(call, s.id(p), info).uint64(0)
+	header := len(e)
+	eof := true
+	for i := cookie; i < uint64(len(infos)); i++ {
+		child := joinPath(p, infos[i].name)
+		entry := xdrEncoder{}.bool(true).uint64(s.id(child)).string(infos[i].name).uint64(i + 1)
+		if plus {
+			entry = entry.bool(true).attr(call, s.id(child), infos[i]).bool(true).opaque(s.handleFor(child))
+		}
+		// Reserva espaço para o fim da lista e a indicação de fim
+		if len(e)+len(entry)+8 > int(limit) {
+			if len(e) == header {
+				return s.postOpAttr(xdrEncoder{}.uint32(nfsErrTooSmall), call, p)
+			}
+			eof = false
+			break
+		}
+		e = append(e, entry...)
+	}
+	return e.bool(false).bool(eof)
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// O servidor SMB autentica os usuários com NTLMv2 (MS-NLMP), dentro de SPNEGO (RFC 4178). O NTLMv2 exige o
+// hash NT da senha, que não pode ser obtido do hash PBKDF2; por isso ele só é guardado para os usuários
+// cadastrados com --smb.
+const (
+	ntlmNegotiate    = 1
+	ntlmChallenge    = 2
+	ntlmAuthenticate = 3
+
+	ntlmNegotiateUnicode     = 0x00000001
+	ntlmRequestTarget        = 0x00000004
+	ntlmNegotiateSign        = 0x00000010
+	ntlmNegotiateNTLM        = 0x00000200
+	ntlmNegotiateAlwaysSign  = 0x00008000
+	ntlmTargetTypeServer     = 0x00020000
+	ntlmNegotiateExtended    = 0x00080000
+	ntlmNegotiateTargetInfo  = 0x00800000
+	ntlmNegotiateVersion     = 0x02000000
+	ntlmNegotiate128         = 0x20000000
+	ntlmNegotiateKeyExchange = 0x40000000
+	ntlmNegotiate56          = 0x80000000
+
+	ntlmServerFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateSign | ntlmNegotiateNTLM |
+		ntlmNegotiateAlwaysSign | ntlmNegotiateExtended | ntlmNegotiateTargetInfo | ntlmNegotiateVersion |
+		ntlmNegotiate128 | ntlmNegotiateKeyExchange | ntlmNegotiate56
+
+	ntlmDomain = "FURGFS"
+)
+
+// Identificadores dos pares AV de TargetInfo.
+const (
+	ntlmAvEOL             = 0
+	ntlmAvNbComputerName  = 1
+	ntlmAvNbDomainName    = 2
+	ntlmAvDnsComputerName = 3
+	ntlmAvFlags           = 6
+	ntlmAvTimestamp       = 7
+)
+
+var (
+	ntlmSignature = []byte("NTLMSSP\x00")
+	ntlmOID       = []byte{0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x02, 0x02, 0x0a} // 1.3.6.1.4.1.311.2.2.10
+	spnegoOID     = []byte{0x2b, 0x06, 0x01, 0x05, 0x05, 0x02}                         // 1.3.6.1.5.5.2
+
+	errLogonFailure = errors.New("falha na autenticação")
+)
+
+// ntHash calcula o hash NT de password: MD4 da senha em UTF-16LE.
+func ntHash(password string) []byte {
+	h := md4.New()
+	h.Write(utf16LE(password))
+	return h.Sum(nil)
+}
+
+func utf16LE(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+func fromUTF16LE(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// filetime converte t para o FILETIME do Windows: intervalos de 100 ns desde 1601.
+func filetime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100 + 116444736000000000)
+}
+
+// ntlmServer conduz a autenticação de uma sessão.
+type ntlmServer struct {
+	users map[string]VolumeUser
+
+	negotiate []byte
+	challenge []byte
+	mechTypes []byte // lista de mecanismos do cliente, coberta pelo mechListMIC
+
+	user       string // usuário informado em AUTHENTICATE, mesmo que recusado
+	sessionKey []byte
+}
+
+// accept processa um token SPNEGO (ou uma mensagem NTLM sem SPNEGO) e retorna o token da resposta. done
+// indica que a autenticação terminou; err, que ela falhou.
+func (n *ntlmServer) accept(token []byte) (reply []byte, done bool, err error) {
+	message, mechTypes, err := spnegoMechToken(token)
+	if err != nil {
+		return nil, false, err
+	}
+	if mechTypes != nil {
+		n.mechTypes = mechTypes
+	}
+	if len(message) < 12 || !bytes.Equal(message[:8], ntlmSignature) {
+		return nil, false, errLogonFailure
+	}
+	switch binary.LittleEndian.Uint32(message[8:]) {
+	case ntlmNegotiate:
+		if n.challenge != nil {
+			return nil, false, errLogonFailure
+		}
+		n.negotiate = bytes.Clone(message)
+		if n.challenge, err = n.newChallenge(message); err != nil {
+			return nil, false, err
+		}
+		return spnegoResponse(1, n.challenge, nil), false, nil
+	case ntlmAuthenticate:
+		if n.challenge == nil {
+			return nil, false, errLogonFailure
+		}
+		if err := n.authenticate(bytes.Clone(message)); err != nil {
+			return nil, false, err
+		}
+		var mic []byte
+		if n.mechTypes != nil {
+			mic = n.sign(n.mechTypes)
+		}
+		return spnegoResponse(0, nil, mic), true, nil
+	}
+	return nil, false, errLogonFailure
+}
+
+// newChallenge monta a mensagem CHALLENGE em resposta a NEGOTIATE.
+func (n *ntlmServer) newChallenge(negotiate []byte) ([]byte, error) {
+	if len(negotiate) < 16 {
+		return nil, errLogonFailure
+	}
+	flags := binary.LittleEndian.Uint32(negotiate[12:])&ntlmServerFlags | ntlmTargetTypeServer | ntlmNegotiateTargetInfo
+	computer, _ := os.Hostname()
+	if i := strings.IndexByte(computer, '.'); i > 0 {
+		computer = computer[:i]
+	}
+	computer = strings.ToUpper(computer[:min(len(computer), 15)])
+	if computer == "" {
+		computer = ntlmDomain
+	}
+
+	target := utf16LE(ntlmDomain)
+	var info []byte
+	pair := func(id uint16, value []byte) {
+		info = binary.LittleEndian.AppendUint16(info, id)
+		info = binary.LittleEndian.AppendUint16(info, uint16(len(value)))
+		info = append(info, value...)
+	}
+	pair(ntlmAvNbDomainName, target)
+	pair(ntlmAvNbComputerName, utf16LE(computer))
+	pair(ntlmAvDnsComputerName, utf16LE(strings.ToLower(computer)))
+	pair(ntlmAvTimestamp, binary.LittleEndian.AppendUint64(nil, filetime(time.Now())))
+	pair(ntlmAvEOL, nil)
+
+	const header = 56
+	msg := make([]byte, header, header+len(target)+len(info))
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], ntlmChallenge)
+	putNTLMField(msg[12:], len(target), header)
+	binary.LittleEndian.PutUint32(msg[20:], flags)
+	if _, err := rand.Read(msg[24:32]); err != nil {
+		return nil, err
+	}
+	putNTLMField(msg[40:], len(info), header+len(target))
+	copy(msg[48:], []byte{10, 0, 0x61, 0x4a, 0, 0, 0, 15}) // versão: Windows 10, revisão NTLM 15
+	return append(append(msg, target...), info...), nil
+}
+
+func putNTLMField(b []byte, length, offset int) {
+	binary.LittleEndian.PutUint16(b, uint16(length))
+	binary.LittleEndian.PutUint16(b[2:], uint16(length))
+	binary.LittleEndian.PutUint32(b[4:], uint32(offset))
+}
+
+// ntlmField retorna o conteúdo do campo de tamanho e posição em msg[at:].
+func ntlmField(msg []byte, at int) ([]byte, error) {
+	if len(msg) < at+8 {
+		return nil, errLogonFailure
+	}
+	length := int(binary.LittleEndian.Uint16(msg[at:]))
+	offset := int(binary.LittleEndian.Uint32(msg[at+4:]))
+	if offset+length > len(msg) || offset < 0 {
+		return nil, errLogonFailure
+	}
+	return msg[offset : offset+length], nil
+}
+
+// authenticate confere a resposta NTLMv2 da mensagem AUTHENTICATE e deriva a chave da sessão.
+func (n *ntlmServer) authenticate(msg []byte) error {
+	if len(msg) < 64 {
+		return errLogonFailure
+	}
+	response, err := ntlmField(msg, 20)
+	if err != nil {
+		return err
+	}
+	domain, err := ntlmField(msg, 28)
+	if err != nil {
+		return err
+	}
+	userName, err := ntlmField(msg, 36)
+	if err != nil {
+		return err
+	}
+	encryptedKey, err := ntlmField(msg, 52)
+	if err != nil {
+		return err
+	}
+	flags := binary.LittleEndian.Uint32(msg[60:])
+
+	// Apenas NTLMv2: a resposta tem 16 bytes de prova seguidos do desafio do cliente
+	name := strings.ToLower(fromUTF16LE(userName))
+	n.user = name
+	user, ok := n.users[name]
+	if !ok || len(user.NTHash) != 16 || len(response) < 16+28 {
+		return errLogonFailure
+	}
+	mac := hmac.New(md5.New, user.NTHash)
+	mac.Write(utf16LE(strings.ToUpper(fromUTF16LE(userName))))
+	mac.Write(domain)
+	ntowf := mac.Sum(nil)
+
+	mac = hmac.New(md5.New, ntowf)
+	mac.Write(n.challenge[24:32])
+	mac.Write(response[16:])
+	proof := mac.Sum(nil)
+	if !hmac.Equal(proof, response[:16]) {
+		return errLogonFailure
+	}
+	mac = hmac.New(md5.New, ntowf)
+	mac.Write(proof)
+	baseKey := mac.Sum(nil)
+
+	n.sessionKey = baseKey
+	if flags&ntlmNegotiateKeyExchange != 0 && len(encryptedKey) == 16 {
+		cipher, err := rc4.NewCipher(baseKey)
+		if err != nil {
+			return err
+		}
+		n.sessionKey = make([]byte, 16)
+		cipher.XORKeyStream(n.sessionKey, encryptedKey)
+	}
+
+	// Com o par MsvAvFlags indicando MIC, a mensagem traz o MIC das três mensagens
+	if avFlags, ok := ntlmAvPair(response[16+28:], ntlmAvFlags); ok && len(avFlags) == 4 &&
+		binary.LittleEndian.Uint32(avFlags)&0x2 != 0 && len(msg) >= 88 {
+		mic := bytes.Clone(msg[72:88])
+		clear(msg[72:88])
+		mac = hmac.New(md5.New, n.sessionKey)
+		mac.Write(n.negotiate)
+		mac.Write(n.challenge)
+		mac.Write(msg)
+		if !hmac.Equal(mic, mac.Sum(nil)) {
+			return errLogonFailure
+		}
+	}
+	return nil
+}
+
+// ntlmAvPair procura o par id na lista de pares AV.
+func ntlmAvPair(pairs []byte, id uint16) ([]byte, bool) {
+	for len(pairs) >= 4 {
+		pairID := binary.LittleEndian.Uint16(pairs)
+		length := int(binary.LittleEndian.Uint16(pairs[2:]))
+		if pairID == ntlmAvEOL || len(pairs) < 4+length {
+			break
+		}
+		if pairID == id {
+			return pairs[4 : 4+length], true
+		}
+		pairs = pairs[4+length:]
+	}
+	return nil, false
+}
+
+// sign calcula a assinatura NTLM do servidor para message, com o número de sequência 0; é usada no
+// mechListMIC do SPNEGO.
+func (n *ntlmServer) sign(message []byte) []byte {
+	key := func(magic string) []byte {
+		sum := md5.Sum(append(bytes.Clone(n.sessionKey), magic...))
+		return sum[:]
+	}
+	mac := hmac.New(md5.New, key("session key to server-to-client signing key magic constant\x00"))
+	mac.Write([]byte{0, 0, 0, 0})
+	mac.Write(message)
+	checksum := mac.Sum(nil)[:8]
+	cipher, err := rc4.NewCipher(key("session key to server-to-client sealing key magic constant\x00"))
+	if err == nil {
+		cipher.XORKeyStream(checksum, checksum)
+	}
+	return append(append([]byte{1, 0, 0, 0}, checksum...), 0, 0, 0, 0)
+}
+
+// derElement lê um elemento DER de b e retorna sua etiqueta, seu conteúdo e o restante de b.
+func derElement(b []byte) (tag byte, content, rest []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, nil, errLogonFailure
+	}
+	tag, length, b := b[0], int(b[1]), b[2:]
+	if length&0x80 != 0 {
+		size := length & 0x7f
+		if size == 0 || size > 3 || len(b) < size {
+			return 0, nil, nil, errLogonFailure
+		}
+		length = 0
+		for _, c := range b[:size] {
+			length = length<<8 | int(c)
+		}
+		b = b[size:]
+	}
+	if len(b) < length {
+		return 0, nil, nil, errLogonFailure
+	}
+	return tag, b[:length], b[length:], nil
+}
+
+// derFields lê os campos [n] de uma SEQUENCE DER.
+func derFields(sequence []byte) (map[byte][]byte, error) {
+	tag, content, _, err := derElement(sequence)
+	if err != nil || tag != 0x30 {
+		return nil, errLogonFailure
+	}
+	fields := make(map[byte][]byte)
+	for len(content) > 0 {
+		var field []byte
+		if tag, field, content, err = derElement(content); err != nil {
+			return nil, err
+		}
+		fields[tag&0x1f] = field
+	}
+	return fields, nil
+}
+
+// spnegoMechToken extrai a mensagem NTLM de um token SPNEGO NegTokenInit ou NegTokenResp. Para
+// NegTokenInit, retorna também a lista de mecanismos, em DER.
+func spnegoMechToken(token []byte) (message, mechTypes []byte, err error) {
+	if bytes.HasPrefix(token, ntlmSignature) {
+		return token, nil, nil
+	}
+	tag, content, _, err := derElement(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fields map[byte][]byte
+	switch tag {
+	case 0x60: // [APPLICATION 0]: OID do SPNEGO seguido de [0] NegTokenInit
+		_, _, rest, err := derElement(content)
+		if err != nil {
+			return nil, nil, err
+		}
+		_, init, _, err := derElement(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		if fields, err = derFields(init); err != nil {
+			return nil, nil, err
+		}
+		mechTypes = fields[0]
+		if !bytes.Contains(mechTypes, ntlmOID) {
+			return nil, nil, errLogonFailure
+		}
+	case 0xa1: // [1] NegTokenResp
+		if fields, err = derFields(content); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, errLogonFailure
+	}
+	tag, message, _, err = derElement(fields[2])
+	if err != nil || tag != 0x04 {
+		return nil, nil, errLogonFailure
+	}
+	return message, mechTypes, nil
+}
+
+// der monta um elemento DER.
+func der(tag byte, parts ...[]byte) []byte {
+	content := bytes.Join(parts, nil)
+	n := len(content)
+	out := []byte{tag}
+	switch {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n < 0x100:
+		out = append(out, 0x81, byte(n))
+	case n < 0x10000:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x83, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, content...)
+}
+
+// spnegoResponse monta um NegTokenResp: state 1 é accept-incomplete e 0, accept-completed.
+func spnegoResponse(state byte, token, mic []byte) []byte {
+	fields := [][]byte{der(0xa0, der(0x0a, []byte{state}))}
+	if token != nil {
+		fields = append(fields, der(0xa1, der(0x06, ntlmOID)), der(0xa2, der(0x04, token)))
+	}
+	if mic != nil {
+		fields = append(fields, der(0xa3, der(0x04, mic)))
+	}
+	return der(0xa1, der(0x30, fields...))
+}
+
+// spnegoHint é o NegTokenInit2 enviado em NEGOTIATE, que anuncia o NTLM como único mecanismo.
+func spnegoHint() []byte {
+	hint := der(0xa3, der(0x30, der(0xa0, der(0x1b, []byte("not_defined_in_RFC4178@please_ignore")))))
+	init := der(0x30, der(0xa0, der(0x30, der(0x06, ntlmOID))), hint)
+	return der(0x60, der(0x06, spnegoOID), der(0xa0, init))
+}
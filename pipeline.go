@@ -0,0 +1,377 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// As cópias para dentro e para fora do volume trabalham em etapas simultâneas. Na importação, uma goroutine
+// lê o arquivo de origem adiantado (prefetchReader) enquanto storeFile aloca os blocos, e um grupo de
+// pipelineWorkers cifra e grava os blocos já alocados (blockWriter). Na exportação, o mesmo número de
+// goroutines lê e decifra os trechos contíguos seguintes da cadeia com ReadAt (veja extent.go) enquanto os
+// anteriores são escritos no destino, na ordem do arquivo. Até pipelineDepth pedaços da origem e
+// pipelineExtents trechos da imagem ficam em trânsito em cada etapa; os armazenamentos da imagem aceitam
+// ReadAt e WriteAt simultâneos.
+// Arquivos com menos de pipelineMinBlocks blocos, cujo custo seria dominado pelas goroutines, são copiados
+// pelo laço simples.
+const (
+	pipelineWorkers   = 4
+	pipelineDepth     = 32
+	pipelineExtents   = 8
+	pipelineMinBlocks = 8
+)
+
+// prefetchReader lê r adiantado, em pedaços de size bytes, numa goroutine própria.
+type prefetchReader struct {
+	chunks  chan prefetchChunk
+	done    chan struct{}
+	chunk   []byte // pedaço atual, devolvido a bufferPools ao chegar o seguinte
+	pending []byte
+	err     error
+}
+
+type prefetchChunk struct {
+	data []byte
+	err  error
+}
+
+// newPrefetchReader começa a ler r adiantado. Close encerra a leitura antes do fim de r e espera a goroutine
+// terminar, para que r possa voltar a ser usado por quem o passou.
+func newPrefetchReader(r io.Reader, size int) *prefetchReader {
+	p := &prefetchReader{chunks: make(chan prefetchChunk, pipelineDepth), done: make(chan struct{})}
+	go func() {
+		defer close(p.chunks)
+		for {
+			select {
+			case <-p.done:
+				return
+			default:
+			}
+			buf := getBuffer(size)
+			n, err := io.ReadFull(r, buf)
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			select {
+			case p.chunks <- prefetchChunk{buf[:n], err}:
+			case <-p.done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *prefetchReader) Read(b []byte) (int, error) {
+	for len(p.pending) == 0 {
+		if p.err != nil {
+			return 0, p.err
+		}
+		chunk, ok := <-p.chunks
+		if !ok {
+			return 0, io.EOF
+		}
+		if p.chunk != nil {
+			putBuffer(p.chunk)
+		}
+		p.chunk, p.pending, p.err = chunk.data, chunk.data, chunk.err
+	}
+	n := copy(b, p.pending)
+	p.pending = p.pending[n:]
+	return n, nil
+}
+
+func (p *prefetchReader) Close() error {
+	close(p.done)
+	for chunk := range p.chunks {
+		putBuffer(chunk.data)
+	}
+	return nil
+}
+
+// blockWriter cifra, se preciso, e grava blocos de dados em segundo plano. Blocos consecutivos são juntados
+// num trecho de até extentMaxBytes (veja extent.go), agendado quando a sequência se interrompe ou o trecho
+// enche. Com o cache de escritas ligado, as goroutines só guardam os blocos do trecho nele, um trecho de
+// cada vez, e é write, na goroutine de quem grava o arquivo, que leva o cache à imagem quando ele enche; sem
+// o cache, cada trecho vai direto à imagem com um só WriteAt.
+type blockWriter struct {
+	fs     *FURGFileSystem
+	cipher *xtsCipher
+	jobs   chan blockWrite
+	wg     sync.WaitGroup
+	limit  int        // tamanho máximo de um trecho
+	extent blockWrite // trecho em montagem, ainda não agendado
+
+	mu  sync.Mutex // serializa o cache de escritas e protege err
+	err error
+}
+
+// blockWrite é um trecho a gravar a partir do bloco blockID; só o último bloco pode estar incompleto.
+type blockWrite struct {
+	blockID uint32
+	data    []byte
+}
+
+func (fs *FURGFileSystem) newBlockWriter(c *xtsCipher) *blockWriter {
+	fs.writeCache() // ligado, se for o caso, antes que as goroutines o consultem
+	limit := max(extentMaxBytes/fs.Header.BlockSize, 1) * fs.Header.BlockSize
+	w := &blockWriter{fs: fs, cipher: c, jobs: make(chan blockWrite, pipelineExtents), limit: int(limit)}
+	for range pipelineWorkers {
+		w.wg.Add(1)
+		go w.work()
+	}
+	return w
+}
+
+// write agenda a gravação de uma cópia de data no bloco blockID. Um bloco cifrado é gravado por inteiro,
+// completado com zeros. Retorna o erro de uma gravação anterior, se houver.
+func (w *blockWriter) write(blockID uint32, data []byte) error {
+	if err := w.failed(); err != nil {
+		return err
+	}
+	if c := w.fs.wcache; c != nil {
+		w.mu.Lock()
+		var err error
+		if c.due() {
+			err = w.fs.flushBlocks()
+		}
+		w.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	blockSize := int(w.fs.Header.BlockSize)
+	e := &w.extent
+	if e.data != nil {
+		n := len(e.data)
+		if n%blockSize != 0 || blockID != e.blockID+uint32(n/blockSize) || n+blockSize > w.limit {
+			w.schedule()
+		}
+	}
+	if e.data == nil {
+		*e = blockWrite{blockID, getBuffer(w.limit)[:0]}
+	}
+	n := len(e.data)
+	e.data = append(e.data, data...)
+	if w.cipher != nil {
+		e.data = e.data[:n+blockSize]
+		clear(e.data[n+len(data):])
+	}
+	return nil
+}
+
+// schedule agenda a gravação do trecho em montagem.
+func (w *blockWriter) schedule() {
+	if w.extent.data != nil {
+		w.jobs <- w.extent
+		w.extent = blockWrite{}
+	}
+}
+
+func (w *blockWriter) work() {
+	defer w.wg.Done()
+	for job := range w.jobs {
+		if w.failed() == nil {
+			w.store(job)
+		}
+		putBuffer(job.data)
+	}
+}
+
+func (w *blockWriter) store(job blockWrite) {
+	fs := w.fs
+	blockSize := int(fs.Header.BlockSize)
+	if w.cipher != nil {
+		for i := 0; i < len(job.data); i += blockSize {
+			w.cipher.encryptBlock(job.data[i:i+blockSize], job.blockID+uint32(i/blockSize))
+		}
+	}
+	var err error
+	if c := fs.wcache; c != nil {
+		w.mu.Lock()
+		for i := 0; i < len(job.data); i += blockSize {
+			c.put(job.blockID+uint32(i/blockSize), job.data[i:min(i+blockSize, len(job.data))], fs.Header.BlockSize)
+		}
+		w.mu.Unlock()
+	} else {
+		if _, err = fs.FilePointer.WriteAt(job.data, fs.blockOffset(job.blockID)); err != nil {
+			err = fmt.Errorf("Erro ao escrever dados no arquivo: %v", err)
+		}
+	}
+	if err != nil {
+		w.mu.Lock()
+		if w.err == nil {
+			w.err = err
+		}
+		w.mu.Unlock()
+	}
+}
+
+func (w *blockWriter) failed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// wait espera as gravações agendadas e retorna o primeiro erro.
+func (w *blockWriter) wait() error {
+	w.schedule()
+	close(w.jobs)
+	w.wg.Wait()
+	return w.err
+}
+
+// copyBlocksOut escreve em w os size primeiros bytes dos blocos, lendo e decifrando com c, se não for nil,
+// os trechos contíguos seguintes da cadeia em paralelo com a escrita dos anteriores.
+func (fs *FURGFileSystem) copyBlocksOut(blocks []uint32, size uint32, c *xtsCipher, w io.Writer) error {
+	type result struct {
+		buf []byte
+		err error
+	}
+	type job struct {
+		run blockRun
+		out chan result
+	}
+	jobs := make(chan job, pipelineExtents)
+	order := make(chan chan result, pipelineExtents)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	// Ao sair, inclusive em caso de erro, para o produtor e só então espera as leituras em andamento: o
+	// produtor fecha jobs ao ver done, e os workers terminam
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	for range pipelineWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				buf := fs.extentBuffer(j.run)
+				n, err := fs.FilePointer.ReadAt(buf, fs.blockOffset(j.run.Start))
+				j.out <- result{buf, fs.finishExtent(j.run, buf, n, err, c)}
+			}
+		}()
+	}
+	go func() {
+		defer close(order)
+		defer close(jobs)
+		for _, run := range fs.fileExtents(blocks) {
+			out := make(chan result, 1)
+			select {
+			case order <- out:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- job{run, out}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	remaining := int(size)
+	for out := range order {
+		res := <-out
+		if res.err != nil {
+			return res.err
+		}
+		n := min(len(res.buf), remaining)
+		if _, err := w.Write(res.buf[:n]); err != nil {
+			return fmt.Errorf("erro ao escrever dados no arquivo destino: %v", err)
+		}
+		putBuffer(res.buf)
+		remaining -= n
+		if remaining == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// Um fileReader lê adiantado até readAheadExtents trechos seguintes da cadeia, somando até readAheadBytes
+// com o trecho atual. Um arquivo de um trecho só é lido na hora.
+const (
+	readAheadExtents = 8
+	readAheadBytes   = 4 * extentMaxBytes
+)
+
+// aheadBlock é o resultado da leitura adiantada de um trecho.
+type aheadBlock struct {
+	buf []byte
+	n   int
+	err error
+}
+
+// readAhead lê em segundo plano o trecho da imagem em offset para buf. A goroutine só usa o armazenamento da
+// imagem, que aceita leituras simultâneas às escritas; o cache de escritas e a decifragem ficam com quem
+// recebe o resultado, que pode abandoná-lo sem deixar a goroutine presa.
+func readAhead(store imageStore, offset int64, buf []byte) <-chan aheadBlock {
+	out := make(chan aheadBlock, 1)
+	go func() {
+		n, err := store.ReadAt(buf, offset)
+		out <- aheadBlock{buf, n, err}
+	}()
+	return out
+}
+
+// nextExtent lê para r.buf o primeiro trecho de r.extents, agendando a leitura adiantada dos seguintes.
+func (r *fileReader) nextExtent() error {
+	fs := r.fs
+	if r.buf != nil {
+		r.spare = append(r.spare, r.buf)
+		r.buf = nil
+	}
+	run := r.extents[0]
+	if len(r.extents) < 2 && len(r.ahead) == 0 {
+		r.buf = r.buffer(run)
+		r.extents = r.extents[1:]
+		return fs.readExtent(run, r.buf, r.cipher)
+	}
+	inFlight := 0
+	for _, scheduled := range r.extents[:len(r.ahead)] {
+		inFlight += fs.extentBytes(scheduled)
+	}
+	for i := len(r.ahead); i < len(r.extents) && (i == 0 || i <= readAheadExtents && inFlight < readAheadBytes); i++ {
+		next := r.extents[i]
+		r.ahead = append(r.ahead, readAhead(fs.FilePointer, fs.blockOffset(next.Start), r.buffer(next)))
+		inFlight += fs.extentBytes(next)
+	}
+	res := <-r.ahead[0]
+	r.ahead, r.extents = r.ahead[1:], r.extents[1:]
+	r.buf = res.buf
+	return fs.finishExtent(run, r.buf, res.n, res.err, r.cipher)
+}
+
+// buffer retorna um buffer do tamanho do trecho run, reaproveitando um dos já usados que caiba.
+func (r *fileReader) buffer(run blockRun) []byte {
+	size := r.fs.extentBytes(run)
+	for i, buf := range r.spare {
+		if cap(buf) >= size {
+			r.spare = slices.Delete(r.spare, i, i+1)
+			return buf[:size]
+		}
+	}
+	return r.fs.extentBuffer(run)
+}
+
+// release devolve a bufferPools os buffers do leitor, que chegou ao fim do arquivo.
+func (r *fileReader) release() {
+	if len(r.ahead) > 0 {
+		// Leituras adiantadas ainda podem escrever nos buffers
+		return
+	}
+	for _, buf := range append(r.spare, r.buf) {
+		if buf != nil {
+			putBuffer(buf)
+		}
+	}
+	r.buf, r.spare = nil, nil
+}
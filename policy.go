@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotConfirmed indica que o usuário recusou uma ação destrutiva.
+var ErrNotConfirmed = errors.New("operação cancelada")
+
+// ConfirmPolicy decide se ações destrutivas podem prosseguir. O menu interativo pergunta ao usuário,
+// enquanto a linha de comando exige --force (e --override-protection para arquivos protegidos).
+type ConfirmPolicy interface {
+	// Confirm autoriza uma ação destrutiva descrita por action.
+	Confirm(action string) error
+	// ConfirmProtected autoriza ignorar a proteção do arquivo target.
+	ConfirmProtected(target string) error
+}
+
+// promptPolicy pergunta ao usuário pelo console. A remoção de um arquivo protegido exige digitar o caminho completo.
+type promptPolicy struct{}
+
+func (promptPolicy) Confirm(action string) error {
+	var answer string
+	console.Prompt(action+tr(" Confirmar? (s/n): "), &answer)
+	if answer = strings.ToLower(answer); answer != "s" && answer != "sim" && answer != "y" && answer != "yes" {
+		return ErrNotConfirmed
+	}
+	return nil
+}
+
+func (promptPolicy) ConfirmProtected(target string) error {
+	var answer string
+	fmt.Printf(tr("O arquivo '%s' está protegido contra escrita/remoção.\n"), target)
+	console.Prompt(tr("Para ignorar a proteção, digite o caminho completo do arquivo: "), &answer)
+	if answer != target {
+		return ErrNotConfirmed
+	}
+	return nil
+}
+
+// flagPolicy autoriza as ações de acordo com as opções da linha de comando.
+type flagPolicy struct {
+	Force              bool // --force: confirma ações destrutivas
+	OverrideProtection bool // --override-protection: permite alterar arquivos protegidos
+}
+
+func (p flagPolicy) Confirm(action string) error {
+	if !p.Force {
+		return classErrorf(ErrNotConfirmed, "erro: %s Use --force para confirmar", action)
+	}
+	return nil
+}
+
+func (p flagPolicy) ConfirmProtected(target string) error {
+	if !p.Force || !p.OverrideProtection {
+		return classErrorf(ErrProtected, "erro: o arquivo '%s' está protegido; use --force --override-protection para ignorar a proteção", target)
+	}
+	return nil
+}
+
+// extractPolicyFlags remove --force e --override-protection de args, em qualquer posição, e retorna a política correspondente.
+func extractPolicyFlags(args []string) ([]string, flagPolicy) {
+	var policy flagPolicy
+	var rest []string
+	for _, arg := range args {
+		switch arg {
+		case "--force":
+			policy.Force = true
+		case "--override-protection":
+			policy.OverrideProtection = true
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, policy
+}
+
+// confirm consulta a política de confirmação; sem política definida, a ação é permitida.
+func (fs *FURGFileSystem) confirm(format string, args ...any) error {
+	if fs.Policy == nil {
+		return nil
+	}
+	return fs.Policy.Confirm(fmt.Sprintf(tr(format), args...))
+}
+
+// confirmProtected consulta a política antes de alterar um arquivo protegido; sem política definida, a proteção é mantida.
+func (fs *FURGFileSystem) confirmProtected(target string) error {
+	if fs.Policy == nil {
+		return classErrorf(ErrProtected, "erro: Arquivo protegido, troque sua proteção para poder remover")
+	}
+	return fs.Policy.ConfirmProtected(target)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// Quando o tamanho do conteúdo é conhecido de antemão (um arquivo do sistema real, um buffer em memória ou
+// um trecho limitado deles), storeFile procura uma sequência contígua de blocos livres que caiba o arquivo
+// inteiro e a usa no lugar da alocação bloco a bloco: o arquivo fica em um só trecho, que depois é lido
+// sequencialmente. A sequência não é reservada na FAT; ela só orienta a alocação, que volta a ser a de
+// allocateBlock se o conteúdo passar do tamanho previsto, e sobra livre se ele for menor.
+//
+// Quando o tamanho não é conhecido (a entrada padrão, um pipe ou o corpo de um envio pela rede) e o cache de
+// escritas está ligado, a alocação é adiada: os blocos lidos ficam em memória, como ficariam no cache, e
+// só são reservados quando somam o limite do cache ou quando o conteúdo termina. Nesse momento a
+// quantidade é conhecida, e os blocos acumulados recebem uma sequência contígua da mesma forma, mesmo que
+// o conteúdo tenha chegado em escritas pequenas ou que o espaço livre esteja fragmentado.
+
+// readerSize retorna quantos bytes restam em r, quando isso pode ser sabido sem lê-lo.
+func readerSize(r io.Reader) (int64, bool) {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len()), true
+	case *io.LimitedReader:
+		size, ok := readerSize(r.R)
+		return min(size, r.N), ok
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return 0, false
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		return max(info.Size()-offset, 0), true
+	}
+	return 0, false
+}
+
+// findFreeRun procura count blocos livres consecutivos a partir de onde allocateBlock pararia, dando a
+// volta na FAT. Como 0 em NextBlockID encerra uma cadeia, o bloco 0 só entra na sequência se ela abrir uma
+// cadeia nova (chainStart); para continuar uma cadeia, ele é tratado como ocupado.
+func (fs *FURGFileSystem) findFreeRun(count uint32, chainStart bool) (blockRun, bool) {
+	n := uint32(len(fs.FAT))
+	if count < 2 || count > n || uint64(count)*uint64(fs.Header.BlockSize) > uint64(fs.Header.FreeSpace) {
+		return blockRun{}, false
+	}
+	start := fs.nextFree
+	if start >= n {
+		start = 0
+	}
+	// Duas passadas: do ponto de partida ao fim da FAT e, depois, do início até ele
+	for _, bounds := range [][2]uint32{{start, n}, {0, min(start+count-1, n)}} {
+		length := uint32(0)
+		for i := bounds[0]; i < bounds[1]; i++ {
+			if fs.fatEntry(i).Used || i == 0 && !chainStart {
+				length = 0
+				continue
+			}
+			length++
+			if length == count {
+				return blockRun{Start: i + 1 - count, Length: count}, true
+			}
+		}
+	}
+	return blockRun{}, false
+}
+
+// allocateFromRun reserva o próximo bloco de run, consumindo-o, se ainda estiver livre, ou um bloco de
+// allocateBlock. Como em findFreeRun, o bloco 0 só é usado se abrir uma cadeia nova (chainStart).
+func (fs *FURGFileSystem) allocateFromRun(run *blockRun, chainStart bool) (uint32, error) {
+	if run.Length > 0 && fs.fatErr == nil && !fs.fatEntry(run.Start).Used && (run.Start != 0 || chainStart) {
+		blockID := run.Start
+		run.Start++
+		run.Length--
+		*fs.modifyFAT(blockID) = FATEntry{BlockID: blockID, Used: true}
+		fs.Header.FreeSpace -= fs.Header.BlockSize
+		fs.nextFree = blockID + 1
+		return blockID, nil
+	}
+	run.Length = 0
+	return fs.allocateBlock()
+}
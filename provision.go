@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// "apply" provisiona um volume a partir de um manifesto YAML ou JSON com os diretórios, os arquivos e os
+// atributos desejados, como a infraestrutura como código: cria a imagem, se ela não existir, e leva o
+// volume ao estado descrito, alterando só o que difere. Aplicar o mesmo manifesto de novo não altera nada.
+// A geometria do volume (size, block-size, entries e cas) só é usada ao criar a imagem.
+//
+//	volume:
+//	  size: 64M
+//	  label: site
+//	directories:
+//	  - /logs
+//	  - path: /dados
+//	    quota: 10M
+//	files:
+//	  - path: /www
+//	    source: ./site
+//	  - path: /etc/motd
+//	    content: "bem-vindo\n"
+//	    protected: true
+
+// volumeManifest é o conteúdo de um manifesto.
+type volumeManifest struct {
+	Volume struct {
+		Size      string  `yaml:"size"`
+		BlockSize string  `yaml:"block-size"`
+		Entries   uint32  `yaml:"entries"`
+		Label     *string `yaml:"label"`
+		CAS       bool    `yaml:"cas"`
+	} `yaml:"volume"`
+	Directories []manifestDirectory `yaml:"directories"`
+	Files       []manifestFile      `yaml:"files"`
+}
+
+// manifestDirectory é um diretório do manifesto; na forma curta, só o caminho. A cota só é alterada quando
+// declarada.
+type manifestDirectory struct {
+	Path       string `yaml:"path"`
+	Quota      string `yaml:"quota"`       // limite de bytes, como em quota set
+	QuotaFiles int    `yaml:"quota-files"` // limite de arquivos
+}
+
+func (d *manifestDirectory) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&d.Path)
+	}
+	type plain manifestDirectory
+	return node.Decode((*plain)(d))
+}
+
+// manifestFile é um arquivo do manifesto, com o conteúdo de source ou de content. source é um arquivo ou
+// um diretório do sistema real, relativo ao manifesto; um diretório é sincronizado como em sync.
+type manifestFile struct {
+	Path      string  `yaml:"path"`
+	Source    string  `yaml:"source"`
+	Content   *string `yaml:"content"`
+	Protected bool    `yaml:"protected"`
+}
+
+// readManifest lê e valida o manifesto name. JSON é lido como YAML, do qual é um subconjunto.
+func readManifest(name string) (*volumeManifest, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "erro ao ler o manifesto: %v", err)
+	}
+	var m volumeManifest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, classErrorf(ErrUsage, "erro: manifesto '%s' inválido: %v", name, err)
+	}
+
+	valid := func(p string) (string, error) {
+		if !strings.HasPrefix(p, "/") {
+			return "", classErrorf(ErrUsage, "erro: o caminho '%s' do manifesto não é absoluto", p)
+		}
+		p = normalizePath(p)
+		if p == systemDir || strings.HasPrefix(p, systemDir+"/") {
+			return "", classErrorf(ErrUsage, "erro: o diretório de sistema %s não pode ser provisionado", systemDir)
+		}
+		return p, nil
+	}
+	for i := range m.Directories {
+		if m.Directories[i].Path, err = valid(m.Directories[i].Path); err != nil {
+			return nil, err
+		}
+	}
+	for i := range m.Files {
+		f := &m.Files[i]
+		if f.Path, err = valid(f.Path); err != nil {
+			return nil, err
+		}
+		if f.Path == "/" || (f.Source == "") == (f.Content == nil) {
+			return nil, classErrorf(ErrUsage, "erro: o arquivo '%s' do manifesto precisa de source ou de content", f.Path)
+		}
+		if f.Source != "" && !filepath.IsAbs(f.Source) {
+			f.Source = filepath.Join(filepath.Dir(name), f.Source)
+		}
+	}
+	return &m, nil
+}
+
+// ApplyResult resume a aplicação de um manifesto.
+type ApplyResult struct {
+	Created, Updated, Removed, Unchanged, Protected int
+}
+
+// ApplyManifest leva o volume ao estado de m e chama report para cada alteração, com os símbolos de Sync
+// e "*" para um atributo alterado (rótulo, cota ou proteção). Com prune, as entradas que o manifesto não
+// declara são apagadas.
+func (fs *FURGFileSystem) ApplyManifest(m *volumeManifest, prune, dryRun bool, report func(symbol, p string)) (ApplyResult, error) {
+	var result ApplyResult
+	count := func(symbol, p string) {
+		switch symbol {
+		case "+":
+			result.Created++
+		case "~", "*":
+			result.Updated++
+		case "-":
+			result.Removed++
+		case "!":
+			result.Protected++
+		}
+		report(symbol, p)
+	}
+
+	// managed são as entradas declaradas e os seus diretórios pais; tudo abaixo de trees é sincronizado
+	managed := map[string]bool{"/": true}
+	var trees []string
+	declare := func(p string) {
+		for ; p != "/"; p, _ = splitPath(p) {
+			managed[p] = true
+		}
+	}
+	planned := make(map[string]bool) // diretórios que a simulação criaria
+	ensureDir := func(p string) error {
+		var parts []string
+		for ; p != "/"; p, _ = splitPath(p) {
+			parts = append(parts, p)
+		}
+		for i := len(parts) - 1; i >= 0; i-- {
+			dir, name := splitPath(parts[i])
+			index := fs.lookupEntry(name, dir)
+			switch {
+			case index != -1 && fs.RootDir[index].IsDirectory || planned[parts[i]]:
+				continue
+			case index != -1:
+				return classErrorf(ErrUsage, "erro: '%s' é um arquivo no volume e um diretório no manifesto", parts[i])
+			}
+			count("+", parts[i]+"/")
+			if dryRun {
+				planned[parts[i]] = true
+			} else if err := fs.CreateDirectory(name, dir, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if label := m.Volume.Label; label != nil {
+		info, err := fs.VolumeInfo()
+		if err != nil {
+			return result, err
+		}
+		if info.Label != *label {
+			count("*", fmt.Sprintf(tr("rótulo '%s'"), *label))
+			if !dryRun {
+				if _, err := fs.TuneVolume(label, ""); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
+
+	quotas, err := fs.Quotas()
+	if err != nil {
+		return result, err
+	}
+	for _, d := range m.Directories {
+		declare(d.Path)
+		if err := ensureDir(d.Path); err != nil {
+			return result, err
+		}
+		if d.Quota == "" && d.QuotaFiles == 0 {
+			continue
+		}
+		quota := Quota{Files: d.QuotaFiles}
+		if d.Quota != "" && d.Quota != "none" {
+			size, err := parseSize(d.Quota)
+			if err != nil {
+				return result, err
+			}
+			quota.Bytes = uint64(size)
+		}
+		if quotas[d.Path] == quota {
+			continue
+		}
+		count("*", fmt.Sprintf(tr("cota de '%s'"), d.Path))
+		if !dryRun {
+			if err := fs.SetQuota(d.Path, quota); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	for _, f := range m.Files {
+		declare(f.Path)
+		parent, _ := splitPath(f.Path)
+		if err := ensureDir(parent); err != nil {
+			return result, err
+		}
+		if f.Source != "" {
+			info, err := os.Stat(f.Source)
+			if err != nil {
+				return result, classErrorf(ErrNotFound, "erro: '%s' não existe no sistema real", f.Source)
+			}
+			if info.IsDir() {
+				trees = append(trees, f.Path)
+				if err := ensureDir(f.Path); err != nil {
+					return result, err
+				}
+				// Sync não regrava arquivos protegidos: com --override-protection, a proteção da árvore é
+				// retirada antes e restaurada depois
+				protected := make(map[string]bool)
+				for _, i := range fs.entriesInDirectory(f.Path, true) {
+					if entry := &fs.RootDir[i]; entry.Protected && !dryRun && fs.confirmProtected(f.Path) == nil {
+						protected[entry.FullPath()] = true
+						fs.setProtected(i, false)
+					}
+				}
+				created := make(map[string]bool)
+				synced, err := fs.Sync(f.Source, f.Path, SyncOptions{Delete: prune, DryRun: dryRun}, func(symbol, rel string) {
+					created[joinPath(f.Path, rel)] = symbol == "+"
+					count(symbol, joinPath(f.Path, rel))
+				})
+				if err != nil {
+					return result, err
+				}
+				result.Unchanged += synced.Unchanged
+				for _, i := range fs.entriesInDirectory(f.Path, true) {
+					if p := fs.RootDir[i].FullPath(); created[p] {
+						fs.setProtected(i, f.Protected)
+						continue
+					} else if protected[p] {
+						fs.setProtected(i, true)
+					}
+					fs.applyProtection(i, f.Protected, dryRun, count)
+				}
+				continue
+			}
+		}
+		if err := fs.applyFile(f, dryRun, &result, count); err != nil {
+			return result, err
+		}
+	}
+
+	if prune {
+		var extra []string
+		for i := range fs.RootDir {
+			entry := &fs.RootDir[i]
+			p := entry.FullPath()
+			if entry.Name[0] == 0 || managed[p] || p == systemDir || strings.HasPrefix(p, systemDir+"/") {
+				continue
+			}
+			inTree := false
+			for _, tree := range trees {
+				inTree = inTree || strings.HasPrefix(p, tree+"/")
+			}
+			if !inTree {
+				extra = append(extra, p)
+			}
+		}
+		// Em ordem decrescente, o conteúdo de um diretório é apagado antes dele
+		sort.Sort(sort.Reverse(sort.StringSlice(extra)))
+		for _, p := range extra {
+			if dryRun {
+				count("-", p)
+				continue
+			}
+			if err := fs.removeSyncEntry(p); errors.Is(err, ErrProtected) {
+				count("!", p)
+			} else if err != nil {
+				return result, err
+			} else {
+				count("-", p)
+			}
+		}
+	}
+	return result, nil
+}
+
+// applyFile grava o arquivo f do manifesto, se o conteúdo do volume for diferente, e ajusta a proteção.
+func (fs *FURGFileSystem) applyFile(f manifestFile, dryRun bool, result *ApplyResult, count func(symbol, p string)) error {
+	var open func() (io.ReadCloser, error)
+	var size int64
+	var hash string
+	if f.Content != nil {
+		sum := sha256.Sum256([]byte(*f.Content))
+		size, hash = int64(len(*f.Content)), hex.EncodeToString(sum[:])
+		open = func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(*f.Content)), nil }
+	} else {
+		info, err := os.Stat(f.Source)
+		if err != nil {
+			return classErrorf(ErrNotFound, "erro: '%s' não existe no sistema real", f.Source)
+		}
+		if hash, err = hostFileHash(f.Source); err != nil {
+			return err
+		}
+		size = info.Size()
+		open = func() (io.ReadCloser, error) { return os.Open(f.Source) }
+	}
+
+	dir, name := splitPath(f.Path)
+	index := fs.lookupEntry(name, dir)
+	if index != -1 && fs.RootDir[index].IsDirectory {
+		return classErrorf(ErrUsage, "erro: '%s' é um diretório no volume e um arquivo no manifesto", f.Path)
+	}
+	if index != -1 && int64(fs.RootDir[index].Size) == size {
+		current, err := fs.imageFileHash(f.Path)
+		if err != nil {
+			return err
+		}
+		if current == hash {
+			result.Unchanged++
+			fs.applyProtection(index, f.Protected, dryRun, count)
+			return nil
+		}
+	}
+
+	if index != -1 && fs.RootDir[index].Protected {
+		if err := fs.confirmProtected(f.Path); err != nil {
+			count("!", f.Path)
+			return nil
+		}
+	}
+	symbol := "+"
+	if index != -1 {
+		symbol = "~"
+	}
+	count(symbol, f.Path)
+	if dryRun {
+		return nil
+	}
+	if index != -1 {
+		fs.setProtected(index, false)
+	}
+	r, err := open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := fs.storeSyncFile(f.Path, r); err != nil {
+		return err
+	}
+	fs.setProtected(fs.lookupEntry(name, dir), f.Protected)
+	return nil
+}
+
+// applyProtection dá à entrada index a proteção do manifesto, se ela for um arquivo.
+func (fs *FURGFileSystem) applyProtection(index int, protected, dryRun bool, count func(symbol, p string)) {
+	entry := &fs.RootDir[index]
+	if entry.IsDirectory || entry.Protected == protected {
+		return
+	}
+	count("*", entry.FullPath())
+	if !dryRun {
+		fs.setProtected(index, protected)
+	}
+}
+
+// cliApply implementa "apply [--prune] [-n] <manifesto>": cria a imagem, se preciso, e aplica o manifesto.
+func cliApply(imageName string, args []string) (err error) {
+	args, policy := extractPolicyFlags(args)
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	prune := flags.Bool("prune", false, "apaga as entradas que o manifesto não declara (exige --force)")
+	dryRun := flags.Bool("n", false, "mostra o que seria feito, sem alterar nada")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 1 {
+		return classErrorf(ErrUsage, "uso: furgfs apply [--prune] [-n] <manifesto>")
+	}
+	m, err := readManifest(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	report := func(symbol, p string) { fmt.Printf("%s %s\n", symbol, p) }
+
+	if _, statErr := os.Stat(imageName); errors.Is(statErr, os.ErrNotExist) && !isRemoteImage(imageName) {
+		if *dryRun {
+			fmt.Printf(tr("A imagem '%s' seria criada com o conteúdo do manifesto.\n"), imageName)
+			return nil
+		}
+		// Os valores do manifesto vêm depois dos padrões de mkfs e prevalecem
+		mkfs := []string{"--size", "10M", "--block-size", "4096", "--entries", "100"}
+		if m.Volume.Size != "" {
+			mkfs = append(mkfs, "--size", m.Volume.Size)
+		}
+		if m.Volume.BlockSize != "" {
+			mkfs = append(mkfs, "--block-size", m.Volume.BlockSize)
+		}
+		if m.Volume.Entries != 0 {
+			mkfs = append(mkfs, "--entries", fmt.Sprint(m.Volume.Entries))
+		}
+		if m.Volume.Label != nil {
+			mkfs = append(mkfs, "--label", *m.Volume.Label)
+		}
+		if m.Volume.CAS {
+			mkfs = append(mkfs, "--cas")
+		}
+		if err := cliMkfs(imageName, append(mkfs, imageName)); err != nil {
+			return err
+		}
+	}
+
+	fs, err := loadFileSystem(imageName)
+	if err != nil {
+		return err
+	}
+	defer fs.closeImage()
+	fs.Policy = policy
+	saves := fs.saves
+	defer func() { fs.auditCommand("apply", args, saves, err) }()
+	if *prune && !*dryRun {
+		if err := fs.confirm("As entradas que o manifesto não declara serão apagadas."); err != nil {
+			return err
+		}
+	}
+
+	result, err := fs.ApplyManifest(m, *prune, *dryRun, report)
+	if !*dryRun {
+		err = saveImages([]*FURGFileSystem{fs}, err)
+	}
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Printf(tr("Simulação: %d entrada(s) a criar, %d a alterar, %d a remover, %d arquivo(s) sem alteração.\n"), result.Created, result.Updated, result.Removed, result.Unchanged)
+	} else {
+		fmt.Printf(tr("Manifesto aplicado: %d entrada(s) criada(s), %d alterada(s), %d removida(s), %d arquivo(s) sem alteração.\n"), result.Created, result.Updated, result.Removed, result.Unchanged)
+	}
+	if result.Protected > 0 {
+		return classErrorf(ErrProtected, "erro: %d entrada(s) protegida(s) não foram alteradas", result.Protected)
+	}
+	return nil
+}
@@ -0,0 +1,614 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// As cotas limitam o total de bytes e o número de arquivos de um diretório, contando todos os seus
+// subdiretórios, e ficam em /.furgfs/quota.json associadas ao caminho do diretório. Os limites são
+// verificados ao gravar e ao mover arquivos; os arquivos do diretório de sistema não são contados.
+//
+// Um usuário dos servidores de rede também pode ter uma cota, em /.furgfs/user_quota.json, para o que grava
+// pelo volume compartilhado. A tabela do diretório não tem dono, por isso o dono de cada arquivo gravado por
+// um usuário fica em /.furgfs/owners.json, pelo caminho, e acompanha o arquivo quando ele é movido. O uso de
+// um usuário soma os arquivos de que ele é dono e que ainda existem; os gravados pela linha de comando não
+// têm dono.
+const (
+	quotaInfoName     = "quota.json"
+	userQuotaInfoName = "user_quota.json"
+	ownersInfoName    = "owners.json"
+)
+
+// Quota é o limite de um diretório; zero indica sem limite.
+type Quota struct {
+	Bytes uint64 `json:"bytes,omitempty"`
+	Files int    `json:"files,omitempty"`
+}
+
+// dirUsage é o espaço ocupado por um diretório e seus subdiretórios.
+type dirUsage struct {
+	Bytes uint64
+	Files int
+}
+
+// Quotas lê as cotas do volume, indexadas pelo caminho do diretório.
+func (fs *FURGFileSystem) Quotas() (map[string]Quota, error) {
+	quotas := make(map[string]Quota)
+	data, err := fs.readSystemFile(quotaInfoName)
+	if errors.Is(err, os.ErrNotExist) {
+		return quotas, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &quotas); err != nil {
+		return nil, classErrorf(ErrCorrupted, "erro ao ler as cotas: %v", err)
+	}
+	return quotas, nil
+}
+
+// SetQuota define a cota do diretório dir; uma cota sem limites é removida.
+func (fs *FURGFileSystem) SetQuota(dir string, quota Quota) error {
+	dir = fs.resolvePath(dir)
+	if dir != "/" && fs.CheckDirectoryExists(dir) == -1 {
+		return classErrorf(ErrNotFound, "erro: O diretório '%s' não existe", dir)
+	}
+	if dir == systemDir || strings.HasPrefix(dir, systemDir+"/") {
+		return classErrorf(ErrUsage, "erro: o diretório de sistema %s não tem cota", systemDir)
+	}
+	quotas, err := fs.Quotas()
+	if err != nil {
+		return err
+	}
+	if quota == (Quota{}) {
+		delete(quotas, dir)
+	} else {
+		quotas[dir] = quota
+	}
+	return fs.writeQuotas(quotas)
+}
+
+// writeQuotas grava as cotas em /.furgfs/quota.json.
+func (fs *FURGFileSystem) writeQuotas(quotas map[string]Quota) error {
+	data, err := json.MarshalIndent(quotas, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fs.writeSystemFile(quotaInfoName, data)
+}
+
+// usageOf soma os arquivos de dir e de seus subdiretórios, fora do diretório de sistema.
+func (fs *FURGFileSystem) usageOf(dir string) dirUsage {
+	var usage dirUsage
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 || entry.IsDirectory || entry.PathString() == systemDir {
+			continue
+		}
+		if path := entry.PathString(); path == dir || strings.HasPrefix(path+"/", prefix) {
+			usage.Bytes += uint64(entry.Size)
+			usage.Files++
+		}
+	}
+	return usage
+}
+
+// covers informa se a cota do diretório quotaDir vale para as entradas de dir.
+func covers(quotaDir, dir string) bool {
+	return quotaDir == "/" || dir == quotaDir || strings.HasPrefix(dir, quotaDir+"/")
+}
+
+// quotaRoom verifica se files arquivos novos cabem nas cotas que valem para dir e retorna quantos bytes ainda
+// podem ser gravados nele (math.MaxInt64 sem limite) e o diretório da cota mais restrita. As cotas que já
+// valem para from, a origem de uma movimentação, são ignoradas.
+func (fs *FURGFileSystem) quotaRoom(dir, from string, files int) (int64, string, error) {
+	room, tightest := int64(math.MaxInt64), ""
+	if dir == systemDir {
+		return room, tightest, nil
+	}
+	quotas, err := fs.Quotas()
+	if err != nil {
+		return 0, "", err
+	}
+	for quotaDir, quota := range quotas {
+		if !covers(quotaDir, dir) || (from != "" && covers(quotaDir, from)) {
+			continue
+		}
+		usage := fs.usageOf(quotaDir)
+		if quota.Files > 0 && usage.Files+files > quota.Files {
+			return 0, "", classErrorf(ErrNoSpace, "erro: a cota de %d arquivo(s) de '%s' seria excedida", quota.Files, quotaDir)
+		}
+		if quota.Bytes > 0 {
+			left := int64(0)
+			if quota.Bytes > usage.Bytes {
+				left = int64(quota.Bytes - usage.Bytes)
+			}
+			if left < room {
+				room, tightest = left, quotaDir
+			}
+		}
+	}
+	return room, tightest, nil
+}
+
+// storeQuotaFile grava r como a nova entrada entry, como storeFile, desde que o conteúdo caiba nas cotas
+// do diretório de destino. O arquivo não tem dono.
+func (fs *FURGFileSystem) storeQuotaFile(entry FileEntry, r io.Reader) (int, error) {
+	return fs.storeUserFile(entry, r, "")
+}
+
+// storeUserFile grava r como storeQuotaFile, em nome de user, um usuário dos servidores de rede: o conteúdo
+// também precisa caber na cota do usuário, e ele passa a ser o dono do arquivo. Um arquivo já existente no
+// mesmo caminho, que o novo vai substituir, não conta no uso do usuário.
+func (fs *FURGFileSystem) storeUserFile(entry FileEntry, r io.Reader, user string) (int, error) {
+	room, quotaDir, err := fs.quotaRoom(entry.PathString(), "", 1)
+	if err != nil {
+		return -1, err
+	}
+	owners, err := fs.fileOwners()
+	if err != nil {
+		return -1, err
+	}
+	p := entry.FullPath()
+	userRoom, err := fs.userQuotaRoom(user, owners, p)
+	if err != nil {
+		return -1, err
+	}
+	limit := min(room, userRoom)
+	if limit < math.MaxInt64 {
+		// Lê no máximo um byte além do limite, apenas para saber que ele foi ultrapassado
+		r = io.LimitReader(r, limit+1)
+	}
+	index, err := fs.storeFile(entry, r)
+	if err != nil {
+		return -1, err
+	}
+	if size := int64(fs.RootDir[index].Size); size > limit {
+		fs.discardEntries([]int{index})
+		if size > room {
+			return -1, fs.quotaExceeded(quotaDir)
+		}
+		return -1, fs.userQuotaExceeded(user)
+	}
+	if owner, ok := owners[p]; ok && owner == user || !ok && user == "" {
+		return index, nil
+	}
+	if user == "" {
+		delete(owners, p)
+	} else {
+		owners[p] = user
+	}
+	// Os caminhos que não existem mais, apagados depois de gravados, são descartados
+	for p := range owners {
+		if dir, name := splitPath(p); fs.lookupEntry(name, dir) == -1 {
+			delete(owners, p)
+		}
+	}
+	if err := fs.writeFileOwners(owners); err != nil {
+		fs.discardEntries([]int{index})
+		return -1, err
+	}
+	return index, nil
+}
+
+// checkMoveQuota verifica se a entrada source pode ser movida para o diretório dstDir sem exceder as cotas.
+func (fs *FURGFileSystem) checkMoveQuota(source, dstDir string, entry *FileEntry) error {
+	moved := dirUsage{Bytes: uint64(entry.Size), Files: 1}
+	if entry.IsDirectory {
+		moved = fs.usageOf(source)
+	}
+	srcDir, _ := splitPath(source)
+	room, quotaDir, err := fs.quotaRoom(dstDir, srcDir, moved.Files)
+	if err == nil && int64(moved.Bytes) > room {
+		err = fs.quotaExceeded(quotaDir)
+	}
+	return err
+}
+
+// moveQuotas acompanha a movimentação do diretório source para destination, mudando o caminho das cotas de
+// source e de seus subdiretórios.
+func (fs *FURGFileSystem) moveQuotas(source, destination string) error {
+	quotas, err := fs.Quotas()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for dir, quota := range quotas {
+		if dir == source || strings.HasPrefix(dir, source+"/") {
+			delete(quotas, dir)
+			quotas[destination+strings.TrimPrefix(dir, source)] = quota
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return fs.writeQuotas(quotas)
+}
+
+// UserQuotas lê as cotas dos usuários, indexadas pelo nome.
+func (fs *FURGFileSystem) UserQuotas() (map[string]Quota, error) {
+	quotas := make(map[string]Quota)
+	data, err := fs.readSystemFile(userQuotaInfoName)
+	if errors.Is(err, os.ErrNotExist) {
+		return quotas, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &quotas); err != nil {
+		return nil, classErrorf(ErrCorrupted, "erro ao ler as cotas dos usuários: %v", err)
+	}
+	return quotas, nil
+}
+
+// SetUserQuota define a cota do usuário name; uma cota sem limites é removida.
+func (fs *FURGFileSystem) SetUserQuota(name string, quota Quota) error {
+	users, err := fs.Users()
+	if err != nil {
+		return err
+	}
+	quotas, err := fs.UserQuotas()
+	if err != nil {
+		return err
+	}
+	if quota == (Quota{}) {
+		delete(quotas, name)
+	} else if _, ok := users[name]; !ok {
+		return classErrorf(ErrNotFound, "erro: o usuário '%s' não existe", name)
+	} else {
+		quotas[name] = quota
+	}
+	data, err := json.MarshalIndent(quotas, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fs.writeSystemFile(userQuotaInfoName, data)
+}
+
+// fileOwners lê os donos dos arquivos gravados pelos usuários, indexados pelo caminho.
+func (fs *FURGFileSystem) fileOwners() (map[string]string, error) {
+	owners := make(map[string]string)
+	data, err := fs.readSystemFile(ownersInfoName)
+	if errors.Is(err, os.ErrNotExist) {
+		return owners, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &owners); err != nil {
+		return nil, classErrorf(ErrCorrupted, "erro ao ler os donos dos arquivos: %v", err)
+	}
+	return owners, nil
+}
+
+// writeFileOwners grava os donos dos arquivos em /.furgfs/owners.json.
+func (fs *FURGFileSystem) writeFileOwners(owners map[string]string) error {
+	data, err := json.MarshalIndent(owners, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fs.writeSystemFile(ownersInfoName, data)
+}
+
+// moveOwners acompanha a movimentação de source para destination, mudando o caminho dos arquivos de que os
+// usuários são donos. O dono de um arquivo substituído em destination é esquecido.
+func (fs *FURGFileSystem) moveOwners(source, destination string) error {
+	owners, err := fs.fileOwners()
+	if err != nil {
+		return err
+	}
+	_, changed := owners[destination]
+	delete(owners, destination)
+	for p, owner := range owners {
+		if p == source || strings.HasPrefix(p, source+"/") {
+			delete(owners, p)
+			owners[destination+strings.TrimPrefix(p, source)] = owner
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return fs.writeFileOwners(owners)
+}
+
+// userUsage soma os arquivos de que user é dono, fora o do caminho skip.
+func (fs *FURGFileSystem) userUsage(user string, owners map[string]string, skip string) dirUsage {
+	var usage dirUsage
+	for p, owner := range owners {
+		if owner != user || p == skip {
+			continue
+		}
+		dir, name := splitPath(p)
+		if index := fs.lookupEntry(name, dir); index != -1 && !fs.RootDir[index].IsDirectory {
+			usage.Bytes += uint64(fs.RootDir[index].Size)
+			usage.Files++
+		}
+	}
+	return usage
+}
+
+// userQuotaRoom verifica se um arquivo novo em p cabe na cota de user e retorna quantos bytes ele ainda pode
+// gravar (math.MaxInt64 sem limite). O arquivo que já estiver em p vai ser substituído e não conta.
+func (fs *FURGFileSystem) userQuotaRoom(user string, owners map[string]string, p string) (int64, error) {
+	if user == "" {
+		return math.MaxInt64, nil
+	}
+	quotas, err := fs.UserQuotas()
+	if err != nil {
+		return 0, err
+	}
+	quota, ok := quotas[user]
+	if !ok {
+		return math.MaxInt64, nil
+	}
+	usage := fs.userUsage(user, owners, p)
+	if quota.Files > 0 && usage.Files+1 > quota.Files {
+		return 0, classErrorf(ErrNoSpace, "erro: a cota de %d arquivo(s) do usuário '%s' seria excedida", quota.Files, user)
+	}
+	if quota.Bytes == 0 {
+		return math.MaxInt64, nil
+	}
+	return int64(quota.Bytes - min(usage.Bytes, quota.Bytes)), nil
+}
+
+// userQuotaExceeded é o erro de uma gravação que ultrapassaria a cota de bytes do usuário user.
+func (fs *FURGFileSystem) userQuotaExceeded(user string) error {
+	quotas, _ := fs.UserQuotas()
+	return classErrorf(ErrNoSpace, "erro: a cota de %d bytes do usuário '%s' seria excedida", quotas[user].Bytes, user)
+}
+
+// quotaExceeded é o erro de uma gravação que ultrapassaria a cota de bytes de dir.
+func (fs *FURGFileSystem) quotaExceeded(dir string) error {
+	quotas, _ := fs.Quotas()
+	return classErrorf(ErrNoSpace, "erro: a cota de %d bytes de '%s' seria excedida", quotas[dir].Bytes, dir)
+}
+
+// quotaReportEntry é uma linha de "quota show" e "quota report", de um diretório ou, em "quota show", de um
+// usuário.
+type quotaReportEntry struct {
+	Path       string `json:"path,omitempty"`
+	User       string `json:"user,omitempty"`
+	Bytes      uint64 `json:"bytes"`
+	Files      int    `json:"files"`
+	BytesLimit uint64 `json:"bytes_limit,omitempty"`
+	FilesLimit int    `json:"files_limit,omitempty"`
+	Missing    bool   `json:"missing,omitempty"` // o diretório ou o usuário da cota não existe mais
+}
+
+// QuotaUsage retorna as cotas do volume com o uso atual de cada diretório, em ordem de caminho.
+func (fs *FURGFileSystem) QuotaUsage() ([]quotaReportEntry, error) {
+	quotas, err := fs.Quotas()
+	if err != nil {
+		return nil, err
+	}
+	entries := []quotaReportEntry{}
+	for dir, quota := range quotas {
+		usage := fs.usageOf(dir)
+		entries = append(entries, quotaReportEntry{
+			Path: dir, Bytes: usage.Bytes, Files: usage.Files, BytesLimit: quota.Bytes, FilesLimit: quota.Files,
+			Missing: dir != "/" && fs.CheckDirectoryExists(dir) == -1,
+		})
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].Path < entries[b].Path })
+	return entries, nil
+}
+
+// UserQuotaUsage retorna as cotas dos usuários com o uso atual de cada um, em ordem de nome.
+func (fs *FURGFileSystem) UserQuotaUsage() ([]quotaReportEntry, error) {
+	quotas, err := fs.UserQuotas()
+	if err != nil {
+		return nil, err
+	}
+	owners, err := fs.fileOwners()
+	if err != nil {
+		return nil, err
+	}
+	users, err := fs.Users()
+	if err != nil {
+		return nil, err
+	}
+	entries := []quotaReportEntry{}
+	for name, quota := range quotas {
+		usage := fs.userUsage(name, owners, "")
+		_, exists := users[name]
+		entries = append(entries, quotaReportEntry{
+			User: name, Bytes: usage.Bytes, Files: usage.Files, BytesLimit: quota.Bytes, FilesLimit: quota.Files, Missing: !exists,
+		})
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].User < entries[b].User })
+	return entries, nil
+}
+
+// TopConsumers retorna os top diretórios que mais ocupam espaço, contando seus subdiretórios, com as cotas
+// que valem para cada um.
+func (fs *FURGFileSystem) TopConsumers(top int) ([]quotaReportEntry, error) {
+	quotas, err := fs.Quotas()
+	if err != nil {
+		return nil, err
+	}
+	usage := map[string]*dirUsage{"/": {}}
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 || entry.FullPath() == systemDir || entry.PathString() == systemDir {
+			continue
+		}
+		if entry.IsDirectory {
+			if usage[entry.FullPath()] == nil {
+				usage[entry.FullPath()] = &dirUsage{}
+			}
+			continue
+		}
+		for dir := entry.PathString(); ; dir, _ = splitPath(dir) {
+			if usage[dir] == nil {
+				usage[dir] = &dirUsage{}
+			}
+			usage[dir].Bytes += uint64(entry.Size)
+			usage[dir].Files++
+			if dir == "/" {
+				break
+			}
+		}
+	}
+
+	entries := []quotaReportEntry{}
+	for dir, u := range usage {
+		quota := quotas[dir]
+		entries = append(entries, quotaReportEntry{Path: dir, Bytes: u.Bytes, Files: u.Files, BytesLimit: quota.Bytes, FilesLimit: quota.Files})
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].Bytes != entries[b].Bytes {
+			return entries[a].Bytes > entries[b].Bytes
+		}
+		return entries[a].Path < entries[b].Path
+	})
+	return entries[:min(top, len(entries))], nil
+}
+
+// printQuotaTable exibe as linhas de "quota show" e "quota report". A última coluna, title, é o diretório
+// ou o usuário.
+func printQuotaTable(entries []quotaReportEntry, title string) {
+	limit := func(used, max uint64) string {
+		if max == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%d (%.0f%%)", max, float64(used)/float64(max)*100)
+	}
+	fmt.Printf("%12s  %-18s  %7s  %-14s  %s\n", tr("BYTES"), tr("COTA"), tr("ARQS"), tr("COTA ARQS"), title)
+	for _, e := range entries {
+		name := cmp.Or(e.Path, e.User)
+		if e.Missing {
+			name += tr(" (inexistente)")
+		}
+		fmt.Printf("%12d  %-18s  %7d  %-14s  %s\n", e.Bytes, limit(e.Bytes, e.BytesLimit), e.Files,
+			limit(uint64(e.Files), uint64(e.FilesLimit)), name)
+	}
+}
+
+// setUserQuotaCLI define a cota do usuário name para "quota set --user".
+func (fs *FURGFileSystem) setUserQuotaCLI(name string, quota Quota) error {
+	if err := fs.SetUserQuota(name, quota); err != nil {
+		return err
+	}
+	owners, err := fs.fileOwners()
+	if err != nil {
+		return err
+	}
+	if quota == (Quota{}) {
+		fmt.Printf(tr("Cota do usuário '%s' removida.\n"), name)
+	} else if used := fs.userUsage(name, owners, ""); (quota.Bytes > 0 && used.Bytes > quota.Bytes) || (quota.Files > 0 && used.Files > quota.Files) {
+		fmt.Printf(tr("Cota do usuário '%s' definida; ele já ocupa %d bytes em %d arquivo(s), acima do limite.\n"), name, used.Bytes, used.Files)
+	} else {
+		fmt.Printf(tr("Cota do usuário '%s' definida.\n"), name)
+	}
+	return fs.saveFileSystemState()
+}
+
+// cliQuota implementa "quota set [--files N|none] <diretório> <tamanho|none>", "quota set [--files N|none]
+// --user <nome> <tamanho|none>", "quota show [--json]" e "quota report [--json] [--top N]".
+func cliQuota(fs *FURGFileSystem, args []string) error {
+	usage := classErrorf(ErrUsage, "uso: furgfs quota set [--files N|none] [--user <nome>] [<diretório>] <tamanho|none> | quota show [--json] | quota report [--json] [--top N]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	flags := flag.NewFlagSet("quota "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "set":
+		files := flags.String("files", "", "número máximo de arquivos (none remove o limite)")
+		user := flags.String("user", "", "define a cota de um usuário dos servidores de rede, e não de um diretório")
+		if err := flags.Parse(args[1:]); err != nil {
+			return classErrorf(ErrUsage, "%w", err)
+		}
+		if (*user == "" && flags.NArg() != 2) || (*user != "" && flags.NArg() != 1) {
+			return usage
+		}
+		var quota Quota
+		if limit := flags.Arg(flags.NArg() - 1); limit != "none" {
+			size, err := parseSize(limit)
+			if err != nil {
+				return err
+			}
+			quota.Bytes = uint64(size)
+		}
+		if *files != "" && *files != "none" {
+			if _, err := fmt.Sscan(*files, &quota.Files); err != nil || quota.Files <= 0 {
+				return classErrorf(ErrUsage, "erro: número de arquivos '%s' inválido", *files)
+			}
+		}
+		if *user != "" {
+			return fs.setUserQuotaCLI(*user, quota)
+		}
+		dir := fs.resolvePath(flags.Arg(0))
+		if err := fs.SetQuota(dir, quota); err != nil {
+			return err
+		}
+		if quota == (Quota{}) {
+			fmt.Printf(tr("Cota de '%s' removida.\n"), dir)
+		} else if used := fs.usageOf(dir); (quota.Bytes > 0 && used.Bytes > quota.Bytes) || (quota.Files > 0 && used.Files > quota.Files) {
+			fmt.Printf(tr("Cota de '%s' definida; o diretório já ocupa %d bytes em %d arquivo(s), acima do limite.\n"), dir, used.Bytes, used.Files)
+		} else {
+			fmt.Printf(tr("Cota de '%s' definida.\n"), dir)
+		}
+		return fs.saveFileSystemState()
+	case "show", "report":
+		asJSON := flags.Bool("json", false, "saída em JSON")
+		top := defaultTopFiles
+		if args[0] == "report" {
+			flags.IntVar(&top, "top", defaultTopFiles, "número de diretórios exibidos")
+		}
+		if err := flags.Parse(args[1:]); err != nil {
+			return classErrorf(ErrUsage, "%w", err)
+		}
+		if flags.NArg() != 0 || top < 0 {
+			return usage
+		}
+
+		var entries, users []quotaReportEntry
+		var err error
+		if args[0] == "show" {
+			if entries, err = fs.QuotaUsage(); err == nil {
+				users, err = fs.UserQuotaUsage()
+			}
+		} else {
+			entries, err = fs.TopConsumers(top)
+		}
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(struct {
+				Schema      int                `json:"schema"`
+				Directories []quotaReportEntry `json:"directories"`
+				Users       []quotaReportEntry `json:"users,omitempty"`
+			}{jsonSchemaVersion, entries, users})
+		}
+		if args[0] == "show" && len(entries) == 0 && len(users) == 0 {
+			fmt.Println(tr("Nenhuma cota definida."))
+			return nil
+		}
+		if len(entries) > 0 || args[0] == "report" {
+			printQuotaTable(entries, tr("DIRETÓRIO"))
+		}
+		if len(users) > 0 {
+			if len(entries) > 0 {
+				fmt.Println()
+			}
+			printQuotaTable(users, tr("USUÁRIO"))
+		}
+		return nil
+	default:
+		return usage
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+// As leituras de um sharedVolume (Stat, ReadDir, ReadAt e ReadFile) usam a parte compartilhada de
+// volumeState.mu: várias rodam ao mesmo tempo, e uma alteração espera as leituras em andamento e roda
+// sozinha. Vários clientes podem baixar ou listar arquivos em paralelo, cada um lendo os blocos com ReadAt.
+//
+// Cada leitura trabalha sobre uma cópia rasa do FURGFileSystem, a visão de reading: a FAT, o diretório, o
+// cache de escritas e a cifra são os mesmos e não mudam enquanto a leitura dura, mas o span atual, que
+// startSpan troca a cada operação, é só dela. O que as operações montariam na primeira consulta, como os
+// trechos da FAT ainda não lidos e o índice do diretório, é preparado antes com o volume bloqueado com
+// exclusividade (veja prepareReaders), para que nenhuma leitura altere o estado compartilhado. Com
+// --archives, as leituras continuam exclusivas: os índices dos arquivos navegáveis guardam a posição da
+// leitura e são montados sob demanda.
+
+// readersReady informa se o estado compartilhado já está pronto para leituras simultâneas.
+func (fs *FURGFileSystem) readersReady() bool {
+	return fs.fatLoaded == nil && fs.dirIndex != nil
+}
+
+// prepareReaders lê o que falta da FAT e monta o índice do diretório. Deve ser chamada com o volume
+// bloqueado com exclusividade.
+func (fs *FURGFileSystem) prepareReaders() error {
+	fs.fatEntries()
+	if fs.fatErr != nil {
+		return fs.fatErr
+	}
+	fs.directoryIndex()
+	return nil
+}
+
+// reading bloqueia o volume para uma leitura e retorna a visão de v em que ela deve ser feita e a função
+// que a encerra.
+func (v *sharedVolume) reading() (*sharedVolume, func()) {
+	if !v.archives {
+		for failed := false; !failed; {
+			v.mu.RLock()
+			if v.volumeState.fs.readersReady() {
+				view := *v.volumeState.fs
+				return &sharedVolume{volumeState: v.volumeState, user: v.user, fs: &view}, v.mu.RUnlock
+			}
+			v.mu.RUnlock()
+			v.mu.Lock()
+			err := v.volumeState.fs.prepareReaders()
+			v.mu.Unlock()
+			// Sem a FAT inteira, a leitura é feita com exclusividade, como as alterações
+			failed = err != nil
+		}
+	}
+	v.mu.Lock()
+	return v, v.mu.Unlock
+}
@@ -0,0 +1,25 @@
+package main
+
+import "fmt"
+
+// RepackDirectory move as entradas em uso para o início da tabela do diretório, mantendo sua ordem relativa
+// e eliminando as lacunas deixadas por remoções. Retorna o número de entradas que mudaram de posição.
+func (fs *FURGFileSystem) RepackDirectory() int {
+	next := 0
+	moved := 0
+	for i := range fs.RootDir {
+		if fs.RootDir[i].Name[0] == 0 {
+			continue
+		}
+		if i != next {
+			entry := fs.RootDir[i]
+			fs.clearEntry(i)
+			fs.setEntry(next, &entry)
+			moved++
+		}
+		next++
+	}
+
+	fmt.Printf("Diretório reorganizado: %d entrada(s) em uso, %d movida(s), %d livre(s) no fim da tabela.\n", next, moved, len(fs.RootDir)-next)
+	return moved
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// A replicação mantém uma imagem espelho, local ou remota, sempre atualizada. As escritas na imagem são
+// anotadas em pedaços de replicaChunk bytes e, a cada estado salvo, os pedaços alterados são copiados e
+// enviados ao espelho em segundo plano, sem atrasar o comando. O destino fica em /.furgfs/replica.json.
+const (
+	replicaInfoName = "replica.json"
+	replicaChunk    = 4096
+	replicaQueue    = 16
+)
+
+// replicaConfig é o conteúdo de /.furgfs/replica.json.
+type replicaConfig struct {
+	Target string `json:"target"`
+}
+
+// replicaBatch é um estado salvo da imagem: os pedaços alterados e o tamanho da imagem.
+type replicaBatch struct {
+	size   int64
+	chunks []replicaData
+}
+
+type replicaData struct {
+	off  int64
+	data []byte
+}
+
+// replicaStore envolve o armazenamento da imagem, anotando as escritas e enviando-as ao espelho.
+type replicaStore struct {
+	imageStore
+	target string
+
+	send    sync.Mutex // mantém a ordem dos estados na fila
+	mu      sync.Mutex
+	dirty   map[int64]bool // pedaços alterados desde o último envio, pelo deslocamento
+	resized bool
+	queue   chan replicaBatch
+	done    chan struct{}
+	failed  bool // um envio falhou; o espelho só volta a ser atualizado com "replica sync"
+}
+
+// startReplica passa a replicar a imagem se /.furgfs/replica.json indicar um espelho. O espelho de uma
+// imagem aberta como espelho de si mesma é ignorado.
+func (fs *FURGFileSystem) startReplica(imageName string) {
+	config, err := fs.ReplicaConfig()
+	if err != nil {
+		logger.Warn("configuração de replicação inválida", fs.imageAttr(), "error", err)
+		return
+	}
+	if config.Target == "" || sameFile(config.Target, imageName) {
+		return
+	}
+	fs.replica = &replicaStore{
+		imageStore: fs.FilePointer,
+		target:     config.Target,
+		dirty:      make(map[int64]bool),
+		queue:      make(chan replicaBatch, replicaQueue),
+		done:       make(chan struct{}),
+	}
+	fs.FilePointer = fs.replica
+	go fs.replica.run()
+	logger.Debug("replicação ativa", fs.imageAttr(), "target", config.Target)
+}
+
+// ReplicaConfig retorna o espelho configurado; sem espelho, o destino fica vazio.
+func (fs *FURGFileSystem) ReplicaConfig() (replicaConfig, error) {
+	var config replicaConfig
+	data, err := fs.readSystemFile(replicaInfoName)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	err = json.Unmarshal(data, &config)
+	return config, err
+}
+
+func (s *replicaStore) mark(off int64, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for chunk := off / replicaChunk * replicaChunk; chunk < off+int64(n); chunk += replicaChunk {
+		s.dirty[chunk] = true
+	}
+}
+
+func (s *replicaStore) WriteAt(p []byte, off int64) (int, error) {
+	n, err := s.imageStore.WriteAt(p, off)
+	s.mark(off, n)
+	return n, err
+}
+
+func (s *replicaStore) Truncate(size int64) error {
+	err := s.imageStore.Truncate(size)
+	s.mu.Lock()
+	s.resized = true
+	s.mu.Unlock()
+	return err
+}
+
+// Sync grava a imagem e envia as alterações, como ao salvar o estado.
+func (s *replicaStore) Sync() error {
+	err := s.imageStore.Sync()
+	s.commit()
+	return err
+}
+
+// commit copia os pedaços alterados e os põe na fila de envio. A cópia é feita agora, para que o
+// espelho receba exatamente o estado salvo mesmo que a imagem mude antes do envio.
+func (s *replicaStore) commit() {
+	s.send.Lock()
+	defer s.send.Unlock()
+	if batch, ok := s.collect(); ok {
+		s.queue <- batch
+	}
+}
+
+func (s *replicaStore) collect() (replicaBatch, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failed || len(s.dirty) == 0 && !s.resized {
+		return replicaBatch{}, false
+	}
+	info, err := s.imageStore.Stat()
+	if err != nil {
+		s.fail(err)
+		return replicaBatch{}, false
+	}
+	batch := replicaBatch{size: info.Size()}
+	for off := range s.dirty {
+		if off >= batch.size {
+			continue
+		}
+		data := make([]byte, min(replicaChunk, batch.size-off))
+		if _, err := s.imageStore.ReadAt(data, off); err != nil {
+			s.fail(err)
+			return replicaBatch{}, false
+		}
+		batch.chunks = append(batch.chunks, replicaData{off, data})
+	}
+	// Do fim para o começo: os blocos de dados chegam ao espelho antes da FAT e do diretório que os usam
+	sort.Slice(batch.chunks, func(i, j int) bool { return batch.chunks[i].off > batch.chunks[j].off })
+	s.dirty, s.resized = make(map[int64]bool), false
+	return batch, true
+}
+
+// fail interrompe a replicação; deve ser chamado com s.mu travado.
+func (s *replicaStore) fail(err error) {
+	if !s.failed {
+		logger.Error("replicação interrompida; use furgfs replica sync para atualizar o espelho", "target", s.target, "error", err)
+	}
+	s.failed = true
+}
+
+// run envia os estados da fila ao espelho, na ordem em que foram salvos.
+func (s *replicaStore) run() {
+	defer close(s.done)
+	var mirror imageStore
+	for batch := range s.queue {
+		s.mu.Lock()
+		failed := s.failed
+		s.mu.Unlock()
+		if failed {
+			continue
+		}
+		err := func() error {
+			if mirror == nil {
+				var err error
+				if mirror, err = openImageStore(s.target); err != nil {
+					return err
+				}
+			}
+			for _, chunk := range batch.chunks {
+				if _, err := mirror.WriteAt(chunk.data, chunk.off); err != nil {
+					return err
+				}
+			}
+			if info, err := mirror.Stat(); err != nil || info.Size() != batch.size {
+				if err := mirror.Truncate(batch.size); err != nil {
+					return err
+				}
+			}
+			return mirror.Sync()
+		}()
+		if err != nil {
+			s.mu.Lock()
+			s.fail(err)
+			s.mu.Unlock()
+			continue
+		}
+		logger.Debug("estado replicado", "target", s.target, "chunks", len(batch.chunks), "size", batch.size)
+	}
+	if mirror != nil {
+		if err := mirror.Close(); err != nil {
+			logger.Error("erro ao fechar o espelho", "target", s.target, "error", err)
+		}
+	}
+}
+
+// Close envia o que falta, espera a fila esvaziar e fecha a imagem.
+func (s *replicaStore) Close() error {
+	s.commit()
+	close(s.queue)
+	<-s.done
+	return s.imageStore.Close()
+}
+
+// copyToReplica copia a imagem inteira para target, criando-o ou substituindo-o.
+func (fs *FURGFileSystem) copyToReplica(target string) (int64, error) {
+	source := fs.FilePointer
+	if fs.replica != nil {
+		source = fs.replica.imageStore
+	}
+	info, err := source.Stat()
+	if err != nil {
+		return 0, err
+	}
+	mirror, err := createImageStore(target)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao criar o espelho: %w", err)
+	}
+	_, err = io.Copy(io.NewOffsetWriter(mirror, 0), io.NewSectionReader(source, 0, info.Size()))
+	if err == nil {
+		err = mirror.Sync()
+	}
+	if closeErr := mirror.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("erro ao copiar a imagem para o espelho: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// replicaStatus compara o espelho com a imagem: o tamanho e a área de metadados (cabeçalho, FAT e
+// diretório) precisam ser iguais. Como os blocos de dados nunca são reescritos no lugar, isso basta
+// para saber se o espelho está em dia.
+func (fs *FURGFileSystem) replicaStatus(target string) (bool, error) {
+	mirror, err := openImageStore(target)
+	if err != nil {
+		return false, err
+	}
+	defer mirror.Close()
+	local, err := fs.FilePointer.Stat()
+	if err != nil {
+		return false, err
+	}
+	remote, err := mirror.Stat()
+	if err != nil {
+		return false, err
+	}
+	if local.Size() != remote.Size() {
+		return false, nil
+	}
+	a, b := make([]byte, fs.Header.DataStart), make([]byte, fs.Header.DataStart)
+	if _, err := fs.FilePointer.ReadAt(a, 0); err != nil {
+		return false, err
+	}
+	if _, err := mirror.ReadAt(b, 0); err != nil && err != io.EOF {
+		return false, err
+	}
+	return bytes.Equal(a, b), nil
+}
+
+// cliReplica implementa "replica set <destino>", "replica sync", "replica status [--json]" e "replica remove".
+func cliReplica(fs *FURGFileSystem, args []string) error {
+	usage := classErrorf(ErrUsage, "uso: furgfs replica set <destino> | replica sync | replica status [--json] | replica remove")
+	if len(args) == 0 {
+		return usage
+	}
+	config, err := fs.ReplicaConfig()
+	if err != nil {
+		return classErrorf(ErrCorrupted, "erro ao ler %s: %v", joinPath(systemDir, replicaInfoName), err)
+	}
+
+	flags := flag.NewFlagSet("replica "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "set":
+		if len(args) != 2 {
+			return usage
+		}
+		target := args[1]
+		if sameFile(target, fs.FilePointer.Name()) {
+			return classErrorf(ErrUsage, "erro: o espelho não pode ser a própria imagem")
+		}
+		data, err := json.MarshalIndent(replicaConfig{Target: target}, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := fs.writeSystemFile(replicaInfoName, data); err != nil {
+			return err
+		}
+		if err := fs.saveFileSystemState(); err != nil {
+			return err
+		}
+		size, err := fs.copyToReplica(target)
+		if err != nil {
+			return err
+		}
+		fmt.Printf(tr("Espelho '%s' criado com %d bytes; as próximas alterações serão replicadas.\n"), target, size)
+		return nil
+	case "sync":
+		if len(args) != 1 {
+			return usage
+		}
+		if config.Target == "" {
+			return classErrorf(ErrNotFound, "erro: nenhum espelho configurado; use furgfs replica set <destino>")
+		}
+		size, err := fs.copyToReplica(config.Target)
+		if err != nil {
+			return err
+		}
+		fmt.Printf(tr("Espelho '%s' atualizado com %d bytes.\n"), config.Target, size)
+		return nil
+	case "status":
+		asJSON := flags.Bool("json", false, "saída em JSON")
+		if err := flags.Parse(args[1:]); err != nil {
+			return classErrorf(ErrUsage, "%w", err)
+		}
+		if flags.NArg() != 0 {
+			return usage
+		}
+		status := struct {
+			Schema int    `json:"schema"`
+			Target string `json:"target,omitempty"`
+			InSync bool   `json:"in_sync"`
+			Error  string `json:"error,omitempty"`
+		}{Schema: jsonSchemaVersion, Target: config.Target}
+		if config.Target != "" {
+			status.InSync, err = fs.replicaStatus(config.Target)
+			if err != nil {
+				status.Error = err.Error()
+			}
+		}
+		if *asJSON {
+			return printJSON(status)
+		}
+		switch {
+		case config.Target == "":
+			fmt.Println(tr("Nenhum espelho configurado."))
+		case status.Error != "":
+			fmt.Printf(tr("Espelho: %s (inacessível: %s)\n"), config.Target, status.Error)
+		case status.InSync:
+			fmt.Printf(tr("Espelho: %s (em dia)\n"), config.Target)
+		default:
+			fmt.Printf(tr("Espelho: %s (desatualizado; use furgfs replica sync)\n"), config.Target)
+		}
+		return nil
+	case "remove":
+		if len(args) != 1 {
+			return usage
+		}
+		if config.Target == "" {
+			return classErrorf(ErrNotFound, "erro: nenhum espelho configurado")
+		}
+		fs.discardEntries([]int{fs.lookupEntry(replicaInfoName, systemDir)})
+		fmt.Printf(tr("A imagem deixou de ser replicada para '%s'; o espelho não foi apagado.\n"), config.Target)
+		return fs.saveFileSystemState()
+	}
+	return usage
+}
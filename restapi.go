@@ -0,0 +1,386 @@
+package main
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	iofs "io/fs"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// A API REST de "serve api" fica sob /api/v1 e é descrita em openapi.json, servido em
+// /api/v1/openapi.json. Os pedidos são autenticados por HTTP Basic com os usuários cadastrados em
+// "furgfs user"; usuários somente leitura só podem usar GET.
+const apiPrefix = "/api/v1"
+
+// apiMaxChunk é o maior pedaço aceito por PATCH /api/v1/uploads/{id} e enviado por um download com offset.
+const apiMaxChunk = 64 << 20
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// apiError é o corpo das respostas de erro.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// apiRename é o corpo de POST /api/v1/rename.
+type apiRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// apiStartUpload é o corpo de POST /api/v1/uploads.
+type apiStartUpload struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// apiServer atende a API REST sobre um sharedVolume.
+type apiServer struct {
+	vol   *sharedVolume
+	users map[string]VolumeUser
+}
+
+// setupAPI prepara "serve api".
+func setupAPI(flags *flag.FlagSet) func(vol *sharedVolume) (shareServer, error) {
+	return func(vol *sharedVolume) (shareServer, error) {
+		users, err := serveUsers(vol)
+		if err != nil {
+			return nil, err
+		}
+		api := &apiServer{vol: vol, users: users}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET "+apiPrefix+"/openapi.json", api.spec)
+		mux.HandleFunc("GET "+apiPrefix+"/list/{path...}", api.list)
+		mux.HandleFunc("GET "+apiPrefix+"/stat/{path...}", api.stat)
+		mux.HandleFunc("GET "+apiPrefix+"/files/{path...}", api.download)
+		mux.HandleFunc("PUT "+apiPrefix+"/files/{path...}", api.upload)
+		mux.HandleFunc("DELETE "+apiPrefix+"/files/{path...}", api.remove)
+		mux.HandleFunc("POST "+apiPrefix+"/mkdir/{path...}", api.mkdir)
+		mux.HandleFunc("POST "+apiPrefix+"/rename", api.rename)
+		mux.HandleFunc("POST "+apiPrefix+"/uploads", api.startUpload)
+		mux.HandleFunc("GET "+apiPrefix+"/uploads/{id}", api.uploadStatus)
+		mux.HandleFunc("PATCH "+apiPrefix+"/uploads/{id}", api.uploadChunk)
+		mux.HandleFunc("POST "+apiPrefix+"/uploads/{id}/commit", api.commitUpload)
+		mux.HandleFunc("DELETE "+apiPrefix+"/uploads/{id}", api.abortUpload)
+		return &http.Server{Handler: api.authenticate(mux)}, nil
+	}
+}
+
+// authenticate exige um usuário cadastrado e recusa alterações de usuários somente leitura. A
+// especificação é pública.
+func (a *apiServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == apiPrefix+"/openapi.json" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		name, password, ok := r.BasicAuth()
+		user, known := a.users[name]
+		if !ok || !known || !user.CheckPassword(password) {
+			logger.Warn("api: autenticação recusada", "user", name, "remote", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Basic realm="furgfs"`)
+			writeAPIJSON(w, http.StatusUnauthorized, apiError{"autenticação necessária"})
+			return
+		}
+		if user.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeAPIJSON(w, http.StatusForbidden, apiError{"usuário somente leitura"})
+			return
+		}
+		logger.Info("api", "user", name, "method", r.Method, "path", r.URL.Path)
+		next.ServeHTTP(w, r.WithContext(withVolumeUser(r.Context(), name)))
+	})
+}
+
+// writeAPIJSON escreve v como a resposta JSON, com o código status.
+func writeAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// writeAPIError escreve err com o código HTTP correspondente.
+func writeAPIError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, iofs.ErrNotExist), errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, iofs.ErrPermission), errors.Is(err, ErrProtected):
+		status = http.StatusForbidden
+	case errors.Is(err, iofs.ErrExist), errors.Is(err, errNotEmpty):
+		status = http.StatusConflict
+	case errors.Is(err, errIsDirectory), errors.Is(err, errNotDir), errors.Is(err, ErrUsage):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrNoSpace):
+		status = http.StatusInsufficientStorage
+	case errors.Is(err, errUploadOffset):
+		status = http.StatusConflict
+	case errors.Is(err, errChunkChecksum), errors.Is(err, ErrCorrupted):
+		status = http.StatusUnprocessableEntity
+	}
+	writeAPIJSON(w, status, apiError{err.Error()})
+}
+
+// apiPath retorna o caminho do volume indicado no final da URL.
+func apiPath(r *http.Request) string {
+	return normalizePath(r.PathValue("path"))
+}
+
+// apiEntry descreve a entrada p no formato de "stat --json".
+func apiEntry(p string, info entryInfo) jsonEntry {
+	kind := "file"
+	if info.dir {
+		kind = "directory"
+	}
+	return jsonEntry{Path: p, Name: info.name, Type: kind, Size: uint32(info.size), Protected: info.protected}
+}
+
+func (a *apiServer) spec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+func (a *apiServer) list(w http.ResponseWriter, r *http.Request) {
+	p := apiPath(r)
+	infos, err := a.vol.ReadDir(p)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	listing := jsonListing{Schema: jsonSchemaVersion, Directory: p, Entries: []jsonEntry{}}
+	for _, info := range infos {
+		listing.Entries = append(listing.Entries, apiEntry(joinPath(p, info.name), info))
+	}
+	writeAPIJSON(w, http.StatusOK, listing)
+}
+
+func (a *apiServer) stat(w http.ResponseWriter, r *http.Request) {
+	p := apiPath(r)
+	info, err := a.vol.Stat(p)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, apiEntry(p, info))
+}
+
+func (a *apiServer) download(w http.ResponseWriter, r *http.Request) {
+	p := apiPath(r)
+	f, err := a.vol.OpenFile(p, os.O_RDONLY)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if info.IsDir() {
+		writeAPIError(w, errIsDirectory)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if r.URL.Query().Has("offset") {
+		a.downloadChunk(w, r, f, info.Size())
+		return
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// downloadChunk envia o pedaço do arquivo f indicado por ?offset=N&length=M (até apiMaxChunk bytes), com a
+// posição, o SHA-256 do pedaço e o tamanho do arquivo nos cabeçalhos X-Chunk-Offset, X-Chunk-Sha256 e
+// X-File-Size.
+func (a *apiServer) downloadChunk(w http.ResponseWriter, r *http.Request, f *sharedFile, size int64) {
+	query := r.URL.Query()
+	offset, err := strconv.ParseInt(query.Get("offset"), 10, 64)
+	length := int64(apiMaxChunk)
+	if err == nil && query.Has("length") {
+		length, err = strconv.ParseInt(query.Get("length"), 10, 64)
+	}
+	if err != nil || offset < 0 || offset > size || length <= 0 {
+		writeAPIJSON(w, http.StatusBadRequest, apiError{"offset e length inválidos"})
+		return
+	}
+	buf := make([]byte, min(length, apiMaxChunk, size-offset))
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		writeAPIError(w, err)
+		return
+	}
+	sum := sha256.Sum256(buf[:n])
+	w.Header().Set("X-Chunk-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("X-Chunk-Sha256", hex.EncodeToString(sum[:]))
+	w.Header().Set("X-File-Size", strconv.FormatInt(size, 10))
+	w.Header().Set("Content-Length", strconv.Itoa(n))
+	w.Write(buf[:n])
+}
+
+func (a *apiServer) upload(w http.ResponseWriter, r *http.Request) {
+	p := apiPath(r)
+	_, err := a.vol.Stat(p)
+	created := errors.Is(err, iofs.ErrNotExist)
+	if err = a.vol.userVolume(r.Context()).WriteFile(p, r.Body); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	info, err := a.vol.Stat(p)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeAPIJSON(w, status, apiEntry(p, info))
+}
+
+// remove apaga um arquivo ou diretório vazio; com ?recursive=true, apaga também o conteúdo do diretório.
+func (a *apiServer) remove(w http.ResponseWriter, r *http.Request) {
+	p := apiPath(r)
+	var err error
+	if r.URL.Query().Get("recursive") == "true" {
+		err = a.vol.userVolume(r.Context()).RemoveAll(p)
+	} else {
+		err = a.vol.userVolume(r.Context()).Remove(p)
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *apiServer) mkdir(w http.ResponseWriter, r *http.Request) {
+	p := apiPath(r)
+	if err := a.vol.userVolume(r.Context()).Mkdir(p); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	info, err := a.vol.Stat(p)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusCreated, apiEntry(p, info))
+}
+
+// rename move uma entrada; o destino não pode existir.
+func (a *apiServer) rename(w http.ResponseWriter, r *http.Request) {
+	var body apiRename
+	decoder := json.NewDecoder(io.LimitReader(r.Body, 64*1024))
+	if err := decoder.Decode(&body); err != nil || body.From == "" || body.To == "" {
+		writeAPIJSON(w, http.StatusBadRequest, apiError{`corpo inválido; esperado {"from": "...", "to": "..."}`})
+		return
+	}
+	from, to := normalizePath(body.From), normalizePath(body.To)
+	if _, err := a.vol.Stat(to); err == nil {
+		writeAPIError(w, iofs.ErrExist)
+		return
+	}
+	if err := a.vol.userVolume(r.Context()).Rename(from, to); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	info, err := a.vol.Stat(to)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, apiEntry(to, info))
+}
+
+func (a *apiServer) startUpload(w http.ResponseWriter, r *http.Request) {
+	var body apiStartUpload
+	decoder := json.NewDecoder(io.LimitReader(r.Body, 64*1024))
+	if err := decoder.Decode(&body); err != nil || body.Path == "" {
+		writeAPIJSON(w, http.StatusBadRequest, apiError{`corpo inválido; esperado {"path": "...", "size": N, "sha256": "..."}`})
+		return
+	}
+	session, err := a.vol.userVolume(r.Context()).StartUpload(body.Path, body.Size, body.SHA256)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusCreated, session)
+}
+
+func (a *apiServer) uploadStatus(w http.ResponseWriter, r *http.Request) {
+	session, err := a.vol.userVolume(r.Context()).Upload(r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, session)
+}
+
+// uploadChunk acrescenta ao envio o pedaço do corpo, que começa em ?offset=N e tem o SHA-256 opcional do
+// cabeçalho X-Chunk-Sha256. Um pedaço que não chega inteiro é descartado.
+func (a *apiServer) uploadChunk(w http.ResponseWriter, r *http.Request) {
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil {
+		writeAPIJSON(w, http.StatusBadRequest, apiError{"offset inválido"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, apiMaxChunk+1))
+	if err != nil {
+		writeAPIJSON(w, http.StatusBadRequest, apiError{err.Error()})
+		return
+	}
+	if len(data) > apiMaxChunk {
+		writeAPIJSON(w, http.StatusRequestEntityTooLarge, apiError{"pedaço maior que 64 MiB"})
+		return
+	}
+	session, err := a.vol.userVolume(r.Context()).AppendUpload(r.PathValue("id"), offset, data, r.Header.Get("X-Chunk-Sha256"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, session)
+}
+
+func (a *apiServer) commitUpload(w http.ResponseWriter, r *http.Request) {
+	vol := a.vol.userVolume(r.Context())
+	session, err := vol.Upload(r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	_, err = a.vol.Stat(session.Path)
+	created := errors.Is(err, iofs.ErrNotExist)
+	p, err := vol.CommitUpload(session.ID)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	info, err := a.vol.Stat(p)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeAPIJSON(w, status, apiEntry(p, info))
+}
+
+func (a *apiServer) abortUpload(w http.ResponseWriter, r *http.Request) {
+	if err := a.vol.userVolume(r.Context()).AbortUpload(r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
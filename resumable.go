@@ -0,0 +1,287 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Os envios retomáveis da API REST e do gRPC levam arquivos grandes por conexões instáveis: o cliente abre
+// um envio com o caminho e o tamanho, manda o conteúdo em pedaços, cada um com a sua posição e,
+// opcionalmente, o seu SHA-256, e pede a gravação quando terminar. Os pedaços recebidos ficam no sistema
+// real, em <imagem>.uploads, e não no volume, que só grava arquivos inteiros; assim, um envio interrompido,
+// mesmo por um reinício do servidor, continua do último byte recebido. Envios parados há mais de
+// uploadExpiry são descartados.
+const (
+	uploadsSuffix = ".uploads"
+	uploadExpiry  = 24 * time.Hour
+)
+
+var (
+	// errUploadOffset é retornado quando um pedaço não começa no offset atual do envio; o cliente deve
+	// consultar o envio e continuar de onde ele parou.
+	errUploadOffset = errors.New("posição do pedaço diferente do offset do envio")
+	// errChunkChecksum é retornado quando o SHA-256 de um pedaço não confere; o pedaço é descartado.
+	errChunkChecksum = errors.New("o SHA-256 do pedaço não confere")
+
+	uploadIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+// uploadSession é um envio retomável, guardado em <id>.json ao lado dos dados recebidos, em <id>.part.
+type uploadSession struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	SHA256  string    `json:"sha256,omitempty"` // hash esperado do arquivo inteiro
+	User    string    `json:"user,omitempty"`
+	Created time.Time `json:"created"`
+	Offset  int64     `json:"offset"` // bytes recebidos, lido do tamanho de <id>.part
+}
+
+// uploadStore guarda os envios retomáveis de um volume servido.
+type uploadStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// uploadStagingDir retorna o diretório dos envios da imagem imageName; o de uma imagem remota fica junto
+// do cache local dela.
+func uploadStagingDir(imageName string) (string, error) {
+	if !isRemoteImage(imageName) {
+		return imageName + uploadsSuffix, nil
+	}
+	cache, _, err := remoteCachePaths(imageName)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(cache, ".img") + uploadsSuffix, nil
+}
+
+// uploads retorna os envios retomáveis do volume.
+func (v *sharedVolume) uploads() (*uploadStore, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.uploadStore == nil {
+		dir, err := uploadStagingDir(v.fs.FilePointer.Name())
+		if err != nil {
+			return nil, err
+		}
+		v.uploadStore = &uploadStore{dir: dir}
+	}
+	return v.uploadStore, nil
+}
+
+func (s *uploadStore) metaPath(id string) string { return filepath.Join(s.dir, id+".json") }
+func (s *uploadStore) partPath(id string) string { return filepath.Join(s.dir, id+".part") }
+
+// load lê o envio id do usuário user. Um envio de outro usuário é tratado como inexistente.
+func (s *uploadStore) load(id, user string) (uploadSession, error) {
+	var session uploadSession
+	if !uploadIDPattern.MatchString(id) {
+		return session, iofs.ErrNotExist
+	}
+	data, err := os.ReadFile(s.metaPath(id))
+	if err != nil {
+		return session, iofs.ErrNotExist
+	}
+	if err := json.Unmarshal(data, &session); err != nil || session.User != user {
+		return session, iofs.ErrNotExist
+	}
+	info, err := os.Stat(s.partPath(id))
+	if err != nil {
+		return session, iofs.ErrNotExist
+	}
+	session.Offset = info.Size()
+	return session, nil
+}
+
+// drop apaga os arquivos do envio id e, se não houver outros envios, o diretório.
+func (s *uploadStore) drop(id string) {
+	os.Remove(s.partPath(id))
+	os.Remove(s.metaPath(id))
+	os.Remove(s.dir)
+}
+
+// expire descarta os envios que não recebem pedaços há mais de uploadExpiry.
+func (s *uploadStore) expire() {
+	parts, _ := filepath.Glob(filepath.Join(s.dir, "*.part"))
+	for _, part := range parts {
+		if info, err := os.Stat(part); err == nil && time.Since(info.ModTime()) > uploadExpiry {
+			id := strings.TrimSuffix(filepath.Base(part), ".part")
+			logger.Info("envio retomável expirado", "id", id)
+			s.drop(id)
+		}
+	}
+}
+
+// StartUpload abre um envio retomável do arquivo p, com size bytes e, se sum não for vazio, o SHA-256
+// sum. O destino e o espaço livre são conferidos já aqui, para que o envio não seja perdido no fim.
+func (v *sharedVolume) StartUpload(p string, size int64, sum string) (uploadSession, error) {
+	p = normalizePath(p)
+	if size < 0 || size > math.MaxUint32 {
+		return uploadSession{}, classErrorf(ErrUsage, "erro: tamanho inválido; o FURGfs2 guarda arquivos de até 4 GB")
+	}
+	sum = strings.ToLower(sum)
+	if _, err := hex.DecodeString(sum); err != nil || sum != "" && len(sum) != 2*sha256.Size {
+		return uploadSession{}, classErrorf(ErrUsage, "erro: hash '%s' inválido; use os 64 dígitos hexadecimais do SHA-256", sum)
+	}
+	err := v.with(func(fs *FURGFileSystem) error {
+		if _, err := v.writeTarget(p); err != nil {
+			return err
+		}
+		if uint64(size) > fs.freeSpaceInfo().FreeBytes {
+			return classErrorf(ErrNoSpace, "erro: espaço insuficiente para '%s'", p)
+		}
+		return nil
+	})
+	if err != nil {
+		return uploadSession{}, err
+	}
+	store, err := v.uploads()
+	if err != nil {
+		return uploadSession{}, err
+	}
+
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return uploadSession{}, err
+	}
+	session := uploadSession{ID: hex.EncodeToString(id[:]), Path: p, Size: size, SHA256: sum, User: v.user, Created: time.Now().UTC()}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.expire()
+	if err := os.MkdirAll(store.dir, 0o700); err != nil {
+		return uploadSession{}, err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return uploadSession{}, err
+	}
+	if err := os.WriteFile(store.partPath(session.ID), nil, 0o600); err != nil {
+		return uploadSession{}, err
+	}
+	if err := os.WriteFile(store.metaPath(session.ID), data, 0o600); err != nil {
+		store.drop(session.ID)
+		return uploadSession{}, err
+	}
+	logger.Info("envio retomável aberto", "id", session.ID, "path", p, "size", size, "user", v.user)
+	return session, nil
+}
+
+// Upload retorna o envio id.
+func (v *sharedVolume) Upload(id string) (uploadSession, error) {
+	store, err := v.uploads()
+	if err != nil {
+		return uploadSession{}, err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.load(id, v.user)
+}
+
+// AppendUpload acrescenta data, que começa em offset, ao envio id, conferindo antes o SHA-256 sum, se
+// informado. Retorna o envio atualizado, também quando o pedaço é recusado.
+func (v *sharedVolume) AppendUpload(id string, offset int64, data []byte, sum string) (uploadSession, error) {
+	store, err := v.uploads()
+	if err != nil {
+		return uploadSession{}, err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	session, err := store.load(id, v.user)
+	if err != nil {
+		return session, err
+	}
+	if offset != session.Offset {
+		return session, fmt.Errorf("%w: esperado %d, recebido %d", errUploadOffset, session.Offset, offset)
+	}
+	if session.Offset+int64(len(data)) > session.Size {
+		return session, classErrorf(ErrUsage, "erro: o envio passaria do tamanho declarado (%d bytes)", session.Size)
+	}
+	if hash := sha256.Sum256(data); sum != "" && !strings.EqualFold(sum, hex.EncodeToString(hash[:])) {
+		return session, errChunkChecksum
+	}
+	f, err := os.OpenFile(store.partPath(id), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return session, err
+	}
+	n, err := f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	session.Offset += int64(n)
+	return session, err
+}
+
+// CommitUpload grava no volume o arquivo do envio id, completo, e descarta o envio. Se o SHA-256 do
+// arquivo inteiro não conferir, o envio também é descartado.
+func (v *sharedVolume) CommitUpload(id string) (string, error) {
+	store, err := v.uploads()
+	if err != nil {
+		return "", err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	session, err := store.load(id, v.user)
+	if err != nil {
+		return "", err
+	}
+	if session.Offset != session.Size {
+		return "", fmt.Errorf("%w: o envio tem %d de %d bytes", errUploadOffset, session.Offset, session.Size)
+	}
+	f, err := os.Open(store.partPath(id))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if session.SHA256 != "" {
+		sum, err := hashReader(f)
+		if err != nil {
+			return "", err
+		}
+		if sum != session.SHA256 {
+			store.drop(id)
+			return "", classErrorf(ErrCorrupted, "erro: o SHA-256 do arquivo recebido não confere; o envio foi descartado")
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+	if err := v.WriteFile(session.Path, f); err != nil {
+		return "", err
+	}
+	f.Close()
+	store.drop(id)
+	logger.Info("envio retomável gravado", "id", id, "path", session.Path, "size", session.Size, "user", v.user)
+	return session.Path, nil
+}
+
+// AbortUpload descarta o envio id.
+func (v *sharedVolume) AbortUpload(id string) error {
+	store, err := v.uploads()
+	if err != nil {
+		return err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, err := store.load(id, v.user); err != nil {
+		return err
+	}
+	store.drop(id)
+	return nil
+}
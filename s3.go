@@ -0,0 +1,845 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// "serve s3" oferece um subconjunto da API do Amazon S3, com endereçamento por caminho
+// (http://host:9000/balde/chave): os baldes são os diretórios da raiz do volume e as chaves, os caminhos
+// dos arquivos dentro deles, com "/" separando os diretórios. Os pedidos são assinados com AWS Signature
+// Version 4, com o nome do usuário como chave de acesso e a chave secreta criada por "furgfs user s3key".
+const (
+	s3XMLNS        = "http://s3.amazonaws.com/doc/2006-03-01/"
+	s3Algorithm    = "AWS4-HMAC-SHA256"
+	s3TimeFormat   = "20060102T150405Z"
+	s3MaxKeys      = 1000
+	s3MaxSkew      = 15 * time.Minute
+	s3EmptySHA256  = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	s3Unsigned     = "UNSIGNED-PAYLOAD"
+	s3Streaming    = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	s3ListedFormat = "2006-01-02T15:04:05.000Z"
+)
+
+// s3Unsupported são os sub-recursos de baldes e objetos que o servidor não implementa.
+var s3Unsupported = []string{
+	"uploads", "uploadId", "partNumber", "acl", "policy", "tagging", "lifecycle", "cors", "encryption",
+	"object-lock", "retention", "legal-hold", "website", "logging", "notification", "replication",
+	"accelerate", "requestPayment", "versions", "restore", "select",
+}
+
+// s3Error é uma resposta de erro do S3.
+type s3Error struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource,omitempty"`
+	status   int
+}
+
+func (e *s3Error) Error() string { return e.Code + ": " + e.Message }
+
+func newS3Error(status int, code, message string) *s3Error {
+	return &s3Error{Code: code, Message: message, status: status}
+}
+
+type s3Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type s3Bucket struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type s3ListAllMyBucketsResult struct {
+	XMLName xml.Name   `xml:"ListAllMyBucketsResult"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	Owner   s3Owner    `xml:"Owner"`
+	Buckets []s3Bucket `xml:"Buckets>Bucket"`
+}
+
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3Prefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// s3ListBucketResult atende ListObjects e ListObjectsV2; os campos de cada versão ficam vazios na outra.
+type s3ListBucketResult struct {
+	XMLName               xml.Name   `xml:"ListBucketResult"`
+	Xmlns                 string     `xml:"xmlns,attr"`
+	Name                  string     `xml:"Name"`
+	Prefix                string     `xml:"Prefix"`
+	Marker                *string    `xml:"Marker"`
+	NextMarker            string     `xml:"NextMarker,omitempty"`
+	StartAfter            string     `xml:"StartAfter,omitempty"`
+	ContinuationToken     string     `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string     `xml:"NextContinuationToken,omitempty"`
+	KeyCount              *int       `xml:"KeyCount"`
+	MaxKeys               int        `xml:"MaxKeys"`
+	Delimiter             string     `xml:"Delimiter,omitempty"`
+	EncodingType          string     `xml:"EncodingType,omitempty"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	Contents              []s3Object `xml:"Contents"`
+	CommonPrefixes        []s3Prefix `xml:"CommonPrefixes"`
+}
+
+type s3CopyObjectResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+type s3Delete struct {
+	Quiet   bool `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+type s3Deleted struct {
+	Key string `xml:"Key"`
+}
+
+type s3DeleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type s3DeleteResult struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Deleted []s3Deleted     `xml:"Deleted"`
+	Errors  []s3DeleteError `xml:"Error"`
+}
+
+// s3Key é uma chave listada: um arquivo ou, terminada em "/", um diretório vazio.
+type s3Key struct {
+	key  string
+	path string
+	info entryInfo
+}
+
+// s3Server atende a API S3 sobre um sharedVolume.
+type s3Server struct {
+	vol       *sharedVolume
+	users     map[string]VolumeUser
+	maxObject int64
+}
+
+// s3Request é o estado de um pedido autenticado.
+type s3Request struct {
+	user       string
+	signingKey []byte
+	amzDate    string
+	scope      string
+	signature  string
+	payload    string // valor de x-amz-content-sha256
+}
+
+// setupS3 prepara "serve s3". Os usuários são lidos uma vez, ao iniciar o servidor.
+func setupS3(flags *flag.FlagSet) func(vol *sharedVolume) (shareServer, error) {
+	return func(vol *sharedVolume) (shareServer, error) {
+		users, err := serveUsers(vol)
+		if err != nil {
+			return nil, err
+		}
+		keys := 0
+		for _, user := range users {
+			if user.S3Secret != "" {
+				keys++
+			}
+		}
+		if keys == 0 {
+			return nil, classErrorf(ErrUsage, "erro: nenhum usuário com chave S3; use furgfs user s3key <nome>")
+		}
+		s := &s3Server{vol: vol, users: users}
+		vol.with(func(fs *FURGFileSystem) error {
+			s.maxObject = int64(fs.freeSpaceInfo().TotalBytes)
+			return nil
+		})
+		return &http.Server{Handler: s}, nil
+	}
+}
+
+func (s *s3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	req, err := s.authenticate(r)
+	if err != nil {
+		logger.Warn("s3: autenticação recusada", "error", err, "remote", r.RemoteAddr)
+		writeS3Error(w, r, err)
+		return
+	}
+	logger.Info("s3", "user", req.user, "method", r.Method, "path", r.URL.Path)
+	if s.users[req.user].ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeS3Error(w, r, newS3Error(http.StatusForbidden, "AccessDenied", "usuário somente leitura"))
+		return
+	}
+	// O restante do pedido usa a visão do volume do usuário, o autor das alterações na auditoria
+	view := *s
+	view.vol = s.vol.as(req.user)
+	s = &view
+	query := r.URL.Query()
+	for _, name := range s3Unsupported {
+		if query.Has(name) {
+			writeS3Error(w, r, newS3Error(http.StatusNotImplemented, "NotImplemented", "operação não suportada: "+name))
+			return
+		}
+	}
+
+	switch {
+	case bucket == "" && r.Method == http.MethodGet:
+		err = s.listBuckets(w)
+	case bucket == "":
+		err = newS3Error(http.StatusMethodNotAllowed, "MethodNotAllowed", "método não permitido")
+	case key == "" && r.Method == http.MethodPut:
+		err = s.createBucket(w, r, req, bucket)
+	case key == "" && r.Method == http.MethodDelete:
+		err = s.deleteBucket(w, bucket)
+	case key == "" && r.Method == http.MethodHead:
+		err = s.checkBucket(bucket)
+	case key == "" && r.Method == http.MethodPost && query.Has("delete"):
+		err = s.deleteObjects(w, r, req, bucket)
+	case key == "" && r.Method == http.MethodGet && query.Has("location"):
+		err = s.bucketConfig(w, bucket, struct {
+			XMLName xml.Name `xml:"LocationConstraint"`
+			Xmlns   string   `xml:"xmlns,attr"`
+		}{Xmlns: s3XMLNS})
+	case key == "" && r.Method == http.MethodGet && query.Has("versioning"):
+		err = s.bucketConfig(w, bucket, struct {
+			XMLName xml.Name `xml:"VersioningConfiguration"`
+			Xmlns   string   `xml:"xmlns,attr"`
+		}{Xmlns: s3XMLNS})
+	case key == "" && r.Method == http.MethodGet:
+		err = s.listObjects(w, r, bucket)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		err = s.copyObject(w, r, bucket, key)
+	case r.Method == http.MethodPut:
+		err = s.putObject(w, r, req, bucket, key)
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		err = s.getObject(w, r, bucket, key)
+	case r.Method == http.MethodDelete:
+		if err = s.deleteObject(bucket, key); err == nil {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		err = newS3Error(http.StatusMethodNotAllowed, "MethodNotAllowed", "método não permitido")
+	}
+	if err != nil {
+		writeS3Error(w, r, err)
+	}
+}
+
+// writeS3XML escreve v como a resposta XML, com o código status.
+func writeS3XML(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(v)
+}
+
+// writeS3Error escreve err no formato de erro do S3, convertendo os erros do volume.
+func writeS3Error(w http.ResponseWriter, r *http.Request, err error) {
+	var e *s3Error
+	if !errors.As(err, &e) {
+		e = s3ErrorFor(err, "NoSuchKey")
+	}
+	e.Resource = r.URL.Path
+	if r.Method == http.MethodHead {
+		w.WriteHeader(e.status)
+		return
+	}
+	writeS3XML(w, e.status, e)
+}
+
+// s3ErrorFor converte um erro do volume; notFound é o código usado quando a entrada não existe.
+func s3ErrorFor(err error, notFound string) *s3Error {
+	switch {
+	case errors.Is(err, errNotEmpty):
+		// ENOTEMPTY também corresponde a fs.ErrExist; por isso é testado antes
+		return newS3Error(http.StatusConflict, "BucketNotEmpty", err.Error())
+	case errors.Is(err, iofs.ErrNotExist), errors.Is(err, ErrNotFound):
+		return newS3Error(http.StatusNotFound, notFound, err.Error())
+	case errors.Is(err, iofs.ErrPermission), errors.Is(err, ErrProtected):
+		return newS3Error(http.StatusForbidden, "AccessDenied", err.Error())
+	case errors.Is(err, ErrNameTooLong), errors.Is(err, ErrPathTooLong):
+		return newS3Error(http.StatusBadRequest, "KeyTooLongError", err.Error())
+	case errors.Is(err, iofs.ErrExist), errors.Is(err, errIsDirectory), errors.Is(err, errNotDir), errors.Is(err, ErrUsage):
+		return newS3Error(http.StatusBadRequest, "InvalidArgument", err.Error())
+	case errors.Is(err, ErrNoSpace):
+		return newS3Error(http.StatusInsufficientStorage, "EntityTooLarge", err.Error())
+	}
+	return newS3Error(http.StatusInternalServerError, "InternalError", err.Error())
+}
+
+// s3Escape codifica s como a AWS nas assinaturas: só os caracteres não reservados ficam como estão e, com
+// slash, também "/".
+func s3Escape(s string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 || slash && c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticate confere a assinatura AWS4-HMAC-SHA256 do cabeçalho Authorization ou, em uma URL
+// pré-assinada, da query.
+func (s *s3Server) authenticate(r *http.Request) (*s3Request, error) {
+	query := r.URL.Query()
+	var credential, signedHeaders, signature string
+	req := &s3Request{payload: r.Header.Get("X-Amz-Content-Sha256")}
+	if auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), s3Algorithm+" "); ok {
+		for _, field := range strings.Split(auth, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+			switch name {
+			case "Credential":
+				credential = value
+			case "SignedHeaders":
+				signedHeaders = value
+			case "Signature":
+				signature = value
+			}
+		}
+		req.amzDate = r.Header.Get("X-Amz-Date")
+		date, err := time.Parse(s3TimeFormat, req.amzDate)
+		if err != nil {
+			return nil, newS3Error(http.StatusForbidden, "AccessDenied", "X-Amz-Date ausente ou inválido")
+		}
+		if skew := time.Since(date); skew > s3MaxSkew || skew < -s3MaxSkew {
+			return nil, newS3Error(http.StatusForbidden, "RequestTimeTooSkewed", "a hora do cliente difere demais da do servidor")
+		}
+		if req.payload == "" {
+			req.payload = s3Unsigned
+		}
+	} else if query.Get("X-Amz-Algorithm") == s3Algorithm {
+		credential, signedHeaders, signature = query.Get("X-Amz-Credential"), query.Get("X-Amz-SignedHeaders"), query.Get("X-Amz-Signature")
+		req.amzDate = query.Get("X-Amz-Date")
+		date, err := time.Parse(s3TimeFormat, req.amzDate)
+		expires, _ := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil || time.Now().After(date.Add(time.Duration(expires)*time.Second)) {
+			return nil, newS3Error(http.StatusForbidden, "AccessDenied", "URL pré-assinada expirada")
+		}
+		req.payload = s3Unsigned
+	} else {
+		return nil, newS3Error(http.StatusForbidden, "AccessDenied", "pedido sem assinatura AWS4-HMAC-SHA256")
+	}
+
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != "s3" || parts[4] != "aws4_request" || signedHeaders == "" {
+		return nil, newS3Error(http.StatusForbidden, "AuthorizationHeaderMalformed", "credencial inválida")
+	}
+	user, ok := s.users[parts[0]]
+	if !ok || user.S3Secret == "" {
+		return nil, newS3Error(http.StatusForbidden, "InvalidAccessKeyId", "chave de acesso desconhecida")
+	}
+	req.user, req.scope = parts[0], strings.Join(parts[1:], "/")
+	req.signingKey = []byte("AWS4" + user.S3Secret)
+	for _, part := range parts[1:] {
+		req.signingKey = hmacSHA256(req.signingKey, part)
+	}
+
+	// Requisição canônica: método, caminho, query, cabeçalhos assinados e hash do conteúdo
+	var canonicalQuery []string
+	for name, values := range query {
+		if name == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			canonicalQuery = append(canonicalQuery, s3Escape(name, false)+"="+s3Escape(value, false))
+		}
+	}
+	sort.Strings(canonicalQuery)
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := strings.Join(r.Header.Values(name), ",")
+		switch {
+		case name == "host":
+			value = r.Host
+		case name == "content-length" && value == "":
+			value = strconv.FormatInt(r.ContentLength, 10)
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	canonical := strings.Join([]string{
+		r.Method, s3Escape(r.URL.Path, true), strings.Join(canonicalQuery, "&"),
+		canonicalHeaders.String(), signedHeaders, req.payload,
+	}, "\n")
+	stringToSign := strings.Join([]string{s3Algorithm, req.amzDate, req.scope, sha256Hex([]byte(canonical))}, "\n")
+	expected := hex.EncodeToString(hmacSHA256(req.signingKey, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, newS3Error(http.StatusForbidden, "SignatureDoesNotMatch", "a assinatura do pedido não confere")
+	}
+	req.signature = signature
+	return req, nil
+}
+
+// body lê o conteúdo do pedido e confere o hash informado em x-amz-content-sha256. No envio em pedaços
+// (aws-chunked), confere também a assinatura de cada pedaço.
+func (s *s3Server) body(r *http.Request, req *s3Request) ([]byte, error) {
+	reader := io.LimitReader(r.Body, s.maxObject+1)
+	var data []byte
+	var err error
+	switch {
+	case strings.HasPrefix(req.payload, "STREAMING-"):
+		data, err = s.chunked(bufio.NewReader(reader), req, strings.HasPrefix(req.payload, s3Streaming))
+	default:
+		data, err = io.ReadAll(reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.maxObject {
+		return nil, newS3Error(http.StatusBadRequest, "EntityTooLarge", "o objeto é maior que o volume")
+	}
+	if req.payload != s3Unsigned && !strings.HasPrefix(req.payload, "STREAMING-") && sha256Hex(data) != req.payload {
+		return nil, newS3Error(http.StatusBadRequest, "XAmzContentSHA256Mismatch", "o conteúdo não confere com x-amz-content-sha256")
+	}
+	if sum := r.Header.Get("Content-Md5"); sum != "" {
+		digest := md5.Sum(data)
+		if base64.StdEncoding.EncodeToString(digest[:]) != sum {
+			return nil, newS3Error(http.StatusBadRequest, "BadDigest", "o conteúdo não confere com Content-MD5")
+		}
+	}
+	return data, nil
+}
+
+// chunked decodifica o conteúdo em pedaços "tamanho;chunk-signature=...\r\n dados \r\n", que termina em um
+// pedaço vazio; com signed, cada pedaço é assinado encadeando a assinatura anterior.
+func (s *s3Server) chunked(r *bufio.Reader, req *s3Request, signed bool) ([]byte, error) {
+	malformed := newS3Error(http.StatusBadRequest, "IncompleteBody", "conteúdo em pedaços malformado")
+	var data []byte
+	previous := req.signature
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, malformed
+		}
+		sizeHex, extension, _ := strings.Cut(strings.TrimRight(line, "\r\n"), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size < 0 || int64(len(data))+size > s.maxObject {
+			return nil, malformed
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, malformed
+		}
+		if signed {
+			stringToSign := strings.Join([]string{s3Algorithm + "-PAYLOAD", req.amzDate, req.scope, previous, s3EmptySHA256, sha256Hex(chunk)}, "\n")
+			expected := hex.EncodeToString(hmacSHA256(req.signingKey, stringToSign))
+			signature := strings.TrimPrefix(extension, "chunk-signature=")
+			if !hmac.Equal([]byte(expected), []byte(signature)) {
+				return nil, newS3Error(http.StatusForbidden, "SignatureDoesNotMatch", "a assinatura de um pedaço não confere")
+			}
+			previous = signature
+		}
+		data = append(data, chunk...)
+		if size == 0 {
+			// Os cabeçalhos finais (trailers), se houver, são ignorados
+			return data, nil
+		}
+		if crlf, err := r.ReadString('\n'); err != nil || strings.TrimRight(crlf, "\r\n") != "" {
+			return nil, malformed
+		}
+	}
+}
+
+// s3ETag calcula a ETag do S3 para um conteúdo enviado de uma vez: o MD5 entre aspas.
+func s3ETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// s3ObjectPath retorna o caminho do volume da chave key em bucket. Chaves com componentes vazios, "." ou
+// ".." não correspondem a um caminho e são recusadas.
+func s3ObjectPath(bucket, key string) (string, error) {
+	for _, part := range strings.Split(strings.TrimSuffix(key, "/"), "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", newS3Error(http.StatusBadRequest, "InvalidArgument", "chave inválida: "+key)
+		}
+	}
+	return "/" + bucket + "/" + strings.TrimSuffix(key, "/"), nil
+}
+
+func (s *s3Server) checkBucket(bucket string) error {
+	info, err := s.vol.Stat("/" + bucket)
+	if err != nil || !info.dir || strings.Contains(bucket, "/") {
+		return newS3Error(http.StatusNotFound, "NoSuchBucket", "o balde não existe")
+	}
+	return nil
+}
+
+func (s *s3Server) bucketConfig(w http.ResponseWriter, bucket string, v any) error {
+	if err := s.checkBucket(bucket); err != nil {
+		return err
+	}
+	writeS3XML(w, http.StatusOK, v)
+	return nil
+}
+
+func (s *s3Server) listBuckets(w http.ResponseWriter) error {
+	infos, err := s.vol.ReadDir("/")
+	if err != nil {
+		return err
+	}
+	result := s3ListAllMyBucketsResult{Xmlns: s3XMLNS, Owner: s3Owner{ID: "furgfs", DisplayName: "furgfs"}}
+	for _, info := range infos {
+		if info.dir {
+			result.Buckets = append(result.Buckets, s3Bucket{info.name, info.modTime.UTC().Format(s3ListedFormat)})
+		}
+	}
+	writeS3XML(w, http.StatusOK, result)
+	return nil
+}
+
+func (s *s3Server) createBucket(w http.ResponseWriter, r *http.Request, req *s3Request, bucket string) error {
+	// O corpo pode trazer a região em CreateBucketConfiguration, que é ignorada
+	if _, err := s.body(r, req); err != nil {
+		return err
+	}
+	if err := s.vol.Mkdir("/" + bucket); err != nil {
+		switch {
+		case errors.Is(err, iofs.ErrExist):
+			return newS3Error(http.StatusConflict, "BucketAlreadyOwnedByYou", "o balde já existe")
+		case errors.Is(err, iofs.ErrNotExist), errors.Is(err, ErrUsage):
+			return newS3Error(http.StatusBadRequest, "InvalidBucketName", err.Error())
+		}
+		return err
+	}
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *s3Server) deleteBucket(w http.ResponseWriter, bucket string) error {
+	if err := s.checkBucket(bucket); err != nil {
+		return err
+	}
+	if err := s.vol.Remove("/" + bucket); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// walk acrescenta a keys as chaves sob o diretório dir; root é o diretório do balde.
+func (s *s3Server) walk(root, dir string, keys *[]s3Key) error {
+	infos, err := s.vol.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(infos) == 0 && dir != root {
+		info, err := s.vol.Stat(dir)
+		if err != nil {
+			return err
+		}
+		*keys = append(*keys, s3Key{strings.TrimPrefix(dir, root+"/") + "/", dir, info})
+	}
+	for _, info := range infos {
+		p := joinPath(dir, info.name)
+		if info.dir {
+			if err := s.walk(root, p, keys); err != nil {
+				return err
+			}
+		} else {
+			*keys = append(*keys, s3Key{strings.TrimPrefix(p, root+"/"), p, info})
+		}
+	}
+	return nil
+}
+
+// listObjects implementa ListObjects e, com list-type=2, ListObjectsV2. Os arquivos são listados com a
+// ETag do conteúdo, e os diretórios vazios aparecem como chaves terminadas em "/".
+func (s *s3Server) listObjects(w http.ResponseWriter, r *http.Request, bucket string) error {
+	if err := s.checkBucket(bucket); err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	v2 := query.Get("list-type") == "2"
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	maxKeys := s3MaxKeys
+	if value := query.Get("max-keys"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return newS3Error(http.StatusBadRequest, "InvalidArgument", "max-keys inválido")
+		}
+		maxKeys = min(n, s3MaxKeys)
+	}
+	after := query.Get("marker")
+	if v2 {
+		after = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.StdEncoding.DecodeString(token)
+			if err != nil {
+				return newS3Error(http.StatusBadRequest, "InvalidArgument", "continuation-token inválido")
+			}
+			after = string(decoded)
+		}
+	}
+
+	// Só o diretório que contém todas as chaves com o prefixo é percorrido
+	root := "/" + bucket
+	start := root
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		start = normalizePath(root + "/" + prefix[:i])
+	}
+	var keys []s3Key
+	if info, err := s.vol.Stat(start); err == nil && info.dir && strings.HasPrefix(start, root) {
+		if err := s.walk(root, start, &keys); err != nil {
+			return err
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].key < keys[j].key })
+
+	encode := func(s string) string { return s }
+	if query.Get("encoding-type") == "url" {
+		encode = func(s string) string { return s3Escape(s, true) }
+	}
+	result := s3ListBucketResult{Xmlns: s3XMLNS, Name: bucket, Prefix: encode(prefix), MaxKeys: maxKeys, Delimiter: encode(delimiter)}
+	if query.Get("encoding-type") == "url" {
+		result.EncodingType = "url"
+	}
+	last, count := "", 0
+	for _, k := range keys {
+		if !strings.HasPrefix(k.key, prefix) {
+			continue
+		}
+		name := k.key
+		if delimiter != "" {
+			if i := strings.Index(k.key[len(prefix):], delimiter); i >= 0 {
+				name = k.key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if name <= after || name == last {
+			continue
+		}
+		if count == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		last = name
+		count++
+		if name != k.key {
+			result.CommonPrefixes = append(result.CommonPrefixes, s3Prefix{encode(name)})
+			continue
+		}
+		object := s3Object{Key: encode(k.key), LastModified: k.info.modTime.UTC().Format(s3ListedFormat), Size: k.info.size, StorageClass: "STANDARD", ETag: s3ETag(nil)}
+		if !k.info.dir {
+			data, err := s.vol.ReadFile(k.path)
+			if err != nil {
+				return err
+			}
+			object.ETag = s3ETag(data)
+		}
+		result.Contents = append(result.Contents, object)
+	}
+
+	if v2 {
+		result.KeyCount = &count
+		result.StartAfter = encode(query.Get("start-after"))
+		result.ContinuationToken = query.Get("continuation-token")
+		if result.IsTruncated {
+			result.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(last))
+		}
+	} else {
+		marker := encode(query.Get("marker"))
+		result.Marker = &marker
+		if result.IsTruncated {
+			result.NextMarker = encode(last)
+		}
+	}
+	writeS3XML(w, http.StatusOK, result)
+	return nil
+}
+
+// mkdirAll cria os diretórios que faltam até p.
+func (s *s3Server) mkdirAll(p string) error {
+	parts := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for i := range parts {
+		dir := "/" + strings.Join(parts[:i+1], "/")
+		info, err := s.vol.Stat(dir)
+		if errors.Is(err, iofs.ErrNotExist) {
+			err = s.vol.Mkdir(dir)
+		} else if err == nil && !info.dir {
+			err = errNotDir
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// store grava data como a chave key; uma chave terminada em "/" cria um diretório.
+func (s *s3Server) store(bucket, key string, data []byte) error {
+	if err := s.checkBucket(bucket); err != nil {
+		return err
+	}
+	p, err := s3ObjectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(key, "/") {
+		if len(data) > 0 {
+			return newS3Error(http.StatusBadRequest, "InvalidArgument", "uma chave terminada em / não pode ter conteúdo")
+		}
+		return s.mkdirAll(p)
+	}
+	dir, _ := splitPath(p)
+	if err := s.mkdirAll(dir); err != nil {
+		return err
+	}
+	return s.vol.WriteFile(p, bytes.NewReader(data))
+}
+
+func (s *s3Server) putObject(w http.ResponseWriter, r *http.Request, req *s3Request, bucket, key string) error {
+	data, err := s.body(r, req)
+	if err != nil {
+		return err
+	}
+	if err := s.store(bucket, key, data); err != nil {
+		return err
+	}
+	w.Header().Set("ETag", s3ETag(data))
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// copyObject implementa CopyObject; os metadados não são guardados, e a diretiva de metadados é ignorada.
+func (s *s3Server) copyObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	source, _, _ := strings.Cut(r.Header.Get("X-Amz-Copy-Source"), "?")
+	source, err := url.PathUnescape(source)
+	if err != nil {
+		return newS3Error(http.StatusBadRequest, "InvalidArgument", "x-amz-copy-source inválido")
+	}
+	sourceBucket, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if err := s.checkBucket(sourceBucket); err != nil {
+		return err
+	}
+	p, err := s3ObjectPath(sourceBucket, sourceKey)
+	if err != nil {
+		return err
+	}
+	data, err := s.vol.ReadFile(p)
+	if err != nil {
+		return s3ErrorFor(err, "NoSuchKey")
+	}
+	if err := s.store(bucket, key, data); err != nil {
+		return err
+	}
+	writeS3XML(w, http.StatusOK, s3CopyObjectResult{LastModified: time.Now().UTC().Format(s3ListedFormat), ETag: s3ETag(data)})
+	return nil
+}
+
+// getObject implementa GetObject e HeadObject, com intervalos (Range) e pedidos condicionais.
+func (s *s3Server) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	if err := s.checkBucket(bucket); err != nil {
+		return err
+	}
+	p, err := s3ObjectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	info, err := s.vol.Stat(p)
+	if err != nil || info.dir != strings.HasSuffix(key, "/") {
+		return newS3Error(http.StatusNotFound, "NoSuchKey", "a chave não existe")
+	}
+	var data []byte
+	if !info.dir {
+		if data, err = s.vol.ReadFile(p); err != nil {
+			return err
+		}
+	}
+	w.Header().Set("ETag", s3ETag(data))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", info.modTime, bytes.NewReader(data))
+	return nil
+}
+
+// deleteObject apaga a chave key; como no S3, apagar uma chave inexistente não é um erro. Uma chave
+// terminada em "/" só apaga um diretório vazio.
+func (s *s3Server) deleteObject(bucket, key string) error {
+	if err := s.checkBucket(bucket); err != nil {
+		return err
+	}
+	p, err := s3ObjectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	info, err := s.vol.Stat(p)
+	if err != nil || info.dir != strings.HasSuffix(key, "/") {
+		return nil
+	}
+	if err := s.vol.Remove(p); err != nil && !errors.Is(err, errNotEmpty) {
+		return s3ErrorFor(err, "NoSuchKey")
+	}
+	return nil
+}
+
+// deleteObjects implementa DeleteObjects (POST ?delete).
+func (s *s3Server) deleteObjects(w http.ResponseWriter, r *http.Request, req *s3Request, bucket string) error {
+	data, err := s.body(r, req)
+	if err != nil {
+		return err
+	}
+	var request s3Delete
+	if err := xml.Unmarshal(data, &request); err != nil {
+		return newS3Error(http.StatusBadRequest, "MalformedXML", "corpo de DeleteObjects inválido")
+	}
+	if err := s.checkBucket(bucket); err != nil {
+		return err
+	}
+	result := s3DeleteResult{Xmlns: s3XMLNS}
+	for _, object := range request.Objects {
+		if err := s.deleteObject(bucket, object.Key); err != nil {
+			e := s3ErrorFor(err, "NoSuchKey")
+			errors.As(err, &e)
+			result.Errors = append(result.Errors, s3DeleteError{object.Key, e.Code, e.Message})
+		} else if !request.Quiet {
+			result.Deleted = append(result.Deleted, s3Deleted{object.Key})
+		}
+	}
+	writeS3XML(w, http.StatusOK, result)
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// cliScript implementa "script <arquivo|->": lê um script do arquivo (ou da entrada padrão) e o executa.
+func cliScript(fs *FURGFileSystem, args []string) error {
+	if len(args) != 1 {
+		return classErrorf(ErrUsage, "uso: furgfs script <arquivo|->")
+	}
+
+	var content []byte
+	var err error
+	if args[0] == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao ler o script: %v", err)
+	}
+	return fs.RunScript(string(content))
+}
+
+// cliInlineScript implementa "-c \"comando; comando\"".
+func cliInlineScript(fs *FURGFileSystem, args []string) error {
+	if len(args) != 1 {
+		return classErrorf(ErrUsage, "uso: furgfs -c \"comando; comando; ...\"")
+	}
+	return fs.RunScript(args[0])
+}
+
+// RunScript executa em sequência os comandos do script, separados por quebras de linha ou ';', exibindo
+// o resultado de cada um na saída de erro padrão. Linhas iniciadas por '#' são comentários. Todos os comandos
+// são executados mesmo que algum falhe; nesse caso, é retornado um erro com o número de falhas.
+func (fs *FURGFileSystem) RunScript(script string) error {
+	return fs.runScript(script, nil)
+}
+
+// runScript executa o script como RunScript. Os comandos sem --force ou --override-protection usam a política
+// base, quando definida; assim um alias chamado com --force, ou pelo menu, repassa a confirmação aos seus comandos.
+func (fs *FURGFileSystem) runScript(script string, base ConfirmPolicy) error {
+	commands, err := parseScript(script)
+	if err != nil {
+		return err
+	}
+
+	failures := 0
+	var firstErr error
+	for i, stages := range commands {
+		line := stages.String()
+		err = fs.runPipeline(stages, base)
+		// "use" pode ter trocado a imagem atual
+		if current := openImages.currentImage(); current != nil {
+			fs = current
+		}
+		logger.Debug("script", "line", i+1, "command", line, "ok", err == nil)
+		if err != nil {
+			failures++
+			if firstErr == nil {
+				firstErr = err
+			}
+			fmt.Fprintf(os.Stderr, tr("[%d] falhou: %s: %v\n"), i+1, line, stderrColors.failure(err.Error()))
+		} else {
+			fmt.Fprintf(os.Stderr, tr("[%d] ok: %s\n"), i+1, line)
+		}
+	}
+
+	if failures > 0 {
+		// A categoria da primeira falha define o código de saída do script
+		return &classError{err: fmt.Errorf(tr("erro: %d de %d comando(s) falharam"), failures, len(commands)), class: firstErr}
+	}
+	return nil
+}
+
+// runCommand executa um único comando sobre fs. Sem --force ou --override-protection em args, vale a
+// política base, quando definida.
+func (fs *FURGFileSystem) runCommand(args []string, base ConfirmPolicy) error {
+	run := cliCommand(args[0])
+	if run == nil {
+		return classErrorf(ErrUsage, "erro: comando desconhecido '%s'", args[0])
+	}
+	rest, policy := extractPolicyFlags(args[1:])
+	fs.Policy = policy
+	if policy == (flagPolicy{}) && base != nil {
+		fs.Policy = base
+	}
+	saves := fs.saves
+	err := run(fs, rest)
+	fs.auditCommand(args[0], rest, saves, err)
+	return err
+}
+
+// pipeline são os comandos de uma linha ligados por '|': a saída padrão de cada um é a entrada do seguinte.
+type pipeline [][]string
+
+func (p pipeline) String() string {
+	stages := make([]string, len(p))
+	for i, args := range p {
+		stages[i] = strings.Join(args, " ")
+	}
+	return strings.Join(stages, " | ")
+}
+
+// parseScript divide o script em comandos, cada comando nos estágios ligados por '|' e cada estágio em
+// argumentos. Aspas simples ou duplas agrupam argumentos com espaços, ';' ou '|', e '#' no início de um
+// argumento inicia um comentário até o fim da linha.
+func parseScript(script string) ([]pipeline, error) {
+	var commands []pipeline
+	var stages pipeline
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	endArg := func() {
+		if inArg {
+			args = append(args, current.String())
+			current.Reset()
+			inArg = false
+		}
+	}
+	// endStage encerra o estágio atual; um estágio vazio só é aceito quando não há '|' antes dele
+	endStage := func() error {
+		endArg()
+		if len(args) == 0 {
+			if len(stages) > 0 {
+				return classErrorf(ErrUsage, "erro: comando vazio no pipeline")
+			}
+			return nil
+		}
+		stages = append(stages, args)
+		args = nil
+		return nil
+	}
+	endCommand := func() error {
+		if err := endStage(); err != nil {
+			return err
+		}
+		if len(stages) > 0 {
+			commands = append(commands, stages)
+			stages = nil
+		}
+		return nil
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		case c == '#' && !inArg:
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			if err := endCommand(); err != nil {
+				return nil, err
+			}
+		case c == ';' || c == '\n':
+			if err := endCommand(); err != nil {
+				return nil, err
+			}
+		case c == '|':
+			endArg()
+			if len(args) == 0 {
+				return nil, classErrorf(ErrUsage, "erro: comando vazio no pipeline")
+			}
+			if err := endStage(); err != nil {
+				return nil, err
+			}
+		case c == ' ' || c == '\t' || c == '\r':
+			endArg()
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, classErrorf(ErrUsage, "erro: aspas não fechadas no script")
+	}
+	if err := endCommand(); err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
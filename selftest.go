@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// selftestStep é uma etapa do autoteste. As etapas são executadas em ordem sobre a mesma imagem, e cada
+// uma depende do estado deixado pelas anteriores.
+type selftestStep struct {
+	name string
+	run  func(t *selftest) error
+}
+
+// selftest guarda o estado do autoteste: a imagem temporária e o conteúdo esperado de cada arquivo.
+type selftest struct {
+	image    string
+	fs       *FURGFileSystem
+	expected map[string][]byte // caminho interno -> conteúdo
+}
+
+// selftestSteps exercita as operações do FURGfs2 (diretórios, importação, exportação, renomeação, proteção,
+// cópia, movimentação e remoção), verificando o conteúdo e a consistência da imagem entre elas.
+var selftestSteps = []selftestStep{
+	{"criar a imagem", func(t *selftest) error {
+		fs, err := createFileSystemImage(t.image, 512, 256*1024, 32)
+		if err != nil {
+			return err
+		}
+		t.fs = fs
+		if err := fs.initVolume("selftest"); err != nil {
+			return err
+		}
+		return fs.saveFileSystemState()
+	}},
+	{"criar diretórios", func(t *selftest) error {
+		if err := t.fs.CreateDirectory("sub", "/docs", true); err != nil {
+			return err
+		}
+		if t.fs.CheckDirectoryExists("/docs") == -1 || t.fs.CheckDirectoryExists("/docs/sub") == -1 {
+			return errors.New("diretório criado não encontrado")
+		}
+		return nil
+	}},
+	{"importar arquivos", func(t *selftest) error {
+		random := rand.New(rand.NewSource(2))
+		files := map[string]int{"/docs/vazio.txt": 0, "/docs/pequeno.txt": 100, "/docs/sub/grande.bin": 20*512 + 7}
+		for path, size := range files {
+			content := make([]byte, size)
+			random.Read(content)
+			dir, name := splitPath(path)
+			if _, err := t.fs.createFile(dir, name, bytes.NewReader(content), false); err != nil {
+				return err
+			}
+			t.expected[path] = content
+		}
+		return t.checkContents()
+	}},
+	{"exportar arquivos", func(t *selftest) error {
+		for path, content := range t.expected {
+			entry, err := t.fs.internalFile(path)
+			if err != nil {
+				return err
+			}
+			var exported bytes.Buffer
+			if err := t.fs.exportFile(entry, &exported); err != nil {
+				return err
+			}
+			if !bytes.Equal(exported.Bytes(), content) {
+				return fmt.Errorf("conteúdo exportado de '%s' difere do importado", path)
+			}
+		}
+		return nil
+	}},
+	{"renomear", func(t *selftest) error {
+		if err := t.fs.RenameFileFromFileSystem("pequeno.txt", "/docs", "renomeado.txt"); err != nil {
+			return err
+		}
+		t.expected["/docs/renomeado.txt"] = t.expected["/docs/pequeno.txt"]
+		delete(t.expected, "/docs/pequeno.txt")
+		if t.fs.lookupEntry("pequeno.txt", "/docs") != -1 {
+			return errors.New("o nome antigo continua no diretório")
+		}
+		return t.checkContents()
+	}},
+	{"proteger e desproteger", func(t *selftest) error {
+		if err := t.fs.ChangePermission("renomeado.txt", "/docs", true, false); err != nil {
+			return err
+		}
+		err := t.fs.RemoveFileFromFileSystem("renomeado.txt", "/docs")
+		if !errors.Is(err, ErrProtected) {
+			return fmt.Errorf("a remoção de um arquivo protegido não foi impedida (%v)", err)
+		}
+		return t.fs.ChangePermission("renomeado.txt", "/docs", false, false)
+	}},
+	{"copiar diretório", func(t *selftest) error {
+		if err := t.fs.copyTreeTo(t.fs, "/", "docs", "/", "copia"); err != nil {
+			return err
+		}
+		copies := make(map[string][]byte)
+		for path, content := range t.expected {
+			copies["/copia"+path[len("/docs"):]] = content
+		}
+		for path, content := range copies {
+			t.expected[path] = content
+		}
+		return t.checkContents()
+	}},
+	{"mover diretório", func(t *selftest) error {
+		if err := t.fs.MoveEntry("/copia/sub", "/movido"); err != nil {
+			return err
+		}
+		t.expected["/movido/grande.bin"] = t.expected["/copia/sub/grande.bin"]
+		delete(t.expected, "/copia/sub/grande.bin")
+		return t.checkContents()
+	}},
+	{"remover arquivos e diretórios", func(t *selftest) error {
+		if err := t.fs.RemoveFileFromFileSystem("renomeado.txt", "/docs"); err != nil {
+			return err
+		}
+		delete(t.expected, "/docs/renomeado.txt")
+		if err := t.fs.removeInternalTree("/", "copia"); err != nil {
+			return err
+		}
+		delete(t.expected, "/copia/vazio.txt")
+		delete(t.expected, "/copia/renomeado.txt")
+		if err := t.fs.DeleteDirectory("sub", "/docs"); err == nil {
+			return errors.New("um diretório com conteúdo foi removido sem -r")
+		}
+		return t.checkContents()
+	}},
+	{"gravar e recarregar a imagem", func(t *selftest) error {
+		if err := t.fs.saveFileSystemState(); err != nil {
+			return err
+		}
+		t.fs.FilePointer.Close()
+		fs, err := loadFileSystem(t.image)
+		if err != nil {
+			return err
+		}
+		t.fs = fs
+		return t.checkContents()
+	}},
+	{"verificar a consistência (fsck)", func(t *selftest) error {
+		if found, _ := t.fs.CheckFileSystem(fsckCheckOnly); found > 0 {
+			return fmt.Errorf("%d problema(s) encontrado(s)", found)
+		}
+		return nil
+	}},
+}
+
+// checkContents confere que cada arquivo esperado existe com o conteúdo esperado e que não há outros arquivos.
+func (t *selftest) checkContents() error {
+	for path, content := range t.expected {
+		entry, err := t.fs.internalFile(path)
+		if err != nil {
+			return err
+		}
+		stored, err := t.fs.readFileContent(entry)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(stored, content) {
+			return fmt.Errorf("conteúdo de '%s' difere do esperado", path)
+		}
+	}
+	files := 0
+	for i := range t.fs.RootDir {
+		entry := &t.fs.RootDir[i]
+		if entry.Name[0] != 0 && !entry.IsDirectory && entry.PathString() != "/"+systemDirName {
+			files++
+		}
+	}
+	if files != len(t.expected) {
+		return fmt.Errorf("%d arquivo(s) na imagem, %d esperado(s)", files, len(t.expected))
+	}
+	return nil
+}
+
+// cliSelftest implementa "selftest [--keep]": cria uma imagem temporária, executa as etapas de selftestSteps
+// e informa o resultado de cada uma. A execução para na primeira falha. Com --keep, a imagem é mantida.
+func cliSelftest(imageName string, args []string) error {
+	flags := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	keep := flags.Bool("keep", false, "mantém a imagem temporária para inspeção")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 0 {
+		return classErrorf(ErrUsage, "uso: furgfs selftest [--keep]")
+	}
+
+	dir, err := os.MkdirTemp("", "furgfs-selftest-")
+	if err != nil {
+		return fmt.Errorf("erro ao criar o diretório temporário: %v", err)
+	}
+	t := &selftest{image: filepath.Join(dir, "selftest.fs2"), expected: make(map[string][]byte)}
+	defer func() {
+		if t.fs != nil {
+			t.fs.FilePointer.Close()
+		}
+		if *keep {
+			fmt.Printf(tr("Imagem mantida em %s\n"), t.image)
+		} else {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	for k, step := range selftestSteps {
+		// As mensagens das operações são descartadas; apenas o resultado de cada etapa é exibido
+		_, err := redirectStdio(nil, false, true, func() error {
+			return step.run(t)
+		})
+		if err != nil {
+			fmt.Printf("%s %s: %s\n", stdoutColors.failure(tr("FALHOU")), tr(step.name), err)
+			return fmt.Errorf(tr("erro: autoteste falhou na etapa %d de %d"), k+1, len(selftestSteps))
+		}
+		fmt.Printf("ok     %s\n", tr(step.name))
+	}
+	fmt.Printf(tr("Todas as %d etapas passaram.\n"), len(selftestSteps))
+	return nil
+}
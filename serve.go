@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// shareServer é um servidor de rede que atende um volume compartilhado; *http.Server já satisfaz a interface.
+type shareServer interface {
+	Serve(l net.Listener) error
+	Close() error
+}
+
+// serveProtocol descreve um protocolo de "serve". setup registra as opções próprias do protocolo em flags e
+// retorna a função que cria o servidor depois que as opções foram lidas.
+type serveProtocol struct {
+	defaultAddr string
+	setup       func(flags *flag.FlagSet) func(vol *sharedVolume) (shareServer, error)
+}
+
+var serveProtocols = map[string]serveProtocol{
+	"api":    {defaultAddr: ":8081", setup: setupAPI},
+	"grpc":   {defaultAddr: ":9090", setup: setupGRPC},
+	"http":   {defaultAddr: ":8000", setup: setupHTTP},
+	"nfs":    {defaultAddr: ":2049", setup: setupNFS},
+	"nbd":    {defaultAddr: ":10809", setup: setupNBD},
+	"s3":     {defaultAddr: ":9000", setup: setupS3},
+	"sftp":   {defaultAddr: ":2022", setup: setupSFTP},
+	"smb":    {defaultAddr: ":445", setup: setupSMB},
+	"webdav": {defaultAddr: ":8080", setup: setupWebDAV},
+}
+
+// serveUsers lê os usuários que podem entrar nos servidores que exigem autenticação.
+func serveUsers(vol *sharedVolume) (map[string]VolumeUser, error) {
+	var users map[string]VolumeUser
+	err := vol.with(func(fs *FURGFileSystem) error {
+		var err error
+		users, err = fs.Users()
+		return err
+	})
+	if err == nil && len(users) == 0 {
+		err = classErrorf(ErrUsage, "erro: nenhum usuário cadastrado; use furgfs user add <nome>")
+	}
+	return users, err
+}
+
+func serveProtocolNames() string {
+	names := make([]string, 0, len(serveProtocols))
+	for name := range serveProtocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
+
+// cliServe implementa "serve <protocolo> [--addr <endereço>] [opções] [imagem]": compartilha o volume pela
+// rede até ser interrompido com Ctrl+C.
+func cliServe(imageName string, args []string) error {
+	if len(args) == 0 {
+		return classErrorf(ErrUsage, "uso: furgfs serve <%s> [--addr <endereço>] [opções] [imagem]", serveProtocolNames())
+	}
+	protocol, ok := serveProtocols[args[0]]
+	if !ok {
+		return classErrorf(ErrUsage, "erro: protocolo '%s' desconhecido; use %s", args[0], serveProtocolNames())
+	}
+	flags := flag.NewFlagSet("serve "+args[0], flag.ContinueOnError)
+	addr := flags.String("addr", protocol.defaultAddr, "endereço em que o servidor aceita conexões")
+	archives := flags.Bool("archives", false, "mostra os arquivos zip e tar como diretórios somente leitura")
+	flags.DurationVar(&commitDelay, "commit-delay", commitDelay, "junta as alterações feitas nesse intervalo em uma só gravação dos metadados")
+	newServer := protocol.setup(flags)
+	if err := flags.Parse(args[1:]); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() > 1 {
+		return classErrorf(ErrUsage, "uso: furgfs serve <%s> [--addr <endereço>] [opções] [imagem]", serveProtocolNames())
+	}
+	if flags.NArg() == 1 {
+		var err error
+		if imageName, err = resolveImage(flags.Arg(0)); err != nil {
+			return err
+		}
+	}
+
+	fs, err := loadFileSystem(imageName)
+	if err != nil {
+		return err
+	}
+	defer fs.closeImage()
+	vol, err := newSharedVolume(fs)
+	if err != nil {
+		return err
+	}
+	defer vol.hooks.Close()
+	vol.hideSystem, vol.source, vol.archives = true, args[0], *archives
+	server, err := newServer(vol)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf(tr("erro ao escutar em '%s': %v"), *addr, err)
+	}
+	fmt.Printf(tr("Servindo '%s' por %s em %s; Ctrl+C para encerrar.\n"), imageName, args[0], listener.Addr())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		server.Close()
+	}()
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	if err := vol.flushBatch(); err != nil {
+		return err
+	}
+	fmt.Println(tr("Servidor encerrado."))
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// session guarda as imagens abertas ao mesmo tempo, cada uma identificada por um nome curto,
+// e qual delas é a atual, sobre a qual os comandos operam.
+type session struct {
+	images  map[string]*FURGFileSystem
+	current string
+}
+
+// openImages é a sessão do processo, compartilhada pelo menu interativo e pelos scripts.
+var openImages = &session{images: make(map[string]*FURGFileSystem)}
+
+// imageHandleName sugere um nome para a imagem path: o nome do arquivo sem a extensão.
+func imageHandleName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// add registra fs com o nome name (ou um nome derivado do arquivo, se name for vazio) e a torna atual
+// se ainda não houver imagem atual. Retorna o nome usado.
+func (s *session) add(name string, fs *FURGFileSystem) (string, error) {
+	if name == "" {
+		name = imageHandleName(fs.FilePointer.Name())
+	}
+	if _, exists := s.images[name]; exists {
+		return "", fmt.Errorf("erro: já existe uma imagem aberta com o nome '%s'", name)
+	}
+	for other, image := range s.images {
+		if sameFile(image.FilePointer.Name(), fs.FilePointer.Name()) {
+			return "", fmt.Errorf("erro: a imagem '%s' já está aberta como '%s'", fs.FilePointer.Name(), other)
+		}
+	}
+	s.images[name] = fs
+	if s.current == "" {
+		s.current = name
+	}
+	return name, nil
+}
+
+// open carrega a imagem path e a registra com o nome name.
+func (s *session) open(name, path string) (string, error) {
+	fs, err := loadFileSystem(path)
+	if err != nil {
+		return "", err
+	}
+	name, err = s.add(name, fs)
+	if err != nil {
+		fs.FilePointer.Close()
+		return "", err
+	}
+	logger.Info("imagem aberta", fs.imageAttr(), "name", name)
+	return name, nil
+}
+
+// use torna atual a imagem name.
+func (s *session) use(name string) (*FURGFileSystem, error) {
+	fs, ok := s.images[name]
+	if !ok {
+		return nil, classErrorf(ErrNotFound, "erro: nenhuma imagem aberta com o nome '%s'", name)
+	}
+	s.current = name
+	return fs, nil
+}
+
+// get retorna a imagem name.
+func (s *session) get(name string) (*FURGFileSystem, error) {
+	fs, ok := s.images[name]
+	if !ok {
+		return nil, classErrorf(ErrNotFound, "erro: nenhuma imagem aberta com o nome '%s'", name)
+	}
+	return fs, nil
+}
+
+// currentImage retorna a imagem atual, ou nil se nenhuma estiver aberta.
+func (s *session) currentImage() *FURGFileSystem {
+	return s.images[s.current]
+}
+
+// close salva e fecha a imagem name. A imagem atual não pode ser fechada.
+func (s *session) close(name string) error {
+	fs, ok := s.images[name]
+	if !ok {
+		return classErrorf(ErrNotFound, "erro: nenhuma imagem aberta com o nome '%s'", name)
+	}
+	if name == s.current {
+		return fmt.Errorf("erro: '%s' é a imagem atual; troque de imagem antes de fechá-la", name)
+	}
+	err := fs.saveFileSystemState()
+	if err != nil {
+		return err
+	}
+	delete(s.images, name)
+	logger.Info("imagem fechada", fs.imageAttr(), "name", name)
+	return fs.closeImage()
+}
+
+// closeAll fecha todas as imagens, salvando-as antes se save for verdadeiro. Retorna o primeiro erro.
+func (s *session) closeAll(save bool) error {
+	var firstErr error
+	for _, name := range s.names() {
+		fs := s.images[name]
+		if save {
+			if err := fs.saveFileSystemState(); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("erro ao salvar '%s': %w", name, err)
+			}
+		}
+		// O fechamento de uma imagem remota envia as escritas pendentes e pode falhar
+		if err := fs.closeImage(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("erro ao fechar '%s': %w", name, err)
+		}
+		delete(s.images, name)
+	}
+	s.current = ""
+	return firstErr
+}
+
+func (s *session) names() []string {
+	names := make([]string, 0, len(s.images))
+	for name := range s.images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// list exibe as imagens abertas, marcando a atual com '*'.
+func (s *session) list() {
+	for _, name := range s.names() {
+		marker := " "
+		if name == s.current {
+			marker = "*"
+		}
+		fs := s.images[name]
+		fmt.Printf("%s %-12s %s (%s)\n", marker, name, fs.FilePointer.Name(), fs.WorkingDir)
+	}
+}
+
+// sameFile informa se dois caminhos se referem ao mesmo arquivo.
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// cliOpen implementa "open <imagem> [nome]": abre outra imagem na sessão sem trocar a atual.
+func cliOpen(fs *FURGFileSystem, args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs open <imagem> [nome]")
+	}
+	name := ""
+	if len(args) == 2 {
+		name = args[1]
+	}
+	path, err := resolveImage(args[0])
+	if err != nil {
+		return err
+	}
+	name, err = openImages.open(name, path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imagem '%s' aberta como '%s'.\n", path, name)
+	return nil
+}
+
+// cliUse implementa "use <nome>": troca a imagem atual.
+func cliUse(fs *FURGFileSystem, args []string) error {
+	if len(args) != 1 {
+		return classErrorf(ErrUsage, "uso: furgfs use <nome>")
+	}
+	_, err := openImages.use(args[0])
+	return err
+}
+
+// cliClose implementa "close <nome>".
+func cliClose(fs *FURGFileSystem, args []string) error {
+	if len(args) != 1 {
+		return classErrorf(ErrUsage, "uso: furgfs close <nome>")
+	}
+	return openImages.close(args[0])
+}
+
+// cliImages implementa "images": lista as imagens abertas.
+func cliImages(fs *FURGFileSystem, args []string) error {
+	openImages.list()
+	return nil
+}
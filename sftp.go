@@ -0,0 +1,412 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// O servidor SFTP atende os clientes sftp e scp do OpenSSH com o servidor de pedidos de github.com/pkg/sftp,
+// sobre o transporte SSH de golang.org/x/crypto/ssh; os pedidos vão para o sharedVolume pelos handlers de
+// sftpHandlers. Os usuários são os cadastrados com "furgfs user"; a chave do servidor fica em
+// /.furgfs/ssh_host_key, a menos que --host-key seja usado.
+const sftpHostKeyName = "ssh_host_key"
+
+// sftpServer aceita conexões SSH e atende o subsistema sftp de cada sessão.
+type sftpServer struct {
+	vol    *sharedVolume
+	config *ssh.ServerConfig
+	users  map[string]VolumeUser
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]bool
+}
+
+// setupSFTP prepara "serve sftp". Os usuários são lidos uma vez, ao iniciar o servidor.
+func setupSFTP(flags *flag.FlagSet) func(vol *sharedVolume) (shareServer, error) {
+	hostKeyFile := flags.String("host-key", "", "chave privada do servidor no formato do OpenSSH (padrão: gerada e guardada no volume)")
+	return func(vol *sharedVolume) (shareServer, error) {
+		server := &sftpServer{vol: vol, conns: make(map[net.Conn]bool)}
+		var signer ssh.Signer
+		var err error
+		if server.users, err = serveUsers(vol); err != nil {
+			return nil, err
+		}
+		err = vol.with(func(fs *FURGFileSystem) error {
+			var err error
+			if *hostKeyFile != "" {
+				data, err := os.ReadFile(*hostKeyFile)
+				if err != nil {
+					return fmt.Errorf("erro ao ler '%s': %v", *hostKeyFile, err)
+				}
+				signer, err = ssh.ParsePrivateKey(data)
+				return err
+			}
+			signer, err = fs.sftpHostKey()
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		server.config = &ssh.ServerConfig{
+			PasswordCallback:  server.checkPassword,
+			PublicKeyCallback: server.checkKey,
+		}
+		server.config.AddHostKey(signer)
+		fmt.Printf(tr("Chave do servidor: %s\n"), ssh.FingerprintSHA256(signer.PublicKey()))
+		return server, nil
+	}
+}
+
+// sftpHostKey lê a chave do servidor guardada no volume, gerando uma chave Ed25519 na primeira vez.
+func (fs *FURGFileSystem) sftpHostKey() (ssh.Signer, error) {
+	data, err := fs.readSystemFile(sftpHostKeyName)
+	if errors.Is(err, os.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao gerar a chave do servidor: %v", err)
+		}
+		block, err := ssh.MarshalPrivateKey(key, "furgfs")
+		if err != nil {
+			return nil, err
+		}
+		data = pem.EncodeToMemory(block)
+		if err = fs.writeSystemFile(sftpHostKeyName, data); err != nil {
+			return nil, err
+		}
+		if err = fs.saveFileSystemState(); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, classErrorf(ErrCorrupted, "erro ao ler a chave do servidor: %v", err)
+	}
+	return signer, nil
+}
+
+func (s *sftpServer) permissions(name string) *ssh.Permissions {
+	return &ssh.Permissions{Extensions: map[string]string{"user": name}}
+}
+
+func (s *sftpServer) checkPassword(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	user, ok := s.users[conn.User()]
+	if !ok || !user.CheckPassword(string(password)) {
+		logger.Warn("sftp: senha recusada", "user", conn.User(), "remote", conn.RemoteAddr().String())
+		return nil, errors.New("senha recusada")
+	}
+	return s.permissions(conn.User()), nil
+}
+
+func (s *sftpServer) checkKey(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	user, ok := s.users[conn.User()]
+	if ok {
+		for _, line := range user.Keys {
+			authorized, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+			if err == nil && string(authorized.Marshal()) == string(key.Marshal()) {
+				return s.permissions(conn.User()), nil
+			}
+		}
+	}
+	return nil, errors.New("chave recusada")
+}
+
+// Serve aceita conexões até Close.
+func (s *sftpServer) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		go func() {
+			s.handleConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close encerra o servidor e todas as conexões abertas.
+func (s *sftpServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func (s *sftpServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+	sconn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		logger.Info("sftp: conexão recusada", "remote", conn.RemoteAddr().String(), "error", err)
+		return
+	}
+	name := sconn.Permissions.Extensions["user"]
+	logger.Info("sftp: conexão", "user", name, "remote", conn.RemoteAddr().String())
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "apenas sessões são aceitas")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && len(req.Payload) >= 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					h := &sftpHandlers{vol: s.vol.as(name), readOnly: s.users[name].ReadOnly, writers: make(map[string]*sharedFile)}
+					go func() {
+						// Os arquivos que o cliente deixou abertos são fechados, gravando o conteúdo alterado
+						server := sftp.NewRequestServer(channel, sftp.Handlers{FileGet: h, FilePut: h, FileCmd: h, FileList: h})
+						status := uint32(0)
+						if err := server.Serve(); err != nil && err != io.EOF {
+							logger.Warn("sftp: sessão encerrada", "user", name, "error", err)
+							status = 1
+						}
+						// Sem exit-status, o scp do OpenSSH termina com erro mesmo depois de copiar tudo
+						channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+						server.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// sftpHandlers atende os pedidos de um cliente SFTP sobre o volume, com as permissões do seu usuário.
+type sftpHandlers struct {
+	vol      *sharedVolume
+	readOnly bool
+
+	mu      sync.Mutex
+	writers map[string]*sharedFile // arquivos abertos para escrita, pelo caminho, que recebem o SETSTAT de tamanho
+}
+
+// sftpError dá a err o código de status que o cliente deve receber, mantendo a mensagem.
+type sftpError struct {
+	err  error
+	code error
+}
+
+func (e sftpError) Error() string   { return e.err.Error() }
+func (e sftpError) Unwrap() []error { return []error{e.err, e.code} }
+
+// sftpStatus converte os erros do volume nos códigos de SSH_FXP_STATUS correspondentes.
+func sftpStatus(err error) error {
+	switch {
+	case err == nil, err == io.EOF:
+		return err
+	case errors.Is(err, iofs.ErrNotExist), errors.Is(err, ErrNotFound):
+		return sftpError{err, sftp.ErrSSHFxNoSuchFile}
+	case errors.Is(err, iofs.ErrPermission), errors.Is(err, ErrProtected):
+		return sftpError{err, sftp.ErrSSHFxPermissionDenied}
+	}
+	return err
+}
+
+// sftpFile é um arquivo aberto por um cliente. Ao ser fechado, grava o conteúdo alterado e deixa de
+// receber o SETSTAT de tamanho.
+type sftpFile struct {
+	*sharedFile
+	h *sftpHandlers
+}
+
+func (f sftpFile) Close() error {
+	f.h.mu.Lock()
+	if f.h.writers[f.path] == f.sharedFile {
+		delete(f.h.writers, f.path)
+	}
+	f.h.mu.Unlock()
+	return sftpStatus(f.sharedFile.Close())
+}
+
+// open abre o arquivo do pedido com as flags de SSH_FXP_OPEN.
+func (h *sftpHandlers) open(r *sftp.Request) (sftpFile, error) {
+	pflags := r.Pflags()
+	flag := os.O_RDONLY
+	switch {
+	case pflags.Read && pflags.Write:
+		flag = os.O_RDWR
+	case pflags.Write:
+		flag = os.O_WRONLY
+	}
+	if pflags.Append {
+		flag |= os.O_APPEND
+	}
+	if pflags.Creat {
+		flag |= os.O_CREATE
+	}
+	if pflags.Trunc {
+		flag |= os.O_TRUNC
+	}
+	if pflags.Excl {
+		flag |= os.O_EXCL
+	}
+	if h.readOnly && flag != os.O_RDONLY {
+		return sftpFile{}, sftpStatus(iofs.ErrPermission)
+	}
+	f, err := h.vol.OpenFile(r.Filepath, flag)
+	if err != nil {
+		return sftpFile{}, sftpStatus(err)
+	}
+	if f.writable {
+		h.mu.Lock()
+		h.writers[f.path] = f
+		h.mu.Unlock()
+	}
+	return sftpFile{f, h}, nil
+}
+
+func (h *sftpHandlers) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	return h.open(r)
+}
+
+func (h *sftpHandlers) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	return h.open(r)
+}
+
+func (h *sftpHandlers) OpenFile(r *sftp.Request) (sftp.WriterAtReaderAt, error) {
+	return h.open(r)
+}
+
+// Filecmd atende os pedidos que alteram o volume sem abrir um arquivo.
+func (h *sftpHandlers) Filecmd(r *sftp.Request) error {
+	if h.readOnly {
+		return sftpStatus(iofs.ErrPermission)
+	}
+	return sftpStatus(h.command(r))
+}
+
+func (h *sftpHandlers) command(r *sftp.Request) error {
+	switch r.Method {
+	case "Setstat":
+		return h.setstat(r)
+	case "Remove", "Rmdir":
+		info, err := h.vol.Stat(r.Filepath)
+		switch {
+		case err != nil:
+			return err
+		case info.dir && r.Method == "Remove":
+			return errIsDirectory
+		case !info.dir && r.Method == "Rmdir":
+			return errNotDir
+		}
+		return h.vol.Remove(r.Filepath)
+	case "Mkdir":
+		return h.vol.Mkdir(r.Filepath)
+	case "Rename":
+		// Na versão 3, o destino não pode existir; posix-rename@openssh.com o substitui
+		if _, err := h.vol.Stat(r.Target); err == nil {
+			return iofs.ErrExist
+		}
+		return h.vol.Rename(r.Filepath, r.Target)
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+// PosixRename atende posix-rename@openssh.com, que substitui o destino.
+func (h *sftpHandlers) PosixRename(r *sftp.Request) error {
+	if h.readOnly {
+		return sftpStatus(iofs.ErrPermission)
+	}
+	return sftpStatus(h.vol.Rename(r.Filepath, r.Target))
+}
+
+// setstat aplica o tamanho e as permissões de SETSTAT e FSETSTAT; os outros atributos são ignorados. Um
+// arquivo aberto para escrita tem o conteúdo em memória, e é nele que o tamanho muda.
+func (h *sftpHandlers) setstat(r *sftp.Request) error {
+	attrs, flags := r.Attributes(), r.AttrFlags()
+	if flags.Size {
+		h.mu.Lock()
+		f := h.writers[normalizePath(r.Filepath)]
+		h.mu.Unlock()
+		var err error
+		if f != nil {
+			err = f.Truncate(int64(attrs.Size))
+		} else {
+			err = h.vol.Truncate(r.Filepath, int64(attrs.Size))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if flags.Permissions {
+		info, err := h.vol.Stat(r.Filepath)
+		if err != nil {
+			return err
+		}
+		if !info.dir {
+			return h.vol.SetProtected(r.Filepath, attrs.Mode&0200 == 0)
+		}
+	}
+	return nil
+}
+
+// sftpListing é o resultado de uma listagem ou de um STAT, entregue ao cliente em partes.
+type sftpListing []os.FileInfo
+
+func (l sftpListing) ListAt(dst []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(dst, l[offset:])
+	if n < len(dst) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Filelist atende as listagens de diretório e os pedidos de STAT e LSTAT.
+func (h *sftpHandlers) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	switch r.Method {
+	case "List":
+		infos, err := h.vol.ReadDir(r.Filepath)
+		if err != nil {
+			return nil, sftpStatus(err)
+		}
+		list := make(sftpListing, len(infos))
+		for i, info := range infos {
+			list[i] = info
+		}
+		return list, nil
+	case "Stat":
+		info, err := h.vol.Stat(r.Filepath)
+		if err != nil {
+			return nil, sftpStatus(err)
+		}
+		return sftpListing{info}, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
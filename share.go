@@ -0,0 +1,823 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// sharedVolume dá acesso a um volume aberto para vários clientes ao mesmo tempo, como o ponto de montagem
+// FUSE e os servidores de rede. Cada operação recebe um caminho absoluto, é executada com o volume bloqueado
+// e, se alterar o volume, grava o estado em seguida. Como o FURGfs2 só grava arquivos inteiros, as escritas
+// substituem o conteúdo completo do arquivo. As alterações são registradas na auditoria em nome de user.
+type sharedVolume struct {
+	*volumeState
+	user string // usuário autenticado que faz as operações (veja as); vazio nos servidores sem usuários
+
+	// fs é o volume em que as operações desta visão são feitas e encobre volumeState.fs: o próprio volume
+	// ou, numa leitura, a cópia de reading (veja readview.go).
+	fs *FURGFileSystem
+}
+
+// volumeState é o estado de um sharedVolume, comum às visões de todos os usuários.
+type volumeState struct {
+	mu sync.RWMutex // compartilhado pelas leituras (veja reading) e exclusivo nas alterações
+	fs *FURGFileSystem
+
+	started  time.Time
+	modified map[string]time.Time // caminhos alterados nesta sessão; o FURGfs2 não registra datas
+
+	// hideSystem esconde o diretório de sistema, que guarda as senhas e a chave do servidor SFTP; os
+	// servidores de rede o usam.
+	hideSystem bool
+	source     string           // origem das operações na auditoria: mount ou o protocolo de "serve"
+	hooks      *webhookNotifier // envio das alterações aos webhooks; nil sem webhooks
+
+	// archives mostra os arquivos zip e tar como diretórios somente leitura (veja archivefs.go)
+	archives     bool
+	archiveCache map[string]*archiveIndex
+
+	uploadStore *uploadStore // envios retomáveis da API REST e do gRPC (veja resumable.go); criado no primeiro uso
+
+	// commitTimer grava o lote de alterações em aberto, com batched operações (veja groupcommit.go)
+	commitTimer *time.Timer
+	batched     int
+}
+
+// errNotEmpty, errIsDirectory e errNotDir complementam os erros de io/fs para as operações de sharedVolume.
+var (
+	errNotEmpty    = syscall.ENOTEMPTY
+	errIsDirectory = syscall.EISDIR
+	errNotDir      = syscall.ENOTDIR
+)
+
+// newSharedVolume prepara fs para ser compartilhado. Em um volume cifrado, a senha é pedida aqui, antes de
+// o primeiro cliente se conectar.
+func newSharedVolume(fs *FURGFileSystem) (*sharedVolume, error) {
+	if _, err := fs.volumeCipher(); err != nil {
+		return nil, err
+	}
+	hooks, err := newWebhookNotifier(fs)
+	if err != nil {
+		return nil, err
+	}
+	fs.WorkingDir = ""
+	state := &volumeState{fs: fs, started: time.Now(), modified: make(map[string]time.Time), hooks: hooks}
+	return &sharedVolume{volumeState: state, fs: fs}, nil
+}
+
+// as retorna uma visão do volume cujas operações são feitas em nome de user.
+func (v *sharedVolume) as(user string) *sharedVolume {
+	return &sharedVolume{volumeState: v.volumeState, user: user, fs: v.volumeState.fs}
+}
+
+// volumeUserKey guarda no contexto de um pedido o usuário autenticado por um servidor (veja withVolumeUser).
+type volumeUserKey struct{}
+
+// withVolumeUser anota em ctx o usuário autenticado do pedido.
+func withVolumeUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, volumeUserKey{}, user)
+}
+
+// userVolume retorna a visão do volume do usuário anotado em ctx por withVolumeUser.
+func (v *sharedVolume) userVolume(ctx context.Context) *sharedVolume {
+	user, _ := ctx.Value(volumeUserKey{}).(string)
+	return v.as(user)
+}
+
+// audit registra na auditoria a operação op sobre p (e target, numa renomeação), com o resultado *err.
+// É chamada por defer, com o volume bloqueado.
+func (v *sharedVolume) audit(op, p, target string, err *error) {
+	v.fs.recordAudit(auditRecord{User: v.user, Source: v.source, Op: op, Path: p, Target: target}, *err)
+}
+
+// entryInfo descreve uma entrada do volume como um io/fs.FileInfo. Arquivos protegidos não têm permissão
+// de escrita.
+type entryInfo struct {
+	name      string
+	size      int64
+	dir       bool
+	protected bool
+	modTime   time.Time
+}
+
+func (i entryInfo) Name() string       { return i.name }
+func (i entryInfo) Size() int64        { return i.size }
+func (i entryInfo) ModTime() time.Time { return i.modTime }
+func (i entryInfo) IsDir() bool        { return i.dir }
+func (i entryInfo) Sys() any           { return nil }
+
+func (i entryInfo) Mode() iofs.FileMode {
+	mode := iofs.FileMode(0644)
+	if i.dir {
+		mode = iofs.ModeDir | 0755
+	}
+	if i.protected {
+		mode &^= 0222
+	}
+	return mode
+}
+
+// info monta o entryInfo da entrada de índice index, ou da raiz com index -1.
+func (v *sharedVolume) info(p string, index int) entryInfo {
+	modTime, ok := v.modified[p]
+	if !ok {
+		modTime = v.started
+	}
+	if index == -1 {
+		return entryInfo{name: "/", dir: true, modTime: modTime}
+	}
+	entry := &v.fs.RootDir[index]
+	if v.archives && !entry.IsDirectory && isArchiveName(entry.NameString()) {
+		return entryInfo{name: entry.NameString(), dir: true, protected: true, modTime: modTime}
+	}
+	return entryInfo{name: entry.NameString(), size: int64(entry.Size), dir: entry.IsDirectory, protected: entry.Protected, modTime: modTime}
+}
+
+// hidden informa se p está no diretório de sistema escondido.
+func (v *sharedVolume) hidden(p string) bool {
+	return v.hideSystem && (p == systemDir || strings.HasPrefix(p, systemDir+"/"))
+}
+
+// lookup retorna o índice da entrada p, ou -1 para a raiz.
+func (v *sharedVolume) lookup(p string) (int, error) {
+	p = normalizePath(p)
+	if p == "/" {
+		return -1, nil
+	}
+	if v.hidden(p) {
+		return -1, iofs.ErrNotExist
+	}
+	dir, name := splitPath(p)
+	index := v.fs.lookupEntry(name, dir)
+	if index == -1 {
+		return -1, iofs.ErrNotExist
+	}
+	return index, nil
+}
+
+// touch registra a alteração de p e de seu diretório pai.
+func (v *sharedVolume) touch(p string) {
+	now := time.Now()
+	v.modified[p] = now
+	dir, _ := splitPath(p)
+	v.modified[dir] = now
+}
+
+// save grava o estado do volume depois de uma alteração, ou a junta ao lote em aberto (veja commit), e, se
+// der certo, envia o evento sobre p aos webhooks; um evento vazio não é enviado.
+func (v *sharedVolume) save(event, p string, err error) error {
+	if err != nil {
+		return err
+	}
+	v.touch(p)
+	if err := v.commit(); err != nil {
+		return err
+	}
+	if event != "" {
+		v.notify(event, p)
+	}
+	return nil
+}
+
+// notify envia aos webhooks o evento sobre p. Em create e modify, o tipo e o tamanho vêm da entrada.
+func (v *sharedVolume) notify(event, p string) {
+	if v.hooks == nil {
+		return
+	}
+	e := webhookEvent{Event: event, Path: p, User: v.user, Source: v.source}
+	if index, err := v.lookup(p); event != "delete" && err == nil && index != -1 {
+		entry := &v.fs.RootDir[index]
+		e.Type, e.Size = "file", int64(entry.Size)
+		if entry.IsDirectory {
+			e.Type, e.Size = "directory", 0
+		}
+	}
+	v.hooks.notify(e)
+}
+
+// Stat descreve a entrada p.
+func (v *sharedVolume) Stat(p string) (entryInfo, error) {
+	v, done := v.reading()
+	defer done()
+	p = normalizePath(p)
+	if archive, inner, ok := v.archiveOf(p); ok && inner != "" {
+		return v.archiveStat(archive, inner)
+	}
+	index, err := v.lookup(p)
+	if err != nil {
+		return entryInfo{}, err
+	}
+	return v.info(p, index), nil
+}
+
+// ReadDir lista o diretório p, em ordem de nome.
+func (v *sharedVolume) ReadDir(p string) ([]entryInfo, error) {
+	v, done := v.reading()
+	defer done()
+	p = normalizePath(p)
+	if archive, inner, ok := v.archiveOf(p); ok {
+		return v.archiveReadDir(archive, inner)
+	}
+	index, err := v.lookup(p)
+	if err != nil {
+		return nil, err
+	}
+	if index != -1 && !v.fs.RootDir[index].IsDirectory {
+		return nil, errNotDir
+	}
+	var infos []entryInfo
+	for _, i := range v.fs.entriesInDirectory(p, false) {
+		if child := joinPath(p, v.fs.RootDir[i].NameString()); !v.hidden(child) {
+			infos = append(infos, v.info(child, i))
+		}
+	}
+	sort.Slice(infos, func(a, b int) bool { return infos[a].name < infos[b].name })
+	return infos, nil
+}
+
+// ReadAt lê o conteúdo do arquivo p a partir de off, como io.ReaderAt.
+func (v *sharedVolume) ReadAt(p string, buf []byte, off int64) (int, error) {
+	v, done := v.reading()
+	defer done()
+	p = normalizePath(p)
+	if archive, inner, ok := v.archiveOf(p); ok {
+		return v.archiveReadAt(archive, inner, buf, off)
+	}
+	index, err := v.lookup(p)
+	if err != nil {
+		return 0, err
+	}
+	if index == -1 || v.fs.RootDir[index].IsDirectory {
+		return 0, errIsDirectory
+	}
+	r, err := v.fs.newFileReaderAt(&v.fs.RootDir[index])
+	if err != nil {
+		return 0, err
+	}
+	defer r.release()
+	return r.ReadAt(buf, off)
+}
+
+// ReadFile lê todo o conteúdo do arquivo p.
+func (v *sharedVolume) ReadFile(p string) ([]byte, error) {
+	v, done := v.reading()
+	defer done()
+	p = normalizePath(p)
+	if archive, inner, ok := v.archiveOf(p); ok {
+		info, err := v.archiveStat(archive, inner)
+		if err != nil {
+			return nil, err
+		}
+		content := make([]byte, info.size)
+		if _, err := v.archiveReadAt(archive, inner, content, 0); err != nil && err != io.EOF {
+			return nil, err
+		}
+		return content, nil
+	}
+	index, err := v.lookup(p)
+	if err != nil {
+		return nil, err
+	}
+	if index == -1 || v.fs.RootDir[index].IsDirectory {
+		return nil, errIsDirectory
+	}
+	return v.fs.readFileContent(&v.fs.RootDir[index])
+}
+
+// WriteFile cria o arquivo p ou substitui seu conteúdo pelo lido de r. O conteúdo antigo só é descartado
+// depois que o novo foi gravado.
+func (v *sharedVolume) WriteFile(p string, r io.Reader) (err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	p = normalizePath(p)
+	defer v.audit("write", p, "", &err)
+	old, err := v.writeTarget(p)
+	if err != nil {
+		return err
+	}
+	dir, name := splitPath(p)
+	var entry FileEntry
+	copy(entry.Name[:], name)
+	copy(entry.Path[:], dir)
+	_, err = v.fs.storeUserFile(entry, r, v.user)
+	if err == nil && old != -1 {
+		v.fs.discardEntries([]int{old})
+	}
+	event := "modify"
+	if old == -1 {
+		event = "create"
+	}
+	return v.save(event, p, err)
+}
+
+// writeTarget confere se o arquivo p pode ser criado ou ter o conteúdo substituído e retorna o índice da
+// entrada atual, ou -1 se ela não existir. O volume já deve estar bloqueado.
+func (v *sharedVolume) writeTarget(p string) (int, error) {
+	if err := v.checkWritable(p); err != nil {
+		return -1, err
+	}
+	old, err := v.lookup(p)
+	if err != nil && !errors.Is(err, iofs.ErrNotExist) {
+		return -1, err
+	}
+	if err == nil {
+		if old == -1 || v.fs.RootDir[old].IsDirectory {
+			return -1, errIsDirectory
+		}
+		if v.fs.RootDir[old].Protected {
+			return -1, iofs.ErrPermission
+		}
+	}
+	dir, name := splitPath(p)
+	if v.hidden(p) || v.fs.CheckDirectoryExists(dir) == -1 {
+		return -1, iofs.ErrNotExist
+	}
+	if err := v.fs.Rules.ValidateEntry(dir, name); err != nil {
+		return -1, err
+	}
+	return old, nil
+}
+
+// Truncate muda o tamanho do arquivo p, completando com zeros.
+func (v *sharedVolume) Truncate(p string, size int64) error {
+	content, err := v.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	if size <= int64(len(content)) {
+		content = content[:size]
+	} else {
+		content = append(content, make([]byte, size-int64(len(content)))...)
+	}
+	return v.WriteFile(p, bytes.NewReader(content))
+}
+
+// Mkdir cria o diretório p.
+func (v *sharedVolume) Mkdir(p string) (err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	p = normalizePath(p)
+	defer v.audit("mkdir", p, "", &err)
+	if err := v.checkWritable(p); err != nil {
+		return err
+	}
+	if _, err := v.lookup(p); err == nil {
+		return iofs.ErrExist
+	}
+	dir, name := splitPath(p)
+	if v.hidden(p) || v.fs.CheckDirectoryExists(dir) == -1 {
+		return iofs.ErrNotExist
+	}
+	return v.save("create", p, v.fs.CreateDirectory(name, dir, false))
+}
+
+// Remove apaga o arquivo ou o diretório vazio p. Arquivos protegidos não são apagados.
+func (v *sharedVolume) Remove(p string) (err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	p = normalizePath(p)
+	defer v.audit("remove", p, "", &err)
+	if err := v.checkWritable(p); err != nil {
+		return err
+	}
+	index, err := v.lookup(p)
+	if err != nil {
+		return err
+	}
+	if index == -1 {
+		return iofs.ErrPermission
+	}
+	return v.save("delete", p, v.removeEntry(p, index))
+}
+
+func (v *sharedVolume) removeEntry(p string, index int) error {
+	if err := v.checkRemovable(p, index); err != nil {
+		return err
+	}
+	v.fs.discardEntries([]int{index})
+	return nil
+}
+
+// checkRemovable diz se a entrada index, em p, pode ser apagada: não pode estar protegida nem, se for um
+// diretório, ter conteúdo.
+func (v *sharedVolume) checkRemovable(p string, index int) error {
+	entry := &v.fs.RootDir[index]
+	if entry.Protected {
+		return iofs.ErrPermission
+	}
+	if entry.IsDirectory && len(v.fs.entriesInDirectory(p, false)) > 0 {
+		return errNotEmpty
+	}
+	return nil
+}
+
+// RemoveAll apaga p e, se for um diretório, todo o seu conteúdo. Para no primeiro arquivo protegido.
+func (v *sharedVolume) RemoveAll(p string) (err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	p = normalizePath(p)
+	defer v.audit("remove-all", p, "", &err)
+	if err := v.checkWritable(p); err != nil {
+		return err
+	}
+	index, err := v.lookup(p)
+	if err != nil {
+		return err
+	}
+	if index == -1 {
+		return iofs.ErrPermission
+	}
+	var remove func(p string, index int) error
+	remove = func(p string, index int) error {
+		if v.fs.RootDir[index].IsDirectory {
+			for _, i := range v.fs.entriesInDirectory(p, false) {
+				if err := remove(joinPath(p, v.fs.RootDir[i].NameString()), i); err != nil {
+					return err
+				}
+			}
+		}
+		return v.removeEntry(p, index)
+	}
+	return v.save("delete", p, remove(p, index))
+}
+
+// Rename move oldPath para newPath. Um arquivo já existente em newPath é substituído, assim como um
+// diretório vazio, se oldPath também for um diretório. A entrada substituída sai da tabela antes da
+// movimentação, mas seus blocos só são liberados depois que ela deu certo; se MoveEntry falhar, ela volta
+// ao diretório como estava.
+func (v *sharedVolume) Rename(oldPath, newPath string) (err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	oldPath, newPath = normalizePath(oldPath), normalizePath(newPath)
+	defer v.audit("rename", oldPath, newPath, &err)
+	if err := v.checkWritable(oldPath, newPath); err != nil {
+		return err
+	}
+	index, err := v.lookup(oldPath)
+	if err != nil {
+		return err
+	}
+	if index == -1 || v.hidden(newPath) {
+		return iofs.ErrPermission
+	}
+	target, err := v.lookup(newPath)
+	var replaced *FileEntry
+	if err == nil && newPath != oldPath {
+		if target == -1 || v.fs.RootDir[target].IsDirectory != v.fs.RootDir[index].IsDirectory {
+			return iofs.ErrExist
+		}
+		if err := v.checkRemovable(newPath, target); err != nil {
+			return err
+		}
+		if v.fs.isCASFile(&v.fs.RootDir[target]) {
+			// A contagem de referências dos blocos precisa incluir a entrada substituída, que vai sair da tabela
+			if _, err := v.fs.casBlockIndex(); err != nil {
+				return err
+			}
+		}
+		saved := v.fs.RootDir[target]
+		replaced = &saved
+		v.fs.clearEntry(target)
+	}
+	err = v.fs.MoveEntry(oldPath, newPath)
+	if replaced != nil {
+		if err != nil {
+			// A posição liberada pode ter sido ocupada por um arquivo de sistema gravado por MoveEntry
+			err = cmp.Or(v.fs.AddFileEntry(replaced), err)
+		} else {
+			v.fs.releaseBlocks(replaced)
+		}
+	}
+	if err == nil {
+		v.touch(oldPath)
+	}
+	if err = v.save("", newPath, err); err == nil {
+		v.notify("delete", oldPath)
+		v.notify("create", newPath)
+	}
+	return err
+}
+
+// SetProtected liga ou desliga a proteção do arquivo p.
+func (v *sharedVolume) SetProtected(p string, protected bool) (err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	p = normalizePath(p)
+	op := "unprotect"
+	if protected {
+		op = "protect"
+	}
+	defer v.audit(op, p, "", &err)
+	if err := v.checkWritable(p); err != nil {
+		return err
+	}
+	index, err := v.lookup(p)
+	if err != nil {
+		return err
+	}
+	if index == -1 || v.fs.RootDir[index].IsDirectory {
+		return errIsDirectory
+	}
+	if v.fs.RootDir[index].Protected == protected {
+		return nil
+	}
+	v.fs.setProtected(index, protected)
+	return v.save("modify", p, nil)
+}
+
+// sharedFile é um arquivo ou diretório aberto em um sharedVolume, usado pelos servidores de rede. A leitura
+// de um arquivo aberto só para leitura é feita direto no volume; um arquivo aberto para escrita é mantido em
+// memória e gravado por inteiro em Close.
+type sharedFile struct {
+	vol  *sharedVolume
+	path string
+
+	mu       sync.Mutex
+	off      int64
+	writable bool
+	append   bool
+	buf      []byte
+	dirty    bool
+	entries  []entryInfo // conteúdo de um diretório, lido na primeira listagem
+	listed   bool
+}
+
+// OpenFile abre p com as flags de os.OpenFile. Com os.O_CREATE, um arquivo inexistente é criado vazio.
+func (v *sharedVolume) OpenFile(p string, flag int) (*sharedFile, error) {
+	p = normalizePath(p)
+	info, err := v.Stat(p)
+	switch {
+	case errors.Is(err, iofs.ErrNotExist) && flag&os.O_CREATE != 0:
+		if err = v.WriteFile(p, bytes.NewReader(nil)); err != nil {
+			return nil, err
+		}
+		info = entryInfo{}
+	case err != nil:
+		return nil, err
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, iofs.ErrExist
+	}
+
+	f := &sharedFile{vol: v, path: p}
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return f, nil
+	}
+	if info.dir {
+		return nil, errIsDirectory
+	}
+	if info.protected {
+		return nil, iofs.ErrPermission
+	}
+	f.writable, f.append = true, flag&os.O_APPEND != 0
+	if flag&os.O_TRUNC != 0 {
+		f.dirty = true
+	} else if f.buf, err = v.ReadFile(p); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Stat descreve o arquivo aberto; o tamanho de um arquivo aberto para escrita é o do conteúdo em memória.
+func (f *sharedFile) Stat() (iofs.FileInfo, error) {
+	info, err := f.vol.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.writable {
+		info.size = int64(len(f.buf))
+	}
+	return info, nil
+}
+
+func (f *sharedFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.readAt(p, off)
+}
+
+func (f *sharedFile) readAt(p []byte, off int64) (int, error) {
+	if !f.writable {
+		return f.vol.ReadAt(f.path, p, off)
+	}
+	if off >= int64(len(f.buf)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.buf[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *sharedFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.readAt(p, f.off)
+	f.off += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *sharedFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		if f.writable {
+			offset += int64(len(f.buf))
+		} else {
+			info, err := f.vol.Stat(f.path)
+			if err != nil {
+				return 0, err
+			}
+			offset += info.size
+		}
+	}
+	if offset < 0 {
+		return 0, iofs.ErrInvalid
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *sharedFile) WriteAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.writeAt(p, off)
+}
+
+func (f *sharedFile) writeAt(p []byte, off int64) (int, error) {
+	if !f.writable {
+		return 0, iofs.ErrPermission
+	}
+	if end := off + int64(len(p)); end > int64(len(f.buf)) {
+		f.buf = append(f.buf, make([]byte, end-int64(len(f.buf)))...)
+	}
+	copy(f.buf[off:], p)
+	f.dirty = true
+	return len(p), nil
+}
+
+func (f *sharedFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.append {
+		f.off = int64(len(f.buf))
+	}
+	n, err := f.writeAt(p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+// Truncate muda o tamanho do arquivo aberto para escrita, completando com zeros.
+func (f *sharedFile) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.writable {
+		return iofs.ErrPermission
+	}
+	if size <= int64(len(f.buf)) {
+		f.buf = f.buf[:size]
+	} else {
+		f.buf = append(f.buf, make([]byte, size-int64(len(f.buf)))...)
+	}
+	f.dirty = true
+	return nil
+}
+
+// Sync grava no volume o conteúdo alterado; o arquivo continua aberto.
+func (f *sharedFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.dirty {
+		return nil
+	}
+	if err := f.vol.WriteFile(f.path, bytes.NewReader(f.buf)); err != nil {
+		return err
+	}
+	f.dirty = false
+	return nil
+}
+
+// Close grava no volume o conteúdo alterado.
+func (f *sharedFile) Close() error {
+	return f.Sync()
+}
+
+// Readdir lista o diretório aberto como http.File: com count > 0, devolve até count entradas por chamada e
+// io.EOF ao final; senão, todas as que faltam.
+func (f *sharedFile) Readdir(count int) ([]iofs.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.listed {
+		entries, err := f.vol.ReadDir(f.path)
+		if err != nil {
+			return nil, err
+		}
+		f.entries, f.listed = entries, true
+	}
+	n := len(f.entries)
+	if count > 0 {
+		if n == 0 {
+			return nil, io.EOF
+		}
+		n = min(n, count)
+	}
+	infos := make([]iofs.FileInfo, n)
+	for i := range infos {
+		infos[i] = f.entries[i]
+	}
+	f.entries = f.entries[n:]
+	return infos, nil
+}
+
+// with executa fn com o volume bloqueado, para operações que não têm um método próprio.
+func (v *sharedVolume) with(fn func(fs *FURGFileSystem) error) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return fn(v.fs)
+}
+
+// ReadDir lista o diretório aberto como io/fs.ReadDirFile.
+func (f *sharedFile) ReadDir(count int) ([]iofs.DirEntry, error) {
+	infos, err := f.Readdir(count)
+	entries := make([]iofs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = iofs.FileInfoToDirEntry(info)
+	}
+	return entries, err
+}
+
+// volumeFS apresenta um sharedVolume como io/fs.FS, com os caminhos relativos à raiz do volume.
+type volumeFS struct {
+	vol *sharedVolume
+}
+
+var (
+	_ iofs.StatFS    = volumeFS{}
+	_ iofs.ReadDirFS = volumeFS{}
+)
+
+// path converte um nome de io/fs em um caminho absoluto do volume.
+func (f volumeFS) path(op, name string) (string, error) {
+	if !iofs.ValidPath(name) {
+		return "", &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
+	}
+	return normalizePath("/" + name), nil
+}
+
+func (f volumeFS) Open(name string) (iofs.File, error) {
+	p, err := f.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := f.vol.OpenFile(p, os.O_RDONLY)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return file, nil
+}
+
+func (f volumeFS) Stat(name string) (iofs.FileInfo, error) {
+	p, err := f.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.vol.Stat(p)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+func (f volumeFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	p, err := f.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := f.vol.ReadDir(p)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	entries := make([]iofs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = iofs.FileInfoToDirEntry(info)
+	}
+	return entries, nil
+}
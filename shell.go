@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// pipedInput indica que a entrada padrão do comando em execução é a saída do estágio anterior de um
+// pipeline. Comandos como rm passam a ler dela os caminhos quando não recebem argumentos.
+var pipedInput bool
+
+// runPipeline executa os estágios de stages em sequência. A saída padrão de cada estágio é guardada e entregue
+// como entrada padrão do seguinte; o último escreve na saída padrão normal. O pipeline para no primeiro erro.
+func (fs *FURGFileSystem) runPipeline(stages pipeline, base ConfirmPolicy) error {
+	if len(stages) == 1 {
+		return fs.runCommand(stages[0], base)
+	}
+
+	var input []byte
+	for k, args := range stages {
+		output, err := redirectStdio(input, k > 0, k < len(stages)-1, func() error {
+			return fs.runCommand(args, base)
+		})
+		// "use" pode ter trocado a imagem atual
+		if current := openImages.currentImage(); current != nil {
+			fs = current
+		}
+		if err != nil {
+			return err
+		}
+		input = output
+	}
+	return nil
+}
+
+// redirectStdio executa run com a entrada padrão lida de input, se feed for verdadeiro, e, se capture
+// for verdadeiro, com a saída padrão guardada e retornada, sem cores.
+func redirectStdio(input []byte, feed, capture bool, run func() error) ([]byte, error) {
+	if feed {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, fmt.Errorf("erro ao criar o pipe: %v", err)
+		}
+		// Se o comando não ler toda a entrada, a escrita falha quando r é fechado e a goroutine termina
+		go func() {
+			w.Write(input)
+			w.Close()
+		}()
+		savedStdin, savedPiped := os.Stdin, pipedInput
+		os.Stdin, pipedInput = r, true
+		defer func() {
+			os.Stdin, pipedInput = savedStdin, savedPiped
+			r.Close()
+		}()
+	}
+	if !capture {
+		return nil, run()
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar o pipe: %v", err)
+	}
+	var output bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&output, r)
+		close(done)
+	}()
+
+	savedStdout, savedColors := os.Stdout, stdoutColors
+	os.Stdout, stdoutColors = w, palette{}
+	err = run()
+	os.Stdout, stdoutColors = savedStdout, savedColors
+	w.Close()
+	<-done
+	r.Close()
+	return output.Bytes(), err
+}
+
+// readPipedLines lê as linhas não vazias da entrada padrão, usada pelos comandos que recebem caminhos de um pipeline.
+func readPipedLines() ([]string, error) {
+	content, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler a entrada padrão: %v", err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// cliShell implementa "shell": lê comandos interativamente, com histórico e completamento por Tab, e os executa
+// até "exit" ou o fim da entrada. Cada linha aceita a sintaxe dos scripts, inclusive pipelines com '|'.
+// As ações destrutivas pedem confirmação, a menos que o comando traga --force.
+func cliShell(fs *FURGFileSystem, args []string) error {
+	if len(args) != 0 {
+		return classErrorf(ErrUsage, "uso: furgfs shell")
+	}
+	console.Complete = func(line string) []string {
+		return fs.completeShellLine(line)
+	}
+	defer func() { console.Complete = nil }()
+
+	for {
+		line, err := console.ReadLine(fmt.Sprintf("%s:%s> ", openImages.current, fs.WorkingDir))
+		if err == io.EOF {
+			fmt.Println()
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("erro ao ler o comando: %v", err)
+		}
+		if line == "exit" || line == "sair" {
+			return nil
+		}
+
+		commands, err := parseScript(line)
+		if err != nil {
+			printError(err)
+			continue
+		}
+		for _, stages := range commands {
+			err := fs.runPipeline(stages, promptPolicy{})
+			if current := openImages.currentImage(); current != nil {
+				fs = current
+			}
+			if err != nil {
+				printError(err)
+			}
+		}
+	}
+}
+
+// completeShellLine completa a última palavra de line: no início de um comando (após '|' ou ';'), com os
+// nomes dos comandos e aliases; nos demais argumentos, com os caminhos internos.
+func (fs *FURGFileSystem) completeShellLine(line string) []string {
+	head, word := "", line
+	if i := strings.LastIndexAny(line, " |;"); i >= 0 {
+		head, word = line[:i+1], line[i+1:]
+	}
+
+	trimmed := strings.TrimSpace(head)
+	if trimmed != "" && !strings.HasSuffix(trimmed, "|") && !strings.HasSuffix(trimmed, ";") {
+		var candidates []string
+		for _, path := range fs.completePath(word) {
+			candidates = append(candidates, head+path)
+		}
+		return candidates
+	}
+
+	var candidates []string
+	for _, command := range commands {
+		if command.Run != nil && strings.HasPrefix(command.Name, word) {
+			candidates = append(candidates, head+command.Name+" ")
+		}
+	}
+	for name := range aliases {
+		if strings.HasPrefix(name, word) {
+			candidates = append(candidates, head+name+" ")
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
@@ -0,0 +1,1379 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	iofs "io/fs"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// O servidor SMB implementa os dialetos 2.0.2 e 2.1 do SMB2 (MS-SMB2), os mais simples que o Windows, o
+// macOS e o cifs do Linux ainda aceitam, sem oplocks, leases nem notificações de alteração. Os usuários são
+// os cadastrados com "furgfs user --smb", autenticados por NTLMv2; as mensagens são assinadas quando o
+// cliente pede. O volume aparece como um único compartilhamento, chamado furgfs a menos que --share seja
+// usado.
+const (
+	smbMaxMessage  = 1 << 20
+	smbMaxTransfer = 64 * 1024
+	smbHeaderSize  = 64
+
+	smbDialect202      = 0x0202
+	smbDialect210      = 0x0210
+	smbDialectWildcard = 0x02ff
+)
+
+// Comandos do SMB2.
+const (
+	smbNegotiate      = 0x00
+	smbSessionSetup   = 0x01
+	smbLogoff         = 0x02
+	smbTreeConnect    = 0x03
+	smbTreeDisconnect = 0x04
+	smbCreate         = 0x05
+	smbClose          = 0x06
+	smbFlush          = 0x07
+	smbRead           = 0x08
+	smbWrite          = 0x09
+	smbLock           = 0x0a
+	smbIoctl          = 0x0b
+	smbCancel         = 0x0c
+	smbEcho           = 0x0d
+	smbQueryDirectory = 0x0e
+	smbChangeNotify   = 0x0f
+	smbQueryInfo      = 0x10
+	smbSetInfo        = 0x11
+)
+
+// Flags do cabeçalho.
+const (
+	smbFlagResponse = 0x01
+	smbFlagRelated  = 0x04
+	smbFlagSigned   = 0x08
+)
+
+// Códigos NTSTATUS usados nas respostas.
+const (
+	smbStatusOK                   = 0x00000000
+	smbStatusBufferOverflow       = 0x80000005
+	smbStatusNoMoreFiles          = 0x80000006
+	smbStatusUnsuccessful         = 0xc0000001
+	smbStatusInvalidInfoClass     = 0xc0000003
+	smbStatusInfoLengthMismatch   = 0xc0000004
+	smbStatusInvalidParameter     = 0xc000000d
+	smbStatusNoSuchFile           = 0xc000000f
+	smbStatusEndOfFile            = 0xc0000011
+	smbStatusMoreProcessing       = 0xc0000016
+	smbStatusAccessDenied         = 0xc0000022
+	smbStatusObjectNameInvalid    = 0xc0000033
+	smbStatusObjectNameNotFound   = 0xc0000034
+	smbStatusObjectNameCollision  = 0xc0000035
+	smbStatusObjectPathNotFound   = 0xc000003a
+	smbStatusLogonFailure         = 0xc000006d
+	smbStatusDiskFull             = 0xc000007f
+	smbStatusFileIsADirectory     = 0xc00000ba
+	smbStatusNotSupported         = 0xc00000bb
+	smbStatusNetworkNameDeleted   = 0xc00000c9
+	smbStatusBadNetworkName       = 0xc00000cc
+	smbStatusDirectoryNotEmpty    = 0xc0000101
+	smbStatusNotADirectory        = 0xc0000103
+	smbStatusCannotDelete         = 0xc0000121
+	smbStatusFileClosed           = 0xc0000128
+	smbStatusFSDriverRequired     = 0xc000019c
+	smbStatusUserSessionDeleted   = 0xc0000203
+	smbStatusInvalidDeviceRequest = 0xc0000010
+)
+
+// Valores de CREATE e dos atributos de arquivo.
+const (
+	smbDispSupersede   = 0
+	smbDispOpen        = 1
+	smbDispCreate      = 2
+	smbDispOpenIf      = 3
+	smbDispOverwrite   = 4
+	smbDispOverwriteIf = 5
+
+	smbDirectoryFile    = 0x00000001
+	smbNonDirectoryFile = 0x00000040
+	smbDeleteOnClose    = 0x00001000
+
+	smbAccessWrite   = 0x00000002 | 0x00000004 | 0x10000000 | 0x40000000 // escrita, acréscimo, GENERIC_ALL e GENERIC_WRITE
+	smbAccessDelete  = 0x00010000
+	smbAccessMaximum = 0x02000000
+	smbAccessAll     = 0x001f01ff
+	smbAccessRead    = 0x001200a9
+
+	smbAttrReadOnly  = 0x01
+	smbAttrDirectory = 0x10
+	smbAttrArchive   = 0x20
+)
+
+var smbMagic = []byte{0xfe, 'S', 'M', 'B'}
+
+// smbServer aceita conexões SMB2 e atende o volume como um compartilhamento de disco.
+type smbServer struct {
+	vol       *sharedVolume
+	users     map[string]VolumeUser
+	share     string
+	guid      [16]byte
+	blockSize uint32
+	maxName   int
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]bool
+}
+
+// setupSMB prepara "serve smb". Os usuários são lidos uma vez, ao iniciar o servidor.
+func setupSMB(flags *flag.FlagSet) func(vol *sharedVolume) (shareServer, error) {
+	share := flags.String("share", "furgfs", "nome do compartilhamento")
+	return func(vol *sharedVolume) (shareServer, error) {
+		if *share == "" || strings.ContainsAny(*share, `\/`) || strings.HasSuffix(*share, "$") {
+			return nil, classErrorf(ErrUsage, "erro: nome de compartilhamento '%s' inválido", *share)
+		}
+		users, err := serveUsers(vol)
+		if err != nil {
+			return nil, err
+		}
+		smbUsers := 0
+		for _, user := range users {
+			if len(user.NTHash) > 0 {
+				smbUsers++
+			}
+		}
+		if smbUsers == 0 {
+			return nil, classErrorf(ErrUsage, "erro: nenhum usuário com acesso por SMB; use furgfs user passwd --smb <nome>")
+		}
+		server := &smbServer{vol: vol, users: users, share: *share, conns: make(map[net.Conn]bool)}
+		if _, err := rand.Read(server.guid[:]); err != nil {
+			return nil, err
+		}
+		vol.with(func(fs *FURGFileSystem) error {
+			server.blockSize = fs.freeSpaceInfo().BlockSize
+			server.maxName = min(fs.Rules.MaxNameLength, 32)
+			return nil
+		})
+		return server, nil
+	}
+}
+
+// Serve aceita conexões até Close.
+func (s *smbServer) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		go func() {
+			s.handleConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close encerra o servidor e todas as conexões abertas.
+func (s *smbServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// smbSession é uma sessão autenticada, ou em autenticação, de uma conexão.
+type smbSession struct {
+	id              uint64
+	ntlm            *ntlmServer
+	user            string
+	readOnly        bool
+	key             []byte // chave de assinatura; nil enquanto a autenticação não termina
+	signingRequired bool
+	trees           map[uint32]bool
+}
+
+// smbOpen é um arquivo ou diretório aberto por CREATE. O conteúdo escrito fica em writer até ser gravado
+// por FLUSH ou CLOSE.
+type smbOpen struct {
+	path          string
+	dir           bool
+	session       *smbSession
+	tree          uint32
+	access        uint32
+	writer        *sharedFile
+	deleteOnClose bool
+
+	listing []smbListEntry // resultado da última busca de QUERY_DIRECTORY
+	listPos int
+	listed  bool
+}
+
+type smbListEntry struct {
+	name string
+	path string
+	info entryInfo
+}
+
+// smbRequest é uma mensagem SMB2 recebida, com o estado herdado da mensagem anterior de um pedido composto.
+type smbRequest struct {
+	raw       []byte // a mensagem, a partir do cabeçalho
+	body      []byte
+	command   uint16
+	charge    uint16
+	credits   uint16
+	flags     uint32
+	messageID uint64
+	pid       uint32
+	treeID    uint32
+	sessionID uint64
+
+	session *smbSession
+	fileID  uint64 // FileId usado ou criado, herdado pela operação relacionada seguinte
+	final   bool   // última etapa de SESSION_SETUP, cuja resposta é assinada
+}
+
+// smbConn é o estado de uma conexão.
+type smbConn struct {
+	server          *smbServer
+	conn            net.Conn
+	dialect         uint16
+	signingRequired bool
+	sessions        map[uint64]*smbSession
+	opens           map[uint64]*smbOpen
+	nextID          uint64
+}
+
+func (s *smbServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+	c := &smbConn{server: s, conn: conn, sessions: make(map[uint64]*smbSession), opens: make(map[uint64]*smbOpen)}
+	defer c.closeAll(nil, 0)
+	for {
+		msg, err := readNetBIOS(conn)
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				logger.Info("smb: conexão encerrada", "remote", conn.RemoteAddr().String(), "error", err)
+			}
+			return
+		}
+		reply, err := c.handle(msg)
+		if err != nil {
+			logger.Warn("smb: mensagem inválida", "remote", conn.RemoteAddr().String(), "error", err)
+			return
+		}
+		if reply == nil {
+			continue
+		}
+		header := binary.BigEndian.AppendUint32(nil, uint32(len(reply)))
+		if _, err := conn.Write(append(header, reply...)); err != nil {
+			return
+		}
+	}
+}
+
+// readNetBIOS lê uma mensagem com o cabeçalho de 4 bytes do transporte direto por TCP.
+func readNetBIOS(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:]) & 0xffffff
+	if header[0] != 0 || size > smbMaxMessage {
+		return nil, fmt.Errorf("quadro NetBIOS inválido")
+	}
+	msg := make([]byte, size)
+	_, err := io.ReadFull(r, msg)
+	return msg, err
+}
+
+// handle processa uma mensagem, que pode ter vários pedidos compostos, e retorna a resposta.
+func (c *smbConn) handle(msg []byte) ([]byte, error) {
+	if len(msg) >= 5 && bytes.Equal(msg[:4], []byte{0xff, 'S', 'M', 'B'}) && msg[4] == 0x72 {
+		return c.negotiateSMB1(msg)
+	}
+
+	var responses [][]byte
+	var signers []*smbSession
+	var prev *smbRequest
+	prevStatus := uint32(smbStatusOK)
+	for len(msg) > 0 {
+		if len(msg) < smbHeaderSize || !bytes.Equal(msg[:4], smbMagic) {
+			return nil, fmt.Errorf("cabeçalho SMB2 inválido")
+		}
+		raw := msg
+		next := binary.LittleEndian.Uint32(msg[20:])
+		if next != 0 {
+			if next < smbHeaderSize || int(next) > len(msg) {
+				return nil, fmt.Errorf("NextCommand inválido")
+			}
+			raw = msg[:next]
+		}
+		msg = msg[len(raw):]
+
+		r := &smbRequest{
+			raw:       raw,
+			body:      raw[smbHeaderSize:],
+			charge:    binary.LittleEndian.Uint16(raw[6:]),
+			command:   binary.LittleEndian.Uint16(raw[12:]),
+			credits:   binary.LittleEndian.Uint16(raw[14:]),
+			flags:     binary.LittleEndian.Uint32(raw[16:]),
+			messageID: binary.LittleEndian.Uint64(raw[24:]),
+			pid:       binary.LittleEndian.Uint32(raw[32:]),
+			treeID:    binary.LittleEndian.Uint32(raw[36:]),
+			sessionID: binary.LittleEndian.Uint64(raw[40:]),
+		}
+		related := r.flags&smbFlagRelated != 0 && prev != nil
+		if related {
+			r.sessionID, r.treeID, r.fileID = prev.sessionID, prev.treeID, prev.fileID
+		}
+		if r.command == smbCancel {
+			continue
+		}
+
+		var status uint32
+		var body []byte
+		if related && prevStatus&0xc0000000 == 0xc0000000 {
+			status = prevStatus
+		} else {
+			status, body = c.dispatch(r)
+		}
+		if body == nil {
+			body = make([]byte, 9) // resposta de erro
+			binary.LittleEndian.PutUint16(body, 9)
+		}
+		responses = append(responses, c.response(r, status, body))
+		signers = append(signers, c.signer(r))
+		prev, prevStatus = r, status
+	}
+	if len(responses) == 0 {
+		return nil, nil
+	}
+
+	// As respostas compostas ficam alinhadas em 8 bytes, e cada uma é assinada separadamente
+	var out []byte
+	for i, resp := range responses {
+		if i < len(responses)-1 {
+			for len(resp)%8 != 0 {
+				resp = append(resp, 0)
+			}
+			binary.LittleEndian.PutUint32(resp[20:], uint32(len(resp)))
+		}
+		if binary.LittleEndian.Uint32(resp[16:])&smbFlagSigned != 0 {
+			copy(resp[48:64], smbSignature(signers[i].key, resp))
+		}
+		out = append(out, resp...)
+	}
+	return out, nil
+}
+
+// response monta o cabeçalho da resposta a r.
+func (c *smbConn) response(r *smbRequest, status uint32, body []byte) []byte {
+	resp := make([]byte, smbHeaderSize, smbHeaderSize+len(body))
+	copy(resp, smbMagic)
+	binary.LittleEndian.PutUint16(resp[4:], smbHeaderSize)
+	binary.LittleEndian.PutUint16(resp[6:], r.charge)
+	binary.LittleEndian.PutUint32(resp[8:], status)
+	binary.LittleEndian.PutUint16(resp[12:], r.command)
+	binary.LittleEndian.PutUint16(resp[14:], max(r.credits, 1))
+	flags := uint32(smbFlagResponse) | r.flags&smbFlagRelated
+	if s := c.signer(r); s != nil && s.key != nil && status != smbStatusMoreProcessing &&
+		(r.flags&smbFlagSigned != 0 || s.signingRequired || r.final) {
+		flags |= smbFlagSigned
+	}
+	binary.LittleEndian.PutUint32(resp[16:], flags)
+	binary.LittleEndian.PutUint64(resp[24:], r.messageID)
+	binary.LittleEndian.PutUint32(resp[32:], r.pid)
+	binary.LittleEndian.PutUint32(resp[36:], r.treeID)
+	binary.LittleEndian.PutUint64(resp[40:], r.sessionID)
+	return append(resp, body...)
+}
+
+// signer retorna a sessão cuja chave assina a resposta a r; a de LOGOFF já foi removida da conexão.
+func (c *smbConn) signer(r *smbRequest) *smbSession {
+	if r.session != nil {
+		return r.session
+	}
+	return c.sessions[r.sessionID]
+}
+
+// smbSignature calcula a assinatura de msg com a chave da sessão: HMAC-SHA256, truncado em 16 bytes.
+func smbSignature(key, msg []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(msg[:48])
+	mac.Write(make([]byte, 16))
+	mac.Write(msg[smbHeaderSize:])
+	return mac.Sum(nil)[:16]
+}
+
+// dispatch executa o pedido r. Um corpo nil indica uma resposta de erro.
+func (c *smbConn) dispatch(r *smbRequest) (uint32, []byte) {
+	if r.flags&smbFlagSigned != 0 {
+		if s := c.sessions[r.sessionID]; s != nil && s.key != nil && !hmac.Equal(smbSignature(s.key, r.raw), r.raw[48:64]) {
+			logger.Warn("smb: assinatura inválida", "user", s.user, "remote", c.conn.RemoteAddr().String())
+			return smbStatusAccessDenied, nil
+		}
+	}
+	switch r.command {
+	case smbNegotiate:
+		return c.negotiate(r)
+	case smbSessionSetup:
+		return c.sessionSetup(r)
+	case smbEcho:
+		return smbStatusOK, smbEmpty(4)
+	}
+
+	r.session = c.sessions[r.sessionID]
+	if r.session == nil || r.session.key == nil {
+		return smbStatusUserSessionDeleted, nil
+	}
+	switch r.command {
+	case smbLogoff:
+		c.closeAll(r.session, 0)
+		delete(c.sessions, r.session.id)
+		return smbStatusOK, smbEmpty(4)
+	case smbTreeConnect:
+		return c.treeConnect(r)
+	}
+	if !r.session.trees[r.treeID] {
+		return smbStatusNetworkNameDeleted, nil
+	}
+	switch r.command {
+	case smbTreeDisconnect:
+		c.closeAll(r.session, r.treeID)
+		delete(r.session.trees, r.treeID)
+		return smbStatusOK, smbEmpty(4)
+	case smbCreate:
+		return c.create(r)
+	case smbClose:
+		return c.close(r)
+	case smbFlush:
+		return c.flush(r)
+	case smbRead:
+		return c.read(r)
+	case smbWrite:
+		return c.write(r)
+	case smbLock:
+		// Sem suporte a travas: todas são concedidas
+		return smbStatusOK, smbEmpty(4)
+	case smbIoctl:
+		return c.ioctl(r)
+	case smbQueryDirectory:
+		return c.queryDirectory(r)
+	case smbQueryInfo:
+		return c.queryInfo(r)
+	case smbSetInfo:
+		return c.setInfo(r)
+	case smbChangeNotify:
+		return smbStatusNotSupported, nil
+	}
+	return smbStatusNotSupported, nil
+}
+
+// smbEmpty retorna um corpo de size bytes que só traz o StructureSize.
+func smbEmpty(size int) []byte {
+	body := make([]byte, size)
+	binary.LittleEndian.PutUint16(body, uint16(size))
+	return body
+}
+
+// smbBody monta o corpo de uma resposta: a parte fixa, de structureSize bytes sem o byte ímpar, e buffer.
+func smbBody(structureSize int, buffer []byte) []byte {
+	body := make([]byte, structureSize&^1, structureSize&^1+max(len(buffer), structureSize&1))
+	binary.LittleEndian.PutUint16(body, uint16(structureSize))
+	body = append(body, buffer...)
+	if structureSize&1 != 0 && len(buffer) == 0 {
+		body = append(body, 0)
+	}
+	return body
+}
+
+// smbBuffer retorna o trecho de r.raw indicado por um deslocamento a partir do cabeçalho.
+func smbBuffer(r *smbRequest, offset, length int) ([]byte, bool) {
+	if length == 0 {
+		return nil, true
+	}
+	if offset < smbHeaderSize || offset+length > len(r.raw) {
+		return nil, false
+	}
+	return r.raw[offset : offset+length], true
+}
+
+// negotiateSMB1 responde ao NEGOTIATE do SMB1 que os clientes antigos enviam primeiro, escolhendo o SMB2.
+func (c *smbConn) negotiateSMB1(msg []byte) ([]byte, error) {
+	dialect := uint16(0)
+	switch {
+	case bytes.Contains(msg, []byte("\x02SMB 2.???\x00")):
+		dialect = smbDialectWildcard
+	case bytes.Contains(msg, []byte("\x02SMB 2.002\x00")):
+		dialect = smbDialect202
+	default:
+		return nil, fmt.Errorf("o cliente não aceita SMB2")
+	}
+	r := &smbRequest{command: smbNegotiate}
+	return c.response(r, smbStatusOK, c.negotiateResponse(dialect)), nil
+}
+
+func (c *smbConn) negotiate(r *smbRequest) (uint32, []byte) {
+	b := r.body
+	if len(b) < 36 {
+		return smbStatusInvalidParameter, nil
+	}
+	count := int(binary.LittleEndian.Uint16(b[2:]))
+	if len(b) < 36+2*count {
+		return smbStatusInvalidParameter, nil
+	}
+	c.signingRequired = binary.LittleEndian.Uint16(b[4:])&0x2 != 0
+	dialect := uint16(0)
+	for i := 0; i < count; i++ {
+		d := binary.LittleEndian.Uint16(b[36+2*i:])
+		if (d == smbDialect202 || d == smbDialect210) && d > dialect {
+			dialect = d
+		}
+	}
+	if dialect == 0 {
+		return smbStatusNotSupported, nil
+	}
+	c.dialect = dialect
+	return smbStatusOK, c.negotiateResponse(dialect)
+}
+
+func (c *smbConn) negotiateResponse(dialect uint16) []byte {
+	blob := spnegoHint()
+	body := smbBody(65, blob)
+	binary.LittleEndian.PutUint16(body[2:], 0x1) // assinatura disponível
+	binary.LittleEndian.PutUint16(body[4:], dialect)
+	copy(body[8:24], c.server.guid[:])
+	binary.LittleEndian.PutUint32(body[28:], smbMaxTransfer)
+	binary.LittleEndian.PutUint32(body[32:], smbMaxTransfer)
+	binary.LittleEndian.PutUint32(body[36:], smbMaxTransfer)
+	binary.LittleEndian.PutUint64(body[40:], filetime(time.Now()))
+	binary.LittleEndian.PutUint16(body[56:], smbHeaderSize+64)
+	binary.LittleEndian.PutUint16(body[58:], uint16(len(blob)))
+	return body
+}
+
+func (c *smbConn) sessionSetup(r *smbRequest) (uint32, []byte) {
+	b := r.body
+	if len(b) < 24 {
+		return smbStatusInvalidParameter, nil
+	}
+	token, ok := smbBuffer(r, int(binary.LittleEndian.Uint16(b[12:])), int(binary.LittleEndian.Uint16(b[14:])))
+	if !ok {
+		return smbStatusInvalidParameter, nil
+	}
+	s := c.sessions[r.sessionID]
+	if r.sessionID != 0 && s == nil {
+		return smbStatusUserSessionDeleted, nil
+	}
+	if s == nil {
+		c.nextID++
+		s = &smbSession{id: c.nextID, trees: make(map[uint32]bool)}
+		c.sessions[s.id] = s
+		r.sessionID = s.id
+	}
+	if s.ntlm == nil {
+		s.ntlm = &ntlmServer{users: c.server.users}
+	}
+	s.signingRequired = c.signingRequired || b[3]&0x2 != 0
+
+	reply, done, err := s.ntlm.accept(token)
+	if err != nil {
+		logger.Warn("smb: autenticação recusada", "user", s.ntlm.user, "remote", c.conn.RemoteAddr().String())
+		if s.key == nil {
+			delete(c.sessions, s.id)
+		}
+		s.ntlm = nil
+		return smbStatusLogonFailure, nil
+	}
+	body := smbBody(9, reply)
+	binary.LittleEndian.PutUint16(body[4:], smbHeaderSize+8)
+	binary.LittleEndian.PutUint16(body[6:], uint16(len(reply)))
+	if !done {
+		return smbStatusMoreProcessing, body
+	}
+	s.user, s.key = s.ntlm.user, s.ntlm.sessionKey
+	s.readOnly = c.server.users[s.user].ReadOnly
+	s.ntlm = nil
+	r.session, r.final = s, true
+	logger.Info("smb: sessão", "user", s.user, "remote", c.conn.RemoteAddr().String())
+	return smbStatusOK, body
+}
+
+func (c *smbConn) treeConnect(r *smbRequest) (uint32, []byte) {
+	b := r.body
+	if len(b) < 8 {
+		return smbStatusInvalidParameter, nil
+	}
+	raw, ok := smbBuffer(r, int(binary.LittleEndian.Uint16(b[4:])), int(binary.LittleEndian.Uint16(b[6:])))
+	if !ok {
+		return smbStatusInvalidParameter, nil
+	}
+	unc := fromUTF16LE(raw)
+	share := unc[strings.LastIndex(unc, `\`)+1:]
+	if !strings.EqualFold(share, c.server.share) {
+		return smbStatusBadNetworkName, nil
+	}
+	c.nextID++
+	r.treeID = uint32(c.nextID)
+	r.session.trees[r.treeID] = true
+	body := smbBody(16, nil)
+	body[2] = 1 // disco
+	access := uint32(smbAccessAll)
+	if r.session.readOnly {
+		access = smbAccessRead
+	}
+	binary.LittleEndian.PutUint32(body[12:], access)
+	return smbStatusOK, body
+}
+
+// smbPath converte o nome de um arquivo do compartilhamento em um caminho do volume. Fluxos alternativos
+// (nome:fluxo) e curingas não são aceitos.
+func smbPath(name string) (string, bool) {
+	if strings.ContainsAny(name, `:*?"<>|`) {
+		return "", false
+	}
+	return normalizePath(strings.ReplaceAll(name, `\`, "/")), true
+}
+
+// smbStatus converte err no NTSTATUS correspondente.
+func smbStatus(err error) uint32 {
+	switch {
+	case errors.Is(err, errNotEmpty):
+		// ENOTEMPTY também corresponde a fs.ErrExist; por isso é testado antes
+		return smbStatusDirectoryNotEmpty
+	case errors.Is(err, iofs.ErrNotExist), errors.Is(err, ErrNotFound):
+		return smbStatusObjectNameNotFound
+	case errors.Is(err, iofs.ErrPermission), errors.Is(err, ErrProtected):
+		return smbStatusAccessDenied
+	case errors.Is(err, iofs.ErrExist):
+		return smbStatusObjectNameCollision
+	case errors.Is(err, errIsDirectory):
+		return smbStatusFileIsADirectory
+	case errors.Is(err, errNotDir):
+		return smbStatusNotADirectory
+	case errors.Is(err, ErrNameTooLong), errors.Is(err, ErrPathTooLong):
+		return smbStatusObjectNameInvalid
+	case errors.Is(err, ErrUsage):
+		return smbStatusInvalidParameter
+	case errors.Is(err, ErrNoSpace):
+		return smbStatusDiskFull
+	}
+	logger.Warn("smb: erro", "error", err)
+	return smbStatusUnsuccessful
+}
+
+// smbAttributes retorna os atributos de arquivo do Windows de info.
+func smbAttributes(info entryInfo) uint32 {
+	if info.dir {
+		return smbAttrDirectory
+	}
+	if info.protected {
+		return smbAttrArchive | smbAttrReadOnly
+	}
+	return smbAttrArchive
+}
+
+// allocation arredonda size para um número inteiro de blocos.
+func (s *smbServer) allocation(size int64) uint64 {
+	block := int64(max(s.blockSize, 1))
+	return uint64((size + block - 1) / block * block)
+}
+
+// smbFileID retorna um número estável para o caminho p, usado como índice do arquivo.
+func smbFileID(p string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(p))
+	return h.Sum64() | 1
+}
+
+// putTimes preenche as quatro datas (criação, acesso, escrita e alteração) com a data de alteração de info,
+// a única conhecida.
+func putTimes(b []byte, info entryInfo) {
+	t := filetime(info.modTime)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(b[8*i:], t)
+	}
+}
+
+// stat descreve o arquivo aberto, considerando o conteúdo ainda não gravado.
+func (c *smbConn) stat(o *smbOpen) (entryInfo, error) {
+	info, err := c.server.vol.Stat(o.path)
+	if err == nil && o.writer != nil {
+		o.writer.mu.Lock()
+		info.size = int64(len(o.writer.buf))
+		o.writer.mu.Unlock()
+	}
+	return info, err
+}
+
+// open retorna o arquivo aberto indicado pelo FileId em r.body[at:]. Em um pedido relacionado, o FileId
+// 0xffffffffffffffff indica o arquivo da operação anterior.
+func (c *smbConn) open(r *smbRequest, at int) (*smbOpen, uint32) {
+	if len(r.body) < at+16 {
+		return nil, smbStatusInvalidParameter
+	}
+	id := binary.LittleEndian.Uint64(r.body[at+8:])
+	if id == ^uint64(0) && r.flags&smbFlagRelated != 0 {
+		id = r.fileID
+	}
+	o := c.opens[id]
+	if o == nil || o.session != r.session {
+		return nil, smbStatusFileClosed
+	}
+	r.fileID = id
+	return o, smbStatusOK
+}
+
+func (c *smbConn) create(r *smbRequest) (uint32, []byte) {
+	b := r.body
+	if len(b) < 56 {
+		return smbStatusInvalidParameter, nil
+	}
+	access := binary.LittleEndian.Uint32(b[24:])
+	disposition := binary.LittleEndian.Uint32(b[36:])
+	options := binary.LittleEndian.Uint32(b[40:])
+	rawName, ok := smbBuffer(r, int(binary.LittleEndian.Uint16(b[44:])), int(binary.LittleEndian.Uint16(b[46:])))
+	if !ok || disposition > smbDispOverwriteIf {
+		return smbStatusInvalidParameter, nil
+	}
+	p, ok := smbPath(fromUTF16LE(rawName))
+	if !ok {
+		return smbStatusObjectNameInvalid, nil
+	}
+	s := r.session
+	vol := c.server.vol.as(s.user)
+	if s.readOnly && (access&(smbAccessWrite|smbAccessDelete) != 0 || options&smbDeleteOnClose != 0) {
+		return smbStatusAccessDenied, nil
+	}
+
+	info, err := vol.Stat(p)
+	exists := err == nil
+	if err != nil && !errors.Is(err, iofs.ErrNotExist) {
+		return smbStatus(err), nil
+	}
+	if !exists {
+		if parent, err := vol.Stat(path.Dir(p)); err != nil || !parent.dir {
+			return smbStatusObjectPathNotFound, nil
+		}
+	}
+	action := uint32(1) // aberto
+	switch {
+	case exists && disposition == smbDispCreate:
+		return smbStatusObjectNameCollision, nil
+	case exists && options&smbDeleteOnClose != 0 && (p == "/" || info.protected):
+		return smbStatusCannotDelete, nil
+	case exists && info.dir && options&smbNonDirectoryFile != 0:
+		return smbStatusFileIsADirectory, nil
+	case exists && !info.dir && options&smbDirectoryFile != 0:
+		return smbStatusNotADirectory, nil
+	case exists && (disposition == smbDispSupersede || disposition == smbDispOverwrite || disposition == smbDispOverwriteIf):
+		if info.dir {
+			return smbStatusInvalidParameter, nil
+		}
+		if s.readOnly {
+			return smbStatusAccessDenied, nil
+		}
+		if err := vol.WriteFile(p, bytes.NewReader(nil)); err != nil {
+			return smbStatus(err), nil
+		}
+		action = 3 // sobrescrito
+		if disposition == smbDispSupersede {
+			action = 0
+		}
+	case exists:
+		if !info.dir && info.protected && access&smbAccessWrite != 0 {
+			return smbStatusAccessDenied, nil
+		}
+	case disposition == smbDispOpen || disposition == smbDispOverwrite:
+		return smbStatusObjectNameNotFound, nil
+	default:
+		if s.readOnly {
+			return smbStatusAccessDenied, nil
+		}
+		if options&smbDirectoryFile != 0 {
+			err = vol.Mkdir(p)
+		} else {
+			err = vol.WriteFile(p, bytes.NewReader(nil))
+		}
+		if err != nil {
+			return smbStatus(err), nil
+		}
+		action = 2 // criado
+	}
+	if info, err = vol.Stat(p); err != nil {
+		return smbStatus(err), nil
+	}
+	if access&smbAccessMaximum != 0 {
+		access = smbAccessAll
+		if s.readOnly {
+			access = smbAccessRead
+		}
+	}
+
+	c.nextID++
+	id := c.nextID
+	c.opens[id] = &smbOpen{path: p, dir: info.dir, session: s, tree: r.treeID, access: access, deleteOnClose: options&smbDeleteOnClose != 0}
+	r.fileID = id
+
+	body := smbBody(89, nil)
+	binary.LittleEndian.PutUint32(body[4:], action)
+	putTimes(body[8:], info)
+	binary.LittleEndian.PutUint64(body[40:], c.server.allocation(info.size))
+	binary.LittleEndian.PutUint64(body[48:], uint64(info.size))
+	binary.LittleEndian.PutUint32(body[56:], smbAttributes(info))
+	binary.LittleEndian.PutUint64(body[64:], id)
+	binary.LittleEndian.PutUint64(body[72:], id)
+	return smbStatusOK, body
+}
+
+// closeOpen fecha o arquivo id: grava o conteúdo escrito ou, se ele foi marcado para ser apagado, apaga-o.
+func (c *smbConn) closeOpen(id uint64) error {
+	o := c.opens[id]
+	delete(c.opens, id)
+	if o.deleteOnClose {
+		return c.server.vol.as(o.session.user).Remove(o.path)
+	}
+	if o.writer != nil {
+		return o.writer.Close()
+	}
+	return nil
+}
+
+// closeAll fecha os arquivos abertos na sessão s e, se tree não for 0, só os desse compartilhamento. Com s
+// nil, fecha todos os arquivos da conexão.
+func (c *smbConn) closeAll(s *smbSession, tree uint32) {
+	for id, o := range c.opens {
+		if s == nil || o.session == s && (tree == 0 || o.tree == tree) {
+			if err := c.closeOpen(id); err != nil {
+				logger.Warn("smb: erro ao fechar", "path", o.path, "error", err)
+			}
+		}
+	}
+}
+
+func (c *smbConn) close(r *smbRequest) (uint32, []byte) {
+	o, status := c.open(r, 8)
+	if o == nil {
+		return status, nil
+	}
+	if err := c.closeOpen(r.fileID); err != nil {
+		return smbStatus(err), nil
+	}
+	body := smbBody(60, nil)
+	if binary.LittleEndian.Uint16(r.body[2:])&0x1 != 0 {
+		if info, err := c.server.vol.Stat(o.path); err == nil {
+			binary.LittleEndian.PutUint16(body[2:], 0x1)
+			putTimes(body[8:], info)
+			binary.LittleEndian.PutUint64(body[40:], c.server.allocation(info.size))
+			binary.LittleEndian.PutUint64(body[48:], uint64(info.size))
+			binary.LittleEndian.PutUint32(body[56:], smbAttributes(info))
+		}
+	}
+	return smbStatusOK, body
+}
+
+func (c *smbConn) flush(r *smbRequest) (uint32, []byte) {
+	o, status := c.open(r, 8)
+	if o == nil {
+		return status, nil
+	}
+	if o.writer != nil {
+		if err := o.writer.Sync(); err != nil {
+			return smbStatus(err), nil
+		}
+	}
+	return smbStatusOK, smbEmpty(4)
+}
+
+func (c *smbConn) read(r *smbRequest) (uint32, []byte) {
+	o, status := c.open(r, 16)
+	if o == nil {
+		return status, nil
+	}
+	if o.dir {
+		return smbStatusInvalidDeviceRequest, nil
+	}
+	length := binary.LittleEndian.Uint32(r.body[4:])
+	offset := int64(binary.LittleEndian.Uint64(r.body[8:]))
+	if length > smbMaxTransfer || offset < 0 {
+		return smbStatusInvalidParameter, nil
+	}
+	buf := make([]byte, length)
+	var n int
+	var err error
+	if o.writer != nil {
+		n, err = o.writer.ReadAt(buf, offset)
+	} else {
+		n, err = c.server.vol.ReadAt(o.path, buf, offset)
+	}
+	if err != nil && err != io.EOF {
+		return smbStatus(err), nil
+	}
+	if n == 0 && length > 0 {
+		return smbStatusEndOfFile, nil
+	}
+	body := smbBody(17, buf[:n])
+	body[2] = smbHeaderSize + 16
+	binary.LittleEndian.PutUint32(body[4:], uint32(n))
+	return smbStatusOK, body
+}
+
+// writer abre o arquivo para escrita na primeira WRITE; o conteúdo é gravado em FLUSH e CLOSE.
+func (c *smbConn) writer(o *smbOpen) (*sharedFile, error) {
+	if o.writer == nil {
+		f, err := c.server.vol.as(o.session.user).OpenFile(o.path, os.O_RDWR)
+		if err != nil {
+			return nil, err
+		}
+		o.writer = f
+	}
+	return o.writer, nil
+}
+
+func (c *smbConn) write(r *smbRequest) (uint32, []byte) {
+	o, status := c.open(r, 16)
+	if o == nil {
+		return status, nil
+	}
+	if r.session.readOnly || o.access&smbAccessWrite == 0 {
+		return smbStatusAccessDenied, nil
+	}
+	if o.dir {
+		return smbStatusInvalidDeviceRequest, nil
+	}
+	data, ok := smbBuffer(r, int(binary.LittleEndian.Uint16(r.body[2:])), int(binary.LittleEndian.Uint32(r.body[4:])))
+	offset := int64(binary.LittleEndian.Uint64(r.body[8:]))
+	if !ok || offset < 0 {
+		return smbStatusInvalidParameter, nil
+	}
+	f, err := c.writer(o)
+	if err != nil {
+		return smbStatus(err), nil
+	}
+	if _, err := f.WriteAt(data, offset); err != nil {
+		return smbStatus(err), nil
+	}
+	body := smbBody(17, nil)
+	binary.LittleEndian.PutUint32(body[4:], uint32(len(data)))
+	return smbStatusOK, body
+}
+
+func (c *smbConn) ioctl(r *smbRequest) (uint32, []byte) {
+	if len(r.body) < 8 {
+		return smbStatusInvalidParameter, nil
+	}
+	switch binary.LittleEndian.Uint32(r.body[4:]) {
+	case 0x00060194, 0x000601b0: // FSCTL_DFS_GET_REFERRALS e FSCTL_DFS_GET_REFERRALS_EX
+		return smbStatusFSDriverRequired, nil
+	}
+	return smbStatusNotSupported, nil
+}
+
+// smbMatch informa se name corresponde ao padrão de busca do Windows, sem diferenciar maiúsculas.
+func smbMatch(pattern, name string) bool {
+	if pattern == "*" || pattern == "*.*" {
+		return true
+	}
+	pattern = strings.NewReplacer("<", "*", ">", "?", `"`, ".", "[", `\[`, "]", `\]`, `\`, `\\`).Replace(strings.ToLower(pattern))
+	matched, _ := path.Match(pattern, strings.ToLower(name))
+	return matched
+}
+
+// list refaz a busca de o com pattern, incluindo "." e "..".
+func (c *smbConn) list(o *smbOpen, pattern string) error {
+	vol := c.server.vol
+	infos, err := vol.ReadDir(o.path)
+	if err != nil {
+		return err
+	}
+	self, err := vol.Stat(o.path)
+	if err != nil {
+		return err
+	}
+	parent, err := vol.Stat(path.Dir(o.path))
+	if err != nil {
+		parent = self
+	}
+	o.listing, o.listPos, o.listed = nil, 0, true
+	entries := []smbListEntry{{".", o.path, self}, {"..", path.Dir(o.path), parent}}
+	for _, info := range infos {
+		entries = append(entries, smbListEntry{info.name, joinPath(o.path, info.name), info})
+	}
+	for _, entry := range entries {
+		if smbMatch(pattern, entry.name) {
+			o.listing = append(o.listing, entry)
+		}
+	}
+	return nil
+}
+
+// dirEntry codifica uma entrada de QUERY_DIRECTORY na classe class; retorna nil para classes não
+// aceitas.
+func (c *smbConn) dirEntry(class byte, entry smbListEntry) []byte {
+	var fixed int
+	switch class {
+	case 1: // FileDirectoryInformation
+		fixed = 64
+	case 2: // FileFullDirectoryInformation
+		fixed = 68
+	case 3: // FileBothDirectoryInformation
+		fixed = 94
+	case 12: // FileNamesInformation
+		fixed = 12
+	case 37: // FileIdBothDirectoryInformation
+		fixed = 104
+	case 38: // FileIdFullDirectoryInformation
+		fixed = 80
+	default:
+		return nil
+	}
+	name := utf16LE(entry.name)
+	b := make([]byte, fixed, fixed+len(name))
+	if class == 12 {
+		binary.LittleEndian.PutUint32(b[8:], uint32(len(name)))
+		return append(b, name...)
+	}
+	putTimes(b[8:], entry.info)
+	binary.LittleEndian.PutUint64(b[40:], uint64(entry.info.size))
+	binary.LittleEndian.PutUint64(b[48:], c.server.allocation(entry.info.size))
+	binary.LittleEndian.PutUint32(b[56:], smbAttributes(entry.info))
+	binary.LittleEndian.PutUint32(b[60:], uint32(len(name)))
+	switch class {
+	case 37:
+		binary.LittleEndian.PutUint64(b[96:], smbFileID(entry.path))
+	case 38:
+		binary.LittleEndian.PutUint64(b[72:], smbFileID(entry.path))
+	}
+	return append(b, name...)
+}
+
+func (c *smbConn) queryDirectory(r *smbRequest) (uint32, []byte) {
+	o, status := c.open(r, 8)
+	if o == nil {
+		return status, nil
+	}
+	if !o.dir {
+		return smbStatusInvalidParameter, nil
+	}
+	class, flags := r.body[2], r.body[3]
+	rawPattern, ok := smbBuffer(r, int(binary.LittleEndian.Uint16(r.body[24:])), int(binary.LittleEndian.Uint16(r.body[26:])))
+	if !ok {
+		return smbStatusInvalidParameter, nil
+	}
+	limit := int(binary.LittleEndian.Uint32(r.body[28:]))
+	if c.dirEntry(class, smbListEntry{}) == nil {
+		return smbStatusInvalidInfoClass, nil
+	}
+	first := !o.listed || flags&(0x01|0x10) != 0 // SMB2_RESTART_SCANS e SMB2_REOPEN
+	if first {
+		pattern := fromUTF16LE(rawPattern)
+		if pattern == "" {
+			pattern = "*"
+		}
+		if err := c.list(o, pattern); err != nil {
+			return smbStatus(err), nil
+		}
+		if len(o.listing) == 0 {
+			return smbStatusNoSuchFile, nil
+		}
+	}
+	if o.listPos >= len(o.listing) {
+		return smbStatusNoMoreFiles, nil
+	}
+
+	var out []byte
+	last := -1
+	for o.listPos < len(o.listing) {
+		entry := c.dirEntry(class, o.listing[o.listPos])
+		start := (len(out) + 7) &^ 7
+		if start+len(entry) > limit {
+			break
+		}
+		if last >= 0 {
+			binary.LittleEndian.PutUint32(out[last:], uint32(start-last))
+		}
+		out = append(out, make([]byte, start-len(out))...)
+		out = append(out, entry...)
+		last = start
+		o.listPos++
+		if flags&0x02 != 0 { // SMB2_RETURN_SINGLE_ENTRY
+			break
+		}
+	}
+	if last < 0 {
+		return smbStatusInfoLengthMismatch, nil
+	}
+	body := smbBody(9, out)
+	binary.LittleEndian.PutUint16(body[2:], smbHeaderSize+8)
+	binary.LittleEndian.PutUint32(body[4:], uint32(len(out)))
+	return smbStatusOK, body
+}
+
+// fileInfo codifica a classe class de QUERY_INFO sobre um arquivo.
+func (c *smbConn) fileInfo(o *smbOpen, class byte) ([]byte, uint32) {
+	info, err := c.stat(o)
+	if err != nil {
+		return nil, smbStatus(err)
+	}
+	basic := make([]byte, 40)
+	putTimes(basic, info)
+	binary.LittleEndian.PutUint32(basic[32:], smbAttributes(info))
+
+	standard := make([]byte, 24)
+	binary.LittleEndian.PutUint64(standard, c.server.allocation(info.size))
+	binary.LittleEndian.PutUint64(standard[8:], uint64(info.size))
+	binary.LittleEndian.PutUint32(standard[16:], 1)
+	if o.deleteOnClose {
+		standard[20] = 1
+	}
+	if info.dir {
+		standard[21] = 1
+	}
+
+	internal := binary.LittleEndian.AppendUint64(nil, smbFileID(o.path))
+	access := binary.LittleEndian.AppendUint32(nil, o.access)
+	name := utf16LE(strings.ReplaceAll(o.path, "/", `\`))
+	nameInfo := append(binary.LittleEndian.AppendUint32(nil, uint32(len(name))), name...)
+
+	switch class {
+	case 4: // FileBasicInformation
+		return basic, smbStatusOK
+	case 5: // FileStandardInformation
+		return standard, smbStatusOK
+	case 6: // FileInternalInformation
+		return internal, smbStatusOK
+	case 7, 14, 16, 17: // FileEaInformation, FilePositionInformation, FileModeInformation, FileAlignmentInformation
+		return make([]byte, map[byte]int{7: 4, 14: 8, 16: 4, 17: 4}[class]), smbStatusOK
+	case 8: // FileAccessInformation
+		return access, smbStatusOK
+	case 9: // FileNameInformation
+		return nameInfo, smbStatusOK
+	case 18: // FileAllInformation
+		all := bytes.Join([][]byte{basic, standard, internal, make([]byte, 4), access, make([]byte, 16), nameInfo}, nil)
+		return all, smbStatusOK
+	case 22: // FileStreamInformation
+		if info.dir {
+			return []byte{}, smbStatusOK
+		}
+		stream := utf16LE("::$DATA")
+		b := make([]byte, 24, 24+len(stream))
+		binary.LittleEndian.PutUint32(b[4:], uint32(len(stream)))
+		binary.LittleEndian.PutUint64(b[8:], uint64(info.size))
+		binary.LittleEndian.PutUint64(b[16:], c.server.allocation(info.size))
+		return append(b, stream...), smbStatusOK
+	case 34: // FileNetworkOpenInformation
+		b := make([]byte, 56)
+		putTimes(b, info)
+		binary.LittleEndian.PutUint64(b[32:], c.server.allocation(info.size))
+		binary.LittleEndian.PutUint64(b[40:], uint64(info.size))
+		binary.LittleEndian.PutUint32(b[48:], smbAttributes(info))
+		return b, smbStatusOK
+	case 35: // FileAttributeTagInformation
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint32(b, smbAttributes(info))
+		return b, smbStatusOK
+	}
+	return nil, smbStatusInvalidInfoClass
+}
+
+// fsInfo codifica a classe class de QUERY_INFO sobre o sistema de arquivos.
+func (c *smbConn) fsInfo(class byte) ([]byte, uint32) {
+	var space jsonFreeSpace
+	c.server.vol.with(func(fs *FURGFileSystem) error {
+		space = fs.freeSpaceInfo()
+		return nil
+	})
+	sector := uint32(512)
+	if space.BlockSize%512 != 0 {
+		sector = space.BlockSize
+	}
+	units := func(bytes uint64) uint64 { return bytes / uint64(max(space.BlockSize, 1)) }
+
+	switch class {
+	case 1: // FileFsVolumeInformation
+		label := utf16LE(c.server.share)
+		b := make([]byte, 18, 18+len(label))
+		binary.LittleEndian.PutUint64(b, filetime(c.server.vol.started))
+		binary.LittleEndian.PutUint32(b[8:], binary.LittleEndian.Uint32(c.server.guid[:]))
+		binary.LittleEndian.PutUint32(b[12:], uint32(len(label)))
+		return append(b, label...), smbStatusOK
+	case 3: // FileFsSizeInformation
+		b := make([]byte, 24)
+		binary.LittleEndian.PutUint64(b, units(space.TotalBytes))
+		binary.LittleEndian.PutUint64(b[8:], units(space.FreeBytes))
+		binary.LittleEndian.PutUint32(b[16:], space.BlockSize/sector)
+		binary.LittleEndian.PutUint32(b[20:], sector)
+		return b, smbStatusOK
+	case 4: // FileFsDeviceInformation: disco
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint32(b, 7)
+		return b, smbStatusOK
+	case 5: // FileFsAttributeInformation
+		name := utf16LE("FURGfs2")
+		b := make([]byte, 12, 12+len(name))
+		binary.LittleEndian.PutUint32(b, 0x1|0x2|0x4) // nomes com maiúsculas preservadas e diferenciadas, em Unicode
+		binary.LittleEndian.PutUint32(b[4:], uint32(c.server.maxName))
+		binary.LittleEndian.PutUint32(b[8:], uint32(len(name)))
+		return append(b, name...), smbStatusOK
+	case 7: // FileFsFullSizeInformation
+		b := make([]byte, 32)
+		binary.LittleEndian.PutUint64(b, units(space.TotalBytes))
+		binary.LittleEndian.PutUint64(b[8:], units(space.FreeBytes))
+		binary.LittleEndian.PutUint64(b[16:], units(space.FreeBytes))
+		binary.LittleEndian.PutUint32(b[24:], space.BlockSize/sector)
+		binary.LittleEndian.PutUint32(b[28:], sector)
+		return b, smbStatusOK
+	case 11: // FileFsSectorSizeInformation
+		b := make([]byte, 28)
+		for i := 0; i < 4; i++ {
+			binary.LittleEndian.PutUint32(b[4*i:], sector)
+		}
+		return b, smbStatusOK
+	}
+	return nil, smbStatusInvalidInfoClass
+}
+
+func (c *smbConn) queryInfo(r *smbRequest) (uint32, []byte) {
+	o, status := c.open(r, 24)
+	if o == nil {
+		return status, nil
+	}
+	infoType, class := r.body[2], r.body[3]
+	limit := int(binary.LittleEndian.Uint32(r.body[4:]))
+	var data []byte
+	switch infoType {
+	case 1:
+		data, status = c.fileInfo(o, class)
+	case 2:
+		data, status = c.fsInfo(class)
+	default:
+		return smbStatusNotSupported, nil
+	}
+	if status != smbStatusOK {
+		return status, nil
+	}
+	if len(data) > limit {
+		data, status = data[:limit], smbStatusBufferOverflow
+	}
+	body := smbBody(9, data)
+	binary.LittleEndian.PutUint16(body[2:], smbHeaderSize+8)
+	binary.LittleEndian.PutUint32(body[4:], uint32(len(data)))
+	return status, body
+}
+
+func (c *smbConn) setInfo(r *smbRequest) (uint32, []byte) {
+	o, status := c.open(r, 16)
+	if o == nil {
+		return status, nil
+	}
+	if r.body[2] != 1 {
+		return smbStatusNotSupported, nil
+	}
+	if r.session.readOnly {
+		return smbStatusAccessDenied, nil
+	}
+	data, ok := smbBuffer(r, int(binary.LittleEndian.Uint16(r.body[8:])), int(binary.LittleEndian.Uint32(r.body[4:])))
+	if !ok {
+		return smbStatusInvalidParameter, nil
+	}
+	vol := c.server.vol.as(r.session.user)
+	switch class := r.body[3]; class {
+	case 4: // FileBasicInformation: as datas não são guardadas; o atributo somente leitura protege o arquivo
+		if len(data) < 36 {
+			return smbStatusInfoLengthMismatch, nil
+		}
+		attributes := binary.LittleEndian.Uint32(data[32:])
+		info, err := vol.Stat(o.path)
+		if err != nil {
+			return smbStatus(err), nil
+		}
+		if protected := attributes&smbAttrReadOnly != 0; attributes != 0 && !info.dir && protected != info.protected {
+			if err := vol.SetProtected(o.path, protected); err != nil {
+				return smbStatus(err), nil
+			}
+		}
+	case 10: // FileRenameInformation
+		if len(data) < 20 || len(data) < 20+int(binary.LittleEndian.Uint32(data[16:])) {
+			return smbStatusInfoLengthMismatch, nil
+		}
+		to, ok := smbPath(fromUTF16LE(data[20 : 20+binary.LittleEndian.Uint32(data[16:])]))
+		if !ok {
+			return smbStatusObjectNameInvalid, nil
+		}
+		if _, err := vol.Stat(to); err == nil && to != o.path && data[0] == 0 {
+			return smbStatusObjectNameCollision, nil
+		}
+		if o.writer != nil {
+			if err := o.writer.Sync(); err != nil {
+				return smbStatus(err), nil
+			}
+		}
+		if err := vol.Rename(o.path, to); err != nil {
+			return smbStatus(err), nil
+		}
+		o.path = to
+		if o.writer != nil {
+			o.writer.path = to
+		}
+	case 13: // FileDispositionInformation
+		if len(data) < 1 {
+			return smbStatusInfoLengthMismatch, nil
+		}
+		if data[0] != 0 {
+			info, err := vol.Stat(o.path)
+			if err != nil {
+				return smbStatus(err), nil
+			}
+			if o.path == "/" || info.protected {
+				return smbStatusCannotDelete, nil
+			}
+			if info.dir {
+				if infos, err := vol.ReadDir(o.path); err != nil || len(infos) > 0 {
+					return smbStatusDirectoryNotEmpty, nil
+				}
+			}
+		}
+		o.deleteOnClose = data[0] != 0
+	case 19: // FileAllocationInformation: o espaço não é reservado antes da escrita
+	case 20: // FileEndOfFileInformation
+		if len(data) < 8 {
+			return smbStatusInfoLengthMismatch, nil
+		}
+		size := int64(binary.LittleEndian.Uint64(data))
+		if o.dir || size < 0 {
+			return smbStatusInvalidParameter, nil
+		}
+		f, err := c.writer(o)
+		if err != nil {
+			return smbStatus(err), nil
+		}
+		if err := f.Truncate(size); err != nil {
+			return smbStatus(err), nil
+		}
+	default:
+		return smbStatusInvalidInfoClass, nil
+	}
+	return smbStatusOK, smbEmpty(2)
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Um snapshot guarda, em /.furgfs/snap.<nome>, as entradas do volume e os blocos de cada arquivo no momento
+// em que foi criado. Os blocos de dados nunca são reescritos no lugar (alterar um arquivo grava blocos novos),
+// então basta impedir que os blocos de um snapshot sejam liberados: eles continuam marcados como usados na FAT
+// mesmo depois de o arquivo ser removido, e voltam a ficar livres quando o último snapshot que os usa é apagado.
+const snapshotPrefix = "snap."
+
+// Tamanho máximo do nome de um snapshot: o nome do arquivo de sistema tem o prefixo e, durante a gravação, o sufixo '~'.
+const maxSnapshotName = 32 - len(snapshotPrefix) - 1
+
+// Snapshot é o conteúdo de um arquivo de snapshot.
+type Snapshot struct {
+	Name    string          `json:"name"`
+	Created time.Time       `json:"created"`
+	Entries []snapshotEntry `json:"entries"`
+}
+
+// snapshotEntry é um diretório ou arquivo do snapshot, com a sequência de blocos do conteúdo.
+type snapshotEntry struct {
+	Path      string   `json:"path"`
+	Directory bool     `json:"directory,omitempty"`
+	Protected bool     `json:"protected,omitempty"`
+	Size      uint32   `json:"size"`
+	Blocks    []uint32 `json:"blocks,omitempty"`
+	Hashes    []string `json:"hashes,omitempty"` // hashes dos blocos, no armazenamento por conteúdo
+}
+
+// validateSnapshotName verifica se name pode ser usado como nome de snapshot.
+func (fs *FURGFileSystem) validateSnapshotName(name string) error {
+	if len(name) > maxSnapshotName {
+		return classErrorf(ErrUsage, "erro: o nome do snapshot tem %d bytes, o máximo é %d", len(name), maxSnapshotName)
+	}
+	return fs.Rules.ValidateName(name)
+}
+
+// isSnapshotFile informa se a entrada é um arquivo de snapshot do diretório de sistema.
+func isSnapshotFile(entry *FileEntry) bool {
+	return !entry.IsDirectory && entry.PathString() == systemDir && strings.HasPrefix(entry.NameString(), snapshotPrefix)
+}
+
+// Snapshots lê todos os snapshots do volume, do mais antigo para o mais recente.
+func (fs *FURGFileSystem) Snapshots() ([]Snapshot, error) {
+	var snapshots []Snapshot
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 || !isSnapshotFile(entry) {
+			continue
+		}
+		snapshot, err := fs.readSnapshot(strings.TrimPrefix(entry.NameString(), snapshotPrefix))
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.Before(snapshots[j].Created)
+	})
+	return snapshots, nil
+}
+
+// readSnapshot lê o snapshot name.
+func (fs *FURGFileSystem) readSnapshot(name string) (Snapshot, error) {
+	var snapshot Snapshot
+	data, err := fs.readSystemFile(snapshotPrefix + name)
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshot, classErrorf(ErrNotFound, "erro: o snapshot '%s' não existe", name)
+	}
+	if err != nil {
+		return snapshot, err
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, classErrorf(ErrCorrupted, "erro: o snapshot '%s' está corrompido: %v", name, err)
+	}
+	return snapshot, nil
+}
+
+// snapshotBlocks retorna os blocos retidos pelos snapshots. O resultado fica em fs.pinnedBlocks até que um
+// snapshot seja criado, restaurado ou apagado. Um snapshot ilegível não retém blocos e é apenas registrado no log.
+func (fs *FURGFileSystem) snapshotBlocks() map[uint32]bool {
+	if fs.pinnedBlocks != nil {
+		return fs.pinnedBlocks
+	}
+	fs.pinnedBlocks = make(map[uint32]bool)
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 || !isSnapshotFile(entry) {
+			continue
+		}
+		snapshot, err := fs.readSnapshot(strings.TrimPrefix(entry.NameString(), snapshotPrefix))
+		if err != nil {
+			logger.Warn("snapshot ignorado", fs.imageAttr(), "name", entry.NameString(), "err", err)
+			continue
+		}
+		for _, e := range snapshot.Entries {
+			for _, blockID := range e.Blocks {
+				if int(blockID) < len(fs.FAT) {
+					fs.pinnedBlocks[blockID] = true
+				}
+			}
+		}
+	}
+	return fs.pinnedBlocks
+}
+
+// volumeContent retorna os índices das entradas do volume, exceto as do diretório de sistema.
+func (fs *FURGFileSystem) volumeContent() []int {
+	var indices []int
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 {
+			continue
+		}
+		if path := entry.FullPath(); path != systemDir && !strings.HasPrefix(path, systemDir+"/") {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// volumeEntries descreve as entradas de volumeContent, com os blocos de cada arquivo, ordenadas pelo caminho:
+// os diretórios pais vêm antes do conteúdo, para que a restauração possa recriá-los em ordem.
+func (fs *FURGFileSystem) volumeEntries() ([]snapshotEntry, error) {
+	var entries []snapshotEntry
+	for _, i := range fs.volumeContent() {
+		entry := &fs.RootDir[i]
+		blocks, err := fs.fileBlocks(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%w (execute o fsck antes)", err)
+		}
+		e := snapshotEntry{
+			Path: entry.FullPath(), Directory: entry.IsDirectory, Protected: entry.Protected, Size: entry.Size, Blocks: blocks,
+		}
+		if len(blocks) > 0 && fs.isCASFile(entry) {
+			if e.Hashes, err = fs.casFileHashes(entry); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries, nil
+}
+
+// confirmReplaceVolume consulta a política antes de substituir o conteúdo do volume (a ação descrita por format),
+// inclusive para cada arquivo protegido que será removido. Retorna os índices das entradas atuais.
+func (fs *FURGFileSystem) confirmReplaceVolume(format string, args ...any) ([]int, error) {
+	if err := fs.confirm(format, args...); err != nil {
+		return nil, err
+	}
+	current := fs.volumeContent()
+	for _, i := range current {
+		if entry := &fs.RootDir[i]; entry.Protected && !entry.IsDirectory {
+			if err := fs.confirmProtected(entry.FullPath()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return current, nil
+}
+
+// CreateSnapshot registra o estado atual do volume como o snapshot name. O diretório de sistema não faz parte
+// do snapshot. Os blocos dos arquivos passam a ser retidos até que o snapshot seja apagado.
+func (fs *FURGFileSystem) CreateSnapshot(name string) error {
+	if err := fs.validateSnapshotName(name); err != nil {
+		return err
+	}
+	if fs.lookupEntry(snapshotPrefix+name, systemDir) != -1 {
+		return classErrorf(ErrUsage, "erro: o snapshot '%s' já existe", name)
+	}
+
+	entries, err := fs.volumeEntries()
+	if err != nil {
+		return err
+	}
+	snapshot := Snapshot{Name: name, Created: time.Now().UTC().Truncate(time.Second), Entries: entries}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := fs.writeSystemFile(snapshotPrefix+name, data); err != nil {
+		return err
+	}
+	fs.pinnedBlocks = nil
+	logger.Info("snapshot criado", fs.imageAttr(), "name", name, "entries", len(snapshot.Entries))
+	fmt.Printf(tr("Snapshot '%s' criado com %d entrada(s).\n"), name, len(snapshot.Entries))
+	return nil
+}
+
+// DeleteSnapshot apaga o snapshot name e libera os blocos que só ele retinha.
+func (fs *FURGFileSystem) DeleteSnapshot(name string) error {
+	snapshot, err := fs.readSnapshot(name)
+	if err != nil {
+		return err
+	}
+	if err := fs.confirm("O snapshot '%s' será apagado e não poderá mais ser restaurado.", name); err != nil {
+		return err
+	}
+
+	released, err := fs.dropSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(tr("Snapshot '%s' apagado, %d bloco(s) liberado(s).\n"), name, released)
+	return nil
+}
+
+// dropSnapshot apaga o arquivo do snapshot, sem confirmação, e libera os blocos que só ele retinha.
+// Retorna o número de blocos liberados.
+func (fs *FURGFileSystem) dropSnapshot(snapshot Snapshot) (int, error) {
+	fs.discardEntries([]int{fs.lookupEntry(snapshotPrefix+snapshot.Name, systemDir)})
+	fs.pinnedBlocks = nil
+	referenced, err := fs.referencedBlocks()
+	if err != nil {
+		return 0, fmt.Errorf("%w (execute o fsck antes)", err)
+	}
+	var released []uint32
+	for _, e := range snapshot.Entries {
+		for _, blockID := range e.Blocks {
+			if int(blockID) < len(fs.FAT) && !referenced[blockID] {
+				released = append(released, blockID)
+			}
+		}
+	}
+	fs.freeBlocks(released)
+	logger.Info("snapshot apagado", fs.imageAttr(), "name", snapshot.Name, "released", len(released))
+	return len(released), nil
+}
+
+// RestoreSnapshot substitui todo o conteúdo do volume, exceto o diretório de sistema, pelo do snapshot name.
+// Os arquivos atuais são removidos; seus blocos continuam retidos se outro snapshot os usar.
+func (fs *FURGFileSystem) RestoreSnapshot(name string) error {
+	snapshot, err := fs.readSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	free := 0
+	for i := range fs.RootDir {
+		if fs.RootDir[i].Name[0] == 0 {
+			free++
+		}
+	}
+	current := fs.volumeContent()
+	if free+len(current) < len(snapshot.Entries) {
+		return classErrorf(ErrNoSpace, "erro: o snapshot tem %d entradas, mas o diretório só comporta %d", len(snapshot.Entries), free+len(current))
+	}
+	for _, e := range snapshot.Entries {
+		for _, blockID := range e.Blocks {
+			if int(blockID) >= len(fs.FAT) {
+				return classErrorf(ErrCorrupted, "erro: o snapshot '%s' referencia o bloco %d, fora da FAT", name, blockID)
+			}
+		}
+	}
+
+	current, err = fs.confirmReplaceVolume("O conteúdo atual do volume será substituído pelo do snapshot '%s' (%s).", name, snapshot.Created.Local().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return err
+	}
+
+	fs.discardEntries(current)
+	for _, e := range snapshot.Entries {
+		dir, base := splitPath(e.Path)
+		var entry FileEntry
+		copy(entry.Name[:], base)
+		copy(entry.Path[:], dir)
+		entry.IsDirectory, entry.Protected, entry.Size = e.Directory, e.Protected, e.Size
+		if len(e.Blocks) > 0 && fs.isCASFile(&entry) {
+			if entry.FirstBlockID, err = fs.restoreCASFile(e, &entry); err != nil {
+				return err
+			}
+			if err := fs.AddFileEntry(&entry); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Os blocos foram retidos pelo snapshot; a cadeia é refeita porque a FAT pode ter sido alterada depois
+		for k, blockID := range e.Blocks {
+			var next uint32
+			if k+1 < len(e.Blocks) {
+				next = e.Blocks[k+1]
+			}
+			if !fs.fatEntry(blockID).Used {
+				fs.Header.FreeSpace -= fs.Header.BlockSize
+			}
+			*fs.modifyFAT(blockID) = FATEntry{BlockID: blockID, NextBlockID: next, Used: true}
+		}
+		if len(e.Blocks) > 0 {
+			entry.FirstBlockID = e.Blocks[0]
+		}
+		if err := fs.AddFileEntry(&entry); err != nil {
+			return err
+		}
+	}
+	if fs.CheckDirectoryExists(fs.WorkingDir) == -1 {
+		fs.WorkingDir = "/"
+	}
+	fs.pinnedBlocks = nil
+	if fs.cas != nil {
+		fs.cas.index = nil
+	}
+
+	logger.Info("snapshot restaurado", fs.imageAttr(), "name", name, "entries", len(snapshot.Entries))
+	fmt.Printf(tr("Snapshot '%s' restaurado: %d entrada(s).\n"), name, len(snapshot.Entries))
+	return nil
+}
+
+// ShowSnapshots lista os snapshots com a data de criação, o número de entradas e o tamanho dos arquivos.
+func (fs *FURGFileSystem) ShowSnapshots(snapshots []Snapshot) {
+	if len(snapshots) == 0 {
+		fmt.Println(tr("Nenhum snapshot encontrado."))
+		return
+	}
+	fmt.Printf("%-28s %-20s %10s %12s\n", tr("Nome"), tr("Criado em"), tr("Entradas"), tr("Tamanho"))
+	for _, snapshot := range snapshots {
+		var size uint64
+		for _, e := range snapshot.Entries {
+			size += uint64(e.Size)
+		}
+		fmt.Printf("%-28s %-20s %10d %12d\n", snapshot.Name, snapshot.Created.Local().Format("2006-01-02 15:04:05"), len(snapshot.Entries), size)
+	}
+}
+
+// cliSnapshot implementa "snapshot create|restore|delete <nome>" e "snapshot list [--json]".
+func cliSnapshot(fs *FURGFileSystem, args []string) error {
+	usage := classErrorf(ErrUsage, "uso: furgfs snapshot create|restore|delete <nome> | snapshot list [--json]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch args[0] {
+	case "list":
+		flags := flag.NewFlagSet("snapshot list", flag.ContinueOnError)
+		asJSON := flags.Bool("json", false, "saída em JSON")
+		if err := flags.Parse(args[1:]); err != nil {
+			return classErrorf(ErrUsage, "%w", err)
+		}
+		if flags.NArg() != 0 {
+			return usage
+		}
+		snapshots, err := fs.Snapshots()
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			type snapshotJSON struct {
+				Name    string    `json:"name"`
+				Created time.Time `json:"created"`
+				Entries int       `json:"entries"`
+			}
+			out := struct {
+				Schema    int            `json:"schema"`
+				Snapshots []snapshotJSON `json:"snapshots"`
+			}{Schema: jsonSchemaVersion, Snapshots: []snapshotJSON{}}
+			for _, snapshot := range snapshots {
+				out.Snapshots = append(out.Snapshots, snapshotJSON{snapshot.Name, snapshot.Created, len(snapshot.Entries)})
+			}
+			return printJSON(out)
+		}
+		fs.ShowSnapshots(snapshots)
+		return nil
+	case "create", "restore", "delete":
+		if len(args) != 2 {
+			return usage
+		}
+	default:
+		return usage
+	}
+
+	var err error
+	switch args[0] {
+	case "create":
+		err = fs.CreateSnapshot(args[1])
+	case "restore":
+		err = fs.RestoreSnapshot(args[1])
+	case "delete":
+		err = fs.DeleteSnapshot(args[1])
+	}
+	if err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// SplitFile divide um arquivo armazenado em partes de até chunkSize bytes, gravadas como novas entradas
+// no mesmo diretório com os nomes "<nome>.000", "<nome>.001" e assim por diante. O arquivo original é mantido.
+func (fs *FURGFileSystem) SplitFile(fileName, path string, chunkSize uint32) error {
+	path = fs.resolvePath(path)
+	if chunkSize == 0 {
+		return fmt.Errorf("erro: O tamanho das partes deve ser maior que zero")
+	}
+
+	rootDirIndex := fs.lookupEntry(fileName, path)
+	if rootDirIndex == -1 || fs.RootDir[rootDirIndex].IsDirectory {
+		return classErrorf(ErrNotFound, "erro: O arquivo '%s' em '%s' não foi encontrado no sistema de arquivos", fileName, path)
+	}
+	entry := &fs.RootDir[rootDirIndex]
+
+	parts := int((entry.Size + chunkSize - 1) / chunkSize)
+	if parts == 0 {
+		return fmt.Errorf("erro: O arquivo '%s' está vazio", fileName)
+	}
+	if parts > 1000 {
+		return fmt.Errorf("erro: O arquivo seria dividido em %d partes, o máximo é 1000", parts)
+	}
+
+	names := make([]string, parts)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s.%03d", fileName, i)
+		if err := fs.Rules.ValidateName(names[i]); err != nil {
+			return err
+		}
+		if fs.lookupEntry(names[i], path) != -1 {
+			return fmt.Errorf("erro: Já existe uma entrada com o nome '%s' em '%s'", names[i], path)
+		}
+	}
+	if free := fs.countFreeEntries(); free < parts {
+		return classErrorf(ErrNoSpace, "erro: São necessárias %d entradas livres no diretório, há apenas %d", parts, free)
+	}
+
+	r, err := fs.newFileReader(entry)
+	if err != nil {
+		return err
+	}
+
+	var created []int
+	for i, name := range names {
+		var nameArray [32]byte
+		copy(nameArray[:], name)
+
+		index, err := fs.storeFile(FileEntry{Name: nameArray, Path: entry.Path}, io.LimitReader(r, int64(chunkSize)))
+		if err != nil {
+			fs.discardEntries(created)
+			return fmt.Errorf("erro ao gravar a parte %d: %w", i, err)
+		}
+		created = append(created, index)
+	}
+
+	fmt.Printf("Arquivo '%s' dividido em %d partes (%s a %s).\n", entry.FullPath(), parts, names[0], names[parts-1])
+	return nil
+}
+
+// JoinFiles concatena, em ordem alfabética, os arquivos de path cujo nome casa com pattern
+// e grava o resultado como um novo arquivo newName em newPath.
+func (fs *FURGFileSystem) JoinFiles(pattern, path, newName, newPath string) error {
+	path = fs.resolvePath(path)
+	newPath = fs.resolvePath(newPath)
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return fmt.Errorf("erro: Padrão '%s' inválido: %v", pattern, err)
+	}
+
+	var parts []int
+	for _, i := range fs.entriesInDirectory(path, false) {
+		if matched, _ := filepath.Match(pattern, fs.RootDir[i].NameString()); matched && !fs.RootDir[i].IsDirectory {
+			parts = append(parts, i)
+		}
+	}
+	if len(parts) == 0 {
+		return classErrorf(ErrNotFound, "erro: Nenhum arquivo em '%s' casa com o padrão '%s'", path, pattern)
+	}
+	sort.Slice(parts, func(a, b int) bool {
+		return fs.RootDir[parts[a]].NameString() < fs.RootDir[parts[b]].NameString()
+	})
+
+	readers := make([]io.Reader, len(parts))
+	for i, index := range parts {
+		r, err := fs.newFileReader(&fs.RootDir[index])
+		if err != nil {
+			return err
+		}
+		readers[i] = r
+	}
+
+	index, err := fs.createFile(newPath, newName, io.MultiReader(readers...), false)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d partes unidas em '%s' (%d bytes).\n", len(parts), fs.RootDir[index].FullPath(), fs.RootDir[index].Size)
+	return nil
+}
+
+// cliSplit implementa "split <caminho-interno> <tamanho>".
+func cliSplit(fs *FURGFileSystem, args []string) error {
+	if len(args) != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs split <caminho-interno> <tamanho>")
+	}
+	chunkSize, err := parseSize(args[1])
+	if err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+
+	path, name := splitPath(fs.resolvePath(args[0]))
+	if err := fs.SplitFile(name, path, chunkSize); err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
+
+// cliJoin implementa "join <padrão> <destino>".
+func cliJoin(fs *FURGFileSystem, args []string) error {
+	if len(args) != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs join <padrão> <destino>")
+	}
+
+	path, pattern := splitPath(fs.resolvePath(args[0]))
+	newPath, newName := splitPath(fs.resolvePath(args[1]))
+	if err := fs.JoinFiles(pattern, path, newName, newPath); err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
+
+// countFreeEntries conta quantas entradas do diretório ainda estão livres.
+func (fs *FURGFileSystem) countFreeEntries() int {
+	free := 0
+	for i := range fs.RootDir {
+		if fs.RootDir[i].Name[0] == 0 {
+			free++
+		}
+	}
+	return free
+}
+
+// discardEntries libera os blocos e apaga as entradas informadas, desfazendo uma operação incompleta.
+func (fs *FURGFileSystem) discardEntries(indices []int) {
+	for _, i := range indices {
+		fs.releaseBlocks(&fs.RootDir[i])
+		fs.clearEntry(i)
+	}
+}
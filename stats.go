@@ -0,0 +1,206 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Número padrão de maiores arquivos exibidos pelo relatório de estatísticas.
+const defaultTopFiles = 10
+
+// sizeBucket é uma faixa do histograma de uso de blocos: arquivos que ocupam de MinBlocks a MaxBlocks blocos.
+type sizeBucket struct {
+	MinBlocks int `json:"min_blocks"`
+	MaxBlocks int `json:"max_blocks"`
+	Files     int `json:"files"`
+	Blocks    int `json:"blocks"`
+}
+
+// jsonStats é a saída de "stats --json". Os tamanhos são em bytes.
+type jsonStats struct {
+	Schema          int           `json:"schema"`
+	Volume          VolumeInfo    `json:"volume"`
+	Files           int           `json:"files"`
+	Directories     int           `json:"directories"`
+	EmptyFiles      int           `json:"empty_files"`
+	ProtectedFiles  int           `json:"protected_files"`
+	DataBytes       uint64        `json:"data_bytes"`
+	AllocatedBytes  uint64        `json:"allocated_bytes"`
+	SlackBytes      uint64        `json:"slack_bytes"` // espaço perdido no fim do último bloco de cada arquivo
+	DedupBytes      uint64        `json:"dedup_bytes"` // espaço economizado por blocos compartilhados no armazenamento por conteúdo
+	AverageFileSize float64       `json:"average_file_size"`
+	MetadataBytes   uint32        `json:"metadata_bytes"` // cabeçalho, FAT e tabela do diretório
+	Largest         []jsonEntry   `json:"largest"`
+	Histogram       []sizeBucket  `json:"histogram"`
+	Space           jsonFreeSpace `json:"space"`
+}
+
+// VolumeStats percorre o diretório e a FAT e calcula as estatísticas de uso do volume, incluindo os top
+// maiores arquivos e um histograma do número de blocos por arquivo em faixas de potências de dois.
+func (fs *FURGFileSystem) VolumeStats(top int) (jsonStats, error) {
+	info, err := fs.VolumeInfo()
+	if err != nil {
+		return jsonStats{}, err
+	}
+	stats := jsonStats{
+		Volume:        info,
+		Schema:        jsonSchemaVersion,
+		MetadataBytes: fs.Header.DataStart,
+		Space:         fs.freeSpaceInfo(),
+		Largest:       []jsonEntry{},
+		Histogram:     []sizeBucket{},
+	}
+
+	var files []*FileEntry
+	unique := make(map[uint32]bool)
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 {
+			continue
+		}
+		if entry.IsDirectory {
+			stats.Directories++
+			continue
+		}
+		blocks, err := fs.fileBlocks(entry)
+		if err != nil {
+			return stats, fmt.Errorf("%w (execute o fsck antes)", err)
+		}
+
+		stats.Files++
+		files = append(files, entry)
+		if entry.Protected {
+			stats.ProtectedFiles++
+		}
+		if entry.Size == 0 {
+			stats.EmptyFiles++
+		}
+		allocated := uint64(len(blocks)) * uint64(fs.Header.BlockSize)
+		stats.DataBytes += uint64(entry.Size)
+		stats.AllocatedBytes += allocated
+		if allocated > uint64(entry.Size) {
+			stats.SlackBytes += allocated - uint64(entry.Size)
+		}
+		stats.addToHistogram(len(blocks))
+		for _, blockID := range blocks {
+			if unique[blockID] {
+				stats.DedupBytes += uint64(fs.Header.BlockSize)
+			}
+			unique[blockID] = true
+		}
+	}
+	if stats.Files > 0 {
+		stats.AverageFileSize = float64(stats.DataBytes) / float64(stats.Files)
+	}
+
+	sort.Slice(files, func(a, b int) bool {
+		if files[a].Size != files[b].Size {
+			return files[a].Size > files[b].Size
+		}
+		return files[a].FullPath() < files[b].FullPath()
+	})
+	for _, entry := range files[:min(top, len(files))] {
+		stats.Largest = append(stats.Largest, newJSONEntry(entry))
+	}
+	return stats, nil
+}
+
+// addToHistogram conta um arquivo de n blocos na faixa correspondente: 0, 1, 2-3, 4-7, 8-15...
+func (stats *jsonStats) addToHistogram(n int) {
+	low, high := 0, 0
+	if n > 0 {
+		low = 1
+		for low*2 <= n {
+			low *= 2
+		}
+		high = low*2 - 1
+	}
+	i := sort.Search(len(stats.Histogram), func(i int) bool { return stats.Histogram[i].MinBlocks >= low })
+	if i == len(stats.Histogram) || stats.Histogram[i].MinBlocks != low {
+		stats.Histogram = append(stats.Histogram, sizeBucket{})
+		copy(stats.Histogram[i+1:], stats.Histogram[i:])
+		stats.Histogram[i] = sizeBucket{MinBlocks: low, MaxBlocks: high}
+	}
+	stats.Histogram[i].Files++
+	stats.Histogram[i].Blocks += n
+}
+
+// Largura máxima, em caracteres, das barras do histograma.
+const histogramWidth = 40
+
+// PrintStats exibe o relatório de estatísticas de uso do volume.
+func (fs *FURGFileSystem) PrintStats(top int) error {
+	stats, err := fs.VolumeStats(top)
+	if err != nil {
+		return err
+	}
+
+	percent := 0.0
+	if stats.Space.TotalBytes > 0 {
+		percent = float64(stats.Space.UsedBytes) / float64(stats.Space.TotalBytes) * 100
+	}
+	if stats.Volume.UUID != "" {
+		fmt.Printf("Rótulo:                %s\n", stats.Volume.Label)
+		fmt.Printf("UUID:                  %s\n", stats.Volume.UUID)
+	}
+	fmt.Printf("Arquivos:              %d (%d vazios, %d protegidos)\n", stats.Files, stats.EmptyFiles, stats.ProtectedFiles)
+	fmt.Printf("Diretórios:            %d\n", stats.Directories)
+	fmt.Printf("Entradas:              %d de %d em uso\n", stats.Space.UsedEntries, stats.Space.TotalEntries)
+	fmt.Printf("Dados dos arquivos:    %d bytes\n", stats.DataBytes)
+	fmt.Printf("Tamanho médio:         %.1f bytes\n", stats.AverageFileSize)
+	fmt.Printf("Espaço alocado:        %d bytes (%d perdidos no último bloco dos arquivos)\n", stats.AllocatedBytes, stats.SlackBytes)
+	if stats.Volume.Storage == storageCAS {
+		fmt.Printf("Deduplicação:          %d bytes economizados por blocos compartilhados\n", stats.DedupBytes)
+	}
+	fmt.Printf("Espaço ocupado:        %d de %d bytes (%.2f%%)\n", stats.Space.UsedBytes, stats.Space.TotalBytes, percent)
+	fmt.Printf("Metadados:             %d bytes (cabeçalho, FAT e diretório; %.2f%% da imagem)\n",
+		stats.MetadataBytes, float64(stats.MetadataBytes)/float64(max(stats.Space.ImageSize, 1))*100)
+
+	if len(stats.Largest) > 0 {
+		fmt.Printf("\nMaiores arquivos:\n")
+		for _, entry := range stats.Largest {
+			fmt.Printf("  %12d  %s\n", entry.Size, entry.Path)
+		}
+	}
+
+	if len(stats.Histogram) > 0 {
+		most := 0
+		for _, bucket := range stats.Histogram {
+			most = max(most, bucket.Files)
+		}
+		fmt.Printf("\nArquivos por número de blocos:\n")
+		for _, bucket := range stats.Histogram {
+			label := fmt.Sprintf("%d", bucket.MinBlocks)
+			if bucket.MaxBlocks > bucket.MinBlocks {
+				label = fmt.Sprintf("%d-%d", bucket.MinBlocks, bucket.MaxBlocks)
+			}
+			bar := strings.Repeat("#", max(1, bucket.Files*histogramWidth/most))
+			fmt.Printf("  %11s  %6d  %s\n", label, bucket.Files, bar)
+		}
+	}
+	return nil
+}
+
+// cliStats implementa "stats [--json] [--top N]".
+func cliStats(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "saída em JSON")
+	top := flags.Int("top", defaultTopFiles, "número de maiores arquivos exibidos")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 0 || *top < 0 {
+		return classErrorf(ErrUsage, "uso: furgfs stats [--json] [--top N]")
+	}
+
+	if !*asJSON {
+		return fs.PrintStats(*top)
+	}
+	stats, err := fs.VolumeStats(*top)
+	if err != nil {
+		return err
+	}
+	return printJSON(stats)
+}
@@ -0,0 +1,578 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// imageStore é onde a imagem fica guardada. Um *os.File atende à interface; as imagens remotas
+// (http://, https:// e s3://) usam um remoteStore, que guarda as páginas lidas em um cache local. O acesso
+// é só posicional, com ReadAt e WriteAt: não há uma posição compartilhada, e várias goroutines podem ler e
+// gravar a mesma imagem ao mesmo tempo.
+type imageStore interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+	Name() string
+	Stat() (os.FileInfo, error)
+	Truncate(size int64) error
+	Sync() error
+}
+
+// remotePageSize é a unidade lida do armazenamento remoto e guardada no cache local.
+const remotePageSize = 64 << 10
+
+// isRemoteImage informa se name indica uma imagem remota em vez de um arquivo local.
+func isRemoteImage(name string) bool {
+	for _, scheme := range []string{"http://", "https://", "s3://"} {
+		if strings.HasPrefix(name, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// mapImages faz openImageStore mapear as imagens locais na memória (veja mmapStore); vem da opção global
+// --mmap ou da variável FURGFS_MMAP.
+var mapImages bool
+
+// openImageStore abre a imagem name para leitura e escrita: um arquivo local ou um objeto remoto.
+func openImageStore(name string) (imageStore, error) {
+	if !isRemoteImage(name) {
+		f, err := os.OpenFile(name, os.O_RDWR, 0666)
+		if err != nil {
+			return nil, err
+		}
+		if mapImages {
+			return openMmapStore(f)
+		}
+		return f, nil
+	}
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "s3" {
+		return remoteImageStore(openRemoteStore(name, &httpBackend{url: name}, true))
+	}
+	backend, err := newS3Backend(u)
+	if err != nil {
+		return nil, err
+	}
+	return remoteImageStore(openRemoteStore(name, backend, false))
+}
+
+// createImageStore cria a imagem name vazia, substituindo a que existir, e a abre como openImageStore.
+func createImageStore(name string) (imageStore, error) {
+	if !isRemoteImage(name) {
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "s3" {
+		return nil, errReadOnlyStore
+	}
+	backend, err := newS3Backend(u)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := backend.upload(bytes.NewReader(nil), 0, ""); err != nil {
+		return nil, err
+	}
+	return remoteImageStore(openRemoteStore(name, backend, false))
+}
+
+// remoteImageStore evita que um *remoteStore nulo chegue ao chamador como uma interface não nula.
+func remoteImageStore(s *remoteStore, err error) (imageStore, error) {
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// errReadOnlyStore é retornado pelas escritas em uma imagem servida por HTTP.
+var errReadOnlyStore = errors.New("imagem remota somente leitura")
+
+// remoteBackend é o acesso a um objeto remoto: seu tamanho e versão, leituras de intervalos e o envio do
+// conteúdo inteiro, já que nenhum dos serviços aceita escritas parciais.
+type remoteBackend interface {
+	stat() (size int64, etag string, err error)
+	readRange(p []byte, off int64) error
+	upload(r io.Reader, size int64, etag string) (string, error)
+}
+
+// remoteCacheMeta descreve o conteúdo do cache local de uma imagem remota. O cache só é reaproveitado
+// se o objeto remoto ainda estiver na versão ETag.
+type remoteCacheMeta struct {
+	URL     string `json:"url"`
+	ETag    string `json:"etag"`
+	Size    int64  `json:"size"`
+	Present []byte `json:"present"`         // mapa de bits das páginas já copiadas para o cache
+	Dirty   bool   `json:"dirty,omitempty"` // o cache tem escritas ainda não enviadas
+}
+
+func (m *remoteCacheMeta) present(page int64) bool {
+	return m.Present[page/8]&(1<<(page%8)) != 0
+}
+
+func (m *remoteCacheMeta) setPresent(page int64) {
+	m.Present[page/8] |= 1 << (page % 8)
+}
+
+// grow aumenta o mapa de bits para uma imagem de size bytes.
+func (m *remoteCacheMeta) grow(size int64) {
+	for int64(len(m.Present))*8 < pageCount(size) {
+		m.Present = append(m.Present, 0)
+	}
+}
+
+// remoteStore é uma imagem remota com cache local: as leituras trazem páginas inteiras para o arquivo
+// de cache e as escritas ficam nele até Sync ou Close, que enviam a imagem inteira de volta.
+type remoteStore struct {
+	name     string
+	backend  remoteBackend
+	readOnly bool // a imagem vem de um servidor HTTP e não aceita escritas
+	mu       sync.Mutex
+	cache    *os.File
+	metaPath string
+	meta     remoteCacheMeta
+}
+
+// remoteCachePaths retorna o arquivo de cache e o de metadados da imagem remota name, no diretório de
+// cache do usuário ou em FURGFS_CACHE_DIR.
+func remoteCachePaths(name string) (string, string, error) {
+	dir := os.Getenv("FURGFS_CACHE_DIR")
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", "", fmt.Errorf("erro ao localizar o diretório de cache: %v", err)
+		}
+		dir = filepath.Join(base, "furgfs", "remote")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", fmt.Errorf("erro ao criar o diretório de cache: %v", err)
+	}
+	sum := sha256.Sum256([]byte(name))
+	key := hex.EncodeToString(sum[:16])
+	return filepath.Join(dir, key+".img"), filepath.Join(dir, key+".json"), nil
+}
+
+// openRemoteStore consulta a versão atual do objeto e abre o cache local, descartando-o se o objeto
+// tiver mudado desde a última vez.
+func openRemoteStore(name string, backend remoteBackend, readOnly bool) (*remoteStore, error) {
+	size, etag, err := backend.stat()
+	if err != nil {
+		return nil, err
+	}
+	cachePath, metaPath, err := remoteCachePaths(name)
+	if err != nil {
+		return nil, err
+	}
+	s := &remoteStore{name: name, backend: backend, readOnly: readOnly, metaPath: metaPath}
+
+	var meta remoteCacheMeta
+	data, err := os.ReadFile(metaPath)
+	if err == nil {
+		err = json.Unmarshal(data, &meta)
+	}
+	reuse := err == nil && meta.URL == name && meta.ETag == etag && meta.Size == size
+	if !reuse && err == nil && meta.Dirty {
+		logger.Warn("a imagem remota mudou; as escritas locais ainda não enviadas foram descartadas", "image", name)
+	}
+	if !reuse {
+		meta = remoteCacheMeta{URL: name, ETag: etag, Size: size}
+	}
+	s.meta = meta
+	s.meta.grow(size)
+
+	flags := os.O_RDWR | os.O_CREATE
+	if !reuse {
+		flags |= os.O_TRUNC
+	}
+	s.cache, err = os.OpenFile(cachePath, flags, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir o cache local: %v", err)
+	}
+	if err := s.cache.Truncate(size); err != nil {
+		s.cache.Close()
+		return nil, fmt.Errorf("erro ao preparar o cache local: %v", err)
+	}
+	if err := s.saveMeta(); err != nil {
+		s.cache.Close()
+		return nil, err
+	}
+	logger.Debug("imagem remota aberta", "image", name, "size", size, "cached", reuse)
+	return s, nil
+}
+
+func pageCount(size int64) int64 {
+	return (size + remotePageSize - 1) / remotePageSize
+}
+
+func (s *remoteStore) saveMeta() error {
+	data, err := json.Marshal(s.meta)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.metaPath, data, 0600); err != nil {
+		return fmt.Errorf("erro ao salvar o cache local: %v", err)
+	}
+	return nil
+}
+
+// fetch garante que as páginas de first a last estejam no cache, lendo as ausentes em intervalos
+// contíguos.
+func (s *remoteStore) fetch(first, last int64) error {
+	for page := first; page <= last; {
+		if s.meta.present(page) {
+			page++
+			continue
+		}
+		end := page
+		for end < last && !s.meta.present(end+1) {
+			end++
+		}
+		off := page * remotePageSize
+		buf := make([]byte, min((end+1)*remotePageSize, s.meta.Size)-off)
+		if err := s.backend.readRange(buf, off); err != nil {
+			return err
+		}
+		if _, err := s.cache.WriteAt(buf, off); err != nil {
+			return fmt.Errorf("erro ao gravar o cache local: %v", err)
+		}
+		for ; page <= end; page++ {
+			s.meta.setPresent(page)
+		}
+	}
+	return nil
+}
+
+// cover traz para o cache as páginas do intervalo [off, off+n), limitado ao tamanho da imagem.
+func (s *remoteStore) cover(off, n int64) error {
+	if n <= 0 || off >= s.meta.Size {
+		return nil
+	}
+	end := min(off+n, s.meta.Size)
+	return s.fetch(off/remotePageSize, (end-1)/remotePageSize)
+}
+
+func (s *remoteStore) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.cover(off, int64(len(p))); err != nil {
+		return 0, err
+	}
+	if off >= s.meta.Size {
+		return 0, io.EOF
+	}
+	n, err := s.cache.ReadAt(p[:min(int64(len(p)), s.meta.Size-off)], off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (s *remoteStore) WriteAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.readOnly {
+		return 0, errReadOnlyStore
+	}
+	if err := s.cover(off, int64(len(p))); err != nil {
+		return 0, err
+	}
+	n, err := s.cache.WriteAt(p, off)
+	if end := off + int64(n); end > s.meta.Size {
+		s.resize(end)
+	}
+	s.meta.Dirty = true
+	return n, err
+}
+
+// resize ajusta o mapa de páginas a um novo tamanho; as páginas novas existem apenas no cache.
+func (s *remoteStore) resize(size int64) {
+	s.meta.grow(size)
+	for page := pageCount(s.meta.Size); page < pageCount(size); page++ {
+		s.meta.setPresent(page)
+	}
+	// A última página antiga, se parcial, foi completada com zeros pelo cache
+	if s.meta.Size%remotePageSize != 0 && size > s.meta.Size {
+		s.meta.setPresent(s.meta.Size / remotePageSize)
+	}
+	s.meta.Size = size
+}
+
+func (s *remoteStore) Truncate(size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.readOnly {
+		return errReadOnlyStore
+	}
+	// A página que passa a ser a última precisa estar inteira no cache antes do corte
+	if size > 0 {
+		if err := s.cover(size-1, 1); err != nil {
+			return err
+		}
+	}
+	if err := s.cache.Truncate(size); err != nil {
+		return err
+	}
+	if size < s.meta.Size {
+		s.meta.Size = size
+	} else {
+		s.resize(size)
+	}
+	s.meta.Dirty = true
+	return nil
+}
+
+func (s *remoteStore) Name() string { return s.name }
+
+func (s *remoteStore) Stat() (os.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return entryInfo{name: path.Base(s.name), size: s.meta.Size, protected: s.readOnly}, nil
+}
+
+// Sync envia a imagem ao armazenamento remoto se houver escritas pendentes. Antes, as páginas que
+// nunca foram lidas são trazidas para o cache, para que o envio tenha a imagem inteira.
+func (s *remoteStore) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sync()
+}
+
+func (s *remoteStore) sync() error {
+	if !s.meta.Dirty {
+		return nil
+	}
+	if err := s.cover(0, s.meta.Size); err != nil {
+		return err
+	}
+	if err := s.cache.Sync(); err != nil {
+		return err
+	}
+	if err := s.saveMeta(); err != nil {
+		return err
+	}
+	etag, err := s.backend.upload(io.NewSectionReader(s.cache, 0, s.meta.Size), s.meta.Size, s.meta.ETag)
+	if err != nil {
+		return err
+	}
+	logger.Info("imagem remota enviada", "image", s.name, "size", s.meta.Size)
+	s.meta.ETag, s.meta.Dirty = etag, false
+	return s.saveMeta()
+}
+
+// Close envia as escritas pendentes e fecha o cache. Se o envio falhar, as escritas continuam no cache
+// e são enviadas na próxima vez que a imagem for aberta e alterada.
+func (s *remoteStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.sync()
+	if err != nil {
+		logger.Error("erro ao enviar a imagem remota", "image", s.name, "error", err)
+	}
+	s.saveMeta()
+	if closeErr := s.cache.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// httpBackend lê uma imagem publicada em um servidor HTTP com suporte a pedidos Range. É somente leitura.
+type httpBackend struct {
+	url string
+}
+
+func (b *httpBackend) stat() (int64, string, error) {
+	resp, err := http.Head(b.url)
+	if err != nil {
+		return 0, "", fmt.Errorf("erro ao consultar a imagem remota: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("erro ao consultar a imagem remota: %s", resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return 0, "", errors.New("erro: o servidor não informou o tamanho da imagem remota")
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		etag = resp.Header.Get("Last-Modified")
+	}
+	return resp.ContentLength, etag, nil
+}
+
+func (b *httpBackend) readRange(p []byte, off int64) error {
+	req, err := http.NewRequest(http.MethodGet, b.url, nil)
+	if err != nil {
+		return err
+	}
+	return readRangeResponse(req, p, off)
+}
+
+func (b *httpBackend) upload(io.Reader, int64, string) (string, error) {
+	return "", errReadOnlyStore
+}
+
+// readRangeResponse envia req pedindo len(p) bytes a partir de off e lê a resposta em p.
+func readRangeResponse(req *http.Request, p []byte, off int64) error {
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro ao ler a imagem remota: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("erro ao ler a imagem remota: %s", resp.Status)
+	}
+	if _, err := io.ReadFull(resp.Body, p); err != nil {
+		return fmt.Errorf("erro ao ler a imagem remota: %v", err)
+	}
+	return nil
+}
+
+// s3Backend guarda a imagem como um objeto S3, em s3://bucket/chave. As credenciais vêm de
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY e AWS_SESSION_TOKEN, a região de AWS_REGION e o endereço do
+// serviço de FURGFS_S3_ENDPOINT, para serviços compatíveis como "furgfs serve s3".
+type s3Backend struct {
+	object    string // URL do objeto, no estilo de caminho: endpoint/bucket/chave
+	region    string
+	accessKey string
+	secretKey string
+	token     string
+}
+
+func newS3Backend(u *url.URL) (*s3Backend, error) {
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return nil, classErrorf(ErrUsage, "erro: imagem S3 inválida, use s3://bucket/chave")
+	}
+	b := &s3Backend{
+		region:    os.Getenv("AWS_REGION"),
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if b.accessKey == "" || b.secretKey == "" {
+		return nil, classErrorf(ErrUsage, "erro: defina AWS_ACCESS_KEY_ID e AWS_SECRET_ACCESS_KEY para usar imagens S3")
+	}
+	if b.region == "" {
+		b.region = "us-east-1"
+	}
+	endpoint := strings.TrimSuffix(os.Getenv("FURGFS_S3_ENDPOINT"), "/")
+	if endpoint == "" {
+		endpoint = "https://s3." + b.region + ".amazonaws.com"
+	}
+	b.object = endpoint + "/" + s3Escape(u.Host, false) + "/" + s3Escape(key, true)
+	return b, nil
+}
+
+// request cria um pedido ao objeto assinado com AWS4-HMAC-SHA256, sem assinar o conteúdo.
+func (b *s3Backend) request(method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, b.object, body)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + b.region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3Unsigned)
+	headers := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\nx-amz-content-sha256:" + s3Unsigned + "\nx-amz-date:" + amzDate + "\n"
+	if b.token != "" {
+		req.Header.Set("X-Amz-Security-Token", b.token)
+		headers += ";x-amz-security-token"
+		canonicalHeaders += "x-amz-security-token:" + b.token + "\n"
+	}
+	canonical := strings.Join([]string{method, req.URL.EscapedPath(), "", canonicalHeaders, headers, s3Unsigned}, "\n")
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, sha256Hex([]byte(canonical))}, "\n")
+	key := []byte("AWS4" + b.secretKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, b.accessKey, scope, headers, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+	return req, nil
+}
+
+func (b *s3Backend) stat() (int64, string, error) {
+	req, err := b.request(http.MethodHead, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("erro ao consultar a imagem remota: %v", err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return 0, "", classErrorf(ErrNotFound, "a imagem remota '%s' não existe", b.object)
+	case resp.StatusCode != http.StatusOK:
+		return 0, "", fmt.Errorf("erro ao consultar a imagem remota: %s", resp.Status)
+	}
+	return resp.ContentLength, resp.Header.Get("ETag"), nil
+}
+
+func (b *s3Backend) readRange(p []byte, off int64) error {
+	req, err := b.request(http.MethodGet, nil)
+	if err != nil {
+		return err
+	}
+	return readRangeResponse(req, p, off)
+}
+
+// upload envia a imagem inteira. Com etag, o envio é condicional: se outro cliente tiver alterado o
+// objeto, o serviço recusa a escrita em vez de sobrescrever as alterações dele.
+func (b *s3Backend) upload(r io.Reader, size int64, etag string) (string, error) {
+	req, err := b.request(http.MethodPut, r)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("erro ao enviar a imagem remota: %v", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return "", classErrorf(ErrCorrupted, "erro: a imagem remota foi alterada por outro cliente; as escritas ficaram no cache local")
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("erro ao enviar a imagem remota: %s %s", resp.Status, bytes.TrimSpace(body))
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return etag, nil
+	}
+	_, etag, err = b.stat()
+	return etag, err
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// O cabeçalho é protegido por um CRC-32 gravado nos 4 bytes imediatamente anteriores a DataStart.
+// Esse espaço nunca é usado: a FAT e o diretório são reservados com o tamanho das structs em memória
+// (12 e 172 bytes por entrada), mas gravados com binary.Write (9 e 170 bytes), o que sempre deixa
+// pelo menos 5 bytes livres antes da região de dados. Imagens antigas têm zeros nessa posição,
+// o que é interpretado como "sem checksum".
+const headerChecksumSize = 4
+
+// headerChecksum calcula o CRC-32 (IEEE) da serialização do cabeçalho.
+func headerChecksum(h Header) uint32 {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, h)
+	return crc32.ChecksumIEEE(buf.Bytes())
+}
+
+// readHeader lê o cabeçalho e o checksum gravado diretamente do arquivo, sem carregar a FAT e o diretório,
+// para que cabeçalhos danificados ainda possam ser inspecionados e corrigidos.
+func readHeader(f io.ReaderAt) (Header, uint32, error) {
+	var h Header
+	err := binary.Read(io.NewSectionReader(f, 0, int64(unsafe.Sizeof(Header{}))), binary.LittleEndian, &h)
+	if err != nil {
+		return h, 0, fmt.Errorf("erro ao ler o cabeçalho: %v", err)
+	}
+	if h.DataStart < headerChecksumSize {
+		return h, 0, nil
+	}
+	var stored [headerChecksumSize]byte
+	_, err = f.ReadAt(stored[:], int64(h.DataStart-headerChecksumSize))
+	if err != nil {
+		return h, 0, nil
+	}
+	return h, binary.LittleEndian.Uint32(stored[:]), nil
+}
+
+// writeHeader grava o cabeçalho h e seu checksum no arquivo.
+func writeHeader(f io.WriterAt, h Header) error {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, h)
+	_, err := f.WriteAt(buf.Bytes(), 0)
+	if err != nil {
+		return fmt.Errorf("erro ao salvar cabeçalho: %v", err)
+	}
+	return writeHeaderChecksum(f, h)
+}
+
+func writeHeaderChecksum(f io.WriterAt, h Header) error {
+	if h.DataStart < headerChecksumSize {
+		return nil
+	}
+	var sum [headerChecksumSize]byte
+	binary.LittleEndian.PutUint32(sum[:], headerChecksum(h))
+	_, err := f.WriteAt(sum[:], int64(h.DataStart-headerChecksumSize))
+	if err != nil {
+		return fmt.Errorf("erro ao salvar o checksum do cabeçalho: %v", err)
+	}
+	return nil
+}
+
+// headerFields lista os campos do cabeçalho na ordem em que são gravados, para exibição e edição por nome.
+func headerFields(h *Header) []struct {
+	Name  string
+	Value *uint32
+} {
+	return []struct {
+		Name  string
+		Value *uint32
+	}{
+		{"TotalSize", &h.TotalSize},
+		{"BlockSize", &h.BlockSize},
+		{"FreeSpace", &h.FreeSpace},
+		{"FATEntrypointAddress", &h.FATEntrypointAddress},
+		{"RootDirStart", &h.RootDirStart},
+		{"DataStart", &h.DataStart},
+	}
+}
+
+// headerProblems verifica a coerência dos campos do cabeçalho entre si.
+func headerProblems(h Header) []string {
+	var problems []string
+	headerSize := uint32(unsafe.Sizeof(Header{}))
+	if h.BlockSize < 512 {
+		problems = append(problems, fmt.Sprintf("BlockSize %d é menor que 512", h.BlockSize))
+	}
+	if h.FATEntrypointAddress != headerSize {
+		problems = append(problems, fmt.Sprintf("FATEntrypointAddress deveria ser %d", headerSize))
+	}
+	if h.RootDirStart <= h.FATEntrypointAddress || h.DataStart <= h.RootDirStart {
+		problems = append(problems, "as regiões não estão em ordem (cabeçalho < FAT < diretório < dados)")
+	}
+	if h.DataStart >= h.TotalSize {
+		problems = append(problems, "DataStart está além de TotalSize")
+	} else if h.BlockSize >= 512 && h.FreeSpace > (h.TotalSize-h.DataStart)/h.BlockSize*h.BlockSize {
+		problems = append(problems, "FreeSpace é maior que a região de dados")
+	}
+	if h.DataStart > h.RootDirStart && (h.DataStart-h.RootDirStart)%uint32(unsafe.Sizeof(FileEntry{})) != 0 {
+		problems = append(problems, "o tamanho do diretório não é múltiplo do tamanho de uma entrada")
+	}
+	return problems
+}
+
+// cliSuperblock implementa "superblock [show] [--json]" e "superblock set <campo> <valor>". Trabalha
+// direto no arquivo da imagem, sem carregá-la, para funcionar mesmo com cabeçalhos inconsistentes.
+func cliSuperblock(imageName string, args []string) error {
+	args, policy := extractPolicyFlags(args)
+	if len(args) > 0 && args[0] == "set" {
+		if len(args) != 3 {
+			return classErrorf(ErrUsage, "uso: furgfs superblock set <campo> <valor> --force")
+		}
+		return patchSuperblock(imageName, args[1], args[2], policy)
+	}
+	if len(args) > 0 && args[0] == "show" {
+		args = args[1:]
+	}
+
+	flags := flag.NewFlagSet("superblock", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "saída em JSON")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 0 {
+		return classErrorf(ErrUsage, "uso: furgfs superblock [show] [--json] | superblock set <campo> <valor>")
+	}
+
+	f, err := os.Open(imageName)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo: %v", err)
+	}
+	defer f.Close()
+	h, stored, err := readHeader(f)
+	if err != nil {
+		return err
+	}
+	computed := headerChecksum(h)
+	problems := headerProblems(h)
+
+	if *asJSON {
+		out := struct {
+			Schema               int      `json:"schema"`
+			TotalSize            uint32   `json:"total_size"`
+			BlockSize            uint32   `json:"block_size"`
+			FreeSpace            uint32   `json:"free_space"`
+			FATEntrypointAddress uint32   `json:"fat_entrypoint_address"`
+			RootDirStart         uint32   `json:"root_dir_start"`
+			DataStart            uint32   `json:"data_start"`
+			StoredChecksum       *uint32  `json:"stored_checksum"` // null em imagens sem checksum
+			ComputedChecksum     uint32   `json:"computed_checksum"`
+			ChecksumValid        bool     `json:"checksum_valid"`
+			Problems             []string `json:"problems"`
+		}{
+			Schema: jsonSchemaVersion, TotalSize: h.TotalSize, BlockSize: h.BlockSize, FreeSpace: h.FreeSpace,
+			FATEntrypointAddress: h.FATEntrypointAddress, RootDirStart: h.RootDirStart, DataStart: h.DataStart,
+			ComputedChecksum: computed, ChecksumValid: stored == computed, Problems: problems,
+		}
+		if stored != 0 {
+			out.StoredChecksum = &stored
+		}
+		if out.Problems == nil {
+			out.Problems = []string{}
+		}
+		return printJSON(out)
+	}
+
+	for _, field := range headerFields(&h) {
+		fmt.Printf("%-21s %d\n", field.Name+":", *field.Value)
+	}
+	switch stored {
+	case 0:
+		fmt.Printf("%-21s ausente (calculado: %08x)\n", "Checksum:", computed)
+	case computed:
+		fmt.Printf("%-21s %08x (válido)\n", "Checksum:", stored)
+	default:
+		fmt.Printf("%-21s %08x (INVÁLIDO, calculado: %08x)\n", "Checksum:", stored, computed)
+	}
+	for _, problem := range problems {
+		fmt.Println("Problema:", problem)
+	}
+	return nil
+}
+
+// patchSuperblock altera um único campo do cabeçalho e recalcula o checksum.
+func patchSuperblock(imageName, fieldName, valueStr string, policy flagPolicy) error {
+	value, err := strconv.ParseUint(valueStr, 10, 32)
+	if err != nil {
+		return fmt.Errorf("erro: valor '%s' inválido", valueStr)
+	}
+
+	f, err := os.OpenFile(imageName, os.O_RDWR, 0666)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir o arquivo: %v", err)
+	}
+	defer f.Close()
+	h, _, err := readHeader(f)
+	if err != nil {
+		return err
+	}
+
+	var target *uint32
+	var names []string
+	for _, field := range headerFields(&h) {
+		names = append(names, field.Name)
+		if strings.EqualFold(field.Name, fieldName) {
+			target = field.Value
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("erro: campo '%s' desconhecido; campos: %s", fieldName, strings.Join(names, ", "))
+	}
+
+	old := *target
+	*target = uint32(value)
+	for _, problem := range headerProblems(h) {
+		fmt.Println("Aviso:", problem)
+	}
+	err = policy.Confirm(fmt.Sprintf(tr("O campo %s será alterado de %d para %d."), fieldName, old, value))
+	if err != nil {
+		return err
+	}
+
+	err = writeHeader(f, h)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d -> %d (checksum %08x)\n", fieldName, old, value, headerChecksum(h))
+	return nil
+}
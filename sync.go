@@ -0,0 +1,575 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// "sync" sincroniza um diretório do sistema real com um diretório da imagem, como o rsync: só os arquivos
+// que mudaram são copiados, da origem para o destino. O FURGfs2 não guarda datas de modificação, por isso o
+// estado de cada arquivo na última sincronização (tamanho, data no sistema real e SHA-256) fica em
+// /.furgfs/sync.json; um arquivo real com o mesmo tamanho e a mesma data de antes não precisa ser relido.
+const syncStateName = "sync.json"
+
+// syncFile é o estado de um arquivo ou diretório na última sincronização. Os blocos de dados nunca são
+// reescritos no lugar, então um arquivo da imagem com o mesmo tamanho e o mesmo primeiro bloco de antes não
+// foi alterado, assim como um arquivo real com o mesmo tamanho e a mesma data.
+type syncFile struct {
+	Directory bool   `json:"directory,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	ModTime   int64  `json:"mtime,omitempty"` // data de modificação no sistema real, em nanossegundos
+	Block     uint32 `json:"block,omitempty"` // primeiro bloco do arquivo na imagem
+	SHA256    string `json:"sha256,omitempty"`
+}
+
+// syncPair é o estado de um par de diretórios sincronizados, indexado pelo caminho relativo dos arquivos.
+type syncPair struct {
+	Host  string              `json:"host"`
+	Image string              `json:"image"`
+	Files map[string]syncFile `json:"files"`
+}
+
+// syncEntry é um arquivo ou diretório de um dos lados da sincronização.
+type syncEntry struct {
+	dir     bool
+	size    int64
+	modTime int64  // só no sistema real
+	block   uint32 // só na imagem
+}
+
+// SyncOptions controla uma sincronização.
+type SyncOptions struct {
+	ToHost   bool // copia da imagem para o sistema real, em vez do contrário
+	Delete   bool // apaga no destino as entradas que não existem na origem
+	Checksum bool // compara o conteúdo de todos os arquivos com o mesmo tamanho
+	DryRun   bool // só informa o que seria feito
+	Filter   *transferFilter
+}
+
+// SyncResult resume uma sincronização.
+type SyncResult struct {
+	Copied, Removed, Unchanged, Protected int
+}
+
+// syncPairs lê o estado das sincronizações do volume.
+func (fs *FURGFileSystem) syncPairs() ([]syncPair, error) {
+	var pairs []syncPair
+	data, err := fs.readSystemFile(syncStateName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &pairs); err != nil {
+		return nil, classErrorf(ErrCorrupted, "erro ao ler o estado da sincronização: %v", err)
+	}
+	return pairs, nil
+}
+
+// writeSyncPairs grava o estado das sincronizações em /.furgfs/sync.json.
+func (fs *FURGFileSystem) writeSyncPairs(pairs []syncPair) error {
+	data, err := json.MarshalIndent(pairs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fs.writeSystemFile(syncStateName, data)
+}
+
+// hostSyncTree lista as entradas de root no sistema real aceitas por filter. Links simbólicos e arquivos
+// especiais são ignorados.
+func hostSyncTree(root string, filter *transferFilter) (map[string]syncEntry, error) {
+	entries := make(map[string]syncEntry)
+	err := filepath.WalkDir(root, func(p string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("erro ao ler '%s': %v", p, err)
+		}
+		if p == root {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("erro ao acessar '%s': %v", p, err)
+		}
+		if !filter.allows(rel, d.IsDir(), info.Size()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		entries[rel] = syncEntry{dir: d.IsDir(), size: info.Size(), modTime: info.ModTime().UnixNano()}
+		return nil
+	})
+	return entries, err
+}
+
+// imageSyncTree lista as entradas de root na imagem aceitas por filter, sem o diretório de sistema.
+func (fs *FURGFileSystem) imageSyncTree(root string, filter *transferFilter) map[string]syncEntry {
+	entries := make(map[string]syncEntry)
+	var walk func(dir, rel string)
+	walk = func(dir, rel string) {
+		for _, i := range fs.entriesInDirectory(dir, false) {
+			entry := &fs.RootDir[i]
+			full, childRel := entry.FullPath(), path.Join(rel, entry.NameString())
+			if full == systemDir || !filter.allows(childRel, entry.IsDirectory, int64(entry.Size)) {
+				continue
+			}
+			entries[childRel] = syncEntry{dir: entry.IsDirectory, size: int64(entry.Size), block: entry.FirstBlockID}
+			if entry.IsDirectory {
+				walk(full, childRel)
+			}
+		}
+	}
+	walk(root, "")
+	return entries
+}
+
+// hashReader calcula o SHA-256 do conteúdo de r.
+func hashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hostFileHash calcula o SHA-256 do arquivo p do sistema real.
+func hostFileHash(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", fmt.Errorf("erro ao abrir o arquivo: %v", err)
+	}
+	defer f.Close()
+	return hashReader(f)
+}
+
+// imageFileHash calcula o SHA-256 do arquivo p da imagem, ou o lê do índice de metadados, se houver.
+func (fs *FURGFileSystem) imageFileHash(p string) (string, error) {
+	if hash, ok := fs.indexedHash(p); ok {
+		return hash, nil
+	}
+	dir, name := splitPath(p)
+	index := fs.lookupEntry(name, dir)
+	if index == -1 {
+		return "", classErrorf(ErrNotFound, "erro: '%s' não foi encontrado no sistema de arquivos", p)
+	}
+	r, err := fs.newFileReader(&fs.RootDir[index])
+	if err != nil {
+		return "", err
+	}
+	return hashReader(r)
+}
+
+// removeSyncEntry apaga o arquivo ou diretório p da imagem com todo o seu conteúdo, sem pedir confirmação.
+// Entradas protegidas não são apagadas, e os diretórios que as contêm ficam.
+func (fs *FURGFileSystem) removeSyncEntry(p string) error {
+	dir, name := splitPath(p)
+	index := fs.lookupEntry(name, dir)
+	if index == -1 {
+		return nil
+	}
+	if fs.RootDir[index].IsDirectory {
+		var protectedErr error
+		for _, i := range fs.entriesInDirectory(p, false) {
+			if err := fs.removeSyncEntry(fs.RootDir[i].FullPath()); errors.Is(err, ErrProtected) {
+				protectedErr = err
+			} else if err != nil {
+				return err
+			}
+		}
+		if protectedErr != nil {
+			return protectedErr
+		}
+	}
+	if fs.RootDir[index].Protected {
+		return classErrorf(ErrProtected, "erro: '%s' está protegido", p)
+	}
+	logger.Info("entrada removida pela sincronização", fs.imageAttr(), "path", p)
+	fs.discardEntries([]int{index})
+	return nil
+}
+
+// storeSyncFile grava o conteúdo de r como o arquivo p da imagem, substituindo o arquivo anterior só depois
+// de o novo ser gravado.
+func (fs *FURGFileSystem) storeSyncFile(p string, r io.Reader) error {
+	dir, name := splitPath(p)
+	old := fs.lookupEntry(name, dir)
+	if old != -1 && fs.RootDir[old].Protected {
+		return classErrorf(ErrProtected, "erro: '%s' está protegido", p)
+	}
+	if err := fs.Rules.ValidateEntry(dir, name); err != nil {
+		return err
+	}
+	var entry FileEntry
+	copy(entry.Name[:], name)
+	copy(entry.Path[:], dir)
+	if _, err := fs.storeQuotaFile(entry, r); err != nil {
+		return err
+	}
+	if old != -1 {
+		fs.discardEntries([]int{old})
+	}
+	return nil
+}
+
+// writeHostFile grava o conteúdo de r no arquivo p do sistema real por meio de um arquivo temporário no
+// mesmo diretório, para que uma falha não deixe o arquivo pela metade.
+func writeHostFile(p string, r io.Reader) error {
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".*")
+	if err != nil {
+		return fmt.Errorf("erro ao criar o arquivo no sistema real: %v", err)
+	}
+	err = f.Chmod(0644)
+	if err == nil {
+		_, err = io.Copy(f, r)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("erro ao gravar '%s': %v", p, err)
+	}
+	return nil
+}
+
+// Sync sincroniza o diretório hostDir do sistema real com o diretório imageDir da imagem, na direção de
+// opts, e chama report para cada alteração: "+" para uma entrada criada, "~" para um arquivo atualizado,
+// "-" para uma entrada apagada e "!" para uma entrada protegida que não pôde ser alterada. Um arquivo é
+// copiado quando o tamanho ou o SHA-256 difere; o conteúdo só é lido se o tamanho ou a data do arquivo
+// real mudou desde a última sincronização, ou com opts.Checksum.
+func (fs *FURGFileSystem) Sync(hostDir, imageDir string, opts SyncOptions, report func(symbol, rel string)) (SyncResult, error) {
+	var result SyncResult
+	hostDir, err := filepath.Abs(hostDir)
+	if err != nil {
+		return result, err
+	}
+	imageDir = fs.resolvePath(imageDir)
+	if imageDir == systemDir || strings.HasPrefix(imageDir, systemDir+"/") {
+		return result, classErrorf(ErrUsage, "erro: o diretório de sistema %s não pode ser sincronizado", systemDir)
+	}
+
+	// A origem precisa existir; o destino é criado se preciso
+	hostInfo, hostErr := os.Stat(hostDir)
+	if hostErr == nil && !hostInfo.IsDir() {
+		return result, classErrorf(ErrUsage, "erro: '%s' não é um diretório", hostDir)
+	}
+	imageExists := imageDir == "/" || fs.CheckDirectoryExists(imageDir) != -1
+	switch {
+	case !opts.ToHost && hostErr != nil:
+		return result, classErrorf(ErrNotFound, "erro: o diretório '%s' não existe no sistema real", hostDir)
+	case opts.ToHost && !imageExists:
+		return result, classErrorf(ErrNotFound, "erro: O diretório '%s' não existe", imageDir)
+	case opts.DryRun:
+	case !opts.ToHost && !imageExists:
+		dir, name := splitPath(imageDir)
+		if err := fs.CreateDirectory(name, dir, true); err != nil {
+			return result, err
+		}
+	case opts.ToHost && hostErr != nil:
+		if err := os.MkdirAll(hostDir, 0755); err != nil {
+			return result, fmt.Errorf("erro ao criar o diretório no sistema real: %v", err)
+		}
+	}
+
+	host := make(map[string]syncEntry)
+	if hostErr == nil {
+		if host, err = hostSyncTree(hostDir, opts.Filter); err != nil {
+			return result, err
+		}
+	}
+	image := make(map[string]syncEntry)
+	if imageExists {
+		image = fs.imageSyncTree(imageDir, opts.Filter)
+	}
+	pairs, err := fs.syncPairs()
+	if err != nil {
+		return result, err
+	}
+	pairIndex := -1
+	for i, pair := range pairs {
+		if pair.Host == hostDir && pair.Image == imageDir {
+			pairIndex = i
+		}
+	}
+	if pairIndex == -1 {
+		pairs = append(pairs, syncPair{Host: hostDir, Image: imageDir})
+		pairIndex = len(pairs) - 1
+	}
+	previous := pairs[pairIndex].Files
+	files := make(map[string]syncFile)
+
+	source, dest := host, image
+	if opts.ToHost {
+		source, dest = image, host
+	}
+	hostPath := func(rel string) string { return filepath.Join(hostDir, filepath.FromSlash(rel)) }
+	imagePath := func(rel string) string { return joinPath(imageDir, rel) }
+	// remove apaga rel do destino, com todo o conteúdo
+	remove := func(rel string) error {
+		if opts.DryRun {
+			return nil
+		}
+		if opts.ToHost {
+			if err := os.RemoveAll(hostPath(rel)); err != nil {
+				return fmt.Errorf("erro ao remover '%s': %v", hostPath(rel), err)
+			}
+			return nil
+		}
+		return fs.removeSyncEntry(imagePath(rel))
+	}
+
+	rels := make([]string, 0, len(source))
+	for rel := range source {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		s := source[rel]
+		d, exists := dest[rel]
+		if exists && d.dir != s.dir {
+			// Um arquivo no lugar de um diretório, ou o contrário: a entrada do destino é substituída
+			if err := remove(rel); errors.Is(err, ErrProtected) {
+				result.Protected++
+				report("!", rel)
+				continue
+			} else if err != nil {
+				return result, err
+			}
+			result.Removed++
+			report("-", rel)
+			exists = false
+		}
+
+		if s.dir {
+			files[rel] = syncFile{Directory: true}
+			if exists {
+				continue
+			}
+			report("+", rel+"/")
+			if opts.DryRun {
+				continue
+			}
+			if opts.ToHost {
+				err = os.Mkdir(hostPath(rel), 0755)
+			} else {
+				dir, name := splitPath(imagePath(rel))
+				err = fs.CreateDirectory(name, dir, false)
+			}
+			if err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		h, i := host[rel], image[rel]
+		if exists && h.size == i.size {
+			record, ok := previous[rel]
+			unchanged := !opts.Checksum && ok && record.Size == h.size && record.ModTime == h.modTime
+			var imageHash string
+			if unchanged && opts.ToHost {
+				// A imagem não tem datas: na cópia para o sistema real, o conteúdo da origem é sempre conferido
+				if imageHash, err = fs.imageFileHash(imagePath(rel)); err != nil {
+					return result, err
+				}
+				unchanged = imageHash == record.SHA256
+			}
+			if !unchanged {
+				hostHash, err := hostFileHash(hostPath(rel))
+				if err != nil {
+					return result, err
+				}
+				if imageHash == "" {
+					if imageHash, err = fs.imageFileHash(imagePath(rel)); err != nil {
+						return result, err
+					}
+				}
+				unchanged = hostHash == imageHash
+				record = syncFile{Size: h.size, ModTime: h.modTime, SHA256: hostHash}
+			}
+			record.Block = i.block
+			if unchanged {
+				result.Unchanged++
+				files[rel] = record
+				continue
+			}
+		}
+
+		symbol := "+"
+		if exists {
+			symbol = "~"
+		}
+		if opts.DryRun {
+			result.Copied++
+			report(symbol, rel)
+			continue
+		}
+		record, err := fs.syncCopy(hostPath(rel), imagePath(rel), opts.ToHost)
+		if errors.Is(err, ErrProtected) {
+			result.Protected++
+			report("!", rel)
+			continue
+		} else if err != nil {
+			return result, err
+		}
+		result.Copied++
+		report(symbol, rel)
+		files[rel] = record
+	}
+
+	if opts.Delete {
+		var extra []string
+		for rel := range dest {
+			if _, ok := source[rel]; !ok {
+				extra = append(extra, rel)
+			}
+		}
+		// Em ordem decrescente, o conteúdo de um diretório é apagado antes dele
+		sort.Sort(sort.Reverse(sort.StringSlice(extra)))
+		for _, rel := range extra {
+			switch {
+			case opts.DryRun:
+			case opts.ToHost:
+				err = os.Remove(hostPath(rel))
+				if errors.Is(err, errNotEmpty) || errors.Is(err, os.ErrNotExist) {
+					// Ficou no diretório uma entrada ignorada pelos filtros
+					continue
+				} else if err != nil {
+					return result, fmt.Errorf("erro ao remover '%s': %v", hostPath(rel), err)
+				}
+			default:
+				dir, name := splitPath(imagePath(rel))
+				index := fs.lookupEntry(name, dir)
+				switch {
+				case index == -1:
+					continue
+				case fs.RootDir[index].Protected:
+					result.Protected++
+					report("!", rel)
+					continue
+				case fs.RootDir[index].IsDirectory && len(fs.entriesInDirectory(imagePath(rel), false)) > 0:
+					// Ficou no diretório uma entrada protegida ou ignorada pelos filtros
+					continue
+				}
+				fs.discardEntries([]int{index})
+			}
+			result.Removed++
+			report("-", rel)
+		}
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+	pairs[pairIndex].Files = files
+	old, _ := json.Marshal(previous)
+	current, _ := json.Marshal(files)
+	if bytes.Equal(old, current) {
+		return result, nil
+	}
+	return result, fs.writeSyncPairs(pairs)
+}
+
+// syncCopy copia um arquivo entre hostPath e imagePath, na direção indicada por toHost, e retorna o seu
+// novo estado.
+func (fs *FURGFileSystem) syncCopy(hostPath, imagePath string, toHost bool) (syncFile, error) {
+	var record syncFile
+	h := sha256.New()
+	if toHost {
+		dir, name := splitPath(imagePath)
+		index := fs.lookupEntry(name, dir)
+		if index == -1 {
+			return record, classErrorf(ErrNotFound, "erro: '%s' não foi encontrado no sistema de arquivos", imagePath)
+		}
+		r, err := fs.newFileReader(&fs.RootDir[index])
+		if err != nil {
+			return record, err
+		}
+		if err := writeHostFile(hostPath, io.TeeReader(r, h)); err != nil {
+			return record, err
+		}
+	} else {
+		f, err := os.Open(hostPath)
+		if err != nil {
+			return record, fmt.Errorf("erro ao abrir o arquivo: %v", err)
+		}
+		defer f.Close()
+		if err := fs.storeSyncFile(imagePath, io.TeeReader(f, h)); err != nil {
+			return record, err
+		}
+	}
+	// O estado usa os dados do arquivo real e da entrada da imagem depois da cópia
+	info, err := os.Stat(hostPath)
+	if err != nil {
+		return record, fmt.Errorf("erro ao acessar '%s': %v", hostPath, err)
+	}
+	dir, name := splitPath(imagePath)
+	record = syncFile{Size: info.Size(), ModTime: info.ModTime().UnixNano(), SHA256: hex.EncodeToString(h.Sum(nil))}
+	if index := fs.lookupEntry(name, dir); index != -1 {
+		record.Block = fs.RootDir[index].FirstBlockID
+	}
+	return record, nil
+}
+
+// cliSync implementa "sync [opções] <diretório-local> <caminho-interno>".
+func cliSync(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	var opts SyncOptions
+	flags.BoolVar(&opts.ToHost, "to-host", false, "copia da imagem para o diretório local")
+	flags.BoolVar(&opts.Delete, "delete", false, "apaga no destino as entradas que não existem na origem")
+	flags.BoolVar(&opts.Checksum, "checksum", false, "compara o SHA-256 de todos os arquivos com o mesmo tamanho")
+	flags.BoolVar(&opts.DryRun, "n", false, "mostra o que seria feito, sem alterar nada")
+	filter := &transferFilter{}
+	minSize, maxSize := filter.addFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if err := filter.setSizes(*minSize, *maxSize); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return classErrorf(ErrUsage, "uso: furgfs sync [--to-host] [--delete] [--checksum] [-n] [filtros] <diretório-local> <caminho-interno>")
+	}
+	if filter.active() {
+		opts.Filter = filter
+	}
+
+	result, err := fs.Sync(flags.Arg(0), flags.Arg(1), opts, func(symbol, rel string) {
+		fmt.Printf("%s %s\n", symbol, rel)
+	})
+	if !opts.DryRun {
+		err = saveImages([]*FURGFileSystem{fs}, err)
+	}
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		fmt.Printf(tr("Simulação: %d arquivo(s) a copiar, %d entrada(s) a remover, %d arquivo(s) sem alteração.\n"), result.Copied, result.Removed, result.Unchanged)
+	} else {
+		fmt.Printf(tr("%d arquivo(s) copiado(s), %d entrada(s) removida(s), %d arquivo(s) sem alteração.\n"), result.Copied, result.Removed, result.Unchanged)
+	}
+	if result.Protected > 0 {
+		return classErrorf(ErrProtected, "erro: %d entrada(s) protegida(s) não foram alteradas", result.Protected)
+	}
+	return nil
+}
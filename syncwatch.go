@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// "sync-watch" mantém um diretório do sistema real e um diretório da imagem sincronizados nos dois sentidos.
+// Cada lado é comparado com o estado da última sincronização em /.furgfs/sync.json, o mesmo de "sync": o que
+// mudou só de um lado é levado para o outro, e o que mudou dos dois lados de formas diferentes é um conflito,
+// que fica como está até que um dos lados seja acertado. Alterar um arquivo vence apagá-lo.
+
+// syncSide é o lado de um par que mudou desde a última sincronização.
+type syncSide int
+
+const (
+	syncNone syncSide = iota
+	syncHost
+	syncImage
+	syncBoth
+)
+
+// syncTwoWay faz uma passagem da sincronização nos dois sentidos entre hostDir e imageDir e informa se a
+// imagem foi alterada. report recebe cada alteração, com o caminho completo no lado alterado, como em Sync;
+// conflicts guarda os conflitos já informados, para que cada um seja informado uma só vez.
+func (fs *FURGFileSystem) syncTwoWay(hostDir, imageDir string, conflicts map[string]string, report func(symbol, p string)) (bool, error) {
+	host, err := hostSyncTree(hostDir, nil)
+	if err != nil {
+		return false, err
+	}
+	image := fs.imageSyncTree(imageDir, nil)
+	pairs, err := fs.syncPairs()
+	if err != nil {
+		return false, err
+	}
+	pairIndex := -1
+	for i, pair := range pairs {
+		if pair.Host == hostDir && pair.Image == imageDir {
+			pairIndex = i
+		}
+	}
+	if pairIndex == -1 {
+		pairs = append(pairs, syncPair{Host: hostDir, Image: imageDir})
+		pairIndex = len(pairs) - 1
+	}
+	base := pairs[pairIndex].Files
+	files := make(map[string]syncFile)
+	changed := false
+
+	hostPath := func(rel string) string { return filepath.Join(hostDir, filepath.FromSlash(rel)) }
+	imagePath := func(rel string) string { return joinPath(imageDir, rel) }
+
+	// hostChanged e imageChanged comparam cada lado com o estado da última sincronização; o conteúdo só é
+	// lido quando o tamanho é o mesmo, mas a data (no sistema real) ou o primeiro bloco (na imagem) mudou
+	hostChanged := func(rel string, e syncEntry, ok bool, b syncFile, bok bool) (bool, error) {
+		switch {
+		case ok != bok:
+			return true, nil
+		case !ok || e.dir || b.Directory:
+			return e.dir != b.Directory, nil
+		case e.size != b.Size:
+			return true, nil
+		case e.modTime == b.ModTime:
+			return false, nil
+		}
+		sum, err := hostFileHash(hostPath(rel))
+		return sum != b.SHA256, err
+	}
+	imageChanged := func(rel string, e syncEntry, ok bool, b syncFile, bok bool) (bool, error) {
+		switch {
+		case ok != bok:
+			return true, nil
+		case !ok || e.dir || b.Directory:
+			return e.dir != b.Directory, nil
+		case e.size != b.Size:
+			return true, nil
+		case e.block == b.Block:
+			return false, nil
+		}
+		sum, err := fs.imageFileHash(imagePath(rel))
+		return sum != b.SHA256, err
+	}
+	// parent cria, no lado de destino, o diretório pai de rel, que pode ter sido apagado do outro lado
+	parent := func(rel string, toHost bool) error {
+		if toHost {
+			return os.MkdirAll(filepath.Dir(hostPath(rel)), 0755)
+		}
+		dir, _ := splitPath(imagePath(rel))
+		if dir == "/" || fs.CheckDirectoryExists(dir) != -1 {
+			return nil
+		}
+		changed = true
+		dir, name := splitPath(dir)
+		return fs.CreateDirectory(name, dir, true)
+	}
+	// conflict informa um conflito, se ele ainda não foi informado nesse estado
+	conflict := func(rel string, h, i syncEntry) {
+		state := fmt.Sprint(h, i)
+		if conflicts[rel] != state {
+			conflicts[rel] = state
+			report("!", rel)
+		}
+	}
+
+	all := make(map[string]bool)
+	for _, tree := range []map[string]syncEntry{host, image} {
+		for rel := range tree {
+			all[rel] = true
+		}
+	}
+	for rel := range base {
+		all[rel] = true
+	}
+	rels := make([]string, 0, len(all))
+	for rel := range all {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	// As criações e atualizações seguem a ordem crescente, com os diretórios antes do conteúdo; as remoções
+	// ficam para depois, em ordem decrescente
+	var removals []struct {
+		rel    string
+		inHost bool
+	}
+	for _, rel := range rels {
+		h, hok := host[rel]
+		i, iok := image[rel]
+		b, bok := base[rel]
+		hc, err := hostChanged(rel, h, hok, b, bok)
+		if err != nil {
+			return changed, err
+		}
+		ic, err := imageChanged(rel, i, iok, b, bok)
+		if err != nil {
+			return changed, err
+		}
+
+		side := syncNone
+		switch {
+		case hc && ic:
+			side = syncBoth
+		case hc:
+			side = syncHost
+		case ic:
+			side = syncImage
+		}
+		if side == syncBoth {
+			// Os dois lados mudaram: se chegaram ao mesmo estado, basta registrá-lo; alterar vence apagar
+			switch {
+			case !hok && !iok:
+				continue
+			case hok && iok && h.dir && i.dir:
+				files[rel] = syncFile{Directory: true}
+				continue
+			case hok && iok && !h.dir && !i.dir && h.size == i.size:
+				hostHash, err := hostFileHash(hostPath(rel))
+				if err != nil {
+					return changed, err
+				}
+				imageHash, err := fs.imageFileHash(imagePath(rel))
+				if err != nil {
+					return changed, err
+				}
+				if hostHash == imageHash {
+					files[rel] = syncFile{Size: h.size, ModTime: h.modTime, Block: i.block, SHA256: hostHash}
+					delete(conflicts, rel)
+					continue
+				}
+			case !hok:
+				side = syncImage
+			case !iok:
+				side = syncHost
+			}
+		}
+
+		switch side {
+		case syncNone:
+			if bok && hok && !h.dir {
+				// A data e o bloco podem ter mudado sem que o conteúdo mudasse
+				b.ModTime, b.Block = h.modTime, i.block
+			}
+			if bok && hok {
+				files[rel] = b
+			}
+			continue
+		case syncBoth:
+			conflict(rel, h, i)
+			if bok {
+				files[rel] = b
+			}
+			continue
+		}
+
+		fromHost := side == syncHost
+		source, sok, dest, dok := i, iok, h, hok
+		if fromHost {
+			source, sok, dest, dok = h, hok, i, iok
+		}
+		if !sok {
+			removals = append(removals, struct {
+				rel    string
+				inHost bool
+			}{rel, !fromHost})
+			if bok {
+				// Fica no estado até ser apagado, para que uma falha seja tentada de novo
+				files[rel] = b
+			}
+			continue
+		}
+		if dok && dest.dir != source.dir {
+			// Um arquivo no lugar de um diretório, ou o contrário
+			removed := hostPath(rel)
+			if fromHost {
+				removed = imagePath(rel)
+				err = fs.removeSyncEntry(removed)
+				changed = true
+			} else {
+				err = os.RemoveAll(removed)
+			}
+			if errors.Is(err, ErrProtected) {
+				conflict(rel, h, i)
+				if bok {
+					files[rel] = b
+				}
+				continue
+			} else if err != nil {
+				return changed, err
+			}
+			report("-", removed)
+			dok = false
+		}
+
+		if err := parent(rel, !fromHost); err != nil {
+			return changed, err
+		}
+		if source.dir {
+			files[rel] = syncFile{Directory: true}
+			if dok {
+				continue
+			}
+			if fromHost {
+				dir, name := splitPath(imagePath(rel))
+				err = fs.CreateDirectory(name, dir, false)
+				changed = true
+				report("+", imagePath(rel)+"/")
+			} else {
+				err = os.Mkdir(hostPath(rel), 0755)
+				report("+", hostPath(rel)+"/")
+			}
+			if err != nil {
+				return changed, err
+			}
+			continue
+		}
+
+		record, err := fs.syncCopy(hostPath(rel), imagePath(rel), !fromHost)
+		if errors.Is(err, ErrProtected) {
+			conflict(rel, h, i)
+			if bok {
+				files[rel] = b
+			}
+			continue
+		} else if err != nil {
+			return changed, err
+		}
+		symbol := "+"
+		if dok {
+			symbol = "~"
+		}
+		if fromHost {
+			changed = true
+			report(symbol, imagePath(rel))
+		} else {
+			report(symbol, hostPath(rel))
+		}
+		files[rel] = record
+		delete(conflicts, rel)
+	}
+
+	for n := len(removals) - 1; n >= 0; n-- {
+		rel := removals[n].rel
+		if removals[n].inHost {
+			err := os.Remove(hostPath(rel))
+			if errors.Is(err, errNotEmpty) {
+				// O diretório recebeu entradas novas, que serão levadas para a imagem
+				continue
+			} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return changed, fmt.Errorf("erro ao remover '%s': %v", hostPath(rel), err)
+			}
+			report("-", hostPath(rel))
+		} else {
+			dir, name := splitPath(imagePath(rel))
+			index := fs.lookupEntry(name, dir)
+			if index != -1 {
+				entry := &fs.RootDir[index]
+				if entry.Protected {
+					conflict(rel, host[rel], image[rel])
+					continue
+				}
+				if entry.IsDirectory && len(fs.entriesInDirectory(imagePath(rel), false)) > 0 {
+					continue
+				}
+				fs.discardEntries([]int{index})
+				changed = true
+				report("-", imagePath(rel))
+			}
+		}
+		delete(files, rel)
+	}
+
+	old, _ := json.Marshal(base)
+	current, _ := json.Marshal(files)
+	if bytes.Equal(old, current) {
+		return changed, nil
+	}
+	pairs[pairIndex].Files = files
+	return true, fs.writeSyncPairs(pairs)
+}
+
+// watchTree acrescenta ao watcher o diretório root e todos os seus subdiretórios; acrescentar um diretório
+// já observado não tem efeito.
+func watchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(p string, d iofs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		return watcher.Add(p)
+	})
+}
+
+// cliSyncWatch implementa "sync-watch [--delay <duração>] <diretório-local> <caminho-interno> [imagem]".
+// A imagem é lida de novo a cada passagem, para que as alterações feitas por outros processos, como um
+// "serve", sejam vistas.
+func cliSyncWatch(imageName string, args []string) error {
+	flags := flag.NewFlagSet("sync-watch", flag.ContinueOnError)
+	delay := flags.Duration("delay", 500*time.Millisecond, "espera sem alterações antes de sincronizar")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() != 2 && flags.NArg() != 3 {
+		return classErrorf(ErrUsage, "uso: furgfs sync-watch [--delay <duração>] <diretório-local> <caminho-interno> [imagem]")
+	}
+	if flags.NArg() == 3 {
+		var err error
+		if imageName, err = resolveImage(flags.Arg(2)); err != nil {
+			return err
+		}
+	}
+	hostDir, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(hostDir); err != nil || !info.IsDir() {
+		return classErrorf(ErrNotFound, "erro: o diretório '%s' não existe no sistema real", hostDir)
+	}
+	imageDir := normalizePath(flags.Arg(1))
+	if imageDir == systemDir || strings.HasPrefix(imageDir, systemDir+"/") {
+		return classErrorf(ErrUsage, "erro: o diretório de sistema %s não pode ser sincronizado", systemDir)
+	}
+
+	conflicts := make(map[string]string)
+	report := func(symbol, p string) {
+		fmt.Printf("%s %s %s\n", time.Now().Format(time.TimeOnly), symbol, p)
+	}
+	pass := func() error {
+		fs, err := loadFileSystem(imageName)
+		if err != nil {
+			return err
+		}
+		defer fs.closeImage()
+		created := false
+		if imageDir != "/" && fs.CheckDirectoryExists(imageDir) == -1 {
+			dir, name := splitPath(imageDir)
+			if err := fs.CreateDirectory(name, dir, true); err != nil {
+				return err
+			}
+			created = true
+		}
+		changed, err := fs.syncTwoWay(hostDir, imageDir, conflicts, report)
+		if changed || created {
+			err = saveImages([]*FURGFileSystem{fs}, err)
+		}
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(imageName); err != nil {
+		return fmt.Errorf("erro ao observar '%s': %v", imageName, err)
+	}
+	if err := watchTree(watcher, hostDir); err != nil {
+		return fmt.Errorf("erro ao observar '%s': %v", hostDir, err)
+	}
+	if err := pass(); err != nil {
+		return err
+	}
+	fmt.Printf(tr("Sincronizando '%s' com '%s' em '%s'; Ctrl+C para encerrar.\n"), hostDir, imageDir, imageName)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	timer := time.NewTimer(0)
+	<-timer.C
+	for {
+		select {
+		case <-signals:
+			fmt.Println(tr("Sincronização encerrada."))
+			return nil
+		case event := <-watcher.Events:
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					watchTree(watcher, event.Name)
+				}
+			}
+			timer.Reset(*delay)
+		case err := <-watcher.Errors:
+			logger.Warn("erro ao observar as alterações", "error", err)
+			timer.Reset(*delay)
+		case <-timer.C:
+			// Uma falha, como uma imagem lida no meio de uma gravação, é tentada de novo na próxima alteração
+			if err := pass(); err != nil {
+				logger.Error("erro na sincronização", "error", err)
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// enableRawMode desativa o modo canônico e o eco do terminal fd, para que o editor de linha receba cada tecla
+// assim que for digitada. Os sinais (Ctrl+C) continuam ativos. A função retornada restaura o modo anterior.
+// Retorna erro se fd não for um terminal.
+func enableRawMode(fd int) (func(), error) {
+	var original syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(&original)))
+	if errno != 0 {
+		return nil, errno
+	}
+
+	raw := original
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(&raw)))
+	if errno != 0 {
+		return nil, errno
+	}
+
+	return func() {
+		syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(&original)))
+	}, nil
+}
+
+// terminalSize retorna o número de colunas e linhas do terminal fd.
+func terminalSize(fd int) (int, int, error) {
+	var ws struct{ Row, Col, Xpixel, Ypixel uint16 }
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return 0, 0, errno
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// enableRawMode não é suportado fora do Linux; o editor de linha usa a leitura comum, linha a linha.
+func enableRawMode(fd int) (func(), error) {
+	return nil, errors.New("modo raw do terminal não suportado nesta plataforma")
+}
+
+// terminalSize não é suportado fora do Linux.
+func terminalSize(fd int) (int, int, error) {
+	return 0, 0, errors.New("tamanho do terminal não disponível nesta plataforma")
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer gera os spans das operações do sistema de arquivos: comandos, carga e gravação do estado,
+// alocação, leitura e escrita de blocos e busca no diretório. Eles vão para o TracerProvider global do
+// OpenTelemetry, que descarta tudo até ser configurado por setupTracing ou por quem incorpora o FURGfs2.
+var tracer = otel.Tracer("FURGFS2")
+
+// traceContext é o contexto do comando em execução, pai dos spans abertos fora de outro span.
+var traceContext = context.Background()
+
+// startCommandSpan abre o span de um comando, pai dos spans das operações feitas por ele, e retorna a
+// função que o encerra.
+func startCommandSpan(command string) func(err error) {
+	ctx, span := tracer.Start(context.Background(), "furgfs "+command, trace.WithAttributes(attribute.String("furgfs.command", command)))
+	traceContext = ctx
+	return func(err error) {
+		endSpan(span, err)
+		traceContext = context.Background()
+	}
+}
+
+// startSpan abre um span filho do span atual de fs, que passa a ser o atual até que a função retornada
+// o encerre com o erro da operação.
+func (fs *FURGFileSystem) startSpan(name string, attrs ...attribute.KeyValue) func(err error) {
+	saved := fs.spanContext
+	parent := saved
+	if parent == nil {
+		parent = traceContext
+	}
+	ctx, span := tracer.Start(parent, name, trace.WithAttributes(attrs...))
+	fs.spanContext = ctx
+	return func(err error) {
+		endSpan(span, err)
+		fs.spanContext = saved
+	}
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// setupTracing configura a exportação dos spans pelas variáveis padrão do OpenTelemetry:
+// OTEL_TRACES_EXPORTER=otlp, ou apenas um OTEL_EXPORTER_OTLP_ENDPOINT, envia por OTLP/HTTP, e console
+// escreve os spans em JSON na saída de erro. Retorna a função que envia os spans pendentes no fim.
+func setupTracing() (func(), error) {
+	name := os.Getenv("OTEL_TRACES_EXPORTER")
+	if name == "" && (os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "") {
+		name = "otlp"
+	}
+	ctx := context.Background()
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch name {
+	case "", "none":
+		return func() {}, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "console":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+	default:
+		return nil, classErrorf(ErrUsage, "erro: exportador de traces '%s' não suportado (use otlp, console ou none)", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// OTEL_SERVICE_NAME e OTEL_RESOURCE_ATTRIBUTES têm precedência sobre o nome padrão
+	res, err := resource.New(ctx, resource.WithAttributes(attribute.String("service.name", "furgfs")),
+		resource.WithFromEnv(), resource.WithTelemetrySDK())
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return func() {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			logger.Warn("erro ao enviar os spans", "error", err)
+		}
+	}, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// patternList é uma opção de linha de comando que pode ser repetida, acumulando padrões glob.
+type patternList []string
+
+func (p *patternList) String() string { return strings.Join(*p, ",") }
+
+func (p *patternList) Set(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("padrão '%s' inválido: %v", pattern, err)
+	}
+	*p = append(*p, pattern)
+	return nil
+}
+
+// transferFilter seleciona as entradas de uma cópia recursiva entre o sistema real e o FURGfs2. Padrões com
+// '/' são comparados com o caminho relativo à raiz da cópia; os demais, apenas com o nome. Um diretório
+// excluído não é percorrido. Um filtro nil aceita tudo.
+type transferFilter struct {
+	include, exclude patternList
+	minSize, maxSize int64 // 0 em maxSize indica sem limite
+
+	copied, skipped int
+}
+
+// addFlags registra as opções do filtro em flags.
+func (f *transferFilter) addFlags(flags *flag.FlagSet) (minSize, maxSize *string) {
+	flags.Var(&f.include, "include", "copia apenas os arquivos que casam com o padrão (pode ser repetida)")
+	flags.Var(&f.exclude, "exclude", "ignora as entradas que casam com o padrão (pode ser repetida)")
+	return flags.String("min-size", "", "ignora arquivos menores que o tamanho"), flags.String("max-size", "", "ignora arquivos maiores que o tamanho")
+}
+
+// setSizes converte os limites de tamanho das opções --min-size e --max-size.
+func (f *transferFilter) setSizes(minSize, maxSize string) error {
+	for _, limit := range []struct {
+		value  string
+		target *int64
+	}{{minSize, &f.minSize}, {maxSize, &f.maxSize}} {
+		if limit.value == "" {
+			continue
+		}
+		size, err := parseSize(limit.value)
+		if err != nil {
+			return err
+		}
+		*limit.target = int64(size)
+	}
+	return nil
+}
+
+// active informa se alguma opção de filtro foi usada.
+func (f *transferFilter) active() bool {
+	return len(f.include) > 0 || len(f.exclude) > 0 || f.minSize > 0 || f.maxSize > 0
+}
+
+// allows informa se a entrada de caminho relativo rel deve ser copiada, e conta as ignoradas.
+func (f *transferFilter) allows(rel string, isDir bool, size int64) bool {
+	if f == nil {
+		return true
+	}
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			target := path.Base(rel)
+			if strings.Contains(pattern, "/") {
+				target = rel
+			}
+			if ok, _ := path.Match(pattern, target); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	ok := !matches(f.exclude)
+	if ok && !isDir {
+		ok = (len(f.include) == 0 || matches(f.include)) && size >= f.minSize && (f.maxSize == 0 || size <= f.maxSize)
+	}
+	if !ok {
+		f.skipped++
+	}
+	return ok
+}
+
+// copiedFile conta um arquivo copiado.
+func (f *transferFilter) copiedFile() {
+	if f != nil {
+		f.copied++
+	}
+}
+
+// relOrName retorna o caminho relativo da entrada ou, para a raiz da cópia, o nome.
+func relOrName(rel, name string) string {
+	if rel == "" {
+		return name
+	}
+	return rel
+}
+
+// exportTree copia o arquivo ou diretório name, em dir no FURGfs2, para hostPath no sistema real,
+// apenas com as entradas aceitas por filter.
+func (fs *FURGFileSystem) exportTree(dir, name, hostPath string, filter *transferFilter) error {
+	// rel é o caminho da entrada relativo à raiz da cópia, vazio para a própria raiz
+	var walk func(dir, name, hostPath, rel string) error
+	walk = func(dir, name, hostPath, rel string) error {
+		index := fs.lookupEntry(name, dir)
+		if index == -1 {
+			return classErrorf(ErrNotFound, "erro: '%s' não foi encontrado no sistema de arquivos", joinPath(dir, name))
+		}
+		entry := &fs.RootDir[index]
+		if (rel != "" || !entry.IsDirectory) && !filter.allows(relOrName(rel, name), entry.IsDirectory, int64(entry.Size)) {
+			return nil
+		}
+
+		if !entry.IsDirectory {
+			f, err := os.Create(hostPath)
+			if err != nil {
+				return fmt.Errorf("erro ao criar o arquivo no sistema real: %v", err)
+			}
+			defer f.Close()
+			filter.copiedFile()
+			return fs.exportFile(entry, f)
+		}
+
+		err := os.MkdirAll(hostPath, 0755)
+		if err != nil {
+			return fmt.Errorf("erro ao criar o diretório no sistema real: %v", err)
+		}
+		full := joinPath(dir, name)
+		for _, i := range fs.entriesInDirectory(full, false) {
+			child := fs.RootDir[i].NameString()
+			err = walk(full, child, filepath.Join(hostPath, child), path.Join(rel, child))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(dir, name, hostPath, "")
+}
+
+// importTree copia o arquivo ou diretório hostPath do sistema real para dir/name no FURGfs2,
+// apenas com as entradas aceitas por filter.
+func (fs *FURGFileSystem) importTree(hostPath, dir, name string, filter *transferFilter) error {
+	// rel é o caminho da entrada relativo à raiz da cópia, vazio para a própria raiz
+	var walk func(hostPath, dir, name, rel string) error
+	walk = func(hostPath, dir, name, rel string) error {
+		info, err := os.Stat(hostPath)
+		if err != nil {
+			return fmt.Errorf("erro ao acessar '%s': %v", hostPath, err)
+		}
+		if (rel != "" || !info.IsDir()) && !filter.allows(relOrName(rel, name), info.IsDir(), info.Size()) {
+			return nil
+		}
+
+		if !info.IsDir() {
+			f, err := os.Open(hostPath)
+			if err != nil {
+				return fmt.Errorf("erro ao abrir o arquivo: %v", err)
+			}
+			defer f.Close()
+			filter.copiedFile()
+			_, err = fs.createFile(dir, name, f, false)
+			return err
+		}
+
+		err = fs.CreateDirectory(name, dir, false)
+		if err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(hostPath)
+		if err != nil {
+			return fmt.Errorf("erro ao ler '%s': %v", hostPath, err)
+		}
+		for _, e := range entries {
+			err = walk(filepath.Join(hostPath, e.Name()), joinPath(dir, name), e.Name(), path.Join(rel, e.Name()))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(hostPath, dir, name, "")
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tuiItem é uma linha de um painel do gerenciador de arquivos.
+type tuiItem struct {
+	name  string
+	isDir bool
+	size  int64
+}
+
+// tuiPane é um painel do gerenciador: o FURGfs2 (internal) ou o sistema de arquivos real.
+type tuiPane struct {
+	internal bool
+	dir      string
+	items    []tuiItem
+	cursor   int
+	offset   int
+}
+
+// fileManager é o gerenciador de arquivos em tela cheia, com um painel para o FURGfs2 e outro para o
+// sistema real. As operações usam as mesmas funções do menu interativo.
+type fileManager struct {
+	fs     *FURGFileSystem
+	panes  [2]*tuiPane
+	active int
+	status string
+	out    io.Writer
+	width  int
+	height int
+}
+
+const fileManagerHelp = "Tab painel  Enter abrir  Backspace voltar  c/F5 copiar  m/F6 mover  d/F8 remover  r recarregar  q sair"
+
+// FileManager abre o gerenciador de arquivos em tela cheia. Requer que a entrada padrão seja um terminal.
+func (fs *FURGFileSystem) FileManager() error {
+	restore, err := enableRawMode(console.fd)
+	if err != nil {
+		return fmt.Errorf("erro: o gerenciador de arquivos precisa de um terminal")
+	}
+	defer restore()
+
+	hostDir, err := os.Getwd()
+	if err != nil {
+		hostDir = "/"
+	}
+	fm := &fileManager{
+		fs:  fs,
+		out: os.Stdout,
+		panes: [2]*tuiPane{
+			{internal: true, dir: fs.WorkingDir},
+			{dir: hostDir},
+		},
+	}
+	fm.reload()
+
+	// As remoções já são confirmadas na própria tela; arquivos protegidos continuam protegidos
+	defer func(policy ConfirmPolicy) { fs.Policy = policy }(fs.Policy)
+	fs.Policy = nil
+
+	io.WriteString(fm.out, "\x1b[?1049h\x1b[?25l")
+	defer io.WriteString(fm.out, "\x1b[?25h\x1b[?1049l")
+
+	for {
+		fm.draw()
+		key, err := fm.readKey()
+		if err != nil {
+			return nil
+		}
+		fm.status = ""
+		pane := fm.panes[fm.active]
+
+		switch key {
+		case "q", "ctrl+d":
+			return nil
+		case "tab":
+			fm.active = 1 - fm.active
+		case "up", "k":
+			pane.cursor = max(0, pane.cursor-1)
+		case "down", "j":
+			pane.cursor = min(len(pane.items)-1, pane.cursor+1)
+		case "home":
+			pane.cursor = 0
+		case "end":
+			pane.cursor = len(pane.items) - 1
+		case "enter", "right":
+			fm.open(pane)
+		case "backspace", "left":
+			fm.enter(pane, "..")
+		case "r":
+			fm.reload()
+		case "c", "f5":
+			fm.transfer(false)
+		case "m", "f6":
+			fm.transfer(true)
+		case "d", "f8":
+			fm.delete()
+		}
+	}
+}
+
+// reload relê o conteúdo dos dois painéis.
+func (fm *fileManager) reload() {
+	for _, pane := range fm.panes {
+		var err error
+		if pane.internal {
+			pane.items = fm.internalItems(pane.dir)
+		} else {
+			pane.items, err = hostItems(pane.dir)
+			if err != nil {
+				fm.status = err.Error()
+			}
+		}
+		if pane.dir != "/" {
+			pane.items = append([]tuiItem{{name: "..", isDir: true}}, pane.items...)
+		}
+		pane.cursor = max(0, min(pane.cursor, len(pane.items)-1))
+	}
+}
+
+func (fm *fileManager) internalItems(dir string) []tuiItem {
+	var items []tuiItem
+	for _, i := range fm.fs.entriesInDirectory(dir, false) {
+		entry := &fm.fs.RootDir[i]
+		items = append(items, tuiItem{name: entry.NameString(), isDir: entry.IsDirectory, size: int64(entry.Size)})
+	}
+	sortItems(items)
+	return items
+}
+
+func hostItems(dir string) ([]tuiItem, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler '%s': %v", dir, err)
+	}
+	var items []tuiItem
+	for _, e := range entries {
+		item := tuiItem{name: e.Name(), isDir: e.IsDir()}
+		if info, err := e.Info(); err == nil && !e.IsDir() {
+			item.size = info.Size()
+		}
+		items = append(items, item)
+	}
+	sortItems(items)
+	return items, nil
+}
+
+// sortItems ordena os itens com os diretórios primeiro e, depois, por nome.
+func sortItems(items []tuiItem) {
+	sort.Slice(items, func(a, b int) bool {
+		if items[a].isDir != items[b].isDir {
+			return items[a].isDir
+		}
+		return items[a].name < items[b].name
+	})
+}
+
+func (pane *tuiPane) selected() (tuiItem, bool) {
+	if pane.cursor < 0 || pane.cursor >= len(pane.items) {
+		return tuiItem{}, false
+	}
+	return pane.items[pane.cursor], true
+}
+
+func (pane *tuiPane) join(name string) string {
+	if pane.internal {
+		return joinPath(pane.dir, name)
+	}
+	return filepath.Join(pane.dir, name)
+}
+
+func (fm *fileManager) open(pane *tuiPane) {
+	item, ok := pane.selected()
+	if !ok || !item.isDir {
+		return
+	}
+	fm.enter(pane, item.name)
+}
+
+// enter muda o diretório do painel para name ("..", para subir) e posiciona o cursor no diretório de origem ao subir.
+func (fm *fileManager) enter(pane *tuiPane, name string) {
+	if pane.dir == "/" && name == ".." {
+		return
+	}
+	previous := pane.dir
+	if pane.internal {
+		pane.dir = normalizePath(joinPath(pane.dir, name))
+	} else {
+		pane.dir = filepath.Clean(filepath.Join(pane.dir, name))
+	}
+	pane.cursor, pane.offset = 0, 0
+	fm.reload()
+
+	if name == ".." {
+		for i, item := range pane.items {
+			if item.name == filepath.Base(previous) {
+				pane.cursor = i
+			}
+		}
+	}
+}
+
+// transfer copia (ou move, se move for verdadeiro) o item selecionado para o diretório do outro painel.
+func (fm *fileManager) transfer(move bool) {
+	src, dst := fm.panes[fm.active], fm.panes[1-fm.active]
+	item, ok := src.selected()
+	if !ok || item.name == ".." {
+		return
+	}
+	if src.internal == dst.internal && src.dir == dst.dir {
+		fm.status = "erro: origem e destino são o mesmo diretório"
+		return
+	}
+
+	var err error
+	switch {
+	case src.internal && !dst.internal:
+		err = fm.fs.exportTree(src.dir, item.name, dst.join(item.name), nil)
+	case !src.internal && dst.internal:
+		err = fm.fs.importTree(src.join(item.name), dst.dir, item.name, nil)
+	case src.internal:
+		err = fm.fs.copyTreeTo(fm.fs, src.dir, item.name, dst.dir, item.name)
+	default:
+		err = copyHostTree(src.join(item.name), dst.join(item.name))
+	}
+	if err == nil && move {
+		err = fm.remove(src, item)
+	}
+	if dst.internal || move && src.internal {
+		op := "copy"
+		if move {
+			op = "move"
+		}
+		fm.fs.recordAudit(auditRecord{User: localUser(), Source: "tui", Op: op, Path: src.join(item.name), Target: dst.join(item.name)}, err)
+	}
+
+	if err != nil {
+		fm.status = err.Error()
+	} else if move {
+		fm.status = fmt.Sprintf("'%s' movido para '%s'.", item.name, dst.dir)
+	} else {
+		fm.status = fmt.Sprintf("'%s' copiado para '%s'.", item.name, dst.dir)
+	}
+	fm.reload()
+}
+
+// delete remove o item selecionado, após confirmação.
+func (fm *fileManager) delete() {
+	pane := fm.panes[fm.active]
+	item, ok := pane.selected()
+	if !ok || item.name == ".." {
+		return
+	}
+
+	question := fmt.Sprintf("Remover '%s'", pane.join(item.name))
+	if item.isDir {
+		question += " e todo o seu conteúdo"
+	}
+	fm.status = question + "? (s/n)"
+	fm.draw()
+	key, err := fm.readKey()
+	if err != nil || (key != "s" && key != "S" && key != "y") {
+		fm.status = "Remoção cancelada."
+		return
+	}
+
+	err = fm.remove(pane, item)
+	if pane.internal {
+		fm.fs.recordAudit(auditRecord{User: localUser(), Source: "tui", Op: "remove", Path: pane.join(item.name)}, err)
+	}
+	if err != nil {
+		fm.status = err.Error()
+	} else {
+		fm.status = fmt.Sprintf("'%s' removido.", item.name)
+	}
+	fm.reload()
+}
+
+func (fm *fileManager) remove(pane *tuiPane, item tuiItem) error {
+	if pane.internal {
+		return fm.fs.removeInternalTree(pane.dir, item.name)
+	}
+	return os.RemoveAll(pane.join(item.name))
+}
+
+// readKey lê uma tecla e a traduz para um nome ("up", "enter", "f5", ou o próprio caractere).
+func (fm *fileManager) readKey() (string, error) {
+	r, _, err := console.in.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	switch r {
+	case '\r', '\n':
+		return "enter", nil
+	case '\t':
+		return "tab", nil
+	case 127, 8:
+		return "backspace", nil
+	case 4:
+		return "ctrl+d", nil
+	case 27:
+		switch console.readEscape() {
+		case "A":
+			return "up", nil
+		case "B":
+			return "down", nil
+		case "C":
+			return "right", nil
+		case "D":
+			return "left", nil
+		case "H", "1~", "7~":
+			return "home", nil
+		case "F", "4~", "8~":
+			return "end", nil
+		case "15~":
+			return "f5", nil
+		case "17~":
+			return "f6", nil
+		case "19~":
+			return "f8", nil
+		}
+		return "", nil
+	}
+	return string(r), nil
+}
+
+// draw redesenha a tela inteira: título, os dois painéis, a linha de status e a ajuda.
+func (fm *fileManager) draw() {
+	fm.width, fm.height = 80, 24
+	if w, h, err := terminalSize(console.fd); err == nil && w > 20 && h > 6 {
+		fm.width, fm.height = w, h
+	}
+	paneWidth := (fm.width - 1) / 2
+	rows := fm.height - 4
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	used := fm.fs.Header.TotalSize - fm.fs.Header.DataStart - fm.fs.Header.FreeSpace
+	b.WriteString(fitText(fmt.Sprintf(" FURGfs2 — %d de %d bytes em uso", used, fm.fs.Header.TotalSize-fm.fs.Header.DataStart), fm.width))
+	b.WriteString("\r\n")
+
+	titles := [2]string{" FURGfs2: " + fm.panes[0].dir, " Sistema real: " + fm.panes[1].dir}
+	for i := range fm.panes {
+		title := fitText(titles[i], paneWidth)
+		if i == fm.active {
+			title = "\x1b[1;4m" + title + "\x1b[0m"
+		}
+		b.WriteString(title)
+		if i == 0 {
+			b.WriteString("│")
+		}
+	}
+	b.WriteString("\r\n")
+
+	for _, pane := range fm.panes {
+		if pane.cursor < pane.offset {
+			pane.offset = pane.cursor
+		}
+		if pane.cursor >= pane.offset+rows {
+			pane.offset = pane.cursor - rows + 1
+		}
+	}
+	for row := 0; row < rows; row++ {
+		for i, pane := range fm.panes {
+			line := ""
+			index := pane.offset + row
+			if index < len(pane.items) {
+				line = formatItem(pane.items[index], paneWidth)
+			}
+			line = fitText(line, paneWidth)
+			if index == pane.cursor && index < len(pane.items) {
+				if i == fm.active {
+					line = "\x1b[7m" + line + "\x1b[0m"
+				} else {
+					line = "\x1b[4m" + line + "\x1b[0m"
+				}
+			}
+			b.WriteString(line)
+			if i == 0 {
+				b.WriteString("│")
+			}
+		}
+		b.WriteString("\r\n")
+	}
+
+	b.WriteString(fitText(" "+fm.status, fm.width))
+	b.WriteString("\r\n\x1b[7m")
+	b.WriteString(fitText(" "+fileManagerHelp, fm.width))
+	b.WriteString("\x1b[0m")
+	io.WriteString(fm.out, b.String())
+}
+
+func formatItem(item tuiItem, width int) string {
+	if item.isDir {
+		return " " + item.name + "/"
+	}
+	size := fmt.Sprintf("%d", item.size)
+	name := " " + item.name
+	if pad := width - len([]rune(name)) - len(size) - 1; pad > 0 {
+		return name + strings.Repeat(" ", pad) + size
+	}
+	return name
+}
+
+// fitText corta ou completa s com espaços para ocupar exatamente width colunas.
+func fitText(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return s + strings.Repeat(" ", width-len(runes))
+}
+
+// copyHostTree copia o arquivo ou diretório src para dst, ambos no sistema real.
+func copyHostTree(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if dst == src || strings.HasPrefix(dst, src+string(filepath.Separator)) {
+			return fmt.Errorf("erro: não é possível copiar '%s' para dentro de si mesmo", src)
+		}
+		err = os.MkdirAll(dst, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			err = copyHostTree(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name()))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// uuidPattern é o formato aceito por "tune --uuid".
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// TuneVolume altera a identificação do volume: o rótulo, se label não for nil, e o UUID, se uuid não for
+// vazio ("regenerate" gera um novo). Imagens antigas, sem metadados, passam a tê-los.
+func (fs *FURGFileSystem) TuneVolume(label *string, uuid string) (VolumeInfo, error) {
+	info, err := fs.VolumeInfo()
+	if err != nil {
+		return info, err
+	}
+	if label != nil {
+		if err := defaultValidationRules.ValidateName(*label); *label != "" && err != nil {
+			return info, classErrorf(ErrUsage, "erro: rótulo '%s' inválido: %v", *label, errors.Unwrap(err))
+		}
+		info.Label = *label
+	}
+	if uuid != "" {
+		if uuid == "regenerate" {
+			if uuid, err = newUUID(); err != nil {
+				return info, err
+			}
+		} else if uuid = strings.ToLower(uuid); !uuidPattern.MatchString(uuid) {
+			return info, classErrorf(ErrUsage, "erro: UUID '%s' inválido", uuid)
+		}
+		if info.UUID != "" && uuid != info.UUID {
+			err = fs.confirm("O UUID do volume será trocado de %s para %s; novos backups incrementais não poderão continuar as cadeias atuais.", info.UUID, uuid)
+			if err != nil {
+				return info, err
+			}
+		}
+		info.UUID = uuid
+	}
+	return info, fs.SetVolumeInfo(info)
+}
+
+// cliTune implementa "tune [--label <rótulo>] [--uuid regenerate|<uuid>]".
+func cliTune(fs *FURGFileSystem, args []string) error {
+	flags := flag.NewFlagSet("tune", flag.ContinueOnError)
+	label := flags.String("label", "", "novo rótulo do volume (vazio remove o rótulo)")
+	uuid := flags.String("uuid", "", "novo UUID do volume, ou regenerate para gerar um aleatório")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	labelSet := false
+	flags.Visit(func(f *flag.Flag) { labelSet = labelSet || f.Name == "label" })
+	if flags.NArg() != 0 || (!labelSet && *uuid == "") {
+		return classErrorf(ErrUsage, "uso: furgfs tune [--label <rótulo>] [--uuid regenerate|<uuid>]")
+	}
+
+	var newLabel *string
+	if labelSet {
+		newLabel = label
+	}
+	info, err := fs.TuneVolume(newLabel, *uuid)
+	if err != nil {
+		return err
+	}
+	if err = fs.saveFileSystemState(); err != nil {
+		return err
+	}
+	fmt.Printf(tr("Rótulo: '%s', UUID: %s\n"), info.Label, info.UUID)
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// deletedCandidate é uma cadeia de blocos livres que parece ter pertencido a um arquivo removido.
+type deletedCandidate struct {
+	Blocks      []uint32
+	Size        uint32
+	ContentType string
+}
+
+// isStaleBlock indica se o bloco livre ainda guarda os dados da FAT de quando estava em uso. Ao liberar um bloco,
+// apenas o campo Used é apagado, então BlockID continua igual ao índice; blocos nunca usados têm BlockID 0.
+func (fs *FURGFileSystem) isStaleBlock(blockID uint32) bool {
+	fatEntry := *fs.fatEntry(blockID)
+	return !fatEntry.Used && fatEntry.BlockID == blockID
+}
+
+// ScanDeletedFiles percorre a FAT em busca de cadeias de blocos livres cujos encadeamentos ainda estão intactos,
+// reconstruindo heuristicamente os arquivos removidos. O tamanho é estimado pelos blocos da cadeia, ignorando os
+// bytes nulos no fim do último bloco, e o tipo do conteúdo é deduzido pelos primeiros bytes. Cadeias cujo
+// primeiro bloco só contém zeros são descartadas. No armazenamento por conteúdo, as cadeias são manifestos, e a
+// busca é recusada.
+func (fs *FURGFileSystem) ScanDeletedFiles() ([]deletedCandidate, error) {
+	if err := fs.checkNotCAS("undelete"); err != nil {
+		return nil, err
+	}
+	pointed := make(map[uint32]bool)
+	for i := range fs.fatEntries() {
+		if fs.isStaleBlock(uint32(i)) {
+			if next := fs.FAT[i].NextBlockID; next != 0 {
+				pointed[next] = true
+			}
+		}
+	}
+
+	visited := make(map[uint32]bool)
+	buf := make([]byte, fs.Header.BlockSize)
+	var candidates []deletedCandidate
+	for i := range fs.fatEntries() {
+		head := uint32(i)
+		if !fs.isStaleBlock(head) || pointed[head] || visited[head] {
+			continue
+		}
+
+		chain := []uint32{head}
+		visited[head] = true
+		for next := fs.fatEntry(head).NextBlockID; next != 0; next = fs.fatEntry(next).NextBlockID {
+			// A cadeia termina onde o próximo bloco já foi reaproveitado ou visitado
+			if int(next) >= len(fs.FAT) || !fs.isStaleBlock(next) || visited[next] {
+				break
+			}
+			chain = append(chain, next)
+			visited[next] = true
+		}
+
+		n, err := fs.readBlock(chain[0], buf)
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimRight(buf[:n], "\x00")) == 0 {
+			continue
+		}
+		contentType := http.DetectContentType(buf[:n])
+
+		n, err = fs.readBlock(chain[len(chain)-1], buf)
+		if err != nil {
+			return nil, err
+		}
+		size := uint32(len(chain)-1)*fs.Header.BlockSize + uint32(len(bytes.TrimRight(buf[:n], "\x00")))
+
+		candidates = append(candidates, deletedCandidate{Blocks: chain, Size: size, ContentType: contentType})
+	}
+
+	sort.Slice(candidates, func(a, b int) bool {
+		return candidates[a].Size > candidates[b].Size
+	})
+	return candidates, nil
+}
+
+// ShowDeletedFiles exibe as cadeias encontradas por ScanDeletedFiles, numeradas a partir de 1.
+func (fs *FURGFileSystem) ShowDeletedFiles(candidates []deletedCandidate) {
+	if len(candidates) == 0 {
+		fmt.Println("Nenhum arquivo removido recuperável foi encontrado.")
+		return
+	}
+	for i, candidate := range candidates {
+		fmt.Printf("%d. bloco inicial %d, %d bloco(s), ~%d bytes, %s\n",
+			i+1, candidate.Blocks[0], len(candidate.Blocks), candidate.Size, candidate.ContentType)
+	}
+}
+
+// UndeleteFile marca novamente como usados os blocos da cadeia encontrada e cria para ela a entrada name em path.
+func (fs *FURGFileSystem) UndeleteFile(candidate deletedCandidate, name, path string) error {
+	path = fs.resolvePath(path)
+	for _, blockID := range candidate.Blocks {
+		if !fs.isStaleBlock(blockID) {
+			return fmt.Errorf("erro: o bloco %d já foi reaproveitado, o arquivo não pode mais ser recuperado", blockID)
+		}
+	}
+
+	var nameArray [32]byte
+	copy(nameArray[:], name)
+
+	var pathArray [128]byte
+	copy(pathArray[:], path)
+
+	switch err := fs.Rules.ValidateEntry(path, name); {
+	case err != nil:
+		return err
+	case fs.CheckDirectoryExists(path) == -1:
+		return classErrorf(ErrNotFound, "erro: O caminho '%s' não existe", path)
+	case fs.CheckFileEntryAlreadyExists(nameArray, pathArray) != -1:
+		return fmt.Errorf("erro: arquivo com o mesmo nome já existe no diretório pai.")
+	}
+
+	err := fs.AddFileEntry(&FileEntry{Name: nameArray, Path: pathArray, Size: candidate.Size, FirstBlockID: candidate.Blocks[0]})
+	if err != nil {
+		return err
+	}
+	for i, blockID := range candidate.Blocks {
+		*fs.modifyFAT(blockID) = FATEntry{BlockID: blockID, Used: true}
+		if i < len(candidate.Blocks)-1 {
+			fs.modifyFAT(blockID).NextBlockID = candidate.Blocks[i+1]
+		}
+		fs.Header.FreeSpace -= fs.Header.BlockSize
+	}
+
+	fmt.Printf("Arquivo recuperado como '%s' (%d bytes).\n", joinPath(path, name), candidate.Size)
+	return nil
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Os usuários dos servidores de rede ficam em /.furgfs/users.json. A senha é guardada como um hash
+// PBKDF2-SHA256 com sal; as chaves públicas, no formato de authorized_keys do OpenSSH. Para o SMB, que
+// autentica por NTLMv2, os usuários cadastrados com --smb guardam também o hash NT da senha; para o S3, a
+// chave secreta criada por "user s3key" fica em claro, pois as assinaturas AWS exigem conhecê-la.
+const (
+	usersInfoName   = "users.json"
+	userIterations  = 100000
+	userSaltSize    = 16
+	userHashSize    = 32
+	userNamePattern = `^[a-z_][a-z0-9_.-]{0,31}$`
+)
+
+var userNameRegexp = regexp.MustCompile(userNamePattern)
+
+// VolumeUser é um usuário cadastrado no volume.
+type VolumeUser struct {
+	Salt       []byte   `json:"salt,omitempty"`
+	Hash       []byte   `json:"hash,omitempty"`
+	Iterations int      `json:"iterations,omitempty"`
+	Keys       []string `json:"keys,omitempty"`
+	ReadOnly   bool     `json:"read_only,omitempty"`
+	NTHash     []byte   `json:"nt_hash,omitempty"`
+	S3Secret   string   `json:"s3_secret,omitempty"`
+}
+
+// SetPassword troca a senha do usuário; uma senha vazia remove o acesso por senha.
+func (u *VolumeUser) SetPassword(password string) error {
+	if password == "" {
+		u.Salt, u.Hash, u.Iterations = nil, nil, 0
+		return nil
+	}
+	u.Salt = make([]byte, userSaltSize)
+	if _, err := rand.Read(u.Salt); err != nil {
+		return fmt.Errorf("erro ao gerar o sal: %v", err)
+	}
+	u.Iterations = userIterations
+	u.Hash = pbkdf2SHA256([]byte(password), u.Salt, u.Iterations, userHashSize)
+	return nil
+}
+
+// SetSMBPassword guarda o hash NT de password, usado pelo SMB; uma senha vazia remove o acesso por SMB. O
+// hash NT não tem sal e equivale à senha para quem o obtiver.
+func (u *VolumeUser) SetSMBPassword(password string) {
+	u.NTHash = nil
+	if password != "" {
+		u.NTHash = ntHash(password)
+	}
+}
+
+// newS3Secret cria uma chave secreta do S3: 30 bytes aleatórios em base64, com 40 caracteres como as da AWS.
+func newS3Secret() (string, error) {
+	secret := make([]byte, 30)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("erro ao gerar a chave: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(secret), nil
+}
+
+// CheckPassword informa se password é a senha do usuário.
+func (u *VolumeUser) CheckPassword(password string) bool {
+	if len(u.Hash) == 0 {
+		return false
+	}
+	hash := pbkdf2SHA256([]byte(password), u.Salt, u.Iterations, len(u.Hash))
+	return subtle.ConstantTimeCompare(hash, u.Hash) == 1
+}
+
+// Users lê os usuários do volume, indexados pelo nome.
+func (fs *FURGFileSystem) Users() (map[string]VolumeUser, error) {
+	users := make(map[string]VolumeUser)
+	data, err := fs.readSystemFile(usersInfoName)
+	if errors.Is(err, os.ErrNotExist) {
+		return users, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &users); err != nil {
+		return nil, classErrorf(ErrCorrupted, "erro ao ler os usuários: %v", err)
+	}
+	return users, nil
+}
+
+// SetUser cadastra ou altera o usuário name.
+func (fs *FURGFileSystem) SetUser(name string, user VolumeUser) error {
+	if !userNameRegexp.MatchString(name) {
+		return classErrorf(ErrUsage, "erro: nome de usuário '%s' inválido", name)
+	}
+	users, err := fs.Users()
+	if err != nil {
+		return err
+	}
+	users[name] = user
+	return fs.writeUsers(users)
+}
+
+// RemoveUser remove o usuário name.
+func (fs *FURGFileSystem) RemoveUser(name string) error {
+	users, err := fs.Users()
+	if err != nil {
+		return err
+	}
+	if _, ok := users[name]; !ok {
+		return classErrorf(ErrNotFound, "erro: o usuário '%s' não existe", name)
+	}
+	delete(users, name)
+	return fs.writeUsers(users)
+}
+
+// writeUsers grava os usuários em /.furgfs/users.json.
+func (fs *FURGFileSystem) writeUsers(users map[string]VolumeUser) error {
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fs.writeSystemFile(usersInfoName, data)
+}
+
+// readAuthorizedKeys lê as chaves públicas de um arquivo no formato de authorized_keys, ignorando linhas
+// vazias e comentários.
+func readAuthorizedKeys(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler '%s': %v", path, err)
+	}
+	var keys []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, classErrorf(ErrUsage, "erro: nenhuma chave encontrada em '%s'", path)
+	}
+	return keys, nil
+}
+
+// cliUser implementa "user add [--read-only] [--smb] [--key <arquivo.pub>] <nome>", "user passwd [--smb] <nome>",
+// "user s3key [--remove] <nome>", "user remove <nome>" e "user list [--json]".
+func cliUser(fs *FURGFileSystem, args []string) error {
+	usage := classErrorf(ErrUsage, "uso: furgfs user add [--read-only] [--smb] [--key <arquivo.pub>] <nome> | user passwd [--smb] <nome> | user s3key [--remove] <nome> | user remove <nome> | user list [--json]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	flags := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "add":
+		readOnly := flags.Bool("read-only", false, "o usuário só pode ler o volume")
+		keyFile := flags.String("key", "", "arquivo com as chaves públicas SSH do usuário; sem ele, é pedida uma senha")
+		smb := flags.Bool("smb", false, "guarda também o hash NT da senha, para \"serve smb\"")
+		if err := flags.Parse(args[1:]); err != nil {
+			return classErrorf(ErrUsage, "%w", err)
+		}
+		if flags.NArg() != 1 {
+			return usage
+		}
+		if *smb && *keyFile != "" {
+			return classErrorf(ErrUsage, "erro: --smb exige uma senha e não pode ser usado com --key")
+		}
+		name := flags.Arg(0)
+		users, err := fs.Users()
+		if err != nil {
+			return err
+		}
+		if _, ok := users[name]; ok {
+			return classErrorf(ErrUsage, "erro: o usuário '%s' já existe", name)
+		}
+		user := VolumeUser{ReadOnly: *readOnly}
+		if *keyFile != "" {
+			if user.Keys, err = readAuthorizedKeys(*keyFile); err != nil {
+				return err
+			}
+		} else {
+			password, err := newPassword()
+			if err != nil {
+				return err
+			}
+			if err = user.SetPassword(password); err != nil {
+				return err
+			}
+			if *smb {
+				user.SetSMBPassword(password)
+			}
+		}
+		if err = fs.SetUser(name, user); err != nil {
+			return err
+		}
+		fmt.Printf(tr("Usuário '%s' cadastrado.\n"), name)
+		return fs.saveFileSystemState()
+	case "remove":
+		if len(args) != 2 {
+			return usage
+		}
+		if err := fs.RemoveUser(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf(tr("Usuário '%s' removido.\n"), args[1])
+		return fs.saveFileSystemState()
+	case "s3key":
+		remove := flags.Bool("remove", false, "remove a chave, tirando o acesso por S3")
+		if err := flags.Parse(args[1:]); err != nil {
+			return classErrorf(ErrUsage, "%w", err)
+		}
+		if flags.NArg() != 1 {
+			return usage
+		}
+		name := flags.Arg(0)
+		users, err := fs.Users()
+		if err != nil {
+			return err
+		}
+		user, ok := users[name]
+		if !ok {
+			return classErrorf(ErrNotFound, "erro: o usuário '%s' não existe", name)
+		}
+		user.S3Secret = ""
+		if !*remove {
+			if user.S3Secret, err = newS3Secret(); err != nil {
+				return err
+			}
+		}
+		if err = fs.SetUser(name, user); err != nil {
+			return err
+		}
+		if *remove {
+			fmt.Printf(tr("Chave S3 de '%s' removida.\n"), name)
+		} else {
+			fmt.Printf(tr("Chave de acesso: %s\nChave secreta: %s\n"), name, user.S3Secret)
+		}
+		return fs.saveFileSystemState()
+	case "passwd":
+		smb := flags.Bool("smb", false, "guarda também o hash NT da senha, para \"serve smb\"; sem ele, o acesso por SMB é removido")
+		if err := flags.Parse(args[1:]); err != nil {
+			return classErrorf(ErrUsage, "%w", err)
+		}
+		if flags.NArg() != 1 {
+			return usage
+		}
+		name := flags.Arg(0)
+		users, err := fs.Users()
+		if err != nil {
+			return err
+		}
+		user, ok := users[name]
+		if !ok {
+			return classErrorf(ErrNotFound, "erro: o usuário '%s' não existe", name)
+		}
+		password, err := newPassword()
+		if err != nil {
+			return err
+		}
+		if err = user.SetPassword(password); err != nil {
+			return err
+		}
+		user.NTHash = nil
+		if *smb {
+			user.SetSMBPassword(password)
+		}
+		if err = fs.SetUser(name, user); err != nil {
+			return err
+		}
+		fmt.Printf(tr("Senha de '%s' alterada.\n"), name)
+		return fs.saveFileSystemState()
+	case "list":
+		asJSON := flags.Bool("json", false, "saída em JSON")
+		if err := flags.Parse(args[1:]); err != nil {
+			return classErrorf(ErrUsage, "%w", err)
+		}
+		if flags.NArg() != 0 {
+			return usage
+		}
+		users, err := fs.Users()
+		if err != nil {
+			return err
+		}
+		type jsonUser struct {
+			Name     string `json:"name"`
+			Password bool   `json:"password"`
+			Keys     int    `json:"keys"`
+			ReadOnly bool   `json:"read_only"`
+			SMB      bool   `json:"smb"`
+			S3       bool   `json:"s3"`
+		}
+		list := make([]jsonUser, 0, len(users))
+		for name, user := range users {
+			list = append(list, jsonUser{name, len(user.Hash) > 0, len(user.Keys), user.ReadOnly, len(user.NTHash) > 0, user.S3Secret != ""})
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+		if *asJSON {
+			return printJSON(struct {
+				Schema int        `json:"schema"`
+				Users  []jsonUser `json:"users"`
+			}{jsonSchemaVersion, list})
+		}
+		if len(list) == 0 {
+			fmt.Println(tr("Nenhum usuário cadastrado."))
+			return nil
+		}
+		for _, user := range list {
+			access := tr("leitura e escrita")
+			if user.ReadOnly {
+				access = tr("somente leitura")
+			}
+			var methods []string
+			if user.Password {
+				methods = append(methods, tr("senha"))
+			}
+			if user.Keys > 0 {
+				methods = append(methods, fmt.Sprintf(tr("%d chave(s)"), user.Keys))
+			}
+			if user.SMB {
+				methods = append(methods, "SMB")
+			}
+			if user.S3 {
+				methods = append(methods, "S3")
+			}
+			fmt.Printf("%-16s %-24s %s\n", user.Name, strings.Join(methods, ", "), access)
+		}
+		return nil
+	default:
+		return usage
+	}
+}
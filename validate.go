@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Erros de validação de nomes e caminhos. São retornados dentro de um *ValidationError e podem ser
+// identificados com errors.Is.
+var (
+	ErrEmptyName       = errors.New("nome vazio")
+	ErrNameTooLong     = errors.New("nome longo demais")
+	ErrPathTooLong     = errors.New("caminho longo demais")
+	ErrReservedChar    = errors.New("caractere reservado")
+	ErrControlChar     = errors.New("caractere de controle")
+	ErrInvalidEncoding = errors.New("texto UTF-8 inválido")
+	ErrReservedName    = errors.New("nome reservado")
+	ErrRelativePath    = errors.New("o caminho deve começar com '/'")
+	ErrTrailingSlash   = errors.New("o caminho não pode terminar com '/'")
+	ErrEmptyComponent  = errors.New("o caminho tem um componente vazio ('//')")
+)
+
+// ValidationError descreve por que um nome ou caminho foi rejeitado.
+type ValidationError struct {
+	Field string // "nome" ou "caminho"
+	Value string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("erro: %s '%s' inválido: %v", e.Field, e.Value, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationRules são as regras aplicadas aos nomes e caminhos criados no FURGfs2.
+// Os limites de tamanho não podem passar dos campos de FileEntry (32 bytes para o nome e 128 para o caminho).
+type ValidationRules struct {
+	MaxNameLength     int
+	MaxPathLength     int
+	ReservedChars     string // caracteres proibidos em nomes, além de '/'
+	AllowControlChars bool
+}
+
+// defaultValidationRules proíbe também os caracteres usados nos padrões glob (*, ? e [),
+// para que um nome nunca seja confundido com um padrão nas operações em lote.
+var defaultValidationRules = ValidationRules{
+	MaxNameLength: 32,
+	MaxPathLength: 128,
+	ReservedChars: "*?[",
+}
+
+// ValidateName verifica um nome de arquivo ou diretório (um único componente de caminho).
+func (r ValidationRules) ValidateName(name string) error {
+	invalid := func(err error) error {
+		return &ValidationError{Field: "nome", Value: name, Err: err}
+	}
+
+	switch {
+	case name == "" || isAllNullBytes(name):
+		return invalid(ErrEmptyName)
+	case len(name) > min(r.MaxNameLength, 32):
+		return invalid(fmt.Errorf("%w: %d bytes, o máximo é %d", ErrNameTooLong, len(name), min(r.MaxNameLength, 32)))
+	case name == "." || name == "..":
+		return invalid(ErrReservedName)
+	case !utf8.ValidString(name):
+		return invalid(ErrInvalidEncoding)
+	}
+	for _, c := range name {
+		if c == '/' || strings.ContainsRune(r.ReservedChars, c) {
+			return invalid(fmt.Errorf("%w %q", ErrReservedChar, c))
+		}
+		if unicode.IsControl(c) && !r.AllowControlChars {
+			return invalid(fmt.Errorf("%w %q", ErrControlChar, c))
+		}
+	}
+	return nil
+}
+
+// ValidatePath verifica o caminho absoluto de um diretório: deve começar com '/', não pode terminar com '/'
+// (exceto a raiz) nem ter componentes vazios, e cada componente deve ser um nome válido.
+func (r ValidationRules) ValidatePath(path string) error {
+	invalid := func(err error) error {
+		return &ValidationError{Field: "caminho", Value: path, Err: err}
+	}
+
+	switch {
+	case path == "/":
+		return nil
+	case !strings.HasPrefix(path, "/"):
+		return invalid(ErrRelativePath)
+	case len(path) > min(r.MaxPathLength, 128):
+		return invalid(fmt.Errorf("%w: %d bytes, o máximo é %d", ErrPathTooLong, len(path), min(r.MaxPathLength, 128)))
+	case strings.HasSuffix(path, "/"):
+		return invalid(ErrTrailingSlash)
+	}
+	for _, component := range strings.Split(path[1:], "/") {
+		if component == "" {
+			return invalid(ErrEmptyComponent)
+		}
+		var validationErr *ValidationError
+		if err := r.ValidateName(component); errors.As(err, &validationErr) {
+			return invalid(validationErr.Err)
+		}
+	}
+	return nil
+}
+
+// ValidateEntry verifica o nome e o caminho do diretório pai de uma nova entrada. O caminho completo também
+// precisa caber no campo Path, pois ele será o diretório pai das entradas criadas dentro de um diretório.
+func (r ValidationRules) ValidateEntry(path, name string) error {
+	if err := r.ValidatePath(path); err != nil {
+		return err
+	}
+	return r.ValidateName(name)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// verifyReport é o relatório de integridade de "verify-image". Cada seção traz os problemas da sua verificação;
+// OK é verdadeiro quando nenhuma seção tem problemas.
+type verifyReport struct {
+	Schema int    `json:"schema"`
+	Image  string `json:"image"`
+	OK     bool   `json:"ok"`
+
+	Header struct {
+		TotalSize uint32   `json:"total_size"`
+		BlockSize uint32   `json:"block_size"`
+		DataStart uint32   `json:"data_start"`
+		Problems  []string `json:"problems"`
+	} `json:"header"`
+
+	Checksums struct {
+		Header        string   `json:"header"`  // "valid", "invalid" ou "absent"
+		Content       string   `json:"content"` // "verified" no armazenamento por conteúdo, "encrypted" se ele for cifrado, ou "absent"
+		Files         int      `json:"files"`
+		FilesCovered  int      `json:"files_covered"` // só o armazenamento por conteúdo guarda hashes do conteúdo
+		MetadataFiles int      `json:"metadata_files"`
+		Problems      []string `json:"problems"`
+	} `json:"checksums"`
+
+	Chains struct {
+		Blocks   int      `json:"blocks"`
+		Used     int      `json:"used"`
+		Entries  int      `json:"entries"`
+		Problems []string `json:"problems"`
+	} `json:"chains"`
+
+	FreeSpace struct {
+		Recorded   uint32 `json:"recorded"`
+		Computed   uint32 `json:"computed"`
+		Consistent bool   `json:"consistent"`
+	} `json:"free_space"`
+}
+
+// VerifyImage verifica a imagem imageName sem alterá-la: o arquivo é aberto apenas para leitura. Um cabeçalho
+// com problemas impede as demais verificações, que dependem dele para localizar a FAT e o diretório.
+func VerifyImage(imageName string) (verifyReport, error) {
+	var report verifyReport
+	report.Schema = jsonSchemaVersion
+	report.Image = imageName
+	report.Header.Problems = []string{}
+	report.Chains.Problems = []string{}
+	report.Checksums.Problems = []string{}
+
+	f, err := os.Open(imageName)
+	if err != nil {
+		return report, fmt.Errorf("erro ao abrir o arquivo: %w", err)
+	}
+	defer f.Close()
+
+	h, stored, err := readHeader(f)
+	if err != nil {
+		return report, classErrorf(ErrCorrupted, "%w", err)
+	}
+	report.Header.TotalSize, report.Header.BlockSize, report.Header.DataStart = h.TotalSize, h.BlockSize, h.DataStart
+	report.Header.Problems = append(report.Header.Problems, headerProblems(h)...)
+	if len(report.Header.Problems) > 0 {
+		return report, nil
+	}
+
+	switch stored {
+	case 0:
+		report.Checksums.Header = "absent"
+	case headerChecksum(h):
+		report.Checksums.Header = "valid"
+	default:
+		report.Checksums.Header = "invalid"
+	}
+
+	fs, err := readFileSystem(f)
+	if err != nil {
+		return report, err
+	}
+	freeBlocks := 0
+	for _, fatEntry := range fs.fatEntries() {
+		if !fatEntry.Used {
+			freeBlocks++
+		}
+	}
+	report.Chains.Blocks = len(fs.FAT)
+	report.Chains.Used = len(fs.FAT) - freeBlocks
+	report.FreeSpace.Recorded = h.FreeSpace
+	report.FreeSpace.Computed = uint32(freeBlocks) * h.BlockSize
+	report.FreeSpace.Consistent = report.FreeSpace.Recorded == report.FreeSpace.Computed
+
+	// O espaço livre tem sua própria seção; com o valor calculado, checkConsistency relata apenas as cadeias.
+	// A alteração fica na memória, pois o arquivo foi aberto apenas para leitura.
+	fs.Header.FreeSpace = report.FreeSpace.Computed
+	fs.checkConsistency(func(problem string) bool {
+		report.Chains.Problems = append(report.Chains.Problems, problem)
+		return false
+	})
+
+	// No armazenamento por conteúdo, cada bloco é conferido com o hash do manifesto; sem a senha de um volume
+	// cifrado, o conteúdo não pode ser conferido
+	report.Checksums.Content = "absent"
+	if fs.casVolume() {
+		report.Checksums.Content = "verified"
+		if encrypted, err := fs.encrypted(); err != nil || encrypted {
+			report.Checksums.Content = "encrypted"
+		}
+	}
+	for i := range fs.RootDir {
+		entry := &fs.RootDir[i]
+		if entry.Name[0] == 0 {
+			continue
+		}
+		report.Chains.Entries++
+		switch {
+		case entry.IsDirectory:
+		case entry.PathString() == "/"+systemDirName:
+			report.Checksums.MetadataFiles++
+		default:
+			report.Checksums.Files++
+			if report.Checksums.Content != "verified" || entry.Size == 0 {
+				continue
+			}
+			problems, err := fs.verifyCASFile(entry)
+			if err != nil {
+				// A cadeia com problemas já aparece na seção das cadeias
+				continue
+			}
+			report.Checksums.FilesCovered++
+			report.Checksums.Problems = append(report.Checksums.Problems, problems...)
+		}
+	}
+
+	report.OK = len(report.Chains.Problems) == 0 && report.FreeSpace.Consistent && report.Checksums.Header != "invalid" &&
+		len(report.Checksums.Problems) == 0
+	return report, nil
+}
+
+// cliVerifyImage implementa "verify-image [--json] [imagem]": exibe o relatório de integridade da imagem
+// (a atual, por padrão). Termina com o código de imagem corrompida quando há problemas.
+func cliVerifyImage(imageName string, args []string) error {
+	flags := flag.NewFlagSet("verify-image", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "saída em JSON")
+	if err := flags.Parse(args); err != nil {
+		return classErrorf(ErrUsage, "%w", err)
+	}
+	if flags.NArg() > 1 {
+		return classErrorf(ErrUsage, "uso: furgfs verify-image [--json] [imagem]")
+	}
+	if flags.NArg() == 1 {
+		imageName = flags.Arg(0)
+	}
+
+	report, err := VerifyImage(imageName)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		err = printJSON(report)
+	} else {
+		printVerifyReport(report)
+	}
+	if err == nil && !report.OK {
+		return classErrorf(ErrCorrupted, "erro: a imagem '%s' tem problemas de integridade (veja fsck)", imageName)
+	}
+	return err
+}
+
+// printVerifyReport exibe o relatório de integridade em texto, uma seção por verificação.
+func printVerifyReport(report verifyReport) {
+	status := func(problems int) string {
+		if problems == 0 {
+			return "ok"
+		}
+		return stdoutColors.failure(fmt.Sprintf(tr("%d problema(s)"), problems))
+	}
+
+	fmt.Printf(tr("Imagem: %s\n"), report.Image)
+	fmt.Printf("%-24s %s\n", tr("Cabeçalho:"), status(len(report.Header.Problems)))
+	for _, problem := range report.Header.Problems {
+		fmt.Println("  -", problem)
+	}
+	if len(report.Header.Problems) > 0 {
+		fmt.Println(tr("As demais verificações dependem de um cabeçalho válido (veja superblock)."))
+		return
+	}
+
+	fmt.Printf("%-24s %s\n", tr("Cadeias da FAT:"), status(len(report.Chains.Problems)))
+	fmt.Printf(tr("  %d bloco(s), %d em uso, %d entrada(s) no diretório\n"), report.Chains.Blocks, report.Chains.Used, report.Chains.Entries)
+	for _, problem := range report.Chains.Problems {
+		fmt.Println("  -", problem)
+	}
+
+	checksumStatus := tr("cabeçalho válido")
+	switch report.Checksums.Header {
+	case "absent":
+		checksumStatus = tr("cabeçalho sem checksum")
+	case "invalid":
+		checksumStatus = stdoutColors.failure(tr("cabeçalho inválido (execute o fsck)"))
+	}
+	if len(report.Checksums.Problems) > 0 {
+		checksumStatus += ", " + status(len(report.Checksums.Problems))
+	}
+	fmt.Printf("%-24s %s\n", tr("Checksums:"), checksumStatus)
+	switch report.Checksums.Content {
+	case "verified":
+		fmt.Printf(tr("  conteúdo: %d de %d arquivo(s) conferidos com os hashes dos blocos\n"),
+			report.Checksums.FilesCovered, report.Checksums.Files)
+	case "encrypted":
+		fmt.Printf(tr("  conteúdo: %d arquivo(s) não conferidos (o volume é cifrado)\n"), report.Checksums.Files)
+	default:
+		fmt.Printf(tr("  conteúdo: %d de %d arquivo(s) cobertos (o formato não guarda checksums de dados)\n"),
+			report.Checksums.FilesCovered, report.Checksums.Files)
+	}
+	for _, problem := range report.Checksums.Problems {
+		fmt.Println("  -", problem)
+	}
+
+	freeStatus := "ok"
+	if !report.FreeSpace.Consistent {
+		freeStatus = stdoutColors.failure(tr("inconsistente"))
+	}
+	fmt.Printf("%-24s %s\n", tr("Espaço livre:"), freeStatus)
+	fmt.Printf(tr("  registrado: %d bytes, calculado pela FAT: %d bytes\n"), report.FreeSpace.Recorded, report.FreeSpace.Computed)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Os metadados do volume que não cabem no cabeçalho de formato fixo ficam em arquivos protegidos dentro do
+// diretório de sistema /.furgfs. Assim, imagens antigas continuam válidas (apenas não têm esses arquivos)
+// e as ferramentas que percorrem a FAT, como fsck e gc, tratam esses arquivos como quaisquer outros.
+const (
+	systemDirName  = ".furgfs"
+	systemDir      = "/" + systemDirName
+	volumeInfoName = "volume.json"
+)
+
+// VolumeInfo identifica o volume.
+type VolumeInfo struct {
+	Label   string    `json:"label"`
+	UUID    string    `json:"uuid"`
+	Created time.Time `json:"created"`
+	Storage string    `json:"storage,omitempty"` // "cas" no armazenamento por conteúdo (veja cas.go)
+}
+
+// initVolume grava os metadados de um volume recém-criado, com o rótulo label e um UUID novo.
+func (fs *FURGFileSystem) initVolume(label string) error {
+	uuid, err := newUUID()
+	if err != nil {
+		return err
+	}
+	return fs.SetVolumeInfo(VolumeInfo{Label: label, UUID: uuid, Created: time.Now().UTC().Truncate(time.Second)})
+}
+
+// VolumeInfo lê os metadados do volume. Imagens sem /.furgfs/volume.json retornam um VolumeInfo vazio.
+func (fs *FURGFileSystem) VolumeInfo() (VolumeInfo, error) {
+	var info VolumeInfo
+	data, err := fs.readSystemFile(volumeInfoName)
+	if errors.Is(err, os.ErrNotExist) {
+		return info, nil
+	}
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(data, &info)
+	if err != nil {
+		return info, fmt.Errorf("erro ao ler os metadados do volume: %v", err)
+	}
+	return info, nil
+}
+
+// SetVolumeInfo grava os metadados do volume.
+func (fs *FURGFileSystem) SetVolumeInfo(info VolumeInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fs.writeSystemFile(volumeInfoName, data)
+}
+
+// readSystemFile lê o arquivo name do diretório de sistema; retorna os.ErrNotExist se ele não existir.
+func (fs *FURGFileSystem) readSystemFile(name string) ([]byte, error) {
+	index := fs.lookupEntry(name, systemDir)
+	if index == -1 || fs.RootDir[index].IsDirectory {
+		return nil, os.ErrNotExist
+	}
+	return fs.readFileContent(&fs.RootDir[index])
+}
+
+// writeSystemFile substitui o conteúdo do arquivo name do diretório de sistema, criando o diretório se preciso.
+// O novo conteúdo é gravado antes de o antigo ser descartado, para que uma falha não perca os metadados.
+func (fs *FURGFileSystem) writeSystemFile(name string, data []byte) error {
+	if fs.CheckDirectoryExists(systemDir) == -1 {
+		err := fs.CreateDirectory(systemDirName, "/", false)
+		if err != nil {
+			return fmt.Errorf("erro ao criar o diretório de sistema: %w", err)
+		}
+	}
+
+	tmpName := name + "~"
+	if old := fs.lookupEntry(tmpName, systemDir); old != -1 {
+		fs.discardEntries([]int{old})
+	}
+	index, err := fs.createFile(systemDir, tmpName, bytes.NewReader(data), true)
+	if err != nil {
+		return fmt.Errorf("erro ao gravar '%s': %w", joinPath(systemDir, name), err)
+	}
+	if old := fs.lookupEntry(name, systemDir); old != -1 {
+		fs.discardEntries([]int{old})
+	}
+	var nameArray [32]byte
+	copy(nameArray[:], name)
+	fs.renameEntry(index, nameArray, fs.RootDir[index].Path)
+	return nil
+}
+
+// newUUID gera um UUID aleatório (versão 4).
+func newUUID() (string, error) {
+	var b [16]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return "", fmt.Errorf("erro ao gerar o UUID: %v", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
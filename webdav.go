@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+
+	"golang.org/x/net/webdav"
+)
+
+// davFileSystem adapta um sharedVolume à interface webdav.FileSystem.
+type davFileSystem struct {
+	vol *sharedVolume
+}
+
+func (d davFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return d.vol.Mkdir(name)
+}
+
+func (d davFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	return d.vol.OpenFile(name, flag)
+}
+
+func (d davFileSystem) RemoveAll(ctx context.Context, name string) error {
+	return d.vol.RemoveAll(name)
+}
+
+func (d davFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	return d.vol.Rename(oldName, newName)
+}
+
+func (d davFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return d.vol.Stat(name)
+}
+
+// setupWebDAV prepara "serve webdav". Os bloqueios (LOCK) pedidos pelos clientes ficam só em memória.
+func setupWebDAV(flags *flag.FlagSet) func(vol *sharedVolume) (shareServer, error) {
+	return func(vol *sharedVolume) (shareServer, error) {
+		handler := &webdav.Handler{
+			FileSystem: davFileSystem{vol},
+			LockSystem: webdav.NewMemLS(),
+			Logger: func(r *http.Request, err error) {
+				if err != nil {
+					logger.Warn("webdav", "method", r.Method, "path", r.URL.Path, "error", err)
+				} else {
+					logger.Info("webdav", "method", r.Method, "path", r.URL.Path)
+				}
+			},
+		}
+		return &http.Server{Handler: handler}, nil
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Os webhooks avisam sistemas externos das alterações feitas pelos servidores de "serve" e pelo ponto de
+// montagem: a cada arquivo ou diretório criado, alterado ou apagado, um POST com o evento em JSON é enviado
+// às URLs cadastradas em /.furgfs/webhooks.json. O envio é feito em segundo plano, na ordem dos eventos,
+// sem atrasar o cliente; uma renomeação gera o evento delete do caminho antigo e o create do novo.
+const (
+	webhookInfoName = "webhooks.json"
+	webhookQueue    = 256
+	webhookAttempts = 4
+	webhookTimeout  = 10 * time.Second
+)
+
+// webhookEvents são os tipos de evento, na ordem em que são exibidos.
+var webhookEvents = []string{"create", "modify", "delete"}
+
+// webhookConfig é um webhook cadastrado. Sem eventos, o webhook recebe todos.
+type webhookConfig struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+	Secret string   `json:"secret,omitempty"` // chave do HMAC-SHA256 do corpo, enviado em X-Furgfs-Signature
+}
+
+func (h webhookConfig) wants(event string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, event)
+}
+
+// webhookEvent é o corpo de uma notificação.
+type webhookEvent struct {
+	Event  string    `json:"event"`
+	Path   string    `json:"path"`
+	Type   string    `json:"type,omitempty"` // "file" ou "directory"; ausente em delete
+	Size   int64     `json:"size,omitempty"`
+	User   string    `json:"user,omitempty"`
+	Source string    `json:"source,omitempty"`
+	Volume string    `json:"volume"`
+	Time   time.Time `json:"time"`
+}
+
+// Webhooks retorna os webhooks cadastrados.
+func (fs *FURGFileSystem) Webhooks() ([]webhookConfig, error) {
+	var hooks []webhookConfig
+	data, err := fs.readSystemFile(webhookInfoName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		return nil, classErrorf(ErrCorrupted, "erro ao ler %s: %v", joinPath(systemDir, webhookInfoName), err)
+	}
+	return hooks, nil
+}
+
+// setWebhooks grava a lista de webhooks; uma lista vazia apaga /.furgfs/webhooks.json.
+func (fs *FURGFileSystem) setWebhooks(hooks []webhookConfig) error {
+	if len(hooks) == 0 {
+		if index := fs.lookupEntry(webhookInfoName, systemDir); index != -1 {
+			fs.discardEntries([]int{index})
+		}
+		return fs.saveFileSystemState()
+	}
+	data, err := json.MarshalIndent(hooks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fs.writeSystemFile(webhookInfoName, data); err != nil {
+		return err
+	}
+	return fs.saveFileSystemState()
+}
+
+// webhookNotifier envia os eventos de um sharedVolume aos webhooks, um de cada vez.
+type webhookNotifier struct {
+	hooks  []webhookConfig
+	volume string
+	client *http.Client
+	queue  chan webhookEvent
+	done   chan struct{}
+}
+
+// newWebhookNotifier começa a enviar os eventos aos webhooks de fs; retorna nil se não houver nenhum.
+func newWebhookNotifier(fs *FURGFileSystem) (*webhookNotifier, error) {
+	hooks, err := fs.Webhooks()
+	if err != nil || len(hooks) == 0 {
+		return nil, err
+	}
+	n := &webhookNotifier{
+		hooks:  hooks,
+		volume: filepath.Base(fs.FilePointer.Name()),
+		client: &http.Client{Timeout: webhookTimeout},
+		queue:  make(chan webhookEvent, webhookQueue),
+		done:   make(chan struct{}),
+	}
+	go n.run()
+	return n, nil
+}
+
+// notify põe o evento na fila. Com a fila cheia, porque os webhooks não respondem, o evento é descartado.
+func (n *webhookNotifier) notify(event webhookEvent) {
+	if n == nil {
+		return
+	}
+	event.Volume, event.Time = n.volume, time.Now()
+	select {
+	case n.queue <- event:
+	default:
+		logger.Warn("webhook: fila cheia, evento descartado", "event", event.Event, "path", event.Path)
+	}
+}
+
+// Close espera o envio dos eventos da fila, por até webhookTimeout.
+func (n *webhookNotifier) Close() {
+	if n == nil {
+		return
+	}
+	close(n.queue)
+	select {
+	case <-n.done:
+	case <-time.After(webhookTimeout):
+		logger.Warn("webhook: eventos não enviados no encerramento", "pending", len(n.queue))
+	}
+}
+
+func (n *webhookNotifier) run() {
+	defer close(n.done)
+	for event := range n.queue {
+		for _, hook := range n.hooks {
+			if !hook.wants(event.Event) {
+				continue
+			}
+			if err := n.deliver(hook, event); err != nil {
+				logger.Warn("webhook: envio falhou", "url", hook.URL, "event", event.Event, "path", event.Path, "error", err)
+			}
+		}
+	}
+}
+
+// deliver envia event a hook, tentando de novo, com espera crescente, após falhas de rede e respostas 5xx.
+func (n *webhookNotifier) deliver(hook webhookConfig, event webhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		retry, err = postWebhook(n.client, hook, event.Event, body)
+		if err == nil || !retry || attempt == webhookAttempts {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// postWebhook faz um POST de body para hook e informa se vale tentar de novo após uma falha.
+func postWebhook(client *http.Client, hook webhookConfig, event string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "furgfs")
+	req.Header.Set("X-Furgfs-Event", event)
+	if hook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Furgfs-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode >= 500, fmt.Errorf("resposta %s", resp.Status)
+	}
+	return false, nil
+}
+
+// parseWebhookEvents interpreta a lista de eventos de --events, separados por vírgula.
+func parseWebhookEvents(list string) ([]string, error) {
+	if list == "" || list == "all" {
+		return nil, nil
+	}
+	var events []string
+	for _, event := range strings.Split(list, ",") {
+		event = strings.TrimSpace(event)
+		if !slices.Contains(webhookEvents, event) {
+			return nil, classErrorf(ErrUsage, "erro: evento '%s' desconhecido; use %s", event, strings.Join(webhookEvents, ", "))
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// cliWebhook implementa "webhook add", "webhook list", "webhook remove" e "webhook test".
+func cliWebhook(fs *FURGFileSystem, args []string) error {
+	usage := classErrorf(ErrUsage, "uso: furgfs webhook add [--events create,modify,delete] [--secret <chave>] <url> | webhook list [--json] | webhook remove <url> | webhook test <url>")
+	if len(args) == 0 {
+		return usage
+	}
+	hooks, err := fs.Webhooks()
+	if err != nil {
+		return err
+	}
+	find := func(rawURL string) (int, error) {
+		index := slices.IndexFunc(hooks, func(h webhookConfig) bool { return h.URL == rawURL })
+		if index == -1 {
+			return -1, classErrorf(ErrNotFound, "erro: webhook '%s' não cadastrado", rawURL)
+		}
+		return index, nil
+	}
+
+	flags := flag.NewFlagSet("webhook "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "add":
+		events := flags.String("events", "", "eventos enviados, separados por vírgula (padrão: todos)")
+		secret := flags.String("secret", "", "chave para assinar o corpo com HMAC-SHA256")
+		if err := flags.Parse(args[1:]); err != nil {
+			return classErrorf(ErrUsage, "%w", err)
+		}
+		if flags.NArg() != 1 {
+			return usage
+		}
+		hook := webhookConfig{URL: flags.Arg(0), Secret: *secret}
+		if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return classErrorf(ErrUsage, "erro: URL '%s' inválida; use http:// ou https://", hook.URL)
+		}
+		if hook.Events, err = parseWebhookEvents(*events); err != nil {
+			return err
+		}
+		// Cadastrar de novo a mesma URL troca os eventos e a chave
+		if index, err := find(hook.URL); err == nil {
+			hooks[index] = hook
+		} else {
+			hooks = append(hooks, hook)
+		}
+		if err := fs.setWebhooks(hooks); err != nil {
+			return err
+		}
+		fmt.Printf(tr("Webhook '%s' cadastrado.\n"), hook.URL)
+		return nil
+	case "remove":
+		if len(args) != 2 {
+			return usage
+		}
+		index, err := find(args[1])
+		if err != nil {
+			return err
+		}
+		if err := fs.setWebhooks(slices.Delete(hooks, index, index+1)); err != nil {
+			return err
+		}
+		fmt.Printf(tr("Webhook '%s' removido.\n"), args[1])
+		return nil
+	case "test":
+		if len(args) != 2 {
+			return usage
+		}
+		index, err := find(args[1])
+		if err != nil {
+			return err
+		}
+		event := webhookEvent{Event: "test", Path: "/", Type: "directory", User: localUser(), Source: "cli",
+			Volume: filepath.Base(fs.FilePointer.Name()), Time: time.Now()}
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := postWebhook(&http.Client{Timeout: webhookTimeout}, hooks[index], event.Event, body); err != nil {
+			return fmt.Errorf(tr("erro ao enviar para '%s': %v"), args[1], err)
+		}
+		fmt.Printf(tr("Evento de teste entregue a '%s'.\n"), args[1])
+		return nil
+	case "list":
+		asJSON := flags.Bool("json", false, "saída em JSON")
+		if err := flags.Parse(args[1:]); err != nil {
+			return classErrorf(ErrUsage, "%w", err)
+		}
+		if flags.NArg() != 0 {
+			return usage
+		}
+		type jsonWebhook struct {
+			URL    string   `json:"url"`
+			Events []string `json:"events"`
+			Signed bool     `json:"signed"`
+		}
+		list := make([]jsonWebhook, 0, len(hooks))
+		for _, hook := range hooks {
+			events := hook.Events
+			if len(events) == 0 {
+				events = webhookEvents
+			}
+			list = append(list, jsonWebhook{hook.URL, events, hook.Secret != ""})
+		}
+		if *asJSON {
+			return printJSON(struct {
+				Schema   int           `json:"schema"`
+				Webhooks []jsonWebhook `json:"webhooks"`
+			}{jsonSchemaVersion, list})
+		}
+		if len(list) == 0 {
+			fmt.Println(tr("Nenhum webhook cadastrado."))
+			return nil
+		}
+		for _, hook := range list {
+			signed := ""
+			if hook.Signed {
+				signed = tr(" (assinado)")
+			}
+			fmt.Printf("%s  %s%s\n", hook.URL, strings.Join(hook.Events, ","), signed)
+		}
+		return nil
+	default:
+		return usage
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// As escritas de blocos de dados passam por um cache em memória: writeBlock guarda o conteúdo e as
+// leituras o enxergam, mas a imagem só recebe os blocos em lotes, ordenados e com os blocos vizinhos
+// juntados em uma única escrita. Importar muitos arquivos pequenos deixa de custar uma escrita pequena e
+// aleatória por bloco. O lote é gravado quando os blocos pendentes somam o limite do cache, quando o
+// mais antigo espera há writeCacheDelay (conferido a cada nova escrita), em Flush e sempre antes dos
+// metadados, em saveFileSystemState, para que a FAT gravada nunca aponte para blocos ainda em memória.
+// Blocos pendentes descartados, num comando que falha antes de salvar o estado, não pertencem a nenhum
+// arquivo. FURGFS_WRITE_CACHE muda o limite (por exemplo, 32M); 0 desliga o cache.
+const (
+	writeCacheLimit = 8 << 20
+	writeCacheDelay = 5 * time.Second
+)
+
+// writeCache guarda os blocos escritos e ainda não gravados na imagem.
+type writeCache struct {
+	limit  int
+	blocks map[uint32][]byte // conteúdo pendente, do início do bloco; pode ser menor que o bloco
+	size   int               // bytes pendentes
+	oldest time.Time         // primeira escrita pendente
+}
+
+// writeCache retorna o cache de escritas do volume, criado na primeira escrita; nil com o cache desligado.
+func (fs *FURGFileSystem) writeCache() *writeCache {
+	if fs.wcache != nil {
+		return fs.wcache
+	}
+	limit := writeCacheLimit
+	if value := os.Getenv("FURGFS_WRITE_CACHE"); value != "" {
+		size, err := parseSize(value)
+		if err != nil {
+			logger.Warn("FURGFS_WRITE_CACHE inválido; usando o padrão", "value", value, "error", err)
+		} else {
+			limit = int(size)
+		}
+	}
+	if limit <= 0 {
+		return nil
+	}
+	fs.wcache = &writeCache{limit: limit, blocks: make(map[uint32][]byte)}
+	return fs.wcache
+}
+
+// cacheBlock guarda data como o novo início do bloco blockID e grava o lote se o cache encheu ou envelheceu.
+func (fs *FURGFileSystem) cacheBlock(c *writeCache, blockID uint32, data []byte) error {
+	c.put(blockID, data, fs.Header.BlockSize)
+	if c.due() {
+		return fs.flushBlocks()
+	}
+	return nil
+}
+
+// put guarda data como o novo início do bloco blockID, sem gravar nada na imagem.
+func (c *writeCache) put(blockID uint32, data []byte, blockSize uint32) {
+	pending, ok := c.blocks[blockID]
+	if !ok {
+		if len(c.blocks) == 0 {
+			c.oldest = time.Now()
+		}
+		pending = make([]byte, 0, blockSize)
+	}
+	// Uma escrita mais curta só substitui o início do bloco, como na imagem
+	if len(data) > len(pending) {
+		c.size += len(data) - len(pending)
+		pending = pending[:len(data)]
+	}
+	copy(pending, data)
+	c.blocks[blockID] = pending
+}
+
+// due informa se o cache encheu ou envelheceu e deve ser gravado.
+func (c *writeCache) due() bool {
+	return c.size >= c.limit || len(c.blocks) > 0 && time.Since(c.oldest) >= writeCacheDelay
+}
+
+// overlay copia para p a parte pendente do bloco blockID que começa em inner. Retorna quantos bytes do
+// início de p vieram do cache.
+func (c *writeCache) overlay(blockID uint32, p []byte, inner int64) int {
+	if c == nil {
+		return 0
+	}
+	pending, ok := c.blocks[blockID]
+	if !ok || inner >= int64(len(pending)) {
+		return 0
+	}
+	return copy(p, pending[inner:])
+}
+
+// holds informa se algum dos blocos tem conteúdo pendente no cache.
+func (c *writeCache) holds(blocks []uint32) bool {
+	if c == nil || len(c.blocks) == 0 {
+		return false
+	}
+	for _, blockID := range blocks {
+		if _, ok := c.blocks[blockID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// flushBlocks grava na imagem os blocos pendentes, em ordem e juntando os vizinhos. O fim de um bloco
+// incompleto seguido de outro pendente é preenchido com zeros: nenhuma leitura usa os bytes além do
+// tamanho do arquivo, e a recuperação de cadeias órfãs (veja gc) já os descarta.
+func (fs *FURGFileSystem) flushBlocks() (err error) {
+	c := fs.wcache
+	if c == nil || len(c.blocks) == 0 {
+		return nil
+	}
+	end := fs.startSpan("furgfs.block.flush")
+	defer func() { end(err) }()
+	ids := make([]uint32, 0, len(c.blocks))
+	for id := range c.blocks {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	blockSize := int64(fs.Header.BlockSize)
+	writes := 0
+	var run []byte
+	for i := 0; i < len(ids); {
+		first := ids[i]
+		run = append(run[:0], c.blocks[first]...)
+		for i++; i < len(ids) && ids[i] == ids[i-1]+1; i++ {
+			offset := int64(ids[i]-first) * blockSize
+			run = append(run, make([]byte, offset-int64(len(run)))...)
+			run = append(run, c.blocks[ids[i]]...)
+		}
+		if _, err = fs.FilePointer.WriteAt(run, fs.blockOffset(first)); err != nil {
+			return fmt.Errorf("Erro ao escrever dados no arquivo: %v", err)
+		}
+		writes++
+	}
+	logger.Debug("blocos pendentes gravados", fs.imageAttr(), "blocks", len(ids), "bytes", c.size, "writes", writes)
+	clear(c.blocks)
+	c.size = 0
+	return nil
+}
+
+// Flush grava os blocos pendentes e pede ao sistema operacional que leve a imagem ao disco.
+func (fs *FURGFileSystem) Flush() error {
+	if err := fs.flushBlocks(); err != nil {
+		return err
+	}
+	return fs.FilePointer.Sync()
+}
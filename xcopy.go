@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// copyTreeTo copia o arquivo ou diretório name, em dir, para destDir/destName na imagem dst, que pode ser
+// a própria fs ou outra imagem aberta. Os dados são lidos da cadeia de blocos da origem e gravados
+// diretamente na FAT do destino, sem arquivo temporário no sistema real. A proteção é preservada.
+func (fs *FURGFileSystem) copyTreeTo(dst *FURGFileSystem, dir, name, destDir, destName string) error {
+	index := fs.lookupEntry(name, dir)
+	if index == -1 {
+		return classErrorf(ErrNotFound, "erro: '%s' não foi encontrado no sistema de arquivos", joinPath(dir, name))
+	}
+	entry := &fs.RootDir[index]
+	full := joinPath(dir, name)
+	destFull := joinPath(destDir, destName)
+	if dst == fs && entry.IsDirectory && (destFull == full || strings.HasPrefix(destFull, full+"/")) {
+		return fmt.Errorf("erro: não é possível copiar '%s' para dentro de si mesmo", full)
+	}
+
+	if !entry.IsDirectory {
+		r, err := fs.newFileReader(entry)
+		if err != nil {
+			return err
+		}
+		_, err = dst.createFile(destDir, destName, r, entry.Protected)
+		return err
+	}
+
+	err := dst.CreateDirectory(destName, destDir, false)
+	if err != nil {
+		return err
+	}
+	for _, i := range fs.entriesInDirectory(full, false) {
+		child := fs.RootDir[i].NameString()
+		err = fs.copyTreeTo(dst, full, child, destFull, child)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitImagePath separa um argumento no formato "imagem:/caminho" na imagem aberta com esse nome e no
+// caminho. Sem o prefixo (ou se o prefixo não for o nome de uma imagem aberta), usa a imagem atual fs.
+func splitImagePath(fs *FURGFileSystem, arg string) (*FURGFileSystem, string) {
+	if name, path, ok := strings.Cut(arg, ":"); ok {
+		if image, err := openImages.get(name); err == nil {
+			return image, image.resolvePath(path)
+		}
+	}
+	return fs, fs.resolvePath(arg)
+}
+
+// transferEntry copia (ou move, se move for verdadeiro) source para destination, que podem estar em imagens
+// diferentes ("imagem:/caminho"). Se destination for um diretório existente, a entrada vai para dentro dele.
+// Retorna as imagens alteradas, que devem ser salvas.
+func (fs *FURGFileSystem) transferEntry(source, destination string, move bool) ([]*FURGFileSystem, error) {
+	src, srcFull := splitImagePath(fs, source)
+	dst, dstFull := splitImagePath(fs, destination)
+
+	if src == dst && move {
+		return []*FURGFileSystem{src}, src.MoveEntry(srcFull, dstFull)
+	}
+
+	srcDir, srcName := splitPath(srcFull)
+	if srcFull == "/" || src.lookupEntry(srcName, srcDir) == -1 {
+		return nil, classErrorf(ErrNotFound, "erro: '%s' não foi encontrado no sistema de arquivos", source)
+	}
+	if dst.CheckDirectoryExists(dstFull) != -1 {
+		dstFull = joinPath(dstFull, srcName)
+	}
+	dstDir, dstName := splitPath(dstFull)
+
+	err := src.copyTreeTo(dst, srcDir, srcName, dstDir, dstName)
+	if err != nil {
+		// Desfaz a cópia parcial para não deixar uma árvore incompleta no destino
+		if dst.lookupEntry(dstName, dstDir) != -1 {
+			policy := dst.Policy
+			dst.Policy = nil
+			dst.removeInternalTree(dstDir, dstName)
+			dst.Policy = policy
+		}
+		return nil, err
+	}
+	if !move {
+		return []*FURGFileSystem{dst}, nil
+	}
+	return []*FURGFileSystem{dst, src}, src.removeInternalTree(srcDir, srcName)
+}
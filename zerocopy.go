@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// A exportação de um arquivo sem cifra não passa os blocos um a um por um buffer: cada sequência contígua
+// da cadeia é um trecho da imagem (io.SectionReader) copiado de uma vez para o destino. Entre arquivos
+// locais, o trecho é copiado pelo kernel (veja copyFileSection); de uma imagem mapeada na memória, é escrito
+// direto do mapeamento; nos demais casos, io.Copy escolhe o caminho, inclusive o ReadFrom do destino.
+
+// sectionWriter é implementado pelos armazenamentos que escrevem um trecho da imagem em w sem cópias
+// intermediárias.
+type sectionWriter interface {
+	writeSection(w io.Writer, off, n int64) (int64, error)
+}
+
+// baseStore retorna o armazenamento sob os envoltórios do índice e da replicação, que só interferem nas
+// escritas.
+func baseStore(s imageStore) imageStore {
+	for {
+		switch wrapper := s.(type) {
+		case *metaIndex:
+			s = wrapper.imageStore
+		case *replicaStore:
+			s = wrapper.imageStore
+		default:
+			return s
+		}
+	}
+}
+
+// copySections escreve em w os size primeiros bytes dos blocos, copiando cada sequência contígua como um
+// trecho da imagem.
+func (fs *FURGFileSystem) copySections(blocks []uint32, size uint32, w io.Writer) error {
+	store := baseStore(fs.FilePointer)
+	remaining := int64(size)
+	for _, run := range blockRuns(blocks) {
+		if remaining == 0 {
+			break
+		}
+		section := io.NewSectionReader(store, fs.blockOffset(run.Start), min(int64(run.Length)*int64(fs.Header.BlockSize), remaining))
+		written, err := copySection(w, section)
+		if err != nil {
+			return fmt.Errorf("erro ao escrever dados no arquivo destino: %v", err)
+		}
+		if written < section.Size() {
+			return fmt.Errorf("erro ao ler bloco %d: %v", run.Start, io.ErrUnexpectedEOF)
+		}
+		remaining -= written
+	}
+	return nil
+}
+
+// copySection copia o trecho para w pelo caminho mais direto que o armazenamento e o destino permitem.
+func copySection(w io.Writer, section *io.SectionReader) (int64, error) {
+	store, off, n := section.Outer()
+	if s, ok := store.(sectionWriter); ok {
+		return s.writeSection(w, off, n)
+	}
+	var written int64
+	if src, ok := store.(*os.File); ok {
+		if dst, ok := w.(*os.File); ok {
+			written = copyFileSection(dst, src, off, n)
+		}
+	}
+	// O que o kernel não copiou, por não ser suportado ou por erro, segue pelo caminho comum, que informa
+	// o erro se ele persistir
+	rest, err := io.Copy(w, io.NewSectionReader(store, off+written, n-written))
+	return written + rest, err
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyFileSection copia n bytes de src, a partir de off, para a posição atual de dst sem passá-los pela
+// memória do processo: com copy_file_range, que no mesmo sistema de arquivos pode até compartilhar os
+// blocos, ou com sendfile, que aceita destinos como pipes. A posição de src não é usada nem alterada.
+// Retorna quantos bytes foram copiados; o restante, se houver, fica para a cópia comum.
+func copyFileSection(dst, src *os.File, off, n int64) int64 {
+	var written int64
+	for _, copyRange := range []func(int) (int, error){
+		func(remaining int) (int, error) {
+			return unix.CopyFileRange(int(src.Fd()), &off, int(dst.Fd()), nil, remaining, 0)
+		},
+		func(remaining int) (int, error) {
+			return unix.Sendfile(int(dst.Fd()), int(src.Fd()), &off, remaining)
+		},
+	} {
+		for written < n {
+			copied, err := copyRange(int(min(n-written, 1<<30)))
+			if err != nil || copied == 0 {
+				break
+			}
+			written += int64(copied)
+		}
+	}
+	return written
+}